@@ -0,0 +1,382 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"go-web-app/dao/mysql"
+	"go-web-app/logic"
+	"go-web-app/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+)
+
+// AdminUserListHandler 最近注册的用户
+func AdminUserListHandler(c *gin.Context) {
+	page, size, err := getPageInfo(c)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	data, err := logic.GetRecentUsers(page, size)
+	if err != nil {
+		zap.L().Error("logic.GetRecentUsers failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// AdminBanUserHandler 封禁用户
+func AdminBanUserHandler(c *gin.Context) {
+	uid, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	adminID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if err := logic.BanUser(uid); err != nil {
+		zap.L().Error("logic.BanUser failed", zap.Int64("uid", uid), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	auditAs(c, adminID, models.AuditActionBanUser, userTarget(uid))
+	ResponseSuccess(c, nil)
+}
+
+// AdminMergeUserHandler 把路径中的用户合并到target_id，用于同一个人重复注册的账号
+func AdminMergeUserHandler(c *gin.Context) {
+	uid, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	adminID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	p := new(models.ParamAdminMerge)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("merge user with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	data, err := logic.MergeUsers(uid, p.TargetID)
+	if err != nil {
+		zap.L().Error("logic.MergeUsers failed", zap.Int64("from", uid), zap.Int64("to", p.TargetID), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	auditAs(c, adminID, models.AuditActionMergeUser, userTarget(uid)+"->"+userTarget(p.TargetID))
+	ResponseSuccess(c, data)
+}
+
+// AdminRemoveUserContentHandler 批量删除用户在所有社区发表的帖子和评论
+func AdminRemoveUserContentHandler(c *gin.Context) {
+	uid, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	handleRemoveUserContent(c, uid, 0)
+}
+
+// AdminAddFeaturedHandler 把社区加到首页推荐列表的最后
+func AdminAddFeaturedHandler(c *gin.Context) {
+	handleFeatured(c, logic.AddFeaturedCommunity)
+}
+
+// AdminRemoveFeaturedHandler 取消推荐社区
+func AdminRemoveFeaturedHandler(c *gin.Context) {
+	handleFeatured(c, logic.RemoveFeaturedCommunity)
+}
+
+func handleFeatured(c *gin.Context, action func(communityID int64) error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	if err := action(id); err != nil {
+		zap.L().Error("featured community action failed", zap.Int64("community_id", id), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// AdminReorderFeaturedHandler 调整推荐社区的顺序
+func AdminReorderFeaturedHandler(c *gin.Context) {
+	p := new(models.ParamFeaturedOrder)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("reorder featured communities with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	if err := logic.ReorderFeaturedCommunities(p.CommunityIDs); err != nil {
+		zap.L().Error("logic.ReorderFeaturedCommunities failed", zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// AdminCreateCommunityHandler 创建社区，名称已被使用时和格式错误一样返回字段的错误信息
+func AdminCreateCommunityHandler(c *gin.Context) {
+	p := new(models.ParamCreateCommunity)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("create community with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	data, err := logic.CreateCommunity(p)
+	if errors.Is(err, mysql.ErrorCommunityExist) {
+		ResponseFieldError(c, "name", "taken")
+		return
+	}
+	if err != nil {
+		zap.L().Error("logic.CreateCommunity failed", zap.String("name", p.Name), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	audit(c, models.AuditActionCreateCommunity, communityTarget(data.ID))
+	ResponseSuccess(c, data)
+}
+
+// communityImportMax 每次最多导入多少个社区
+const communityImportMax = 500
+
+// AdminImportCommunitiesHandler 批量创建社区，请求体为ParamCreateCommunity的数组
+// 每一项使用和单个创建相同的校验，没有通过的跳过并在结果中返回原因，已经存在的社区不修改，可以重复执行
+func AdminImportCommunitiesHandler(c *gin.Context) {
+	var list []*models.ParamCreateCommunity
+	if err := c.ShouldBindJSON(&list); err != nil || len(list) == 0 || len(list) > communityImportMax {
+		zap.L().Error("import communities with invalid param", zap.Int("count", len(list)), zap.Error(err))
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	result := &models.CommunityImportResult{Items: make([]*models.CommunityImportItem, len(list))}
+	valid := make([]*models.ParamCreateCommunity, 0, len(list))
+	validItems := make([]*models.CommunityImportItem, 0, len(list))
+	for i, p := range list {
+		if p == nil {
+			p = new(models.ParamCreateCommunity)
+		}
+		p.Name = strings.TrimSpace(p.Name)
+		item := &models.CommunityImportItem{Name: p.Name}
+		result.Items[i] = item
+		if err := binding.Validator.ValidateStruct(p); err != nil {
+			item.Status = models.CommunityImportInvalid
+			if errs, ok := err.(validator.ValidationErrors); ok {
+				item.Errors = removeTopStruct(errs.Translate(translatorFor(c)))
+			}
+			result.Invalid++
+			continue
+		}
+		valid = append(valid, p)
+		validItems = append(validItems, item)
+	}
+	ids, created, err := logic.ImportCommunities(valid)
+	if err != nil {
+		zap.L().Error("logic.ImportCommunities failed", zap.Int("count", len(valid)), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	for i, item := range validItems {
+		item.CommunityID = ids[i]
+		if created[i] {
+			item.Status = models.CommunityImportCreated
+			result.Created++
+			audit(c, models.AuditActionCreateCommunity, communityTarget(ids[i]))
+		} else {
+			item.Status = models.CommunityImportExists
+			result.Exists++
+		}
+	}
+	ResponseSuccess(c, result)
+}
+
+// AdminStatsHandler 网站的总体数据
+func AdminStatsHandler(c *gin.Context) {
+	data, err := logic.GetSiteStats()
+	if err != nil {
+		zap.L().Error("logic.GetSiteStats failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// AdminPurgeHandler 立即执行一次过期数据的清理，返回每类数据删除的条数
+func AdminPurgeHandler(c *gin.Context) {
+	adminID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	result, err := logic.RunPurge()
+	if err != nil {
+		zap.L().Error("logic.RunPurge failed", zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	auditAs(c, adminID, models.AuditActionPurge, "")
+	ResponseSuccess(c, result)
+}
+
+// AdminAuditLogHandler 查询审计日志，可以按操作者、操作类型和时间范围过滤
+func AdminAuditLogHandler(c *gin.Context) {
+	p := new(models.ParamAuditQuery)
+	if err := c.ShouldBindQuery(p); err != nil {
+		zap.L().Error("query audit logs with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	var err error
+	if p.Page, p.Size, err = getPageInfo(c); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	data, total, err := logic.GetAuditLogs(p)
+	if err != nil {
+		zap.L().Error("logic.GetAuditLogs failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccessWithPage(c, data, p.Page, p.Size, total)
+}
+
+// AdminGetMaintenanceHandler 当前的维护模式，没有开启时data为空
+func AdminGetMaintenanceHandler(c *gin.Context) {
+	ResponseSuccess(c, logic.GetMaintenance())
+}
+
+// AdminSetMaintenanceHandler 开启或关闭维护模式，所有实例在几秒内生效
+func AdminSetMaintenanceHandler(c *gin.Context) {
+	p := new(models.ParamMaintenance)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("set maintenance with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	adminID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	m, err := logic.SetMaintenance(p)
+	if err != nil {
+		zap.L().Error("logic.SetMaintenance failed", zap.String("mode", p.Mode), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	auditAs(c, adminID, models.AuditActionMaintenance, p.Mode)
+	ResponseSuccess(c, m)
+}
+
+// AdminFeatureFlagListHandler 所有功能开关
+func AdminFeatureFlagListHandler(c *gin.Context) {
+	data, err := logic.GetFeatureFlags()
+	if err != nil {
+		zap.L().Error("logic.GetFeatureFlags failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// AdminSetFeatureFlagHandler 创建或修改功能开关，percent为开启的用户比例
+func AdminSetFeatureFlagHandler(c *gin.Context) {
+	p := new(models.ParamFeatureFlag)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("set feature flag with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	adminID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	name := c.Param("name")
+	f, err := logic.SetFeatureFlag(name, p)
+	if err != nil {
+		zap.L().Error("logic.SetFeatureFlag failed", zap.String("name", name), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	auditAs(c, adminID, models.AuditActionFeatureFlag, featureTarget(f.Name))
+	ResponseSuccess(c, f)
+}
+
+// AdminDeleteFeatureFlagHandler 删除功能开关
+func AdminDeleteFeatureFlagHandler(c *gin.Context) {
+	adminID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	name := c.Param("name")
+	if err := logic.DeleteFeatureFlag(name); err != nil {
+		zap.L().Error("logic.DeleteFeatureFlag failed", zap.String("name", name), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	auditAs(c, adminID, models.AuditActionFeatureFlag, featureTarget(name))
+	ResponseSuccess(c, nil)
+}
+
+// AdminExportPostsHandler 按条件导出帖子，每行一个JSON对象(NDJSON)，边查询边写给客户端
+// 开始写入之后出错只能中断响应，客户端根据最后一行是否完整判断
+func AdminExportPostsHandler(c *gin.Context) {
+	p := new(models.ParamPostExport)
+	if err := c.ShouldBindQuery(p); err != nil {
+		zap.L().Error("export posts with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	if !p.From.IsZero() && !p.To.IsZero() && !p.From.Before(p.To) {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	started := false
+	enc := json.NewEncoder(c.Writer)
+	emit := func(row *models.PostExport) error {
+		if !started {
+			started = true
+			c.Header("Content-Type", "application/x-ndjson")
+			c.Header("Cache-Control", "no-store")
+			c.Status(http.StatusOK)
+		}
+		return enc.Encode(row)
+	}
+	flush := func() error {
+		if started {
+			c.Writer.Flush()
+		}
+		// 客户端断开后不再继续查询
+		return c.Request.Context().Err()
+	}
+	err := logic.ExportPosts(c.Request.Context(), p, emit, flush)
+	if err != nil {
+		zap.L().Error("logic.ExportPosts failed", zap.Any("param", p), zap.Bool("started", started), zap.Error(err))
+		if !started {
+			ResponseErrorFrom(c, err)
+		}
+		return
+	}
+	if !started {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(http.StatusOK)
+	}
+}
@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"encoding/json"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminImportCommunitiesValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	require.NoError(t, InitValidator("en"))
+	old := settings.Conf.CommunityConfig
+	settings.Conf.CommunityConfig = &settings.CommunityConfig{NameMinLen: 2, NameMaxLen: 8, ReservedNames: []string{"admin"}}
+	defer func() { settings.Conf.CommunityConfig = old }()
+
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		AdminImportCommunitiesHandler(c)
+		return w
+	}
+
+	// 每一项单独校验，没有通过的不影响其他项
+	w := post(`[{"name":" Admin "},{"name":"a b"},{"name":"cs","visibility":"secret"},null]`)
+	var res struct {
+		Code ResCode                      `json:"code"`
+		Data models.CommunityImportResult `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, CodeSuccess, res.Code)
+	assert.Equal(t, 4, res.Data.Invalid)
+	require.Len(t, res.Data.Items, 4)
+	assert.Equal(t, "Admin", res.Data.Items[0].Name)
+	assert.Equal(t, models.CommunityImportInvalid, res.Data.Items[0].Status)
+	assert.Contains(t, res.Data.Items[0].Errors, "name")
+	assert.Contains(t, res.Data.Items[2].Errors, "visibility")
+	assert.Contains(t, res.Data.Items[3].Errors, "name")
+
+	for _, body := range []string{`[]`, `{"name":"cs"}`, `not json`} {
+		w = post(body)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		assert.Equal(t, CodeInvalidParam, res.Code, body)
+	}
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"go-web-app/logic"
+	"go-web-app/settings"
+	"io"
+	"io/ioutil"
+	"mime"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// UploadAttachmentHandler 帖子作者上传附件，表单字段为file
+func UploadAttachmentHandler(c *gin.Context) {
+	pid, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		zap.L().Error("c.FormFile(file) failed", zap.Error(err))
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	maxSize := settings.Current().AttachmentConfig.MaxSize * 1024
+	if file.Size > maxSize {
+		ResponseError(c, CodeFileTooLarge)
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		zap.L().Error("file.Open() failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	defer f.Close()
+	// 不相信客户端声明的大小，最多读取maxSize+1个字节
+	data, err := ioutil.ReadAll(io.LimitReader(f, maxSize+1))
+	if err != nil {
+		zap.L().Error("read attachment failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	if int64(len(data)) > maxSize {
+		ResponseError(c, CodeFileTooLarge)
+		return
+	}
+	a, err := logic.UploadAttachment(userID, pid, file.Filename, data)
+	if err != nil {
+		zap.L().Error("logic.UploadAttachment failed", zap.Int64("pid", pid), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, a)
+}
+
+// GetPostAttachmentsHandler 帖子的附件和下载地址
+func GetPostAttachmentsHandler(c *gin.Context) {
+	pid, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, _ := GetCurrentUserID(c)
+	list, err := logic.GetPostAttachments(c.Request.Context(), userID, pid)
+	if err != nil {
+		zap.L().Error("logic.GetPostAttachments failed", zap.Int64("pid", pid), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, list)
+}
+
+// DownloadAttachmentHandler 下载附件，Content-Type按保存时判断的类型返回
+func DownloadAttachmentHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, _ := GetCurrentUserID(c)
+	a, path, err := logic.GetAttachmentFile(c.Request.Context(), userID, id)
+	if err != nil {
+		zap.L().Error("logic.GetAttachmentFile failed", zap.Int64("id", id), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	c.Header("Content-Type", a.ContentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.FileName}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.File(path)
+}
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"go-web-app/logic"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// audit 记录当前登录用户的敏感操作，写入是异步的，不影响请求
+func audit(c *gin.Context, action, target string) {
+	userID, _ := GetCurrentUserID(c)
+	auditAs(c, userID, action, target)
+}
+
+// auditAs 用于登录等还没有登录用户的请求，由调用方指定操作者
+func auditAs(c *gin.Context, actorID int64, action, target string) {
+	logic.RecordAudit(actorID, action, target, c.ClientIP())
+}
+
+func userTarget(uid int64) string {
+	return "user:" + strconv.FormatInt(uid, 10)
+}
+
+func postTarget(pid int64) string {
+	return "post:" + strconv.FormatInt(pid, 10)
+}
+
+func communityTarget(communityID int64) string {
+	return "community:" + strconv.FormatInt(communityID, 10)
+}
+
+func memberTarget(communityID, uid int64) string {
+	return communityTarget(communityID) + "/" + userTarget(uid)
+}
+
+func webhookTarget(communityID, id int64) string {
+	return communityTarget(communityID) + "/webhook:" + strconv.FormatInt(id, 10)
+}
+
+func featureTarget(name string) string {
+	return "feature:" + name
+}
@@ -0,0 +1,36 @@
+package controller
+
+import (
+	"go-web-app/logic"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func BlockHandler(c *gin.Context) {
+	handleBlock(c, logic.Block)
+}
+
+func UnblockHandler(c *gin.Context) {
+	handleBlock(c, logic.Unblock)
+}
+
+func handleBlock(c *gin.Context, action func(userID, targetID int64) error) {
+	targetID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if err := action(userID, targetID); err != nil {
+		zap.L().Error("block action failed", zap.Int64("userID", userID), zap.Int64("targetID", targetID), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
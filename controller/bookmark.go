@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"go-web-app/logic"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func SavePostHandler(c *gin.Context) {
+	handleBookmark(c, logic.SavePost)
+}
+
+func UnsavePostHandler(c *gin.Context) {
+	handleBookmark(c, logic.UnsavePost)
+}
+
+func handleBookmark(c *gin.Context, action func(userID, pid int64) error) {
+	pid, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if err := action(userID, pid); err != nil {
+		zap.L().Error("bookmark action failed", zap.Int64("userID", userID), zap.Int64("pid", pid), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// SavedPostListHandler 当前用户收藏的帖子
+func SavedPostListHandler(c *gin.Context) {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	page, size, err := getPageInfo(c)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	data, total, err := logic.GetSavedPosts(c.Request.Context(), userID, page, size)
+	if err != nil {
+		zap.L().Error("logic.GetSavedPosts failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	logic.FillUserVotes(c.Request.Context(), userID, data)
+	ResponseSuccessWithPage(c, data, page, size, total)
+}
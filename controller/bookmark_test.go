@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleBookmark(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var gotUser, gotPost int64
+	run := func(id string, login bool, err error) (*httptest.ResponseRecorder, ResCode) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+		c.Params = gin.Params{{Key: "id", Value: id}}
+		if login {
+			c.Set(ContextUserIDKey, int64(7))
+		}
+		handleBookmark(c, func(userID, pid int64) error {
+			gotUser, gotPost = userID, pid
+			return err
+		})
+		var res struct {
+			Code ResCode `json:"code"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		return w, res.Code
+	}
+
+	_, code := run("42", true, nil)
+	assert.Equal(t, CodeSuccess, code)
+	assert.EqualValues(t, 7, gotUser)
+	assert.EqualValues(t, 42, gotPost)
+
+	_, code = run("abc", true, nil)
+	assert.Equal(t, CodeInvalidParam, code)
+	_, code = run("42", false, nil)
+	assert.Equal(t, CodeNeedLogin, code)
+	// 帖子不存在时返回404
+	w, code := run("43", true, sql.ErrNoRows)
+	assert.Equal(t, CodeNotFound, code)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
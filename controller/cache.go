@@ -0,0 +1,19 @@
+package controller
+
+import (
+	"context"
+	"go-web-app/logic"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cachedResponse 按接口的缓存策略读取load的结果，params为影响结果的参数，调用方需要先补全默认值
+// 登录和未登录的请求分开缓存，和当前用户有关的字段需要在读取缓存之后再补充
+func cachedResponse(c *gin.Context, name string, load func(ctx context.Context) (interface{}, error), params ...string) (interface{}, error) {
+	scope := "anon"
+	if _, err := GetCurrentUserID(c); err == nil {
+		scope = "auth"
+	}
+	return logic.CachedResponse(c.Request.Context(), name, scope+"|"+strings.Join(params, "|"), load)
+}
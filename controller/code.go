@@ -1,5 +1,7 @@
 package controller
 
+import "net/http"
+
 type ResCode int64
 
 const (
@@ -12,6 +14,45 @@ const (
 
 	CodeNeedLogin
 	CodeInvalidToken
+	CodeInvalidResetToken
+	CodeEmailExist
+	CodeEmailNotVerified
+	CodeInvalidVerifyToken
+	CodeTooManyRequests
+	CodeNoPermission
+	CodeNotFound
+	CodeFileTooLarge
+	CodeCommentLocked
+	CodeRequestTooLarge
+	CodeRequestTimeout
+	CodeAccountDeactivated
+	CodeInvalidReactivateToken
+	CodeReportExists
+	CodeIdempotencyConflict
+	CodeInappropriateContent
+	CodeAccountBanned
+	CodeLoginLocked
+	CodeVersionConflict
+	CodeMaintenance
+	CodeInvalidCaptcha
+	CodeAccountTooNew
+	CodeInvalidMergeToken
+	CodeDuplicatePost
+	CodePrivateCommunity
+	CodeJoinApprovalRequired
+	CodeServiceUnavailable
+	CodeInvalidCSRFToken
+	CodeEditWindowExpired
+	CodeSignupUnavailable
+	CodeThreadClosed
+	CodePostNotAllowed
+	CodeAnonymousNotAllowed
+	CodeKarmaTooLow
+	CodeTwoFactorRequired
+	CodeInvalidTwoFactorCode
+	CodeSpamLink
+	CodeVoteUndoExpired
+	CodeModQueueConflict
 )
 
 var codeMsgMap = map[ResCode]string{
@@ -23,6 +64,79 @@ var codeMsgMap = map[ResCode]string{
 	CodeServerBusy:      "Interval error",
 	CodeNeedLogin:       "Login required",
 	CodeInvalidToken:    "Invalid Token",
+
+	CodeInvalidResetToken:      "Reset link is invalid or expired",
+	CodeEmailExist:             "Email already registered",
+	CodeEmailNotVerified:       "Please verify your email before logging in",
+	CodeInvalidVerifyToken:     "Verification link is invalid or expired",
+	CodeTooManyRequests:        "Too many requests, please try again later",
+	CodeNoPermission:           "Permission denied",
+	CodeNotFound:               "Not found",
+	CodeFileTooLarge:           "File is too large",
+	CodeCommentLocked:          "Comments on this post are locked",
+	CodeRequestTooLarge:        "Request body is too large",
+	CodeRequestTimeout:         "Request timed out, please try again later",
+	CodeAccountDeactivated:     "Account is deactivated",
+	CodeInvalidReactivateToken: "Reactivation link is invalid or expired",
+	CodeReportExists:           "You have already reported this post",
+	CodeIdempotencyConflict:    "Idempotency-Key is already used by another request",
+	CodeInappropriateContent:   "Content contains inappropriate words",
+	CodeAccountBanned:          "Account is banned",
+	CodeLoginLocked:            "Too many failed login attempts, please try again later",
+	CodeVersionConflict:        "Post was modified by someone else, please reload and try again",
+	CodeMaintenance:            "Service is under maintenance, please try again later",
+	CodeInvalidCaptcha:         "Captcha verification failed, please try again",
+	CodeAccountTooNew:          "Your account is too new for this action",
+	CodeInvalidMergeToken:      "Merge token is invalid or expired",
+	CodeDuplicatePost:          "You have already posted this recently",
+	CodePrivateCommunity:       "This community is private, join it to see its posts",
+	CodeJoinApprovalRequired:   "Joining this community requires an invite or a moderator's approval",
+	CodeServiceUnavailable:     "Service is temporarily unavailable, please try again later",
+	CodeInvalidCSRFToken:       "CSRF token is missing or invalid",
+	CodeEditWindowExpired:      "This can no longer be edited",
+	CodeSignupUnavailable:      "Registration is not available right now",
+	CodeThreadClosed:           "This thread is closed to new comments",
+	CodePostNotAllowed:         "You don't have permission to post in this community",
+	CodeAnonymousNotAllowed:    "This community doesn't allow anonymous posts",
+	CodeKarmaTooLow:            "You don't have enough karma for this action",
+	CodeTwoFactorRequired:      "Two-factor authentication code required",
+	CodeInvalidTwoFactorCode:   "Invalid two-factor authentication code",
+	CodeSpamLink:               "Content contains links that are not allowed",
+	CodeVoteUndoExpired:        "This vote can no longer be undone",
+	CodeModQueueConflict:       "This item is already handled by another moderator",
+}
+
+// codeStatusMap 需要用HTTP状态码表达的错误，没有列出的错误码返回200，客户端根据code判断
+var codeStatusMap = map[ResCode]int{
+	CodeNotFound:             http.StatusNotFound,
+	CodeTooManyRequests:      http.StatusTooManyRequests,
+	CodeLoginLocked:          http.StatusTooManyRequests,
+	CodeIdempotencyConflict:  http.StatusConflict,
+	CodeVersionConflict:      http.StatusConflict,
+	CodeDuplicatePost:        http.StatusConflict,
+	CodeFileTooLarge:         http.StatusRequestEntityTooLarge,
+	CodeRequestTooLarge:      http.StatusRequestEntityTooLarge,
+	CodeRequestTimeout:       http.StatusServiceUnavailable,
+	CodeMaintenance:          http.StatusServiceUnavailable,
+	CodeAccountTooNew:        http.StatusForbidden,
+	CodePrivateCommunity:     http.StatusForbidden,
+	CodeJoinApprovalRequired: http.StatusForbidden,
+	CodeServiceUnavailable:   http.StatusServiceUnavailable,
+	CodeInvalidCSRFToken:     http.StatusForbidden,
+	CodeEditWindowExpired:    http.StatusForbidden,
+	CodeVoteUndoExpired:      http.StatusForbidden,
+	CodeModQueueConflict:     http.StatusConflict,
+	CodeSignupUnavailable:    http.StatusForbidden,
+	CodePostNotAllowed:       http.StatusForbidden,
+	CodeKarmaTooLow:          http.StatusForbidden,
+}
+
+// Status 错误码对应的HTTP状态码
+func (rescode ResCode) Status() int {
+	if status, ok := codeStatusMap[rescode]; ok {
+		return status
+	}
+	return http.StatusOK
 }
 
 func (rescode ResCode) Msg() string {
@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"go-web-app/logic"
+	"go-web-app/models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func CreateCommentHandler(c *gin.Context) {
+	p := new(models.ParamCreateComment)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("create comment with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	sanitizeFields(&p.Content)
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if !filterContent(c, &p.Content) {
+		return
+	}
+	if !allowCreation(c, userID, logic.CreationKindComment) {
+		return
+	}
+	comment, err := logic.CreateComment(c.Request.Context(), userID, p)
+	if err != nil {
+		zap.L().Error("logic.CreateComment failed", zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, comment)
+}
+
+// GetCommentListHandler 帖子的评论树，?sort=best|new|old|controversial，没有传时按best排序，每一层的回复分别排序
+func GetCommentListHandler(c *gin.Context) {
+	pid, err := strconv.ParseInt(c.Param("postID"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	page, size, err := getPageInfo(c)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	p := new(models.ParamCommentSort)
+	if err := c.ShouldBindQuery(p); err != nil {
+		ResponseBindError(c, err)
+		return
+	}
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if err := logic.CheckPostIDAccess(c.Request.Context(), userID, pid); err != nil {
+		zap.L().Warn("logic.CheckPostIDAccess failed", zap.Int64("pid", pid), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	data, total, err := logic.GetCommentList(c.Request.Context(), pid, page, size, p.Sort)
+	if err != nil {
+		zap.L().Error("logic.GetCommentList failed", zap.Int64("pid", pid), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	data = logic.FilterBlockedComments(userID, data)
+	ResponseSuccessWithPage(c, selectFields(c, fieldsComment, data), page, size, total)
+}
+
+// UpdateCommentHandler 作者编辑自己的评论，内容的规则与发表时相同
+func UpdateCommentHandler(c *gin.Context) {
+	cid, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	p := new(models.ParamUpdateComment)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("update comment with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	sanitizeFields(&p.Content)
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if !filterContent(c, &p.Content) {
+		return
+	}
+	comment, err := logic.UpdateComment(userID, cid, p)
+	if err != nil {
+		zap.L().Error("logic.UpdateComment failed", zap.Int64("cid", cid), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, comment)
+}
+
+func DeleteCommentHandler(c *gin.Context) {
+	cid, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if err := logic.DeleteComment(userID, cid); err != nil {
+		zap.L().Error("logic.DeleteComment failed", zap.Int64("cid", cid), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
@@ -1,7 +1,12 @@
 package controller
 
 import (
+	"context"
+	"errors"
+	"go-web-app/dao/mysql"
 	"go-web-app/logic"
+	"go-web-app/models"
+	"io"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -9,12 +14,30 @@ import (
 )
 
 func CommunityHandler(c *gin.Context) {
-	data, err := logic.GetCommunityList()
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	data, err := logic.GetCommunityList(userID)
 	if err != nil {
 		zap.L().Error("logic.GetCommunityList() failed", zap.Error(err))
 		ResponseError(c, CodeServerBusy)
 		return
 	}
+	ResponseSuccess(c, selectFields(c, fieldsCommunity, data))
+}
+
+// TrendingCommunitiesHandler 最近一段时间内新帖子和新成员最多的社区
+func TrendingCommunitiesHandler(c *gin.Context) {
+	data, err := cachedResponse(c, logic.CacheTrendingCommunities, func(ctx context.Context) (interface{}, error) {
+		return logic.GetTrendingCommunities(ctx)
+	})
+	if err != nil {
+		zap.L().Error("logic.GetTrendingCommunities() failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
 	ResponseSuccess(c, data)
 }
 
@@ -25,11 +48,483 @@ func CommunityDetailHandler(c *gin.Context) {
 		ResponseError(c, CodeInvalidParam)
 		return
 	}
-	data, err := logic.GetCommunityDetail(id)
+	data, err := logic.GetCommunityDetail(c.Request.Context(), id)
 	if err != nil {
-		zap.L().Error("logic.GetCommunityList() failed", zap.Error(err))
+		zap.L().Error("logic.GetCommunityDetail() failed", zap.Int64("community_id", id), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, selectFields(c, fieldsCommunity, data))
+}
+
+// FeaturedCommunitiesHandler 首页推荐的社区，按管理员设置的顺序
+func FeaturedCommunitiesHandler(c *gin.Context) {
+	data, err := logic.GetFeaturedCommunities(c.Request.Context())
+	if err != nil {
+		zap.L().Error("logic.GetFeaturedCommunities failed", zap.Error(err))
 		ResponseError(c, CodeServerBusy)
 		return
 	}
+	ResponseSuccess(c, selectFields(c, fieldsCommunity, data))
+}
+
+// CommunityPostListHandler 社区内的帖子列表，分页和排序参数与全站帖子列表一致
+func CommunityPostListHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	p := &models.ParamPostList{Order: models.OrderTime}
+	if err := c.ShouldBindQuery(p); err != nil {
+		zap.L().Error("Invalid params at CommunityPostListHandler", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	if p.Page, p.Size, err = getPageInfo(c); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	p.CommunityID = id
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	data, hasMore, err := logic.GetCommunityFeed(c.Request.Context(), userID, p)
+	if err != nil {
+		zap.L().Error("logic.GetCommunityFeed() failed", zap.Int64("community_id", id), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccessWithMore(c, selectFields(c, fieldsPost, personalizePosts(c, data)), p.Page, p.Size, hasMore)
+}
+
+func JoinCommunityHandler(c *gin.Context) {
+	handleMembership(c, logic.JoinCommunity)
+}
+
+func LeaveCommunityHandler(c *gin.Context) {
+	handleMembership(c, func(_ context.Context, userID, communityID int64) error {
+		return logic.LeaveCommunity(userID, communityID)
+	})
+}
+
+// MuteCommunityHandler 在全站和首页信息流中隐藏社区的帖子，不影响社区成员身份
+func MuteCommunityHandler(c *gin.Context) {
+	handleMembership(c, logic.MuteCommunity)
+}
+
+func UnmuteCommunityHandler(c *gin.Context) {
+	handleMembership(c, func(_ context.Context, userID, communityID int64) error {
+		return logic.UnmuteCommunity(userID, communityID)
+	})
+}
+
+func handleMembership(c *gin.Context, action func(ctx context.Context, userID, communityID int64) error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if err := action(c.Request.Context(), userID, id); err != nil {
+		zap.L().Error("community membership action failed", zap.Int64("userID", userID), zap.Int64("community_id", id), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+func PromoteModeratorHandler(c *gin.Context) {
+	handleModerator(c, func(communityID, userID int64) error {
+		if err := logic.PromoteModerator(communityID, userID); err != nil {
+			return err
+		}
+		audit(c, models.AuditActionPromoteMod, memberTarget(communityID, userID))
+		return nil
+	})
+}
+
+func DemoteModeratorHandler(c *gin.Context) {
+	handleModerator(c, func(communityID, userID int64) error {
+		if err := logic.DemoteModerator(communityID, userID); err != nil {
+			return err
+		}
+		audit(c, models.AuditActionDemoteMod, memberTarget(communityID, userID))
+		return nil
+	})
+}
+
+// PromoteCommunityAdminHandler 社区管理员或站点管理员把社区成员设为社区管理员
+func PromoteCommunityAdminHandler(c *gin.Context) {
+	handleModerator(c, func(communityID, userID int64) error {
+		if err := logic.PromoteCommunityAdmin(communityID, userID); err != nil {
+			return err
+		}
+		audit(c, models.AuditActionPromoteAdmin, memberTarget(communityID, userID))
+		return nil
+	})
+}
+
+// DemoteCommunityAdminHandler 撤销社区管理员，最后一个管理员不能被撤销
+func DemoteCommunityAdminHandler(c *gin.Context) {
+	handleModerator(c, func(communityID, userID int64) error {
+		if err := logic.DemoteCommunityAdmin(communityID, userID); err != nil {
+			return err
+		}
+		audit(c, models.AuditActionDemoteAdmin, memberTarget(communityID, userID))
+		return nil
+	})
+}
+
+func handleModerator(c *gin.Context, action func(communityID, userID int64) error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	uid, err := strconv.ParseInt(c.Param("uid"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	if err := action(id, uid); err != nil {
+		zap.L().Error("moderator action failed", zap.Int64("community_id", id), zap.Int64("uid", uid), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// CreateCommunityHandler 用户创建社区并成为社区管理员，声望不够community.create_min_karma时返回需要的声望
+func CreateCommunityHandler(c *gin.Context) {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if err := logic.CheckCreateCommunityKarma(userID); err != nil {
+		ResponseErrorFrom(c, err)
+		return
+	}
+	p := new(models.ParamCreateCommunity)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("create community with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	data, err := logic.CreateUserCommunity(userID, p)
+	if errors.Is(err, mysql.ErrorCommunityExist) {
+		ResponseFieldError(c, "name", "taken")
+		return
+	}
+	if err != nil {
+		zap.L().Error("logic.CreateUserCommunity failed", zap.String("name", p.Name), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	audit(c, models.AuditActionCreateCommunity, communityTarget(data.ID))
+	ResponseSuccess(c, data)
+}
+
+// RequestJoinCommunityHandler 申请加入私有社区，公开社区和已被邀请时直接加入
+func RequestJoinCommunityHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	data, err := logic.RequestJoinCommunity(c.Request.Context(), userID, id)
+	if err != nil {
+		zap.L().Error("logic.RequestJoinCommunity failed", zap.Int64("userID", userID), zap.Int64("community_id", id), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
 	ResponseSuccess(c, data)
 }
+
+// JoinRequestListHandler 版主查看本社区等待处理的加入申请
+func JoinRequestListHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	page, size, err := getPageInfo(c)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	data, total, err := logic.GetJoinRequests(id, page, size)
+	if err != nil {
+		zap.L().Error("logic.GetJoinRequests failed", zap.Int64("community_id", id), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccessWithPage(c, data, page, size, total)
+}
+
+func ApproveJoinRequestHandler(c *gin.Context) {
+	handleJoinRequest(c, logic.ApproveJoinRequest)
+}
+
+func DenyJoinRequestHandler(c *gin.Context) {
+	handleJoinRequest(c, logic.DenyJoinRequest)
+}
+
+// InviteMemberHandler 版主邀请用户加入本社区，用户之后调用加入接口即可加入
+func InviteMemberHandler(c *gin.Context) {
+	handleJoinRequest(c, logic.InviteToCommunity)
+}
+
+func handleJoinRequest(c *gin.Context, action func(communityID, moderatorID, userID int64) error) {
+	moderatorID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	handleModerator(c, func(communityID, userID int64) error {
+		return action(communityID, moderatorID, userID)
+	})
+}
+
+// SetCommunityVisibilityHandler 社区管理员把社区设为公开或私有
+func SetCommunityVisibilityHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	p := new(models.ParamCommunityVisibility)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("set community visibility with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	if err := logic.SetCommunityVisibility(id, p.Visibility); err != nil {
+		zap.L().Error("logic.SetCommunityVisibility failed", zap.Int64("community_id", id), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	audit(c, models.AuditActionCommunityVisibility, communityTarget(id)+"/"+p.Visibility)
+	ResponseSuccess(c, nil)
+}
+
+// SetCommunityMinPostLengthHandler 版主设置社区中帖子内容的最少字符数
+func SetCommunityMinPostLengthHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	p := new(models.ParamCommunityMinPostLength)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("set community min post length with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	if err := logic.SetCommunityMinPostLength(id, *p.MinPostLength); err != nil {
+		zap.L().Error("logic.SetCommunityMinPostLength failed", zap.Int64("community_id", id), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	audit(c, models.AuditActionCommunityPostLength, communityTarget(id)+"/"+strconv.Itoa(*p.MinPostLength))
+	ResponseSuccess(c, nil)
+}
+
+// SetCommunityPostPermissionHandler 社区管理员设置谁可以在社区中发帖，只允许版主发帖时社区只用来发布公告
+func SetCommunityPostPermissionHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	p := new(models.ParamCommunityPostPermission)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("set community post permission with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	if err := logic.SetCommunityPostPermission(id, p.PostPermission); err != nil {
+		zap.L().Error("logic.SetCommunityPostPermission failed", zap.Int64("community_id", id), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	audit(c, models.AuditActionCommunityPostPerm, communityTarget(id)+"/"+p.PostPermission)
+	ResponseSuccess(c, nil)
+}
+
+// SetCommunityAnonymousHandler 版主设置社区是否允许匿名发帖
+func SetCommunityAnonymousHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	p := new(models.ParamCommunityAnonymous)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("set community anonymous with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	if err := logic.SetCommunityAllowAnonymous(id, *p.AllowAnonymous); err != nil {
+		zap.L().Error("logic.SetCommunityAllowAnonymous failed", zap.Int64("community_id", id), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	audit(c, models.AuditActionCommunityAnonymous, communityTarget(id)+"/"+strconv.FormatBool(*p.AllowAnonymous))
+	ResponseSuccess(c, nil)
+}
+
+// SetCommunityRulesHandler 版主修改社区的规则和帖子可以使用的flair
+func SetCommunityRulesHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	p := new(models.ParamCommunityRules)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("set community rules with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	sanitizeFields(&p.Rules)
+	for i := range p.Flairs {
+		sanitizeFields(&p.Flairs[i])
+	}
+	if err := logic.SetCommunityRules(id, p); err != nil {
+		zap.L().Error("logic.SetCommunityRules failed", zap.Int64("community_id", id), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	audit(c, models.AuditActionCommunityRules, communityTarget(id))
+	ResponseSuccess(c, nil)
+}
+
+// RemoveUserContentHandler 版主批量删除用户在本社区发表的帖子和评论
+func RemoveUserContentHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	uid, err := strconv.ParseInt(c.Param("uid"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	handleRemoveUserContent(c, uid, id)
+}
+
+// handleRemoveUserContent 请求体中的时间范围可以为空，communityID为0时删除所有社区中的内容
+func handleRemoveUserContent(c *gin.Context, uid, communityID int64) {
+	p := new(models.ParamRemoveContent)
+	if err := c.ShouldBindJSON(p); err != nil && err != io.EOF {
+		zap.L().Error("remove user content with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	if !p.From.IsZero() && !p.To.IsZero() && !p.To.After(p.From) {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	data, err := logic.RemoveUserContent(uid, communityID, p)
+	if err != nil {
+		zap.L().Error("logic.RemoveUserContent failed", zap.Int64("uid", uid), zap.Int64("community_id", communityID), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	target := userTarget(uid)
+	if communityID != 0 {
+		target = memberTarget(communityID, uid)
+	}
+	audit(c, models.AuditActionRemoveContent, target)
+	ResponseSuccess(c, data)
+}
+
+// RemoveCommunityPostHandler 版主删除本社区的帖子
+func RemoveCommunityPostHandler(c *gin.Context) {
+	handleModeratePost(c, func(communityID, pid int64) error {
+		if err := logic.RemoveCommunityPost(communityID, pid); err != nil {
+			return err
+		}
+		audit(c, models.AuditActionRemovePost, postTarget(pid))
+		return nil
+	})
+}
+
+func LockCommentsHandler(c *gin.Context) {
+	handleModeratePost(c, func(communityID, pid int64) error {
+		return logic.SetCommentLocked(communityID, pid, true)
+	})
+}
+
+func UnlockCommentsHandler(c *gin.Context) {
+	handleModeratePost(c, func(communityID, pid int64) error {
+		return logic.SetCommentLocked(communityID, pid, false)
+	})
+}
+
+func PinPostHandler(c *gin.Context) {
+	handleModeratePost(c, func(communityID, pid int64) error {
+		return logic.PinPost(communityID, pid)
+	})
+}
+
+func UnpinPostHandler(c *gin.Context) {
+	handleModeratePost(c, func(communityID, pid int64) error {
+		return logic.UnpinPost(communityID, pid)
+	})
+}
+
+// RevealPostAuthorHandler 版主查看本社区中匿名帖子的真实作者，每次查看都记录到审计日志
+func RevealPostAuthorHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	pid, err := strconv.ParseInt(c.Param("pid"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	author, err := logic.RevealPostAuthor(c.Request.Context(), id, pid)
+	if err != nil {
+		zap.L().Error("logic.RevealPostAuthor failed", zap.Int64("community_id", id), zap.Int64("pid", pid), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	audit(c, models.AuditActionRevealAuthor, postTarget(pid))
+	ResponseSuccess(c, author)
+}
+
+func handleModeratePost(c *gin.Context, action func(communityID, pid int64) error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	pid, err := strconv.ParseInt(c.Param("pid"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	if err := action(id, pid); err != nil {
+		zap.L().Error("moderate post failed", zap.Int64("community_id", id), zap.Int64("pid", pid), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"go-web-app/settings"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// CSRFTokenHandler 返回当前的CSRF token，cookie中没有时签发新的
+// 前端在修改请求的csrf.header_name请求头中带上返回的token
+func CSRFTokenHandler(c *gin.Context) {
+	cfg := settings.Current().CSRFConfig
+	if token, err := c.Cookie(cfg.CookieName); err == nil && token != "" {
+		ResponseSuccess(c, gin.H{"token": token, "header": cfg.HeaderName})
+		return
+	}
+	issueCSRFToken(c, cfg)
+}
+
+// RotateCSRFTokenHandler 重新签发CSRF token，例如登录之后，之前的token立即失效
+func RotateCSRFTokenHandler(c *gin.Context) {
+	issueCSRFToken(c, settings.Current().CSRFConfig)
+}
+
+func issueCSRFToken(c *gin.Context, cfg *settings.CSRFConfig) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		zap.L().Error("generate csrf token failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	// 浏览器会拒绝没有Secure的SameSite=None
+	sameSite := parseSameSite(cfg.SameSite)
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     cfg.CookieName,
+		Value:    token,
+		Path:     cfg.CookiePath,
+		Domain:   cfg.Domain,
+		MaxAge:   cfg.MaxAge,
+		Secure:   cfg.Secure || sameSite == http.SameSiteNoneMode,
+		HttpOnly: cfg.HttpOnly,
+		SameSite: sameSite,
+	})
+	ResponseSuccess(c, gin.H{"token": token, "header": cfg.HeaderName})
+}
+
+// parseSameSite 不认识的值按lax处理
+func parseSameSite(s string) http.SameSite {
+	switch strings.ToLower(s) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"go-web-app/dao/mongodb"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/logic"
+	"go-web-app/pkg/avatar"
+	"go-web-app/pkg/hub"
+	"go-web-app/pkg/jwt"
+	"math"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errorCodes dao和logic层返回的错误对应的错误码，按顺序使用errors.Is匹配
+// withMsg为true时使用错误本身的内容作为msg，告诉用户具体哪里不对
+var errorCodes = []struct {
+	err     error
+	code    ResCode
+	withMsg bool
+}{
+	// 资源不存在
+	{sql.ErrNoRows, CodeNotFound, false},
+	{mysql.ErrorInvalidID, CodeNotFound, false},
+	{mongodb.ErrorCommentNotExist, CodeNotFound, false},
+	{mongodb.ErrorModQueueItemNotExist, CodeNotFound, false},
+	{logic.ErrorOAuthNotConfigured, CodeNotFound, false},
+	{logic.ErrorTwoFactorNotConfigured, CodeNotFound, false},
+	{logic.ErrorSessionNotFound, CodeNotFound, false},
+
+	// 用户和登录
+	{mysql.ErrorUserExist, CodeUserExist, false},
+	{mysql.ErrorEmailExist, CodeEmailExist, false},
+	{mysql.ErrorUserNotExist, CodeUserNotExist, false},
+	{mysql.ErrorInvalidPassword, CodeInvalidPassword, false},
+	{logic.ErrorLoginLocked, CodeLoginLocked, false},
+	{logic.ErrorEmailNotVerified, CodeEmailNotVerified, false},
+	{logic.ErrorOAuthEmailNotVerified, CodeEmailNotVerified, false},
+	{logic.ErrorOAuthEmailDomain, CodeNoPermission, true},
+	{logic.ErrorAccountDeactivated, CodeAccountDeactivated, false},
+	{logic.ErrorAccountBanned, CodeAccountBanned, false},
+	{logic.ErrorVerifyResendTooFrequent, CodeTooManyRequests, false},
+	{logic.ErrorUsernameChangeTooFrequent, CodeTooManyRequests, false},
+	{logic.ErrorExportTooFrequent, CodeTooManyRequests, false},
+	{logic.ErrorCaptchaRequired, CodeInvalidCaptcha, false},
+	{logic.ErrorCaptchaInvalid, CodeInvalidCaptcha, false},
+	{logic.ErrorAccountTooNew, CodeAccountTooNew, false},
+	{logic.ErrorKarmaTooLow, CodeKarmaTooLow, true},
+	{logic.ErrorTwoFactorRequired, CodeTwoFactorRequired, false},
+	{logic.ErrorTwoFactorCodeInvalid, CodeInvalidTwoFactorCode, false},
+	{logic.ErrorSpamLink, CodeSpamLink, false},
+
+	// token
+	{jwt.ErrInvalidToken, CodeInvalidToken, false},
+	{redis.ErrRefreshTokenInvalid, CodeInvalidToken, false},
+	{logic.ErrorOAuthStateInvalid, CodeInvalidToken, false},
+	{redis.ErrPasswordResetTokenInvalid, CodeInvalidResetToken, false},
+	{redis.ErrVerifyTokenInvalid, CodeInvalidVerifyToken, false},
+	{redis.ErrReactivateTokenInvalid, CodeInvalidReactivateToken, false},
+	{redis.ErrMergeTokenInvalid, CodeInvalidMergeToken, false},
+	{redis.ErrTwoFactorChallengeInvalid, CodeInvalidToken, false},
+
+	// 权限和状态
+	{logic.ErrorNoPermission, CodeNoPermission, false},
+	{logic.ErrorCommentLocked, CodeCommentLocked, false},
+	{logic.ErrorThreadClosed, CodeThreadClosed, false},
+	{logic.ErrorCommentEditExpired, CodeEditWindowExpired, false},
+	{logic.ErrorReportExists, CodeReportExists, false},
+	{logic.ErrorModQueueConflict, CodeModQueueConflict, false},
+	{logic.ErrorIdempotencyConflict, CodeIdempotencyConflict, false},
+	{logic.ErrorVersionConflict, CodeVersionConflict, false},
+	{logic.ErrorDuplicatePost, CodeDuplicatePost, false},
+	{logic.ErrorPrivateCommunity, CodePrivateCommunity, false},
+	{logic.ErrorJoinApprovalRequired, CodeJoinApprovalRequired, false},
+	{logic.ErrorPostNotAllowed, CodePostNotAllowed, false},
+	{logic.ErrorAnonymousNotAllowed, CodeAnonymousNotAllowed, false},
+	{hub.ErrTooManyConnections, CodeTooManyRequests, false},
+	{logic.ErrorPurgeRunning, CodeTooManyRequests, false},
+	{logic.ErrorMergeRunning, CodeTooManyRequests, false},
+	{logic.ErrorRemoveContentRunning, CodeTooManyRequests, false},
+	{redis.ErrUnavailable, CodeServiceUnavailable, false},
+
+	// 参数不合法
+	{logic.ErrorInvalidTag, CodeInvalidParam, true},
+	{logic.ErrorInvalidFlair, CodeInvalidParam, true},
+	{logic.ErrorInvalidNotificationType, CodeInvalidParam, true},
+	{logic.ErrorInvalidFeatureFlag, CodeInvalidParam, true},
+	{logic.ErrorTooManyTags, CodeInvalidParam, true},
+	{logic.ErrorTooManyFollowedTags, CodeInvalidParam, true},
+	{logic.ErrorTooManyPinned, CodeInvalidParam, true},
+	{logic.ErrorTooManyFeatured, CodeInvalidParam, true},
+	{mysql.ErrorFeaturedMismatch, CodeInvalidParam, true},
+	{logic.ErrorTooManyPollOptions, CodeInvalidParam, true},
+	{logic.ErrorAttachmentType, CodeInvalidParam, true},
+	{logic.ErrorTooManyAttachments, CodeInvalidParam, true},
+	{logic.ErrorTooManyWebhooks, CodeInvalidParam, true},
+	{logic.ErrorTooManyIDs, CodeInvalidParam, true},
+	{logic.ErrorInvalidWebhookURL, CodeInvalidParam, true},
+	{logic.ErrorWebhookNotFound, CodeNotFound, true},
+	{logic.ErrorAttachmentsTooLarge, CodeFileTooLarge, false},
+	{logic.ErrorDuplicatePollOption, CodeInvalidParam, true},
+	{logic.ErrorEmptyPollOption, CodeInvalidParam, true},
+	{logic.ErrorPollExpireInPast, CodeInvalidParam, true},
+	{logic.ErrorPollClosed, CodeInvalidParam, true},
+	{logic.ErrorInvalidPollOption, CodeInvalidParam, true},
+	{logic.ErrorPublishAtInPast, CodeInvalidParam, true},
+	{logic.ErrorNotDraft, CodeInvalidParam, true},
+	{logic.ErrorNoCommunity, CodeInvalidParam, true},
+	{logic.ErrorTooManyCommunities, CodeInvalidParam, true},
+	{logic.ErrorPostTooShort, CodeInvalidParam, true},
+	{logic.ErrorPrivateCrossPost, CodeInvalidParam, true},
+	{logic.ErrorNoJoinRequest, CodeInvalidParam, true},
+	{logic.ErrorFollowSelf, CodeInvalidParam, true},
+	{logic.ErrorBlockSelf, CodeInvalidParam, true},
+	{mysql.ErrorNotMember, CodeInvalidParam, true},
+	{logic.ErrorLastCommunityAdmin, CodeInvalidParam, true},
+	{mysql.ErrorMergeSelf, CodeInvalidParam, true},
+	{mysql.ErrorUserMerged, CodeInvalidParam, true},
+	{logic.ErrorMergeEmailMismatch, CodeInvalidParam, true},
+	{logic.ErrorTwoFactorEnabled, CodeInvalidParam, true},
+	{logic.ErrorTwoFactorNotEnabled, CodeInvalidParam, true},
+	{logic.ErrorTwoFactorNotSetup, CodeInvalidParam, true},
+	{redis.ErrVoteTimeExpire, CodeInvalidParam, true},
+	{redis.ErrVoteRepeated, CodeInvalidParam, true},
+	{redis.ErrVoteUndoExpired, CodeVoteUndoExpired, false},
+	{redis.ErrPollVoted, CodeInvalidParam, true},
+	{avatar.ErrUnsupportedFormat, CodeInvalidParam, true},
+	{avatar.ErrImageTooLarge, CodeInvalidParam, true},
+}
+
+// errorCode 查找err对应的错误码和msg，没有对应的错误码时返回CodeServerBusy
+func errorCode(err error) (ResCode, string) {
+	for _, e := range errorCodes {
+		if !errors.Is(err, e.err) {
+			continue
+		}
+		if e.withMsg {
+			// 使用哨兵错误的内容，不返回包装时附加的内部信息
+			return e.code, e.err.Error()
+		}
+		return e.code, e.code.Msg()
+	}
+	return CodeServerBusy, CodeServerBusy.Msg()
+}
+
+// ResponseErrorFrom 根据dao和logic层返回的错误响应对应的错误码，对应关系见errorCodes
+func ResponseErrorFrom(c *gin.Context, err error) {
+	// 账号注册时间不够时告诉用户还要等多久
+	var tooNew *logic.AccountTooNewError
+	if errors.As(err, &tooNew) {
+		seconds := int64(math.Ceil(tooNew.Wait.Seconds()))
+		c.Header("Retry-After", strconv.FormatInt(seconds, 10))
+		writeResponse(c, CodeAccountTooNew.Status(), &ResponseData{
+			Code: CodeAccountTooNew,
+			Msg:  tooNew.Error(),
+			Data: gin.H{"retry_after": seconds},
+		})
+		return
+	}
+	// 声望不够时返回需要的声望，客户端可以提示还差多少
+	var lowKarma *logic.KarmaTooLowError
+	if errors.As(err, &lowKarma) {
+		writeResponse(c, CodeKarmaTooLow.Status(), &ResponseData{
+			Code: CodeKarmaTooLow,
+			Msg:  lowKarma.Error(),
+			Data: gin.H{"required_karma": lowKarma.Required, "karma": lowKarma.Current},
+		})
+		return
+	}
+	// 开启了两步验证的账号返回临时token，客户端用它提交验证码
+	var twoFactor *logic.TwoFactorRequiredError
+	if errors.As(err, &twoFactor) {
+		writeResponse(c, CodeTwoFactorRequired.Status(), &ResponseData{
+			Code: CodeTwoFactorRequired,
+			Msg:  CodeTwoFactorRequired.Msg(),
+			Data: twoFactor.Challenge,
+		})
+		return
+	}
+	code, msg := errorCode(err)
+	ResponseErrorWithMsg(c, code, msg)
+}
@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-web-app/dao/mysql"
+	"go-web-app/logic"
+	"go-web-app/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorCode(t *testing.T) {
+	for _, e := range errorCodes {
+		// 包装过的错误也能匹配，msg不包含包装时附加的内容
+		code, msg := errorCode(fmt.Errorf("internal detail: %w", e.err))
+		assert.Equal(t, e.code, code, e.err.Error())
+		assert.NotContains(t, msg, "internal detail")
+		if e.withMsg {
+			assert.Equal(t, e.err.Error(), msg)
+		} else {
+			assert.Equal(t, e.code.Msg(), msg)
+		}
+	}
+
+	code, msg := errorCode(errors.New("connection refused"))
+	assert.Equal(t, CodeServerBusy, code)
+	assert.Equal(t, CodeServerBusy.Msg(), msg)
+
+	// LoginLockedError通过Is匹配ErrorLoginLocked
+	code, _ = errorCode(&logic.LoginLockedError{})
+	assert.Equal(t, CodeLoginLocked, code)
+	code, _ = errorCode(&logic.VersionConflictError{Current: 3})
+	assert.Equal(t, CodeVersionConflict, code)
+	code, _ = errorCode(&logic.AccountTooNewError{})
+	assert.Equal(t, CodeAccountTooNew, code)
+	code, _ = errorCode(&logic.DuplicatePostError{PostID: 1})
+	assert.Equal(t, CodeDuplicatePost, code)
+}
+
+func TestResponseErrorFrom(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	do := func(err error) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		ResponseErrorFrom(c, err)
+		return w
+	}
+
+	w := do(mysql.ErrorInvalidID)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"code":1014,"msg":"Not found"}`, w.Body.String())
+
+	// 帖子不存在或者没有权限查看草稿时返回404
+	w = do(fmt.Errorf("load post: %w", sql.ErrNoRows))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"code":1014,"msg":"Not found"}`, w.Body.String())
+
+	w = do(logic.ErrorTooManyTags)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"code":1001,"msg":"Too many tags. "}`, w.Body.String())
+
+	assert.Equal(t, http.StatusConflict, do(logic.ErrorIdempotencyConflict).Code)
+	assert.Equal(t, http.StatusForbidden, do(logic.ErrorPrivateCommunity).Code)
+	assert.Equal(t, http.StatusForbidden, do(logic.ErrorJoinApprovalRequired).Code)
+	assert.Equal(t, http.StatusForbidden, do(logic.ErrorPostNotAllowed).Code)
+	assert.Equal(t, http.StatusOK, do(errors.New("boom")).Code)
+
+	// 注册时间不够时msg说明还要等多久
+	w = do(fmt.Errorf("create post: %w", &logic.AccountTooNewError{
+		Action: logic.AccountActionDownvote, MinAge: 24 * time.Hour, Wait: 90*time.Minute + time.Second,
+	}))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "5401", w.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"code":1029,"msg":"Your account must be at least 24h old to downvote, please try again in 1h31m","data":{"retry_after":5401}}`, w.Body.String())
+
+	// 声望不够时返回需要的声望
+	w = do(&logic.KarmaTooLowError{Required: 100, Current: 12})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), `"data":{"karma":12,"required_karma":100}`)
+	assert.Contains(t, w.Body.String(), "You need at least 100 karma to create a community")
+
+	// 开启了两步验证时返回临时token
+	w = do(&logic.TwoFactorRequiredError{Challenge: &models.TwoFactorChallenge{Token: "tok"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), fmt.Sprintf(`"code":%d`, CodeTwoFactorRequired))
+	assert.Contains(t, w.Body.String(), `"two_factor_token":"tok"`)
+}
@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// postDetailETag 帖子详情的ETag，不同版本的接口格式不同，ETag也不同
+// 传了etag=content时忽略投票数这类经常变化的字段，使用弱ETag
+func postDetailETag(c *gin.Context, version, hash string, voteNum int64, saved bool) string {
+	if hash == "" {
+		return ""
+	}
+	// 同一个帖子的JSON和MessagePack编码是不同的表示，ETag也要不同
+	if wantsMsgPack(c) {
+		version += "-msgpack"
+	}
+	if c.Query("etag") == "content" {
+		return `W/"` + version + "-" + hash + `"`
+	}
+	s := "0"
+	if saved {
+		s = "1"
+	}
+	return `"` + version + "-" + hash + "-" + strconv.FormatInt(voteNum, 10) + "-" + s + `"`
+}
+
+// checkNotModified 设置ETag，与If-None-Match匹配时返回304，调用方不需要再写入响应
+// 收藏状态与当前用户有关，响应只允许客户端缓存
+func checkNotModified(c *gin.Context, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	h := c.Writer.Header()
+	h.Set("ETag", etag)
+	h.Set("Cache-Control", "private, no-cache")
+	h.Add("Vary", "Authorization")
+	if etagMatch(c.GetHeader("If-None-Match"), etag) {
+		c.AbortWithStatus(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// etagMatch If-None-Match使用弱比较，忽略W/前缀
+func etagMatch(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" || strings.TrimPrefix(v, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
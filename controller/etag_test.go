@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEtagMatch(t *testing.T) {
+	assert.True(t, etagMatch(`"v1-abc-3-0"`, `"v1-abc-3-0"`))
+	assert.True(t, etagMatch(`W/"v1-abc", "other"`, `W/"v1-abc"`))
+	assert.True(t, etagMatch(`"v1-abc"`, `W/"v1-abc"`))
+	assert.True(t, etagMatch(`*`, `"v1-abc-3-0"`))
+	assert.False(t, etagMatch(`"v1-abc-2-0"`, `"v1-abc-3-0"`))
+	assert.False(t, etagMatch("", `"v1-abc-3-0"`))
+}
+
+func TestCheckNotModified(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/post", func(c *gin.Context) {
+		if checkNotModified(c, postDetailETag(c, "v1", "abc", 3, false)) {
+			return
+		}
+		c.String(http.StatusOK, "detail")
+	})
+	do := func(url, inm string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		if inm != "" {
+			req.Header.Set("If-None-Match", inm)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("/post", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"v1-abc-3-0"`, w.Header().Get("ETag"))
+	assert.Equal(t, http.StatusNotModified, do("/post", `"v1-abc-3-0"`).Code)
+	assert.Equal(t, http.StatusOK, do("/post", `"v1-abc-2-0"`).Code)
+
+	w = do("/post?etag=content", "")
+	assert.Equal(t, `W/"v1-abc"`, w.Header().Get("ETag"))
+	assert.Equal(t, http.StatusNotModified, do("/post?etag=content", `W/"v1-abc"`).Code)
+}
@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"go-web-app/logic"
+	"go-web-app/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ExportHandler 开始导出当前用户的数据，body可以为空，返回的token用于查询状态和下载
+func ExportHandler(c *gin.Context) {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	p := new(models.ParamExport)
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(p); err != nil {
+			zap.L().Error("export with invalid param", zap.Error(err))
+			ResponseBindError(c, err)
+			return
+		}
+	}
+	job, err := logic.StartExport(userID, p)
+	if err != nil {
+		zap.L().Error("logic.StartExport failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, job)
+}
+
+// ExportDownloadHandler 导出完成后下载zip文件，还没有完成时返回导出的状态
+func ExportDownloadHandler(c *gin.Context) {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	job, file, size, err := logic.GetExport(userID, c.Param("token"))
+	if err != nil {
+		zap.L().Error("logic.GetExport failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	if file == nil {
+		ResponseSuccess(c, job)
+		return
+	}
+	defer file.Close()
+	name := "export-" + job.CreateTime.Format("20060102") + ".zip"
+	c.DataFromReader(http.StatusOK, size, "application/zip", file, map[string]string{
+		"Content-Disposition": `attachment; filename="` + name + `"`,
+	})
+}
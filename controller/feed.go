@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"go-web-app/logic"
+	"go-web-app/models"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func HomeFeedHandler(c *gin.Context) {
+	p := new(models.ParamFeed)
+	if err := c.ShouldBindQuery(p); err != nil {
+		zap.L().Error("Invalid params at HomeFeedHandler", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	var err error
+	if _, p.Size, err = getPageInfo(c); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	data, err := logic.GetHomeFeed(c.Request.Context(), userID, p)
+	if err != nil {
+		zap.L().Error("logic.GetHomeFeed() failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	data.List = logic.FilterMutedPosts(userID, logic.FilterBlockedPosts(userID, data.List))
+	logic.FillUserVotes(c.Request.Context(), userID, data.List)
+	ResponseSuccess(c, data)
+}
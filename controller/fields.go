@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 列表和详情接口支持?fields=post_id,title,vote_num只返回需要的字段，减少移动端的流量
+// 每种资源的字段有白名单，不在白名单中的字段直接忽略，不返回错误
+const (
+	fieldsPost      = "post"
+	fieldsComment   = "comment"
+	fieldsCommunity = "community"
+	fieldsProfile   = "profile"
+)
+
+// sensitiveFields 任何资源的白名单中都不能出现的字段，修改白名单时误加会在启动时panic
+var sensitiveFields = map[string]bool{
+	"password":      true,
+	"email":         true,
+	"token":         true,
+	"access_token":  true,
+	"refresh_token": true,
+	"ip":            true,
+	"content_hash":  true,
+	"deleted":       true,
+	"deactivated":   true,
+}
+
+type fieldSet struct {
+	allowed map[string]bool
+	// nested 嵌套的同类资源，例如评论树的children，按同样的字段过滤
+	nested string
+}
+
+var fieldSets = map[string]*fieldSet{
+	fieldsPost: newFieldSet("",
+		"post_id", "author_id", "author_name", "anonymous", "community_id", "community_ids", "community", "flair",
+		"status", "comment_locked", "comments_locked", "version", "title", "slug", "content", "excerpt", "html", "tags",
+		"publish_at", "create_time", "vote_num", "is_saved", "user_vote", "preview", "mentions", "pinned", "poll", "highlight"),
+	fieldsComment: newFieldSet("children",
+		"comment_id", "post_id", "author_id", "parent_id", "content", "mentions",
+		"vote_num", "score", "collapsed", "author", "create_time", "edited_at", "reply_count", "children"),
+	fieldsCommunity: newFieldSet("",
+		"id", "name", "introduction", "visibility", "min_post_length", "post_permission", "allow_anonymous", "create_time",
+		"is_member", "member_count", "required_post_length", "rules", "flairs"),
+	fieldsProfile: newFieldSet("",
+		"user_id", "username", "avatar", "join_time", "stats", "relationship"),
+}
+
+func newFieldSet(nested string, fields ...string) *fieldSet {
+	s := &fieldSet{allowed: make(map[string]bool, len(fields)), nested: nested}
+	for _, f := range fields {
+		if sensitiveFields[f] {
+			panic("sensitive field in whitelist: " + f)
+		}
+		s.allowed[f] = true
+	}
+	return s
+}
+
+// requestedFields 请求的字段中在白名单内的部分，没有fields参数或者没有有效的字段时返回nil，表示返回全部字段
+func requestedFields(c *gin.Context, resource string) map[string]bool {
+	raw := c.Query("fields")
+	set := fieldSets[resource]
+	if raw == "" || set == nil {
+		return nil
+	}
+	var fields map[string]bool
+	for _, f := range strings.Split(raw, ",") {
+		f = strings.TrimSpace(f)
+		if !set.allowed[f] {
+			continue
+		}
+		if fields == nil {
+			fields = make(map[string]bool)
+		}
+		fields[f] = true
+	}
+	return fields
+}
+
+// selectFields 按fields参数裁剪data，data是单个资源或资源的列表，分页信息由调用方另外包装
+// 裁剪时先序列化为JSON，json:"-"的字段本来就不会返回
+func selectFields(c *gin.Context, resource string, data interface{}) interface{} {
+	fields := requestedFields(c, resource)
+	if fields == nil || data == nil {
+		return data
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		zap.L().Error("marshal response for fields failed", zap.String("resource", resource), zap.Error(err))
+		return data
+	}
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	// 保留int64的精度
+	d.UseNumber()
+	if err = d.Decode(&v); err != nil {
+		return data
+	}
+	return filterFields(v, fields, fieldSets[resource].nested)
+}
+
+func filterFields(v interface{}, fields map[string]bool, nested string) interface{} {
+	switch val := v.(type) {
+	case []interface{}:
+		for i := range val {
+			val[i] = filterFields(val[i], fields, nested)
+		}
+		return val
+	case map[string]interface{}:
+		for k, child := range val {
+			if !fields[k] {
+				delete(val, k)
+				continue
+			}
+			if k == nested && nested != "" {
+				val[k] = filterFields(child, fields, nested)
+			} else {
+				val[k] = restoreNumbers(child)
+			}
+		}
+		return val
+	}
+	return v
+}
+
+// restoreNumbers 解码时为了保留int64的精度使用了json.Number，返回之前转换回数字，否则MessagePack会编码为字符串
+func restoreNumbers(v interface{}) interface{} {
+	switch val := v.(type) {
+	case []interface{}:
+		for i := range val {
+			val[i] = restoreNumbers(val[i])
+		}
+	case map[string]interface{}:
+		for k, child := range val {
+			val[k] = restoreNumbers(child)
+		}
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i
+		}
+		if f, err := val.Float64(); err == nil {
+			return f
+		}
+	}
+	return v
+}
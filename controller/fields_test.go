@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"encoding/json"
+	"go-web-app/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	posts := []*models.PostDetail{{
+		AuthorName:  "alice",
+		VoteNum:     3,
+		ContentHash: "secret",
+		Post:        &models.Post{PostID: 1 << 60, Title: "hello", CreateTime: time.Now()},
+	}}
+	get := func(query, resource string, data interface{}) string {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+		b, err := json.Marshal(selectFields(c, resource, data))
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	// 不认识的字段忽略，id保留精度
+	assert.JSONEq(t, `[{"post_id":1152921504606846976,"title":"hello"}]`,
+		get("fields=post_id,%20title,unknown", fieldsPost, posts))
+	// 没有有效的字段时返回全部字段
+	all := get("fields=nope", fieldsPost, posts)
+	assert.Contains(t, all, `"author_name":"alice"`)
+	assert.NotContains(t, all, "secret")
+	assert.Equal(t, all, get("", fieldsPost, posts))
+	// 白名单之外的字段即使存在也不返回
+	assert.JSONEq(t, `[{"vote_num":3}]`, get("fields=vote_num,content_hash,ContentHash", fieldsPost, posts))
+
+	// 评论树的children按同样的字段过滤
+	nodes := []*models.CommentNode{{
+		Comment:  &models.Comment{CommentID: 1, Content: "a"},
+		Children: []*models.CommentNode{{Comment: &models.Comment{CommentID: 2, Content: "b"}, Children: []*models.CommentNode{}}},
+	}}
+	assert.JSONEq(t, `[{"comment_id":1,"children":[{"comment_id":2,"children":[]}]}]`,
+		get("fields=comment_id,children", fieldsComment, nodes))
+}
+
+func TestFieldSetsExcludeSensitive(t *testing.T) {
+	for resource, set := range fieldSets {
+		for f := range set.allowed {
+			assert.False(t, sensitiveFields[f], "%s: %s", resource, f)
+		}
+	}
+	assert.Panics(t, func() { newFieldSet("", "id", "email") })
+}
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"go-web-app/pkg/profanity"
+	"go-web-app/settings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ContentFilterReject = "reject"
+
+// contentFilter 当前的敏感词过滤器，配置重新加载后整体替换
+var contentFilter atomic.Value // *profanity.Filter
+
+// InitContentFilter 根据配置创建敏感词过滤器，并在配置文件修改后重建
+func InitContentFilter() {
+	contentFilter.Store(profanity.New(settings.Current().ContentFilterConfig.Words))
+	settings.OnReload(func(conf *settings.AppConfig) {
+		contentFilter.Store(profanity.New(conf.ContentFilterConfig.Words))
+	})
+}
+
+// filterContent 检查用户提交的文本。reject模式下有敏感词时返回错误响应和false，
+// mask模式下直接把文本中的敏感词替换为*
+func filterContent(c *gin.Context, texts ...*string) bool {
+	f, ok := contentFilter.Load().(*profanity.Filter)
+	if !ok {
+		return true
+	}
+	reject := settings.Current().ContentFilterConfig.Mode == ContentFilterReject
+	for _, text := range texts {
+		if !reject {
+			*text = f.Mask(*text)
+			continue
+		}
+		if f.Contains(*text) {
+			ResponseError(c, CodeInappropriateContent)
+			return false
+		}
+	}
+	return true
+}
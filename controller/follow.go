@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"errors"
+	"go-web-app/dao/mysql"
+	"go-web-app/logic"
+	"go-web-app/models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func FollowHandler(c *gin.Context) {
+	handleFollow(c, logic.Follow)
+}
+
+func UnfollowHandler(c *gin.Context) {
+	handleFollow(c, logic.Unfollow)
+}
+
+func handleFollow(c *gin.Context, action func(userID, targetID int64) error) {
+	targetID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if err := action(userID, targetID); err != nil {
+		zap.L().Error("follow action failed", zap.Int64("userID", userID), zap.Int64("targetID", targetID), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+func FollowerListHandler(c *gin.Context) {
+	handleFollowList(c, logic.GetFollowers)
+}
+
+func FollowingListHandler(c *gin.Context) {
+	handleFollowList(c, logic.GetFollowing)
+}
+
+func handleFollowList(c *gin.Context, list func(uid, page, size int64) ([]*models.FollowUser, int64, error)) {
+	uid, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	page, size, err := getPageInfo(c)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	data, total, err := list(uid, page, size)
+	if err != nil {
+		zap.L().Error("get follow list failed", zap.Int64("uid", uid), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccessWithPage(c, data, page, size, total)
+}
+
+// UserProfileHandler 用户的公开主页
+func UserProfileHandler(c *gin.Context) {
+	uid, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	viewerID, _ := GetCurrentUserID(c)
+	profile, err := logic.GetUserProfile(viewerID, uid)
+	if err != nil {
+		if !errors.Is(err, mysql.ErrorUserNotExist) {
+			zap.L().Error("logic.GetUserProfile failed", zap.Int64("uid", uid), zap.Error(err))
+		}
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, selectFields(c, fieldsProfile, profile))
+}
+
+// UserProfileByUsernameHandler 按用户名查看主页，旧的用户名返回改名之后的用户
+func UserProfileByUsernameHandler(c *gin.Context) {
+	viewerID, _ := GetCurrentUserID(c)
+	profile, err := logic.GetUserProfileByUsername(viewerID, c.Param("name"))
+	if err != nil {
+		if !errors.Is(err, mysql.ErrorUserNotExist) {
+			zap.L().Error("logic.GetUserProfileByUsername failed", zap.String("name", c.Param("name")), zap.Error(err))
+		}
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, selectFields(c, fieldsProfile, profile))
+}
+
+// UserPostListHandler 用户在所有社区发布的帖子，按时间或分数排序
+func UserPostListHandler(c *gin.Context) {
+	uid, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	p := new(models.ParamUserPosts)
+	if err := c.ShouldBindQuery(p); err != nil {
+		ResponseBindError(c, err)
+		return
+	}
+	page, size, err := getPageInfo(c)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	viewerID, _ := GetCurrentUserID(c)
+	data, total, err := logic.GetUserPosts(c.Request.Context(), viewerID, uid, p, page, size)
+	if err != nil {
+		if !errors.Is(err, mysql.ErrorUserNotExist) {
+			zap.L().Error("logic.GetUserPosts failed", zap.Int64("uid", uid), zap.Error(err))
+		}
+		ResponseErrorFrom(c, err)
+		return
+	}
+	logic.FillUserVotes(c.Request.Context(), viewerID, data)
+	ResponseSuccessWithPage(c, selectFields(c, fieldsPost, data), page, size, total)
+}
+
+// UserBatchHandler 按id批量查询用户的公开信息，例如粉丝列表中的头像和用户名
+func UserBatchHandler(c *gin.Context) {
+	p := new(models.ParamUserBatch)
+	if err := c.ShouldBindJSON(p); err != nil {
+		ResponseBindError(c, err)
+		return
+	}
+	data, err := logic.GetUserMiniProfiles(c.Request.Context(), p.IDs)
+	if err != nil {
+		if !errors.Is(err, logic.ErrorTooManyIDs) {
+			zap.L().Error("logic.GetUserMiniProfiles failed", zap.Int("count", len(p.IDs)), zap.Error(err))
+		}
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, data)
+}
@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"go-web-app/dao/redis"
+	"go-web-app/logic"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthzHandler 存活探针，进程能处理请求就返回200，mode为当前的维护模式
+// redis_breaker为redis熔断器的状态，熔断时进程仍然可以处理请求，所以不影响状态码
+func HealthzHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":        "ok",
+		"mode":          logic.MaintenanceMode(),
+		"redis_breaker": redis.BreakerState(),
+	})
+}
+
+// ReadyzHandler 就绪探针，依赖的数据库任意一个不可用时返回503
+func ReadyzHandler(c *gin.Context) {
+	status, ready := logic.CheckReadiness(c.Request.Context())
+	code := http.StatusOK
+	if !ready {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{
+		"ready":         ready,
+		"mode":          logic.MaintenanceMode(),
+		"dependencies":  status,
+		"redis_breaker": redis.BreakerState(),
+	})
+}
@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"context"
+	"go-web-app/logic"
+	"go-web-app/models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// LeaderboardHandler 声望排行榜，window为all或week
+func LeaderboardHandler(c *gin.Context) {
+	p := &models.ParamLeaderboard{Window: models.LeaderboardAllTime}
+	if err := c.ShouldBindQuery(p); err != nil {
+		zap.L().Error("Invalid params at LeaderboardHandler", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	if p.Window == "" {
+		p.Window = models.LeaderboardAllTime
+	}
+	var err error
+	if _, p.Size, err = getPageInfo(c); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	cached, err := cachedResponse(c, logic.CacheLeaderboard, func(ctx context.Context) (interface{}, error) {
+		return logic.GetLeaderboard(ctx, p)
+	}, p.Window, strconv.FormatInt(p.Size, 10))
+	if err != nil {
+		zap.L().Error("logic.GetLeaderboard() failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	// 缓存的排行榜被所有用户共享，复制之后再补充当前用户的排名
+	data := *cached.(*models.Leaderboard)
+	if data.Me, err = logic.GetLeaderboardRank(c.Request.Context(), userID, p.Window); err != nil {
+		zap.L().Error("logic.GetLeaderboardRank() failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, &data)
+}
@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"fmt"
+	"go-web-app/logic"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// allowCreation 检查用户发帖或评论的频率，超出限制时返回429并告诉客户端多久之后可以重试
+// redis出问题时放行
+func allowCreation(c *gin.Context, userID int64, kind string) bool {
+	limit, err := logic.CheckCreationLimit(userID, kind)
+	if err != nil {
+		zap.L().Error("logic.CheckCreationLimit failed", zap.Int64("userID", userID), zap.String("kind", kind), zap.Error(err))
+		return true
+	}
+	if limit == nil {
+		return true
+	}
+	seconds := int64(math.Ceil(limit.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.FormatInt(seconds, 10))
+	writeResponse(c, http.StatusTooManyRequests, &ResponseData{
+		Code: CodeTooManyRequests,
+		Msg: fmt.Sprintf("You can create at most %d %ss per %s, please try again in %s",
+			limit.Limit, kind, windowName(limit.Window), time.Duration(seconds)*time.Second),
+		Data: gin.H{"retry_after": seconds},
+	})
+	return false
+}
+
+func windowName(d time.Duration) string {
+	if d == time.Minute {
+		return "minute"
+	}
+	return "hour"
+}
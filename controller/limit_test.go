@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"encoding/json"
+	"go-web-app/logic"
+	"go-web-app/settings"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllowCreation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mr := useMiniredis(t)
+	old := settings.Conf.CreationLimitConfig
+	settings.Conf.CreationLimitConfig = &settings.CreationLimitConfig{CommentsPerMinute: 1}
+	t.Cleanup(func() { settings.Conf.CreationLimitConfig = old })
+
+	check := func() (bool, *httptest.ResponseRecorder) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+		return allowCreation(c, 1, logic.CreationKindComment), w
+	}
+
+	ok, _ := check()
+	assert.True(t, ok)
+	ok, w := check()
+	require.False(t, ok)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	var res struct {
+		Code ResCode `json:"code"`
+		Data struct {
+			RetryAfter int64 `json:"retry_after"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, CodeTooManyRequests, res.Code)
+	assert.True(t, res.Data.RetryAfter > 0 && res.Data.RetryAfter <= 60)
+
+	// redis不可用时放行
+	mr.Close()
+	ok, w = check()
+	assert.True(t, ok)
+	assert.Equal(t, 0, w.Body.Len())
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"go-web-app/logic"
+	"go-web-app/models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ModQueueHandler 版主查看自己社区的审核队列，可以按状态过滤，mine=true只看自己认领的条目
+func ModQueueHandler(c *gin.Context) {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	p := new(models.ParamModQueue)
+	if err := c.ShouldBindQuery(p); err != nil {
+		zap.L().Error("query moderation queue with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	page, size, err := getPageInfo(c)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	list, hasMore, err := logic.GetModQueue(userID, p, page, size)
+	if err != nil {
+		zap.L().Error("logic.GetModQueue failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccessWithMore(c, list, page, size, hasMore)
+}
+
+// ClaimModQueueHandler 版主认领一个条目，已经被其他版主认领时返回冲突
+func ClaimModQueueHandler(c *gin.Context) {
+	userID, itemID, ok := modQueueItemParam(c)
+	if !ok {
+		return
+	}
+	item, err := logic.ClaimModQueueItem(userID, itemID)
+	if err != nil {
+		zap.L().Error("logic.ClaimModQueueItem failed", zap.Int64("itemID", itemID), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, item)
+}
+
+// ResolveModQueueHandler 版主处理认领的条目，返回的条目中audit_id为这次操作的审计日志
+func ResolveModQueueHandler(c *gin.Context) {
+	userID, itemID, ok := modQueueItemParam(c)
+	if !ok {
+		return
+	}
+	p := new(models.ParamResolveModQueue)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("resolve moderation queue item with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	item, err := logic.ResolveModQueueItem(userID, itemID, p, c.ClientIP())
+	if err != nil {
+		zap.L().Error("logic.ResolveModQueueItem failed", zap.Int64("itemID", itemID), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, item)
+}
+
+func modQueueItemParam(c *gin.Context) (userID, itemID int64, ok bool) {
+	itemID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return 0, 0, false
+	}
+	if userID, err = GetCurrentUserID(c); err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return 0, 0, false
+	}
+	return userID, itemID, true
+}
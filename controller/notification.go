@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"go-web-app/logic"
+	"go-web-app/models"
+	"io"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// NotificationListHandler 通知列表，未读的在前，type可以是reply、vote、follow或mention
+func NotificationListHandler(c *gin.Context) {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	page, size, err := getPageInfo(c)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	data, total, err := logic.GetNotifications(userID, c.Query("type"), page, size)
+	if err != nil {
+		zap.L().Error("logic.GetNotifications failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccessWithPage(c, data, page, size, total)
+}
+
+// MarkNotificationsReadHandler 标记通知为已读，不传ids时全部标记
+func MarkNotificationsReadHandler(c *gin.Context) {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	p := new(models.ParamMarkRead)
+	if err := c.ShouldBindJSON(p); err != nil && err != io.EOF {
+		zap.L().Error("mark notifications read with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	if err := logic.MarkNotificationsRead(userID, p.IDs); err != nil {
+		zap.L().Error("logic.MarkNotificationsRead failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// MarkAllNotificationsReadHandler 全部标记为已读，返回之后的未读数，重复调用没有副作用
+func MarkAllNotificationsReadHandler(c *gin.Context) {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	data, err := logic.MarkAllNotificationsRead(userID)
+	if err != nil {
+		zap.L().Error("logic.MarkAllNotificationsRead failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// UnreadCountHandler 未读通知数，用于显示角标
+func UnreadCountHandler(c *gin.Context) {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	data, err := logic.GetUnreadCount(userID)
+	if err != nil {
+		zap.L().Error("logic.GetUnreadCount failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// NotificationPrefHandler 当前用户的通知设置
+func NotificationPrefHandler(c *gin.Context) {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	data, err := logic.GetNotificationPref(userID)
+	if err != nil {
+		zap.L().Error("logic.GetNotificationPref failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// UpdateNotificationPrefHandler 修改通知设置，只修改传了的类型，返回修改后的设置
+func UpdateNotificationPrefHandler(c *gin.Context) {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	p := new(models.ParamNotificationPref)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("update notification preferences with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	data, err := logic.UpdateNotificationPref(userID, p)
+	if err != nil {
+		zap.L().Error("logic.UpdateNotificationPref failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
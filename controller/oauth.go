@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"go-web-app/logic"
+	"go-web-app/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	oauthStateCookie = "oauth_state"
+	oauthCookiePath  = "/api/v1/oauth"
+)
+
+// GoogleLoginHandler 跳转到Google授权页面
+// state同时保存在cookie中，回调时两边一致才能登录，防止把别人的授权结果登录到自己的浏览器
+func GoogleLoginHandler(c *gin.Context) {
+	authURL, state, err := logic.GoogleLoginURL()
+	if err != nil {
+		zap.L().Error("logic.GoogleLoginURL failed", zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	c.SetCookie(oauthStateCookie, state, 600, oauthCookiePath, "", c.Request.TLS != nil, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// GoogleCallbackHandler Google授权后的回调，只允许配置的学校邮箱登录
+func GoogleCallbackHandler(c *gin.Context) {
+	if reason := c.Query("error"); reason != "" {
+		ResponseErrorWithMsg(c, CodeInvalidParam, "authorization failed: "+reason)
+		return
+	}
+	state, code := c.Query("state"), c.Query("code")
+	cookieState, _ := c.Cookie(oauthStateCookie)
+	if state == "" || code == "" || subtle.ConstantTimeCompare([]byte(state), []byte(cookieState)) != 1 {
+		ResponseError(c, CodeInvalidToken)
+		return
+	}
+	c.SetCookie(oauthStateCookie, "", -1, oauthCookiePath, "", c.Request.TLS != nil, true)
+
+	u, err := logic.GoogleCallback(c.Request.Context(), state, code)
+	if err != nil {
+		zap.L().Error("logic.GoogleCallback failed", zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	userID, token, err := logic.OAuthLogin(c.Request.Context(), u, clientInfo(c))
+	if err != nil {
+		zap.L().Error("logic.OAuthLogin failed", zap.String("email", u.Email), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	auditAs(c, userID, models.AuditActionLogin, userTarget(userID))
+	ResponseSuccess(c, token)
+}
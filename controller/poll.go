@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"go-web-app/logic"
+	"go-web-app/models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// VotePollHandler 在帖子附带的投票中选择一个选项，返回当前的结果
+func VotePollHandler(c *gin.Context) {
+	pid, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	p := new(models.ParamPollVote)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("vote poll with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	data, err := logic.VotePoll(c.Request.Context(), userID, pid, *p.Option)
+	if err != nil {
+		zap.L().Error("logic.VotePoll failed", zap.Int64("userID", userID), zap.Int64("pid", pid), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, data)
+}
@@ -1,81 +1,570 @@
 package controller
 
 import (
+	"context"
+	"errors"
 	"go-web-app/logic"
 	"go-web-app/models"
+	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"go.uber.org/zap"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	HeaderIdempotencyKey    = "Idempotency-Key"
+	maxIdempotencyKeyLength = 128
+)
+
 func CreatePostHandler(c *gin.Context) {
 	p := new(models.Post)
 	if err := c.ShouldBindJSON(p); err != nil {
 		zap.L().Debug("c.ShouldBindJSON(p) error", zap.Any("err", err))
 		zap.L().Error("create post with invalid param")
-		ResponseError(c, CodeInvalidParam)
+		ResponseBindError(c, err)
 		return
 	}
+	sanitizeFields(&p.Title, &p.Content, &p.Flair)
 	userID, err := GetCurrentUserID(c)
 	if err != nil {
 		ResponseError(c, CodeNeedLogin)
 		return
 	}
 	p.AuthorId = userID
-	if err := logic.CreatePost(p); err != nil {
+	// 状态不由客户端指定，?draft=true时保存为草稿
+	p.Status = models.PostStatusNormal
+	if draft, _ := strconv.ParseBool(c.Query("draft")); draft {
+		p.Status = models.PostStatusDraft
+	}
+	if !filterContent(c, &p.Title, &p.Content) {
+		return
+	}
+	// 客户端重试时带上同一个Idempotency-Key，不会重复发帖
+	key := c.GetHeader(HeaderIdempotencyKey)
+	if len(key) > maxIdempotencyKeyLength {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	if !allowCreation(c, userID, logic.CreationKindPost) {
+		return
+	}
+	postID := int64(0)
+	if key == "" {
+		err = logic.CreatePost(c.Request.Context(), p)
+		postID = p.PostID
+	} else {
+		postID, err = logic.CreatePostIdempotent(c.Request.Context(), p, key)
+	}
+	if err != nil {
+		// 自己最近发过相同的内容时返回之前的帖子
+		var dup *logic.DuplicatePostError
+		if errors.As(err, &dup) {
+			writeResponse(c, CodeDuplicatePost.Status(), &ResponseData{
+				Code: CodeDuplicatePost,
+				Msg:  CodeDuplicatePost.Msg(),
+				Data: gin.H{"post_id": dup.PostID, "url": postPath(dup.PostID)},
+			})
+			return
+		}
+		var short *logic.PostTooShortError
+		if errors.As(err, &short) {
+			ResponseFieldError(c, "content", "textmin", strconv.Itoa(short.Min))
+			return
+		}
 		zap.L().Error("logic.CreatePost(p) failed", zap.Error(err))
-		ResponseError(c, CodeServerBusy)
+		ResponseErrorFrom(c, err)
 		return
 	}
-	ResponseSuccess(c, nil)
+	data := gin.H{"post_id": postID}
+	// 链接没有通过检查的帖子已经保存，版主处理之前其他用户看不到
+	if p.Status == models.PostStatusHidden {
+		data["pending_review"] = true
+	}
+	// 其他用户最近发过相同的内容时只提示，不阻止发布
+	if p.DuplicateOf != 0 {
+		data["duplicate_of"] = gin.H{"post_id": p.DuplicateOf, "url": postPath(p.DuplicateOf)}
+	}
+	ResponseSuccess(c, data)
+}
+
+// postPath 帖子详情的接口地址
+func postPath(pid int64) string {
+	return "/api/v1/post/" + strconv.FormatInt(pid, 10)
 }
 
 func GetPostDetailHandler(c *gin.Context) {
+	data, ok := getPostDetail(c)
+	if !ok || checkNotModified(c, postDetailETag(c, "v1", data.ContentHash, data.VoteNum, data.IsSaved)) {
+		return
+	}
+	ResponseSuccess(c, selectFields(c, fieldsPost, data))
+}
+
+// GetPostFullHandler 帖子详情和第一页的评论，客户端打开帖子时只需要一次请求，评论的排序同GetCommentListHandler
+func GetPostFullHandler(c *gin.Context) {
+	p := new(models.ParamCommentSort)
+	if err := c.ShouldBindQuery(p); err != nil {
+		ResponseBindError(c, err)
+		return
+	}
+	post, ok := getPostDetail(c)
+	if !ok {
+		return
+	}
+	userID, _ := GetCurrentUserID(c)
+	comments, total, err := logic.GetTopComments(c.Request.Context(), userID, post.PostID, p.Sort)
+	if err != nil {
+		zap.L().Error("logic.GetTopComments failed", zap.Int64("pid", post.PostID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, &models.PostFull{
+		Post:            post,
+		Comments:        comments,
+		CommentTotal:    total,
+		HasMoreComments: total > int64(len(comments)),
+	})
+}
+
+// getPostDetail v1和v2的帖子详情共用，失败时已经写入了错误响应
+func getPostDetail(c *gin.Context) (*models.PostDetail, bool) {
 	pidStr := c.Param("id")
 	pid, err := strconv.ParseInt(pidStr, 10, 64)
 	if err != nil {
 		zap.L().Error("get post detail with invalid params. ", zap.Error(err))
 		ResponseError(c, CodeInvalidParam)
-		return
+		return nil, false
 	}
-	data, err := logic.GetPostById(pid)
+	return loadPostDetail(c, pid)
+}
+
+// GetPostBySlugHandler 通过slug访问帖子，旧的slug永久重定向到帖子当前的slug
+func GetPostBySlugHandler(c *gin.Context) {
+	pid, canonical, err := logic.ResolvePostSlug(c.Param("slug"))
 	if err != nil {
-		zap.L().Error("logic.GetPostById(pid) failed", zap.Error(err))
-		ResponseError(c, CodeServerBusy)
+		zap.L().Warn("logic.ResolvePostSlug failed", zap.String("slug", c.Param("slug")), zap.Error(err))
+		ResponseErrorFrom(c, err)
 		return
 	}
-	ResponseSuccess(c, data)
+	if canonical != c.Param("slug") {
+		target := "/api/v1/p/" + url.PathEscape(canonical)
+		if c.Request.URL.RawQuery != "" {
+			target += "?" + c.Request.URL.RawQuery
+		}
+		c.Redirect(http.StatusMovedPermanently, target)
+		return
+	}
+	data, ok := loadPostDetail(c, pid)
+	if !ok || checkNotModified(c, postDetailETag(c, "v1", data.ContentHash, data.VoteNum, data.IsSaved)) {
+		return
+	}
+	ResponseSuccess(c, selectFields(c, fieldsPost, data))
+}
+
+func loadPostDetail(c *gin.Context, pid int64) (*models.PostDetail, bool) {
+	userID, err := GetCurrentUserID(c)
+	data, perr := logic.GetPostByIdForUser(c.Request.Context(), pid, userID)
+	if perr != nil {
+		zap.L().Error("logic.GetPostByIdForUser failed", zap.Int64("pid", pid), zap.Error(perr))
+		ResponseErrorFrom(c, perr)
+		return nil, false
+	}
+	if err := logic.CheckPostAccess(c.Request.Context(), userID, data.Post); err != nil {
+		zap.L().Warn("logic.CheckPostAccess failed", zap.Int64("pid", pid), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return nil, false
+	}
+	if err == nil {
+		if data.IsSaved, err = logic.IsPostSaved(userID, pid); err != nil {
+			zap.L().Error("logic.IsPostSaved failed", zap.Int64("pid", pid), zap.Error(err))
+		}
+	}
+	// 投票结果随时变化，不放在详情的缓存中，票数和当前用户的选择也要体现在ETag中
+	if data.Poll, err = logic.GetPollResult(pid, userID); err != nil {
+		zap.L().Error("logic.GetPollResult failed", zap.Int64("pid", pid), zap.Error(err))
+	}
+	if data.Poll != nil && data.ContentHash != "" {
+		choice := -1
+		if data.Poll.UserChoice != nil {
+			choice = *data.Poll.UserChoice
+		}
+		data.ContentHash += "-" + strconv.FormatInt(data.Poll.TotalVotes, 10) + "-" + strconv.Itoa(choice)
+	}
+	return data, true
 }
 
 func GetPostListHandler(c *gin.Context) {
-	page, size := getPageInfo(c)
-	data, err := logic.GetPostList(page, size)
+	// 传了游标或时间时按游标分页，page和size参数继续兼容
+	if c.Query("cursor") != "" || c.Query("before") != "" {
+		getPostListByCursor(c)
+		return
+	}
+	// 按标签筛选时，从标签的有序集合中按发布时间取帖子
+	if c.Query("tag") != "" {
+		getTagPostList(c)
+		return
+	}
+	// 指定了排序方式时，从redis的有序集合中按顺序取帖子
+	if c.Query("order") != "" {
+		getOrderedPostList(c)
+		return
+	}
+	page, size, err := getPageInfo(c)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	data, total, err := logic.GetPostList(c.Request.Context(), page, size)
 	if err != nil {
 		zap.L().Error("logic.GetPostList() failed", zap.Error(err))
 		ResponseError(c, CodeServerBusy)
 		return
 	}
-	ResponseSuccess(c, data)
+	ResponseSuccessWithPage(c, selectFields(c, fieldsPost, personalizeFeed(c, data)), page, size, total)
+}
+
+func getPostListByCursor(c *gin.Context) {
+	p := new(models.ParamFeed)
+	if err := c.ShouldBindQuery(p); err != nil || p.Cursor < 0 {
+		zap.L().Error("Invalid params at GetPostListHandler", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	var err error
+	if _, p.Size, err = getPageInfo(c); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	data, err := logic.GetPostListByCursor(c.Request.Context(), p)
+	if err != nil {
+		zap.L().Error("logic.GetPostListByCursor() failed", zap.Int64("cursor", p.Cursor), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, gin.H{
+		"list":        selectFields(c, fieldsPost, personalizeFeed(c, data.List)),
+		"next_cursor": data.NextCursor,
+	})
+}
+
+func getTagPostList(c *gin.Context) {
+	tag, err := logic.NormalizeTag(c.Query("tag"))
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	page, size, err := getPageInfo(c)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	data, hasMore, err := logic.GetTagPostList(c.Request.Context(), tag, page, size)
+	if err != nil {
+		zap.L().Error("logic.GetTagPostList() failed", zap.String("tag", tag), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccessWithMore(c, selectFields(c, fieldsPost, personalizeFeed(c, data)), page, size, hasMore)
+}
+
+func getOrderedPostList(c *gin.Context) {
+	p := &models.ParamPostList{Order: models.OrderTime}
+	if err := c.ShouldBindQuery(p); err != nil {
+		zap.L().Error("Invalid params at GetPostListHandler", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	var err error
+	if p.Page, p.Size, err = getPageInfo(c); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	data, hasMore, err := logic.GetPostListNew(c.Request.Context(), p)
+	if err != nil {
+		zap.L().Error("logic.GetPostListNew() failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccessWithMore(c, selectFields(c, fieldsPost, personalizePostList(c, p.CommunityID, data)), p.Page, p.Size, hasMore)
 }
 
 func GetPostListHandler2(c *gin.Context) {
 	p := &models.ParamPostList{
 		CommunityID: 1,
-		Page:        10,
 		Order:       models.OrderTime,
 	}
 	if err := c.ShouldBindQuery(p); err != nil {
 		zap.L().Error("Invalid params at GetPostListHandler2", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	var err error
+	if p.Page, p.Size, err = getPageInfo(c); err != nil {
 		ResponseError(c, CodeInvalidParam)
 		return
 	}
-	data, err := logic.GetPostListNew(p)
+	data, hasMore, err := logic.GetPostListNew(c.Request.Context(), p)
 	if err != nil {
 		zap.L().Error("logic.GetPostList() failed", zap.Error(err))
 		ResponseError(c, CodeServerBusy)
 		return
 	}
+	ResponseSuccessWithMore(c, selectFields(c, fieldsPost, personalizePostList(c, p.CommunityID, data)), p.Page, p.Size, hasMore)
+}
+
+func SearchPostHandler(c *gin.Context) {
+	p := new(models.ParamSearch)
+	if err := c.ShouldBindQuery(p); err != nil {
+		zap.L().Error("Invalid params at SearchPostHandler", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	var err error
+	p.Query = strings.TrimSpace(p.Query)
+	if p.Page, p.Size, err = getPageInfo(c); err != nil || p.Query == "" {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	// 指定了type时返回按类型分组的结果，没有指定时保持原来的帖子列表
+	if p.Type != "" {
+		globalSearch(c, p)
+		return
+	}
+	data, total, err := logic.SearchPosts(c.Request.Context(), p)
+	if err != nil {
+		zap.L().Error("logic.SearchPosts() failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccessWithPage(c, selectFields(c, fieldsPost, personalizePosts(c, data)), p.Page, p.Size, total)
+}
+
+func UpdatePostHandler(c *gin.Context) {
+	pid, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	p := new(models.ParamUpdatePost)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("update post with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	sanitizeFields(&p.Title, &p.Content)
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if !filterContent(c, &p.Title, &p.Content) {
+		return
+	}
+	version, err := logic.UpdatePost(c.Request.Context(), userID, pid, p)
+	if err != nil {
+		// 冲突时返回当前的版本，客户端重新获取帖子后合并修改
+		var conflict *logic.VersionConflictError
+		if errors.As(err, &conflict) {
+			writeResponse(c, CodeVersionConflict.Status(), &ResponseData{
+				Code: CodeVersionConflict,
+				Msg:  CodeVersionConflict.Msg(),
+				Data: gin.H{"version": conflict.Current},
+			})
+			return
+		}
+		zap.L().Error("logic.UpdatePost failed", zap.Int64("pid", pid), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, gin.H{"version": version})
+}
+
+func GetPostHistoryHandler(c *gin.Context) {
+	pid, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, _ := GetCurrentUserID(c)
+	data, err := logic.GetPostHistory(c.Request.Context(), userID, pid)
+	if err != nil {
+		zap.L().Error("logic.GetPostHistory failed", zap.Int64("pid", pid), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
 	ResponseSuccess(c, data)
 }
+
+// GetRelatedPostsHandler 帖子详情页下方的相关帖子
+func GetRelatedPostsHandler(c *gin.Context) {
+	pid, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	cached, err := cachedResponse(c, logic.CacheRelatedPosts, func(ctx context.Context) (interface{}, error) {
+		return logic.GetRelatedPosts(ctx, pid)
+	}, strconv.FormatInt(pid, 10))
+	if err != nil {
+		zap.L().Error("logic.GetRelatedPosts failed", zap.Int64("pid", pid), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	data := cached.([]*models.PostDetail)
+	if userID, err := GetCurrentUserID(c); err == nil {
+		data = fillUserVotes(c, userID, data)
+	}
+	ResponseSuccess(c, selectFields(c, fieldsPost, data))
+}
+
+func DeletePostHandler(c *gin.Context) {
+	pid, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if err := logic.DeletePost(userID, pid); err != nil {
+		zap.L().Error("logic.DeletePost failed", zap.Int64("pid", pid), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+func RestorePostHandler(c *gin.Context) {
+	pid, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	if err := logic.RestorePost(pid); err != nil {
+		zap.L().Error("logic.RestorePost failed", zap.Int64("pid", pid), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// ScheduledPostListHandler 当前用户还没有发布的定时帖子
+func ScheduledPostListHandler(c *gin.Context) {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	data, err := logic.GetScheduledPosts(userID)
+	if err != nil {
+		zap.L().Error("logic.GetScheduledPosts failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// CancelScheduledPostHandler 取消还没有发布的定时帖子
+func CancelScheduledPostHandler(c *gin.Context) {
+	pid, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if err := logic.CancelScheduledPost(userID, pid); err != nil {
+		zap.L().Error("logic.CancelScheduledPost failed", zap.Int64("pid", pid), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// DraftListHandler 当前用户的草稿
+func DraftListHandler(c *gin.Context) {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	data, err := logic.GetDrafts(userID)
+	if err != nil {
+		zap.L().Error("logic.GetDrafts failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// PublishPostHandler 发布草稿
+func PublishPostHandler(c *gin.Context) {
+	pid, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if err := logic.PublishDraft(c.Request.Context(), userID, pid); err != nil {
+		zap.L().Error("logic.PublishDraft failed", zap.Int64("pid", pid), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// personalizePosts 去掉当前用户屏蔽的作者的帖子，并补充当前用户的投票
+func personalizePosts(c *gin.Context, data []*models.PostDetail) []*models.PostDetail {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		return data
+	}
+	return fillUserVotes(c, userID, logic.FilterBlockedPosts(userID, data))
+}
+
+// personalizePostList 指定了社区时是用户主动访问该社区，不过滤隐藏的社区
+func personalizePostList(c *gin.Context, communityID int64, data []*models.PostDetail) []*models.PostDetail {
+	if communityID != 0 {
+		return personalizePosts(c, data)
+	}
+	return personalizeFeed(c, data)
+}
+
+// personalizeFeed 全站的信息流还要去掉当前用户隐藏的社区的帖子
+func personalizeFeed(c *gin.Context, data []*models.PostDetail) []*models.PostDetail {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		return data
+	}
+	return fillUserVotes(c, userID, logic.FilterMutedPosts(userID, logic.FilterBlockedPosts(userID, data)))
+}
+
+// fillUserVotes 列表可能来自共享的缓存，复制之后再补充当前用户的投票
+func fillUserVotes(c *gin.Context, userID int64, data []*models.PostDetail) []*models.PostDetail {
+	data = copyPostDetails(data)
+	logic.FillUserVotes(c.Request.Context(), userID, data)
+	return data
+}
+
+// copyPostDetails 浅复制列表中的帖子，修改UserVote这类和当前用户有关的字段时不影响原来的列表
+func copyPostDetails(list []*models.PostDetail) []*models.PostDetail {
+	copied := make([]*models.PostDetail, 0, len(list))
+	for _, p := range list {
+		cp := *p
+		copied = append(copied, &cp)
+	}
+	return copied
+}
@@ -2,12 +2,16 @@ package controller
 
 import (
 	"bytes"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCreatePostHandler(t *testing.T) {
@@ -42,3 +46,35 @@ func TestCreatePostHandler(t *testing.T) {
 	//}
 	//assert.Equal(t, res.Code, CodeNeedLogin)
 }
+
+func TestPersonalizePostsFillsUserVotes(t *testing.T) {
+	mr := useMiniredis(t)
+	require.NoError(t, redis.SetBlockedIDs(9, []int64{3}, time.Minute))
+	_, err := mr.ZAdd(redis.Prefix+redis.KeyPostVotedZSetPF+"1", 1, "9")
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	list := []*models.PostDetail{
+		{Post: &models.Post{PostID: 1, AuthorId: 2}},
+		{Post: &models.Post{PostID: 2, AuthorId: 3}},
+		{Post: &models.Post{PostID: 4, AuthorId: 2}},
+	}
+
+	// 没有登录时原样返回，不补充投票
+	data := personalizePosts(c, list)
+	require.Len(t, data, 3)
+	assert.Nil(t, data[0].UserVote)
+
+	c.Set(ContextUserIDKey, int64(9))
+	data = personalizePosts(c, list)
+	require.Len(t, data, 2)
+	assert.Equal(t, int64(1), data[0].PostID)
+	require.NotNil(t, data[0].UserVote)
+	assert.Equal(t, int8(1), *data[0].UserVote)
+	require.NotNil(t, data[1].UserVote)
+	assert.Equal(t, int8(0), *data[1].UserVote)
+	// 原来的列表可能是共享的缓存，不被修改
+	assert.Nil(t, list[0].UserVote)
+}
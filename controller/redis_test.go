@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"go-web-app/dao/redis"
+	"go-web-app/settings"
+	"strconv"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// useMiniredis 让dao/redis连接到内存中的miniredis，测试结束后关闭
+func useMiniredis(t *testing.T) *miniredis.Miniredis {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	port, err := strconv.Atoi(mr.Port())
+	require.NoError(t, err)
+	require.NoError(t, redis.Init(&settings.RedisConfig{
+		Host:              mr.Host(),
+		Port:              port,
+		PoolSize:          4,
+		BreakerThreshold:  100,
+		BreakerCooldown:   1000,
+		FallbackCacheSize: 16,
+	}))
+	t.Cleanup(func() {
+		redis.Close()
+		mr.Close()
+	})
+	return mr
+}
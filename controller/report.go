@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"go-web-app/logic"
+	"go-web-app/models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ReportPostHandler 举报帖子
+func ReportPostHandler(c *gin.Context) {
+	pid, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	p := new(models.ParamReport)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("report post with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if err := logic.ReportPost(userID, pid, p); err != nil {
+		zap.L().Error("logic.ReportPost failed", zap.Int64("pid", pid), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// ReportListHandler 版主查看所管理社区的待处理举报
+func ReportListHandler(c *gin.Context) {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	page, size, err := getPageInfo(c)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	data, err := logic.GetReports(userID, page, size)
+	if err != nil {
+		zap.L().Error("logic.GetReports failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// ResolveReportHandler 版主处理帖子的举报
+func ResolveReportHandler(c *gin.Context) {
+	pid, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	p := new(models.ParamResolveReport)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("resolve report with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if err := logic.ResolveReport(userID, pid, p.Action, c.ClientIP()); err != nil {
+		zap.L().Error("logic.ResolveReport failed", zap.Int64("pid", pid), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
@@ -2,15 +2,28 @@ package controller
 
 import (
 	"errors"
+	"go-web-app/models"
+	"go-web-app/settings"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
-const ContextUserIDKey = "userID"
+const (
+	ContextUserIDKey    = "userID"
+	ContextSessionIDKey = "sessionID"
+)
+
+// maxUserAgentLength 记录在会话中的User-Agent的最大长度
+const maxUserAgentLength = 256
+
+// maxPageOffset 分页时跳过的条数上限，(page-1)*size超过它时拒绝，避免溢出成负数，也避免数据库扫描过多的行
+const maxPageOffset = 100000
 
 var ErrorUserNotLogin = errors.New("User did not login. ")
 
+var ErrorInvalidPage = errors.New("invalid page or size")
+
 func GetCurrentUserID(c *gin.Context) (userID int64, err error) {
 	uid, ok := c.Get(ContextUserIDKey)
 	if !ok {
@@ -25,23 +38,46 @@ func GetCurrentUserID(c *gin.Context) (userID int64, err error) {
 	return
 }
 
-func getPageInfo(c *gin.Context) (int64, int64) {
-	pageStr := c.Query("page")
-	sizeStr := c.Query("size")
+// GetCurrentSessionID 当前请求的登录会话，没有会话之前签发的token返回空字符串
+func GetCurrentSessionID(c *gin.Context) string {
+	return c.GetString(ContextSessionIDKey)
+}
 
-	var (
-		page int64
-		size int64
-		err  error
-	)
+// clientInfo 登录和刷新token的客户端，记录在会话中
+func clientInfo(c *gin.Context) *models.ClientInfo {
+	ua := c.Request.UserAgent()
+	if len(ua) > maxUserAgentLength {
+		ua = ua[:maxUserAgentLength]
+	}
+	return &models.ClientInfo{IP: c.ClientIP(), UserAgent: ua}
+}
+
+// getPageInfo 解析page和size查询参数，所有列表接口都通过这里分页。
+// page默认为1，size默认为pagination.default_size，超过pagination.max_size时按最大值处理；
+// 参数不是数字、page或size小于1、跳过的条数超过maxPageOffset时返回ErrorInvalidPage
+func getPageInfo(c *gin.Context) (page, size int64, err error) {
+	cfg := settings.Current().PaginationConfig
+	return parsePageInfo(c.Query("page"), c.Query("size"), cfg.DefaultSize, cfg.MaxSize)
+}
 
-	page, err = strconv.ParseInt(pageStr, 10, 64)
-	if err != nil {
-		page = 1
+func parsePageInfo(pageStr, sizeStr string, defaultSize, maxSize int64) (page, size int64, err error) {
+	page, size = 1, defaultSize
+	if pageStr != "" {
+		if page, err = strconv.ParseInt(pageStr, 10, 64); err != nil || page < 1 {
+			return 0, 0, ErrorInvalidPage
+		}
+	}
+	if sizeStr != "" {
+		if size, err = strconv.ParseInt(sizeStr, 10, 64); err != nil || size < 1 {
+			return 0, 0, ErrorInvalidPage
+		}
+	}
+	if maxSize > 0 && size > maxSize {
+		size = maxSize
 	}
-	size, err = strconv.ParseInt(sizeStr, 10, 64)
-	if err != nil {
-		size = 10
+	// 用除法比较，page很大时乘法会溢出
+	if page-1 > maxPageOffset/size {
+		return 0, 0, ErrorInvalidPage
 	}
-	return page, size
+	return page, size, nil
 }
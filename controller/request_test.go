@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"go-web-app/settings"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePageInfo(t *testing.T) {
+	tests := []struct {
+		page, size string
+		wantPage   int64
+		wantSize   int64
+		wantErr    bool
+	}{
+		{"", "", 1, 10, false},
+		{"3", "", 3, 10, false},
+		{"2", "20", 2, 20, false},
+		{"1", "100", 1, 100, false},
+		// 超过上限时按最大值处理
+		{"1", "5000", 1, 100, false},
+		{"-1", "10", 0, 0, true},
+		{"0", "10", 0, 0, true},
+		{"1", "0", 0, 0, true},
+		{"1", "-5", 0, 0, true},
+		{"abc", "10", 0, 0, true},
+		{"1", "1e3", 0, 0, true},
+		// 跳过的条数超过上限，包括乘法会溢出的情况
+		{"10001", "10", 10001, 10, false},
+		{"10002", "10", 0, 0, true},
+		{"1844674407370955162", "10", 0, 0, true},
+		{"9223372036854775807", "100", 0, 0, true},
+	}
+	for _, tt := range tests {
+		page, size, err := parsePageInfo(tt.page, tt.size, 10, 100)
+		if tt.wantErr {
+			assert.Equal(t, ErrorInvalidPage, err, "page=%q size=%q", tt.page, tt.size)
+			continue
+		}
+		assert.NoError(t, err, "page=%q size=%q", tt.page, tt.size)
+		assert.Equal(t, tt.wantPage, page, "page=%q size=%q", tt.page, tt.size)
+		assert.Equal(t, tt.wantSize, size, "page=%q size=%q", tt.page, tt.size)
+	}
+
+	// max_size为0时不限制
+	_, size, err := parsePageInfo("", "5000", 10, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5000), size)
+	// 不限制size时同样检查跳过的条数
+	_, _, err = parsePageInfo("2", "9223372036854775807", 10, 0)
+	assert.Equal(t, ErrorInvalidPage, err)
+}
+
+func TestGetPageInfo(t *testing.T) {
+	old := settings.Conf.PaginationConfig
+	settings.Conf.PaginationConfig = &settings.PaginationConfig{DefaultSize: 20, MaxSize: 50}
+	defer func() { settings.Conf.PaginationConfig = old }()
+
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/api/v1/posts?page=2", nil)
+	page, size, err := getPageInfo(c)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), page)
+	assert.Equal(t, int64(20), size)
+
+	// gin会缓存解析过的查询参数，每个请求用新的Context
+	c, _ = gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/api/v1/posts?size=500", nil)
+	_, size, err = getPageInfo(c)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(50), size)
+}
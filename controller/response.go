@@ -1,9 +1,12 @@
 package controller
 
 import (
+	"go-web-app/settings"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
 )
 
 /*
@@ -21,13 +24,51 @@ type ResponseData struct {
 	Data interface{} `json:"data,omitempty"`
 }
 
+func msgPackEnabled() bool {
+	cfg := settings.Current().APIConfig
+	return cfg != nil && cfg.MsgPack
+}
+
+// wantsMsgPack 开启了api.msgpack并且客户端在Accept中把MessagePack放在JSON之前时返回true，默认使用JSON
+// 和gin一样按Accept中的顺序匹配，不处理q值
+func wantsMsgPack(c *gin.Context) bool {
+	if !msgPackEnabled() {
+		return false
+	}
+	for _, v := range strings.Split(c.GetHeader("Accept"), ",") {
+		if i := strings.IndexByte(v, ';'); i >= 0 {
+			v = v[:i]
+		}
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "application/msgpack", "application/x-msgpack":
+			return true
+		case "application/json", "application/*", "*/*":
+			return false
+		}
+	}
+	return false
+}
+
+// writeResponse 所有ResponseData格式的响应都通过这里写入，按Accept选择JSON或MessagePack
+func writeResponse(c *gin.Context, status int, rd *ResponseData) {
+	if msgPackEnabled() {
+		c.Writer.Header().Add("Vary", "Accept")
+	}
+	if wantsMsgPack(c) {
+		c.Render(status, render.MsgPack{Data: rd})
+		return
+	}
+	c.JSON(status, rd)
+}
+
+// ResponseError 返回错误码，HTTP状态码见codeStatusMap
 func ResponseError(c *gin.Context, code ResCode) {
 	rd := &ResponseData{
 		Code: code,
 		Msg:  code.Msg(),
 		Data: nil,
 	}
-	c.JSON(http.StatusOK, rd)
+	writeResponse(c, code.Status(), rd)
 }
 
 func ResponseSuccess(c *gin.Context, data interface{}) {
@@ -36,7 +77,7 @@ func ResponseSuccess(c *gin.Context, data interface{}) {
 		Msg:  CodeSuccess.Msg(),
 		Data: data,
 	}
-	c.JSON(http.StatusOK, rd)
+	writeResponse(c, http.StatusOK, rd)
 }
 
 func ResponseErrorWithMsg(c *gin.Context, code ResCode, msg interface{}) {
@@ -45,5 +86,59 @@ func ResponseErrorWithMsg(c *gin.Context, code ResCode, msg interface{}) {
 		Msg:  msg,
 		Data: nil,
 	}
-	c.JSON(http.StatusOK, rd)
+	writeResponse(c, code.Status(), rd)
+}
+
+// ResponseErrorWithStatus 需要使用与codeStatusMap不同的HTTP状态码时使用，例如认证失败时返回401
+func ResponseErrorWithStatus(c *gin.Context, status int, code ResCode) {
+	rd := &ResponseData{
+		Code: code,
+		Msg:  code.Msg(),
+		Data: nil,
+	}
+	writeResponse(c, status, rd)
+}
+
+// PageData 列表接口统一的分页结构
+// 能统计总数的列表返回total和total_pages，基于redis有序集合的列表只返回has_more
+type PageData struct {
+	List       interface{} `json:"list"`
+	Page       int64       `json:"page"`
+	Size       int64       `json:"size"`
+	Total      *int64      `json:"total,omitempty"`
+	TotalPages *int64      `json:"total_pages,omitempty"`
+	HasMore    *bool       `json:"has_more,omitempty"`
+}
+
+// NewPageData 根据总数构造分页结构
+func NewPageData(list interface{}, page, size, total int64) *PageData {
+	var totalPages int64
+	if size > 0 {
+		totalPages = (total + size - 1) / size
+	}
+	return &PageData{
+		List:       list,
+		Page:       page,
+		Size:       size,
+		Total:      &total,
+		TotalPages: &totalPages,
+	}
+}
+
+// NewPageDataWithMore 不方便统计总数时，只告诉客户端是否还有下一页
+func NewPageDataWithMore(list interface{}, page, size int64, hasMore bool) *PageData {
+	return &PageData{
+		List:    list,
+		Page:    page,
+		Size:    size,
+		HasMore: &hasMore,
+	}
+}
+
+func ResponseSuccessWithPage(c *gin.Context, list interface{}, page, size, total int64) {
+	ResponseSuccess(c, NewPageData(list, page, size, total))
+}
+
+func ResponseSuccessWithMore(c *gin.Context, list interface{}, page, size int64, hasMore bool) {
+	ResponseSuccess(c, NewPageDataWithMore(list, page, size, hasMore))
 }
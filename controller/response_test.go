@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"encoding/json"
+	"go-web-app/settings"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
+)
+
+func TestNewPageData(t *testing.T) {
+	p := NewPageData([]int{1, 2, 3}, 2, 3, 7)
+	assert.Equal(t, int64(7), *p.Total)
+	assert.Equal(t, int64(3), *p.TotalPages)
+	assert.Nil(t, p.HasMore)
+
+	p = NewPageData([]int{}, 1, 10, 0)
+	assert.Equal(t, int64(0), *p.TotalPages)
+
+	b, _ := json.Marshal(NewPageDataWithMore([]int{1}, 1, 1, false))
+	assert.JSONEq(t, `{"list":[1],"page":1,"size":1,"has_more":false}`, string(b))
+}
+
+// encodingSample 覆盖常见的字段类型，两种编码解码后应该得到相同的结果
+type encodingSample struct {
+	ID      int64     `json:"id"`
+	Name    string    `json:"name"`
+	Score   float64   `json:"score"`
+	Tags    []string  `json:"tags"`
+	Secret  string    `json:"-"`
+	Empty   string    `json:"empty,omitempty"`
+	Created time.Time `json:"created"`
+	Nested  *struct {
+		OK bool `json:"ok"`
+	} `json:"nested"`
+}
+
+func TestResponseEncoding(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	old := settings.Conf.APIConfig
+	settings.Conf.APIConfig = &settings.APIConfig{MsgPack: true}
+	defer func() { settings.Conf.APIConfig = old }()
+
+	sample := &encodingSample{ID: 1 << 60, Name: "hello", Score: 1.5, Tags: []string{"a", "b"}, Secret: "x",
+		Created: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	sample.Nested = &struct {
+		OK bool `json:"ok"`
+	}{OK: true}
+	do := func(accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Request.Header.Set("Accept", accept)
+		ResponseSuccess(c, sample)
+		return w
+	}
+	var result struct {
+		Code ResCode         `json:"code"`
+		Msg  string          `json:"msg"`
+		Data *encodingSample `json:"data"`
+	}
+
+	w := do("")
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+	assert.Equal(t, "Accept", w.Header().Get("Vary"))
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	fromJSON := *result.Data
+
+	w = do("application/msgpack")
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/msgpack")
+	result.Data = nil
+	var mh codec.MsgpackHandle
+	require.NoError(t, codec.NewDecoderBytes(w.Body.Bytes(), &mh).Decode(&result))
+	assert.Equal(t, CodeSuccess, result.Code)
+	fromMsgPack := *result.Data
+
+	want := *sample
+	want.Secret = ""
+	assert.Equal(t, want, fromJSON)
+	assert.Equal(t, want, fromMsgPack)
+
+	// JSON在前或者只接受其他类型时仍然使用JSON
+	assert.Contains(t, do("application/json, application/msgpack").Header().Get("Content-Type"), "application/json")
+	assert.Contains(t, do("text/html").Header().Get("Content-Type"), "application/json")
+	// 关闭api.msgpack之后忽略Accept
+	settings.Conf.APIConfig.MsgPack = false
+	assert.Contains(t, do("application/msgpack").Header().Get("Content-Type"), "application/json")
+}
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"go-web-app/logic"
+	"go-web-app/models"
+	"go-web-app/settings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// searchResults 全局搜索的结果，只包含搜索的类型，每种类型分别分页
+type searchResults struct {
+	Type     string    `json:"type"`
+	Posts    *PageData `json:"posts,omitempty"`
+	Comments *PageData `json:"comments,omitempty"`
+	Users    *PageData `json:"users,omitempty"`
+}
+
+// globalSearch 指定了type时的搜索，type=all时忽略分页参数，每种开启的类型各返回前search.all_size条
+func globalSearch(c *gin.Context, p *models.ParamSearch) {
+	if !logic.SearchTypeEnabled(p.Type) {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	types := []string{p.Type}
+	if p.Type == models.SearchTypeAll {
+		types = []string{models.SearchTypePosts, models.SearchTypeComments, models.SearchTypeUsers}
+		p.Page, p.Size = 1, settings.Current().SearchConfig.AllSize
+	}
+	ctx := c.Request.Context()
+	userID, _ := GetCurrentUserID(c)
+	res := &searchResults{Type: p.Type}
+	for _, t := range types {
+		if !logic.SearchTypeEnabled(t) {
+			continue
+		}
+		var err error
+		switch t {
+		case models.SearchTypePosts:
+			var data []*models.PostDetail
+			var total int64
+			if data, total, err = logic.SearchPosts(ctx, p); err == nil {
+				res.Posts = NewPageData(selectFields(c, fieldsPost, personalizePosts(c, data)), p.Page, p.Size, total)
+			}
+		case models.SearchTypeComments:
+			var data []*models.CommentSearchResult
+			var hasMore bool
+			if data, hasMore, err = logic.SearchComments(ctx, userID, p); err == nil {
+				res.Comments = NewPageDataWithMore(data, p.Page, p.Size, hasMore)
+			}
+		case models.SearchTypeUsers:
+			var data []*models.AuthorProfile
+			var hasMore bool
+			if data, hasMore, err = logic.SearchUsers(ctx, userID, p); err == nil {
+				res.Users = NewPageDataWithMore(data, p.Page, p.Size, hasMore)
+			}
+		}
+		if err != nil {
+			zap.L().Error("global search failed", zap.String("type", t), zap.Error(err))
+			ResponseError(c, CodeServerBusy)
+			return
+		}
+	}
+	ResponseSuccess(c, res)
+}
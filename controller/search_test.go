@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"encoding/json"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlobalSearchTypes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	old := settings.Conf.SearchConfig
+	settings.Conf.SearchConfig = &settings.SearchConfig{Types: []string{models.SearchTypePosts}, AllSize: 5}
+	t.Cleanup(func() { settings.Conf.SearchConfig = old })
+
+	search := func(p *models.ParamSearch) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		globalSearch(c, p)
+		return w
+	}
+	// 没有开启的类型不能搜索
+	w := search(&models.ParamSearch{Query: "exam", Type: models.SearchTypeUsers, Page: 1, Size: 10})
+	var res struct {
+		Code ResCode `json:"code"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, CodeInvalidParam, res.Code)
+
+	// type=all时只包含开启的类型，分页参数被忽略
+	settings.Conf.SearchConfig.Types = []string{models.SearchTypeUsers}
+	p := &models.ParamSearch{Query: "@", Type: models.SearchTypeAll, Page: 3, Size: 50}
+	w = search(p)
+	var all struct {
+		Code ResCode                    `json:"code"`
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &all))
+	assert.Equal(t, CodeSuccess, all.Code)
+	assert.Contains(t, all.Data, "users")
+	assert.NotContains(t, all.Data, "posts")
+	assert.NotContains(t, all.Data, "comments")
+	assert.EqualValues(t, 1, p.Page)
+	assert.EqualValues(t, 5, p.Size)
+}
@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"go-web-app/logic"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// SessionListHandler 当前用户所有登录的设备，current标记发起请求的会话
+func SessionListHandler(c *gin.Context) {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	sessions, err := logic.GetSessions(userID, GetCurrentSessionID(c))
+	if err != nil {
+		zap.L().Error("logic.GetSessions failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, sessions)
+}
+
+// RevokeSessionHandler 退出一个设备，也可以是当前会话
+func RevokeSessionHandler(c *gin.Context) {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if err := logic.RevokeSession(userID, c.Param("id")); err != nil {
+		zap.L().Error("logic.RevokeSession failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// RevokeOtherSessionsHandler 退出除了当前会话之外的所有设备
+func RevokeOtherSessionsHandler(c *gin.Context) {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	n, err := logic.RevokeOtherSessions(userID, GetCurrentSessionID(c))
+	if err != nil {
+		zap.L().Error("logic.RevokeOtherSessions failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, gin.H{"revoked": n})
+}
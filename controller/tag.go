@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"context"
+	"go-web-app/logic"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TagSuggestHandler 按前缀补全标签，GET /tags?prefix=go
+func TagSuggestHandler(c *gin.Context) {
+	prefix := strings.ToLower(strings.TrimSpace(c.Query("prefix")))
+	if prefix == "" {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	data, err := logic.SuggestTags(prefix)
+	if err != nil {
+		zap.L().Error("logic.SuggestTags() failed", zap.String("prefix", prefix), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// TagDetailHandler 返回标签下的帖子数
+func TagDetailHandler(c *gin.Context) {
+	tag, err := logic.NormalizeTag(c.Param("name"))
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	data, err := logic.GetTag(tag)
+	if err != nil {
+		zap.L().Error("logic.GetTag() failed", zap.String("tag", tag), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// TrendingTagsHandler 最近一段时间内最活跃的标签
+func TrendingTagsHandler(c *gin.Context) {
+	data, err := cachedResponse(c, logic.CacheTrendingTags, func(context.Context) (interface{}, error) {
+		return logic.GetTrendingTags()
+	})
+	if err != nil {
+		zap.L().Error("logic.GetTrendingTags() failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// FollowTagHandler 关注标签，关注的标签中有帖子变热门时收到通知
+func FollowTagHandler(c *gin.Context) {
+	handleTagFollow(c, logic.FollowTag)
+}
+
+func UnfollowTagHandler(c *gin.Context) {
+	handleTagFollow(c, logic.UnfollowTag)
+}
+
+func handleTagFollow(c *gin.Context, action func(userID int64, tag string) error) {
+	tag, err := logic.NormalizeTag(c.Param("name"))
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if err := action(userID, tag); err != nil {
+		zap.L().Error("tag follow action failed", zap.Int64("userID", userID), zap.String("tag", tag), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// FollowedTagsHandler 当前用户关注的标签
+func FollowedTagsHandler(c *gin.Context) {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	data, err := logic.GetFollowedTags(userID)
+	if err != nil {
+		zap.L().Error("logic.GetFollowedTags() failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
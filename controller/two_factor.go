@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"go-web-app/logic"
+	"go-web-app/models"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// LoginTwoFactorHandler 登录时提交验证码或恢复码，通过后返回token
+func LoginTwoFactorHandler(c *gin.Context) {
+	p := new(models.ParamTwoFactorLogin)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("two-factor login with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	userID, token, err := logic.LoginTwoFactor(p, clientInfo(c))
+	if err != nil {
+		zap.L().Error("logic.LoginTwoFactor failed", zap.Error(err))
+		setLoginRetryAfter(c, err)
+		ResponseErrorFrom(c, err)
+		return
+	}
+	auditAs(c, userID, models.AuditActionLogin, userTarget(userID))
+	ResponseSuccess(c, token)
+}
+
+func TwoFactorStatusHandler(c *gin.Context) {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	status, err := logic.GetTwoFactorStatus(userID)
+	if err != nil {
+		zap.L().Error("logic.GetTwoFactorStatus failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, status)
+}
+
+// TwoFactorSetupHandler 生成密钥和二维码的内容，提交第一个验证码之后才开启
+func TwoFactorSetupHandler(c *gin.Context) {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	setup, err := logic.SetupTwoFactor(userID)
+	if err != nil {
+		zap.L().Error("logic.SetupTwoFactor failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, setup)
+}
+
+// EnableTwoFactorHandler 校验第一个验证码并开启两步验证，返回的恢复码只显示这一次
+func EnableTwoFactorHandler(c *gin.Context) {
+	handleTwoFactorCode(c, "logic.EnableTwoFactor", func(userID int64, code string) (interface{}, error) {
+		codes, err := logic.EnableTwoFactor(userID, code)
+		if err == nil {
+			audit(c, models.AuditActionEnable2FA, userTarget(userID))
+		}
+		return codes, err
+	})
+}
+
+func DisableTwoFactorHandler(c *gin.Context) {
+	handleTwoFactorCode(c, "logic.DisableTwoFactor", func(userID int64, code string) (interface{}, error) {
+		err := logic.DisableTwoFactor(userID, code)
+		if err == nil {
+			audit(c, models.AuditActionDisable2FA, userTarget(userID))
+		}
+		return nil, err
+	})
+}
+
+// RecoveryCodesHandler 重新生成恢复码，之前的恢复码全部失效
+func RecoveryCodesHandler(c *gin.Context) {
+	handleTwoFactorCode(c, "logic.RegenerateRecoveryCodes", func(userID int64, code string) (interface{}, error) {
+		return logic.RegenerateRecoveryCodes(userID, code)
+	})
+}
+
+// handleTwoFactorCode 需要当前用户提交验证码的接口
+func handleTwoFactorCode(c *gin.Context, name string, fn func(userID int64, code string) (interface{}, error)) {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	p := new(models.ParamTwoFactorCode)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("two-factor code with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	data, err := fn(userID, p.Code)
+	if err != nil {
+		zap.L().Error(name+" failed", zap.Int64("userID", userID), zap.Error(err))
+		setLoginRetryAfter(c, err)
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, data)
+}
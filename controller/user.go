@@ -2,11 +2,13 @@ package controller
 
 import (
 	"errors"
-	"go-web-app/dao/mysql"
 	"go-web-app/logic"
 	"go-web-app/models"
-
-	"github.com/go-playground/validator/v10"
+	"go-web-app/settings"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
 
 	"go.uber.org/zap"
 
@@ -18,55 +20,304 @@ func SignUpHandler(c *gin.Context) {
 	p := new(models.ParamSignUp)
 	if err := c.ShouldBindJSON(p); err != nil {
 		zap.L().Error("signup with invalid param", zap.Error(err))
-		errs, ok := err.(validator.ValidationErrors)
-		if !ok {
-			ResponseError(c, CodeInvalidParam)
-			return
-		}
-		ResponseErrorWithMsg(c, CodeInvalidParam, removeTopStruct(errs.Translate(trans)))
+		ResponseBindError(c, err)
 		//c.JSON(http.StatusOK, gin.H{
 		//	"msg": removeTopStruct(errs.Translate(trans)),
 		//})
 
 		return
 	}
+	if err := logic.VerifyCaptcha(c.Request.Context(), p.CaptchaToken, c.ClientIP()); err != nil {
+		ResponseErrorFrom(c, err)
+		return
+	}
 
-	zap.L().Info("User signup successfully")
 	// business logic
-	if err := logic.SignUp(p); err != nil {
+	user, err := logic.SignUp(c.Request.Context(), p)
+	if err != nil {
 		zap.L().Error("Signup failed", zap.Error(err))
-		if errors.Is(err, mysql.ErrorUserExist) {
-			ResponseError(c, CodeUserExist)
-			return
-		}
-		ResponseError(c, CodeServerBusy)
+		ResponseErrorFrom(c, err)
 		return
 	}
+	zap.L().Info("User signup successfully")
 	// return responses
-	ResponseSuccess(c, nil)
+	ResponseSuccess(c, user)
 }
 
 func LoginHandler(c *gin.Context) {
 	p := new(models.ParamLogin)
 	if err := c.ShouldBindJSON(p); err != nil {
 		zap.L().Error("login with invalid param", zap.Error(err))
-		errs, ok := err.(validator.ValidationErrors)
-		if !ok {
-			ResponseError(c, CodeInvalidParam)
-			return
-		}
-		ResponseErrorWithMsg(c, CodeInvalidParam, removeTopStruct(errs.Translate(trans)))
+		ResponseBindError(c, err)
+		return
+	}
+	userID, token, err := logic.Login(p, clientInfo(c))
+	if errors.Is(err, logic.ErrorTwoFactorRequired) {
+		// 密码正确，返回临时token，验证码通过之后才记录登录
+		ResponseErrorFrom(c, err)
 		return
 	}
-	token, err := logic.Login(p)
 	if err != nil {
 		zap.L().Error("logic.Login failed", zap.String("username", p.Username), zap.Error(err))
-		if errors.Is(err, mysql.ErrorUserNotExist) {
-			ResponseError(c, CodeUserNotExist)
-			return
-		}
-		ResponseError(c, CodeInvalidPassword)
+		setLoginRetryAfter(c, err)
+		ResponseErrorFrom(c, err)
 		return
 	}
+	auditAs(c, userID, models.AuditActionLogin, userTarget(userID))
 	ResponseSuccess(c, token)
 }
+
+// setLoginRetryAfter 登录被临时锁定时告诉客户端还要等多久
+func setLoginRetryAfter(c *gin.Context, err error) {
+	var locked *logic.LoginLockedError
+	if errors.As(err, &locked) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+	}
+}
+
+func RefreshTokenHandler(c *gin.Context) {
+	p := new(models.ParamRefreshToken)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("refresh token with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	token, err := logic.RefreshToken(p, clientInfo(c))
+	if err != nil {
+		zap.L().Error("logic.RefreshToken failed", zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, token)
+}
+
+func LogoutHandler(c *gin.Context) {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if err := logic.Logout(userID, GetCurrentSessionID(c)); err != nil {
+		zap.L().Error("logic.Logout failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+func ForgotPasswordHandler(c *gin.Context) {
+	p := new(models.ParamForgotPassword)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("forgot password with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	if err := logic.VerifyCaptcha(c.Request.Context(), p.CaptchaToken, c.ClientIP()); err != nil {
+		ResponseErrorFrom(c, err)
+		return
+	}
+	if err := logic.ForgotPassword(p); err != nil {
+		zap.L().Error("logic.ForgotPassword failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+func ResetPasswordHandler(c *gin.Context) {
+	p := new(models.ParamResetPassword)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("reset password with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	userID, err := logic.ResetPassword(p)
+	if err != nil {
+		zap.L().Error("logic.ResetPassword failed", zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	auditAs(c, userID, models.AuditActionPasswordReset, userTarget(userID))
+	ResponseSuccess(c, nil)
+}
+
+func VerifyEmailHandler(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	if err := logic.VerifyEmail(token); err != nil {
+		zap.L().Error("logic.VerifyEmail failed", zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+func ResendVerificationHandler(c *gin.Context) {
+	p := new(models.ParamResendVerification)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("resend verification with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	if err := logic.ResendVerification(p); err != nil {
+		zap.L().Error("logic.ResendVerification failed", zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// UploadAvatarHandler 上传头像，表单字段为avatar
+func UploadAvatarHandler(c *gin.Context) {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	file, err := c.FormFile("avatar")
+	if err != nil {
+		zap.L().Error("c.FormFile(avatar) failed", zap.Error(err))
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	maxSize := settings.Current().AvatarConfig.MaxSize * 1024
+	if file.Size > maxSize {
+		ResponseError(c, CodeFileTooLarge)
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		zap.L().Error("file.Open() failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	defer f.Close()
+	// 不相信客户端声明的大小，最多读取maxSize+1个字节
+	data, err := ioutil.ReadAll(io.LimitReader(f, maxSize+1))
+	if err != nil {
+		zap.L().Error("read avatar failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	if int64(len(data)) > maxSize {
+		ResponseError(c, CodeFileTooLarge)
+		return
+	}
+	avatarURL, err := logic.UpdateAvatar(userID, data)
+	if err != nil {
+		zap.L().Error("logic.UpdateAvatar failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, gin.H{"avatar": avatarURL})
+}
+
+// ChangeUsernameHandler 修改当前用户的用户名
+func ChangeUsernameHandler(c *gin.Context) {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	p := new(models.ParamChangeUsername)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("change username with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	if err := logic.ChangeUsername(userID, p); err != nil {
+		zap.L().Error("logic.ChangeUsername failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, gin.H{"username": p.Username})
+}
+
+// DeactivateHandler 注销当前账号，已有的帖子和评论会保留
+func DeactivateHandler(c *gin.Context) {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if err := logic.Deactivate(userID); err != nil {
+		zap.L().Error("logic.Deactivate failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// ReactivateHandler 给已注销账号的邮箱发送恢复链接
+func ReactivateHandler(c *gin.Context) {
+	p := new(models.ParamReactivate)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("reactivate with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	if err := logic.RequestReactivation(p); err != nil {
+		zap.L().Error("logic.RequestReactivation failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// ConfirmReactivateHandler 使用邮件中的token恢复账号
+func ConfirmReactivateHandler(c *gin.Context) {
+	p := new(models.ParamConfirmReactivate)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("confirm reactivate with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	if err := logic.ConfirmReactivation(p); err != nil {
+		zap.L().Error("logic.ConfirmReactivation failed", zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// RequestMergeHandler 登录要被合并的账号后生成确认token，token在10分钟内有效
+func RequestMergeHandler(c *gin.Context) {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	data, err := logic.RequestMerge(userID)
+	if err != nil {
+		zap.L().Error("logic.RequestMerge failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// ConfirmMergeHandler 登录目标账号后提交token，把token对应的账号合并到当前账号
+func ConfirmMergeHandler(c *gin.Context) {
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	p := new(models.ParamConfirmMerge)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("confirm merge with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	data, err := logic.ConfirmMerge(userID, p.Token)
+	if err != nil {
+		zap.L().Error("logic.ConfirmMerge failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	auditAs(c, userID, models.AuditActionMergeUser, userTarget(data.FromUserID))
+	ResponseSuccess(c, data)
+}
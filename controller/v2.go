@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"go-web-app/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPostDetailV2Handler 帖子详情的v2版本，数据与v1相同，返回格式见models.PostDetailV2
+func GetPostDetailV2Handler(c *gin.Context) {
+	data, ok := getPostDetail(c)
+	if !ok || checkNotModified(c, postDetailETag(c, "v2", data.ContentHash, data.VoteNum, data.IsSaved)) {
+		return
+	}
+	ResponseSuccess(c, newPostDetailV2(data))
+}
+
+func newPostDetailV2(d *models.PostDetail) *models.PostDetailV2 {
+	v := &models.PostDetailV2{
+		HTML:    d.HTML,
+		Excerpt: d.Excerpt,
+		Preview: d.Preview,
+		VoteNum: d.VoteNum,
+		IsSaved: d.IsSaved,
+		Poll:    d.Poll,
+		Tags:    []string{},
+	}
+	if p := d.Post; p != nil {
+		v.PostID = p.PostID
+		v.Title = p.Title
+		v.Content = p.Content
+		v.CommentLocked = p.CommentLocked
+		v.CommentsLocked = d.CommentsLocked
+		v.Version = p.Version
+		v.CreateTime = p.CreateTime
+		v.Author = &models.AuthorV2{UserID: p.AuthorId, Username: d.AuthorName}
+		if p.Tags != nil {
+			v.Tags = p.Tags
+		}
+	}
+	if cd := d.CommunityDetail; cd != nil {
+		v.Community = &models.CommunityV2{ID: cd.ID, Name: cd.Name}
+	}
+	return v
+}
@@ -2,10 +2,18 @@ package controller
 
 import (
 	"fmt"
+	"go-web-app/logic"
 	"go-web-app/models"
+	"go-web-app/pkg/quiethours"
+	"go-web-app/settings"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/locales/en"
 	"github.com/go-playground/locales/zh"
@@ -15,10 +23,32 @@ import (
 	zhTranslations "github.com/go-playground/validator/v10/translations/zh"
 )
 
-// 定义一个全局翻译器T
-var trans ut.Translator
+// uni 所有支持的语言的翻译器，trans为默认语言的翻译器，请求头中没有支持的语言时使用
+var (
+	uni   *ut.UniversalTranslator
+	trans ut.Translator
+)
+
+// 帖子和评论的文本按sanitizeText处理之后的内容校验
+// 模型的binding tag用到了这些校验，没有调用InitValidator时(例如测试中)也要注册
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		_ = v.RegisterValidation("notblank", notBlank)
+		_ = v.RegisterValidation("textlen", textLen)
+		_ = v.RegisterValidation("communityname", communityName)
+		_ = v.RegisterValidation("notreserved", notReservedCommunityName)
+		_ = v.RegisterValidation("clock", clock)
+		_ = v.RegisterValidation("tzname", tzName)
+	}
+}
 
-// InitTrans 初始化翻译器
+// validatorTranslations 支持的语言和对应的内置翻译
+var validatorTranslations = map[string]func(v *validator.Validate, trans ut.Translator) error{
+	"en": enTranslations.RegisterDefaultTranslations,
+	"zh": zhTranslations.RegisterDefaultTranslations,
+}
+
+// InitValidator 初始化校验器和所有支持的语言的翻译器，locale为默认语言
 func InitValidator(locale string) (err error) {
 	// 修改gin框架中的Validator引擎属性，实现自定制
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
@@ -35,37 +65,132 @@ func InitValidator(locale string) (err error) {
 		// 为SignUpParam注册自定义校验方法
 		v.RegisterStructValidation(SignUpParamStructLevelValidation, models.ParamSignUp{})
 
-		zhT := zh.New() // 中文翻译器
-		enT := en.New() // 英文翻译器
-
-		// 第一个参数是备用（fallback）的语言环境
-		// 后面的参数是应该支持的语言环境（支持多个）
-		// uni := ut.New(zhT, zhT) 也是可以的
-		uni := ut.New(enT, zhT, enT)
+		// 注册校验学校邮箱的tag
+		if err = v.RegisterValidation("rutgersemail", rutgersEmail); err != nil {
+			return
+		}
 
-		// locale 通常取决于 http 请求头的 'Accept-Language'
-		var ok bool
-		// 也可以使用 uni.FindTranslator(...) 传入多个locale进行查找
-		trans, ok = uni.GetTranslator(locale)
+		// 第一个参数是备用（fallback）的语言环境，后面的参数是支持的语言环境
+		// 每次都重新创建翻译器，重复调用时不会和已经注册的翻译冲突
+		enT := en.New()
+		u := ut.New(enT, enT, zh.New())
+		for l, register := range validatorTranslations {
+			t, _ := u.GetTranslator(l)
+			if err = register(v, t); err != nil {
+				return
+			}
+			if err = registerCustomTranslations(v, t, l); err != nil {
+				return
+			}
+		}
+		t, ok := u.GetTranslator(locale)
 		if !ok {
 			return fmt.Errorf("uni.GetTranslator(%s) failed", locale)
 		}
+		uni, trans = u, t
+	}
+	return
+}
+
+// translatorFor 按请求头Accept-Language选择翻译器，例如"zh-CN,zh;q=0.9,en;q=0.8"
+// 只比较主语言，没有支持的语言时使用默认语言
+func translatorFor(c *gin.Context) ut.Translator {
+	if uni == nil {
+		return trans
+	}
+	if t, ok := uni.FindTranslator(acceptLanguages(c.GetHeader("Accept-Language"))...); ok {
+		return t
+	}
+	return trans
+}
 
-		// 注册翻译器
-		switch locale {
-		case "en":
-			err = enTranslations.RegisterDefaultTranslations(v, trans)
-		case "zh":
-			err = zhTranslations.RegisterDefaultTranslations(v, trans)
-		default:
-			err = enTranslations.RegisterDefaultTranslations(v, trans)
+// acceptLanguages 解析Accept-Language，按q值从高到低返回主语言
+func acceptLanguages(header string) []string {
+	type lang struct {
+		tag string
+		q   float64
+	}
+	langs := make([]lang, 0)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if i := strings.IndexAny(tag, "-_"); i >= 0 {
+			tag = tag[:i]
+		}
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if v, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			langs = append(langs, lang{tag, q})
 		}
-		return
 	}
-	return
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+	tags := make([]string, 0, len(langs))
+	for _, l := range langs {
+		tags = append(tags, l.tag)
+	}
+	return tags
+}
+
+// registerCustomTranslations 为自定义的校验tag注册翻译
+func registerCustomTranslations(v *validator.Validate, trans ut.Translator, locale string) error {
+	msgs := map[string]string{
+		"rutgersemail":  "{0} must be a Rutgers email address",
+		"notblank":      "{0} must not be empty",
+		"textlen":       "{0} must be at most {1} characters",
+		"communityname": "{0} must be {1} characters of letters, digits, '_' or '-'",
+		"notreserved":   "{0} is reserved",
+		"taken":         "{0} is already taken",
+		"textmin":       "{0} must be at least {1} characters",
+		"clock":         "{0} must be a time in HH:MM format",
+		"tzname":        "{0} must be a time zone name such as America/New_York",
+	}
+	if locale == "zh" {
+		msgs = map[string]string{
+			"rutgersemail":  "{0}必须是罗格斯大学邮箱",
+			"notblank":      "{0}不能为空",
+			"textlen":       "{0}长度不能超过{1}个字符",
+			"communityname": "{0}必须是{1}个字母、数字、下划线或连字符",
+			"notreserved":   "{0}是保留名称",
+			"taken":         "{0}已被使用",
+			"textmin":       "{0}长度不能少于{1}个字符",
+			"clock":         "{0}必须是HH:MM格式的时间",
+			"tzname":        "{0}必须是时区名称，例如America/New_York",
+		}
+	}
+	for tag, msg := range msgs {
+		tag, msg := tag, msg
+		err := v.RegisterTranslation(tag, trans, func(ut ut.Translator) error {
+			return ut.Add(tag, msg, true)
+		}, func(ut ut.Translator, fe validator.FieldError) string {
+			param := fe.Param()
+			switch tag {
+			case "textlen":
+				param = strconv.Itoa(textLimit(param))
+			case "communityname":
+				min, max := communityNameLen()
+				param = fmt.Sprintf("%d-%d", min, max)
+			}
+			t, _ := ut.T(tag, fe.Field(), param)
+			return t
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// removeTopStruct 去除提示信息中的结构体名称
+// removeTopStruct 去除提示信息中的结构体名称，嵌套的字段保留为json名称的路径，例如tags[0]
 func removeTopStruct(fields map[string]string) map[string]string {
 	res := map[string]string{}
 	for field, err := range fields {
@@ -74,6 +199,29 @@ func removeTopStruct(fields map[string]string) map[string]string {
 	return res
 }
 
+// ResponseBindError 参数校验失败时msg为字段名到错误信息的映射，方便前端标出对应的输入框
+// 其他错误(例如JSON格式错误)返回通用的参数错误
+func ResponseBindError(c *gin.Context, err error) {
+	errs, ok := err.(validator.ValidationErrors)
+	if !ok {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	ResponseErrorWithMsg(c, CodeInvalidParam, removeTopStruct(errs.Translate(translatorFor(c))))
+}
+
+// ResponseFieldError 和ResponseBindError的格式一样，用于校验之后才发现的字段错误，例如名称已被使用
+// tag为registerCustomTranslations中注册的翻译，params为翻译中{1}开始的参数
+func ResponseFieldError(c *gin.Context, field, tag string, params ...string) {
+	msg := field + " is invalid"
+	if t := translatorFor(c); t != nil {
+		if s, err := t.T(tag, append([]string{field}, params...)...); err == nil {
+			msg = s
+		}
+	}
+	ResponseErrorWithMsg(c, CodeInvalidParam, map[string]string{field: msg})
+}
+
 // SignUpParamStructLevelValidation 自定义SignUpParam结构体校验函数
 func SignUpParamStructLevelValidation(sl validator.StructLevel) {
 	su := sl.Current().Interface().(models.ParamSignUp)
@@ -83,3 +231,115 @@ func SignUpParamStructLevelValidation(sl validator.StructLevel) {
 		sl.ReportError(su.RePassword, "re_password", "RePassword", "eqfield", "password")
 	}
 }
+
+// rutgersEmail 校验邮箱域名是否在配置的允许列表中
+func rutgersEmail(fl validator.FieldLevel) bool {
+	var domains []string
+	if settings.Current().AuthConfig != nil {
+		domains = settings.Current().AuthConfig.AllowedEmailDomains
+	}
+	return logic.IsAllowedEmailDomain(fl.Field().String(), domains)
+}
+
+// sanitizeText 去掉文本中的控制字符(保留换行和制表符)和首尾的空白
+func sanitizeText(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' || r == '\r' {
+			return r
+		}
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+	return strings.TrimSpace(s)
+}
+
+// sanitizeFields 校验通过之后按sanitizeText处理用户提交的文本
+func sanitizeFields(texts ...*string) {
+	for _, text := range texts {
+		*text = sanitizeText(*text)
+	}
+}
+
+func notBlank(fl validator.FieldLevel) bool {
+	return sanitizeText(fl.Field().String()) != ""
+}
+
+// textLimit 参数为title、post或comment，对应text_limit中的配置
+func textLimit(kind string) int {
+	cfg := settings.Current().TextLimitConfig
+	if cfg == nil {
+		return 0
+	}
+	switch kind {
+	case "title":
+		return cfg.Title
+	case "post":
+		return cfg.Post
+	case "comment":
+		return cfg.Comment
+	}
+	return 0
+}
+
+func textLen(fl validator.FieldLevel) bool {
+	limit := textLimit(fl.Param())
+	return limit <= 0 || utf8.RuneCountInString(sanitizeText(fl.Field().String())) <= limit
+}
+
+// communityNameLen 社区名称的长度范围，没有配置时为2-32
+func communityNameLen() (min, max int) {
+	min, max = 2, 32
+	if cfg := settings.Current().CommunityConfig; cfg != nil {
+		if cfg.NameMinLen > 0 {
+			min = cfg.NameMinLen
+		}
+		if cfg.NameMaxLen > 0 {
+			max = cfg.NameMaxLen
+		}
+	}
+	return
+}
+
+// communityName 社区名称只能包含字母、数字、下划线和连字符，长度在配置的范围内
+func communityName(fl validator.FieldLevel) bool {
+	name := fl.Field().String()
+	min, max := communityNameLen()
+	if len(name) < min || len(name) > max {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// notReservedCommunityName 名称不在community.reserved_names中，忽略大小写
+func notReservedCommunityName(fl validator.FieldLevel) bool {
+	cfg := settings.Current().CommunityConfig
+	if cfg == nil {
+		return true
+	}
+	name := fl.Field().String()
+	for _, reserved := range cfg.ReservedNames {
+		if strings.EqualFold(name, reserved) {
+			return false
+		}
+	}
+	return true
+}
+
+// clock HH:MM格式的时间，例如免打扰时段的开始和结束
+func clock(fl validator.FieldLevel) bool {
+	_, err := quiethours.ParseClock(fl.Field().String())
+	return err == nil
+}
+
+// tzName IANA时区名称，不接受Local
+func tzName(fl validator.FieldLevel) bool {
+	_, err := quiethours.LoadLocation(fl.Field().String())
+	return err == nil
+}
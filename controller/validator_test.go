@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"encoding/json"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeText(t *testing.T) {
+	assert.Equal(t, "hello", sanitizeText("  hello\x00 \n"))
+	assert.Equal(t, "a\nb\tc", sanitizeText("a\nb\tc\x07"))
+	assert.Equal(t, "", sanitizeText(" \x00\t "))
+}
+
+func TestTextValidation(t *testing.T) {
+	old := settings.Conf.TextLimitConfig
+	settings.Conf.TextLimitConfig = &settings.TextLimitConfig{Title: 5}
+	defer func() { settings.Conf.TextLimitConfig = old }()
+
+	type param struct {
+		Title string `binding:"required,notblank,textlen=title"`
+	}
+	assert.NoError(t, binding.Validator.ValidateStruct(&param{Title: " 你好世界啊 "}))
+	assert.Error(t, binding.Validator.ValidateStruct(&param{Title: "toolong"}))
+	assert.Error(t, binding.Validator.ValidateStruct(&param{Title: " \x00 "}))
+}
+
+func TestResponseBindError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	assert.NoError(t, InitValidator("en"))
+
+	type param struct {
+		PostID    string `json:"post_id" binding:"required"`
+		Direction int8   `json:"direction" binding:"oneof=-1 0 1"`
+	}
+	bind := func(body string) map[string]interface{} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		ResponseBindError(c, c.ShouldBindJSON(new(param)))
+		res := make(map[string]interface{})
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		return res
+	}
+
+	res := bind(`{"direction": 2}`)
+	fields, ok := res["msg"].(map[string]interface{})
+	assert.True(t, ok)
+	assert.Contains(t, fields, "post_id")
+	assert.Contains(t, fields, "direction")
+
+	res = bind(`{not json`)
+	assert.Equal(t, CodeInvalidParam.Msg(), res["msg"])
+}
+
+func TestResponseBindErrorLocale(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	assert.NoError(t, InitValidator("en"))
+	// 重复初始化不会和已经注册的翻译冲突
+	assert.NoError(t, InitValidator("en"))
+
+	type param struct {
+		PostID string `json:"post_id" binding:"required"`
+		Title  string `json:"title" binding:"notblank"`
+	}
+	bind := func(acceptLanguage string) map[string]interface{} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"title": " "}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+		if acceptLanguage != "" {
+			c.Request.Header.Set("Accept-Language", acceptLanguage)
+		}
+		ResponseBindError(c, c.ShouldBindJSON(new(param)))
+		res := make(map[string]interface{})
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		fields, ok := res["msg"].(map[string]interface{})
+		assert.True(t, ok)
+		return fields
+	}
+
+	tests := []struct {
+		acceptLanguage string
+		postID, title  string
+	}{
+		{"", "post_id is a required field", "title must not be empty"},
+		{"en-US,en;q=0.9", "post_id is a required field", "title must not be empty"},
+		{"zh-CN,zh;q=0.9,en;q=0.8", "post_id为必填字段", "title不能为空"},
+		{"en;q=0.5, zh", "post_id为必填字段", "title不能为空"},
+		{"fr-FR", "post_id is a required field", "title must not be empty"},
+	}
+	for _, tt := range tests {
+		fields := bind(tt.acceptLanguage)
+		assert.Equal(t, tt.postID, fields["post_id"], tt.acceptLanguage)
+		assert.Equal(t, tt.title, fields["title"], tt.acceptLanguage)
+	}
+
+	// 默认语言改为中文时，不支持的语言使用中文
+	assert.NoError(t, InitValidator("zh"))
+	defer func() { assert.NoError(t, InitValidator("en")) }()
+	assert.Equal(t, "post_id为必填字段", bind("fr-FR")["post_id"])
+}
+
+func TestAcceptLanguages(t *testing.T) {
+	assert.Equal(t, []string{"zh", "zh", "en"}, acceptLanguages("zh-CN,zh;q=0.9,en;q=0.8"))
+	assert.Equal(t, []string{"zh", "en"}, acceptLanguages("en;q=0.5, zh_TW"))
+	assert.Equal(t, []string{"en"}, acceptLanguages("fr;q=0, *, en"))
+	assert.Empty(t, acceptLanguages(""))
+}
+
+func TestCommunityNameValidation(t *testing.T) {
+	old := settings.Conf.CommunityConfig
+	settings.Conf.CommunityConfig = &settings.CommunityConfig{NameMinLen: 3, NameMaxLen: 8, ReservedNames: []string{"admin", "Official"}}
+	defer func() { settings.Conf.CommunityConfig = old }()
+
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"golang", true},
+		{"Rutgers_CS", false}, // 超过8个字符
+		{"cs-198", true},
+		{"ab", false},
+		{"a b c", false},
+		{"数学系", false},
+		{"ADMIN", false},
+		{"official", false},
+		{"admins", true},
+	}
+	for _, tt := range tests {
+		err := binding.Validator.ValidateStruct(&models.ParamCreateCommunity{Name: tt.name})
+		assert.Equal(t, tt.ok, err == nil, tt.name)
+	}
+}
+
+func TestResponseFieldError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	assert.NoError(t, InitValidator("en"))
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+	c.Request.Header.Set("Accept-Language", "zh-CN")
+	ResponseFieldError(c, "name", "taken")
+	res := make(map[string]interface{})
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, map[string]interface{}{"name": "name已被使用"}, res["msg"])
+}
+
+func TestResponseFieldErrorParams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	assert.NoError(t, InitValidator("en"))
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+	ResponseFieldError(c, "content", "textmin", "20")
+	res := make(map[string]interface{})
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, map[string]interface{}{"content": "content must be at least 20 characters"}, res["msg"])
+}
+
+func TestQuietHoursValidation(t *testing.T) {
+	tests := []struct {
+		p  models.ParamQuietHours
+		ok bool
+	}{
+		{models.ParamQuietHours{}, true}, // 关闭
+		{models.ParamQuietHours{Start: "22:00", End: "07:00", Timezone: "America/New_York"}, true},
+		{models.ParamQuietHours{Start: "09:00", End: "17:30", Timezone: "Asia/Shanghai"}, true},
+		{models.ParamQuietHours{Start: "22:00", Timezone: "UTC"}, false},
+		{models.ParamQuietHours{End: "07:00", Timezone: "UTC"}, false},
+		{models.ParamQuietHours{Start: "22:00", End: "07:00"}, false},
+		{models.ParamQuietHours{Start: "22:00", End: "22:00", Timezone: "UTC"}, false},
+		{models.ParamQuietHours{Start: "25:00", End: "07:00", Timezone: "UTC"}, false},
+		{models.ParamQuietHours{Start: "10pm", End: "07:00", Timezone: "UTC"}, false},
+		{models.ParamQuietHours{Start: "22:00", End: "07:00", Timezone: "Local"}, false},
+		{models.ParamQuietHours{Start: "22:00", End: "07:00", Timezone: "EST5EDT/Nowhere"}, false},
+	}
+	for _, tt := range tests {
+		err := binding.Validator.ValidateStruct(&tt.p)
+		assert.Equal(t, tt.ok, err == nil, "%+v %v", tt.p, err)
+	}
+}
@@ -3,36 +3,166 @@ package controller
 import (
 	"go-web-app/logic"
 	"go-web-app/models"
+	"strconv"
+	"time"
 
 	"go.uber.org/zap"
 
-	"github.com/go-playground/validator/v10"
-
 	"github.com/gin-gonic/gin"
 )
 
 func PostVoteHandler(c *gin.Context) {
 	p := new(models.ParamVoteData)
 	if err := c.ShouldBind(p); err != nil {
-		validationError, ok := err.(validator.ValidationErrors)
-		if !ok {
-			zap.L().Error("err.(validator.ValidationErrors)", zap.Error(validationError))
-			ResponseError(c, CodeInvalidParam)
-			return
-		}
-		errData := removeTopStruct(validationError.Translate(trans))
 		zap.L().Error("c.ShouldBind(p) failed", zap.Error(err))
-		ResponseErrorWithMsg(c, CodeInvalidParam, errData)
+		ResponseBindError(c, err)
 		return
 	}
 	userId, err := GetCurrentUserID(c)
 	if err != nil {
 		ResponseError(c, CodeNeedLogin)
+		return
 	}
-	if err := logic.VoteForPost(userId, p); err != nil {
+	if err := logic.VoteForPost(c.Request.Context(), userId, p); err != nil {
 		zap.L().Error("logic.VoteForPost() failed", zap.Error(err))
-		ResponseError(c, CodeServerBusy)
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, voteUndoData())
+}
+
+// voteUndoData 开启了撤销时告诉客户端撤销窗口的秒数
+func voteUndoData() interface{} {
+	window := logic.VoteUndoWindow()
+	if window <= 0 {
+		return nil
+	}
+	return gin.H{"undo_window": int(window / time.Second)}
+}
+
+// UndoPostVoteHandler 撤销窗口内撤销刚才对帖子的投票，恢复之前的投票
+func UndoPostVoteHandler(c *gin.Context) {
+	p := new(models.ParamUndoVote)
+	if err := c.ShouldBindJSON(p); err != nil {
+		ResponseBindError(c, err)
+		return
+	}
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if err := logic.UndoPostVote(userID, p); err != nil {
+		zap.L().Warn("logic.UndoPostVote failed", zap.String("pid", p.PostId), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// CommentVoteHandler 给评论投票
+func CommentVoteHandler(c *gin.Context) {
+	cid, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	p := new(models.ParamCommentVote)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("comment vote with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if err := logic.VoteForComment(c.Request.Context(), userID, cid, p); err != nil {
+		zap.L().Error("logic.VoteForComment() failed", zap.Int64("cid", cid), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, voteUndoData())
+}
+
+// UndoCommentVoteHandler 撤销窗口内撤销刚才对评论的投票
+func UndoCommentVoteHandler(c *gin.Context) {
+	cid, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if err := logic.UndoCommentVote(userID, cid); err != nil {
+		zap.L().Warn("logic.UndoCommentVote failed", zap.Int64("cid", cid), zap.Error(err))
+		ResponseErrorFrom(c, err)
 		return
 	}
 	ResponseSuccess(c, nil)
 }
+
+// PostVoteStatsHandler 版主查看帖子投票的汇总，用于审核刷票
+func PostVoteStatsHandler(c *gin.Context) {
+	pid, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	data, err := logic.GetPostVoteStats(userID, pid)
+	if err != nil {
+		zap.L().Error("logic.GetPostVoteStats failed", zap.Int64("pid", pid), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// UserVotedPostsHandler 当前用户自己赞成或反对过的帖子，路径中的id不是当前用户时拒绝访问
+// gin不允许/user/votes和/user/:id同时注册，所以路径中带上用户id
+func UserVotedPostsHandler(c *gin.Context) {
+	uid, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if uid != userID {
+		ResponseError(c, CodeNoPermission)
+		return
+	}
+	p := new(models.ParamUserVotes)
+	if err := c.ShouldBindQuery(p); err != nil {
+		ResponseBindError(c, err)
+		return
+	}
+	if p.Dir == "" {
+		p.Dir = models.VoteDirUp
+	}
+	page, size, err := getPageInfo(c)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	data, total, err := logic.GetUserVotedPosts(c.Request.Context(), userID, p.Dir, page, size)
+	if err != nil {
+		zap.L().Error("logic.GetUserVotedPosts failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	logic.FillUserVotes(c.Request.Context(), userID, data)
+	ResponseSuccessWithPage(c, selectFields(c, fieldsPost, data), page, size, total)
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"go-web-app/logic"
+	"go-web-app/models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// WebhookListHandler 版主查看本社区的webhook
+func WebhookListHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	data, err := logic.GetWebhooks(id)
+	if err != nil {
+		zap.L().Error("logic.GetWebhooks failed", zap.Int64("community_id", id), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// AddWebhookHandler 版主添加webhook，社区中有新帖子时通知这个地址
+func AddWebhookHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, err := GetCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	p := new(models.ParamWebhook)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("add webhook with invalid param", zap.Error(err))
+		ResponseBindError(c, err)
+		return
+	}
+	w, err := logic.AddWebhook(id, userID, p)
+	if err != nil {
+		zap.L().Error("logic.AddWebhook failed", zap.Int64("community_id", id), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	audit(c, models.AuditActionAddWebhook, webhookTarget(id, w.WebhookID))
+	ResponseSuccess(c, w)
+}
+
+// DeleteWebhookHandler 版主删除webhook
+func DeleteWebhookHandler(c *gin.Context) {
+	id, wid, ok := parseWebhookParams(c)
+	if !ok {
+		return
+	}
+	if err := logic.DeleteWebhook(id, wid); err != nil {
+		zap.L().Error("logic.DeleteWebhook failed", zap.Int64("community_id", id), zap.Int64("webhook_id", wid), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	audit(c, models.AuditActionDeleteWebhook, webhookTarget(id, wid))
+	ResponseSuccess(c, nil)
+}
+
+// TestWebhookHandler 版主向webhook发送一个测试事件，返回接收方的响应状态
+func TestWebhookHandler(c *gin.Context) {
+	id, wid, ok := parseWebhookParams(c)
+	if !ok {
+		return
+	}
+	data, err := logic.TestWebhook(c.Request.Context(), id, wid)
+	if err != nil {
+		zap.L().Error("logic.TestWebhook failed", zap.Int64("community_id", id), zap.Int64("webhook_id", wid), zap.Error(err))
+		ResponseErrorFrom(c, err)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+func parseWebhookParams(c *gin.Context) (id, wid int64, ok bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	wid, err = strconv.ParseInt(c.Param("wid"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	return id, wid, true
+}
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"errors"
+	"go-web-app/logic"
+	"go-web-app/pkg/hub"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// 使用access token认证而不是cookie，不需要校验Origin
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// NotificationWSHandler 通过websocket推送新通知
+func NotificationWSHandler(c *gin.Context) {
+	userID, ok := wsUserID(c)
+	if !ok {
+		return
+	}
+	client, err := logic.ConnectNotifications(userID)
+	if err != nil {
+		responseWSError(c, err)
+		return
+	}
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade失败时已经给客户端返回了错误
+		zap.L().Warn("websocket upgrade failed", zap.Int64("userID", userID), zap.Error(err))
+		client.Close()
+		return
+	}
+	client.Serve(conn)
+}
+
+// PostCommentsWSHandler 查看帖子时通过websocket接收新评论，每条消息与评论列表中的节点格式相同
+func PostCommentsWSHandler(c *gin.Context) {
+	userID, ok := wsUserID(c)
+	if !ok {
+		return
+	}
+	pid, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	if err := logic.CheckPostIDAccess(c.Request.Context(), userID, pid); err != nil {
+		responseWSError(c, err)
+		return
+	}
+	client, release, err := logic.ConnectPostComments(c.Request.Context(), pid)
+	if err != nil {
+		responseWSError(c, err)
+		return
+	}
+	defer release()
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		zap.L().Warn("websocket upgrade failed", zap.Int64("userID", userID), zap.Int64("pid", pid), zap.Error(err))
+		return
+	}
+	client.Serve(conn)
+}
+
+// wsUserID 浏览器无法给websocket设置请求头，access token可以放在Authorization头或token查询参数中
+// 认证失败时已经写入了错误响应
+func wsUserID(c *gin.Context) (int64, bool) {
+	token := c.Query("token")
+	if parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2); len(parts) == 2 && parts[0] == "Bearer" {
+		token = parts[1]
+	}
+	if token == "" {
+		ResponseErrorWithStatus(c, http.StatusUnauthorized, CodeNeedLogin)
+		return 0, false
+	}
+	mc, err := logic.CheckAccessToken(token)
+	if err != nil {
+		// 握手失败时浏览器只能看到状态码
+		code, _ := errorCode(err)
+		status := http.StatusUnauthorized
+		if code == CodeAccountDeactivated || code == CodeAccountBanned {
+			status = http.StatusForbidden
+		}
+		ResponseErrorWithStatus(c, status, code)
+		return 0, false
+	}
+	return mc.UserID, true
+}
+
+func responseWSError(c *gin.Context, err error) {
+	if errors.Is(err, hub.ErrShuttingDown) {
+		ResponseErrorWithStatus(c, http.StatusServiceUnavailable, CodeServerBusy)
+		return
+	}
+	ResponseErrorFrom(c, err)
+}
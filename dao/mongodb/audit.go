@@ -0,0 +1,65 @@
+package mongodb
+
+import (
+	"context"
+	"go-web-app/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// InsertAuditLog 写入一条审计日志，按audit_id去重，写入超时后重试不会产生重复的日志
+func InsertAuditLog(entry *models.AuditLog) (err error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	filter := bson.D{{Key: "audit_id", Value: entry.AuditID}}
+	_, err = collection(CollectionAudit).UpdateOne(ctx, filter, bson.D{{Key: "$setOnInsert", Value: entry}}, options.Update().SetUpsert(true))
+	return
+}
+
+func auditFilter(p *models.ParamAuditQuery) bson.D {
+	filter := bson.D{}
+	if p.ActorID != 0 {
+		filter = append(filter, bson.E{Key: "actor_id", Value: p.ActorID})
+	}
+	if p.Action != "" {
+		filter = append(filter, bson.E{Key: "action", Value: p.Action})
+	}
+	if p.AuditID != 0 {
+		filter = append(filter, bson.E{Key: "audit_id", Value: p.AuditID})
+	}
+	timeRange := bson.D{}
+	if !p.From.IsZero() {
+		timeRange = append(timeRange, bson.E{Key: "$gte", Value: p.From})
+	}
+	if !p.To.IsZero() {
+		timeRange = append(timeRange, bson.E{Key: "$lt", Value: p.To})
+	}
+	if len(timeRange) > 0 {
+		filter = append(filter, bson.E{Key: "create_time", Value: timeRange})
+	}
+	return filter
+}
+
+// GetAuditLogs 按条件查询审计日志，新的在前
+func GetAuditLogs(p *models.ParamAuditQuery) (list []*models.AuditLog, err error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	opts := options.Find().
+		SetSort(bson.D{{Key: "create_time", Value: -1}}).
+		SetSkip((p.Page - 1) * p.Size).
+		SetLimit(p.Size)
+	cur, err := collection(CollectionAudit).Find(ctx, auditFilter(p), opts)
+	if err != nil {
+		return nil, err
+	}
+	list = make([]*models.AuditLog, 0, p.Size)
+	err = cur.All(ctx, &list)
+	return
+}
+
+func CountAuditLogs(p *models.ParamAuditQuery) (int64, error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	return collection(CollectionAudit).CountDocuments(ctx, auditFilter(p))
+}
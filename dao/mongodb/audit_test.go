@@ -0,0 +1,65 @@
+package mongodb
+
+import (
+	"go-web-app/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestAuditFilter(t *testing.T) {
+	// 条件为空时不过滤
+	assert.Equal(t, bson.D{}, auditFilter(&models.ParamAuditQuery{}))
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	assert.Equal(t, bson.D{
+		{Key: "actor_id", Value: int64(7)},
+		{Key: "action", Value: models.AuditActionBanUser},
+		{Key: "create_time", Value: bson.D{{Key: "$gte", Value: from}, {Key: "$lt", Value: to}}},
+	}, auditFilter(&models.ParamAuditQuery{ActorID: 7, Action: models.AuditActionBanUser, From: from, To: to}))
+
+	// 只有一端的时间范围
+	assert.Equal(t, bson.D{
+		{Key: "audit_id", Value: int64(9)},
+		{Key: "create_time", Value: bson.D{{Key: "$lt", Value: to}}},
+	}, auditFilter(&models.ParamAuditQuery{AuditID: 9, To: to}))
+}
+
+func TestGetAuditLogs(t *testing.T) {
+	useMongo(t)
+	now := time.Now().Truncate(time.Millisecond)
+	entries := []*models.AuditLog{
+		{AuditID: 1, ActorID: 7, Action: models.AuditActionBanUser, Target: "user:1", CreateTime: now.Add(-2 * time.Hour)},
+		{AuditID: 2, ActorID: 7, Action: models.AuditActionPurge, Target: "purge", CreateTime: now.Add(-time.Hour)},
+		{AuditID: 3, ActorID: 8, Action: models.AuditActionBanUser, Target: "user:2", CreateTime: now},
+	}
+	for _, e := range entries {
+		require.NoError(t, InsertAuditLog(e))
+	}
+	// 重复写入同一个audit_id不产生新的日志
+	require.NoError(t, InsertAuditLog(&models.AuditLog{AuditID: 1, ActorID: 9, Action: models.AuditActionLogin, CreateTime: now}))
+
+	ids := func(p *models.ParamAuditQuery) []int64 {
+		p.Page, p.Size = 1, 10
+		list, err := GetAuditLogs(p)
+		require.NoError(t, err)
+		total, err := CountAuditLogs(p)
+		require.NoError(t, err)
+		assert.EqualValues(t, len(list), total)
+		var ids []int64
+		for _, e := range list {
+			ids = append(ids, e.AuditID)
+		}
+		return ids
+	}
+	// 新的在前
+	assert.Equal(t, []int64{3, 2, 1}, ids(&models.ParamAuditQuery{}))
+	assert.Equal(t, []int64{2, 1}, ids(&models.ParamAuditQuery{ActorID: 7}))
+	assert.Equal(t, []int64{3, 1}, ids(&models.ParamAuditQuery{Action: models.AuditActionBanUser}))
+	assert.Equal(t, []int64{2}, ids(&models.ParamAuditQuery{From: now.Add(-90 * time.Minute), To: now}))
+	assert.Empty(t, ids(&models.ParamAuditQuery{ActorID: 9}))
+}
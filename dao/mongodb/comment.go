@@ -0,0 +1,431 @@
+package mongodb
+
+import (
+	"context"
+	"go-web-app/models"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func CreateComment(comment *models.Comment) (err error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	_, err = collection(CollectionComment).InsertOne(ctx, comment)
+	return
+}
+
+func GetCommentByID(cid int64) (comment *models.Comment, err error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	comment = new(models.Comment)
+	filter := bson.D{{Key: "comment_id", Value: cid}, {Key: "deleted", Value: false}}
+	err = collection(CollectionComment).FindOne(ctx, filter).Decode(comment)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrorCommentNotExist
+	}
+	return
+}
+
+// GetCommentListByPostID 按创建时间顺序返回帖子下所有未删除的评论
+func GetCommentListByPostID(pid int64) (comments []*models.Comment, err error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	filter := bson.D{{Key: "post_id", Value: pid}, {Key: "deleted", Value: false}}
+	opts := options.Find().SetSort(bson.D{{Key: "create_time", Value: 1}})
+	cur, err := collection(CollectionComment).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	comments = make([]*models.Comment, 0)
+	err = cur.All(ctx, &comments)
+	return
+}
+
+// GetCommentsByAuthor 按创建时间顺序返回用户所有未删除的评论
+func GetCommentsByAuthor(uid int64) (comments []*models.Comment, err error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	filter := bson.D{{Key: "author_id", Value: uid}, {Key: "deleted", Value: false}}
+	opts := options.Find().SetSort(bson.D{{Key: "create_time", Value: 1}})
+	cur, err := collection(CollectionComment).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	comments = make([]*models.Comment, 0)
+	err = cur.All(ctx, &comments)
+	return
+}
+
+// topLevelFilter 帖子下未删除的顶层评论，不包括excludeAuthors发布的
+func topLevelFilter(pid int64, excludeAuthors []int64) bson.D {
+	filter := bson.D{{Key: "post_id", Value: pid}, {Key: "parent_id", Value: 0}, {Key: "deleted", Value: false}}
+	if len(excludeAuthors) > 0 {
+		filter = append(filter, bson.E{Key: "author_id", Value: bson.D{{Key: "$nin", Value: excludeAuthors}}})
+	}
+	return filter
+}
+
+// CountTopLevelComments 帖子下未删除的顶层评论数，不包括excludeAuthors发布的
+func CountTopLevelComments(pid int64, excludeAuthors []int64) (int64, error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	return collection(CollectionComment).CountDocuments(ctx, topLevelFilter(pid, excludeAuthors))
+}
+
+// GetTopLevelCommentIDs 按创建时间排序后跳过skip个，返回最多limit个顶层评论的id，limit为0时返回全部
+// newestFirst为true时最新的在前
+func GetTopLevelCommentIDs(pid int64, excludeAuthors []int64, skip, limit int64, newestFirst bool) ([]int64, error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	direction := 1
+	if newestFirst {
+		direction = -1
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "create_time", Value: direction}, {Key: "comment_id", Value: direction}}).
+		SetProjection(bson.D{{Key: "comment_id", Value: 1}}).
+		SetSkip(skip).
+		SetLimit(limit)
+	cur, err := collection(CollectionComment).Find(ctx, topLevelFilter(pid, excludeAuthors), opts)
+	if err != nil {
+		return nil, err
+	}
+	var comments []*models.Comment
+	if err := cur.All(ctx, &comments); err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(comments))
+	for _, c := range comments {
+		ids = append(ids, c.CommentID)
+	}
+	return ids, nil
+}
+
+// findComments 按创建时间顺序返回符合filter的评论
+func findComments(filter bson.D) ([]*models.Comment, error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	opts := options.Find().SetSort(bson.D{{Key: "create_time", Value: 1}})
+	cur, err := collection(CollectionComment).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	comments := make([]*models.Comment, 0)
+	err = cur.All(ctx, &comments)
+	return comments, err
+}
+
+// GetCommentTrees 读取rootIDs这些顶层评论和它们下面所有的回复，组装成嵌套深度不超过maxDepth的评论树，按rootIDs的顺序返回
+// 回复逐层按parent_id查询，只读取这一页的评论；已删除的回复也会读取，用于把它下面的回复挂到最近的祖先下面
+func GetCommentTrees(pid int64, rootIDs []int64, maxDepth int) ([]*models.CommentNode, error) {
+	if len(rootIDs) == 0 {
+		return []*models.CommentNode{}, nil
+	}
+	roots, err := findComments(bson.D{
+		{Key: "post_id", Value: pid},
+		{Key: "comment_id", Value: bson.D{{Key: "$in", Value: rootIDs}}},
+		{Key: "parent_id", Value: 0},
+		{Key: "deleted", Value: false},
+	})
+	if err != nil {
+		return nil, err
+	}
+	// buildCommentTree按输入的顺序返回顶层评论
+	order := make(map[int64]int, len(rootIDs))
+	for i, id := range rootIDs {
+		order[id] = i
+	}
+	sort.SliceStable(roots, func(i, j int) bool { return order[roots[i].CommentID] < order[roots[j].CommentID] })
+	comments := roots
+	seen := make(map[int64]bool, len(comments))
+	frontier := make([]int64, 0, len(comments))
+	for _, c := range comments {
+		seen[c.CommentID] = true
+		frontier = append(frontier, c.CommentID)
+	}
+	for len(frontier) > 0 {
+		children, err := findComments(bson.D{
+			{Key: "post_id", Value: pid},
+			{Key: "parent_id", Value: bson.D{{Key: "$in", Value: frontier}}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		frontier = frontier[:0]
+		for _, c := range children {
+			if seen[c.CommentID] {
+				continue
+			}
+			seen[c.CommentID] = true
+			frontier = append(frontier, c.CommentID)
+			comments = append(comments, c)
+		}
+	}
+	return buildCommentTree(comments, maxDepth), nil
+}
+
+// buildCommentTree 根据parent_id把评论组装成树，顶层评论的深度为1，按输入中的顺序返回。
+// comments中可以有已删除的评论，它们不出现在树中，下面的回复挂到最近的未删除的祖先下面；
+// 父评论不存在或者整条祖先链都已删除的回复作为顶层评论，排在原来所属的顶层评论的位置。
+// 超过maxDepth的回复挂到深度为maxDepth-1的祖先下面，与深度为maxDepth的评论并列，而不是丢弃；
+// maxDepth为1时所有的回复都作为顶层评论，排在所属的顶层评论后面。
+// 使用广度优先遍历而不是递归，避免很深的评论链撑爆栈。
+func buildCommentTree(comments []*models.Comment, maxDepth int) []*models.CommentNode {
+	if maxDepth < 1 {
+		maxDepth = 1
+	}
+	exists := make(map[int64]bool, len(comments))
+	for _, comment := range comments {
+		exists[comment.CommentID] = true
+	}
+	childrenOf := make(map[int64][]*models.Comment, len(comments))
+	tops := make([]*models.Comment, 0)
+	for _, comment := range comments {
+		if comment.ParentID == 0 || !exists[comment.ParentID] {
+			tops = append(tops, comment)
+			continue
+		}
+		childrenOf[comment.ParentID] = append(childrenOf[comment.ParentID], comment)
+	}
+
+	type item struct {
+		comment *models.Comment
+		node    *models.CommentNode // 已删除的评论为nil
+		depth   int                 // 该评论在树中的深度，已删除的评论为它的回复占用的深度
+		parent  *models.CommentNode // 该评论在树中实际挂载的父节点，nil表示作为顶层评论
+		group   int                 // 作为顶层评论时排在第几个原顶层评论的位置
+	}
+	queue := make([]item, 0, len(comments))
+	visited := make(map[int64]bool, len(comments))
+	for i, comment := range tops {
+		visited[comment.CommentID] = true
+		queue = append(queue, item{comment: comment, depth: 1, group: i})
+	}
+	for i := 0; i < len(queue); i++ {
+		cur := &queue[i]
+		target, depth := cur.parent, cur.depth
+		if !cur.comment.Deleted {
+			cur.node = &models.CommentNode{Comment: cur.comment, Children: make([]*models.CommentNode, 0)}
+			// 没有到达最大深度时挂到当前节点下面，否则与当前节点并列
+			if cur.depth < maxDepth {
+				target, depth = cur.node, cur.depth+1
+			}
+		}
+		for _, comment := range childrenOf[cur.comment.CommentID] {
+			if visited[comment.CommentID] {
+				continue
+			}
+			visited[comment.CommentID] = true
+			queue = append(queue, item{comment: comment, depth: depth, parent: target, group: cur.group})
+		}
+	}
+	// 逆序累加回复数，保证子节点先于父节点计算完成
+	groups := make([][]*models.CommentNode, len(tops))
+	for i := len(queue) - 1; i >= 0; i-- {
+		cur := queue[i]
+		if cur.node == nil {
+			continue
+		}
+		if cur.parent == nil {
+			groups[cur.group] = append(groups[cur.group], cur.node)
+			continue
+		}
+		cur.parent.Children = append(cur.parent.Children, cur.node)
+		cur.parent.ReplyCount += cur.node.ReplyCount + 1
+	}
+	byTime := func(nodes []*models.CommentNode) {
+		sort.SliceStable(nodes, func(i, j int) bool {
+			return nodes[i].CreateTime.Before(nodes[j].CreateTime)
+		})
+	}
+	for _, cur := range queue {
+		if cur.node != nil {
+			byTime(cur.node.Children)
+		}
+	}
+	roots := make([]*models.CommentNode, 0, len(tops))
+	for _, group := range groups {
+		byTime(group)
+		roots = append(roots, group...)
+	}
+	return roots
+}
+
+// UpdateComment 修改评论的内容和@到的用户，并记录编辑时间
+func UpdateComment(cid int64, content string, mentions []*models.Mention, editedAt time.Time) error {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	filter := bson.D{{Key: "comment_id", Value: cid}, {Key: "deleted", Value: false}}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "content", Value: content},
+		{Key: "mentions", Value: mentions},
+		{Key: "edited_at", Value: editedAt},
+	}}}
+	ret, err := collection(CollectionComment).UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if ret.MatchedCount == 0 {
+		return ErrorCommentNotExist
+	}
+	return nil
+}
+
+// DeleteComment 软删除评论以及它下面的整棵回复树
+func DeleteComment(cid int64) (err error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	ids := []int64{cid}
+	frontier := []int64{cid}
+	for len(frontier) > 0 {
+		filter := bson.D{
+			{Key: "parent_id", Value: bson.D{{Key: "$in", Value: frontier}}},
+			{Key: "deleted", Value: false},
+		}
+		opts := options.Find().SetProjection(bson.D{{Key: "comment_id", Value: 1}})
+		cur, err := collection(CollectionComment).Find(ctx, filter, opts)
+		if err != nil {
+			return err
+		}
+		var children []*models.Comment
+		if err := cur.All(ctx, &children); err != nil {
+			return err
+		}
+		frontier = frontier[:0]
+		for _, child := range children {
+			frontier = append(frontier, child.CommentID)
+			ids = append(ids, child.CommentID)
+		}
+	}
+	filter := bson.D{{Key: "comment_id", Value: bson.D{{Key: "$in", Value: ids}}}}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "deleted", Value: true},
+		{Key: "delete_time", Value: time.Now()},
+	}}}
+	_, err = collection(CollectionComment).UpdateMany(ctx, filter, update)
+	return
+}
+
+// CountComments 未删除的评论总数
+func CountComments() (int64, error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	filter := bson.D{{Key: "deleted", Value: false}}
+	return collection(CollectionComment).CountDocuments(ctx, filter)
+}
+
+// CountUserComments 用户发表的未删除的评论数
+func CountUserComments(uid int64) (int64, error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	filter := bson.D{{Key: "author_id", Value: uid}, {Key: "deleted", Value: false}}
+	return collection(CollectionComment).CountDocuments(ctx, filter)
+}
+
+// ReassignComments 把fromUID的所有评论(包括已删除的)改为toUID发表，返回修改的条数
+func ReassignComments(fromUID, toUID int64) (int64, error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	filter := bson.D{{Key: "author_id", Value: fromUID}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "author_id", Value: toUID}}}}
+	ret, err := collection(CollectionComment).UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return ret.ModifiedCount, nil
+}
+
+// authorCommentFilter 用户在[from, to)之间发表的未删除的评论，时间为零值时不限制
+func authorCommentFilter(uid int64, from, to time.Time) bson.D {
+	filter := bson.D{{Key: "author_id", Value: uid}, {Key: "deleted", Value: false}}
+	createTime := bson.D{}
+	if !from.IsZero() {
+		createTime = append(createTime, bson.E{Key: "$gte", Value: from})
+	}
+	if !to.IsZero() {
+		createTime = append(createTime, bson.E{Key: "$lt", Value: to})
+	}
+	if len(createTime) > 0 {
+		filter = append(filter, bson.E{Key: "create_time", Value: createTime})
+	}
+	return filter
+}
+
+// GetCommentedPostIDs 用户在[from, to)之间评论过的帖子id
+func GetCommentedPostIDs(uid int64, from, to time.Time) ([]int64, error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	values, err := collection(CollectionComment).Distinct(ctx, "post_id", authorCommentFilter(uid, from, to))
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(values))
+	for _, v := range values {
+		if id, ok := v.(int64); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// DeleteCommentsByAuthor 软删除用户在[from, to)之间发表的评论，pids不为nil时只删除这些帖子下的评论
+// 其他用户的回复不删除，父评论被删除后它们不会出现在评论树中，返回删除的条数
+func DeleteCommentsByAuthor(uid int64, pids []int64, from, to time.Time) (int64, error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	filter := authorCommentFilter(uid, from, to)
+	if pids != nil {
+		if len(pids) == 0 {
+			return 0, nil
+		}
+		filter = append(filter, bson.E{Key: "post_id", Value: bson.D{{Key: "$in", Value: pids}}})
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "deleted", Value: true},
+		{Key: "delete_time", Value: time.Now()},
+	}}}
+	ret, err := collection(CollectionComment).UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return ret.ModifiedCount, nil
+}
+
+// textSearchQuery 去掉文本检索中表示短语的引号和表示排除的减号，和帖子搜索一样只按词匹配
+func textSearchQuery(query string) string {
+	fields := strings.Fields(strings.ReplaceAll(query, `"`, " "))
+	terms := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if f = strings.TrimLeft(f, "-"); f != "" {
+			terms = append(terms, f)
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
+// SearchComments 在未删除的评论内容中全文检索，按相关度排序
+func SearchComments(ctx context.Context, query string, skip, limit int64) (comments []*models.Comment, err error) {
+	comments = make([]*models.Comment, 0, limit)
+	if query = textSearchQuery(query); query == "" {
+		return
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	filter := bson.D{
+		{Key: "$text", Value: bson.D{{Key: "$search", Value: query}}},
+		{Key: "deleted", Value: false},
+	}
+	score := bson.D{{Key: "score", Value: bson.D{{Key: "$meta", Value: "textScore"}}}}
+	opts := options.Find().SetProjection(score).SetSort(score).SetSkip(skip).SetLimit(limit)
+	cur, err := collection(CollectionComment).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = cur.All(ctx, &comments)
+	return
+}
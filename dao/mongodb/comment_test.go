@@ -0,0 +1,164 @@
+package mongodb
+
+import (
+	"context"
+	"go-web-app/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildCommentTree(t *testing.T) {
+	now := time.Now()
+	newComment := func(id, parent int64) *models.Comment {
+		return &models.Comment{CommentID: id, ParentID: parent, CreateTime: now.Add(time.Duration(id) * time.Second)}
+	}
+	// 1 -> 2 -> 3 -> 4，另外 5 是顶层评论，6 的父评论不存在
+	comments := []*models.Comment{
+		newComment(1, 0),
+		newComment(2, 1),
+		newComment(3, 2),
+		newComment(4, 3),
+		newComment(5, 0),
+		newComment(6, 100),
+	}
+
+	// 父评论不存在的6作为顶层评论
+	roots := buildCommentTree(comments, 5)
+	require.Len(t, roots, 3)
+	assert.Equal(t, int64(1), roots[0].CommentID)
+	assert.Equal(t, 3, roots[0].ReplyCount)
+	assert.Equal(t, int64(4), roots[0].Children[0].Children[0].Children[0].CommentID)
+	assert.Equal(t, 0, roots[1].ReplyCount)
+	assert.Equal(t, int64(6), roots[2].CommentID)
+
+	// 最大深度为2时，3和4都挂到1下面，与2并列
+	roots = buildCommentTree(comments, 2)
+	assert.Equal(t, 3, roots[0].ReplyCount)
+	children := roots[0].Children
+	if assert.Len(t, children, 3) {
+		assert.Equal(t, int64(2), children[0].CommentID)
+		assert.Equal(t, int64(3), children[1].CommentID)
+		assert.Equal(t, int64(4), children[2].CommentID)
+		assert.Empty(t, children[0].Children)
+	}
+
+	// 最大深度为1时回复不丢弃，作为顶层评论排在所属的顶层评论后面
+	roots = buildCommentTree(comments, 1)
+	ids := make([]int64, 0, len(roots))
+	for _, root := range roots {
+		ids = append(ids, root.CommentID)
+		assert.Empty(t, root.Children)
+	}
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6}, ids)
+}
+
+func TestBuildCommentTreeReparentsOrphans(t *testing.T) {
+	now := time.Now()
+	newComment := func(id, parent int64, deleted bool) *models.Comment {
+		return &models.Comment{CommentID: id, ParentID: parent, Deleted: deleted, CreateTime: now.Add(time.Duration(id) * time.Second)}
+	}
+	// 1 -> 2(已删除) -> 3(已删除) -> 4，4挂到最近的未删除的祖先1下面
+	// 5(已删除) -> 6，6没有未删除的祖先，排在5原来的位置
+	comments := []*models.Comment{
+		newComment(1, 0, false),
+		newComment(2, 1, true),
+		newComment(3, 2, true),
+		newComment(4, 3, false),
+		newComment(5, 0, true),
+		newComment(6, 5, false),
+		newComment(7, 0, false),
+	}
+	roots := buildCommentTree(comments, 5)
+	require.Len(t, roots, 3)
+	assert.Equal(t, int64(1), roots[0].CommentID)
+	assert.Equal(t, 1, roots[0].ReplyCount)
+	require.Len(t, roots[0].Children, 1)
+	assert.Equal(t, int64(4), roots[0].Children[0].CommentID)
+	assert.Equal(t, int64(6), roots[1].CommentID)
+	assert.Equal(t, int64(7), roots[2].CommentID)
+
+	// 被删除的评论占用的深度给它的回复使用
+	roots = buildCommentTree(comments, 2)
+	require.Len(t, roots[0].Children, 1)
+	assert.Equal(t, int64(4), roots[0].Children[0].CommentID)
+}
+
+func TestTopLevelCommentPaging(t *testing.T) {
+	useMongo(t)
+	now := time.Now().Truncate(time.Millisecond)
+	add := func(id, parent, author int64) {
+		require.NoError(t, CreateComment(&models.Comment{
+			CommentID: id, PostID: 9, ParentID: parent, AuthorID: author, CreateTime: now.Add(time.Duration(id) * time.Second),
+		}))
+	}
+	// 顶层评论1到5，2和4下面有回复，5的作者是7
+	for id := int64(1); id <= 4; id++ {
+		add(id, 0, 1)
+	}
+	add(5, 0, 7)
+	add(21, 2, 1)
+	add(22, 21, 1)
+	add(41, 4, 1)
+	// 其他帖子的评论不受影响
+	require.NoError(t, CreateComment(&models.Comment{CommentID: 100, PostID: 8, CreateTime: now}))
+
+	total, err := CountTopLevelComments(9, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+	total, err = CountTopLevelComments(9, []int64{7})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), total)
+
+	ids, err := GetTopLevelCommentIDs(9, nil, 2, 2, false)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{3, 4}, ids)
+	ids, err = GetTopLevelCommentIDs(9, []int64{7}, 0, 2, true)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{4, 3}, ids)
+
+	tree, err := GetCommentTrees(9, []int64{4, 2, 999}, 5)
+	require.NoError(t, err)
+	require.Len(t, tree, 2)
+	assert.Equal(t, int64(4), tree[0].CommentID)
+	assert.Equal(t, 1, tree[0].ReplyCount)
+	assert.Equal(t, int64(2), tree[1].CommentID)
+	assert.Equal(t, 2, tree[1].ReplyCount)
+	assert.Equal(t, int64(22), tree[1].Children[0].Children[0].CommentID)
+
+	tree, err = GetCommentTrees(9, nil, 5)
+	require.NoError(t, err)
+	assert.Empty(t, tree)
+}
+
+func TestTextSearchQuery(t *testing.T) {
+	assert.Equal(t, "exam schedule", textSearchQuery(`"exam schedule"`))
+	assert.Equal(t, "exam final", textSearchQuery("exam -final"))
+	assert.Equal(t, "", textSearchQuery(` "" -- `))
+}
+
+func TestSearchComments(t *testing.T) {
+	useMongo(t)
+	now := time.Now()
+	for _, c := range []*models.Comment{
+		{CommentID: 1, PostID: 1, Content: "final exam schedule", CreateTime: now},
+		{CommentID: 2, PostID: 1, Content: "exam room", CreateTime: now},
+		{CommentID: 3, PostID: 1, Content: "exam deleted", Deleted: true, CreateTime: now},
+		{CommentID: 4, PostID: 1, Content: "dining hall", CreateTime: now},
+	} {
+		require.NoError(t, CreateComment(c))
+	}
+	comments, err := SearchComments(context.Background(), "exam schedule", 0, 10)
+	require.NoError(t, err)
+	require.Len(t, comments, 2)
+	// 按相关度排序，已删除的评论不返回
+	assert.EqualValues(t, 1, comments[0].CommentID)
+	assert.EqualValues(t, 2, comments[1].CommentID)
+
+	// 只有排除词时不查询
+	comments, err = SearchComments(context.Background(), `-""`, 0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, comments)
+}
@@ -0,0 +1,8 @@
+package mongodb
+
+import "errors"
+
+var (
+	ErrorCommentNotExist      = errors.New("Comment does not exist")
+	ErrorModQueueItemNotExist = errors.New("Moderation queue item does not exist")
+)
@@ -10,10 +10,12 @@ import (
 )
 
 func GetEventById(eid int64) (event *models.Event, err error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
 	event = new(models.Event)
-	filter := bson.D{{"event_id", eid}}
+	filter := bson.D{{Key: "event_id", Value: eid}}
 	//filter := bson.M{"event_id": eid}
-	err = client.Database("bluebell").Collection("event").FindOne(context.TODO(), filter).Decode(&event)
+	err = collection(CollectionEvent).FindOne(ctx, filter).Decode(&event)
 	if err != nil {
 		zap.L().Error("No event id matched in mongodb", zap.Error(err))
 		log.Fatal(err)
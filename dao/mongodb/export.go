@@ -0,0 +1,87 @@
+package mongodb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// exportMetadata 导出文件的元数据，过期的文件由CleanExpiredExportFiles删除
+type exportMetadata struct {
+	UserID     int64     `bson:"user_id"`
+	ExpireTime time.Time `bson:"expire_time"`
+}
+
+func exportBucket() (*gridfs.Bucket, error) {
+	return gridfs.NewBucket(client.Database(dbName), options.GridFSBucket().SetName(BucketExport))
+}
+
+func exportFileName(token string) string {
+	return token + ".zip"
+}
+
+// SaveExportFile 把导出的压缩包保存到GridFS，多个实例都可以下载
+func SaveExportFile(token string, userID int64, expire time.Time, data []byte) error {
+	bucket, err := exportBucket()
+	if err != nil {
+		return err
+	}
+	if queryTimeout > 0 {
+		if err = bucket.SetWriteDeadline(time.Now().Add(queryTimeout)); err != nil {
+			return err
+		}
+	}
+	opts := options.GridFSUpload().SetMetadata(&exportMetadata{UserID: userID, ExpireTime: expire})
+	_, err = bucket.UploadFromStream(exportFileName(token), bytes.NewReader(data), opts)
+	return err
+}
+
+// OpenExportFile 打开导出的压缩包，返回文件的大小，文件不存在或已经被清理时返回nil
+func OpenExportFile(token string) (*gridfs.DownloadStream, int64, error) {
+	bucket, err := exportBucket()
+	if err != nil {
+		return nil, 0, err
+	}
+	stream, err := bucket.OpenDownloadStreamByName(exportFileName(token))
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return stream, stream.GetFile().Length, nil
+}
+
+// CleanExpiredExportFiles 删除过期的导出文件和文件的分块，返回删除的文件数
+func CleanExpiredExportFiles(ctx context.Context, now time.Time) (n int, err error) {
+	bucket, err := exportBucket()
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	cursor, err := bucket.Find(bson.D{{Key: "metadata.expire_time", Value: bson.D{{Key: "$lte", Value: now}}}})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		if err = ctx.Err(); err != nil {
+			return n, err
+		}
+		var file gridfs.File
+		if err = cursor.Decode(&file); err != nil {
+			return n, err
+		}
+		if err = bucket.Delete(file.ID); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+			return n, err
+		}
+		n++
+	}
+	return n, cursor.Err()
+}
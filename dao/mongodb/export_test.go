@@ -0,0 +1,43 @@
+package mongodb
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportFile(t *testing.T) {
+	useMongo(t)
+	now := time.Now()
+	require.NoError(t, SaveExportFile("fresh", 1, now.Add(time.Hour), []byte("zip data")))
+	require.NoError(t, SaveExportFile("stale", 1, now.Add(-time.Minute), []byte("old")))
+
+	stream, size, err := OpenExportFile("fresh")
+	require.NoError(t, err)
+	require.NotNil(t, stream)
+	b, err := ioutil.ReadAll(stream)
+	require.NoError(t, err)
+	require.NoError(t, stream.Close())
+	assert.Equal(t, "zip data", string(b))
+	assert.Equal(t, int64(len(b)), size)
+
+	// 只删除过期的文件，删除之后打开返回nil
+	n, err := CleanExpiredExportFiles(context.Background(), now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	stream, _, err = OpenExportFile("stale")
+	require.NoError(t, err)
+	assert.Nil(t, stream)
+	stream, _, err = OpenExportFile("fresh")
+	require.NoError(t, err)
+	require.NotNil(t, stream)
+	require.NoError(t, stream.Close())
+
+	stream, _, err = OpenExportFile("missing")
+	require.NoError(t, err)
+	assert.Nil(t, stream)
+}
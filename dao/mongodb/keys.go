@@ -0,0 +1,15 @@
+package mongodb
+
+const (
+	CollectionEvent        = "event"
+	CollectionComment      = "comment"
+	CollectionPostRevision = "post_revision"
+	CollectionNotification = "notification"
+	CollectionReport       = "report"
+	CollectionAudit        = "audit"
+	CollectionPostRender   = "post_render"
+	CollectionPoll         = "poll"
+	CollectionModQueue     = "mod_queue"
+
+	BucketExport = "export" // 用户导出的数据，GridFS的集合为export.files和export.chunks
+)
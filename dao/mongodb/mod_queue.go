@@ -0,0 +1,172 @@
+package mongodb
+
+import (
+	"context"
+	"go-web-app/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// modQueueClaimable 还没有被认领，或者已经被uid认领的条目
+func modQueueClaimable(uid int64) bson.E {
+	return bson.E{Key: "$or", Value: bson.A{
+		bson.D{{Key: "status", Value: models.ModQueueStatusOpen}},
+		bson.D{{Key: "status", Value: models.ModQueueStatusAssigned}, {Key: "assignee_id", Value: uid}},
+	}}
+}
+
+// EnqueueModQueueItem 同一个目标同一种来源已经有未处理的条目时只更新原因和次数，不改变认领状态
+func EnqueueModQueueItem(item *models.ModQueueItem) error {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	filter := bson.D{
+		{Key: "type", Value: item.Type},
+		{Key: "post_id", Value: item.PostID},
+		{Key: "comment_id", Value: item.CommentID},
+		{Key: "status", Value: bson.D{{Key: "$ne", Value: models.ModQueueStatusResolved}}},
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "community_id", Value: item.CommunityID},
+			{Key: "reason", Value: item.Reason},
+			{Key: "detail", Value: item.Detail},
+			{Key: "update_time", Value: item.UpdateTime},
+		}},
+		{Key: "$setOnInsert", Value: bson.D{
+			{Key: "item_id", Value: item.ItemID},
+			{Key: "status", Value: models.ModQueueStatusOpen},
+			{Key: "create_time", Value: item.CreateTime},
+		}},
+		{Key: "$inc", Value: bson.D{{Key: "count", Value: 1}}},
+	}
+	_, err := collection(CollectionModQueue).UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func GetModQueueItem(itemID int64) (*models.ModQueueItem, error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	item := new(models.ModQueueItem)
+	err := collection(CollectionModQueue).FindOne(ctx, bson.D{{Key: "item_id", Value: itemID}}).Decode(item)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrorModQueueItemNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// GetModQueueItems 按状态查询审核队列，未处理的条目先进先出，已处理的条目新的在前
+// communityIDs为nil时返回所有社区的条目，assigneeID不为0时只返回他认领或处理的条目
+func GetModQueueItems(communityIDs []int64, status string, assigneeID, skip, limit int64) (list []*models.ModQueueItem, err error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	filter := bson.D{{Key: "status", Value: status}}
+	if communityIDs != nil {
+		filter = append(filter, bson.E{Key: "community_id", Value: bson.D{{Key: "$in", Value: communityIDs}}})
+	}
+	if assigneeID != 0 {
+		filter = append(filter, bson.E{Key: "assignee_id", Value: assigneeID})
+	}
+	order := 1
+	if status == models.ModQueueStatusResolved {
+		order = -1
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "create_time", Value: order}}).
+		SetSkip(skip).
+		SetLimit(limit)
+	cur, err := collection(CollectionModQueue).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	list = make([]*models.ModQueueItem, 0, limit)
+	err = cur.All(ctx, &list)
+	return
+}
+
+// ClaimModQueueItem 把未认领的条目分配给uid，条目已经被其他人认领或者已经处理时返回false
+func ClaimModQueueItem(itemID, uid int64) (bool, error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	now := time.Now()
+	filter := bson.D{{Key: "item_id", Value: itemID}, {Key: "status", Value: models.ModQueueStatusOpen}}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: models.ModQueueStatusAssigned},
+		{Key: "assignee_id", Value: uid},
+		{Key: "assign_time", Value: now},
+		{Key: "update_time", Value: now},
+	}}}
+	ret, err := collection(CollectionModQueue).UpdateOne(ctx, filter, update)
+	if err != nil || ret.MatchedCount > 0 {
+		return err == nil, err
+	}
+	// 重复认领自己已经认领的条目
+	filter = bson.D{
+		{Key: "item_id", Value: itemID},
+		{Key: "status", Value: models.ModQueueStatusAssigned},
+		{Key: "assignee_id", Value: uid},
+	}
+	n, err := collection(CollectionModQueue).CountDocuments(ctx, filter)
+	return n > 0, err
+}
+
+func resolveModQueueUpdate(uid int64, action, note string, auditID int64) bson.D {
+	now := time.Now()
+	return bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: models.ModQueueStatusResolved},
+		{Key: "assignee_id", Value: uid},
+		{Key: "action", Value: action},
+		{Key: "note", Value: note},
+		{Key: "audit_id", Value: auditID},
+		{Key: "resolve_time", Value: now},
+		{Key: "update_time", Value: now},
+	}}}
+}
+
+// ResolveModQueueItem 只有认领条目的uid可以处理，条目已经不属于uid时返回false
+func ResolveModQueueItem(itemID, uid int64, action, note string, auditID int64) (bool, error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	filter := bson.D{
+		{Key: "item_id", Value: itemID},
+		{Key: "status", Value: models.ModQueueStatusAssigned},
+		{Key: "assignee_id", Value: uid},
+	}
+	ret, err := collection(CollectionModQueue).UpdateOne(ctx, filter, resolveModQueueUpdate(uid, action, note, auditID))
+	if err != nil {
+		return false, err
+	}
+	return ret.MatchedCount > 0, nil
+}
+
+// ResolveTargetModQueueItems 处理同一个目标其他来源的条目，其他版主已经认领的条目不处理
+func ResolveTargetModQueueItems(pid, cid, uid int64, action, note string, auditID int64) (err error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	filter := bson.D{
+		{Key: "post_id", Value: pid},
+		{Key: "comment_id", Value: cid},
+		modQueueClaimable(uid),
+	}
+	_, err = collection(CollectionModQueue).UpdateMany(ctx, filter, resolveModQueueUpdate(uid, action, note, auditID))
+	return
+}
+
+// HasOtherModQueueAssignee 目标是否有条目正在被uid之外的版主处理
+func HasOtherModQueueAssignee(pid, cid, uid int64) (bool, error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	filter := bson.D{
+		{Key: "post_id", Value: pid},
+		{Key: "comment_id", Value: cid},
+		{Key: "status", Value: models.ModQueueStatusAssigned},
+		{Key: "assignee_id", Value: bson.D{{Key: "$ne", Value: uid}}},
+	}
+	n, err := collection(CollectionModQueue).CountDocuments(ctx, filter)
+	return n > 0, err
+}
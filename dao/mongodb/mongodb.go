@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"go-web-app/logger"
 	"go-web-app/models"
+	"go-web-app/pkg/slowlog"
+	"go-web-app/pkg/tracing"
 	"go-web-app/settings"
 	"log"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -21,7 +24,15 @@ type Trainer struct {
 	City string
 }
 
-var client *mongo.Client
+var (
+	client *mongo.Client
+	dbName = "bluebell"
+)
+
+// collection 返回配置的数据库中的集合
+func collection(name string) *mongo.Collection {
+	return client.Database(dbName).Collection(name)
+}
 
 func Init(cfg *settings.MongodbConfig) (err error) {
 
@@ -32,7 +43,7 @@ func Init(cfg *settings.MongodbConfig) (err error) {
 	cred.Password = cfg.Password
 
 	// set client options
-	clientOptions := options.Client().ApplyURI(cfg.Host).SetAuth(cred)
+	clientOptions := options.Client().ApplyURI(cfg.Host).SetAuth(cred).SetMonitor(chainMonitors(slowlog.CommandMonitor(), tracing.CommandMonitor()))
 
 	// Connect to MongoDB
 	client, err = mongo.Connect(context.TODO(), clientOptions)
@@ -47,6 +58,17 @@ func Init(cfg *settings.MongodbConfig) (err error) {
 
 	if err != nil {
 		zap.L().Error("ping mongodb error", zap.Error(err))
+		// 启动时会重试，断开这次创建的连接
+		_ = client.Disconnect(context.Background())
+		return
+	}
+
+	if cfg.DB != "" {
+		dbName = cfg.DB
+	}
+	queryTimeout = time.Duration(cfg.QueryTimeout) * time.Millisecond
+	if err = ensureIndexes(); err != nil {
+		zap.L().Error("create mongodb indexes error", zap.Error(err))
 		return
 	}
 
@@ -54,6 +76,80 @@ func Init(cfg *settings.MongodbConfig) (err error) {
 	return
 }
 
+func ensureIndexes() error {
+	_, err := collection(CollectionComment).Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "comment_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "post_id", Value: 1}, {Key: "create_time", Value: 1}}},
+		// 顶层评论分页和逐层读取回复
+		{Keys: bson.D{{Key: "post_id", Value: 1}, {Key: "parent_id", Value: 1}, {Key: "create_time", Value: 1}}},
+		{Keys: bson.D{{Key: "author_id", Value: 1}, {Key: "deleted", Value: 1}}},
+		{Keys: bson.D{{Key: "content", Value: "text"}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = collection(CollectionPostRevision).Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.D{{Key: "post_id", Value: 1}, {Key: "edit_time", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = collection(CollectionNotification).Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "notification_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "recipient_id", Value: 1}, {Key: "read", Value: 1}, {Key: "create_time", Value: -1}}},
+		// 按类型过滤的通知列表
+		{Keys: bson.D{{Key: "recipient_id", Value: 1}, {Key: "type", Value: 1}, {Key: "read", Value: 1}, {Key: "create_time", Value: -1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = collection(CollectionReport).Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "post_id", Value: 1}, {Key: "reporter_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "community_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = collection(CollectionAudit).Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "create_time", Value: -1}}},
+		{Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "create_time", Value: -1}}},
+		{Keys: bson.D{{Key: "action", Value: 1}, {Key: "create_time", Value: -1}}},
+		{Keys: bson.D{{Key: "audit_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = collection(CollectionModQueue).Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "item_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "community_id", Value: 1}, {Key: "create_time", Value: 1}}},
+		{Keys: bson.D{{Key: "post_id", Value: 1}, {Key: "type", Value: 1}, {Key: "status", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = collection(CollectionPostRender).Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.D{{Key: "post_id", Value: 1}}, Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = collection(CollectionPoll).Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.D{{Key: "post_id", Value: 1}}, Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = collection(BucketExport+".files").Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.D{{Key: "metadata.expire_time", Value: 1}},
+	})
+	return err
+}
+
+// Ping 检查mongodb连接是否可用
+func Ping(ctx context.Context) error {
+	return client.Ping(ctx, nil)
+}
+
 func Close() (err error) {
 	err = client.Disconnect(context.TODO())
 
@@ -70,13 +166,13 @@ func main() {
 		fmt.Printf("Init settings failed, err:%v\n", err)
 		return
 	}
-	if err := logger.Init(settings.Conf.LogConfig, settings.Conf.Mode); err != nil {
+	if err := logger.Init(settings.Current().LogConfig, settings.Current().Mode); err != nil {
 		fmt.Printf("Init logger failed, err:%v\n", err)
 		return
 	}
 	defer zap.L().Sync()
 	zap.L().Debug("logger init success")
-	if err := Init(settings.Conf.MongodbConfig); err != nil {
+	if err := Init(settings.Current().MongodbConfig); err != nil {
 		return
 	}
 	// ****************************
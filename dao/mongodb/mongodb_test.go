@@ -0,0 +1,27 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"go-web-app/settings"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// useMongo 连接环境变量MONGODB_TEST_URI指定的MongoDB，使用一个临时的数据库，测试结束后删除
+// 没有设置时跳过测试
+func useMongo(t *testing.T) {
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI not set")
+	}
+	db := fmt.Sprintf("bluebell_test_%d", time.Now().UnixNano())
+	require.NoError(t, Init(&settings.MongodbConfig{Host: uri, DB: db}))
+	t.Cleanup(func() {
+		_ = client.Database(db).Drop(context.Background())
+		_ = client.Disconnect(context.Background())
+	})
+}
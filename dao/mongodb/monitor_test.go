@@ -0,0 +1,26 @@
+package mongodb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+func TestChainMonitors(t *testing.T) {
+	var calls []string
+	first := &event.CommandMonitor{
+		Started: func(context.Context, *event.CommandStartedEvent) { calls = append(calls, "first started") },
+		Failed:  func(context.Context, *event.CommandFailedEvent) { calls = append(calls, "first failed") },
+	}
+	second := &event.CommandMonitor{
+		Started:   func(context.Context, *event.CommandStartedEvent) { calls = append(calls, "second started") },
+		Succeeded: func(context.Context, *event.CommandSucceededEvent) { calls = append(calls, "second succeeded") },
+	}
+	m := chainMonitors(first, second)
+	m.Started(context.Background(), &event.CommandStartedEvent{})
+	m.Succeeded(context.Background(), &event.CommandSucceededEvent{})
+	m.Failed(context.Background(), &event.CommandFailedEvent{})
+	assert.Equal(t, []string{"first started", "second started", "second succeeded", "first failed"}, calls)
+}
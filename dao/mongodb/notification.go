@@ -0,0 +1,128 @@
+package mongodb
+
+import (
+	"context"
+	"go-web-app/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func CreateNotification(n *models.Notification) (err error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	_, err = collection(CollectionNotification).InsertOne(ctx, n)
+	return
+}
+
+// CreateNotificationOnce 同一个人对同一个目标的同类通知只保存一条，
+// 例如反复点赞或反复关注，已经存在时返回created=false
+func CreateNotificationOnce(n *models.Notification) (created bool, err error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	filter := bson.D{
+		{Key: "recipient_id", Value: n.RecipientID},
+		{Key: "type", Value: n.Type},
+		{Key: "actor_id", Value: n.ActorID},
+		{Key: "target_id", Value: n.TargetID},
+	}
+	update := bson.D{{Key: "$setOnInsert", Value: n}}
+	ret, err := collection(CollectionNotification).UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+	return ret.UpsertedCount > 0, nil
+}
+
+// notificationFilter types为空时不按类型过滤
+func notificationFilter(uid int64, types []string) bson.D {
+	filter := bson.D{{Key: "recipient_id", Value: uid}}
+	if len(types) > 0 {
+		filter = append(filter, bson.E{Key: "type", Value: bson.D{{Key: "$in", Value: types}}})
+	}
+	return filter
+}
+
+// GetNotifications 未读的在前，同样状态的按时间倒序，types为空时返回所有类型
+func GetNotifications(uid int64, types []string, page, size int64) (list []*models.Notification, err error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	filter := notificationFilter(uid, types)
+	opts := options.Find().
+		SetSort(bson.D{{Key: "read", Value: 1}, {Key: "create_time", Value: -1}}).
+		SetSkip((page - 1) * size).
+		SetLimit(size)
+	cur, err := collection(CollectionNotification).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	list = make([]*models.Notification, 0, size)
+	err = cur.All(ctx, &list)
+	return
+}
+
+func CountNotifications(uid int64, types []string) (int64, error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	return collection(CollectionNotification).CountDocuments(ctx, notificationFilter(uid, types))
+}
+
+func CountUnreadNotifications(uid int64) (int64, error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	filter := bson.D{{Key: "recipient_id", Value: uid}, {Key: "read", Value: false}}
+	return collection(CollectionNotification).CountDocuments(ctx, filter)
+}
+
+// MarkNotificationsRead 把通知标记为已读，ids为空时标记该用户所有的通知
+func MarkNotificationsRead(uid int64, ids []int64) (err error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	filter := bson.D{{Key: "recipient_id", Value: uid}, {Key: "read", Value: false}}
+	if len(ids) > 0 {
+		filter = append(filter, bson.E{Key: "notification_id", Value: bson.D{{Key: "$in", Value: ids}}})
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "read", Value: true}}}}
+	_, err = collection(CollectionNotification).UpdateMany(ctx, filter, update)
+	return
+}
+
+// GetUnreadNotificationCounts 统计[since, until)之间创建的未读通知，返回每个用户每种类型的数量
+func GetUnreadNotificationCounts(since, until time.Time) (map[int64]map[string]int64, error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{
+			{Key: "read", Value: false},
+			{Key: "create_time", Value: bson.D{{Key: "$gte", Value: since}, {Key: "$lt", Value: until}}},
+		}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "recipient_id", Value: "$recipient_id"}, {Key: "type", Value: "$type"}}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+	}
+	cur, err := collection(CollectionNotification).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		ID struct {
+			RecipientID int64  `bson:"recipient_id"`
+			Type        string `bson:"type"`
+		} `bson:"_id"`
+		Count int64 `bson:"count"`
+	}
+	if err = cur.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	counts := make(map[int64]map[string]int64)
+	for _, row := range rows {
+		if counts[row.ID.RecipientID] == nil {
+			counts[row.ID.RecipientID] = make(map[string]int64)
+		}
+		counts[row.ID.RecipientID][row.ID.Type] = row.Count
+	}
+	return counts, nil
+}
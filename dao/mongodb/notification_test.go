@@ -0,0 +1,54 @@
+package mongodb
+
+import (
+	"go-web-app/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestNotificationFilter(t *testing.T) {
+	assert.Equal(t, bson.D{{Key: "recipient_id", Value: int64(7)}}, notificationFilter(7, nil))
+	assert.Equal(t, bson.D{
+		{Key: "recipient_id", Value: int64(7)},
+		{Key: "type", Value: bson.D{{Key: "$in", Value: []string{models.NotificationReply}}}},
+	}, notificationFilter(7, []string{models.NotificationReply}))
+}
+
+func TestGetNotificationsByType(t *testing.T) {
+	useMongo(t)
+	now := time.Now().Truncate(time.Millisecond)
+	for i, typ := range []string{models.NotificationReply, models.NotificationVote, models.NotificationReply} {
+		require.NoError(t, CreateNotification(&models.Notification{
+			NotificationID: int64(i + 1),
+			RecipientID:    7,
+			Type:           typ,
+			CreateTime:     now.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+	require.NoError(t, CreateNotification(&models.Notification{NotificationID: 4, RecipientID: 8, Type: models.NotificationReply, CreateTime: now}))
+
+	list, err := GetNotifications(7, []string{models.NotificationReply}, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.EqualValues(t, 3, list[0].NotificationID)
+	assert.EqualValues(t, 1, list[1].NotificationID)
+	count, err := CountNotifications(7, []string{models.NotificationReply})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, count)
+	count, err = CountNotifications(7, nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, count)
+
+	// ids为空时标记所有通知，不影响其他用户
+	require.NoError(t, MarkNotificationsRead(7, nil))
+	unread, err := CountUnreadNotifications(7)
+	require.NoError(t, err)
+	assert.Zero(t, unread)
+	unread, err = CountUnreadNotifications(8)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, unread)
+}
@@ -0,0 +1,33 @@
+package mongodb
+
+import (
+	"context"
+	"go-web-app/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// CreatePoll 保存帖子附带的投票
+func CreatePoll(p *models.Poll) (err error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	_, err = collection(CollectionPoll).InsertOne(ctx, p)
+	return
+}
+
+// GetPoll 帖子没有投票时返回nil
+func GetPoll(pid int64) (*models.Poll, error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	p := new(models.Poll)
+	filter := bson.D{{Key: "post_id", Value: pid}}
+	err := collection(CollectionPoll).FindOne(ctx, filter).Decode(p)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
@@ -0,0 +1,129 @@
+package mongodb
+
+import (
+	"context"
+	"go-web-app/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AddPostRevision 保存帖子的一个历史版本，每个帖子最多保留maxRevisions个版本
+func AddPostRevision(revision *models.PostRevision, maxRevisions int) (err error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	coll := collection(CollectionPostRevision)
+	if _, err = coll.InsertOne(ctx, revision); err != nil {
+		return
+	}
+	_, err = trimPostRevisions(revision.PostID, maxRevisions)
+	return
+}
+
+// trimPostRevisions 删除超出上限的最旧版本，返回删除的个数，maxRevisions<=0表示不限制
+func trimPostRevisions(pid int64, maxRevisions int) (int64, error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	if maxRevisions <= 0 {
+		return 0, nil
+	}
+	coll := collection(CollectionPostRevision)
+	// 找出超出上限的最旧版本并删除
+	filter := bson.D{{Key: "post_id", Value: pid}}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "edit_time", Value: -1}}).
+		SetSkip(int64(maxRevisions)).
+		SetProjection(bson.D{{Key: "_id", Value: 1}})
+	cur, err := coll.Find(ctx, filter, opts)
+	if err != nil {
+		return 0, err
+	}
+	var stale []bson.M
+	if err = cur.All(ctx, &stale); err != nil || len(stale) == 0 {
+		return 0, err
+	}
+	ids := make(bson.A, 0, len(stale))
+	for _, doc := range stale {
+		ids = append(ids, doc["_id"])
+	}
+	ret, err := coll.DeleteMany(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}})
+	if err != nil {
+		return 0, err
+	}
+	return ret.DeletedCount, nil
+}
+
+// GetPostRevisions 返回帖子的历史版本，最新的在前
+func GetPostRevisions(pid int64) (revisions []*models.PostRevision, err error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	filter := bson.D{{Key: "post_id", Value: pid}}
+	opts := options.Find().SetSort(bson.D{{Key: "edit_time", Value: -1}})
+	cur, err := collection(CollectionPostRevision).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	revisions = make([]*models.PostRevision, 0)
+	err = cur.All(ctx, &revisions)
+	return
+}
+
+// SavePostRender 保存帖子的渲染结果，编辑后覆盖旧的结果
+func SavePostRender(r *models.PostRender) (err error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	filter := bson.D{{Key: "post_id", Value: r.PostID}}
+	_, err = collection(CollectionPostRender).ReplaceOne(ctx, filter, r, options.Replace().SetUpsert(true))
+	return
+}
+
+// GetPostRender 没有渲染结果时返回nil
+func GetPostRender(pid int64) (*models.PostRender, error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	r := new(models.PostRender)
+	filter := bson.D{{Key: "post_id", Value: pid}}
+	err := collection(CollectionPostRender).FindOne(ctx, filter).Decode(r)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetPostRenderSummaries 批量查询帖子的摘要和预览，不包含HTML，没有渲染结果的帖子不在结果中
+func GetPostRenderSummaries(ctx context.Context, pids []int64) (map[int64]*models.PostRender, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	renders := make(map[int64]*models.PostRender, len(pids))
+	if len(pids) == 0 {
+		return renders, nil
+	}
+	filter := bson.D{{Key: "post_id", Value: bson.D{{Key: "$in", Value: pids}}}}
+	opts := options.Find().SetProjection(bson.D{{Key: "html", Value: 0}})
+	cur, err := collection(CollectionPostRender).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var list []*models.PostRender
+	if err = cur.All(ctx, &list); err != nil {
+		return nil, err
+	}
+	for _, r := range list {
+		renders[r.PostID] = r
+	}
+	return renders, nil
+}
+
+// SetLinkPreview 补充链接预览的标题和图片，帖子的预览已经换成其他链接时不更新
+func SetLinkPreview(pid int64, preview *models.PostPreview) (err error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	filter := bson.D{{Key: "post_id", Value: pid}, {Key: "preview.url", Value: preview.URL}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "preview", Value: preview}}}}
+	_, err = collection(CollectionPostRender).UpdateOne(ctx, filter, update)
+	return
+}
@@ -0,0 +1,74 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// PurgePostData 删除已彻底删除的帖子的评论、历史版本和渲染结果，返回删除的评论数
+func PurgePostData(pids []int64) (comments int64, err error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	if len(pids) == 0 {
+		return 0, nil
+	}
+	filter := bson.D{{Key: "post_id", Value: bson.D{{Key: "$in", Value: pids}}}}
+	ret, err := collection(CollectionComment).DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	for _, name := range []string{CollectionPostRevision, CollectionPostRender} {
+		if _, err = collection(name).DeleteMany(ctx, filter); err != nil {
+			return ret.DeletedCount, err
+		}
+	}
+	return ret.DeletedCount, nil
+}
+
+// TrimPostRevisions 删除所有帖子超出上限的历史版本，用于调小max_revisions之后清理旧数据
+func TrimPostRevisions(maxRevisions int) (n int64, err error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	if maxRevisions <= 0 {
+		return 0, nil
+	}
+	pipeline := bson.A{
+		bson.D{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$post_id"}, {Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}}}}},
+		bson.D{{Key: "$match", Value: bson.D{{Key: "count", Value: bson.D{{Key: "$gt", Value: maxRevisions}}}}}},
+	}
+	cur, err := collection(CollectionPostRevision).Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, err
+	}
+	var groups []struct {
+		PostID int64 `bson:"_id"`
+	}
+	if err = cur.All(ctx, &groups); err != nil {
+		return 0, err
+	}
+	for _, g := range groups {
+		deleted, err := trimPostRevisions(g.PostID, maxRevisions)
+		if err != nil {
+			return n, err
+		}
+		n += deleted
+	}
+	return n, nil
+}
+
+// PurgeReadNotifications 删除before之前创建的已读通知，未读的通知一直保留
+func PurgeReadNotifications(before time.Time) (int64, error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	filter := bson.D{
+		{Key: "read", Value: true},
+		{Key: "create_time", Value: bson.D{{Key: "$lt", Value: before}}},
+	}
+	ret, err := collection(CollectionNotification).DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return ret.DeletedCount, nil
+}
@@ -0,0 +1,112 @@
+package mongodb
+
+import (
+	"context"
+	"go-web-app/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CreateReport 同一个用户对同一个帖子只能举报一次，已经举报过时返回created=false
+func CreateReport(r *models.Report) (created bool, err error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	filter := bson.D{
+		{Key: "post_id", Value: r.PostID},
+		{Key: "reporter_id", Value: r.ReporterID},
+	}
+	update := bson.D{{Key: "$setOnInsert", Value: r}}
+	ret, err := collection(CollectionReport).UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+	return ret.UpsertedCount > 0, nil
+}
+
+// UpsertSystemReport 系统检测到的问题，每个帖子只保留一条，已经处理过的举报重新变为待处理
+func UpsertSystemReport(r *models.Report) error {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	filter := bson.D{
+		{Key: "post_id", Value: r.PostID},
+		{Key: "reporter_id", Value: r.ReporterID},
+	}
+	set := bson.D{
+		{Key: "comment_id", Value: r.CommentID},
+		{Key: "community_id", Value: r.CommunityID},
+		{Key: "reason", Value: r.Reason},
+		{Key: "detail", Value: r.Detail},
+		{Key: "status", Value: models.ReportStatusOpen},
+		{Key: "create_time", Value: r.CreateTime},
+	}
+	unset := bson.D{{Key: "handler_id", Value: ""}, {Key: "handle_time", Value: ""}}
+	// 之前的系统举报记录的域名不保留
+	if r.Domain != "" {
+		set = append(set, bson.E{Key: "domain", Value: r.Domain})
+	} else {
+		unset = append(unset, bson.E{Key: "domain", Value: ""})
+	}
+	update := bson.D{
+		{Key: "$set", Value: set},
+		{Key: "$setOnInsert", Value: bson.D{{Key: "report_id", Value: r.ReportID}}},
+		{Key: "$unset", Value: unset},
+	}
+	_, err := collection(CollectionReport).UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// CountOpenReports 帖子待处理的举报数
+func CountOpenReports(pid int64) (int64, error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	filter := bson.D{{Key: "post_id", Value: pid}, {Key: "status", Value: models.ReportStatusOpen}}
+	return collection(CollectionReport).CountDocuments(ctx, filter)
+}
+
+// GetOpenReportSummaries 按帖子汇总待处理的举报，举报多的在前
+// communityIDs为nil时返回所有社区的举报
+func GetOpenReportSummaries(communityIDs []int64, page, size int64) (list []*models.ReportSummary, err error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	match := bson.D{{Key: "status", Value: models.ReportStatusOpen}}
+	if communityIDs != nil {
+		match = append(match, bson.E{Key: "community_id", Value: bson.D{{Key: "$in", Value: communityIDs}}})
+	}
+	pipeline := []bson.D{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$post_id"},
+			{Key: "community_id", Value: bson.D{{Key: "$first", Value: "$community_id"}}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+			{Key: "reasons", Value: bson.D{{Key: "$addToSet", Value: "$reason"}}},
+			{Key: "domains", Value: bson.D{{Key: "$addToSet", Value: "$domain"}}},
+			{Key: "last_report_time", Value: bson.D{{Key: "$max", Value: "$create_time"}}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "last_report_time", Value: -1}}}},
+		{{Key: "$skip", Value: (page - 1) * size}},
+		{{Key: "$limit", Value: size}},
+	}
+	cur, err := collection(CollectionReport).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	list = make([]*models.ReportSummary, 0, size)
+	err = cur.All(ctx, &list)
+	return
+}
+
+// ResolveReports 处理帖子所有待处理的举报
+func ResolveReports(pid int64, status string, handlerID int64) (err error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	filter := bson.D{{Key: "post_id", Value: pid}, {Key: "status", Value: models.ReportStatusOpen}}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: status},
+		{Key: "handler_id", Value: handlerID},
+		{Key: "handle_time", Value: time.Now()},
+	}}}
+	_, err = collection(CollectionReport).UpdateMany(ctx, filter, update)
+	return
+}
@@ -0,0 +1,15 @@
+package mongodb
+
+import (
+	"context"
+	"go-web-app/pkg/dbtimeout"
+	"time"
+)
+
+// queryTimeout 每次操作的默认超时，Init时从mongodb.query_timeout读取
+var queryTimeout time.Duration
+
+// withTimeout 返回带默认超时的ctx，可以用dbtimeout.WithTimeout覆盖
+func withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return dbtimeout.Context(ctx, queryTimeout)
+}
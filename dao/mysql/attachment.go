@@ -0,0 +1,77 @@
+package mysql
+
+import (
+	"context"
+	"go-web-app/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const attachmentColumns = "attachment_id, post_id, uploader_id, file_name, content_type, size, stored_name, create_time"
+
+// AddPostAttachment 在一个事务中检查帖子已有的附件并保存新附件
+// check 的参数为帖子已有的附件数和总大小，返回错误时不保存
+func AddPostAttachment(a *models.Attachment, check func(count, size int64) error) error {
+	return WithTx(context.Background(), func(tx *Tx) error {
+		// 锁住帖子，同时上传时不会超过限制
+		var pid int64
+		if err := tx.Get(&pid, "select post_id from post where post_id = ? and deleted_at is null for update", a.PostID); err != nil {
+			return err
+		}
+		var stat struct {
+			Count int64 `db:"count"`
+			Size  int64 `db:"size"`
+		}
+		sqlStr := "select count(*) as count, coalesce(sum(size), 0) as size from post_attachment where post_id = ?"
+		if err := tx.Get(&stat, sqlStr, a.PostID); err != nil {
+			return err
+		}
+		if err := check(stat.Count, stat.Size); err != nil {
+			return err
+		}
+		sqlStr = `insert into post_attachment(attachment_id, post_id, uploader_id, file_name, content_type, size, stored_name)
+		values (?, ?, ?, ?, ?, ?, ?)`
+		_, err := tx.Exec(sqlStr, a.AttachmentID, a.PostID, a.UploaderID, a.FileName, a.ContentType, a.Size, a.StoredName)
+		return err
+	})
+}
+
+// GetPostAttachments 帖子的附件，按上传顺序
+func GetPostAttachments(pid int64) (list []*models.Attachment, err error) {
+	sqlStr := "select " + attachmentColumns + " from post_attachment where post_id = ? order by id"
+	list = make([]*models.Attachment, 0)
+	err = db.Select(&list, sqlStr, pid)
+	return
+}
+
+// GetAttachment 附件不存在时返回sql.ErrNoRows
+func GetAttachment(id int64) (a *models.Attachment, err error) {
+	a = new(models.Attachment)
+	err = db.Get(a, "select "+attachmentColumns+" from post_attachment where attachment_id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// GetOrphanAttachments 帖子已经被彻底删除的附件
+func GetOrphanAttachments(limit int) (list []*models.Attachment, err error) {
+	sqlStr := `select ` + attachmentColumns + ` from post_attachment a
+	where not exists (select 1 from post p where p.post_id = a.post_id) limit ?`
+	list = make([]*models.Attachment, 0, limit)
+	err = db.Select(&list, sqlStr, limit)
+	return
+}
+
+// DeleteAttachments 删除附件的记录，文件由调用方删除
+func DeleteAttachments(ids []int64) (err error) {
+	if len(ids) == 0 {
+		return nil
+	}
+	query, args, err := sqlx.In("delete from post_attachment where attachment_id in (?)", ids)
+	if err != nil {
+		return
+	}
+	_, err = db.Exec(query, args...)
+	return
+}
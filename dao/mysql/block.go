@@ -0,0 +1,20 @@
+package mysql
+
+func Block(blockerID, blockedID int64) (err error) {
+	sqlStr := "insert ignore into block(blocker_id, blocked_id) values (?, ?)"
+	_, err = db.Exec(sqlStr, blockerID, blockedID)
+	return
+}
+
+func Unblock(blockerID, blockedID int64) (err error) {
+	sqlStr := "delete from block where blocker_id = ? and blocked_id = ?"
+	_, err = db.Exec(sqlStr, blockerID, blockedID)
+	return
+}
+
+// GetBlockedIDs 返回uid屏蔽的所有用户id
+func GetBlockedIDs(uid int64) (ids []int64, err error) {
+	sqlStr := "select blocked_id from block where blocker_id = ?"
+	err = db.Select(&ids, sqlStr, uid)
+	return
+}
@@ -0,0 +1,44 @@
+package mysql
+
+import "go-web-app/models"
+
+// SavePost 收藏帖子，重复收藏不会报错
+func SavePost(uid, pid int64) (err error) {
+	sqlStr := "insert ignore into bookmark(user_id, post_id) values (?, ?)"
+	_, err = db.Exec(sqlStr, uid, pid)
+	return
+}
+
+func UnsavePost(uid, pid int64) (err error) {
+	sqlStr := "delete from bookmark where user_id = ? and post_id = ?"
+	_, err = db.Exec(sqlStr, uid, pid)
+	return
+}
+
+// IsPostSaved 用户是否收藏了帖子
+func IsPostSaved(uid, pid int64) (saved bool, err error) {
+	sqlStr := "select count(id) > 0 from bookmark where user_id = ? and post_id = ?"
+	err = db.Get(&saved, sqlStr, uid, pid)
+	return
+}
+
+// GetSavedPosts 用户收藏的帖子，最近收藏的在前，已删除的帖子不返回
+func GetSavedPosts(uid, page, size int64) (posts []*models.Post, err error) {
+	sqlStr := `select p.post_id, p.title, p.content, p.author_id, p.community_id, p.create_time from bookmark b
+	join post p on p.post_id = b.post_id
+	where b.user_id = ? and p.deleted_at is null and p.status = 1
+	order by b.create_time desc, b.id desc
+	limit ?,?`
+	posts = make([]*models.Post, 0, size)
+	err = db.Select(&posts, sqlStr, uid, (page-1)*size, size)
+	return
+}
+
+// GetSavedPostCount 用户收藏的未删除帖子数
+func GetSavedPostCount(uid int64) (count int64, err error) {
+	sqlStr := `select count(b.id) from bookmark b
+	join post p on p.post_id = b.post_id
+	where b.user_id = ? and p.deleted_at is null and p.status = 1`
+	err = db.Get(&count, sqlStr, uid)
+	return
+}
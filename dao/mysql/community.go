@@ -1,14 +1,17 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
 	"go-web-app/models"
 
+	"github.com/jmoiron/sqlx"
+
 	"go.uber.org/zap"
 )
 
 func GetCommunityList() (communityList []*models.Community, err error) {
-	sqlStr := "select community_id, community_name from community"
+	sqlStr := "select community_id, community_name, visibility from community"
 	if err := db.Select(&communityList, sqlStr); err != nil {
 		if err == sql.ErrNoRows {
 			zap.L().Warn("No such community in database")
@@ -20,7 +23,7 @@ func GetCommunityList() (communityList []*models.Community, err error) {
 
 func GetCommunityDetailByID(id int64) (community *models.CommunityDetail, err error) {
 	community = new(models.CommunityDetail)
-	sqlStr := "select community_id, community_name, introduction, create_time from community where community_id = ?"
+	sqlStr := "select community_id, community_name, introduction, visibility, min_post_length, post_permission, allow_anonymous, create_time from community where community_id = ?"
 	if err := db.Get(community, sqlStr, id); err != nil {
 		if err == sql.ErrNoRows {
 			err = ErrorInvalidID
@@ -28,3 +31,159 @@ func GetCommunityDetailByID(id int64) (community *models.CommunityDetail, err er
 	}
 	return community, err
 }
+
+// CheckCommunityNameAvailable 社区名称没有被使用，比较时忽略大小写
+func CheckCommunityNameAvailable(name string) (err error) {
+	var count int
+	if err = db.Get(&count, "select count(*) from community where lower(community_name) = lower(?)", name); err != nil {
+		return
+	}
+	if count > 0 {
+		return ErrorCommunityExist
+	}
+	return
+}
+
+// CreateCommunity 创建社区，社区id为当前最大的id加1，名称已被使用时返回ErrorCommunityExist
+func CreateCommunity(name, introduction, visibility string) (id int64, err error) {
+	if err = CheckCommunityNameAvailable(name); err != nil {
+		return
+	}
+	return insertCommunity(db, name, introduction, visibility)
+}
+
+// insertCommunity 唯一索引保证并发创建时不会出现重复的名称，community_name的排序规则不区分大小写
+func insertCommunity(ext sqlx.Ext, name, introduction, visibility string) (id int64, err error) {
+	sqlStr := `insert into community(community_id, community_name, introduction, visibility)
+	select ifnull(max(community_id), 0) + 1, ?, ?, ? from community`
+	ret, err := ext.Exec(sqlStr, name, introduction, visibility)
+	if err != nil {
+		if isDuplicateEntry(err) {
+			err = ErrorCommunityExist
+		}
+		return
+	}
+	rowID, err := ret.LastInsertId()
+	if err != nil {
+		return
+	}
+	err = sqlx.Get(ext, &id, "select community_id from community where id = ?", rowID)
+	return
+}
+
+// ImportCommunities 在一个事务中创建社区，名称(忽略大小写)已经存在的跳过
+// 返回每个社区的id，created为false表示已经存在，任何一个失败时全部回滚
+func ImportCommunities(list []*models.ParamCreateCommunity) (ids []int64, created []bool, err error) {
+	ids = make([]int64, len(list))
+	created = make([]bool, len(list))
+	err = WithTx(context.Background(), func(tx *Tx) error {
+		for i, p := range list {
+			err := tx.Get(&ids[i], "select community_id from community where lower(community_name) = lower(?)", p.Name)
+			if err == nil {
+				continue
+			}
+			if err != sql.ErrNoRows {
+				return err
+			}
+			if ids[i], err = insertCommunity(tx, p.Name, p.Introduction, p.Visibility); err != nil {
+				return err
+			}
+			created[i] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return
+}
+
+// GetCommunityDetailsByIDs 批量查询社区，key为社区id
+func GetCommunityDetailsByIDs(ctx context.Context, ids []int64) (map[int64]*models.CommunityDetail, error) {
+	communities := make(map[int64]*models.CommunityDetail, len(ids))
+	if len(ids) == 0 {
+		return communities, nil
+	}
+	sqlStr := "select community_id, community_name, introduction, visibility, min_post_length, post_permission, allow_anonymous, create_time from community where community_id in (?)"
+	query, args, err := sqlx.In(sqlStr, ids)
+	if err != nil {
+		return nil, err
+	}
+	var list []*models.CommunityDetail
+	if err = db.SelectContext(ctx, &list, db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	for _, community := range list {
+		communities[community.ID] = community
+	}
+	return communities, nil
+}
+
+// JoinCommunity 加入社区，已经加入时返回changed=false
+// afterInsert 在事务提交前执行，返回错误时回滚
+func JoinCommunity(userID, communityID int64, afterInsert func() error) (changed bool, err error) {
+	return execRelationTx("insert ignore into community_member(user_id, community_id) values (?, ?)",
+		userID, communityID, afterInsert)
+}
+
+// LeaveCommunity 退出社区，未加入时返回changed=false
+// afterDelete 在事务提交前执行，返回错误时回滚
+func LeaveCommunity(userID, communityID int64, afterDelete func() error) (changed bool, err error) {
+	return execRelationTx("delete from community_member where user_id = ? and community_id = ?",
+		userID, communityID, afterDelete)
+}
+
+// GetJoinedCommunityIDs 返回用户加入的所有社区id
+func GetJoinedCommunityIDs(userID int64) (ids []int64, err error) {
+	sqlStr := "select community_id from community_member where user_id = ?"
+	err = db.Select(&ids, sqlStr, userID)
+	return
+}
+
+// GetCommunityMemberCount 直接从数据库统计社区成员数，用于重建redis中的计数
+func GetCommunityMemberCount(communityID int64) (count int64, err error) {
+	err = db.Get(&count, "select count(*) from community_member where community_id = ?", communityID)
+	return
+}
+
+// GetCommunityRole 返回用户在社区中的角色，未加入时返回models.CommunityRoleNone
+func GetCommunityRole(userID, communityID int64) (role int8, err error) {
+	sqlStr := "select role from community_member where user_id = ? and community_id = ?"
+	err = db.Get(&role, sqlStr, userID, communityID)
+	if err == sql.ErrNoRows {
+		return models.CommunityRoleNone, nil
+	}
+	return
+}
+
+// CountCommunityAdmins 社区管理员的人数
+func CountCommunityAdmins(communityID int64) (count int64, err error) {
+	sqlStr := "select count(*) from community_member where community_id = ? and role = ?"
+	err = db.Get(&count, sqlStr, communityID, models.CommunityRoleAdmin)
+	return
+}
+
+// GetModeratedCommunityIDs 用户担任版主或管理员的社区
+func GetModeratedCommunityIDs(userID int64) (ids []int64, err error) {
+	sqlStr := "select community_id from community_member where user_id = ? and role >= ?"
+	ids = make([]int64, 0)
+	err = db.Select(&ids, sqlStr, userID, models.CommunityRoleModerator)
+	return
+}
+
+// SetCommunityRole 修改社区成员的角色，用户未加入社区时返回ErrorNotMember
+func SetCommunityRole(userID, communityID int64, role int8) (err error) {
+	if _, err = GetCommunityDetailByID(communityID); err != nil {
+		return
+	}
+	current, err := GetCommunityRole(userID, communityID)
+	if err != nil {
+		return
+	}
+	if current == models.CommunityRoleNone {
+		return ErrorNotMember
+	}
+	sqlStr := "update community_member set role = ? where user_id = ? and community_id = ?"
+	_, err = db.Exec(sqlStr, role, userID, communityID)
+	return
+}
@@ -0,0 +1,195 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"go-web-app/models"
+)
+
+// publicPostFilter 排除私有社区的帖子，私有社区的帖子不能同时发布到其他社区，所以只需要检查主社区
+const publicPostFilter = "community_id not in (select community_id from community where visibility = 'private')"
+
+// SetCommunityVisibility 修改社区的可见性
+func SetCommunityVisibility(communityID int64, visibility string) (err error) {
+	ret, err := db.Exec("update community set visibility = ? where community_id = ?", visibility, communityID)
+	if err != nil {
+		return
+	}
+	n, err := ret.RowsAffected()
+	if err != nil {
+		return
+	}
+	if n == 0 {
+		// 可见性没有变化时也不会更新，再确认一下社区是否存在
+		_, err = GetCommunityDetailByID(communityID)
+	}
+	return
+}
+
+// SetCommunityMinPostLength 修改社区中帖子内容的最少字符数
+func SetCommunityMinPostLength(communityID int64, length int) (err error) {
+	ret, err := db.Exec("update community set min_post_length = ? where community_id = ?", length, communityID)
+	if err != nil {
+		return
+	}
+	n, err := ret.RowsAffected()
+	if err != nil {
+		return
+	}
+	if n == 0 {
+		_, err = GetCommunityDetailByID(communityID)
+	}
+	return
+}
+
+// SetCommunityPostPermission 修改社区中谁可以发帖
+func SetCommunityPostPermission(communityID int64, permission string) (err error) {
+	ret, err := db.Exec("update community set post_permission = ? where community_id = ?", permission, communityID)
+	if err != nil {
+		return
+	}
+	n, err := ret.RowsAffected()
+	if err != nil {
+		return
+	}
+	if n == 0 {
+		_, err = GetCommunityDetailByID(communityID)
+	}
+	return
+}
+
+// SetCommunityAllowAnonymous 修改社区是否允许匿名发帖
+func SetCommunityAllowAnonymous(communityID int64, allow bool) (err error) {
+	ret, err := db.Exec("update community set allow_anonymous = ? where community_id = ?", allow, communityID)
+	if err != nil {
+		return
+	}
+	n, err := ret.RowsAffected()
+	if err != nil {
+		return
+	}
+	if n == 0 {
+		_, err = GetCommunityDetailByID(communityID)
+	}
+	return
+}
+
+// GetCommunityRules 社区的规则和允许的flair，社区不存在时返回ErrorInvalidID
+func GetCommunityRules(communityID int64) (*models.CommunityRules, error) {
+	var row struct {
+		Rules  string `db:"rules"`
+		Flairs string `db:"flairs"`
+	}
+	sqlStr := "select coalesce(rules, '') as rules, flairs from community where community_id = ?"
+	if err := db.Get(&row, sqlStr, communityID); err != nil {
+		if err == sql.ErrNoRows {
+			err = ErrorInvalidID
+		}
+		return nil, err
+	}
+	r := &models.CommunityRules{Rules: row.Rules, Flairs: []string{}}
+	if row.Flairs != "" {
+		if err := json.Unmarshal([]byte(row.Flairs), &r.Flairs); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// SetCommunityRules 修改社区的规则和允许的flair
+func SetCommunityRules(communityID int64, r *models.CommunityRules) (err error) {
+	flairs, err := json.Marshal(r.Flairs)
+	if err != nil {
+		return
+	}
+	ret, err := db.Exec("update community set rules = ?, flairs = ? where community_id = ?", r.Rules, string(flairs), communityID)
+	if err != nil {
+		return
+	}
+	n, err := ret.RowsAffected()
+	if err != nil {
+		return
+	}
+	if n == 0 {
+		_, err = GetCommunityDetailByID(communityID)
+	}
+	return
+}
+
+// GetJoinRequestStatus 用户在社区的加入申请的状态，没有申请时返回models.JoinRequestNone
+func GetJoinRequestStatus(communityID, userID int64) (status int8, err error) {
+	sqlStr := "select status from community_join_request where community_id = ? and user_id = ?"
+	err = db.Get(&status, sqlStr, communityID, userID)
+	if err == sql.ErrNoRows {
+		return models.JoinRequestNone, nil
+	}
+	return
+}
+
+// SaveJoinRequest 创建申请或邀请，已有记录时覆盖之前的状态
+func SaveJoinRequest(communityID, userID int64, status int8, handlerID int64) (err error) {
+	sqlStr := `insert into community_join_request(community_id, user_id, status, handler_id) values (?, ?, ?, ?)
+	on duplicate key update status = values(status), handler_id = values(handler_id)`
+	_, err = db.Exec(sqlStr, communityID, userID, status, handlerID)
+	return
+}
+
+// DenyJoinRequest 拒绝申请中的请求，申请不存在或已经处理过时返回changed=false
+func DenyJoinRequest(communityID, userID, handlerID int64) (changed bool, err error) {
+	sqlStr := "update community_join_request set status = ?, handler_id = ? where community_id = ? and user_id = ? and status = ?"
+	ret, err := db.Exec(sqlStr, models.JoinRequestDenied, handlerID, communityID, userID, models.JoinRequestPending)
+	if err != nil {
+		return
+	}
+	n, err := ret.RowsAffected()
+	return n > 0, err
+}
+
+// ApproveJoinRequest 在一个事务中把状态为from的申请或邀请改为已通过并加入社区，
+// 状态不是from时返回changed=false，handlerID为0时保留之前的处理人
+// afterJoin 在新加入社区时、事务提交前执行，返回错误时回滚
+func ApproveJoinRequest(communityID, userID int64, from int8, handlerID int64, afterJoin func() error) (changed bool, err error) {
+	err = WithTx(context.Background(), func(tx *Tx) error {
+		sqlStr := `update community_join_request set status = ?, handler_id = if(? = 0, handler_id, ?)
+		where community_id = ? and user_id = ? and status = ?`
+		ret, err := tx.Exec(sqlStr, models.JoinRequestApproved, handlerID, handlerID, communityID, userID, from)
+		if err != nil {
+			return err
+		}
+		n, err := ret.RowsAffected()
+		if err != nil || n == 0 {
+			return err
+		}
+		changed = true
+		ret, err = tx.Exec("insert ignore into community_member(user_id, community_id) values (?, ?)", userID, communityID)
+		if err != nil {
+			return err
+		}
+		if n, err = ret.RowsAffected(); err != nil {
+			return err
+		}
+		if n > 0 {
+			return afterJoin()
+		}
+		return nil
+	})
+	return changed && err == nil, err
+}
+
+// GetPendingJoinRequests 社区中申请中的请求，最早的在前
+func GetPendingJoinRequests(communityID, page, size int64) (list []*models.JoinRequest, err error) {
+	sqlStr := `select r.community_id, r.user_id, u.username, r.status, r.create_time from community_join_request r
+	join user u on u.user_id = r.user_id
+	where r.community_id = ? and r.status = ? order by r.update_time limit ?,?`
+	list = make([]*models.JoinRequest, 0, size)
+	err = db.Select(&list, sqlStr, communityID, models.JoinRequestPending, (page-1)*size, size)
+	return
+}
+
+// CountPendingJoinRequests 社区中申请中的请求数
+func CountPendingJoinRequests(communityID int64) (count int64, err error) {
+	sqlStr := "select count(*) from community_join_request where community_id = ? and status = ?"
+	err = db.Get(&count, sqlStr, communityID, models.JoinRequestPending)
+	return
+}
@@ -0,0 +1,25 @@
+package mysql
+
+import (
+	"go-web-app/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// GetDigestRecipients 返回ids中可以接收摘要邮件的用户: 邮箱已验证、没有注销或被封禁、没有关闭摘要
+func GetDigestRecipients(ids []int64) (users []*models.User, err error) {
+	users = make([]*models.User, 0, len(ids))
+	if len(ids) == 0 {
+		return
+	}
+	sqlStr := `select u.user_id, u.username, u.email from user u
+	left join notification_pref np on np.user_id = u.user_id
+	where u.user_id in (?) and u.email_verified = 1 and u.deactivated_at is null and u.banned_at is null
+	and ifnull(np.digest, 1) = 1`
+	query, args, err := sqlx.In(sqlStr, ids)
+	if err != nil {
+		return
+	}
+	err = db.Select(&users, db.Rebind(query), args...)
+	return
+}
@@ -1,10 +1,23 @@
 package mysql
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+)
 
 var (
 	ErrorUserExist       = errors.New("User existed")
+	ErrorEmailExist      = errors.New("Email already registered")
 	ErrorUserNotExist    = errors.New("User is not existed")
 	ErrorInvalidPassword = errors.New("Wrong password")
 	ErrorInvalidID       = errors.New("Invalid ID")
+	ErrorNotMember       = errors.New("User is not a member of the community")
+	ErrorCommunityExist  = errors.New("Community name already taken")
 )
+
+// isDuplicateEntry 违反唯一索引的错误
+func isDuplicateEntry(err error) bool {
+	var me *mysql.MySQLError
+	return errors.As(err, &me) && me.Number == 1062
+}
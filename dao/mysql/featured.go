@@ -0,0 +1,91 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrorFeaturedMismatch 重新排序时给出的社区与当前推荐的社区不一致
+var ErrorFeaturedMismatch = errors.New("Communities do not match the featured list. ")
+
+// GetFeaturedCommunityIDs 推荐的社区id，按顺序排列
+func GetFeaturedCommunityIDs() (ids []int64, err error) {
+	ids = make([]int64, 0)
+	err = db.Select(&ids, "select community_id from featured_community order by position, id")
+	return
+}
+
+// AddFeaturedCommunity 把社区加到推荐列表的最后，已经在列表中时返回added=false
+// max大于0时列表已满返回full=true
+func AddFeaturedCommunity(communityID int64, max int) (added, full bool, err error) {
+	if _, err = GetCommunityDetailByID(communityID); err != nil {
+		return
+	}
+	err = WithTx(context.Background(), func(tx *Tx) error {
+		// 锁住整个列表，并发添加时位置和数量都不会冲突
+		var ids []int64
+		if err := tx.Select(&ids, "select community_id from featured_community order by position for update"); err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if id == communityID {
+				return nil
+			}
+		}
+		if max > 0 && len(ids) >= max {
+			full = true
+			return nil
+		}
+		sqlStr := `insert into featured_community(community_id, position)
+		select ?, coalesce(max(position), 0) + 1 from featured_community`
+		if _, err := tx.Exec(sqlStr, communityID); err != nil {
+			return err
+		}
+		added = true
+		return nil
+	})
+	if err != nil {
+		return false, false, err
+	}
+	return
+}
+
+// RemoveFeaturedCommunity 从推荐列表中移除社区，不在列表中时返回removed=false
+func RemoveFeaturedCommunity(communityID int64) (removed bool, err error) {
+	ret, err := db.Exec("delete from featured_community where community_id = ?", communityID)
+	if err != nil {
+		return
+	}
+	n, err := ret.RowsAffected()
+	return n > 0, err
+}
+
+// ReorderFeaturedCommunities 按ids的顺序重新排列，ids必须正好是当前推荐的所有社区，否则返回ErrorFeaturedMismatch
+func ReorderFeaturedCommunities(ids []int64) error {
+	return WithTx(context.Background(), func(tx *Tx) error {
+		var current []int64
+		if err := tx.Select(&current, "select community_id from featured_community for update"); err != nil {
+			return err
+		}
+		set := make(map[int64]struct{}, len(current))
+		for _, id := range current {
+			set[id] = struct{}{}
+		}
+		if len(ids) != len(current) {
+			return ErrorFeaturedMismatch
+		}
+		for _, id := range ids {
+			if _, ok := set[id]; !ok {
+				return ErrorFeaturedMismatch
+			}
+			// 重复的id会让后面的检查通过，从集合中删除
+			delete(set, id)
+		}
+		for i, id := range ids {
+			if _, err := tx.Exec("update featured_community set position = ? where community_id = ?", i+1, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
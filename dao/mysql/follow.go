@@ -0,0 +1,90 @@
+package mysql
+
+import (
+	"context"
+	"go-web-app/models"
+)
+
+// Follow 插入一条关注关系，已经关注过时返回changed=false。
+// afterInsert 在事务提交前执行，返回错误时整个关注操作回滚。
+func Follow(followerID, followeeID int64, afterInsert func() error) (changed bool, err error) {
+	return execRelationTx("insert ignore into follow(follower_id, followee_id) values (?, ?)",
+		followerID, followeeID, afterInsert)
+}
+
+// Unfollow 删除一条关注关系，未关注时返回changed=false。
+// afterDelete 在事务提交前执行，返回错误时整个取消关注操作回滚。
+func Unfollow(followerID, followeeID int64, afterDelete func() error) (changed bool, err error) {
+	return execRelationTx("delete from follow where follower_id = ? and followee_id = ?",
+		followerID, followeeID, afterDelete)
+}
+
+// execRelationTx 在事务中插入或删除一条两个id之间的关系，有变化时执行onChange
+func execRelationTx(sqlStr string, followerID, followeeID int64, onChange func() error) (changed bool, err error) {
+	err = WithTx(context.Background(), func(tx *Tx) error {
+		ret, err := tx.Exec(sqlStr, followerID, followeeID)
+		if err != nil {
+			return err
+		}
+		n, err := ret.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if changed = n > 0; changed {
+			return onChange()
+		}
+		return nil
+	})
+	return changed && err == nil, err
+}
+
+// GetFollowers 返回关注了uid的用户，最近关注的在前
+func GetFollowers(uid, page, size int64) (users []*models.FollowUser, err error) {
+	sqlStr := `select u.user_id, u.username, f.create_time from follow f
+	join user u on u.user_id = f.follower_id
+	where f.followee_id = ? and u.deactivated_at is null order by f.id desc limit ?,?`
+	users = make([]*models.FollowUser, 0, size)
+	err = db.Select(&users, sqlStr, uid, (page-1)*size, size)
+	return
+}
+
+// GetFollowing 返回uid关注的用户，最近关注的在前
+func GetFollowing(uid, page, size int64) (users []*models.FollowUser, err error) {
+	sqlStr := `select u.user_id, u.username, f.create_time from follow f
+	join user u on u.user_id = f.followee_id
+	where f.follower_id = ? and u.deactivated_at is null order by f.id desc limit ?,?`
+	users = make([]*models.FollowUser, 0, size)
+	err = db.Select(&users, sqlStr, uid, (page-1)*size, size)
+	return
+}
+
+// GetFollowCount 直接从数据库统计关注数和粉丝数，用于重建redis中的计数
+func GetFollowCount(uid int64) (count *models.FollowCount, err error) {
+	count = new(models.FollowCount)
+	if err = db.Get(&count.Followers, "select count(*) from follow where followee_id = ?", uid); err != nil {
+		return
+	}
+	err = db.Get(&count.Following, "select count(*) from follow where follower_id = ?", uid)
+	return
+}
+
+// GetFollowingIDs 返回uid关注的所有用户id
+func GetFollowingIDs(uid int64) (ids []int64, err error) {
+	sqlStr := "select followee_id from follow where follower_id = ?"
+	err = db.Select(&ids, sqlStr, uid)
+	return
+}
+
+// GetFollowerIDs 返回关注了uid的所有用户id
+func GetFollowerIDs(uid int64) (ids []int64, err error) {
+	sqlStr := "select follower_id from follow where followee_id = ?"
+	err = db.Select(&ids, sqlStr, uid)
+	return
+}
+
+// IsFollowing 判断followerID是否关注了followeeID
+func IsFollowing(followerID, followeeID int64) (following bool, err error) {
+	sqlStr := "select count(*) > 0 from follow where follower_id = ? and followee_id = ?"
+	err = db.Get(&following, sqlStr, followerID, followeeID)
+	return
+}
@@ -0,0 +1,112 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrorMergeSelf  = errors.New("Cannot merge an account into itself")
+	ErrorUserMerged = errors.New("Account has already been merged into another account")
+)
+
+// MergeUser 在一个事务中把fromUID的帖子、关注、屏蔽、社区成员身份和收藏转给toUID，
+// 第三方账号的绑定在toUID没有绑定时一并转移，最后注销fromUID并记录合并到的账号。
+// fromUID已经合并到toUID时重新执行一遍，不会有任何变化；合并到其他账号时返回ErrorUserMerged
+func MergeUser(fromUID, toUID int64) error {
+	if fromUID == toUID {
+		return ErrorMergeSelf
+	}
+	return WithTx(context.Background(), func(tx *Tx) error {
+		if err := lockMergeUsers(tx, fromUID, toUID); err != nil {
+			return err
+		}
+		// 两个账号有相同的关系时(例如都关注了同一个人)，update ignore跳过会违反唯一索引的行，
+		// 随后删除fromUID剩下的这些行；转移后toUID关注或屏蔽自己的关系也删除
+		statements := []struct {
+			sql  string
+			args []interface{}
+		}{
+			{"update post set author_id = ? where author_id = ?", []interface{}{toUID, fromUID}},
+			{"update ignore follow set follower_id = ? where follower_id = ?", []interface{}{toUID, fromUID}},
+			{"update ignore follow set followee_id = ? where followee_id = ?", []interface{}{toUID, fromUID}},
+			{"delete from follow where follower_id = ? or followee_id = ?", []interface{}{fromUID, fromUID}},
+			{"delete from follow where follower_id = ? and followee_id = ?", []interface{}{toUID, toUID}},
+			{"update ignore block set blocker_id = ? where blocker_id = ?", []interface{}{toUID, fromUID}},
+			{"update ignore block set blocked_id = ? where blocked_id = ?", []interface{}{toUID, fromUID}},
+			{"delete from block where blocker_id = ? or blocked_id = ?", []interface{}{fromUID, fromUID}},
+			{"delete from block where blocker_id = ? and blocked_id = ?", []interface{}{toUID, toUID}},
+			// 都是同一社区的成员时保留较高的社区角色
+			{`update community_member t join community_member f on f.community_id = t.community_id and f.user_id = ?
+			set t.role = greatest(t.role, f.role) where t.user_id = ?`, []interface{}{fromUID, toUID}},
+			{"update ignore community_member set user_id = ? where user_id = ?", []interface{}{toUID, fromUID}},
+			{"delete from community_member where user_id = ?", []interface{}{fromUID}},
+			{"update ignore bookmark set user_id = ? where user_id = ?", []interface{}{toUID, fromUID}},
+			{"delete from bookmark where user_id = ?", []interface{}{fromUID}},
+		}
+		for _, s := range statements {
+			if _, err := tx.Exec(s.sql, s.args...); err != nil {
+				return err
+			}
+		}
+		if err := moveOAuth(tx, fromUID, toUID); err != nil {
+			return err
+		}
+		sqlStr := "update user set deactivated_at = ifnull(deactivated_at, now()), merged_into = ? where user_id = ?"
+		_, err := tx.Exec(sqlStr, toUID, fromUID)
+		return err
+	})
+}
+
+// lockMergeUsers 锁住两个用户的行，检查fromUID没有合并到其他账号，toUID存在且没有被合并
+func lockMergeUsers(tx *timeoutTx, fromUID, toUID int64) error {
+	var users []struct {
+		UserID     int64         `db:"user_id"`
+		MergedInto sql.NullInt64 `db:"merged_into"`
+	}
+	query, args, err := sqlx.In("select user_id, merged_into from user where user_id in (?) for update", []int64{fromUID, toUID})
+	if err != nil {
+		return err
+	}
+	if err = tx.Select(&users, tx.Rebind(query), args...); err != nil {
+		return err
+	}
+	if len(users) != 2 {
+		return ErrorUserNotExist
+	}
+	for _, u := range users {
+		if !u.MergedInto.Valid {
+			continue
+		}
+		if u.UserID == toUID || u.MergedInto.Int64 != toUID {
+			return ErrorUserMerged
+		}
+	}
+	return nil
+}
+
+// moveOAuth toUID没有绑定第三方账号时把fromUID的绑定转过去，先清空fromUID的绑定以免违反唯一索引
+func moveOAuth(tx *timeoutTx, fromUID, toUID int64) error {
+	var bound bool
+	if err := tx.Get(&bound, "select oauth_provider is not null from user where user_id = ?", toUID); err != nil || bound {
+		return err
+	}
+	var oauth struct {
+		Provider sql.NullString `db:"oauth_provider"`
+		Subject  sql.NullString `db:"oauth_subject"`
+	}
+	if err := tx.Get(&oauth, "select oauth_provider, oauth_subject from user where user_id = ?", fromUID); err != nil {
+		return err
+	}
+	if !oauth.Provider.Valid {
+		return nil
+	}
+	if _, err := tx.Exec("update user set oauth_provider = null, oauth_subject = null where user_id = ?", fromUID); err != nil {
+		return err
+	}
+	_, err := tx.Exec("update user set oauth_provider = ?, oauth_subject = ? where user_id = ?", oauth.Provider, oauth.Subject, toUID)
+	return err
+}
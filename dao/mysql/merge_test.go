@@ -0,0 +1,89 @@
+package mysql
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMergeUser(t *testing.T) {
+	const from, to, other = 900001, 900002, 900003
+	cleanup := func() {
+		db.MustExec("delete from user where user_id in (?, ?, ?)", from, to, other)
+		db.MustExec("delete from post where post_id = 900101")
+		db.MustExec("delete from follow where follower_id in (?, ?, ?) or followee_id in (?, ?, ?)", from, to, other, from, to, other)
+		db.MustExec("delete from community_member where user_id in (?, ?, ?)", from, to, other)
+		db.MustExec("delete from bookmark where post_id = 900101")
+	}
+	cleanup()
+	defer cleanup()
+
+	for _, uid := range []int64{from, to, other} {
+		db.MustExec("insert into user(user_id, username, password) values (?, ?, '')", uid, fmt.Sprintf("merge_test_%d", uid))
+	}
+	db.MustExec("insert into post(post_id, title, content, author_id, community_id) values (900101, 't', 'c', ?, 1)", from)
+	// 两个账号互相关注，并且都关注了other
+	db.MustExec("insert into follow(follower_id, followee_id) values (?, ?), (?, ?), (?, ?), (?, ?), (?, ?)",
+		from, to, to, from, from, other, to, other, other, from)
+	db.MustExec("insert into community_member(user_id, community_id, role) values (?, 1, 2), (?, 1, 1), (?, 2, 1)", from, to, from)
+	db.MustExec("insert into bookmark(user_id, post_id) values (?, 900101), (?, 900101)", from, to)
+
+	if err := MergeUser(from, to); err != nil {
+		t.Fatalf("MergeUser failed, err:%v", err)
+	}
+	check := func() {
+		var n int
+		db.Get(&n, "select count(*) from post where author_id = ?", to)
+		if n != 1 {
+			t.Fatalf("posts of target = %d, want 1", n)
+		}
+		// 互相关注变成关注自己，被删除；都关注了other只保留一条
+		db.Get(&n, "select count(*) from follow where follower_id = ? or followee_id = ?", from, from)
+		if n != 0 {
+			t.Fatalf("follows of source = %d, want 0", n)
+		}
+		db.Get(&n, "select count(*) from follow where follower_id = ? and followee_id = ?", to, to)
+		if n != 0 {
+			t.Fatalf("target follows itself")
+		}
+		count, err := GetFollowCount(to)
+		if err != nil || count.Following != 1 || count.Followers != 1 {
+			t.Fatalf("GetFollowCount(to) = %+v, %v; want 1 following, 1 follower", count, err)
+		}
+		// 保留较高的社区角色
+		var role int
+		db.Get(&role, "select role from community_member where user_id = ? and community_id = 1", to)
+		if role != 2 {
+			t.Fatalf("role in community 1 = %d, want 2", role)
+		}
+		ids, _ := GetJoinedCommunityIDs(to)
+		if len(ids) != 2 {
+			t.Fatalf("communities of target = %v, want 2", ids)
+		}
+		db.Get(&n, "select count(*) from bookmark where user_id in (?, ?)", from, to)
+		if n != 1 {
+			t.Fatalf("bookmarks = %d, want 1", n)
+		}
+		var merged int64
+		db.Get(&merged, "select ifnull(merged_into, 0) from user where user_id = ? and deactivated_at is not null", from)
+		if merged != to {
+			t.Fatalf("merged_into = %d, want %d", merged, to)
+		}
+	}
+	check()
+
+	// 重试不会有变化
+	if err := MergeUser(from, to); err != nil {
+		t.Fatalf("retry MergeUser failed, err:%v", err)
+	}
+	check()
+
+	if err := MergeUser(from, other); err != ErrorUserMerged {
+		t.Fatalf("merge into another user: err = %v, want ErrorUserMerged", err)
+	}
+	if err := MergeUser(to, from); err != ErrorUserMerged {
+		t.Fatalf("merge into a merged user: err = %v, want ErrorUserMerged", err)
+	}
+	if err := SetDeactivated(from, false); err != ErrorUserMerged {
+		t.Fatalf("reactivate merged user: err = %v, want ErrorUserMerged", err)
+	}
+}
@@ -0,0 +1,145 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+const (
+	migrationLockName    = "go-web-app:schema_migrations"
+	migrationLockTimeout = 60 // 秒
+)
+
+const createMigrationTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version varchar(255) NOT NULL,
+	applied_at timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (version)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci`
+
+type migration struct {
+	Version string // 文件名，例如0001_init.sql
+	SQL     string
+}
+
+// loadMigrations 读取内嵌的迁移文件，按文件名排序
+func loadMigrations(fsys fs.FS) ([]migration, error) {
+	names, err := fs.Glob(fsys, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	migrations := make([]migration, 0, len(names))
+	for _, name := range names {
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration{
+			Version: strings.TrimPrefix(name, "migrations/"),
+			SQL:     string(b),
+		})
+	}
+	return migrations, nil
+}
+
+// splitStatements 按行尾的分号拆分SQL语句，驱动默认不允许一次执行多条语句
+func splitStatements(script string) []string {
+	var stmts []string
+	var buf strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if buf.Len() == 0 && (trimmed == "" || strings.HasPrefix(trimmed, "--")) {
+			continue
+		}
+		buf.WriteString(line)
+		buf.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			stmt := strings.TrimSuffix(strings.TrimSpace(buf.String()), ";")
+			stmts = append(stmts, stmt)
+			buf.Reset()
+		}
+	}
+	if stmt := strings.TrimSpace(buf.String()); stmt != "" {
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
+
+// Migrate 按顺序执行未应用的迁移文件
+// 使用GET_LOCK加锁，多个实例同时启动时只有一个会执行迁移
+func Migrate(ctx context.Context) error {
+	migrations, err := loadMigrations(migrationFS)
+	if err != nil {
+		return fmt.Errorf("load migrations: %w", err)
+	}
+	// 锁是连接级别的，后续语句都在同一个连接上执行
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var locked sql.NullInt64
+	if err = conn.QueryRowContext(ctx, "select get_lock(?, ?)", migrationLockName, migrationLockTimeout).Scan(&locked); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	if locked.Int64 != 1 {
+		return fmt.Errorf("acquire migration lock: timeout after %ds", migrationLockTimeout)
+	}
+	defer func() {
+		_, _ = conn.ExecContext(context.Background(), "select release_lock(?)", migrationLockName)
+	}()
+
+	if _, err = conn.ExecContext(ctx, createMigrationTable); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+		start := time.Now()
+		// MySQL的DDL会隐式提交，无法放在事务中回滚，失败时需要人工处理
+		for _, stmt := range splitStatements(m.SQL) {
+			if _, err = conn.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("migration %s failed: %w", m.Version, err)
+			}
+		}
+		if _, err = conn.ExecContext(ctx, "insert into schema_migrations(version) values(?)", m.Version); err != nil {
+			return fmt.Errorf("record migration %s: %w", m.Version, err)
+		}
+		zap.L().Info("migration applied", zap.String("version", m.Version), zap.Duration("cost", time.Since(start)))
+	}
+	return nil
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[string]bool, error) {
+	rows, err := conn.QueryContext(ctx, "select version from schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[string]bool)
+	for rows.Next() {
+		var version string
+		if err = rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
@@ -0,0 +1,105 @@
+package mysql
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- 注释
+CREATE TABLE a (
+  id int,
+  name varchar(8) COMMENT 'x'
+);
+
+INSERT INTO a VALUES (1, 'a');
+INSERT INTO a VALUES (2, 'b')`
+	got := splitStatements(script)
+	want := []string{
+		"CREATE TABLE a (\n  id int,\n  name varchar(8) COMMENT 'x'\n)",
+		"INSERT INTO a VALUES (1, 'a')",
+		"INSERT INTO a VALUES (2, 'b')",
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationFS)
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	assert.Equal(t, "0001_init.sql", migrations[0].Version)
+	for i := 1; i < len(migrations); i++ {
+		assert.Less(t, migrations[i-1].Version, migrations[i].Version, "migrations not sorted")
+	}
+	for _, m := range migrations {
+		for _, stmt := range splitStatements(m.SQL) {
+			assert.False(t, strings.HasPrefix(strings.ToUpper(stmt), "DROP TABLE"), "%s drops a table", m.Version)
+		}
+	}
+}
+
+// TestMigrateUpgradeBaseline 在只有原始建表语句的库上执行全部迁移
+// 需要一个可以随意清空的库，MYSQL_MIGRATE_TEST_DSN未设置时跳过
+func TestMigrateUpgradeBaseline(t *testing.T) {
+	dsn := os.Getenv("MYSQL_MIGRATE_TEST_DSN")
+	if dsn == "" {
+		t.Skip("MYSQL_MIGRATE_TEST_DSN not set")
+	}
+	ctx := context.Background()
+	testDB, err := sqlx.Connect("mysql", dsn)
+	require.NoError(t, err)
+	old := db
+	db = &timeoutDB{testDB}
+	t.Cleanup(func() {
+		db = old
+		_ = testDB.Close()
+	})
+
+	var tables []string
+	require.NoError(t, testDB.Select(&tables, "select table_name from information_schema.tables where table_schema = database()"))
+	for _, table := range tables {
+		_, err = testDB.Exec("DROP TABLE `" + table + "`")
+		require.NoError(t, err)
+	}
+
+	// 模拟迁移功能上线前的库：只有0001里的表，没有schema_migrations
+	migrations, err := loadMigrations(migrationFS)
+	require.NoError(t, err)
+	for _, stmt := range splitStatements(migrations[0].SQL) {
+		_, err = testDB.Exec(stmt)
+		require.NoError(t, err)
+	}
+	_, err = testDB.Exec("insert into user(user_id, username, password, email) values(1, 'old', 'x', 'old@rutgers.edu')")
+	require.NoError(t, err)
+
+	require.NoError(t, Migrate(ctx))
+	// 再执行一次不应该重复应用
+	require.NoError(t, Migrate(ctx))
+
+	var applied int
+	require.NoError(t, testDB.Get(&applied, "select count(*) from schema_migrations"))
+	require.Equal(t, len(migrations), applied)
+
+	columns := map[string][]string{
+		"user": {"email_verified", "avatar", "role", "deactivated_at", "banned_at"},
+		"post": {"comment_locked", "deleted_at", "slug"},
+	}
+	for table, cols := range columns {
+		for _, col := range cols {
+			var n int
+			require.NoError(t, testDB.Get(&n, "select count(*) from information_schema.columns where table_schema = database() and table_name = ? and column_name = ?", table, col))
+			require.Equal(t, 1, n, "%s.%s missing after migrate", table, col)
+		}
+	}
+
+	// 迁移之前注册的用户不需要重新验证邮箱
+	var verified bool
+	require.NoError(t, testDB.Get(&verified, "select email_verified from user where user_id = 1"))
+	require.True(t, verified)
+}
@@ -1,5 +1,4 @@
-DROP TABLE IF EXISTS `user`;
-CREATE TABLE `user` (
+CREATE TABLE IF NOT EXISTS `user` (
     `id` bigint(20) NOT NULL AUTO_INCREMENT,
     `user_id` bigint(20) NOT NULL,
     `username` varchar(64) COLLATE utf8mb4_general_ci NOT NULL,
@@ -13,9 +12,7 @@ CREATE TABLE `user` (
     UNIQUE KEY `idx_user_id` (`user_id`) USING BTREE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
 
-
-DROP TABLE IF EXISTS `community`;
-CREATE TABLE `community` (
+CREATE TABLE IF NOT EXISTS `community` (
   `id` int(11) NOT NULL AUTO_INCREMENT,
   `community_id` int(10) unsigned NOT NULL,
   `community_name` varchar(128) COLLATE utf8mb4_general_ci NOT NULL,
@@ -26,13 +23,12 @@ CREATE TABLE `community` (
   UNIQUE KEY `idx_community_id` (`community_id`),
   UNIQUE KEY `idx_community_name` (`community_name`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
-INSERT INTO `community` VALUES ('1', '1', 'Go', 'Golang', '2016-11-01 08:10:10', '2016-11-01 08:10:10');
-INSERT INTO `community` VALUES ('2', '2', 'leetcode', '刷题刷题刷题', '2020-01-01 08:00:00', '2020-01-01 08:00:00');
-INSERT INTO `community` VALUES ('3', '3', 'PUBG', '大吉大利，今晚吃鸡。', '2018-08-07 08:30:00', '2018-08-07 08:30:00');
-INSERT INTO `community` VALUES ('4', '4', 'LOL', '欢迎来到英雄联盟!', '2016-01-01 08:00:00', '2016-01-01 08:00:00');
+INSERT IGNORE INTO `community` VALUES ('1', '1', 'Go', 'Golang', '2016-11-01 08:10:10', '2016-11-01 08:10:10');
+INSERT IGNORE INTO `community` VALUES ('2', '2', 'leetcode', '刷题刷题刷题', '2020-01-01 08:00:00', '2020-01-01 08:00:00');
+INSERT IGNORE INTO `community` VALUES ('3', '3', 'PUBG', '大吉大利，今晚吃鸡。', '2018-08-07 08:30:00', '2018-08-07 08:30:00');
+INSERT IGNORE INTO `community` VALUES ('4', '4', 'LOL', '欢迎来到英雄联盟!', '2016-01-01 08:00:00', '2016-01-01 08:00:00');
 
-DROP TABLE IF EXISTS `post`;
-CREATE TABLE `post` (
+CREATE TABLE IF NOT EXISTS `post` (
   `id` bigint(20) NOT NULL AUTO_INCREMENT,
   `post_id` bigint(20) NOT NULL COMMENT '帖子id',
   `title` varchar(128) COLLATE utf8mb4_general_ci NOT NULL COMMENT '标题',
@@ -48,9 +44,7 @@ CREATE TABLE `post` (
   KEY `idx_community_id` (`community_id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
 
-
-DROP TABLE IF EXISTS `comment`;
-CREATE TABLE `comment` (
+CREATE TABLE IF NOT EXISTS `comment` (
   `id` bigint(20) NOT NULL AUTO_INCREMENT,
   `comment_id` bigint(20) unsigned NOT NULL,
   `content` text COLLATE utf8mb4_general_ci NOT NULL,
@@ -63,4 +57,4 @@ CREATE TABLE `comment` (
   PRIMARY KEY (`id`),
   UNIQUE KEY `idx_comment_id` (`comment_id`),
   KEY `idx_author_Id` (`author_id`)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
\ No newline at end of file
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
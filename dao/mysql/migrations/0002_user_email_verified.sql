@@ -0,0 +1,3 @@
+ALTER TABLE `user` ADD COLUMN `email_verified` tinyint(1) NOT NULL DEFAULT '0' AFTER `email`;
+-- 上线邮箱验证之前注册的用户没有收到过验证邮件，视为已验证，否则会无法登录
+UPDATE `user` SET `email_verified` = 1;
@@ -0,0 +1 @@
+ALTER TABLE `user` ADD KEY `idx_email` (`email`);
@@ -0,0 +1 @@
+ALTER TABLE `post` ADD FULLTEXT KEY `idx_title_content` (`title`, `content`) WITH PARSER ngram;
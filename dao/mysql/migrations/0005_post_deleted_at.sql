@@ -0,0 +1 @@
+ALTER TABLE `post` ADD COLUMN `deleted_at` timestamp NULL DEFAULT NULL COMMENT '删除时间，NULL表示未删除' AFTER `update_time`;
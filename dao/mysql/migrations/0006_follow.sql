@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS `follow` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `follower_id` bigint(20) NOT NULL COMMENT '关注者',
+  `followee_id` bigint(20) NOT NULL COMMENT '被关注者',
+  `create_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_follower_followee` (`follower_id`, `followee_id`),
+  KEY `idx_followee_id` (`followee_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
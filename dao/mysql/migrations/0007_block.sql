@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS `block` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `blocker_id` bigint(20) NOT NULL COMMENT '屏蔽者',
+  `blocked_id` bigint(20) NOT NULL COMMENT '被屏蔽者',
+  `create_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_blocker_blocked` (`blocker_id`, `blocked_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
@@ -0,0 +1 @@
+ALTER TABLE `user` ADD COLUMN `avatar` varchar(256) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' AFTER `email_verified`;
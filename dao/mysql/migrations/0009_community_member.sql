@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS `community_member` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `community_id` bigint(20) NOT NULL COMMENT '社区id',
+  `user_id` bigint(20) NOT NULL COMMENT '用户id',
+  `role` tinyint(4) NOT NULL DEFAULT '1' COMMENT '社区角色 1成员 2版主 3管理员',
+  `create_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_user_community` (`user_id`, `community_id`),
+  KEY `idx_community_id` (`community_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
@@ -0,0 +1 @@
+ALTER TABLE `user` ADD COLUMN `role` tinyint(4) NOT NULL DEFAULT '0' COMMENT '0:普通用户 1:管理员' AFTER `gender`;
@@ -0,0 +1 @@
+ALTER TABLE `post` ADD COLUMN `comment_locked` tinyint(1) NOT NULL DEFAULT '0' COMMENT '是否禁止评论' AFTER `status`;
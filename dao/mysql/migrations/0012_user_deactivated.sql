@@ -0,0 +1 @@
+ALTER TABLE `user` ADD COLUMN `deactivated_at` timestamp NULL DEFAULT NULL COMMENT '注销时间，NULL表示正常' AFTER `role`;
@@ -0,0 +1 @@
+ALTER TABLE `user` ADD COLUMN `banned_at` timestamp NULL DEFAULT NULL COMMENT '封禁时间，NULL表示正常' AFTER `deactivated_at`;
@@ -0,0 +1,4 @@
+ALTER TABLE `user`
+  ADD COLUMN `oauth_provider` varchar(32) COLLATE utf8mb4_general_ci DEFAULT NULL COMMENT '第三方登录的提供方，例如google',
+  ADD COLUMN `oauth_subject` varchar(128) COLLATE utf8mb4_general_ci DEFAULT NULL COMMENT '提供方的用户id',
+  ADD UNIQUE KEY `idx_oauth` (`oauth_provider`, `oauth_subject`);
@@ -0,0 +1 @@
+ALTER TABLE `user` ADD COLUMN `karma` bigint(20) NOT NULL DEFAULT '0' COMMENT '声望，redis中的计数定期写回' AFTER `role`;
@@ -0,0 +1,3 @@
+ALTER TABLE `post`
+  ADD COLUMN `publish_at` timestamp NULL DEFAULT NULL COMMENT '定时发布的时间，NULL表示立即发布' AFTER `comment_locked`,
+  ADD KEY `idx_status_publish_at` (`status`, `publish_at`);
@@ -0,0 +1,13 @@
+-- 帖子可以同时发布到多个社区，post.community_id保留为主社区
+CREATE TABLE IF NOT EXISTS `post_community` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `post_id` bigint(20) NOT NULL,
+  `community_id` bigint(20) NOT NULL,
+  `create_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_post_community` (`post_id`, `community_id`),
+  KEY `idx_community_id` (`community_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+INSERT IGNORE INTO `post_community` (`post_id`, `community_id`)
+SELECT `post_id`, `community_id` FROM `post`;
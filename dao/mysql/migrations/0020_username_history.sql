@@ -0,0 +1,10 @@
+-- 用户修改用户名之前使用过的用户名，用于旧链接跳转和保留期内防止被他人注册
+CREATE TABLE IF NOT EXISTS `username_history` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `user_id` bigint(20) NOT NULL,
+  `username` varchar(64) COLLATE utf8mb4_general_ci NOT NULL,
+  `change_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP COMMENT '改为新用户名的时间',
+  PRIMARY KEY (`id`),
+  KEY `idx_username` (`username`),
+  KEY `idx_user_id` (`user_id`, `change_time`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
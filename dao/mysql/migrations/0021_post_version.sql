@@ -0,0 +1,2 @@
+ALTER TABLE `post`
+  ADD COLUMN `version` int(11) NOT NULL DEFAULT '1' COMMENT '每次编辑加1，用于检测并发的编辑' AFTER `comment_locked`;
@@ -0,0 +1,2 @@
+ALTER TABLE `user`
+  ADD COLUMN `merged_into` bigint(20) DEFAULT NULL COMMENT '账号合并后内容所属的用户id，NULL表示没有被合并' AFTER `deactivated_at`;
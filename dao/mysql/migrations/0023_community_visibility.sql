@@ -0,0 +1,17 @@
+-- 私有社区的帖子只有成员可见，加入需要版主邀请或审批
+ALTER TABLE `community`
+  ADD COLUMN `visibility` varchar(16) COLLATE utf8mb4_general_ci NOT NULL DEFAULT 'public' COMMENT 'public公开 private私有' AFTER `introduction`;
+
+-- 私有社区的加入申请和邀请，每个用户在每个社区只保留最近一次
+CREATE TABLE IF NOT EXISTS `community_join_request` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `community_id` bigint(20) NOT NULL,
+  `user_id` bigint(20) NOT NULL,
+  `status` tinyint(4) NOT NULL COMMENT '1申请中 2已邀请 3已通过 4已拒绝',
+  `handler_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '邀请或处理申请的版主',
+  `create_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  `update_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_community_user` (`community_id`, `user_id`),
+  KEY `idx_community_status` (`community_id`, `status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
@@ -0,0 +1,10 @@
+-- 首页推荐的社区，由网站管理员维护，按position从小到大排列
+CREATE TABLE IF NOT EXISTS `featured_community` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `community_id` bigint(20) NOT NULL,
+  `position` int(11) NOT NULL DEFAULT '0',
+  `create_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_community_id` (`community_id`),
+  KEY `idx_position` (`position`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
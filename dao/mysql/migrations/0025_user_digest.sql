@@ -0,0 +1,2 @@
+ALTER TABLE `user`
+  ADD COLUMN `digest_opt_out` tinyint(1) NOT NULL DEFAULT '0' COMMENT '1表示不接收每天的未读通知摘要邮件' AFTER `merged_into`;
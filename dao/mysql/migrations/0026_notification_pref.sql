@@ -0,0 +1,17 @@
+-- 用户的通知设置，没有记录的用户使用默认设置(全部开启)
+CREATE TABLE IF NOT EXISTS `notification_pref` (
+  `user_id` bigint(20) NOT NULL,
+  `reply` tinyint(1) NOT NULL DEFAULT '1',
+  `vote` tinyint(1) NOT NULL DEFAULT '1',
+  `follow` tinyint(1) NOT NULL DEFAULT '1',
+  `mention` tinyint(1) NOT NULL DEFAULT '1',
+  `digest` tinyint(1) NOT NULL DEFAULT '1' COMMENT '每天的未读通知摘要邮件',
+  `create_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  `update_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+-- 摘要邮件的设置移到notification_pref中
+INSERT IGNORE INTO `notification_pref` (`user_id`, `digest`)
+  SELECT `user_id`, 0 FROM `user` WHERE `digest_opt_out` = 1;
+ALTER TABLE `user` DROP COLUMN `digest_opt_out`;
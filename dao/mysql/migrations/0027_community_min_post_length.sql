@@ -0,0 +1,3 @@
+-- 社区中发帖时内容的最少字符数，由版主设置，0表示使用站点的默认值(post.min_content_length)
+ALTER TABLE `community`
+  ADD COLUMN `min_post_length` int(11) NOT NULL DEFAULT '0' COMMENT '帖子内容的最少字符数，0表示使用站点默认值' AFTER `visibility`;
@@ -0,0 +1,3 @@
+-- redis中帖子排序分数的备份，由定时任务写回，redis的数据丢失后用于重建排序
+ALTER TABLE `post`
+  ADD COLUMN `score` double NOT NULL DEFAULT '0' COMMENT 'redis中的排序分数，0表示还没有写回过' AFTER `version`;
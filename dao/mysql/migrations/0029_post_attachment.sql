@@ -0,0 +1,15 @@
+-- 帖子的附件，文件保存在attachment.dir中，帖子被彻底删除后由清理任务删除记录和文件
+CREATE TABLE IF NOT EXISTS `post_attachment` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `attachment_id` bigint(20) NOT NULL,
+  `post_id` bigint(20) NOT NULL,
+  `uploader_id` bigint(20) NOT NULL,
+  `file_name` varchar(255) COLLATE utf8mb4_general_ci NOT NULL COMMENT '上传时的文件名，下载时使用',
+  `content_type` varchar(128) COLLATE utf8mb4_general_ci NOT NULL COMMENT '按文件头判断的类型',
+  `size` bigint(20) NOT NULL COMMENT '字节数',
+  `stored_name` varchar(64) COLLATE utf8mb4_general_ci NOT NULL COMMENT '保存在本地目录中的文件名',
+  `create_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_attachment_id` (`attachment_id`),
+  KEY `idx_post_id` (`post_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
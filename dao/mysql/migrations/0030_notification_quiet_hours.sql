@@ -0,0 +1,5 @@
+-- 免打扰时段，quiet_start为空表示不启用
+ALTER TABLE `notification_pref`
+  ADD COLUMN `quiet_start` char(5) NOT NULL DEFAULT '' COMMENT '开始时间HH:MM' AFTER `digest`,
+  ADD COLUMN `quiet_end` char(5) NOT NULL DEFAULT '' COMMENT '结束时间HH:MM，早于开始时间表示跨过午夜' AFTER `quiet_start`,
+  ADD COLUMN `quiet_timezone` varchar(64) NOT NULL DEFAULT '' COMMENT 'IANA时区名称' AFTER `quiet_end`;
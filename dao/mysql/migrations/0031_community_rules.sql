@@ -0,0 +1,8 @@
+-- 版主设置的社区规则和帖子可以使用的flair，flairs为JSON数组
+ALTER TABLE `community`
+  ADD COLUMN `rules` text COMMENT '社区规则' AFTER `min_post_length`,
+  ADD COLUMN `flairs` varchar(1024) NOT NULL DEFAULT '[]' COMMENT '允许的flair，JSON数组' AFTER `rules`;
+
+-- 帖子的flair，只能是主社区允许的flair之一，空字符串表示没有
+ALTER TABLE `post`
+  ADD COLUMN `flair` varchar(32) NOT NULL DEFAULT '' COMMENT '帖子的flair' AFTER `community_id`;
@@ -0,0 +1,13 @@
+-- 版主为社区设置的webhook，社区中有新帖子时发送通知，secret不为空时对请求签名
+CREATE TABLE IF NOT EXISTS `community_webhook` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `webhook_id` bigint(20) NOT NULL,
+  `community_id` bigint(20) NOT NULL,
+  `url` varchar(512) NOT NULL,
+  `secret` varchar(128) NOT NULL DEFAULT '',
+  `creator_id` bigint(20) NOT NULL,
+  `create_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_webhook_id` (`webhook_id`),
+  KEY `idx_community_id` (`community_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
@@ -0,0 +1,3 @@
+-- 版主手动解锁评论的帖子，不受comment.close_after的限制
+ALTER TABLE `post`
+  ADD COLUMN `comment_unlocked` tinyint(1) NOT NULL DEFAULT 0 COMMENT '版主手动解锁了评论' AFTER `comment_locked`;
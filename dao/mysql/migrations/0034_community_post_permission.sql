@@ -0,0 +1,3 @@
+-- 社区中谁可以发帖，只允许版主发帖时社区只用来发布公告
+ALTER TABLE `community`
+  ADD COLUMN `post_permission` varchar(16) COLLATE utf8mb4_general_ci NOT NULL DEFAULT 'everyone' COMMENT 'everyone所有人 members成员 moderators版主' AFTER `min_post_length`;
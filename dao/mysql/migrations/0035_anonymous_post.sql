@@ -0,0 +1,6 @@
+-- 版主允许后社区中可以匿名发帖，匿名帖子的作者只有版主和管理员可以查看
+ALTER TABLE `community`
+  ADD COLUMN `allow_anonymous` tinyint(1) NOT NULL DEFAULT '0' COMMENT '是否允许匿名发帖' AFTER `post_permission`;
+
+ALTER TABLE `post`
+  ADD COLUMN `anonymous` tinyint(1) NOT NULL DEFAULT '0' COMMENT '匿名发布，公开的接口中不返回作者' AFTER `author_id`;
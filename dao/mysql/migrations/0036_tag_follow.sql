@@ -0,0 +1,11 @@
+-- 用户关注的标签，关注的标签中有帖子变热门时通知用户
+CREATE TABLE IF NOT EXISTS `tag_follow` (
+  `user_id` bigint(20) NOT NULL,
+  `tag` varchar(32) COLLATE utf8mb4_general_ci NOT NULL COMMENT '规范化之后的标签名',
+  `create_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`user_id`, `tag`),
+  KEY `idx_tag` (`tag`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+ALTER TABLE `notification_pref`
+  ADD COLUMN `trending` tinyint(1) NOT NULL DEFAULT '1' COMMENT '关注的标签中有帖子变热门时通知' AFTER `mention`;
@@ -0,0 +1,18 @@
+-- 两步验证，密钥用two_factor.secret_key加密保存，验证过第一个验证码之后才开启
+CREATE TABLE IF NOT EXISTS `user_totp` (
+  `user_id` bigint(20) NOT NULL,
+  `secret` varchar(255) COLLATE utf8mb4_general_ci NOT NULL COMMENT '加密之后的TOTP密钥',
+  `enabled` tinyint(1) NOT NULL DEFAULT '0',
+  `create_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  `update_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+-- 一次性的恢复码，只保存哈希
+CREATE TABLE IF NOT EXISTS `user_recovery_code` (
+  `user_id` bigint(20) NOT NULL,
+  `code_hash` char(64) COLLATE utf8mb4_general_ci NOT NULL COMMENT '恢复码的SHA-256',
+  `used_time` timestamp NULL DEFAULT NULL,
+  `create_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`user_id`, `code_hash`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
@@ -0,0 +1,12 @@
+-- 帖子的slug，post.slug为当前使用的slug，post_slug保存帖子用过的所有slug，旧slug重定向到当前的slug
+ALTER TABLE `post`
+  ADD COLUMN `slug` varchar(128) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '为空表示还没有生成' AFTER `title`,
+  ADD KEY `idx_slug` (`slug`);
+
+CREATE TABLE IF NOT EXISTS `post_slug` (
+  `slug` varchar(128) COLLATE utf8mb4_general_ci NOT NULL,
+  `post_id` bigint(20) NOT NULL,
+  `create_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`slug`),
+  KEY `idx_post_id` (`post_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
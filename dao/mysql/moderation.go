@@ -0,0 +1,102 @@
+package mysql
+
+import (
+	"context"
+	"go-web-app/models"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DeleteUserPosts 在一个事务中软删除用户在[from, to)之间创建的帖子，时间为零值时不限制
+// communityID不为0时只处理发布到该社区的帖子: 同时发布到其他社区的帖子只从该社区移除，作为detached返回，
+// 其余的帖子被删除，作为deleted返回
+func DeleteUserPosts(uid, communityID int64, from, to time.Time) (deleted []*models.Post, detached []int64, err error) {
+	err = WithTx(context.Background(), func(tx *Tx) (err error) {
+		sqlStr := `select post_id, title, content, author_id, anonymous, community_id, status, create_time from post p
+		where author_id = ? and deleted_at is null
+		and (? or create_time >= ?) and (? or create_time < ?)
+		and (? = 0 or p.community_id = ? or exists (select 1 from post_community pc where pc.post_id = p.post_id and pc.community_id = ?))
+		for update`
+		var posts []*models.Post
+		err = tx.Select(&posts, sqlStr, uid, from.IsZero(), from, to.IsZero(), to, communityID, communityID, communityID)
+		if err != nil || len(posts) == 0 {
+			return
+		}
+		ids := make([]int64, 0, len(posts))
+		for _, post := range posts {
+			ids = append(ids, post.PostID)
+		}
+		if communityID != 0 {
+			if detached, err = detachPosts(tx, ids, communityID); err != nil {
+				return
+			}
+		}
+		remaining := make(map[int64]struct{}, len(detached))
+		for _, id := range detached {
+			remaining[id] = struct{}{}
+		}
+		ids = ids[:0]
+		for _, post := range posts {
+			if _, ok := remaining[post.PostID]; !ok {
+				deleted = append(deleted, post)
+				ids = append(ids, post.PostID)
+			}
+		}
+		if len(ids) > 0 {
+			query, args, e := sqlx.In("update post set deleted_at = now() where post_id in (?)", ids)
+			if err = e; err != nil {
+				return
+			}
+			if _, err = tx.Exec(tx.Rebind(query), args...); err != nil {
+				return
+			}
+		}
+		return
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return
+}
+
+// detachPosts 把帖子从社区中移除，主社区被移除时使用剩下的第一个社区，返回还属于其他社区的帖子
+func detachPosts(tx *timeoutTx, ids []int64, communityID int64) (detached []int64, err error) {
+	query, args, err := sqlx.In("delete from post_community where community_id = ? and post_id in (?)", communityID, ids)
+	if err != nil {
+		return
+	}
+	if _, err = tx.Exec(tx.Rebind(query), args...); err != nil {
+		return
+	}
+	query, args, err = sqlx.In("select distinct post_id from post_community where post_id in (?)", ids)
+	if err != nil {
+		return
+	}
+	if err = tx.Select(&detached, tx.Rebind(query), args...); err != nil || len(detached) == 0 {
+		return
+	}
+	query, args, err = sqlx.In(`update post p set community_id = (
+		select pc.community_id from post_community pc where pc.post_id = p.post_id order by pc.id limit 1
+	) where p.post_id in (?) and p.community_id = ?`, detached, communityID)
+	if err != nil {
+		return
+	}
+	_, err = tx.Exec(tx.Rebind(query), args...)
+	return
+}
+
+// GetCommunityPostIDsIn 返回ids中发布到了该社区的帖子，包括已删除的帖子
+func GetCommunityPostIDsIn(communityID int64, ids []int64) (found []int64, err error) {
+	found = make([]int64, 0)
+	if len(ids) == 0 {
+		return
+	}
+	query, args, err := sqlx.In(`select post_id from post where post_id in (?) and community_id = ?
+	union select post_id from post_community where post_id in (?) and community_id = ?`, ids, communityID, ids, communityID)
+	if err != nil {
+		return
+	}
+	err = db.Select(&found, db.Rebind(query), args...)
+	return
+}
@@ -1,16 +1,28 @@
 package mysql
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"go-web-app/pkg/slowlog"
+	"go-web-app/pkg/tracing"
 	"go-web-app/settings"
+	"time"
 
 	"go.uber.org/zap"
 
-	_ "github.com/go-sql-driver/mysql"
+	gomysql "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 )
 
-var db *sqlx.DB
+var db *timeoutDB
+
+// driverName 记录慢查询和span的mysql驱动，见slowlog.WrapDriver和tracing.WrapDriver
+const driverName = "mysql-instrumented"
+
+func init() {
+	sql.Register(driverName, slowlog.WrapDriver(tracing.WrapDriver(gomysql.MySQLDriver{})))
+}
 
 func Init(cfg *settings.MySQLConfig) (err error) {
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true&loc=Local", cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.DB)
@@ -21,17 +33,61 @@ func Init(cfg *settings.MySQLConfig) (err error) {
 	//	viper.GetInt("mysql.port"),
 	//	viper.GetString("mysql.dbname"),
 	//)
-	// 也可以使用MustConnect连接不成功就panic
-	db, err = sqlx.Connect("mysql", dsn)
+	// 使用包装后的驱动，sqlx仍然按mysql的占位符处理
+	sqlDB, err := sql.Open(driverName, dsn)
 	if err != nil {
 		zap.L().Error("connect DB failed", zap.Error(err))
 		return
 	}
-	db.SetMaxOpenConns(cfg.MaxOpenConns)
-	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db = &timeoutDB{sqlx.NewDb(sqlDB, "mysql")}
+	queryTimeout = time.Duration(cfg.QueryTimeout) * time.Millisecond
+	if err = db.Ping(); err != nil {
+		_ = db.Close()
+		zap.L().Error("connect DB failed", zap.Error(err))
+		return
+	}
+	maxOpen, maxIdle, lifetime := applyPoolSettings(db.DB.DB, cfg)
+	zap.L().Info("mysql connection pool",
+		zap.Int("max_open_conns", maxOpen),
+		zap.Int("max_idle_conns", maxIdle),
+		zap.Duration("conn_max_lifetime", lifetime))
+	if cfg.AutoMigrate {
+		if err = Migrate(context.Background()); err != nil {
+			_ = db.Close()
+			zap.L().Error("migrate DB failed", zap.Error(err))
+			return
+		}
+	}
+	return
+}
+
+// applyPoolSettings 把连接池配置应用到db上，返回实际生效的值
+// MaxIdleConns大于MaxOpenConns时没有意义，这里截断为MaxOpenConns
+func applyPoolSettings(db *sql.DB, cfg *settings.MySQLConfig) (maxOpen, maxIdle int, lifetime time.Duration) {
+	maxOpen, maxIdle = cfg.MaxOpenConns, cfg.MaxIdleConns
+	if maxOpen > 0 && maxIdle > maxOpen {
+		zap.L().Warn("mysql max_idle_conns is greater than max_open_conns, clamped",
+			zap.Int("max_idle_conns", maxIdle),
+			zap.Int("max_open_conns", maxOpen))
+		maxIdle = maxOpen
+	}
+	lifetime = time.Duration(cfg.ConnMaxLifetime) * time.Second
+	db.SetMaxOpenConns(maxOpen)
+	db.SetMaxIdleConns(maxIdle)
+	db.SetConnMaxLifetime(lifetime)
 	return
 }
 
 func Close() {
 	_ = db.Close()
 }
+
+// Stats 返回连接池的统计信息
+func Stats() sql.DBStats {
+	return db.Stats()
+}
+
+// Ping 检查数据库连接是否可用
+func Ping(ctx context.Context) error {
+	return db.PingContext(ctx)
+}
@@ -0,0 +1,29 @@
+package mysql
+
+import (
+	"database/sql"
+	"go-web-app/settings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyPoolSettings(t *testing.T) {
+	// sql.Open不会真正建立连接
+	db, err := sql.Open("mysql", "root:root@tcp(127.0.0.1:3306)/test")
+	require.NoError(t, err)
+	defer db.Close()
+
+	cfg := &settings.MySQLConfig{MaxOpenConns: 20, MaxIdleConns: 50, ConnMaxLifetime: 300}
+	maxOpen, maxIdle, lifetime := applyPoolSettings(db, cfg)
+	assert.Equal(t, 20, maxOpen)
+	assert.Equal(t, 20, maxIdle)
+	assert.Equal(t, 300*time.Second, lifetime)
+	assert.Equal(t, 20, db.Stats().MaxOpenConnections)
+
+	cfg = &settings.MySQLConfig{MaxOpenConns: 0, MaxIdleConns: 5}
+	_, maxIdle, _ = applyPoolSettings(db, cfg)
+	assert.Equal(t, 5, maxIdle, "max_idle_conns should not be clamped when max_open_conns is unlimited")
+}
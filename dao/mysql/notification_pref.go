@@ -0,0 +1,33 @@
+package mysql
+
+import (
+	"database/sql"
+	"go-web-app/models"
+)
+
+// GetNotificationPref 用户的通知设置，没有记录时返回默认设置
+func GetNotificationPref(uid int64) (*models.NotificationPref, error) {
+	pref := new(models.NotificationPref)
+	sqlStr := `select reply, vote, follow, mention, digest, trending, quiet_start, quiet_end, quiet_timezone
+	from notification_pref where user_id = ?`
+	err := db.Get(pref, sqlStr, uid)
+	if err == sql.ErrNoRows {
+		return models.DefaultNotificationPref(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pref, nil
+}
+
+// SaveNotificationPref 保存用户的通知设置
+func SaveNotificationPref(uid int64, pref *models.NotificationPref) (err error) {
+	sqlStr := `insert into notification_pref(user_id, reply, vote, follow, mention, digest, trending, quiet_start, quiet_end, quiet_timezone)
+	values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	on duplicate key update reply = values(reply), vote = values(vote), follow = values(follow),
+	mention = values(mention), digest = values(digest), trending = values(trending),
+	quiet_start = values(quiet_start), quiet_end = values(quiet_end), quiet_timezone = values(quiet_timezone)`
+	_, err = db.Exec(sqlStr, uid, pref.Reply, pref.Vote, pref.Follow, pref.Mention, pref.Digest, pref.Trending,
+		pref.QuietStart, pref.QuietEnd, pref.QuietTimezone)
+	return
+}
@@ -1,40 +1,304 @@
 package mysql
 
 import (
+	"context"
 	"go-web-app/models"
-	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
 
-func CreatePost(p *models.Post) (err error) {
-	sqlStr := "insert into post (post_id, title, content, author_id, community_id) values (?, ?, ?, ?, ?) "
-	_, err = db.Exec(sqlStr, p.PostID, p.Title, p.Content, p.AuthorId, p.CommunityID)
-	return
+// CreatePost 帖子和标签在同一个事务中写入，p.Slug被其他帖子使用时加上后缀，写入后p.Slug为实际使用的slug
+func CreatePost(p *models.Post) error {
+	return WithTx(context.Background(), func(tx *Tx) (err error) {
+		status := p.Status
+		if status == 0 {
+			status = models.PostStatusNormal
+		}
+		if p.Slug != "" {
+			if p.Slug, err = insertPostSlug(tx, p.PostID, p.Slug); err != nil {
+				return err
+			}
+		}
+		sqlStr := "insert into post (post_id, title, slug, content, author_id, anonymous, community_id, flair, status, publish_at) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) "
+		if _, err := tx.Exec(sqlStr, p.PostID, p.Title, p.Slug, p.Content, p.AuthorId, p.Anonymous, p.CommunityID, p.Flair, status, p.PublishAt); err != nil {
+			return err
+		}
+		if err := insertPostTags(tx, p.PostID, p.Tags); err != nil {
+			return err
+		}
+		communityIDs := p.CommunityIDs
+		if len(communityIDs) == 0 {
+			communityIDs = []int64{p.CommunityID}
+		}
+		return insertPostCommunities(tx, p.PostID, communityIDs)
+	})
 }
 
 func GetPostById(pid int64) (post *models.Post, err error) {
 	post = new(models.Post)
-	sqlStr := "select post_id, title, content, author_id, community_id, create_time from post where post_id = ?"
+	sqlStr := "select post_id, title, slug, content, author_id, anonymous, community_id, flair, status, comment_locked, comment_unlocked, version, create_time from post where post_id = ? and deleted_at is null"
 	err = db.Get(post, sqlStr, pid)
 	return
 }
 
 func GetPostList(page int64, size int64) (posts []*models.Post, err error) {
-	sqlStr := "select post_id, title, content, author_id, community_id, create_time from post order by create_time desc limit ?,?"
+	sqlStr := "select post_id, title, content, author_id, anonymous, community_id, create_time from post where deleted_at is null and status = 1 and " + publicPostFilter + " order by create_time desc limit ?,?"
 	posts = make([]*models.Post, 0, 2)
 	err = db.Select(&posts, sqlStr, (page-1)*size, size)
 	return
 }
 
-func GetPostListByIDs(ids []string) (postList []*models.Post, err error) {
-	postList = make([]*models.Post, 0, len(ids))
-	sqlStr := "select post_id, title, content, author_id, community_id, create_time from post where post_id in (?) order by FIND_IN_SET(post_id, ?)"
-	query, args, err := sqlx.In(sqlStr, ids, strings.Join(ids, ","))
+// GetPostListBefore 按id倒序取出id小于cursor的帖子，cursor为0时从最新的帖子开始，不包括私有社区的帖子
+// 多取一条用来判断是否还有下一页
+func GetPostListBefore(cursor, size int64) (posts []*models.Post, hasMore bool, err error) {
+	sqlStr := "select post_id, title, content, author_id, anonymous, community_id, create_time from post where deleted_at is null and status = 1 and (? = 0 or post_id < ?) and " + publicPostFilter + " order by post_id desc limit ?"
+	posts = make([]*models.Post, 0, size+1)
+	if err = db.Select(&posts, sqlStr, cursor, cursor, size+1); err != nil {
+		return nil, false, err
+	}
+	if int64(len(posts)) > size {
+		return posts[:size], true, nil
+	}
+	return posts, false, nil
+}
+
+// GetPostCount 未删除的帖子总数
+func GetPostCount() (count int64, err error) {
+	sqlStr := "select count(post_id) from post where deleted_at is null and status = 1"
+	err = db.Get(&count, sqlStr)
+	return
+}
+
+// GetPublicPostCount 公开社区中未删除的帖子总数，用于全站的帖子列表
+func GetPublicPostCount() (count int64, err error) {
+	sqlStr := "select count(post_id) from post where deleted_at is null and status = 1 and " + publicPostFilter
+	err = db.Get(&count, sqlStr)
+	return
+}
+
+// GetUserPostCount 用户发布的未删除的帖子数，显示在用户主页上，不包括匿名帖子
+func GetUserPostCount(uid int64) (count int64, err error) {
+	sqlStr := "select count(post_id) from post where author_id = ? and anonymous = 0 and deleted_at is null and status = 1"
+	err = db.Get(&count, sqlStr, uid)
+	return
+}
+
+// GetPostsByAuthor 用户的所有未删除的帖子，包括草稿和还没有发布的定时帖子，按创建时间顺序
+func GetPostsByAuthor(uid int64) (posts []*models.Post, err error) {
+	sqlStr := `select post_id, title, content, author_id, anonymous, community_id, status, publish_at, create_time from post
+	where author_id = ? and deleted_at is null order by create_time`
+	posts = make([]*models.Post, 0)
+	err = db.Select(&posts, sqlStr, uid)
+	return
+}
+
+// GetPostsByIDs 一次查询取出多个帖子，顺序与ids一致，已删除或隐藏的帖子会被跳过
+func GetPostsByIDs(ctx context.Context, ids []int64) ([]*models.Post, error) {
+	if len(ids) == 0 {
+		return []*models.Post{}, nil
+	}
+	sqlStr := "select post_id, title, slug, content, author_id, anonymous, community_id, flair, create_time from post where post_id in (?) and deleted_at is null and status = 1"
+	query, args, err := sqlx.In(sqlStr, ids)
 	if err != nil {
 		return nil, err
 	}
-	query = db.Rebind(query)
-	err = db.Select(&postList, query, args...)
+	rows := make([]*models.Post, 0, len(ids))
+	if err = db.SelectContext(ctx, &rows, db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]*models.Post, len(rows))
+	for _, post := range rows {
+		byID[post.PostID] = post
+	}
+	posts := make([]*models.Post, 0, len(rows))
+	for _, id := range ids {
+		if post, ok := byID[id]; ok {
+			posts = append(posts, post)
+		}
+	}
+	return posts, nil
+}
+
+// SearchPosts 在标题和内容中全文检索，按相关度排序
+// 使用自然语言模式，query中的特殊字符不会被当作操作符解析
+func SearchPosts(ctx context.Context, query string, page, size int64) (posts []*models.Post, err error) {
+	sqlStr := `select post_id, title, content, author_id, anonymous, community_id, create_time from post
+	where match(title, content) against (? in natural language mode) and deleted_at is null and status = 1
+	and ` + publicPostFilter + `
+	order by match(title, content) against (? in natural language mode) desc
+	limit ?,?`
+	posts = make([]*models.Post, 0, size)
+	err = db.SelectContext(ctx, &posts, sqlStr, query, query, (page-1)*size, size)
 	return
 }
+
+// SearchPostCount 全文检索命中的帖子总数
+func SearchPostCount(ctx context.Context, query string) (count int64, err error) {
+	sqlStr := `select count(post_id) from post
+	where match(title, content) against (? in natural language mode) and deleted_at is null and status = 1
+	and ` + publicPostFilter
+	err = db.GetContext(ctx, &count, sqlStr, query)
+	return
+}
+
+// UpdatePostWithCommunities 编辑帖子并把版本号加1，version不为0时只有当前版本等于version才更新
+// communityIDs不为空时在同一个事务中替换帖子所属的社区；没有更新时updated为false，社区也不修改，调用方重新查询当前的版本
+func UpdatePostWithCommunities(pid, version int64, title, content string, communityIDs []int64) (updated bool, err error) {
+	err = WithTx(context.Background(), func(tx *Tx) error {
+		sqlStr := "update post set title = ?, content = ?, version = version + 1 where post_id = ? and deleted_at is null and (? = 0 or version = ?)"
+		ret, err := tx.Exec(sqlStr, title, content, pid, version, version)
+		if err != nil {
+			return err
+		}
+		n, err := ret.RowsAffected()
+		if err != nil || n == 0 {
+			return err
+		}
+		updated = true
+		if len(communityIDs) == 0 {
+			return nil
+		}
+		return setPostCommunitiesTx(tx, pid, communityIDs)
+	})
+	return updated && err == nil, err
+}
+
+// SetCommentLocked 锁定或解锁帖子的评论，解锁后不再按发布时间自动关闭
+func SetCommentLocked(pid int64, locked bool) (err error) {
+	sqlStr := "update post set comment_locked = ?, comment_unlocked = ? where post_id = ? and deleted_at is null"
+	_, err = db.Exec(sqlStr, locked, !locked, pid)
+	return
+}
+
+// DeletePost 软删除帖子
+func DeletePost(pid int64) (err error) {
+	sqlStr := "update post set deleted_at = now() where post_id = ? and deleted_at is null"
+	_, err = db.Exec(sqlStr, pid)
+	return
+}
+
+// RestorePost 恢复被软删除的帖子
+func RestorePost(pid int64) (err error) {
+	sqlStr := "update post set deleted_at = null where post_id = ? and deleted_at is not null"
+	ret, err := db.Exec(sqlStr, pid)
+	if err != nil {
+		return
+	}
+	n, err := ret.RowsAffected()
+	if err != nil {
+		return
+	}
+	if n == 0 {
+		return ErrorInvalidID
+	}
+	return
+}
+
+// SetPostStatus 修改帖子状态，隐藏的帖子不会出现在列表中
+func SetPostStatus(pid int64, status int32) (err error) {
+	sqlStr := "update post set status = ? where post_id = ? and deleted_at is null"
+	_, err = db.Exec(sqlStr, status, pid)
+	return
+}
+
+// GetDuePosts 到了发布时间的定时帖子
+func GetDuePosts(limit int) (posts []*models.Post, err error) {
+	sqlStr := `select post_id, title, content, author_id, anonymous, community_id, status, publish_at, create_time from post
+	where status = 3 and publish_at <= now() and deleted_at is null
+	order by publish_at limit ?`
+	err = db.Select(&posts, sqlStr, limit)
+	return
+}
+
+// PublishPost 把状态为from的定时帖子或草稿改为已发布，发布时间作为帖子的创建时间
+// 多个请求同时发布同一个帖子时只有一个会返回true
+func PublishPost(pid int64, from int32) (published bool, err error) {
+	sqlStr := "update post set status = 1, create_time = now() where post_id = ? and status = ? and deleted_at is null"
+	ret, err := db.Exec(sqlStr, pid, from)
+	if err != nil {
+		return false, err
+	}
+	n, err := ret.RowsAffected()
+	return n > 0, err
+}
+
+// UnpublishPost 发布之后加入列表失败时把帖子改回原来的状态和创建时间
+func UnpublishPost(pid int64, to int32, createTime time.Time) error {
+	sqlStr := "update post set status = ?, create_time = ? where post_id = ? and status = 1 and deleted_at is null"
+	_, err := db.Exec(sqlStr, to, createTime, pid)
+	return err
+}
+
+// GetScheduledPosts 用户还没有发布的定时帖子，按发布时间排序
+func GetScheduledPosts(uid int64) (posts []*models.Post, err error) {
+	sqlStr := `select post_id, title, content, author_id, anonymous, community_id, status, publish_at, create_time from post
+	where author_id = ? and status = 3 and deleted_at is null
+	order by publish_at`
+	posts = make([]*models.Post, 0)
+	err = db.Select(&posts, sqlStr, uid)
+	return
+}
+
+// CancelScheduledPost 取消还没有发布的定时帖子，帖子不存在或已经发布时返回ErrorInvalidID
+func CancelScheduledPost(uid, pid int64) (err error) {
+	sqlStr := "update post set deleted_at = now() where post_id = ? and author_id = ? and status = 3 and deleted_at is null"
+	ret, err := db.Exec(sqlStr, pid, uid)
+	if err != nil {
+		return
+	}
+	n, err := ret.RowsAffected()
+	if err == nil && n == 0 {
+		err = ErrorInvalidID
+	}
+	return
+}
+
+// GetDraftPosts 用户的草稿，最近编辑的在前
+func GetDraftPosts(uid int64) (posts []*models.Post, err error) {
+	sqlStr := `select post_id, title, content, author_id, anonymous, community_id, status, create_time from post
+	where author_id = ? and status = 4 and deleted_at is null
+	order by update_time desc`
+	posts = make([]*models.Post, 0)
+	err = db.Select(&posts, sqlStr, uid)
+	return
+}
+
+// StreamPosts 逐行读取符合条件的帖子，不会把所有结果读进内存，fn返回错误时停止
+// ctx取消后(例如客户端断开)查询也会停止
+func StreamPosts(ctx context.Context, p *models.ParamPostExport, fn func(post *models.Post) error) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	sqlStr := "select p.post_id, p.title, p.content, p.author_id, p.community_id, p.create_time from post p"
+	args := make([]interface{}, 0, 4)
+	if p.CommunityID != 0 {
+		sqlStr += " join post_community pc on pc.post_id = p.post_id and pc.community_id = ?"
+		args = append(args, p.CommunityID)
+	}
+	sqlStr += " where p.deleted_at is null and p.status = ?"
+	args = append(args, models.PostStatusNormal)
+	if !p.From.IsZero() {
+		sqlStr += " and p.create_time >= ?"
+		args = append(args, p.From)
+	}
+	if !p.To.IsZero() {
+		sqlStr += " and p.create_time < ?"
+		args = append(args, p.To)
+	}
+	sqlStr += " order by p.post_id"
+	rows, err := db.QueryxContext(ctx, sqlStr, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		post := new(models.Post)
+		if err = rows.StructScan(post); err != nil {
+			return err
+		}
+		if err = fn(post); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
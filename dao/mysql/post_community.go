@@ -0,0 +1,74 @@
+package mysql
+
+import (
+	"context"
+	"go-web-app/models"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// insertPostCommunities 在事务中写入帖子所属的社区
+func insertPostCommunities(tx *timeoutTx, postID int64, communityIDs []int64) error {
+	for _, cid := range communityIDs {
+		sqlStr := "insert ignore into post_community (post_id, community_id) values (?, ?)"
+		if _, err := tx.Exec(sqlStr, postID, cid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetPostCommunities 返回帖子所属的所有社区，主社区在前
+func GetPostCommunities(pid int64) (ids []int64, err error) {
+	sqlStr := `select pc.community_id from post_community pc
+	join post p on p.post_id = pc.post_id
+	where pc.post_id = ? order by pc.community_id = p.community_id desc, pc.id`
+	ids = make([]int64, 0)
+	err = db.Select(&ids, sqlStr, pid)
+	return
+}
+
+// IsPostInCommunity 帖子是否发布到了该社区
+func IsPostInCommunity(pid, communityID int64) (bool, error) {
+	var count int64
+	err := db.Get(&count, "select count(*) from post_community where post_id = ? and community_id = ?", pid, communityID)
+	return count > 0, err
+}
+
+// SetPostCommunities 替换帖子所属的社区，communityIDs的第一个作为主社区
+func SetPostCommunities(pid int64, communityIDs []int64) error {
+	if len(communityIDs) == 0 {
+		return ErrorInvalidID
+	}
+	return WithTx(context.Background(), func(tx *Tx) error {
+		return setPostCommunitiesTx(tx, pid, communityIDs)
+	})
+}
+
+// setPostCommunitiesTx 在事务中替换帖子所属的社区，第一个作为主社区
+func setPostCommunitiesTx(tx *Tx, pid int64, communityIDs []int64) error {
+	query, args, err := sqlx.In("delete from post_community where post_id = ? and community_id not in (?)", pid, communityIDs)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(tx.Rebind(query), args...); err != nil {
+		return err
+	}
+	if err = insertPostCommunities(tx, pid, communityIDs); err != nil {
+		return err
+	}
+	_, err = tx.Exec("update post set community_id = ? where post_id = ?", communityIDs[0], pid)
+	return err
+}
+
+// GetActiveCommunityIDs 按since之后发布的帖子数从多到少返回公开社区的id
+func GetActiveCommunityIDs(since time.Time, limit int) (ids []int64, err error) {
+	sqlStr := `select pc.community_id from post_community pc
+	join post p on p.post_id = pc.post_id
+	where p.create_time >= ? and p.status = ? and p.deleted_at is null and p.` + publicPostFilter + `
+	group by pc.community_id order by count(*) desc limit ?`
+	ids = make([]int64, 0, limit)
+	err = db.Select(&ids, sqlStr, since, models.PostStatusNormal, limit)
+	return
+}
@@ -0,0 +1,26 @@
+package mysql
+
+import (
+	"context"
+	"go-web-app/models"
+)
+
+// GetPostScores 所有已发布的帖子保存的分数，用于重建redis中的排序
+func GetPostScores() (list []*models.PostScore, err error) {
+	sqlStr := `select post_id, score, coalesce(publish_at, create_time) as publish_time from post
+	where deleted_at is null and status = 1`
+	err = db.Select(&list, sqlStr)
+	return
+}
+
+// SavePostScores 把redis中的分数写回数据库
+func SavePostScores(scores map[int64]float64) error {
+	return WithTx(context.Background(), func(tx *Tx) error {
+		for pid, score := range scores {
+			if _, err := tx.Exec("update post set score = ? where post_id = ?", score, pid); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,76 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"go-web-app/models"
+	"strconv"
+)
+
+var ErrorSlugConflict = errors.New("Slug already in use. ")
+
+// slugCandidates base已经被其他帖子使用时，依次加上帖子id的36进制的后6位和完整的id
+func slugCandidates(pid int64, base string) []string {
+	id := strconv.FormatInt(pid, 36)
+	candidates := []string{base}
+	if len(id) > 6 {
+		candidates = append(candidates, base+"-"+id[len(id)-6:])
+	}
+	return append(candidates, base+"-"+id)
+}
+
+// insertPostSlug 为帖子保存一个没有被其他帖子使用的slug，帖子以前用过的slug可以直接复用
+func insertPostSlug(tx *Tx, pid int64, base string) (string, error) {
+	for _, slug := range slugCandidates(pid, base) {
+		ret, err := tx.Exec("insert ignore into post_slug (slug, post_id) values (?, ?)", slug, pid)
+		if err != nil {
+			return "", err
+		}
+		if n, err := ret.RowsAffected(); err != nil || n > 0 {
+			return slug, err
+		}
+		var owner int64
+		if err = tx.Get(&owner, "select post_id from post_slug where slug = ?", slug); err != nil && err != sql.ErrNoRows {
+			return "", err
+		}
+		if owner == pid {
+			return slug, nil
+		}
+	}
+	return "", ErrorSlugConflict
+}
+
+// UpdatePostSlug 把帖子当前的slug改为由base生成的slug，以前的slug保留下来用于重定向，返回新的slug
+func UpdatePostSlug(pid int64, base string) (slug string, err error) {
+	err = WithTx(context.Background(), func(tx *Tx) (err error) {
+		if slug, err = insertPostSlug(tx, pid, base); err != nil {
+			return
+		}
+		_, err = tx.Exec("update post set slug = ? where post_id = ?", slug, pid)
+		return
+	})
+	return
+}
+
+// GetPostBySlug 根据帖子用过的任意一个slug查找帖子，返回帖子id和当前的slug，找不到时返回sql.ErrNoRows
+func GetPostBySlug(slug string) (pid int64, canonical string, err error) {
+	var row struct {
+		PostID int64  `db:"post_id"`
+		Slug   string `db:"slug"`
+	}
+	sqlStr := `select p.post_id, p.slug from post_slug s
+	join post p on p.post_id = s.post_id
+	where s.slug = ? and p.deleted_at is null`
+	if err = db.Get(&row, sqlStr, slug); err != nil {
+		return 0, "", err
+	}
+	return row.PostID, row.Slug, nil
+}
+
+// GetPostsWithoutSlug 还没有生成slug的帖子，只返回id和标题，用于补全旧帖子的slug
+func GetPostsWithoutSlug(limit int) (posts []*models.Post, err error) {
+	sqlStr := "select post_id, title from post where slug = '' order by post_id limit ?"
+	err = db.Select(&posts, sqlStr, limit)
+	return
+}
@@ -4,6 +4,9 @@ import (
 	"go-web-app/models"
 	"go-web-app/settings"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func init() {
@@ -36,3 +39,16 @@ func TestCreatePost(t *testing.T) {
 	}
 	t.Logf("CreatePost insert record into mysql success")
 }
+
+func TestGetUserPostCountExcludesAnonymous(t *testing.T) {
+	const uid = 900201
+	cleanup := func() { db.MustExec("delete from post where author_id = ?", uid) }
+	cleanup()
+	defer cleanup()
+
+	db.MustExec("insert into post(post_id, title, content, author_id, community_id) values (900211, 't', 'c', ?, 1)", uid)
+	db.MustExec("insert into post(post_id, title, content, author_id, community_id, anonymous) values (900212, 't', 'c', ?, 1, 1)", uid)
+	count, err := GetUserPostCount(uid)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
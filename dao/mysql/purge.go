@@ -0,0 +1,49 @@
+package mysql
+
+import (
+	"context"
+	"go-web-app/models"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// GetPurgeablePosts 在before之前被软删除的帖子，最多limit个，只返回post_id和author_id
+func GetPurgeablePosts(before time.Time, limit int) (posts []*models.Post, err error) {
+	sqlStr := "select post_id, author_id from post where deleted_at is not null and deleted_at < ? order by deleted_at limit ?"
+	posts = make([]*models.Post, 0, limit)
+	err = db.Select(&posts, sqlStr, before, limit)
+	return
+}
+
+// PurgePosts 彻底删除帖子和关联的标签、社区、收藏记录，只删除仍处于软删除状态的帖子
+// 返回实际删除的帖子id
+func PurgePosts(ids []int64) (deleted []int64, err error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	err = WithTx(context.Background(), func(tx *Tx) (err error) {
+		// 先删除帖子，查询和删除之间被恢复的帖子不会被删除，也不会删除它的关联记录
+		query, args, err := sqlx.In("select post_id from post where post_id in (?) and deleted_at is not null for update", ids)
+		if err != nil {
+			return
+		}
+		if err = tx.Select(&deleted, tx.Rebind(query), args...); err != nil || len(deleted) == 0 {
+			return
+		}
+		for _, table := range []string{"post_tag", "post_community", "bookmark", "post_slug", "post"} {
+			query, args, err = sqlx.In("delete from "+table+" where post_id in (?)", deleted)
+			if err != nil {
+				return
+			}
+			if _, err = tx.Exec(tx.Rebind(query), args...); err != nil {
+				return
+			}
+		}
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
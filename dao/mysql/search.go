@@ -0,0 +1,41 @@
+package mysql
+
+import (
+	"context"
+	"go-web-app/models"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SearchUsersByPrefix 按用户名前缀查找正常状态的用户，已注销、封禁和合并的用户不出现在结果中
+func SearchUsersByPrefix(ctx context.Context, prefix string, offset, limit int64) (users []*models.AuthorProfile, err error) {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix)
+	sqlStr := `select user_id, username, avatar, karma from user
+	where username like ? and deactivated_at is null and banned_at is null and merged_into is null
+	order by username limit ?,?`
+	users = make([]*models.AuthorProfile, 0, limit)
+	err = db.SelectContext(ctx, &users, sqlStr, escaped+"%", offset, limit)
+	return
+}
+
+// GetPublicPostTitles 返回公开可见的帖子的标题，已删除、隐藏、未发布和私有社区的帖子不在结果中
+func GetPublicPostTitles(ctx context.Context, ids []int64) (map[int64]string, error) {
+	titles := make(map[int64]string, len(ids))
+	if len(ids) == 0 {
+		return titles, nil
+	}
+	sqlStr := "select post_id, title from post where post_id in (?) and deleted_at is null and status = 1 and " + publicPostFilter
+	query, args, err := sqlx.In(sqlStr, ids)
+	if err != nil {
+		return nil, err
+	}
+	var rows []*models.Post
+	if err = db.SelectContext(ctx, &rows, db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	for _, post := range rows {
+		titles[post.PostID] = post.Title
+	}
+	return titles, nil
+}
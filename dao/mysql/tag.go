@@ -0,0 +1,67 @@
+package mysql
+
+import (
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// insertPostTags 在事务中写入帖子的标签，不存在的标签先创建
+func insertPostTags(tx *timeoutTx, postID int64, tags []string) error {
+	for _, name := range tags {
+		if _, err := tx.Exec("insert ignore into tag (name) values (?)", name); err != nil {
+			return err
+		}
+		sqlStr := "insert ignore into post_tag (post_id, tag_id) select ?, id from tag where name = ?"
+		if _, err := tx.Exec(sqlStr, postID, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetPostTags 返回帖子的标签，按名称排序
+func GetPostTags(pid int64) (tags []string, err error) {
+	sqlStr := `select t.name from post_tag pt
+	join tag t on t.id = pt.tag_id
+	where pt.post_id = ? order by t.name`
+	tags = make([]string, 0)
+	err = db.Select(&tags, sqlStr, pid)
+	return
+}
+
+// GetTagsByPostIDs 批量查询帖子的标签，key为帖子id
+func GetTagsByPostIDs(ids []int64) (map[int64][]string, error) {
+	tags := make(map[int64][]string, len(ids))
+	if len(ids) == 0 {
+		return tags, nil
+	}
+	sqlStr := `select pt.post_id, t.name from post_tag pt
+	join tag t on t.id = pt.tag_id
+	where pt.post_id in (?) order by t.name`
+	query, args, err := sqlx.In(sqlStr, ids)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		PostID int64  `db:"post_id"`
+		Name   string `db:"name"`
+	}
+	if err = db.Select(&rows, db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		tags[row.PostID] = append(tags[row.PostID], row.Name)
+	}
+	return tags, nil
+}
+
+// SearchTagsByPrefix 按前缀查找标签，用于输入时的自动补全
+func SearchTagsByPrefix(prefix string, limit int64) (tags []string, err error) {
+	// 转义like的通配符，前缀中的%和_按普通字符匹配
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix)
+	sqlStr := "select name from tag where name like ? order by name limit ?"
+	tags = make([]string, 0, limit)
+	err = db.Select(&tags, sqlStr, escaped+"%", limit)
+	return
+}
@@ -0,0 +1,47 @@
+package mysql
+
+import (
+	"context"
+	"go-web-app/models"
+)
+
+// FollowTag 关注标签，已经关注时返回changed=false
+// afterInsert 在新关注时、事务提交前执行，返回错误时回滚
+func FollowTag(uid int64, tag string, afterInsert func() error) (changed bool, err error) {
+	return execTagFollowTx("insert ignore into tag_follow(user_id, tag) values (?, ?)", uid, tag, afterInsert)
+}
+
+// UnfollowTag 取消关注标签，没有关注时返回changed=false
+func UnfollowTag(uid int64, tag string, afterDelete func() error) (changed bool, err error) {
+	return execTagFollowTx("delete from tag_follow where user_id = ? and tag = ?", uid, tag, afterDelete)
+}
+
+func execTagFollowTx(sqlStr string, uid int64, tag string, onChange func() error) (changed bool, err error) {
+	err = WithTx(context.Background(), func(tx *Tx) error {
+		ret, err := tx.Exec(sqlStr, uid, tag)
+		if err != nil {
+			return err
+		}
+		n, err := ret.RowsAffected()
+		if err != nil || n == 0 {
+			return err
+		}
+		changed = true
+		return onChange()
+	})
+	return changed && err == nil, err
+}
+
+// GetFollowedTags 用户关注的标签，按关注时间排序
+func GetFollowedTags(uid int64) (tags []string, err error) {
+	tags = make([]string, 0)
+	err = db.Select(&tags, "select tag from tag_follow where user_id = ? order by create_time", uid)
+	return
+}
+
+// GetAllTagFollows 所有的标签关注关系，用于重建redis中的集合
+func GetAllTagFollows() (follows []*models.TagFollow, err error) {
+	follows = make([]*models.TagFollow, 0)
+	err = db.Select(&follows, "select user_id, tag from tag_follow")
+	return
+}
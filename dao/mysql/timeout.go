@@ -0,0 +1,104 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"go-web-app/pkg/dbtimeout"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// queryTimeout 每条语句的默认超时，事务中的语句也单独计算，Init时从mysql.query_timeout读取
+var queryTimeout time.Duration
+
+// withTimeout 返回带默认超时的ctx，可以用dbtimeout.WithTimeout覆盖
+func withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return dbtimeout.Context(ctx, queryTimeout)
+}
+
+// timeoutDB 不带ctx的查询也使用默认超时，卡住的mysql返回错误而不是一直阻塞请求
+type timeoutDB struct {
+	*sqlx.DB
+}
+
+func (d *timeoutDB) Get(dest interface{}, query string, args ...interface{}) error {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	return d.DB.GetContext(ctx, dest, query, args...)
+}
+
+func (d *timeoutDB) Select(dest interface{}, query string, args ...interface{}) error {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	return d.DB.SelectContext(ctx, dest, query, args...)
+}
+
+func (d *timeoutDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	return d.ExecContext(ctx, query, args...)
+}
+
+// SelectContext 带ctx的查询也加上默认超时，ctx中已有更早的截止时间时以ctx为准
+func (d *timeoutDB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return d.DB.SelectContext(ctx, dest, query, args...)
+}
+
+func (d *timeoutDB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return d.DB.GetContext(ctx, dest, query, args...)
+}
+
+// beginTx 开始事务，事务本身只随ctx取消，其中的每条语句单独使用默认超时，通过WithTx使用
+// 多条语句的事务共用一个超时时，越靠后的语句越容易超时
+func (d *timeoutDB) beginTx(ctx context.Context) (*timeoutTx, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	tx, err := d.BeginTxx(ctx, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &timeoutTx{Tx: tx, ctx: ctx, cancel: cancel}, nil
+}
+
+// timeoutTx 事务中的查询以开始事务时的ctx为父ctx，各自加上默认超时，提交或回滚后释放
+type timeoutTx struct {
+	*sqlx.Tx
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (t *timeoutTx) Get(dest interface{}, query string, args ...interface{}) error {
+	ctx, cancel := withTimeout(t.ctx)
+	defer cancel()
+	return t.GetContext(ctx, dest, query, args...)
+}
+
+func (t *timeoutTx) Select(dest interface{}, query string, args ...interface{}) error {
+	ctx, cancel := withTimeout(t.ctx)
+	defer cancel()
+	return t.SelectContext(ctx, dest, query, args...)
+}
+
+func (t *timeoutTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := withTimeout(t.ctx)
+	defer cancel()
+	return t.ExecContext(ctx, query, args...)
+}
+
+func (t *timeoutTx) Commit() error {
+	defer t.cancel()
+	return t.Tx.Commit()
+}
+
+func (t *timeoutTx) Rollback() error {
+	defer t.cancel()
+	return t.Tx.Rollback()
+}
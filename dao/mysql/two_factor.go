@@ -0,0 +1,138 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"go-web-app/models"
+	"go-web-app/pkg/secretbox"
+	"go-web-app/settings"
+	"strconv"
+)
+
+// totpBox 使用two_factor.secret_key加密密钥，没有配置时返回secretbox.ErrNoKey
+func totpBox() (*secretbox.Box, error) {
+	var key string
+	if cfg := settings.Current().TwoFactorConfig; cfg != nil {
+		key = cfg.SecretKey
+	}
+	return secretbox.New(key)
+}
+
+// 附加数据为用户id，密文复制到其他用户的记录中时无法解密
+func totpAdditional(uid int64) []byte {
+	return []byte(strconv.FormatInt(uid, 10))
+}
+
+// SaveTOTPSecret 加密保存还没有开启的密钥，已经开启时不修改
+func SaveTOTPSecret(uid int64, secret string) error {
+	box, err := totpBox()
+	if err != nil {
+		return err
+	}
+	sealed, err := box.Seal([]byte(secret), totpAdditional(uid))
+	if err != nil {
+		return err
+	}
+	sqlStr := `insert into user_totp(user_id, secret, enabled) values (?, ?, 0)
+	on duplicate key update secret = if(enabled, secret, values(secret))`
+	_, err = db.Exec(sqlStr, uid, sealed)
+	return err
+}
+
+// GetTOTP 用户的两步验证设置，没有设置过时返回nil
+func GetTOTP(uid int64) (*models.UserTOTP, error) {
+	var row struct {
+		Secret  string `db:"secret"`
+		Enabled bool   `db:"enabled"`
+	}
+	err := db.Get(&row, "select secret, enabled from user_totp where user_id = ?", uid)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	box, err := totpBox()
+	if err != nil {
+		return nil, err
+	}
+	secret, err := box.Open(row.Secret, totpAdditional(uid))
+	if err != nil {
+		return nil, err
+	}
+	return &models.UserTOTP{UserID: uid, Secret: string(secret), Enabled: row.Enabled}, nil
+}
+
+// TOTPEnabled 只查询是否开启，不解密密钥，登录时用于判断是否需要验证码
+func TOTPEnabled(uid int64) (bool, error) {
+	var enabled bool
+	err := db.Get(&enabled, "select enabled from user_totp where user_id = ?", uid)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return enabled, err
+}
+
+// EnableTOTP 开启两步验证并替换恢复码，没有待开启的密钥时返回false
+func EnableTOTP(uid int64, codeHashes []string) (enabled bool, err error) {
+	err = WithTx(context.Background(), func(tx *Tx) error {
+		res, err := tx.Exec("update user_totp set enabled = 1 where user_id = ? and enabled = 0", uid)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil || n == 0 {
+			return err
+		}
+		enabled = true
+		return replaceRecoveryCodesTx(tx, uid, codeHashes)
+	})
+	return enabled, err
+}
+
+// DisableTOTP 关闭两步验证，删除密钥和恢复码
+func DisableTOTP(uid int64) error {
+	return WithTx(context.Background(), func(tx *Tx) error {
+		if _, err := tx.Exec("delete from user_totp where user_id = ?", uid); err != nil {
+			return err
+		}
+		_, err := tx.Exec("delete from user_recovery_code where user_id = ?", uid)
+		return err
+	})
+}
+
+// ReplaceRecoveryCodes 重新生成恢复码，之前的恢复码全部失效
+func ReplaceRecoveryCodes(uid int64, codeHashes []string) error {
+	return WithTx(context.Background(), func(tx *Tx) error {
+		return replaceRecoveryCodesTx(tx, uid, codeHashes)
+	})
+}
+
+func replaceRecoveryCodesTx(tx *Tx, uid int64, codeHashes []string) error {
+	if _, err := tx.Exec("delete from user_recovery_code where user_id = ?", uid); err != nil {
+		return err
+	}
+	for _, h := range codeHashes {
+		if _, err := tx.Exec("insert into user_recovery_code(user_id, code_hash) values (?, ?)", uid, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseRecoveryCode 标记恢复码已经使用，不存在或者已经用过时返回false
+func UseRecoveryCode(uid int64, codeHash string) (bool, error) {
+	sqlStr := "update user_recovery_code set used_time = now() where user_id = ? and code_hash = ? and used_time is null"
+	res, err := db.Exec(sqlStr, uid, codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// CountRecoveryCodes 剩余可用的恢复码数量
+func CountRecoveryCodes(uid int64) (n int, err error) {
+	err = db.Get(&n, "select count(*) from user_recovery_code where user_id = ? and used_time is null", uid)
+	return
+}
@@ -0,0 +1,28 @@
+package mysql
+
+import "context"
+
+// Tx WithTx传给回调的事务，每条语句单独使用默认超时
+type Tx = timeoutTx
+
+// WithTx 在一个事务中执行fn，fn返回nil时提交，返回错误或panic时回滚，panic会继续向上抛出
+// fn中不要提交或回滚事务，也不要在fn返回之后继续使用tx
+func WithTx(ctx context.Context, fn func(tx *Tx) error) (err error) {
+	tx, err := db.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	if err = fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,141 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// txDriver 只支持事务的假驱动，记录提交和回滚的次数，不需要连接mysql
+type txDriver struct {
+	mu        sync.Mutex
+	commits   int
+	rollbacks int
+	deadlines []time.Time
+}
+
+func (d *txDriver) Open(string) (driver.Conn, error) { return &txConn{d: d}, nil }
+
+type txConn struct{ d *txDriver }
+
+func (c *txConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *txConn) Close() error                        { return nil }
+func (c *txConn) Begin() (driver.Tx, error)           { return &fakeTx{d: c.d}, nil }
+
+// ExecContext 记录每条语句的截止时间
+func (c *txConn) ExecContext(ctx context.Context, _ string, _ []driver.NamedValue) (driver.Result, error) {
+	deadline, _ := ctx.Deadline()
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.deadlines = append(c.d.deadlines, deadline)
+	return driver.RowsAffected(1), nil
+}
+
+type fakeTx struct{ d *txDriver }
+
+func (t *fakeTx) Commit() error {
+	t.d.mu.Lock()
+	defer t.d.mu.Unlock()
+	t.d.commits++
+	return nil
+}
+
+func (t *fakeTx) Rollback() error {
+	t.d.mu.Lock()
+	defer t.d.mu.Unlock()
+	t.d.rollbacks++
+	return nil
+}
+
+// useTxDriver 把包里的db换成假驱动，测试结束后恢复
+func useTxDriver(t *testing.T) *txDriver {
+	d := &txDriver{}
+	name := "txtest-" + t.Name()
+	sql.Register(name, d)
+	sqlDB, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := db
+	db = &timeoutDB{sqlx.NewDb(sqlDB, "mysql")}
+	t.Cleanup(func() {
+		db = old
+		sqlDB.Close()
+	})
+	return d
+}
+
+func TestWithTxCommit(t *testing.T) {
+	d := useTxDriver(t)
+	if err := WithTx(context.Background(), func(tx *Tx) error { return nil }); err != nil {
+		t.Fatalf("WithTx() = %v, want nil", err)
+	}
+	if d.commits != 1 || d.rollbacks != 0 {
+		t.Fatalf("commits = %d, rollbacks = %d; want 1, 0", d.commits, d.rollbacks)
+	}
+}
+
+func TestWithTxRollbackOnError(t *testing.T) {
+	d := useTxDriver(t)
+	want := errors.New("boom")
+	if err := WithTx(context.Background(), func(tx *Tx) error { return want }); err != want {
+		t.Fatalf("WithTx() = %v, want %v", err, want)
+	}
+	if d.commits != 0 || d.rollbacks != 1 {
+		t.Fatalf("commits = %d, rollbacks = %d; want 0, 1", d.commits, d.rollbacks)
+	}
+	if n := db.Stats().InUse; n != 0 {
+		t.Fatalf("connections in use = %d, want 0", n)
+	}
+}
+
+func TestWithTxRollbackOnPanic(t *testing.T) {
+	d := useTxDriver(t)
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Fatalf("recover() = %v, want the original panic", p)
+			}
+		}()
+		_ = WithTx(context.Background(), func(tx *Tx) error { panic("boom") })
+	}()
+	if d.commits != 0 || d.rollbacks != 1 {
+		t.Fatalf("commits = %d, rollbacks = %d; want 0, 1", d.commits, d.rollbacks)
+	}
+	// 回滚后连接回到连接池，不会泄漏
+	if n := db.Stats().InUse; n != 0 {
+		t.Fatalf("connections in use = %d, want 0", n)
+	}
+}
+
+func TestWithTxStatementTimeout(t *testing.T) {
+	d := useTxDriver(t)
+	old := queryTimeout
+	queryTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { queryTimeout = old })
+
+	err := WithTx(context.Background(), func(tx *Tx) error {
+		if _, err := tx.Exec("update a"); err != nil {
+			return err
+		}
+		// 前面的语句用掉的时间不影响后面的语句
+		time.Sleep(60 * time.Millisecond)
+		_, err := tx.Exec("update b")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WithTx() = %v, want nil", err)
+	}
+	if d.commits != 1 || len(d.deadlines) != 2 {
+		t.Fatalf("commits = %d, statements = %d; want 1, 2", d.commits, len(d.deadlines))
+	}
+	if !d.deadlines[1].After(d.deadlines[0].Add(50 * time.Millisecond)) {
+		t.Fatalf("deadlines = %v, want a separate timeout for each statement", d.deadlines)
+	}
+}
@@ -1,10 +1,13 @@
 package mysql
 
 import (
+	"context"
 	"crypto/md5"
 	"database/sql"
 	"encoding/hex"
 	"go-web-app/models"
+
+	"github.com/jmoiron/sqlx"
 )
 
 const secret = "hanmufu.com"
@@ -21,12 +24,55 @@ func CheckUserExist(username string) (err error) {
 	return
 }
 
-func InsertUser(user *models.User) (err error) {
+func CheckEmailExist(email string) (err error) {
+	sqlStr := "select count(user_id) from user where email = ?"
+	var count int
+	if err := db.Get(&count, sqlStr, email); err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrorEmailExist
+	}
+	return
+}
+
+// InsertUser 在一个事务中创建用户并加入communityIDs中仍然存在的社区，见insertUserTx
+func InsertUser(user *models.User, communityIDs []int64, afterJoin func(joined []int64) error) error {
 	// encrypt password
 	password := encryptPassword(user.Password)
-	sqlStr := "insert into user(user_id, username, password) values (?,?,?)"
-	_, err = db.Exec(sqlStr, user.UserID, user.Username, password)
-	return
+	sqlStr := "insert into user(user_id, username, password, email) values (?,?,?,?)"
+	return insertUserTx(user.UserID, communityIDs, afterJoin, sqlStr,
+		user.UserID, user.Username, password, sql.NullString{String: user.Email, Valid: user.Email != ""})
+}
+
+// insertUserTx 执行创建用户的sqlStr，然后加入communityIDs中存在的社区，不存在的社区跳过
+// afterJoin 的参数为实际加入的社区，在事务提交前执行，返回错误时回滚，没有加入任何社区时不执行
+func insertUserTx(userID int64, communityIDs []int64, afterJoin func(joined []int64) error, sqlStr string, args ...interface{}) error {
+	return WithTx(context.Background(), func(tx *Tx) error {
+		if _, err := tx.Exec(sqlStr, args...); err != nil {
+			return err
+		}
+		if len(communityIDs) == 0 {
+			return nil
+		}
+		query, qargs, err := sqlx.In("select community_id from community where community_id in (?)", communityIDs)
+		if err != nil {
+			return err
+		}
+		var joined []int64
+		if err = tx.Select(&joined, tx.Rebind(query), qargs...); err != nil {
+			return err
+		}
+		for _, cid := range joined {
+			if _, err = tx.Exec("insert ignore into community_member(user_id, community_id) values (?, ?)", userID, cid); err != nil {
+				return err
+			}
+		}
+		if len(joined) > 0 && afterJoin != nil {
+			return afterJoin(joined)
+		}
+		return nil
+	})
 }
 
 func encryptPassword(oPassword string) string {
@@ -37,7 +83,7 @@ func encryptPassword(oPassword string) string {
 
 func Login(user *models.User) (err error) {
 	oPassword := user.Password
-	sqlStr := "select user_id, username, password from user where username=?"
+	sqlStr := "select user_id, username, password, email_verified, deactivated_at is not null as deactivated, banned_at is not null as banned from user where username=?"
 	err = db.Get(user, sqlStr, user.Username)
 	if err == sql.ErrNoRows {
 		return ErrorUserNotExist
@@ -54,7 +100,259 @@ func Login(user *models.User) (err error) {
 
 func GetUserByID(uid int64) (user *models.User, err error) {
 	user = new(models.User)
-	sqlStr := "select user_id, username from user where user_id = ?"
+	sqlStr := `select user_id, username, email_verified, deactivated_at is not null as deactivated, banned_at is not null as banned
+	from user where user_id = ?`
+	err = db.Get(user, sqlStr, uid)
+	return
+}
+
+// GetUsersByIDs 批量查询用户，key为用户id，不存在的用户不在结果中
+func GetUsersByIDs(ctx context.Context, ids []int64) (map[int64]*models.User, error) {
+	users := make(map[int64]*models.User, len(ids))
+	if len(ids) == 0 {
+		return users, nil
+	}
+	sqlStr := "select user_id, username, deactivated_at is not null as deactivated from user where user_id in (?)"
+	query, args, err := sqlx.In(sqlStr, ids)
+	if err != nil {
+		return nil, err
+	}
+	var list []*models.User
+	if err = db.SelectContext(ctx, &list, db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	for _, user := range list {
+		users[user.UserID] = user
+	}
+	return users, nil
+}
+
+// GetAuthorProfiles 批量查询作者的公开信息，key为用户id，不存在的用户不在结果中
+func GetAuthorProfiles(ctx context.Context, ids []int64) (map[int64]*models.AuthorProfile, error) {
+	profiles := make(map[int64]*models.AuthorProfile, len(ids))
+	if len(ids) == 0 {
+		return profiles, nil
+	}
+	sqlStr := "select user_id, username, avatar, karma, deactivated_at is not null as deactivated from user where user_id in (?)"
+	query, args, err := sqlx.In(sqlStr, ids)
+	if err != nil {
+		return nil, err
+	}
+	var list []*models.AuthorProfile
+	if err = db.SelectContext(ctx, &list, db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	for _, profile := range list {
+		profiles[profile.UserID] = profile
+	}
+	return profiles, nil
+}
+
+// GetUserByEmail 已合并到其他账号的用户不会通过邮箱找到
+func GetUserByEmail(email string) (user *models.User, err error) {
+	user = new(models.User)
+	sqlStr := `select user_id, username, email, email_verified, deactivated_at is not null as deactivated, banned_at is not null as banned
+	from user where email = ? and merged_into is null`
+	err = db.Get(user, sqlStr, email)
+	if err == sql.ErrNoRows {
+		return nil, ErrorUserNotExist
+	}
+	return
+}
+
+func UpdatePassword(uid int64, password string) (err error) {
+	sqlStr := "update user set password = ? where user_id = ?"
+	_, err = db.Exec(sqlStr, encryptPassword(password), uid)
+	return
+}
+
+func SetEmailVerified(uid int64) (err error) {
+	sqlStr := "update user set email_verified = 1 where user_id = ?"
+	_, err = db.Exec(sqlStr, uid)
+	return
+}
+
+func GetUserRole(uid int64) (role int8, err error) {
+	sqlStr := "select role from user where user_id = ?"
+	err = db.Get(&role, sqlStr, uid)
+	if err == sql.ErrNoRows {
+		return 0, ErrorUserNotExist
+	}
+	return
+}
+
+func UpdateAvatar(uid int64, avatar string) (err error) {
+	sqlStr := "update user set avatar = ? where user_id = ?"
+	_, err = db.Exec(sqlStr, avatar, uid)
+	return
+}
+
+// SetDeactivated 注销或恢复账号，注销不删除用户的任何内容，已合并到其他账号的用户不能恢复
+func SetDeactivated(uid int64, deactivated bool) (err error) {
+	if deactivated {
+		_, err = db.Exec("update user set deactivated_at = now() where user_id = ? and deactivated_at is null", uid)
+		return
+	}
+	ret, err := db.Exec("update user set deactivated_at = null where user_id = ? and merged_into is null", uid)
+	if err != nil {
+		return
+	}
+	if n, err := ret.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	var merged bool
+	if err = db.Get(&merged, "select merged_into is not null from user where user_id = ?", uid); err == nil && merged {
+		err = ErrorUserMerged
+	}
+	return
+}
+
+// SetBanned 封禁或解封账号
+func SetBanned(uid int64, banned bool) (err error) {
+	sqlStr := "update user set banned_at = null where user_id = ?"
+	if banned {
+		sqlStr = "update user set banned_at = now() where user_id = ? and banned_at is null"
+	}
+	_, err = db.Exec(sqlStr, uid)
+	return
+}
+
+// GetRecentUsers 按注册时间倒序分页查询用户
+func GetRecentUsers(page, size int64) (users []*models.AdminUser, err error) {
+	sqlStr := `select user_id, username, ifnull(email, '') as email, email_verified, role = 1 as is_admin,
+	banned_at is not null as banned, deactivated_at is not null as deactivated, create_time
+	from user
+	order by create_time desc, id desc
+	limit ?, ?`
+	users = make([]*models.AdminUser, 0, size)
+	err = db.Select(&users, sqlStr, (page-1)*size, size)
+	return
+}
+
+// GetUserCount 用户总数，包括已注销和被封禁的用户
+func GetUserCount() (count int64, err error) {
+	sqlStr := "select count(user_id) from user"
+	err = db.Get(&count, sqlStr)
+	return
+}
+
+// GetUserByOAuth 查询第三方账号绑定的用户
+func GetUserByOAuth(provider, subject string) (user *models.User, err error) {
+	user = new(models.User)
+	sqlStr := `select user_id, username, email, email_verified,
+	deactivated_at is not null as deactivated, banned_at is not null as banned
+	from user where oauth_provider = ? and oauth_subject = ?`
+	err = db.Get(user, sqlStr, provider, subject)
+	if err == sql.ErrNoRows {
+		return nil, ErrorUserNotExist
+	}
+	return
+}
+
+// LinkOAuth 把第三方账号绑定到已有的用户，提供方已经验证过邮箱
+// password不为空时同时重置密码
+func LinkOAuth(uid int64, provider, subject, password string) (err error) {
+	if password == "" {
+		sqlStr := "update user set oauth_provider = ?, oauth_subject = ?, email_verified = 1 where user_id = ?"
+		_, err = db.Exec(sqlStr, provider, subject, uid)
+		return
+	}
+	sqlStr := "update user set oauth_provider = ?, oauth_subject = ?, email_verified = 1, password = ? where user_id = ?"
+	_, err = db.Exec(sqlStr, provider, subject, encryptPassword(password), uid)
+	return
+}
+
+// InsertOAuthUser 通过第三方登录注册的用户，邮箱已验证，密码为随机值，communityIDs和afterJoin同InsertUser
+func InsertOAuthUser(user *models.User, provider, subject string, communityIDs []int64, afterJoin func(joined []int64) error) error {
+	sqlStr := `insert into user(user_id, username, password, email, email_verified, oauth_provider, oauth_subject)
+	values (?,?,?,?,1,?,?)`
+	return insertUserTx(user.UserID, communityIDs, afterJoin, sqlStr,
+		user.UserID, user.Username, encryptPassword(user.Password), user.Email, provider, subject)
+}
+
+// GetUserProfile 查询用户主页的基本信息
+func GetUserProfile(uid int64) (profile *models.UserProfile, err error) {
+	profile = new(models.UserProfile)
+	sqlStr := `select user_id, username, avatar, create_time, deactivated_at is not null as deactivated
+	from user where user_id = ?`
+	err = db.Get(profile, sqlStr, uid)
+	if err == sql.ErrNoRows {
+		return nil, ErrorUserNotExist
+	}
+	return
+}
+
+// GetUserKarma 数据库中保存的声望，可能落后于redis
+func GetUserKarma(uid int64) (karma int64, err error) {
+	sqlStr := "select karma from user where user_id = ?"
+	err = db.Get(&karma, sqlStr, uid)
+	if err == sql.ErrNoRows {
+		return 0, ErrorUserNotExist
+	}
+	return
+}
+
+// GetAllKarma 声望不为0的用户，用于重建redis中的声望
+func GetAllKarma() (karma map[int64]int64, err error) {
+	var rows []struct {
+		UserID int64 `db:"user_id"`
+		Karma  int64 `db:"karma"`
+	}
+	if err = db.Select(&rows, "select user_id, karma from user where karma != 0"); err != nil {
+		return nil, err
+	}
+	karma = make(map[int64]int64, len(rows))
+	for _, r := range rows {
+		karma[r.UserID] = r.Karma
+	}
+	return karma, nil
+}
+
+// SaveKarma 把redis中的声望写回数据库
+func SaveKarma(karma map[int64]int64) error {
+	return WithTx(context.Background(), func(tx *Tx) error {
+		for uid, v := range karma {
+			if _, err := tx.Exec("update user set karma = ? where user_id = ?", v, uid); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetUsersByUsernames 按用户名批量查询用户，不存在的用户名不在结果中
+func GetUsersByUsernames(names []string) (users []*models.User, err error) {
+	users = make([]*models.User, 0, len(names))
+	if len(names) == 0 {
+		return
+	}
+	sqlStr := "select user_id, username, deactivated_at is not null as deactivated from user where username in (?)"
+	query, args, err := sqlx.In(sqlStr, names)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Select(&users, db.Rebind(query), args...)
+	return
+}
+
+// GetExportProfile 导出数据时使用的账号信息
+func GetExportProfile(uid int64) (profile *models.ExportProfile, err error) {
+	profile = new(models.ExportProfile)
+	sqlStr := "select user_id, username, coalesce(email, '') as email, avatar, create_time from user where user_id = ?"
+	err = db.Get(profile, sqlStr, uid)
+	if err == sql.ErrNoRows {
+		return nil, ErrorUserNotExist
+	}
+	return
+}
+
+// GetUserEmail 用户的邮箱和是否已验证，没有邮箱时为空字符串
+func GetUserEmail(uid int64) (user *models.User, err error) {
+	user = new(models.User)
+	sqlStr := "select user_id, ifnull(email, '') as email, email_verified from user where user_id = ?"
 	err = db.Get(user, sqlStr, uid)
+	if err == sql.ErrNoRows {
+		return nil, ErrorUserNotExist
+	}
 	return
 }
@@ -0,0 +1,70 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// CheckUsernameAvailable 用户名没有被其他用户使用，比较时忽略大小写，自己换大小写不算冲突
+func CheckUsernameAvailable(uid int64, username string) (err error) {
+	var count int
+	if err = db.Get(&count, "select count(user_id) from user where username = ? and user_id != ?", username, uid); err != nil {
+		return
+	}
+	if count > 0 {
+		return ErrorUserExist
+	}
+	return
+}
+
+// IsUsernameReserved since之后被其他用户改掉的用户名还在保留期内，不能被占用
+func IsUsernameReserved(uid int64, username string, since time.Time) (bool, error) {
+	var count int64
+	sqlStr := "select count(*) from username_history where username = ? and user_id != ? and change_time > ?"
+	err := db.Get(&count, sqlStr, username, uid, since)
+	return count > 0, err
+}
+
+// GetLastUsernameChange 用户最近一次修改用户名的时间，没有修改过时ok为false
+func GetLastUsernameChange(uid int64) (t time.Time, ok bool, err error) {
+	var last sql.NullTime
+	if err = db.Get(&last, "select max(change_time) from username_history where user_id = ?", uid); err != nil {
+		return
+	}
+	return last.Time, last.Valid, nil
+}
+
+// ChangeUsername 修改用户名并记录旧的用户名，新用户名已被使用时返回ErrorUserExist
+func ChangeUsername(uid int64, oldName, newName string) error {
+	return WithTx(context.Background(), func(tx *Tx) error {
+		if _, err := tx.Exec("insert into username_history (user_id, username) values (?, ?)", uid, oldName); err != nil {
+			return err
+		}
+		// 唯一索引保证并发修改时不会出现重复的用户名
+		if _, err := tx.Exec("update user set username = ? where user_id = ?", newName, uid); err != nil {
+			if isDuplicateEntry(err) {
+				return ErrorUserExist
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// GetUserIDByOldUsername 最近一次使用过该用户名的用户
+func GetUserIDByOldUsername(username string) (uid int64, err error) {
+	sqlStr := "select user_id from username_history where username = ? order by change_time desc, id desc limit 1"
+	if err = db.Get(&uid, sqlStr, username); err == sql.ErrNoRows {
+		err = ErrorUserNotExist
+	}
+	return
+}
+
+// GetUserIDByUsername 当前使用该用户名的用户
+func GetUserIDByUsername(username string) (uid int64, err error) {
+	if err = db.Get(&uid, "select user_id from user where username = ?", username); err == sql.ErrNoRows {
+		err = ErrorUserNotExist
+	}
+	return
+}
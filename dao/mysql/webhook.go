@@ -0,0 +1,74 @@
+package mysql
+
+import (
+	"context"
+	"go-web-app/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const webhookColumns = "webhook_id, community_id, url, secret, creator_id, create_time"
+
+// AddWebhook 在一个事务中检查社区已有的webhook并保存新的webhook
+// check 的参数为社区已有的webhook数，返回错误时不保存
+func AddWebhook(w *models.Webhook, check func(count int64) error) error {
+	return WithTx(context.Background(), func(tx *Tx) error {
+		// 锁住社区，同时添加时不会超过限制
+		var cid int64
+		if err := tx.Get(&cid, "select community_id from community where community_id = ? for update", w.CommunityID); err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Get(&count, "select count(*) from community_webhook where community_id = ?", w.CommunityID); err != nil {
+			return err
+		}
+		if err := check(count); err != nil {
+			return err
+		}
+		sqlStr := "insert into community_webhook(webhook_id, community_id, url, secret, creator_id) values (?, ?, ?, ?, ?)"
+		_, err := tx.Exec(sqlStr, w.WebhookID, w.CommunityID, w.URL, w.Secret, w.CreatorID)
+		return err
+	})
+}
+
+// GetWebhooks 社区的webhook，按添加顺序
+func GetWebhooks(communityID int64) (list []*models.Webhook, err error) {
+	sqlStr := "select " + webhookColumns + " from community_webhook where community_id = ? order by id"
+	list = make([]*models.Webhook, 0)
+	err = db.Select(&list, sqlStr, communityID)
+	return
+}
+
+// GetWebhooksByCommunities 多个社区的webhook，帖子同时发布到多个社区时使用
+func GetWebhooksByCommunities(ids []int64) (list []*models.Webhook, err error) {
+	list = make([]*models.Webhook, 0)
+	if len(ids) == 0 {
+		return
+	}
+	query, args, err := sqlx.In("select "+webhookColumns+" from community_webhook where community_id in (?) order by id", ids)
+	if err != nil {
+		return
+	}
+	err = db.Select(&list, db.Rebind(query), args...)
+	return
+}
+
+// GetWebhook webhook不存在时返回sql.ErrNoRows
+func GetWebhook(id int64) (w *models.Webhook, err error) {
+	w = new(models.Webhook)
+	err = db.Get(w, "select "+webhookColumns+" from community_webhook where webhook_id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// DeleteWebhook 删除社区的webhook，返回是否删除
+func DeleteWebhook(communityID, id int64) (deleted bool, err error) {
+	ret, err := db.Exec("delete from community_webhook where community_id = ? and webhook_id = ?", communityID, id)
+	if err != nil {
+		return
+	}
+	n, err := ret.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,60 @@
+package redis
+
+import (
+	"encoding/json"
+	"go-web-app/models"
+	"strconv"
+	"time"
+)
+
+func getAuthorKey(uid int64) string {
+	return getRedisKey(KeyAuthorPF + strconv.FormatInt(uid, 10))
+}
+
+// GetAuthorProfiles 批量读取缓存的作者信息，missing为没有缓存的用户id
+func GetAuthorProfiles(ids []int64) (profiles map[int64]*models.AuthorProfile, missing []int64, err error) {
+	profiles = make(map[int64]*models.AuthorProfile, len(ids))
+	if len(ids) == 0 {
+		return profiles, nil, nil
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = getAuthorKey(id)
+	}
+	vals, err := client.MGet(keys...).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, v := range vals {
+		s, ok := v.(string)
+		profile := new(models.AuthorProfile)
+		// 无法解析的缓存当作未命中
+		if !ok || json.Unmarshal([]byte(s), profile) != nil {
+			missing = append(missing, ids[i])
+			continue
+		}
+		profiles[ids[i]] = profile
+	}
+	return profiles, missing, nil
+}
+
+// SetAuthorProfiles 缓存作者信息，用户名和头像修改时删除缓存，声望在过期之前不更新
+func SetAuthorProfiles(profiles map[int64]*models.AuthorProfile, expiration time.Duration) error {
+	if len(profiles) == 0 {
+		return nil
+	}
+	pipeline := client.Pipeline()
+	for uid, profile := range profiles {
+		b, err := json.Marshal(profile)
+		if err != nil {
+			return err
+		}
+		pipeline.Set(getAuthorKey(uid), b, expiration)
+	}
+	_, err := pipeline.Exec()
+	return err
+}
+
+func DeleteAuthorProfile(uid int64) error {
+	return client.Del(getAuthorKey(uid)).Err()
+}
@@ -0,0 +1,34 @@
+package redis
+
+import (
+	"go-web-app/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorProfileCache(t *testing.T) {
+	mr := useMiniredis(t)
+	alice := &models.AuthorProfile{UserID: 1, Username: "alice", Avatar: "/avatar/1.png", Karma: 12}
+	require.NoError(t, SetAuthorProfiles(map[int64]*models.AuthorProfile{1: alice}, time.Minute))
+	// 无法解析的缓存当作未命中
+	require.NoError(t, mr.Set(getAuthorKey(3), "{"))
+
+	profiles, missing, err := GetAuthorProfiles([]int64{1, 2, 3})
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]*models.AuthorProfile{1: alice}, profiles)
+	assert.Equal(t, []int64{2, 3}, missing)
+
+	mr.FastForward(time.Minute)
+	_, missing, err = GetAuthorProfiles([]int64{1})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, missing)
+
+	require.NoError(t, SetAuthorProfiles(map[int64]*models.AuthorProfile{1: alice}, time.Minute))
+	require.NoError(t, DeleteAuthorProfile(1))
+	_, missing, err = GetAuthorProfiles([]int64{1})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, missing)
+}
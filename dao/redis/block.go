@@ -0,0 +1,59 @@
+package redis
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// 屏蔽列表为空时也要能缓存，所以集合中总是放一个占位成员
+const blockedPlaceholder = "0"
+
+func getBlockedKey(uid int64) string {
+	return getRedisKey(KeyUserBlockedSetPF + strconv.FormatInt(uid, 10))
+}
+
+// GetBlockedIDs 返回缓存的屏蔽列表，没有缓存时返回redis.Nil
+func GetBlockedIDs(uid int64) ([]int64, error) {
+	members, err := client.SMembers(getBlockedKey(uid)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return nil, redis.Nil
+	}
+	ids := make([]int64, 0, len(members))
+	for _, m := range members {
+		if m == blockedPlaceholder {
+			continue
+		}
+		id, err := strconv.ParseInt(m, 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// SetBlockedIDs 缓存用户的屏蔽列表
+func SetBlockedIDs(uid int64, ids []int64, expiration time.Duration) error {
+	key := getBlockedKey(uid)
+	members := make([]interface{}, 0, len(ids)+1)
+	members = append(members, blockedPlaceholder)
+	for _, id := range ids {
+		members = append(members, id)
+	}
+	pipeline := client.TxPipeline()
+	pipeline.Del(key)
+	pipeline.SAdd(key, members...)
+	pipeline.Expire(key, expiration)
+	_, err := pipeline.Exec()
+	return err
+}
+
+// DeleteBlockedIDs 屏蔽关系变化时删除缓存
+func DeleteBlockedIDs(uid int64) error {
+	return client.Del(getBlockedKey(uid)).Err()
+}
@@ -0,0 +1,98 @@
+package redis
+
+import (
+	"errors"
+	"go-web-app/pkg/breaker"
+	"go-web-app/pkg/lru"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrUnavailable redis熔断期间的命令返回这个错误，需要写入redis的操作直接失败
+var ErrUnavailable = errors.New("Redis is temporarily unavailable. ")
+
+var (
+	brk = breaker.New(0, 0, nil)
+	// fallback 熔断期间读取的缓存，保存每个可缓存的读取最近一次成功的结果
+	fallback = lru.New(0)
+)
+
+// limiter 把熔断器接入客户端，每个命令获取连接前检查，命令结束后报告结果
+type limiter struct {
+	*breaker.Breaker
+}
+
+func (l limiter) Allow() error {
+	if err := l.Breaker.Allow(); err != nil {
+		return ErrUnavailable
+	}
+	return nil
+}
+
+func (l limiter) ReportResult(err error) {
+	before := l.State()
+	l.Breaker.ReportResult(err)
+	if after := l.State(); after != before {
+		zap.L().Warn("redis circuit breaker state changed",
+			zap.String("from", before.String()), zap.String("to", after.String()), zap.Error(err))
+	}
+}
+
+// initBreaker Init中创建客户端后调用，threshold<=0时不熔断
+func initBreaker(threshold int, cooldown time.Duration, cacheSize int) {
+	brk = breaker.New(threshold, cooldown, isConnError)
+	fallback = lru.New(cacheSize)
+	client.SetLimiter(limiter{brk})
+}
+
+// isConnError 只有连接不上或者连接断开才说明redis不可用，redis.Nil和命令本身的错误不算
+func isConnError(err error) bool {
+	if err == io.EOF || err == io.ErrUnexpectedEOF || err.Error() == "redis: connection pool timeout" {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// BreakerState redis熔断器当前的状态: closed, open, half_open
+func BreakerState() string {
+	return brk.State().String()
+}
+
+// checkAvailable 不经过熔断器的命令(例如Watch事务)在熔断期间直接返回ErrUnavailable
+func checkAvailable() error {
+	if brk.State() == breaker.StateOpen {
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// cached 可缓存的读取，成功时把结果保存到fallback，redis不可用时返回fallback中的结果
+// 没有缓存时返回原来的错误
+func cached(key string, val interface{}, err error) (interface{}, error) {
+	if err == nil {
+		fallback.Add(key, val)
+		return val, nil
+	}
+	if err != ErrUnavailable && !isConnError(err) {
+		return val, err
+	}
+	if v, ok := fallback.Get(key); ok {
+		return v, nil
+	}
+	return val, err
+}
+
+// idsPage getIDsFromKey在fallback中保存的结果
+type idsPage struct {
+	ids     []string
+	hasMore bool
+}
+
+func pageCacheKey(key string, page, size int64) string {
+	return key + ":" + strconv.FormatInt(page, 10) + ":" + strconv.FormatInt(size, 10)
+}
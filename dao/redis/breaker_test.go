@@ -0,0 +1,58 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreakerFallback(t *testing.T) {
+	mr := useMiniredis(t)
+	oldBrk, oldFallback := brk, fallback
+	initBreaker(2, 50*time.Millisecond, 100)
+	t.Cleanup(func() { brk, fallback = oldBrk, oldFallback })
+
+	key := getRedisKey(KeyPostTimeZSet)
+	_, err := mr.ZAdd(key, 1, "1")
+	require.NoError(t, err)
+	_, err = mr.ZAdd(key, 2, "2")
+	require.NoError(t, err)
+	require.NoError(t, client.ZAdd(getRedisKey(KeyPostVotedZSetPF+"1"), redis.Z{Score: 1, Member: "10"}).Err())
+
+	ids, _, err := getIDsFromKey(key, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2", "1"}, ids)
+	counts, err := GetPostVoteData([]string{"1"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, counts)
+
+	// 连续两次连接失败后熔断
+	addr := mr.Addr()
+	mr.Close()
+	for i := 0; i < 2; i++ {
+		_ = client.Get("x").Err()
+	}
+	assert.Equal(t, "open", BreakerState())
+
+	// 可缓存的读取返回之前的结果，写入直接失败
+	ids, _, err = getIDsFromKey(key, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2", "1"}, ids)
+	counts, err = GetPostVoteData([]string{"1"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, counts)
+	_, _, err = getIDsFromKey(key, 2, 10)
+	assert.Equal(t, ErrUnavailable, err)
+	assert.Equal(t, ErrUnavailable, client.Set("x", "1", 0).Err())
+	_, _, err = VoteForPost("10", "1", 1, 1, time.Hour)
+	assert.Equal(t, ErrUnavailable, err)
+
+	// 恢复后半开，试探成功后关闭
+	require.NoError(t, mr.StartAddr(addr))
+	time.Sleep(60 * time.Millisecond)
+	require.NoError(t, client.Set("x", "1", 0).Err())
+	assert.Equal(t, "closed", BreakerState())
+}
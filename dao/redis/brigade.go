@@ -0,0 +1,54 @@
+package redis
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// recordBurstScript 记录一次可疑的投票并清理窗口之外的记录
+// 窗口内的投票数达到阈值且没有被标记过时设置标记，返回{窗口内的投票数, 是否新标记, 是否已标记}
+const recordBurstScript = `
+redis.call("ZADD", KEYS[1], ARGV[1], ARGV[2])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", ARGV[1] - ARGV[3])
+redis.call("PEXPIRE", KEYS[1], ARGV[3])
+local count = redis.call("ZCARD", KEYS[1])
+local tripped = 0
+if count >= tonumber(ARGV[4]) and redis.call("SET", KEYS[2], ARGV[1], "NX", "PX", ARGV[5]) then
+	tripped = 1
+end
+return {count, tripped, redis.call("EXISTS", KEYS[2])}`
+
+// BurstResult 一次可疑投票之后目标的状态
+type BurstResult struct {
+	Count   int64 // 窗口内同一方向的可疑投票数
+	Tripped bool  // 这一次投票使目标被标记为刷票
+	Flagged bool  // 目标当前处于刷票标记中
+}
+
+// RecordSuspiciousVote 记录新账号或低声望账号的投票，target为post:<id>或comment:<id>
+// 同一方向的投票在window内达到threshold时标记目标，标记在flagTTL之后过期，期间不会重复触发
+func RecordSuspiciousVote(target string, direction int8, voterID int64, now time.Time, window time.Duration, threshold int64, flagTTL time.Duration) (*BurstResult, error) {
+	burstKey := getRedisKey(KeyVoteBurstPF + target + ":" + strconv.Itoa(int(direction)))
+	flagKey := getRedisKey(KeyVoteBrigadePF + target)
+	ret, err := client.Eval(recordBurstScript, []string{burstKey, flagKey},
+		now.UnixNano()/int64(time.Millisecond), voterID,
+		window.Milliseconds(), threshold, flagTTL.Milliseconds()).Result()
+	if err != nil {
+		return nil, err
+	}
+	vals, _ := ret.([]interface{})
+	if len(vals) != 3 {
+		return nil, fmt.Errorf("unexpected reply %v", ret)
+	}
+	count, _ := vals[0].(int64)
+	tripped, _ := vals[1].(int64)
+	flagged, _ := vals[2].(int64)
+	return &BurstResult{Count: count, Tripped: tripped == 1, Flagged: flagged == 1}, nil
+}
+
+// IsBrigaded 目标是否处于刷票标记中
+func IsBrigaded(target string) (bool, error) {
+	n, err := client.Exists(getRedisKey(KeyVoteBrigadePF + target)).Result()
+	return n > 0, err
+}
@@ -0,0 +1,52 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordSuspiciousVote(t *testing.T) {
+	useMiniredis(t)
+	const (
+		window    = 10 * time.Minute
+		threshold = 3
+		flagTTL   = time.Hour
+	)
+	now := time.Now()
+	record := func(target string, direction int8, voterID int64, at time.Time) *BurstResult {
+		r, err := RecordSuspiciousVote(target, direction, voterID, at, window, threshold, flagTTL)
+		require.NoError(t, err)
+		return r
+	}
+
+	// 窗口之外的投票不计入
+	r := record("post:1", -1, 1, now.Add(-2*window))
+	assert.Equal(t, int64(1), r.Count)
+	r = record("post:1", -1, 2, now)
+	assert.Equal(t, int64(1), r.Count)
+	assert.False(t, r.Flagged)
+
+	// 不同方向分开计数，同一个用户重复投票只算一次
+	record("post:1", 1, 3, now)
+	r = record("post:1", -1, 2, now)
+	assert.Equal(t, int64(1), r.Count)
+
+	r = record("post:1", -1, 3, now)
+	assert.False(t, r.Tripped)
+	r = record("post:1", -1, 4, now)
+	assert.Equal(t, int64(3), r.Count)
+	assert.True(t, r.Tripped)
+	assert.True(t, r.Flagged)
+
+	// 标记期间不再重复触发
+	r = record("post:1", -1, 5, now)
+	assert.False(t, r.Tripped)
+	assert.True(t, r.Flagged)
+
+	// 其他目标不受影响
+	r = record("comment:1", -1, 6, now)
+	assert.False(t, r.Flagged)
+}
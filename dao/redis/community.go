@@ -0,0 +1,105 @@
+package redis
+
+import (
+	"go-web-app/models"
+	"strconv"
+	"time"
+)
+
+func getCommunitySetKey(communityID int64) string {
+	return getRedisKey(KeyCommunitySetPF + strconv.FormatInt(communityID, 10))
+}
+
+func getCommunityFlairSetKey(communityID int64, flair string) string {
+	return getRedisKey(KeyCommunityFlairSetPF + strconv.FormatInt(communityID, 10) + ":" + flair)
+}
+
+// AddPostFlair 帖子加入主社区中对应flair的集合，用于按flair筛选社区的帖子
+func AddPostFlair(communityID, postID int64, flair string) error {
+	return client.SAdd(getCommunityFlairSetKey(communityID, flair), postID).Err()
+}
+
+// getCommunityOrderKey 社区的帖子按orderKey排序后缓存的结果
+func getCommunityOrderKey(orderKey string, communityID int64) string {
+	return orderKey + strconv.FormatInt(communityID, 10)
+}
+
+// 只更新已经存在的计数，不存在的计数在读取时从数据库重建
+const hincrIfExistsScript = `if redis.call("HEXISTS", KEYS[1], ARGV[1]) == 1 then return redis.call("HINCRBY", KEYS[1], ARGV[1], ARGV[2]) end return 0`
+
+// IncrCommunityMemberCount 加入/退出社区时更新成员数，delta为1或-1
+func IncrCommunityMemberCount(communityID, delta int64) error {
+	key := getRedisKey(KeyCommunityMemberCountHash)
+	return client.Eval(hincrIfExistsScript, []string{key}, strconv.FormatInt(communityID, 10), delta).Err()
+}
+
+// GetCommunityMemberCount 读取成员数，计数不存在时返回redis.Nil
+func GetCommunityMemberCount(communityID int64) (int64, error) {
+	return client.HGet(getRedisKey(KeyCommunityMemberCountHash), strconv.FormatInt(communityID, 10)).Int64()
+}
+
+func SetCommunityMemberCount(communityID, count int64) error {
+	return client.HSet(getRedisKey(KeyCommunityMemberCountHash), strconv.FormatInt(communityID, 10), count).Err()
+}
+
+// AddPostToCommunities 帖子发布到多个社区时每个社区都加入
+func AddPostToCommunities(postID int64, communityIDs []int64) error {
+	return updateCommunityPosts(postID, communityIDs, true)
+}
+
+// RemovePostFromCommunities 帖子被删除或者移出社区后不再出现在社区的列表中
+func RemovePostFromCommunities(postID int64, communityIDs []int64) error {
+	return updateCommunityPosts(postID, communityIDs, false)
+}
+
+// updateCommunityPosts 同时删除社区排序后的缓存，下次读取时重新计算
+func updateCommunityPosts(postID int64, communityIDs []int64, add bool) error {
+	if len(communityIDs) == 0 {
+		return nil
+	}
+	pipeline := client.TxPipeline()
+	for _, cid := range communityIDs {
+		if add {
+			pipeline.SAdd(getCommunitySetKey(cid), postID)
+			addCommunityFeed(pipeline, cid, postID)
+		} else {
+			// 移出社区的帖子同时取消置顶
+			pipeline.SRem(getCommunitySetKey(cid), postID)
+			pipeline.ZRem(getCommunityFeedKey(cid), postID)
+			pipeline.ZRem(getCommunityPinnedKey(cid), postID)
+		}
+		for _, order := range []string{models.OrderTime, models.OrderScore, models.OrderHot, models.OrderControversial} {
+			pipeline.Del(getCommunityOrderKey(getOrderKey(order), cid))
+		}
+	}
+	_, err := pipeline.Exec()
+	return err
+}
+
+func getCommunityPinnedKey(communityID int64) string {
+	return getRedisKey(KeyCommunityPinnedPF + strconv.FormatInt(communityID, 10))
+}
+
+// pinPostScript 已经置顶的帖子直接返回1，置顶数达到上限时返回0
+const pinPostScript = `
+if redis.call("ZSCORE", KEYS[1], ARGV[1]) then return 1 end
+if tonumber(ARGV[3]) > 0 and redis.call("ZCARD", KEYS[1]) >= tonumber(ARGV[3]) then return 0 end
+redis.call("ZADD", KEYS[1], ARGV[2], ARGV[1])
+return 1`
+
+// PinPost 在社区中置顶帖子，max大于0时置顶数达到max返回false，重复置顶不会报错
+func PinPost(communityID, postID int64, max int) (bool, error) {
+	n, err := client.Eval(pinPostScript, []string{getCommunityPinnedKey(communityID)},
+		postID, time.Now().Unix(), max).Int64()
+	return n == 1, err
+}
+
+// UnpinPost 取消置顶，没有置顶时不会报错
+func UnpinPost(communityID, postID int64) error {
+	return client.ZRem(getCommunityPinnedKey(communityID), postID).Err()
+}
+
+// GetPinnedPostIDs 社区置顶的帖子，最近置顶的在前
+func GetPinnedPostIDs(communityID int64) ([]string, error) {
+	return client.ZRevRange(getCommunityPinnedKey(communityID), 0, -1).Result()
+}
@@ -0,0 +1,103 @@
+package redis
+
+import (
+	"encoding/json"
+	"go-web-app/models"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+func getCommunityDetailKey(id int64) string {
+	return getRedisKey(KeyCommunityDetailPF + strconv.FormatInt(id, 10))
+}
+
+// GetCommunityDetailCaches 批量读取缓存的社区信息，没有缓存的社区不在结果中
+func GetCommunityDetailCaches(ids []int64) (map[int64]*models.CommunityDetail, error) {
+	communities := make(map[int64]*models.CommunityDetail, len(ids))
+	if len(ids) == 0 {
+		return communities, nil
+	}
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, getCommunityDetailKey(id))
+	}
+	values, err := client.MGet(keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		community := new(models.CommunityDetail)
+		// 无法解析的缓存当作未命中
+		if json.Unmarshal([]byte(s), community) != nil {
+			continue
+		}
+		communities[community.ID] = community
+	}
+	return communities, nil
+}
+
+func SetCommunityDetailCaches(communities map[int64]*models.CommunityDetail, ttl time.Duration) error {
+	if len(communities) == 0 {
+		return nil
+	}
+	pipeline := client.Pipeline()
+	for id, community := range communities {
+		b, err := json.Marshal(community)
+		if err != nil {
+			return err
+		}
+		pipeline.Set(getCommunityDetailKey(id), b, ttl)
+	}
+	_, err := pipeline.Exec()
+	return err
+}
+
+// DeleteCommunityDetailCaches 社区信息修改后删除缓存
+func DeleteCommunityDetailCaches(ids ...int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, getCommunityDetailKey(id))
+	}
+	return client.Del(keys...).Err()
+}
+
+// GetFeaturedCommunityCache 缓存的推荐社区id，没有缓存时ok为false
+func GetFeaturedCommunityCache() (ids []int64, ok bool, err error) {
+	b, err := client.Get(getRedisKey(KeyCommunityFeatured)).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if err = json.Unmarshal(b, &ids); err != nil {
+		return nil, false, err
+	}
+	return ids, true, nil
+}
+
+// SetFeaturedCommunityCache 只缓存id和顺序，社区信息使用社区自己的缓存
+func SetFeaturedCommunityCache(ids []int64, ttl time.Duration) error {
+	if ids == nil {
+		ids = []int64{}
+	}
+	b, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+	return client.Set(getRedisKey(KeyCommunityFeatured), b, ttl).Err()
+}
+
+// DeleteFeaturedCommunityCache 推荐列表修改后删除缓存
+func DeleteFeaturedCommunityCache() error {
+	return client.Del(getRedisKey(KeyCommunityFeatured)).Err()
+}
@@ -0,0 +1,172 @@
+package redis
+
+import (
+	"go-web-app/models"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPinPost(t *testing.T) {
+	useMiniredis(t)
+	for _, pid := range []int64{1, 2} {
+		ok, err := PinPost(7, pid, 2)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	}
+	// 达到上限后不能再置顶，已经置顶的帖子重复置顶不受影响
+	ok, err := PinPost(7, 3, 2)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = PinPost(7, 1, 2)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	require.NoError(t, UnpinPost(7, 1))
+	ok, err = PinPost(7, 3, 2)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ids, err := GetPinnedPostIDs(7)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"2", "3"}, ids)
+}
+
+func TestFeaturedCommunityCache(t *testing.T) {
+	useMiniredis(t)
+	_, ok, err := GetFeaturedCommunityCache()
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// 空列表也会缓存，避免每次都查询数据库
+	require.NoError(t, SetFeaturedCommunityCache(nil, time.Minute))
+	ids, ok, err := GetFeaturedCommunityCache()
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Empty(t, ids)
+
+	require.NoError(t, SetFeaturedCommunityCache([]int64{4, 1, 2}, time.Minute))
+	ids, _, _ = GetFeaturedCommunityCache()
+	assert.Equal(t, []int64{4, 1, 2}, ids)
+
+	require.NoError(t, DeleteFeaturedCommunityCache())
+	_, ok, err = GetFeaturedCommunityCache()
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestGetCommunityPostIDsInOrderExcludesPinned(t *testing.T) {
+	useMiniredis(t)
+	// miniredis的ZINTERSTORE不支持普通集合，直接写入社区排序后的缓存
+	key := getCommunityOrderKey(getOrderKey(models.OrderTime), 7)
+	for pid := int64(1); pid <= 7; pid++ {
+		client.ZAdd(key, redis.Z{Score: float64(pid), Member: pid})
+	}
+	// 置顶的帖子不占用分页的位置，不在社区中的id不影响分页
+	pinned := []string{"6", "2", "99"}
+	expected := []struct {
+		ids     []string
+		hasMore bool
+	}{
+		{[]string{"7", "5"}, true},
+		{[]string{"4", "3"}, true},
+		{[]string{"1"}, false},
+	}
+	for i, want := range expected {
+		p := &models.ParamPostList{CommunityID: 7, Page: int64(i + 1), Size: 2, Order: models.OrderTime}
+		ids, hasMore, err := GetCommunityPostIDsInOrder(p, pinned)
+		require.NoError(t, err)
+		assert.Equal(t, want.ids, ids, "page %d", i+1)
+		assert.Equal(t, want.hasMore, hasMore, "page %d", i+1)
+	}
+
+	ids, hasMore, err := GetCommunityPostIDsInOrder(&models.ParamPostList{CommunityID: 7, Page: 1, Size: 3, Order: models.OrderTime}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"7", "6", "5"}, ids)
+	assert.True(t, hasMore)
+}
+
+func TestCommunityMemberCount(t *testing.T) {
+	useMiniredis(t)
+	// 计数不存在时返回redis.Nil，加减不会创建计数，读取时再从数据库重建
+	_, err := GetCommunityMemberCount(7)
+	assert.Equal(t, redis.Nil, err)
+	require.NoError(t, IncrCommunityMemberCount(7, 1))
+	_, err = GetCommunityMemberCount(7)
+	assert.Equal(t, redis.Nil, err)
+
+	require.NoError(t, SetCommunityMemberCount(7, 10))
+	require.NoError(t, IncrCommunityMemberCount(7, 1))
+	require.NoError(t, IncrCommunityMemberCount(7, -1))
+	require.NoError(t, IncrCommunityMemberCount(7, -1))
+	count, err := GetCommunityMemberCount(7)
+	require.NoError(t, err)
+	assert.EqualValues(t, 9, count)
+
+	// 其他社区的计数不受影响
+	_, err = GetCommunityMemberCount(8)
+	assert.Equal(t, redis.Nil, err)
+}
+
+func TestCommunityDetailCaches(t *testing.T) {
+	useMiniredis(t)
+	require.NoError(t, SetCommunityDetailCaches(map[int64]*models.CommunityDetail{
+		1: {ID: 1, Name: "go"},
+		2: {ID: 2, Name: "rust"},
+	}, time.Minute))
+	// 无法解析的缓存当作未命中
+	require.NoError(t, client.Set(getCommunityDetailKey(3), "{", time.Minute).Err())
+
+	communities, err := GetCommunityDetailCaches([]int64{1, 2, 3, 4})
+	require.NoError(t, err)
+	require.Len(t, communities, 2)
+	assert.Equal(t, "go", communities[1].Name)
+	assert.Equal(t, "rust", communities[2].Name)
+
+	require.NoError(t, DeleteCommunityDetailCaches(1))
+	communities, err = GetCommunityDetailCaches([]int64{1, 2})
+	require.NoError(t, err)
+	assert.Len(t, communities, 1)
+	assert.Contains(t, communities, int64(2))
+}
+
+func TestCommunityDetailCachesRedisDown(t *testing.T) {
+	mr := useMiniredis(t)
+	mr.Close()
+	_, err := GetCommunityDetailCaches([]int64{1})
+	assert.Error(t, err)
+	assert.Error(t, SetCommunityDetailCaches(map[int64]*models.CommunityDetail{1: {ID: 1}}, time.Minute))
+	assert.Error(t, DeleteCommunityDetailCaches(1))
+}
+
+func TestCommunityFlairPosts(t *testing.T) {
+	useMiniredis(t)
+	require.NoError(t, AddPostFlair(7, 1, "Question"))
+	require.NoError(t, AddPostFlair(7, 3, "Question"))
+	require.NoError(t, AddPostFlair(7, 2, "News"))
+	members, err := client.SMembers(getCommunityFlairSetKey(7, "Question")).Result()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"1", "3"}, members)
+
+	// miniredis的ZINTERSTORE不支持普通集合，直接写入筛选后的缓存
+	orderKey := getCommunityOrderKey(getOrderKey(models.OrderTime), 7)
+	for pid := int64(1); pid <= 3; pid++ {
+		client.ZAdd(orderKey, redis.Z{Score: float64(pid), Member: pid})
+	}
+	client.ZAdd(orderKey+":flair:Question", redis.Z{Score: 1, Member: 1}, redis.Z{Score: 3, Member: 3})
+	ids, hasMore, err := GetCommunityPostIDsInOrder(&models.ParamPostList{CommunityID: 7, Page: 1, Size: 10, Order: models.OrderTime, Flair: "Question"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"3", "1"}, ids)
+	assert.False(t, hasMore)
+}
+
+func TestCommunityFlairPostsRedisDown(t *testing.T) {
+	mr := useMiniredis(t)
+	mr.Close()
+	assert.Error(t, AddPostFlair(7, 1, "Question"))
+	_, _, err := GetCommunityPostIDsInOrder(&models.ParamPostList{CommunityID: 7, Page: 1, Size: 10, Order: models.OrderTime, Flair: "Question"}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,61 @@
+package redis
+
+import (
+	"go-web-app/models"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// communityBucketKey 每小时一个有序集合，member为社区id，分数为这一小时内的活跃度
+func communityBucketKey(t time.Time) string {
+	return getRedisKey(KeyCommunityTrendingPF + strconv.FormatInt(t.Unix()/3600, 10))
+}
+
+// IncrCommunityActivity 社区有新帖子或新成员时活跃度加weight，分桶在统计窗口之后自动过期
+func IncrCommunityActivity(ids []int64, weight int64, window time.Duration) error {
+	if len(ids) == 0 || weight == 0 {
+		return nil
+	}
+	key := communityBucketKey(time.Now())
+	pipeline := client.TxPipeline()
+	for _, id := range ids {
+		pipeline.ZIncrBy(key, float64(weight), strconv.FormatInt(id, 10))
+	}
+	pipeline.Expire(key, window+time.Hour)
+	_, err := pipeline.Exec()
+	return err
+}
+
+// GetTrendingCommunities 合并最近window个小时的分桶，返回活跃度最高的size个社区，只有id和活跃度
+// 合并结果缓存cacheTTL，期间的新活动不会立即体现
+func GetTrendingCommunities(window int, size int64, cacheTTL time.Duration) ([]*models.TrendingCommunity, error) {
+	key := getRedisKey(KeyCommunityTrendingPF + "top:" + strconv.Itoa(window))
+	if client.Exists(key).Val() < 1 {
+		now := time.Now()
+		keys := make([]string, 0, window)
+		for i := 0; i < window; i++ {
+			keys = append(keys, communityBucketKey(now.Add(-time.Duration(i)*time.Hour)))
+		}
+		pipeline := client.Pipeline()
+		pipeline.ZUnionStore(key, redis.ZStore{}, keys...)
+		pipeline.Expire(key, cacheTTL)
+		if _, err := pipeline.Exec(); err != nil {
+			return nil, err
+		}
+	}
+	zs, err := client.ZRevRangeWithScores(key, 0, size-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*models.TrendingCommunity, 0, len(zs))
+	for _, z := range zs {
+		id, err := strconv.ParseInt(z.Member.(string), 10, 64)
+		if err != nil {
+			continue
+		}
+		list = append(list, &models.TrendingCommunity{ID: id, Activity: int64(z.Score)})
+	}
+	return list, nil
+}
@@ -0,0 +1,32 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrendingCommunities(t *testing.T) {
+	mr := useMiniredis(t)
+	require.NoError(t, IncrCommunityActivity([]int64{1, 2}, 2, 24*time.Hour))
+	require.NoError(t, IncrCommunityActivity([]int64{2}, 1, 24*time.Hour))
+	require.NoError(t, IncrCommunityActivity([]int64{3}, 0, 24*time.Hour))
+
+	list, err := GetTrendingCommunities(24, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, []int64{2, 3}, []int64{list[0].ID, list[0].Activity})
+	assert.Equal(t, []int64{1, 2}, []int64{list[1].ID, list[1].Activity})
+
+	// 缓存期间新的活动不会体现，过期后重新合并
+	require.NoError(t, IncrCommunityActivity([]int64{1}, 5, 24*time.Hour))
+	list, err = GetTrendingCommunities(24, 1, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), list[0].ID)
+	mr.FastForward(time.Minute)
+	list, err = GetTrendingCommunities(24, 1, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 7}, []int64{list[0].ID, list[0].Activity})
+}
@@ -0,0 +1,56 @@
+package redis
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+func getPostDigestKey(owner, digest string) string {
+	return getRedisKey(KeyPostDigestPF + owner + ":" + digest)
+}
+
+// FindDuplicatePost 查找最近发布过相同摘要的帖子，own为uid自己的帖子，other为所有用户中最近的一个，没有时为0
+func FindDuplicatePost(uid int64, digests []string) (own, other int64, err error) {
+	if len(digests) == 0 {
+		return 0, 0, nil
+	}
+	owner := strconv.FormatInt(uid, 10)
+	keys := make([]string, 0, 2*len(digests))
+	for _, d := range digests {
+		keys = append(keys, getPostDigestKey(owner, d), getPostDigestKey("any", d))
+	}
+	vals, err := client.MGet(keys...).Result()
+	if err != nil && err != redis.Nil {
+		return 0, 0, err
+	}
+	for i, v := range vals {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		pid, _ := strconv.ParseInt(s, 10, 64)
+		if i%2 == 0 && own == 0 {
+			own = pid
+		} else if i%2 == 1 && other == 0 {
+			other = pid
+		}
+	}
+	return own, other, nil
+}
+
+// RecordPostDigests 记录新帖子的摘要，window之后过期
+func RecordPostDigests(uid, pid int64, digests []string, window time.Duration) error {
+	if len(digests) == 0 {
+		return nil
+	}
+	owner := strconv.FormatInt(uid, 10)
+	pipeline := client.Pipeline()
+	for _, d := range digests {
+		pipeline.Set(getPostDigestKey(owner, d), pid, window)
+		pipeline.Set(getPostDigestKey("any", d), pid, window)
+	}
+	_, err := pipeline.Exec()
+	return err
+}
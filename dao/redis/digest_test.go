@@ -0,0 +1,38 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindDuplicatePost(t *testing.T) {
+	mr := useMiniredis(t)
+	own, other, err := FindDuplicatePost(1, []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{0, 0}, []int64{own, other})
+
+	require.NoError(t, RecordPostDigests(1, 100, []string{"a", "b"}, time.Hour))
+	// 只有链接相同也算重复
+	own, other, err = FindDuplicatePost(1, []string{"x", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{100, 100}, []int64{own, other})
+
+	// 其他用户只能找到所有用户中的帖子
+	own, other, err = FindDuplicatePost(2, []string{"a"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{0, 100}, []int64{own, other})
+
+	// 所有用户中记录的是最近的帖子
+	require.NoError(t, RecordPostDigests(2, 200, []string{"a"}, time.Hour))
+	own, other, err = FindDuplicatePost(3, []string{"a"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{0, 200}, []int64{own, other})
+
+	mr.FastForward(2 * time.Hour)
+	own, other, err = FindDuplicatePost(1, []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{0, 0}, []int64{own, other})
+}
@@ -0,0 +1,86 @@
+package redis
+
+import (
+	"encoding/json"
+	"go-web-app/models"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// emailDeadMax 死信列表最多保留多少封邮件
+const emailDeadMax = 1000
+
+// emailPromoteBatch 每次最多把多少封到时间的重试邮件放回队列
+const emailPromoteBatch = 100
+
+// EnqueueEmail 把邮件加入队列的左边
+func EnqueueEmail(job *models.EmailJob) error {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return client.LPush(getRedisKey(KeyEmailQueue), b).Err()
+}
+
+// PopEmail 从队列的右边取出最早加入的邮件，队列为空时返回nil
+// 取出后实例退出或崩溃时这封邮件会丢失，验证和重置密码的邮件用户可以重新申请
+func PopEmail() (*models.EmailJob, error) {
+	b, err := client.RPop(getRedisKey(KeyEmailQueue)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	job := new(models.EmailJob)
+	if err = json.Unmarshal(b, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// RetryEmail 邮件在at之后重新加入队列
+func RetryEmail(job *models.EmailJob, at time.Time) error {
+	return ScheduleEmail(job, at)
+}
+
+// ScheduleEmail 邮件在at之后才加入队列，例如免打扰期间的邮件
+func ScheduleEmail(job *models.EmailJob, at time.Time) error {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return client.ZAdd(getRedisKey(KeyEmailRetryZSet), redis.Z{Score: float64(at.Unix()), Member: b}).Err()
+}
+
+// promoteDueScript 把到时间的重试任务从zset移回队列，多个实例同时执行时每个任务只会被移动一次
+// 邮件和webhook的发送队列共用
+var promoteDueScript = redis.NewScript(`
+local jobs = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, job in ipairs(jobs) do
+	redis.call('ZREM', KEYS[1], job)
+	redis.call('LPUSH', KEYS[2], job)
+end
+return #jobs
+`)
+
+// PromoteDueEmails 把到了重试时间的邮件放回队列，返回移动的数量
+func PromoteDueEmails(now time.Time) (int, error) {
+	keys := []string{getRedisKey(KeyEmailRetryZSet), getRedisKey(KeyEmailQueue)}
+	return promoteDueScript.Run(client, keys, now.Unix(), emailPromoteBatch).Int()
+}
+
+// DeadLetterEmail 不再重试的邮件放入死信列表，只保留最近的emailDeadMax封
+func DeadLetterEmail(job *models.EmailJob) error {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	key := getRedisKey(KeyEmailDeadList)
+	pipeline := client.TxPipeline()
+	pipeline.LPush(key, b)
+	pipeline.LTrim(key, 0, emailDeadMax-1)
+	_, err = pipeline.Exec()
+	return err
+}
@@ -0,0 +1,42 @@
+package redis
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// emailDigestKeep 摘要的发送记录保留多久，超过一天即可
+const emailDigestKeep = 48 * time.Hour
+
+// MarkDigestSent 记录当天已给用户发送摘要，已经记录过时返回false，调用方不应再发送
+func MarkDigestSent(day string, uid int64) (bool, error) {
+	key := getRedisKey(KeyEmailDigestSentPF + day)
+	pipeline := client.TxPipeline()
+	added := pipeline.SAdd(key, strconv.FormatInt(uid, 10))
+	pipeline.Expire(key, emailDigestKeep)
+	if _, err := pipeline.Exec(); err != nil {
+		return false, err
+	}
+	return added.Val() > 0, nil
+}
+
+// UnmarkDigestSent 摘要没有加入发送队列时删除记录，下次执行时重新发送
+func UnmarkDigestSent(day string, uid int64) error {
+	return client.SRem(getRedisKey(KeyEmailDigestSentPF+day), strconv.FormatInt(uid, 10)).Err()
+}
+
+// IsDigestDone 当天的摘要是否已经全部发送
+func IsDigestDone(day string) (bool, error) {
+	_, err := client.Get(getRedisKey(KeyEmailDigestDonePF + day)).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// SetDigestDone 记录当天的摘要已经全部发送
+func SetDigestDone(day string) error {
+	return client.Set(getRedisKey(KeyEmailDigestDonePF+day), 1, emailDigestKeep).Err()
+}
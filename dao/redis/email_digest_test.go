@@ -0,0 +1,38 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigestSent(t *testing.T) {
+	useMiniredis(t)
+	added, err := MarkDigestSent("20261014", 1)
+	require.NoError(t, err)
+	assert.True(t, added)
+	// 同一天不重复发送，第二天重新开始
+	added, err = MarkDigestSent("20261014", 1)
+	require.NoError(t, err)
+	assert.False(t, added)
+	added, err = MarkDigestSent("20261015", 1)
+	require.NoError(t, err)
+	assert.True(t, added)
+
+	require.NoError(t, UnmarkDigestSent("20261014", 1))
+	added, err = MarkDigestSent("20261014", 1)
+	require.NoError(t, err)
+	assert.True(t, added)
+
+	done, err := IsDigestDone("20261014")
+	require.NoError(t, err)
+	assert.False(t, done)
+	require.NoError(t, SetDigestDone("20261014"))
+	done, err = IsDigestDone("20261014")
+	require.NoError(t, err)
+	assert.True(t, done)
+	done, err = IsDigestDone("20261015")
+	require.NoError(t, err)
+	assert.False(t, done)
+}
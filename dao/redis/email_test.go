@@ -0,0 +1,53 @@
+package redis
+
+import (
+	"go-web-app/models"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmailQueue(t *testing.T) {
+	useMiniredis(t)
+	job, err := PopEmail()
+	require.NoError(t, err)
+	assert.Nil(t, job)
+
+	// 先加入的先发送
+	require.NoError(t, EnqueueEmail(&models.EmailJob{ID: "1", To: "a@rutgers.edu"}))
+	require.NoError(t, EnqueueEmail(&models.EmailJob{ID: "2", To: "b@rutgers.edu"}))
+	job, err = PopEmail()
+	require.NoError(t, err)
+	assert.Equal(t, "1", job.ID)
+
+	now := time.Now()
+	job.Attempts = 1
+	require.NoError(t, RetryEmail(job, now.Add(time.Minute)))
+	n, err := PromoteDueEmails(now)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+	n, err = PromoteDueEmails(now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, int64(0), client.ZCard(getRedisKey(KeyEmailRetryZSet)).Val())
+
+	// 重试的邮件排在已经在队列中的邮件后面
+	job, _ = PopEmail()
+	assert.Equal(t, "2", job.ID)
+	job, _ = PopEmail()
+	assert.Equal(t, "1", job.ID)
+	assert.Equal(t, 1, job.Attempts)
+}
+
+func TestDeadLetterEmail(t *testing.T) {
+	useMiniredis(t)
+	for i := 0; i < emailDeadMax+5; i++ {
+		require.NoError(t, DeadLetterEmail(&models.EmailJob{ID: strconv.Itoa(i)}))
+	}
+	key := getRedisKey(KeyEmailDeadList)
+	assert.Equal(t, int64(emailDeadMax), client.LLen(key).Val())
+	assert.Contains(t, client.LIndex(key, 0).Val(), `"id":"`+strconv.Itoa(emailDeadMax+4)+`"`)
+}
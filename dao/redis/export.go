@@ -0,0 +1,84 @@
+package redis
+
+import (
+	"encoding/json"
+	"go-web-app/models"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+func getExportKey(token string) string {
+	return getRedisKey(KeyExportPF + token)
+}
+
+// startExportScript 最近没有成功的导出，也没有正在进行的导出时标记开始导出
+var startExportScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then return 0 end
+if redis.call("SET", KEYS[2], 1, "NX", "PX", ARGV[1]) then return 1 end
+return 0`)
+
+func exportKeys(userID int64) (limit, run string) {
+	uid := strconv.FormatInt(userID, 10)
+	return getRedisKey(KeyExportLimitPF + uid), getRedisKey(KeyExportRunPF + uid)
+}
+
+// TryStartExport 每个用户在interval内只能成功导出一次，同一时间只能有一个导出，不能开始时返回false
+// 导出结束后调用FinishExport，timeout之后没有结束的导出不再阻止新的导出
+func TryStartExport(userID int64, timeout time.Duration) (bool, error) {
+	limit, run := exportKeys(userID)
+	n, err := startExportScript.Run(client, []string{limit, run}, timeout.Milliseconds()).Int()
+	return n == 1, err
+}
+
+// FinishExport 导出结束，成功时开始计算下一次导出的间隔，失败时可以立即重新导出
+func FinishExport(userID int64, success bool, interval time.Duration) error {
+	limit, run := exportKeys(userID)
+	pipeline := client.TxPipeline()
+	if success {
+		pipeline.Set(limit, 1, interval)
+	}
+	pipeline.Del(run)
+	_, err := pipeline.Exec()
+	return err
+}
+
+// SetExportJob 保存导出的状态，ttl之后下载链接失效
+func SetExportJob(job *models.ExportJob, ttl time.Duration) error {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return client.Set(getExportKey(job.Token), b, ttl).Err()
+}
+
+// UpdateExportStatus 更新状态并保留原来的过期时间，导出已经过期时不做任何事
+func UpdateExportStatus(token, status string) error {
+	job, err := GetExportJob(token)
+	if err != nil || job == nil {
+		return err
+	}
+	job.Status = status
+	ttl := time.Until(job.ExpireTime)
+	if ttl <= 0 {
+		return nil
+	}
+	return SetExportJob(job, ttl)
+}
+
+// GetExportJob 导出不存在或已经过期时返回nil
+func GetExportJob(token string) (*models.ExportJob, error) {
+	b, err := client.Get(getExportKey(token)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	job := new(models.ExportJob)
+	if err = json.Unmarshal(b, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
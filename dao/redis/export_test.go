@@ -0,0 +1,48 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportQuota(t *testing.T) {
+	mr := useMiniredis(t)
+
+	ok, err := TryStartExport(1, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	// 同一时间只能有一个导出
+	ok, err = TryStartExport(1, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// 失败的导出不占用次数，可以立即重新导出
+	require.NoError(t, FinishExport(1, false, time.Hour))
+	ok, err = TryStartExport(1, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// 成功之后在间隔内不能再导出
+	require.NoError(t, FinishExport(1, true, time.Hour))
+	ok, err = TryStartExport(1, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	mr.FastForward(time.Hour)
+	ok, err = TryStartExport(1, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// 没有结束的导出超时之后不再阻止新的导出
+	mr.FastForward(time.Minute)
+	ok, err = TryStartExport(1, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	mr.Close()
+	_, err = TryStartExport(2, time.Minute)
+	assert.Error(t, err)
+	assert.Error(t, FinishExport(2, true, time.Hour))
+}
@@ -0,0 +1,41 @@
+package redis
+
+import (
+	"encoding/json"
+	"go-web-app/models"
+
+	"go.uber.org/zap"
+)
+
+// GetFeatureFlags 读取所有功能开关，无法解析的开关被忽略
+func GetFeatureFlags() (map[string]*models.FeatureFlag, error) {
+	values, err := client.HGetAll(getRedisKey(KeyFeatureFlagsHash)).Result()
+	if err != nil {
+		return nil, err
+	}
+	flags := make(map[string]*models.FeatureFlag, len(values))
+	for name, v := range values {
+		flag := new(models.FeatureFlag)
+		if err := json.Unmarshal([]byte(v), flag); err != nil {
+			zap.L().Warn("invalid feature flag", zap.String("name", name), zap.Error(err))
+			continue
+		}
+		flag.Name = name
+		flags[name] = flag
+	}
+	return flags, nil
+}
+
+// SetFeatureFlag 创建或修改功能开关
+func SetFeatureFlag(flag *models.FeatureFlag) error {
+	b, err := json.Marshal(flag)
+	if err != nil {
+		return err
+	}
+	return client.HSet(getRedisKey(KeyFeatureFlagsHash), flag.Name, b).Err()
+}
+
+// DeleteFeatureFlag 删除功能开关，之后按未开启处理
+func DeleteFeatureFlag(name string) error {
+	return client.HDel(getRedisKey(KeyFeatureFlagsHash), name).Err()
+}
@@ -0,0 +1,32 @@
+package redis
+
+import (
+	"go-web-app/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeatureFlags(t *testing.T) {
+	mr := useMiniredis(t)
+	flags, err := GetFeatureFlags()
+	require.NoError(t, err)
+	assert.Empty(t, flags)
+
+	require.NoError(t, SetFeatureFlag(&models.FeatureFlag{Name: "new_feed", Enabled: true, Percent: 20}))
+	require.NoError(t, SetFeatureFlag(&models.FeatureFlag{Name: "dark_mode", Enabled: false, Percent: 100}))
+	// 无法解析的开关被忽略
+	mr.HSet(getRedisKey(KeyFeatureFlagsHash), "broken", "{")
+	flags, err = GetFeatureFlags()
+	require.NoError(t, err)
+	require.Len(t, flags, 2)
+	assert.True(t, flags["new_feed"].Enabled)
+	assert.Equal(t, 20, flags["new_feed"].Percent)
+	assert.False(t, flags["dark_mode"].Enabled)
+
+	require.NoError(t, DeleteFeatureFlag("new_feed"))
+	flags, err = GetFeatureFlags()
+	require.NoError(t, err)
+	assert.NotContains(t, flags, "new_feed")
+}
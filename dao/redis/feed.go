@@ -0,0 +1,169 @@
+package redis
+
+import (
+	"go-web-app/models"
+	"go-web-app/pkg/snowflake"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// feedScore 作者和社区动态中帖子的分数，为帖子id中的生成时间(毫秒)，和游标的换算方式一致
+func feedScore(postID int64) float64 {
+	return float64(snowflake.Millis(postID))
+}
+
+func getCommunityFeedKey(communityID int64) string {
+	return getRedisKey(KeyCommunityFeedZSetPF + strconv.FormatInt(communityID, 10))
+}
+
+// buildCommunityFeedScript 社区的动态不存在时从社区的帖子集合建立，分数从id算出: id / 2^TimeShift + EpochMillis
+var buildCommunityFeedScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then return 0 end
+local ids = redis.call("SMEMBERS", KEYS[2])
+for i = 1, #ids, 500 do
+	local args = {}
+	for j = i, math.min(i + 499, #ids) do
+		args[#args + 1] = math.floor(tonumber(ids[j]) / tonumber(ARGV[1])) + tonumber(ARGV[2])
+		args[#args + 1] = ids[j]
+	end
+	redis.call("ZADD", KEYS[1], unpack(args))
+end
+return 1`)
+
+// addCommunityFeedScript 只更新已经建立的社区动态，没有建立的在读取时由buildCommunityFeedScript建立
+var addCommunityFeedScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then return redis.call("ZADD", KEYS[1], ARGV[1], ARGV[2]) end
+return 0`)
+
+// addCommunityFeed 帖子加入社区时同时加入社区的动态
+func addCommunityFeed(pipeline redis.Pipeliner, communityID, postID int64) {
+	addCommunityFeedScript.Eval(pipeline, []string{getCommunityFeedKey(communityID)}, feedScore(postID), postID)
+}
+
+// GetFeedPostIDs 从每个作者和每个社区的帖子中取出id小于beforeID的帖子，去重后按id(即发布时间)倒序返回最多size个。
+// beforeID 为0时从最新的帖子开始。
+func GetFeedPostIDs(authorIDs, communityIDs []int64, beforeID int64, size int64) ([]int64, error) {
+	if len(authorIDs)+len(communityIDs) == 0 || size <= 0 {
+		return nil, nil
+	}
+	max := "+inf"
+	if beforeID != 0 {
+		// 同一毫秒内的帖子按id过滤
+		max = strconv.FormatInt(snowflake.Millis(beforeID), 10)
+	}
+	keys := make([]string, 0, len(authorIDs)+len(communityIDs))
+	for _, uid := range authorIDs {
+		keys = append(keys, getRedisKey(KeyUserPostZSetPF+strconv.FormatInt(uid, 10)))
+	}
+	if len(communityIDs) > 0 {
+		pipeline := client.Pipeline()
+		for _, cid := range communityIDs {
+			key := getCommunityFeedKey(cid)
+			buildCommunityFeedScript.Eval(pipeline, []string{key, getCommunitySetKey(cid)},
+				1<<snowflake.TimeShift(), snowflake.EpochMillis())
+			keys = append(keys, key)
+		}
+		if _, err := pipeline.Exec(); err != nil {
+			return nil, err
+		}
+	}
+
+	pipeline := client.Pipeline()
+	cmds := make([]*redis.StringSliceCmd, 0, len(keys))
+	for _, key := range keys {
+		// 多取一个，弥补同一毫秒内被beforeID过滤掉的帖子
+		cmds = append(cmds, pipeline.ZRevRangeByScore(key, redis.ZRangeBy{
+			Max:   max,
+			Min:   "-inf",
+			Count: size + 1,
+		}))
+	}
+	if _, err := pipeline.Exec(); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	seen := make(map[int64]bool)
+	ids := make([]int64, 0)
+	for _, cmd := range cmds {
+		for _, member := range cmd.Val() {
+			id, err := strconv.ParseInt(member, 10, 64)
+			if err != nil || seen[id] {
+				continue
+			}
+			if beforeID != 0 && id >= beforeID {
+				continue
+			}
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+	if int64(len(ids)) > size {
+		ids = ids[:size]
+	}
+	return ids, nil
+}
+
+// GetUserPostCount 用户发布过的帖子数
+func GetUserPostCount(uid int64) (int64, error) {
+	return client.ZCard(getRedisKey(KeyUserPostZSetPF + strconv.FormatInt(uid, 10))).Result()
+}
+
+// GetCommunityPostIDsSince 社区中since之后发布的帖子，最新的在前，最多limit个
+func GetCommunityPostIDsSince(communityID int64, since time.Time, limit int64) ([]string, error) {
+	key, err := communityPostsInOrder(models.OrderTime, communityID)
+	if err != nil {
+		return nil, err
+	}
+	return client.ZRevRangeByScore(key, redis.ZRangeBy{
+		Max:   "+inf",
+		Min:   strconv.FormatInt(since.Unix(), 10),
+		Count: limit,
+	}).Result()
+}
+
+// GetUserPostIDs 用户发布的所有帖子id
+func GetUserPostIDs(uid int64) ([]string, error) {
+	return client.ZRange(getRedisKey(KeyUserPostZSetPF+strconv.FormatInt(uid, 10)), 0, -1).Result()
+}
+
+// GetUserPostIDsInOrder 用户发布的帖子按order分页，最新或分数最高的在前，同时返回帖子总数
+// 按分数排序时和帖子的分数集合求交集，结果缓存一分钟
+func GetUserPostIDsInOrder(uid int64, order string, page, size int64) ([]string, int64, error) {
+	key := getRedisKey(KeyUserPostZSetPF + strconv.FormatInt(uid, 10))
+	total, err := client.ZCard(key).Result()
+	if err != nil || total == 0 {
+		return nil, total, err
+	}
+	if order == models.OrderScore {
+		orderKey := getRedisKey(KeyUserPostOrderPF + strconv.FormatInt(uid, 10) + ":" + order)
+		if client.Exists(orderKey).Val() < 1 {
+			pipeline := client.Pipeline()
+			pipeline.ZInterStore(orderKey, redis.ZStore{
+				Weights:   []float64{0, 1},
+				Aggregate: "SUM",
+			}, key, getRedisKey(KeyPostScoreZSet))
+			pipeline.Expire(orderKey, 60*time.Second)
+			if _, err := pipeline.Exec(); err != nil {
+				return nil, 0, err
+			}
+		}
+		key = orderKey
+	}
+	start := (page - 1) * size
+	ids, err := client.ZRevRange(key, start, start+size-1).Result()
+	return ids, total, err
+}
+
+// RemoveUserPost 作者删除帖子后从作者的列表中移除，恢复时由RestorePostToFeeds重新加入
+func RemoveUserPost(uid, pid int64) error {
+	suffix := strconv.FormatInt(uid, 10)
+	pipeline := client.TxPipeline()
+	pipeline.ZRem(getRedisKey(KeyUserPostZSetPF+suffix), pid)
+	pipeline.Del(getRedisKey(KeyUserPostOrderPF + suffix + ":" + models.OrderScore))
+	_, err := pipeline.Exec()
+	return err
+}
@@ -0,0 +1,85 @@
+package redis
+
+import (
+	"go-web-app/pkg/snowflake"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetUserPostIDsInOrder(t *testing.T) {
+	useMiniredis(t)
+	userKey := getRedisKey(KeyUserPostZSetPF + "7")
+	client.ZAdd(userKey, redis.Z{Score: 1000, Member: "1"}, redis.Z{Score: 2000, Member: "2"}, redis.Z{Score: 3000, Member: "3"})
+	// 其他用户的帖子不出现在列表中
+	client.ZAdd(getRedisKey(KeyPostScoreZSet), redis.Z{Score: 30, Member: "1"}, redis.Z{Score: 10, Member: "2"},
+		redis.Z{Score: 20, Member: "3"}, redis.Z{Score: 99, Member: "4"})
+
+	ids, total, err := GetUserPostIDsInOrder(7, "time", 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	assert.Equal(t, []string{"3", "2"}, ids)
+	ids, _, err = GetUserPostIDsInOrder(7, "time", 2, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1"}, ids)
+
+	ids, _, err = GetUserPostIDsInOrder(7, "score", 1, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "3", "2"}, ids)
+
+	require.NoError(t, RemoveUserPost(7, 1))
+	ids, total, err = GetUserPostIDsInOrder(7, "score", 1, 3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, []string{"3", "2"}, ids)
+
+	ids, total, err = GetUserPostIDsInOrder(8, "time", 1, 3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), total)
+	assert.Empty(t, ids)
+}
+
+func TestGetFeedPostIDsMergesAuthorsAndCommunities(t *testing.T) {
+	useMiniredis(t)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	id := func(ms, seq int64) int64 {
+		return snowflake.MinID(base.Add(time.Duration(ms)*time.Millisecond)) + seq
+	}
+	a1, c2, a3, c4 := id(1000, 0), id(2000, 0), id(3000, 0), id(3000, 1)
+	for _, pid := range []int64{a1, a3} {
+		client.ZAdd(getRedisKey(KeyUserPostZSetPF+"7"), redis.Z{Score: feedScore(pid), Member: pid})
+	}
+	// 社区的动态在第一次读取时从社区的帖子集合建立，a3同时在作者和社区中
+	client.SAdd(getCommunitySetKey(5), c2, a3, c4)
+
+	ids, err := GetFeedPostIDs([]int64{7}, []int64{5}, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{c4, a3, c2, a1}, ids)
+	score, err := client.ZScore(getCommunityFeedKey(5), strconv.FormatInt(c4, 10)).Result()
+	require.NoError(t, err)
+	assert.Equal(t, feedScore(c4), score)
+
+	// 游标和分数使用同样的换算，同一毫秒内按id过滤
+	ids, err = GetFeedPostIDs([]int64{7}, []int64{5}, c4, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{a3, c2}, ids)
+	ids, err = GetFeedPostIDs([]int64{7}, []int64{5}, c2, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{a1}, ids)
+
+	// 已经建立的社区动态随帖子加入和移出社区更新
+	c5 := id(4000, 0)
+	require.NoError(t, AddPostToCommunities(c5, []int64{5}))
+	require.NoError(t, RemovePostFromCommunities(c2, []int64{5}))
+	ids, err = GetFeedPostIDs(nil, []int64{5}, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{c5, c4, a3}, ids)
+
+	ids, err = GetFeedPostIDs(nil, nil, 0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+}
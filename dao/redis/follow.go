@@ -0,0 +1,56 @@
+package redis
+
+import (
+	"go-web-app/models"
+	"strconv"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	fieldFollowers = "followers"
+	fieldFollowing = "following"
+
+	// 只更新已经存在的计数，不存在的计数在读取时从数据库重建
+	incrIfExistsScript = `if redis.call("EXISTS", KEYS[1]) == 1 then return redis.call("HINCRBY", KEYS[1], ARGV[1], ARGV[2]) end return 0`
+)
+
+func getFollowCountKey(uid int64) string {
+	return getRedisKey(KeyUserFollowCountHashPF + strconv.FormatInt(uid, 10))
+}
+
+// IncrFollowCount 关注/取消关注时同时更新双方的计数，delta为1或-1
+func IncrFollowCount(followerID, followeeID int64, delta int64) error {
+	pipeline := client.TxPipeline()
+	for _, kv := range []struct {
+		uid   int64
+		field string
+	}{{followerID, fieldFollowing}, {followeeID, fieldFollowers}} {
+		key := getFollowCountKey(kv.uid)
+		pipeline.Eval(incrIfExistsScript, []string{key}, kv.field, delta)
+	}
+	_, err := pipeline.Exec()
+	return err
+}
+
+// GetFollowCount 读取计数，计数不存在时返回redis.Nil
+func GetFollowCount(uid int64) (*models.FollowCount, error) {
+	vals, err := client.HMGet(getFollowCountKey(uid), fieldFollowers, fieldFollowing).Result()
+	if err != nil {
+		return nil, err
+	}
+	if vals[0] == nil || vals[1] == nil {
+		return nil, redis.Nil
+	}
+	count := new(models.FollowCount)
+	count.Followers, _ = strconv.ParseInt(vals[0].(string), 10, 64)
+	count.Following, _ = strconv.ParseInt(vals[1].(string), 10, 64)
+	return count, nil
+}
+
+func SetFollowCount(uid int64, count *models.FollowCount) error {
+	return client.HMSet(getFollowCountKey(uid), map[string]interface{}{
+		fieldFollowers: count.Followers,
+		fieldFollowing: count.Following,
+	}).Err()
+}
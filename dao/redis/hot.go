@@ -0,0 +1,129 @@
+package redis
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// HotScore 参考Hacker News的排序算法: (votes-1)/(ageHours+2)^gravity。
+// HN的votes包含发帖人自己的一票，这里的netVotes是其他用户的赞成票减反对票，相当于votes-1。
+func HotScore(netVotes int64, age time.Duration, gravity float64) float64 {
+	ageHours := age.Hours()
+	if ageHours < 0 {
+		ageHours = 0
+	}
+	return float64(netVotes) / math.Pow(ageHours+2, gravity)
+}
+
+// UpdatePostHotScore 根据当前的投票情况和帖子年龄重新计算帖子的热度分数。
+// 每次投票后调用，只更新被投票的帖子，不需要全量重新计算。
+func UpdatePostHotScore(postID string, gravity float64) error {
+	votedKey := getRedisKey(KeyPostVotedZSetPF + postID)
+	pipeline := client.Pipeline()
+	postTime := pipeline.ZScore(getRedisKey(KeyPostTimeZSet), postID)
+	up := pipeline.ZCount(votedKey, "1", "1")
+	down := pipeline.ZCount(votedKey, "-1", "-1")
+	if _, err := pipeline.Exec(); err != nil {
+		return err
+	}
+	age := time.Since(time.Unix(int64(postTime.Val()), 0))
+	score := HotScore(up.Val()-down.Val(), age, gravity)
+	return client.ZAdd(getRedisKey(KeyPostHotZSet), redis.Z{
+		Score:  score,
+		Member: postID,
+	}).Err()
+}
+
+// hotRefreshBatch 每个pipeline重新计算多少个帖子的热度
+const hotRefreshBatch = 1000
+
+// RefreshHotScores 按当前时间重新计算since之后发布的帖子的热度，返回计算的帖子数，since为零值时计算所有帖子。
+// 投票时只按当时的年龄计算，很久没有投票的帖子会一直保留较高的分数，需要定期按年龄重新衰减
+func RefreshHotScores(ctx context.Context, since time.Time, gravity float64) (int, error) {
+	min := "-inf"
+	if !since.IsZero() {
+		min = strconv.FormatInt(since.Unix(), 10)
+	}
+	c := withContext(ctx)
+	posts, err := c.ZRangeByScoreWithScores(getRedisKey(KeyPostTimeZSet), redis.ZRangeBy{Min: min, Max: "+inf"}).Result()
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	hotKey := getRedisKey(KeyPostHotZSet)
+	for start := 0; start < len(posts); start += hotRefreshBatch {
+		if err := ctx.Err(); err != nil {
+			return start, err
+		}
+		end := start + hotRefreshBatch
+		if end > len(posts) {
+			end = len(posts)
+		}
+		batch := posts[start:end]
+		pipeline := c.Pipeline()
+		ups := make([]*redis.IntCmd, 0, len(batch))
+		downs := make([]*redis.IntCmd, 0, len(batch))
+		for _, z := range batch {
+			votedKey := getRedisKey(KeyPostVotedZSetPF + z.Member.(string))
+			ups = append(ups, pipeline.ZCount(votedKey, "1", "1"))
+			downs = append(downs, pipeline.ZCount(votedKey, "-1", "-1"))
+		}
+		if _, err := pipeline.Exec(); err != nil {
+			return 0, err
+		}
+		pipeline = c.Pipeline()
+		for i, z := range batch {
+			// 只更新已经在热度排序中的帖子，计算期间被移除的帖子不会被加回来
+			pipeline.ZAddXX(hotKey, redis.Z{
+				Score:  HotScore(ups[i].Val()-downs[i].Val(), now.Sub(time.Unix(int64(z.Score), 0)), gravity),
+				Member: z.Member,
+			})
+		}
+		if _, err := pipeline.Exec(); err != nil {
+			return 0, err
+		}
+	}
+	return len(posts), nil
+}
+
+// ControversyScore (up+down)*min(up,down)/max(up,down)，赞成和反对票越多且越接近，分数越高
+func ControversyScore(up, down int64) float64 {
+	if up <= 0 || down <= 0 {
+		return 0
+	}
+	return float64(up+down) * math.Min(float64(up), float64(down)) / math.Max(float64(up), float64(down))
+}
+
+// wilsonZ 置信度80%对应的z值
+const wilsonZ = 1.281551565545
+
+// WilsonScore 赞成票比例的Wilson置信区间下限，票数少时偏向保守，用于评论的best排序
+func WilsonScore(up, down int64) float64 {
+	n := float64(up + down)
+	if n <= 0 {
+		return 0
+	}
+	p := float64(up) / n
+	z2 := wilsonZ * wilsonZ
+	return (p + z2/(2*n) - wilsonZ*math.Sqrt((p*(1-p)+z2/(4*n))/n)) / (1 + z2/n)
+}
+
+// UpdatePostControversyScore 根据分开计数的票数重新计算争议度，总票数少于minVotes时从列表中移除
+func UpdatePostControversyScore(postID string, minVotes int64) error {
+	up, down, err := GetPostVoteCount(postID)
+	if err != nil {
+		return err
+	}
+	key := getRedisKey(KeyPostControZSet)
+	if up+down < minVotes {
+		return client.ZRem(key, postID).Err()
+	}
+	return client.ZAdd(key, redis.Z{
+		Score:  ControversyScore(up, down),
+		Member: postID,
+	}).Err()
+}
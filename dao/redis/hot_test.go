@@ -0,0 +1,69 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHotScore(t *testing.T) {
+	// 票数相同时，越新的帖子越热
+	assert.Greater(t, HotScore(10, time.Hour, 1.8), HotScore(10, 10*time.Hour, 1.8))
+	// 年龄相同时，票数越多越热
+	assert.Greater(t, HotScore(20, time.Hour, 1.8), HotScore(10, time.Hour, 1.8))
+	// gravity越大，衰减越快
+	assert.Greater(t, HotScore(10, 5*time.Hour, 1.5), HotScore(10, 5*time.Hour, 2))
+	assert.Equal(t, float64(0), HotScore(0, time.Hour, 1.8))
+	assert.InDelta(t, 10/4.0, HotScore(10, 0, 2), 1e-9)
+}
+
+func TestControversyScore(t *testing.T) {
+	// 总票数相同时，赞成和反对越接近越有争议
+	assert.Greater(t, ControversyScore(50, 50), ControversyScore(90, 10))
+	// 比例相同时，票数越多越有争议
+	assert.Greater(t, ControversyScore(100, 100), ControversyScore(10, 10))
+	assert.Equal(t, float64(0), ControversyScore(10, 0))
+	assert.InDelta(t, 40*10/30.0, ControversyScore(30, 10), 1e-9)
+}
+
+func TestWilsonScore(t *testing.T) {
+	// 比例相同时，票数越多下限越高
+	assert.Greater(t, WilsonScore(100, 10), WilsonScore(10, 1))
+	// 一票赞成不如大量票数中的高比例
+	assert.Greater(t, WilsonScore(90, 10), WilsonScore(1, 0))
+	assert.Greater(t, WilsonScore(10, 1), WilsonScore(5, 5))
+	assert.Equal(t, float64(0), WilsonScore(0, 0))
+	assert.Less(t, WilsonScore(1, 0), float64(1))
+}
+
+func TestRefreshHotScores(t *testing.T) {
+	useMiniredis(t)
+	now := time.Now()
+	timeKey, hotKey := getRedisKey(KeyPostTimeZSet), getRedisKey(KeyPostHotZSet)
+	// 1是10小时前发布的，分数还是发布1小时后投票时计算的；2刚发布；3超出了重新计算的范围；4已经不在热度排序中
+	client.ZAdd(timeKey,
+		redis.Z{Score: float64(now.Add(-10 * time.Hour).Unix()), Member: "1"},
+		redis.Z{Score: float64(now.Unix()), Member: "2"},
+		redis.Z{Score: float64(now.Add(-48 * time.Hour).Unix()), Member: "3"},
+		redis.Z{Score: float64(now.Unix()), Member: "4"})
+	client.ZAdd(hotKey, redis.Z{Score: HotScore(3, time.Hour, 1.8), Member: "1"},
+		redis.Z{Score: 0, Member: "2"}, redis.Z{Score: 5, Member: "3"})
+	client.ZAdd(getRedisKey(KeyPostVotedZSetPF+"1"), redis.Z{Score: 1, Member: "7"}, redis.Z{Score: 1, Member: "8"},
+		redis.Z{Score: 1, Member: "9"})
+	client.ZAdd(getRedisKey(KeyPostVotedZSetPF+"2"), redis.Z{Score: 1, Member: "7"})
+
+	// 衰减之前1排在2前面
+	assert.Equal(t, []string{"3", "1", "2"}, client.ZRevRange(hotKey, 0, -1).Val())
+	n, err := RefreshHotScores(context.Background(), now.Add(-24*time.Hour), 1.8)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.InDelta(t, HotScore(3, 10*time.Hour, 1.8), client.ZScore(hotKey, "1").Val(), 1e-3)
+	assert.InDelta(t, HotScore(1, 0, 1.8), client.ZScore(hotKey, "2").Val(), 1e-3)
+	assert.Equal(t, float64(5), client.ZScore(hotKey, "3").Val())
+	assert.Equal(t, redis.Nil, client.ZScore(hotKey, "4").Err())
+	assert.Equal(t, []string{"3", "2", "1"}, client.ZRevRange(hotKey, 0, -1).Val())
+}
@@ -0,0 +1,52 @@
+package redis
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// 幂等键的值为"请求指纹:帖子id"，帖子还没创建完成时帖子id为0
+
+func getIdempotencyKey(userID int64, key string) string {
+	return getRedisKey(KeyIdempotencyPF + strconv.FormatInt(userID, 10) + ":" + key)
+}
+
+// 键不存在时写入并返回nil，已存在时返回当前的值
+var reserveIdempotencyKeyScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return false
+end
+return redis.call("GET", KEYS[1])
+`)
+
+// ReserveIdempotencyKey 第一次使用幂等键时占用它并返回reserved=true；
+// 已经被使用过时返回之前请求的指纹和帖子id，帖子id为0表示之前的请求还在处理中
+func ReserveIdempotencyKey(userID int64, key, fingerprint string, ttl time.Duration) (reserved bool, oldFingerprint string, postID int64, err error) {
+	val, err := reserveIdempotencyKeyScript.Run(client, []string{getIdempotencyKey(userID, key)},
+		fingerprint+":0", ttl.Milliseconds()).String()
+	if err == redis.Nil {
+		return true, "", 0, nil
+	}
+	if err != nil {
+		return false, "", 0, err
+	}
+	idx := strings.LastIndexByte(val, ':')
+	if idx < 0 {
+		return false, val, 0, nil
+	}
+	postID, _ = strconv.ParseInt(val[idx+1:], 10, 64)
+	return false, val[:idx], postID, nil
+}
+
+// SetIdempotencyResult 保存请求的结果，重试时直接返回
+func SetIdempotencyResult(userID int64, key, fingerprint string, postID int64, ttl time.Duration) error {
+	return client.Set(getIdempotencyKey(userID, key), fingerprint+":"+strconv.FormatInt(postID, 10), ttl).Err()
+}
+
+// ReleaseIdempotencyKey 请求失败后释放幂等键，客户端可以用同一个键重试
+func ReleaseIdempotencyKey(userID int64, key string) error {
+	return client.Del(getIdempotencyKey(userID, key)).Err()
+}
@@ -0,0 +1,55 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	mr := useMiniredis(t)
+	reserved, _, _, err := ReserveIdempotencyKey(1, "k", "fp", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+
+	// 第一次的请求还没处理完时帖子id为0
+	reserved, fp, postID, err := ReserveIdempotencyKey(1, "k", "other", time.Hour)
+	require.NoError(t, err)
+	assert.False(t, reserved)
+	assert.Equal(t, "fp", fp)
+	assert.Zero(t, postID)
+
+	require.NoError(t, SetIdempotencyResult(1, "k", "fp", 42, time.Hour))
+	reserved, fp, postID, err = ReserveIdempotencyKey(1, "k", "fp", time.Hour)
+	require.NoError(t, err)
+	assert.False(t, reserved)
+	assert.Equal(t, "fp", fp)
+	assert.EqualValues(t, 42, postID)
+	// 不同用户的同一个键互不影响
+	reserved, _, _, err = ReserveIdempotencyKey(2, "k", "fp", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+
+	require.NoError(t, ReleaseIdempotencyKey(1, "k"))
+	reserved, _, _, err = ReserveIdempotencyKey(1, "k", "fp", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+
+	// 过期之后可以重新使用
+	mr.FastForward(time.Hour + time.Second)
+	reserved, _, _, err = ReserveIdempotencyKey(1, "k", "new", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+}
+
+func TestIdempotencyKeyRedisDown(t *testing.T) {
+	mr := useMiniredis(t)
+	mr.Close()
+	reserved, _, _, err := ReserveIdempotencyKey(1, "k", "fp", time.Hour)
+	assert.Error(t, err)
+	assert.False(t, reserved)
+	assert.Error(t, SetIdempotencyResult(1, "k", "fp", 42, time.Hour))
+	assert.Error(t, ReleaseIdempotencyKey(1, "k"))
+}
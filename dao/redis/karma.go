@@ -0,0 +1,172 @@
+package redis
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// 用户不在zset中时返回nil，由调用方从mysql读出base后再次调用，避免redis数据丢失后从0开始计数
+// 同时计入当天的分桶，用于最近几天的排行榜
+var incrKarmaScript = redis.NewScript(`
+if not redis.call("ZSCORE", KEYS[1], ARGV[1]) then
+	if ARGV[3] == "" then
+		return false
+	end
+	redis.call("ZADD", KEYS[1], "NX", ARGV[3], ARGV[1])
+end
+local karma = redis.call("ZINCRBY", KEYS[1], ARGV[2], ARGV[1])
+redis.call("SADD", KEYS[2], ARGV[1])
+redis.call("ZINCRBY", KEYS[3], ARGV[2], ARGV[1])
+redis.call("EXPIRE", KEYS[3], ARGV[4])
+return karma
+`)
+
+// karmaBucketKey 每天一个有序集合，分数为这一天内声望的变化
+func karmaBucketKey(t time.Time) string {
+	return getRedisKey(KeyUserKarmaDayPF + strconv.FormatInt(t.Unix()/86400, 10))
+}
+
+// IncrKarma 增加用户的声望并标记为待写回，用户不在redis中且base为nil时返回redis.Nil
+// 当天的分桶在bucketTTL之后过期
+func IncrKarma(uid, delta int64, base *int64, bucketTTL time.Duration) (karma int64, err error) {
+	baseArg := ""
+	if base != nil {
+		baseArg = strconv.FormatInt(*base, 10)
+	}
+	keys := []string{getRedisKey(KeyUserKarmaZSet), getRedisKey(KeyUserKarmaDirtySet), karmaBucketKey(time.Now())}
+	return incrKarmaScript.Run(client, keys, uid, delta, baseArg, int64(bucketTTL.Seconds())).Int64()
+}
+
+// GetKarma 用户的声望，不在redis中时返回redis.Nil
+func GetKarma(uid int64) (int64, error) {
+	score, err := client.ZScore(getRedisKey(KeyUserKarmaZSet), strconv.FormatInt(uid, 10)).Result()
+	return int64(score), err
+}
+
+// GetKarmas 批量查询用户的声望，不在redis中的用户声望为0
+func GetKarmas(uids []int64) (map[int64]int64, error) {
+	karma := make(map[int64]int64, len(uids))
+	if len(uids) == 0 {
+		return karma, nil
+	}
+	pipeline := client.Pipeline()
+	cmds := make([]*redis.FloatCmd, len(uids))
+	for i, uid := range uids {
+		cmds[i] = pipeline.ZScore(getRedisKey(KeyUserKarmaZSet), strconv.FormatInt(uid, 10))
+	}
+	if _, err := pipeline.Exec(); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	for i, uid := range uids {
+		karma[uid] = int64(cmds[i].Val())
+	}
+	return karma, nil
+}
+
+// KarmaLoaded 声望的zset是否存在，不存在说明redis的数据丢失了，需要从mysql重建
+func KarmaLoaded() (bool, error) {
+	n, err := client.Exists(getRedisKey(KeyUserKarmaZSet)).Result()
+	return n > 0, err
+}
+
+// LoadKarma 从mysql重建声望，已经存在的用户不会被覆盖
+func LoadKarma(karma map[int64]int64) error {
+	if len(karma) == 0 {
+		return nil
+	}
+	members := make([]redis.Z, 0, len(karma))
+	for uid, v := range karma {
+		members = append(members, redis.Z{Score: float64(v), Member: uid})
+	}
+	return client.ZAddNX(getRedisKey(KeyUserKarmaZSet), members...).Err()
+}
+
+// PopDirtyKarma 取出最多count个待写回的用户和他们当前的声望
+func PopDirtyKarma(count int64) (map[int64]int64, error) {
+	members, err := client.SPopN(getRedisKey(KeyUserKarmaDirtySet), count).Result()
+	if err != nil || len(members) == 0 {
+		return nil, err
+	}
+	pipeline := client.Pipeline()
+	cmds := make([]*redis.FloatCmd, 0, len(members))
+	for _, m := range members {
+		cmds = append(cmds, pipeline.ZScore(getRedisKey(KeyUserKarmaZSet), m))
+	}
+	if _, err = pipeline.Exec(); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	karma := make(map[int64]int64, len(members))
+	for i, m := range members {
+		uid, err := strconv.ParseInt(m, 10, 64)
+		if err != nil || cmds[i].Err() != nil {
+			continue
+		}
+		karma[uid] = int64(cmds[i].Val())
+	}
+	return karma, nil
+}
+
+// MarkKarmaDirty 写回mysql失败时重新标记，下次再写
+func MarkKarmaDirty(uids []int64) error {
+	if len(uids) == 0 {
+		return nil
+	}
+	members := make([]interface{}, 0, len(uids))
+	for _, uid := range uids {
+		members = append(members, uid)
+	}
+	return client.SAdd(getRedisKey(KeyUserKarmaDirtySet), members...).Err()
+}
+
+// getRecentKarmaKey 合并最近days天的分桶，合并结果缓存一分钟
+func getRecentKarmaKey(days int) (string, error) {
+	key := getRedisKey(KeyUserKarmaDayPF + "top:" + strconv.Itoa(days))
+	if client.Exists(key).Val() > 0 {
+		return key, nil
+	}
+	now := time.Now()
+	keys := make([]string, 0, days)
+	for i := 0; i < days; i++ {
+		keys = append(keys, karmaBucketKey(now.Add(-time.Duration(i)*24*time.Hour)))
+	}
+	pipeline := client.Pipeline()
+	pipeline.ZUnionStore(key, redis.ZStore{}, keys...)
+	pipeline.Expire(key, 60*time.Second)
+	_, err := pipeline.Exec()
+	return key, err
+}
+
+// leaderboardKey days为0时使用总声望，否则使用最近days天的声望变化
+func leaderboardKey(days int) (string, error) {
+	if days == 0 {
+		return getRedisKey(KeyUserKarmaZSet), nil
+	}
+	return getRecentKarmaKey(days)
+}
+
+// GetKarmaLeaderboard 声望最高的size个用户，顺序即排名
+func GetKarmaLeaderboard(days int, size int64) ([]redis.Z, error) {
+	key, err := leaderboardKey(days)
+	if err != nil {
+		return nil, err
+	}
+	return client.ZRevRangeWithScores(key, 0, size-1).Result()
+}
+
+// GetKarmaRank 用户在排行榜中的排名(从1开始)和声望，不在榜上时返回redis.Nil
+func GetKarmaRank(days int, uid int64) (rank, karma int64, err error) {
+	key, err := leaderboardKey(days)
+	if err != nil {
+		return 0, 0, err
+	}
+	member := strconv.FormatInt(uid, 10)
+	pipeline := client.Pipeline()
+	rankCmd := pipeline.ZRevRank(key, member)
+	scoreCmd := pipeline.ZScore(key, member)
+	if _, err = pipeline.Exec(); err != nil {
+		return 0, 0, err
+	}
+	return rankCmd.Val() + 1, int64(scoreCmd.Val()), nil
+}
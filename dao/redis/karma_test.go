@@ -0,0 +1,112 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncrKarma(t *testing.T) {
+	useMiniredis(t)
+	loaded, err := KarmaLoaded()
+	require.NoError(t, err)
+	assert.False(t, loaded)
+
+	// 不在redis中且没有base时由调用方从数据库读取
+	_, err = IncrKarma(1, 3, nil, time.Hour)
+	assert.Equal(t, redis.Nil, err)
+	_, err = GetKarma(1)
+	assert.Equal(t, redis.Nil, err)
+
+	base := int64(10)
+	karma, err := IncrKarma(1, 3, &base, time.Hour)
+	require.NoError(t, err)
+	assert.EqualValues(t, 13, karma)
+	karma, err = IncrKarma(1, -1, nil, time.Hour)
+	require.NoError(t, err)
+	assert.EqualValues(t, 12, karma)
+
+	// 重建时不覆盖已经存在的用户
+	require.NoError(t, LoadKarma(map[int64]int64{1: 100, 2: 5}))
+	loaded, err = KarmaLoaded()
+	require.NoError(t, err)
+	assert.True(t, loaded)
+	karmas, err := GetKarmas([]int64{1, 2, 3})
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]int64{1: 12, 2: 5, 3: 0}, karmas)
+
+	dirty, err := PopDirtyKarma(10)
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]int64{1: 12}, dirty)
+	dirty, err = PopDirtyKarma(10)
+	require.NoError(t, err)
+	assert.Empty(t, dirty)
+
+	require.NoError(t, MarkKarmaDirty([]int64{1, 2}))
+	dirty, err = PopDirtyKarma(10)
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]int64{1: 12, 2: 5}, dirty)
+}
+
+func TestIncrKarmaRedisDown(t *testing.T) {
+	mr := useMiniredis(t)
+	mr.Close()
+	base := int64(10)
+	_, err := IncrKarma(1, 3, &base, time.Hour)
+	assert.Error(t, err)
+	assert.NotEqual(t, redis.Nil, err)
+	_, err = GetKarmas([]int64{1})
+	assert.Error(t, err)
+	_, err = PopDirtyKarma(10)
+	assert.Error(t, err)
+	assert.Error(t, MarkKarmaDirty([]int64{1}))
+}
+
+func TestKarmaLeaderboard(t *testing.T) {
+	useMiniredis(t)
+	require.NoError(t, LoadKarma(map[int64]int64{1: 100, 2: 50}))
+	// 最近几天的排行榜只统计这段时间内的变化
+	for uid, delta := range map[int64]int64{1: 1, 2: 5, 3: 3} {
+		base := int64(0)
+		_, err := IncrKarma(uid, delta, &base, time.Hour)
+		require.NoError(t, err)
+	}
+
+	all, err := GetKarmaLeaderboard(0, 2)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, "1", all[0].Member)
+	assert.EqualValues(t, 101, all[0].Score)
+	assert.Equal(t, "2", all[1].Member)
+
+	week, err := GetKarmaLeaderboard(7, 10)
+	require.NoError(t, err)
+	require.Len(t, week, 3)
+	assert.Equal(t, []interface{}{"2", "3", "1"}, []interface{}{week[0].Member, week[1].Member, week[2].Member})
+
+	rank, karma, err := GetKarmaRank(7, 3)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, rank)
+	assert.EqualValues(t, 3, karma)
+	rank, karma, err = GetKarmaRank(0, 3)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, rank)
+	assert.EqualValues(t, 3, karma)
+	_, _, err = GetKarmaRank(0, 4)
+	assert.Equal(t, redis.Nil, err)
+}
+
+func TestKarmaLeaderboardRedisDown(t *testing.T) {
+	mr := useMiniredis(t)
+	mr.Close()
+	_, err := GetKarmaLeaderboard(0, 10)
+	assert.Error(t, err)
+	_, err = GetKarmaLeaderboard(7, 10)
+	assert.Error(t, err)
+	_, _, err = GetKarmaRank(0, 1)
+	assert.Error(t, err)
+	assert.NotEqual(t, redis.Nil, err)
+}
@@ -1,12 +1,109 @@
 package redis
 
 const (
-	Prefix             = "bluebell:"
-	KeyPostTimeZSet    = "post:time"
-	KeyPostScoreZSet   = "post:score"
-	KeyPostVotedZSetPF = "post:voted:"
+	Prefix               = "bluebell:"
+	KeyPostTimeZSet      = "post:time"
+	KeyPostScoreZSet     = "post:score"
+	KeyPostScoreDirtySet = "post:score:dirty" // 分数有变化但还没有写回mysql的帖子id
+	KeyPostHotZSet       = "post:hot"
+	KeyPostVotedZSetPF   = "post:voted:"
+	KeyPostVoteCountPF   = "post:votes:"        // 帖子的赞成和反对票数，后缀为帖子id，field: up, down
+	KeyPostVoteWeightPF  = "post:vote:weight:"  // 每一票计入排序分数时的权重，后缀为帖子id，field为用户id，没有记录的按1计算
+	KeyPostControZSet    = "post:controversial" // 争议度分数，总票数少于post.controversial_min_votes的帖子不在其中
+	KeyPostDetailPF      = "post:detail:"       // 帖子详情的缓存
+	KeyPostRelatedPF     = "post:related:"      // 相关帖子的id列表，后缀为帖子id
+	KeyPostDigestPF      = "post:digest:"       // 最近发布的帖子内容的摘要，后缀为<用户id>:<摘要>或any:<摘要>，值为帖子id
+	KeyPollTallyPF       = "poll:tally:"        // 投票每个选项的票数，后缀为帖子id，field: 选项下标
+	KeyPollVoterPF       = "poll:voter:"        // 已经投票的用户，后缀为帖子id，field: 用户id，值为选项下标
+	KeyCommentVotedPF    = "comment:voted:"     // 评论的投票记录，member为用户id，分数为投票值
+	KeyCommentScoreHash  = "comment:score"      // field: 评论id，值为赞成票数减反对票数
+	KeyCommentUpDownPF   = "comment:votes:"     // 评论的赞成和反对票数，后缀为评论id，field: up, down
+	KeyVoteBurstPF       = "vote:burst:"        // 可疑账号最近的投票，后缀为post:<id>:<方向>或comment:<id>:<方向>，分数为投票时间(毫秒)
+	KeyVoteBrigadePF     = "vote:brigade:"      // 被判定为刷票的帖子或评论，后缀为post:<id>或comment:<id>
+	KeyLinkMetaPF        = "link:meta:"         // 外部链接的OpenGraph信息，后缀为链接的sha1
+	KeyTagPostZSetPF     = "tag:posts:"         // 标签下的帖子，分数为发布时间
+	KeyTagTrendingPF     = "tag:trending:"      // 按小时分桶的标签活跃次数，后缀为小时数
+	KeyTagFollowerSetPF  = "tag:followers:"     // 关注标签的用户id，后缀为标签名
+	KeyTagFollowsLoaded  = "tag:follows:loaded" // 已经从mysql重建了标签的关注关系
+	KeyTagHotNotifiedPF  = "tag:hot:notified:"  // 已经通知过关注者的热门帖子，后缀为帖子id
 
-	KeyCommunitySetPF = "community:"
+	KeyCommunitySetPF           = "community:"
+	KeyCommunityFeedZSetPF      = "community:feed:"        // 社区的帖子，用于首页动态，后缀为社区id，分数为帖子id中的时间(毫秒)
+	KeyCommunityDetailPF        = "community:detail:"      // 社区信息的缓存，后缀为社区id
+	KeyCommunityMemberCountHash = "community:member:count" // field: 社区id
+	KeyCommunityPinnedPF        = "community:pinned:"      // 社区置顶的帖子，后缀为社区id，分数为置顶时间
+	KeyCommunityFeatured        = "community:featured"     // 首页推荐的社区id列表，JSON数组
+	KeyCommunityFlairSetPF      = "community:flair:"       // 主社区中带某个flair的帖子，后缀为<社区id>:<flair>
+	KeyCommunityTrendingPF      = "community:trending:"    // 按小时分桶的社区活跃度，后缀为小时数
+	KeyUserPostZSetPF           = "user:posts:"            // 用户发布的帖子，分数为帖子id中的时间(毫秒)
+	KeyUserPostOrderPF          = "user:posts:order:"      // 用户的帖子按分数排序后的缓存，后缀为<用户id>:<排序方式>
+	KeyUserVotedZSetPF          = "user:voted:"            // 用户投过票的帖子，后缀为<用户id>:up或<用户id>:down，分数为投票时间(毫秒)
+	KeyUserVotedBackfilled      = "user:voted:backfilled"  // 已经按帖子的投票记录补充了用户的投票记录
+	KeyUserTagSetPF             = "user:tags:"             // 用户关注的标签，后缀为用户id
+
+	KeyUserFollowCountHashPF = "user:follow:count:" // field: followers, following
+	KeyUserBlockedSetPF      = "user:blocked:"      // 用户屏蔽的用户id，包含一个占位成员"0"
+	KeyUserMutedSetPF        = "user:muted:"        // 用户在信息流中隐藏的社区id，不会过期
+	KeyUserKarmaZSet         = "user:karma"         // 用户的声望，分数为声望值
+	KeyUserKarmaDirtySet     = "user:karma:dirty"   // 声望有变化但还没有写回mysql的用户id
+	KeyUserKarmaDayPF        = "user:karma:day:"    // 按天分桶的声望变化，后缀为天数
+
+	KeyRefreshTokenPF  = "refresh:" // 没有会话id的旧refresh token，后缀为用户id
+	KeyPasswordResetPF = "password:reset:"
+	KeyVerifyEmailPF   = "verify:email:"
+	KeyVerifyResendPF  = "verify:resend:"
+	KeyReactivatePF    = "reactivate:"
+	KeyOAuthStatePF    = "oauth:state:" // 第三方登录的state，防止CSRF
+	KeyMergeTokenPF    = "merge:"       // 合并账号时来源账号生成的确认token，值为来源账号的用户id
+
+	KeySessionPF         = "session:"       // 登录会话，field: token, lifetime, user_agent, ip, create_time, last_seen，后缀为用户id:会话id
+	KeyUserSessionZSetPF = "user:sessions:" // 用户的会话id，分数为会话的过期时间(毫秒)
+
+	KeyTwoFactorChallengePF = "2fa:challenge:" // 两步验证登录的临时token，field: uid, lifetime, attempts
+	KeyTOTPUsedPF           = "2fa:used:"      // 已经使用过的验证码，后缀为用户id:周期，防止重放
+
+	KeyVoteUndoPF = "vote:undo:" // 可以撤销的投票，后缀为post:帖子id:用户id或comment:评论id:用户id，JSON，过期时间为撤销窗口
+
+	KeyUserDeactivatedSet = "user:deactivated" // 已注销的用户id，认证时检查
+	KeyUserBannedSet      = "user:banned"      // 被封禁的用户id，认证时检查
+
+	KeyRateLimitPF = "ratelimit:"
+	KeyLoginFailPF = "login:fail:" // 登录失败次数，后缀为ip:<ip>、user:<用户名>或两步验证的2fa:<用户id>
+	KeyLoginLockPF = "login:lock:" // 登录失败过多时的锁，后缀同上
+
+	KeyIdempotencyPF = "idempotency:post:" // 创建帖子的幂等键，后缀为用户id:键
+
+	KeyExportPF      = "export:"       // 数据导出的状态，后缀为下载token
+	KeyExportLimitPF = "export:limit:" // 用户最近一次成功的导出，用于限制导出的频率
+	KeyExportRunPF   = "export:run:"   // 用户正在进行的导出，同一时间只能有一个
+
+	KeyMachineIDCounter = "snowflake:machine:counter" // 分配机器id时的起点，取模后使用
+	KeyMachineIDPF      = "snowflake:machine:"        // 已被租用的机器id，值为实例的标识
+
+	KeyEmailQueue     = "email:queue" // 待发送的邮件，元素为JSON，从右边取出
+	KeyEmailRetryZSet = "email:retry" // 等待重试的邮件，分数为下次发送的时间
+	KeyEmailDeadList  = "email:dead"  // 多次发送失败的邮件，只保留最近的一部分
+
+	KeyWebhookQueue     = "webhook:queue" // 待发送的webhook通知，元素为JSON，从右边取出
+	KeyWebhookRetryZSet = "webhook:retry" // 等待重试的通知，分数为下次发送的时间
+	KeyWebhookDeadList  = "webhook:dead"  // 不再重试的通知，只保留最近的一部分
+
+	KeyEmailDigestSentPF = "email:digest:sent:" // 当天已经发送了未读通知摘要的用户id，后缀为日期
+	KeyEmailDigestDonePF = "email:digest:done:" // 当天的摘要已经全部发送，后缀为日期
+
+	KeyNotifyUnreadPF = "notify:unread:"  // 用户的未读通知数
+	KeyNotifyPrefPF   = "notify:pref:"    // 用户通知设置的缓存，JSON
+	KeyAuthorPF       = "author:"         // 评论列表中作者信息的缓存，后缀为用户id，JSON
+	KeyNotifyChannel  = "notify:channel"  // 新通知的pub/sub频道
+	KeyNotifyDeferred = "notify:deferred" // 免打扰期间推迟推送的通知，分数为推送时间，member为通知的JSON
+
+	KeyPostCommentChannelPF = "post:comments:" // 帖子新评论的pub/sub频道，后缀为帖子id
+
+	KeyJobLockPF = "job:lock:" // 跨实例的互斥锁，用于后台任务和批量操作，值为持有者的token
+
+	KeyMaintenance = "maintenance" // 维护模式的状态，没有这个键表示没有开启
+
+	KeyFeatureFlagsHash = "feature:flags" // 功能开关，field: 开关的名字，值为JSON
 )
 
 func getRedisKey(key string) string {
@@ -0,0 +1,31 @@
+package redis
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"time"
+)
+
+func getLinkMetaKey(url string) string {
+	sum := sha1.Sum([]byte(url))
+	return getRedisKey(KeyLinkMetaPF + hex.EncodeToString(sum[:]))
+}
+
+// GetLinkMetaCache 读取链接的OpenGraph缓存，ok为false表示没有缓存
+// 抓取失败时缓存的是空的标题和图片
+func GetLinkMetaCache(url string) (title, image string, ok bool, err error) {
+	vals, err := client.HGetAll(getLinkMetaKey(url)).Result()
+	if err != nil || len(vals) == 0 {
+		return "", "", false, err
+	}
+	return vals["title"], vals["image"], true, nil
+}
+
+func SetLinkMetaCache(url, title, image string, expiration time.Duration) error {
+	key := getLinkMetaKey(url)
+	pipeline := client.TxPipeline()
+	pipeline.HMSet(key, map[string]interface{}{"title": title, "image": image})
+	pipeline.Expire(key, expiration)
+	_, err := pipeline.Exec()
+	return err
+}
@@ -0,0 +1,144 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+var ErrLockNotHeld = errors.New("Lock is not held. ")
+
+// 只有值仍是自己的token时才续期或释放，避免锁过期后被其他实例拿到时误操作
+var (
+	renewLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+	releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+)
+
+// Lock 跨实例的互斥锁，通过AcquireLock获得，用完后调用ReleaseLock
+type Lock struct {
+	key   string
+	token string
+	ttl   time.Duration
+
+	mu   sync.Mutex
+	stop chan struct{} // 关闭时停止自动续期
+	lost chan struct{} // 自动续期时发现锁丢失后关闭
+}
+
+func getLockKey(key string) string {
+	return getRedisKey(KeyJobLockPF + key)
+}
+
+// AcquireLock 尝试获得锁，已被其他实例持有时ok为false，调用方可以直接跳过这次的工作
+// 持有者崩溃时锁在ttl之后自动失效
+func AcquireLock(ctx context.Context, key string, ttl time.Duration) (lock *Lock, ok bool, err error) {
+	token, err := lockToken()
+	if err != nil {
+		return nil, false, err
+	}
+	ok, err = withContext(ctx).SetNX(getLockKey(key), token, ttl).Result()
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return &Lock{key: key, token: token, ttl: ttl}, true, nil
+}
+
+// ReleaseLock 释放锁并停止自动续期，锁已经过期或被其他实例持有时返回ErrLockNotHeld
+func ReleaseLock(ctx context.Context, lock *Lock) error {
+	lock.stopRenew()
+	n, err := releaseLockScript.Run(withContext(ctx), []string{getLockKey(lock.key)}, lock.token).Int()
+	if err != nil {
+		return err
+	}
+	if n != 1 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// Refresh 把锁的过期时间重置为ttl，锁已经不属于自己时返回ErrLockNotHeld
+func (l *Lock) Refresh(ctx context.Context) error {
+	n, err := renewLockScript.Run(withContext(ctx), []string{getLockKey(l.key)}, l.token, l.ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if n != 1 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// AutoRenew 在后台每隔ttl/3续期一次，直到ReleaseLock；用于执行时间可能超过ttl的任务
+// 锁丢失时返回的channel被关闭，调用方应当尽快停止工作；网络错误时在下一次重试
+func (l *Lock) AutoRenew() (lost <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stop != nil {
+		return l.lost
+	}
+	stop, ch := make(chan struct{}), make(chan struct{})
+	l.stop, l.lost = stop, ch
+	go func() {
+		ticker := time.NewTicker(l.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := l.Refresh(context.Background()); errors.Is(err, ErrLockNotHeld) {
+					close(ch)
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// AutoRenewContext 和AutoRenew一样在后台续期，返回的ctx在锁丢失时取消
+// 分批执行的任务在每一批之前检查ctx，锁丢失后不再继续，避免和拿到锁的实例同时执行
+func (l *Lock) AutoRenewContext(parent context.Context) (context.Context, context.CancelFunc) {
+	lost := l.AutoRenew()
+	ctx, cancel := context.WithCancel(parent)
+	go func() {
+		select {
+		case <-lost:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+func (l *Lock) stopRenew() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stop != nil {
+		close(l.stop)
+		l.stop = nil
+	}
+}
+
+func lockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
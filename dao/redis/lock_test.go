@@ -0,0 +1,118 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useMiniredis 测试期间把client换成内存中的redis
+func useMiniredis(t *testing.T) *miniredis.Miniredis {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	old := client
+	client = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+		client = old
+		mr.Close()
+	})
+	return mr
+}
+
+func TestLockContention(t *testing.T) {
+	mr := useMiniredis(t)
+	ctx := context.Background()
+
+	lock, ok, err := AcquireLock(ctx, "job", time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// 其他实例拿不到锁
+	_, ok, err = AcquireLock(ctx, "job", time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// 释放之后可以再次获得
+	assert.NoError(t, ReleaseLock(ctx, lock))
+	lock, ok, err = AcquireLock(ctx, "job", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// 持有者没有释放时，锁在ttl之后失效
+	mr.FastForward(time.Minute)
+	_, ok, err = AcquireLock(ctx, "job", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, ErrLockNotHeld, lock.Refresh(ctx))
+}
+
+func TestReleaseLockTokenMismatch(t *testing.T) {
+	mr := useMiniredis(t)
+	ctx := context.Background()
+
+	lock, ok, err := AcquireLock(ctx, "job", time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// 锁过期后被其他实例拿到，原来的持有者不能释放别人的锁
+	mr.FastForward(time.Minute)
+	other, ok, err := AcquireLock(ctx, "job", time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, ErrLockNotHeld, ReleaseLock(ctx, lock))
+	assert.True(t, mr.Exists(getLockKey("job")))
+
+	assert.NoError(t, ReleaseLock(ctx, other))
+	assert.False(t, mr.Exists(getLockKey("job")))
+}
+
+func TestLockAutoRenew(t *testing.T) {
+	mr := useMiniredis(t)
+	ctx := context.Background()
+	ttl := 30 * time.Millisecond
+
+	lock, ok, err := AcquireLock(ctx, "job", ttl)
+	require.NoError(t, err)
+	require.True(t, ok)
+	lost := lock.AutoRenew()
+
+	mr.FastForward(20 * time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return mr.TTL(getLockKey("job")) > 10*time.Millisecond
+	}, time.Second, 5*time.Millisecond)
+
+	// 锁被其他人拿走后停止续期并通知调用方
+	mr.Set(getLockKey("job"), "other")
+	select {
+	case <-lost:
+	case <-time.After(time.Second):
+		t.Fatal("lost channel was not closed")
+	}
+	assert.Equal(t, ErrLockNotHeld, ReleaseLock(ctx, lock))
+}
+
+func TestLockAutoRenewContext(t *testing.T) {
+	mr := useMiniredis(t)
+	lock, ok, err := AcquireLock(context.Background(), "job", 30*time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, ok)
+	ctx, cancel := lock.AutoRenewContext(context.Background())
+	defer cancel()
+	assert.Equal(t, getRedisKey("job:lock:job"), getLockKey("job"))
+
+	// 锁丢失后ctx被取消，任务在下一批之前停止
+	assert.NoError(t, ctx.Err())
+	mr.Set(getLockKey("job"), "other")
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context was not canceled")
+	}
+	assert.Equal(t, context.Canceled, ctx.Err())
+}
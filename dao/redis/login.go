@@ -0,0 +1,64 @@
+package redis
+
+import (
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// 登录失败计数，窗口内达到阈值时加锁并清空计数。返回1表示这次失败触发了锁定
+var loginFailureScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+if count >= tonumber(ARGV[2]) then
+	redis.call("SET", KEYS[2], 1, "PX", ARGV[3])
+	redis.call("DEL", KEYS[1])
+	return 1
+end
+return 0
+`)
+
+func getLoginFailKey(key string) string {
+	return getRedisKey(KeyLoginFailPF + key)
+}
+
+func getLoginLockKey(key string) string {
+	return getRedisKey(KeyLoginLockPF + key)
+}
+
+// GetLoginLockTTL 返回keys中剩余时间最长的锁，没有被锁定时返回0
+func GetLoginLockTTL(keys ...string) (time.Duration, error) {
+	pipeline := client.Pipeline()
+	cmds := make([]*redis.DurationCmd, 0, len(keys))
+	for _, key := range keys {
+		cmds = append(cmds, pipeline.PTTL(getLoginLockKey(key)))
+	}
+	if _, err := pipeline.Exec(); err != nil {
+		return 0, err
+	}
+	var ttl time.Duration
+	for _, cmd := range cmds {
+		if d := cmd.Val(); d > ttl {
+			ttl = d
+		}
+	}
+	return ttl, nil
+}
+
+// RecordLoginFailure 记录一次登录失败，window内失败threshold次后锁定cooldown
+func RecordLoginFailure(key string, window time.Duration, threshold int64, cooldown time.Duration) (locked bool, err error) {
+	ret, err := loginFailureScript.Run(client, []string{getLoginFailKey(key), getLoginLockKey(key)},
+		window.Milliseconds(), threshold, cooldown.Milliseconds()).Int()
+	return ret == 1, err
+}
+
+// ResetLoginFailures 登录成功后清空失败计数
+func ResetLoginFailures(keys ...string) error {
+	failKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		failKeys = append(failKeys, getLoginFailKey(key))
+	}
+	return client.Del(failKeys...).Err()
+}
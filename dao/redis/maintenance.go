@@ -0,0 +1,38 @@
+package redis
+
+import (
+	"encoding/json"
+	"go-web-app/models"
+
+	"github.com/go-redis/redis"
+)
+
+// GetMaintenance 没有开启维护模式时返回nil
+func GetMaintenance() (*models.Maintenance, error) {
+	b, err := client.Get(getRedisKey(KeyMaintenance)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := new(models.Maintenance)
+	if err = json.Unmarshal(b, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SetMaintenance 开启维护模式，不会自动过期，需要管理员手动关闭
+func SetMaintenance(m *models.Maintenance) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return client.Set(getRedisKey(KeyMaintenance), b, 0).Err()
+}
+
+// ClearMaintenance 关闭维护模式
+func ClearMaintenance() error {
+	return client.Del(getRedisKey(KeyMaintenance)).Err()
+}
@@ -0,0 +1,142 @@
+package redis
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+var ErrMergeTokenInvalid = errors.New("Merge token is invalid or expired. ")
+
+// 把ARGV[1]的投票转给ARGV[2]，两个用户都投过票时保留ARGV[2]的投票，撤销ARGV[1]那一票对分数和票数的影响
+// KEYS[2]为分数所在的key，KEYS[3]为赞成和反对票数
+// 返回0表示ARGV[1]没有投票，1表示转移了投票，2表示撤销了重复的投票
+var mergeVoteScript = redis.NewScript(`
+local ov = redis.call("ZSCORE", KEYS[1], ARGV[1])
+if not ov then
+	return 0
+end
+ov = tonumber(ov)
+redis.call("ZREM", KEYS[1], ARGV[1])
+if not redis.call("ZSCORE", KEYS[1], ARGV[2]) then
+	redis.call("ZADD", KEYS[1], ov, ARGV[2])
+	return 1
+end
+if ARGV[5] == "post" then
+	redis.call("ZINCRBY", KEYS[2], -ov * tonumber(ARGV[4]), ARGV[3])
+elseif redis.call("HEXISTS", KEYS[2], ARGV[3]) == 1 then
+	redis.call("HINCRBY", KEYS[2], ARGV[3], -ov)
+end
+if redis.call("EXISTS", KEYS[3]) == 1 then
+	redis.call("HINCRBY", KEYS[3], ov > 0 and "up" or "down", -1)
+end
+return 2
+`)
+
+// 把ARGV[1]的声望加到ARGV[2]上，ARGV[1]的声望清零，两人都标记为待写回
+// 不在zset中的用户先使用ARGV[3]、ARGV[4]中从mysql读出的声望
+var mergeKarmaScript = redis.NewScript(`
+local karma = tonumber(redis.call("ZSCORE", KEYS[1], ARGV[1]) or ARGV[3])
+if karma == 0 then
+	return 0
+end
+redis.call("ZADD", KEYS[1], "NX", ARGV[4], ARGV[2])
+redis.call("ZINCRBY", KEYS[1], karma, ARGV[2])
+redis.call("ZADD", KEYS[1], 0, ARGV[1])
+redis.call("SADD", KEYS[2], ARGV[1], ARGV[2])
+return karma
+`)
+
+// SetMergeToken 保存来源账号生成的合并确认token
+func SetMergeToken(token string, userID int64, expiration time.Duration) error {
+	return client.Set(getRedisKey(KeyMergeTokenPF+token), userID, expiration).Err()
+}
+
+// GetMergeToken token对应的来源账号，合并完成之前不删除token，失败后可以用同一个token重试
+func GetMergeToken(token string) (int64, error) {
+	userID, err := client.Get(getRedisKey(KeyMergeTokenPF + token)).Int64()
+	if err == redis.Nil {
+		err = ErrMergeTokenInvalid
+	}
+	return userID, err
+}
+
+func DeleteMergeToken(token string) error {
+	return client.Del(getRedisKey(KeyMergeTokenPF + token)).Err()
+}
+
+// MergeUserVotes 把fromUID在所有帖子和评论上的投票转给toUID，两个账号投过同一个帖子或评论时只保留toUID的投票。
+// 返回撤销了重复投票的帖子id，调用方需要重新计算它们的热度和争议度
+func MergeUserVotes(fromUID, toUID int64) (changedPosts []string, err error) {
+	from, to := strconv.FormatInt(fromUID, 10), strconv.FormatInt(toUID, 10)
+	err = scanKeys(KeyPostVotedZSetPF, func(pid string) error {
+		keys := []string{getRedisKey(KeyPostVotedZSetPF + pid), getRedisKey(KeyPostScoreZSet), getRedisKey(KeyPostVoteCountPF + pid)}
+		ret, err := mergeVoteScript.Run(client, keys, from, to, pid, scorePerVote, "post").Int()
+		if ret == 2 {
+			changedPosts = append(changedPosts, pid)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = MarkPostScoresDirty(changedPosts...); err != nil {
+		return nil, err
+	}
+	// 用户自己的投票记录，重复投票时toUID的记录可能同时出现在up和down中，读取时按帖子的投票记录过滤
+	pipeline := client.TxPipeline()
+	for _, dir := range []string{"up", "down"} {
+		toKey := getUserVotedKey(to, dir)
+		pipeline.ZUnionStore(toKey, redis.ZStore{Aggregate: "MAX"}, toKey, getUserVotedKey(from, dir))
+		pipeline.Del(getUserVotedKey(from, dir))
+	}
+	if _, err = pipeline.Exec(); err != nil {
+		return nil, err
+	}
+	err = scanKeys(KeyCommentVotedPF, func(cid string) error {
+		keys := []string{getRedisKey(KeyCommentVotedPF + cid), getRedisKey(KeyCommentScoreHash), getRedisKey(KeyCommentUpDownPF + cid)}
+		return mergeVoteScript.Run(client, keys, from, to, cid, 0, "comment").Err()
+	})
+	return changedPosts, err
+}
+
+// scanKeys 用SCAN遍历前缀为prefix的key，fn的参数为去掉前缀后的部分
+func scanKeys(prefix string, fn func(suffix string) error) error {
+	full := getRedisKey(prefix)
+	var cursor uint64
+	for {
+		keys, next, err := client.Scan(cursor, full+"*", 1000).Result()
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := fn(strings.TrimPrefix(key, full)); err != nil {
+				return err
+			}
+		}
+		if cursor = next; cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// MergeUserPosts 把fromUID发布的帖子并入toUID的帖子列表
+func MergeUserPosts(fromUID, toUID int64) error {
+	fromKey := getRedisKey(KeyUserPostZSetPF + strconv.FormatInt(fromUID, 10))
+	toKey := getRedisKey(KeyUserPostZSetPF + strconv.FormatInt(toUID, 10))
+	pipeline := client.TxPipeline()
+	pipeline.ZUnionStore(toKey, redis.ZStore{Aggregate: "MAX"}, toKey, fromKey)
+	pipeline.Del(fromKey)
+	_, err := pipeline.Exec()
+	return err
+}
+
+// MergeKarma 把fromUID的声望加到toUID上并清零fromUID的声望，重复执行时fromUID的声望已经为0，不会重复累加。
+// fromBase和toBase是mysql中的声望，用户不在redis中时使用
+func MergeKarma(fromUID, toUID, fromBase, toBase int64) (moved int64, err error) {
+	keys := []string{getRedisKey(KeyUserKarmaZSet), getRedisKey(KeyUserKarmaDirtySet)}
+	return mergeKarmaScript.Run(client, keys, fromUID, toUID, fromBase, toBase).Int64()
+}
@@ -0,0 +1,158 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeUserVotes(t *testing.T) {
+	useMiniredis(t)
+	now := float64(time.Now().Unix())
+	for _, pid := range []string{"1", "2", "3"} {
+		require.NoError(t, client.ZAdd(getRedisKey(KeyPostTimeZSet), redis.Z{Score: now, Member: pid}).Err())
+	}
+	vote := func(userID, postID string, value float64) {
+		_, _, err := VoteForPost(userID, postID, value, 1, time.Hour)
+		require.NoError(t, err)
+	}
+	// 帖子1只有来源账号投票，帖子2两个账号都投了票，帖子3只有目标账号投票
+	vote("10", "1", -1)
+	vote("10", "2", 1)
+	vote("20", "2", -1)
+	vote("30", "2", 1)
+	vote("20", "3", 1)
+	_, err := VoteForComment("10", "100", 1)
+	require.NoError(t, err)
+	_, err = VoteForComment("20", "100", 1)
+	require.NoError(t, err)
+	_, err = VoteForComment("10", "101", -1)
+	require.NoError(t, err)
+	score2 := client.ZScore(getRedisKey(KeyPostScoreZSet), "2").Val()
+
+	changed, err := MergeUserVotes(10, 20)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2"}, changed)
+
+	// 转移的投票不改变分数和票数
+	assert.Equal(t, float64(-1), client.ZScore(getRedisKey(KeyPostVotedZSetPF+"1"), "20").Val())
+	up, down, err := GetPostVoteCount("1")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{0, 1}, []int64{up, down})
+
+	// 重复的投票保留目标账号的，来源账号那一票从分数和票数中撤销
+	assert.Equal(t, float64(-1), client.ZScore(getRedisKey(KeyPostVotedZSetPF+"2"), "20").Val())
+	assert.Equal(t, score2-scorePerVote, client.ZScore(getRedisKey(KeyPostScoreZSet), "2").Val())
+	up, down, err = GetPostVoteCount("2")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 1}, []int64{up, down})
+	assert.Equal(t, float64(1), client.ZScore(getRedisKey(KeyPostVotedZSetPF+"3"), "20").Val())
+
+	scores, err := GetCommentScores([]string{"100", "101"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, -1}, scores)
+	assert.Equal(t, float64(-1), client.ZScore(getRedisKey(KeyCommentVotedPF+"101"), "20").Val())
+	ups, downs, err := GetCommentVoteCounts([]string{"100", "101"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 0}, ups)
+	assert.Equal(t, []int64{0, 1}, downs)
+
+	// 来源账号不再有任何投票
+	for _, key := range []string{"post:voted:1", "post:voted:2", "comment:voted:100", "comment:voted:101"} {
+		assert.Equal(t, redis.Nil, client.ZScore(getRedisKey(key), "10").Err(), key)
+	}
+
+	// 重试时没有变化
+	changed, err = MergeUserVotes(10, 20)
+	require.NoError(t, err)
+	assert.Empty(t, changed)
+	assert.Equal(t, score2-scorePerVote, client.ZScore(getRedisKey(KeyPostScoreZSet), "2").Val())
+	scores, err = GetCommentScores([]string{"100"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, scores)
+}
+
+func TestMergeUserVotesUninitializedCounts(t *testing.T) {
+	useMiniredis(t)
+	// 分开计数和评论得分还没有初始化时不创建它们，之后按投票记录计算
+	require.NoError(t, client.ZAdd(getRedisKey(KeyPostVotedZSetPF+"1"), redis.Z{Score: 1, Member: "10"}, redis.Z{Score: 1, Member: "20"}).Err())
+	require.NoError(t, client.ZAdd(getRedisKey(KeyCommentVotedPF+"100"), redis.Z{Score: 1, Member: "10"}, redis.Z{Score: -1, Member: "20"}).Err())
+	_, err := MergeUserVotes(10, 20)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), client.Exists(getRedisKey(KeyPostVoteCountPF+"1")).Val())
+	assert.False(t, client.HExists(getRedisKey(KeyCommentScoreHash), "100").Val())
+	assert.Equal(t, int64(1), client.ZCard(getRedisKey(KeyPostVotedZSetPF+"1")).Val())
+	assert.Equal(t, float64(-1), client.ZScore(getRedisKey(KeyCommentVotedPF+"100"), "20").Val())
+}
+
+func TestMergeUserPosts(t *testing.T) {
+	useMiniredis(t)
+	require.NoError(t, client.ZAdd(getRedisKey(KeyUserPostZSetPF+"10"), redis.Z{Score: 100, Member: "1"}, redis.Z{Score: 300, Member: "3"}).Err())
+	require.NoError(t, client.ZAdd(getRedisKey(KeyUserPostZSetPF+"20"), redis.Z{Score: 200, Member: "2"}).Err())
+
+	require.NoError(t, MergeUserPosts(10, 20))
+	ids, err := GetUserPostIDs(20)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2", "3"}, ids)
+	ids, err = GetUserPostIDs(10)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+
+	require.NoError(t, MergeUserPosts(10, 20))
+	ids, err = GetUserPostIDs(20)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2", "3"}, ids)
+}
+
+func TestMergeKarma(t *testing.T) {
+	useMiniredis(t)
+	require.NoError(t, client.ZAdd(getRedisKey(KeyUserKarmaZSet), redis.Z{Score: 7, Member: "10"}).Err())
+
+	// 目标账号不在redis中时以mysql中的声望为基础
+	moved, err := MergeKarma(10, 20, 0, 5)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), moved)
+	karma, err := GetKarma(20)
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), karma)
+	karma, err = GetKarma(10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), karma)
+	assert.ElementsMatch(t, []string{"10", "20"}, client.SMembers(getRedisKey(KeyUserKarmaDirtySet)).Val())
+
+	// 重试时来源账号的声望已经清零，不会重复累加
+	moved, err = MergeKarma(10, 20, 7, 5)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), moved)
+	karma, err = GetKarma(20)
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), karma)
+
+	// 来源账号不在redis中时使用mysql中的声望
+	moved, err = MergeKarma(30, 20, 3, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), moved)
+	karma, err = GetKarma(20)
+	require.NoError(t, err)
+	assert.Equal(t, int64(15), karma)
+}
+
+func TestMergeToken(t *testing.T) {
+	useMiniredis(t)
+	_, err := GetMergeToken("missing")
+	assert.Equal(t, ErrMergeTokenInvalid, err)
+
+	require.NoError(t, SetMergeToken("abc", 10, time.Minute))
+	// 读取不会删除token，合并失败后可以重试
+	for i := 0; i < 2; i++ {
+		uid, err := GetMergeToken("abc")
+		require.NoError(t, err)
+		assert.Equal(t, int64(10), uid)
+	}
+	require.NoError(t, DeleteMergeToken("abc"))
+	_, err = GetMergeToken("abc")
+	assert.Equal(t, ErrMergeTokenInvalid, err)
+}
@@ -0,0 +1,62 @@
+package redis
+
+import (
+	"go-web-app/models"
+	"strconv"
+
+	"github.com/go-redis/redis"
+)
+
+// RemovePostsFromFeeds 从全局的排序列表和作者的列表中移除帖子，投票记录保留，恢复时用于重新计算分数
+func RemovePostsFromFeeds(posts []*models.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	pipeline := client.TxPipeline()
+	for _, post := range posts {
+		pid := strconv.FormatInt(post.PostID, 10)
+		for _, key := range []string{KeyPostTimeZSet, KeyPostScoreZSet, KeyPostHotZSet, KeyPostControZSet} {
+			pipeline.ZRem(getRedisKey(key), pid)
+		}
+		pipeline.ZRem(getRedisKey(KeyUserPostZSetPF+strconv.FormatInt(post.AuthorId, 10)), pid)
+	}
+	_, err := pipeline.Exec()
+	return err
+}
+
+// RestorePostToFeeds 把帖子按原发布时间和投票重新加入全局的排序列表和作者的列表，已经在列表中的不修改
+// 热度和争议度需要之后用UpdatePostHotScore和UpdatePostControversyScore重新计算
+func RestorePostToFeeds(post *models.Post) error {
+	pid := strconv.FormatInt(post.PostID, 10)
+	pipeline := client.Pipeline()
+	votes := pipeline.ZRangeWithScores(getRedisKey(KeyPostVotedZSetPF+pid), 0, -1)
+	weights := pipeline.HGetAll(getRedisKey(KeyPostVoteWeightPF + pid))
+	if _, err := pipeline.Exec(); err != nil {
+		return err
+	}
+	// 和投票时一样按每一票的权重计算，没有记录权重的按1计算
+	var net float64
+	for _, z := range votes.Val() {
+		w, err := strconv.ParseFloat(weights.Val()[z.Member.(string)], 64)
+		if err != nil {
+			w = 1
+		}
+		net += z.Score * w
+	}
+	createTime := float64(post.CreateTime.Unix())
+	pipeline = client.TxPipeline()
+	pipeline.ZAddNX(getRedisKey(KeyPostTimeZSet), redis.Z{Score: createTime, Member: pid})
+	pipeline.ZAddNX(getRedisKey(KeyPostScoreZSet), redis.Z{
+		Score:  createTime + net*scorePerVote,
+		Member: pid,
+	})
+	pipeline.SAdd(getRedisKey(KeyPostScoreDirtySet), pid)
+	if !post.Anonymous {
+		pipeline.ZAddNX(getRedisKey(KeyUserPostZSetPF+strconv.FormatInt(post.AuthorId, 10)), redis.Z{
+			Score:  feedScore(post.PostID),
+			Member: pid,
+		})
+	}
+	_, err := pipeline.Exec()
+	return err
+}
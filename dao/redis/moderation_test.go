@@ -0,0 +1,40 @@
+package redis
+
+import (
+	"go-web-app/models"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoveAndRestorePostFeeds(t *testing.T) {
+	useMiniredis(t)
+	created := time.Now().Add(-time.Hour).Truncate(time.Second)
+	post := &models.Post{PostID: 1, AuthorId: 7, CreateTime: created}
+	require.NoError(t, CreatePost(1, []int64{3}, 7, nil))
+	require.NoError(t, CreatePost(2, []int64{3}, 7, nil))
+	require.NoError(t, client.ZAdd(getRedisKey(KeyPostVotedZSetPF+"1"),
+		redis.Z{Score: 1, Member: "8"}, redis.Z{Score: 1, Member: "9"}, redis.Z{Score: -1, Member: "10"}).Err())
+
+	require.NoError(t, RemovePostsFromFeeds([]*models.Post{post}))
+	for _, key := range []string{KeyPostTimeZSet, KeyPostScoreZSet, KeyPostHotZSet, KeyUserPostZSetPF + "7"} {
+		members := client.ZRange(getRedisKey(key), 0, -1).Val()
+		assert.Equal(t, []string{"2"}, members, key)
+	}
+	// 投票记录和社区的列表不受影响
+	assert.Equal(t, int64(3), client.ZCard(getRedisKey(KeyPostVotedZSetPF+"1")).Val())
+	assert.True(t, client.SIsMember(getCommunitySetKey(3), "1").Val())
+
+	require.NoError(t, RestorePostToFeeds(post))
+	assert.Equal(t, float64(created.Unix()), client.ZScore(getRedisKey(KeyPostTimeZSet), "1").Val())
+	assert.Equal(t, float64(created.Unix())+scorePerVote, client.ZScore(getRedisKey(KeyPostScoreZSet), "1").Val())
+	assert.Equal(t, feedScore(1), client.ZScore(getRedisKey(KeyUserPostZSetPF+"7"), "1").Val())
+
+	// 还在列表中的帖子保持原来的分数
+	before := client.ZScore(getRedisKey(KeyPostTimeZSet), "2").Val()
+	require.NoError(t, RestorePostToFeeds(&models.Post{PostID: 2, AuthorId: 7, CreateTime: created}))
+	assert.Equal(t, before, client.ZScore(getRedisKey(KeyPostTimeZSet), "2").Val())
+}
@@ -0,0 +1,33 @@
+package redis
+
+import "strconv"
+
+func getMutedKey(uid int64) string {
+	return getRedisKey(KeyUserMutedSetPF + strconv.FormatInt(uid, 10))
+}
+
+// MuteCommunity 重复隐藏不会报错
+func MuteCommunity(uid, communityID int64) error {
+	return client.SAdd(getMutedKey(uid), communityID).Err()
+}
+
+func UnmuteCommunity(uid, communityID int64) error {
+	return client.SRem(getMutedKey(uid), communityID).Err()
+}
+
+// GetMutedCommunityIDs 返回用户隐藏的社区，没有时返回空列表
+func GetMutedCommunityIDs(uid int64) ([]int64, error) {
+	members, err := client.SMembers(getMutedKey(uid)).Result()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(members))
+	for _, m := range members {
+		id, err := strconv.ParseInt(m, 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,23 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMuteCommunity(t *testing.T) {
+	useMiniredis(t)
+	ids, err := GetMutedCommunityIDs(1)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+
+	require.NoError(t, MuteCommunity(1, 3))
+	require.NoError(t, MuteCommunity(1, 3))
+	require.NoError(t, MuteCommunity(1, 5))
+	require.NoError(t, UnmuteCommunity(1, 5))
+	ids, err = GetMutedCommunityIDs(1)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{3}, ids)
+}
@@ -0,0 +1,100 @@
+package redis
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// 只更新已经存在的计数，不存在的计数在读取时从mongodb重建
+const incrStringIfExistsScript = `if redis.call("EXISTS", KEYS[1]) == 1 then return redis.call("INCR", KEYS[1]) end return 0`
+
+func getUnreadKey(uid int64) string {
+	return getRedisKey(KeyNotifyUnreadPF + strconv.FormatInt(uid, 10))
+}
+
+func IncrUnreadCount(uid int64) error {
+	return client.Eval(incrStringIfExistsScript, []string{getUnreadKey(uid)}).Err()
+}
+
+// GetUnreadCount 读取未读数，计数不存在时返回redis.Nil
+func GetUnreadCount(uid int64) (int64, error) {
+	return client.Get(getUnreadKey(uid)).Int64()
+}
+
+func SetUnreadCount(uid, count int64) error {
+	return client.Set(getUnreadKey(uid), count, 0).Err()
+}
+
+// DeleteUnreadCount 删除计数，下次读取时从mongodb重新统计
+// 新通知只在计数存在时加一，删除之后不会丢失计数
+func DeleteUnreadCount(uid int64) error {
+	return client.Del(getUnreadKey(uid)).Err()
+}
+
+// PublishNotification 把新通知广播给所有实例
+func PublishNotification(payload []byte) error {
+	return client.Publish(getRedisKey(KeyNotifyChannel), payload).Err()
+}
+
+// DeferNotification 免打扰期间的通知在at之后再推送
+func DeferNotification(payload []byte, at time.Time) error {
+	return client.ZAdd(getRedisKey(KeyNotifyDeferred), redis.Z{Score: float64(at.Unix()), Member: payload}).Err()
+}
+
+// popDueScript 取出并删除到时间的member，多个实例同时执行时每个member只会被取出一次
+var popDueScript = redis.NewScript(`
+local members = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, m in ipairs(members) do
+	redis.call('ZREM', KEYS[1], m)
+end
+return members
+`)
+
+// PopDueNotifications 取出最多count个到了推送时间的通知
+func PopDueNotifications(now time.Time, count int) ([]string, error) {
+	ret, err := popDueScript.Run(client, []string{getRedisKey(KeyNotifyDeferred)}, now.Unix(), count).Result()
+	if err != nil {
+		return nil, err
+	}
+	vals, _ := ret.([]interface{})
+	payloads := make([]string, 0, len(vals))
+	for _, v := range vals {
+		if s, ok := v.(string); ok {
+			payloads = append(payloads, s)
+		}
+	}
+	return payloads, nil
+}
+
+// SubscribeNotifications 订阅新通知，调用方负责Close
+func SubscribeNotifications() *redis.PubSub {
+	return client.Subscribe(getRedisKey(KeyNotifyChannel))
+}
+
+// PostCommentChannel 帖子新评论的频道
+func PostCommentChannel(pid int64) string {
+	return getRedisKey(KeyPostCommentChannelPF + strconv.FormatInt(pid, 10))
+}
+
+// ParsePostCommentChannel 从频道名中取出帖子id
+func ParsePostCommentChannel(channel string) (int64, bool) {
+	prefix := getRedisKey(KeyPostCommentChannelPF)
+	if !strings.HasPrefix(channel, prefix) {
+		return 0, false
+	}
+	pid, err := strconv.ParseInt(channel[len(prefix):], 10, 64)
+	return pid, err == nil
+}
+
+// PublishPostComment 把新评论广播给所有实例上正在查看帖子的连接
+func PublishPostComment(pid int64, payload []byte) error {
+	return client.Publish(PostCommentChannel(pid), payload).Err()
+}
+
+// NewPostCommentSubscriber 创建一个还没有订阅任何频道的PubSub，之后按需Subscribe和Unsubscribe
+func NewPostCommentSubscriber() *redis.PubSub {
+	return client.Subscribe()
+}
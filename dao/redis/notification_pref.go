@@ -0,0 +1,47 @@
+package redis
+
+import (
+	"encoding/json"
+	"go-web-app/models"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// notificationPrefTTL 通知设置只在修改时失效，过期时间只用于清理不活跃的用户
+const notificationPrefTTL = 24 * time.Hour
+
+func getNotificationPrefKey(uid int64) string {
+	return getRedisKey(KeyNotifyPrefPF + strconv.FormatInt(uid, 10))
+}
+
+// GetNotificationPrefCache 读取缓存的通知设置，没有缓存时返回nil
+func GetNotificationPrefCache(uid int64) (*models.NotificationPref, error) {
+	b, err := client.Get(getNotificationPrefKey(uid)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 新增类型之前缓存的设置中没有该字段，按默认设置接收
+	pref := models.DefaultNotificationPref()
+	// 无法解析的缓存当作未命中
+	if json.Unmarshal(b, pref) != nil {
+		return nil, nil
+	}
+	return pref, nil
+}
+
+func SetNotificationPrefCache(uid int64, pref *models.NotificationPref) error {
+	b, err := json.Marshal(pref)
+	if err != nil {
+		return err
+	}
+	return client.Set(getNotificationPrefKey(uid), b, notificationPrefTTL).Err()
+}
+
+func DeleteNotificationPrefCache(uid int64) error {
+	return client.Del(getNotificationPrefKey(uid)).Err()
+}
@@ -0,0 +1,34 @@
+package redis
+
+import (
+	"go-web-app/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotificationPrefCache(t *testing.T) {
+	mr := useMiniredis(t)
+	pref, err := GetNotificationPrefCache(1)
+	require.NoError(t, err)
+	assert.Nil(t, pref)
+
+	want := models.DefaultNotificationPref()
+	want.Vote = false
+	require.NoError(t, SetNotificationPrefCache(1, want))
+	pref, err = GetNotificationPrefCache(1)
+	require.NoError(t, err)
+	assert.Equal(t, want, pref)
+
+	// 无法解析的缓存当作未命中
+	require.NoError(t, mr.Set(getNotificationPrefKey(2), "{"))
+	pref, err = GetNotificationPrefCache(2)
+	require.NoError(t, err)
+	assert.Nil(t, pref)
+
+	require.NoError(t, DeleteNotificationPrefCache(1))
+	pref, err = GetNotificationPrefCache(1)
+	require.NoError(t, err)
+	assert.Nil(t, pref)
+}
@@ -0,0 +1,50 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeferredNotifications(t *testing.T) {
+	useMiniredis(t)
+	now := time.Now()
+	require.NoError(t, DeferNotification([]byte(`{"notification_id":1}`), now.Add(-time.Minute)))
+	require.NoError(t, DeferNotification([]byte(`{"notification_id":2}`), now))
+	require.NoError(t, DeferNotification([]byte(`{"notification_id":3}`), now.Add(time.Hour)))
+
+	due, err := PopDueNotifications(now, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"notification_id":1}`}, due)
+	due, err = PopDueNotifications(now, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"notification_id":2}`}, due)
+	// 已经取出的不会再次返回，没到时间的保留
+	due, err = PopDueNotifications(now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, due)
+	due, err = PopDueNotifications(now.Add(time.Hour), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"notification_id":3}`}, due)
+}
+
+func TestUnreadCount(t *testing.T) {
+	mr := useMiniredis(t)
+	require.NoError(t, SetUnreadCount(1, 2))
+	require.NoError(t, IncrUnreadCount(1))
+	count, err := GetUnreadCount(1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	// 删除之后新通知不会增加计数，下次读取时重新统计
+	require.NoError(t, DeleteUnreadCount(1))
+	require.NoError(t, IncrUnreadCount(1))
+	_, err = GetUnreadCount(1)
+	assert.Equal(t, redis.Nil, err)
+
+	mr.Close()
+	assert.Error(t, DeleteUnreadCount(1))
+}
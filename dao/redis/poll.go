@@ -0,0 +1,62 @@
+package redis
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/go-redis/redis"
+)
+
+var ErrPollVoted = errors.New("Already voted in this poll. ")
+
+// votePollScript 每个用户只能投一次，已经投过时返回0
+const votePollScript = `
+if redis.call("HSETNX", KEYS[2], ARGV[1], ARGV[2]) == 0 then return 0 end
+redis.call("HINCRBY", KEYS[1], ARGV[2], 1)
+return 1`
+
+func getPollKeys(pid int64) (tally, voter string) {
+	id := strconv.FormatInt(pid, 10)
+	return getRedisKey(KeyPollTallyPF + id), getRedisKey(KeyPollVoterPF + id)
+}
+
+// VotePoll 记录用户的选择，已经投过票时返回ErrPollVoted
+func VotePoll(pid, userID int64, option int) error {
+	tally, voter := getPollKeys(pid)
+	n, err := client.Eval(votePollScript, []string{tally, voter}, userID, option).Int64()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrPollVoted
+	}
+	return nil
+}
+
+// GetPollTally 返回每个选项的票数，以及用户的选择，userID为0或用户没有投票时choice为nil
+func GetPollTally(pid, userID int64, options int) (votes []int64, choice *int, err error) {
+	tally, voter := getPollKeys(pid)
+	pipeline := client.Pipeline()
+	tallyCmd := pipeline.HGetAll(tally)
+	var choiceCmd *redis.StringCmd
+	if userID != 0 {
+		choiceCmd = pipeline.HGet(voter, strconv.FormatInt(userID, 10))
+	}
+	if _, err = pipeline.Exec(); err != nil && err != redis.Nil {
+		return nil, nil, err
+	}
+	votes = make([]int64, options)
+	for field, v := range tallyCmd.Val() {
+		i, err := strconv.Atoi(field)
+		if err != nil || i < 0 || i >= options {
+			continue
+		}
+		votes[i], _ = strconv.ParseInt(v, 10, 64)
+	}
+	if choiceCmd != nil {
+		if i, err := strconv.Atoi(choiceCmd.Val()); err == nil {
+			choice = &i
+		}
+	}
+	return votes, choice, nil
+}
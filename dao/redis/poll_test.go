@@ -0,0 +1,30 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVotePoll(t *testing.T) {
+	useMiniredis(t)
+	require.NoError(t, VotePoll(1, 10, 0))
+	require.NoError(t, VotePoll(1, 11, 2))
+	require.NoError(t, VotePoll(1, 12, 2))
+	// 重复投票不会改变选择
+	assert.ErrorIs(t, VotePoll(1, 10, 1), ErrPollVoted)
+
+	votes, choice, err := GetPollTally(1, 10, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 0, 2}, votes)
+	require.NotNil(t, choice)
+	assert.Equal(t, 0, *choice)
+
+	_, choice, err = GetPollTally(1, 13, 3)
+	require.NoError(t, err)
+	assert.Nil(t, choice)
+	_, choice, err = GetPollTally(1, 0, 3)
+	require.NoError(t, err)
+	assert.Nil(t, choice)
+}
@@ -2,61 +2,322 @@ package redis
 
 import (
 	"go-web-app/models"
+	"sort"
 	"strconv"
 	"time"
 
 	"github.com/go-redis/redis"
 )
 
-func getIDsFromKey(key string, page, size int64) ([]string, error) {
+// getIDsFromKey 按页取出有序集合中的id，多取一个用来判断是否还有下一页
+func getIDsFromKey(key string, page, size int64) (ids []string, hasMore bool, err error) {
+	if page < 1 || size < 1 {
+		return nil, false, nil
+	}
+	start := (page - 1) * size
+	end := start + size
+	ids, err = client.ZRevRange(key, start, end).Result()
+	if err == nil {
+		hasMore = int64(len(ids)) > size
+		if hasMore {
+			ids = ids[:size]
+		}
+	}
+	// redis不可用时返回之前读取的结果
+	v, err := cached(pageCacheKey(key, page, size), &idsPage{ids, hasMore}, err)
+	if err != nil {
+		return nil, false, err
+	}
+	p := v.(*idsPage)
+	// 缓存中的切片不返回给调用方，避免被修改
+	return append([]string(nil), p.ids...), p.hasMore, nil
+}
+
+// getIDsFromKeyExcluding 同getIDsFromKey，先去掉exclude中的成员再分页
+func getIDsFromKeyExcluding(key string, page, size int64, exclude []string) (ids []string, hasMore bool, err error) {
+	if page < 1 || size < 1 {
+		return nil, false, nil
+	}
+	ids, hasMore, err = excludedPage(key, page, size, exclude)
+	v, err := cached(pageCacheKey(key+":excluding", page, size), &idsPage{ids, hasMore}, err)
+	if err != nil {
+		return nil, false, err
+	}
+	p := v.(*idsPage)
+	return append([]string(nil), p.ids...), p.hasMore, nil
+}
+
+// excludedPage 排在本页起点之前的exclude成员使起点后移，再多取len(exclude)个弥补本页中被去掉的成员
+func excludedPage(key string, page, size int64, exclude []string) ([]string, bool, error) {
+	pipeline := client.Pipeline()
+	cmds := make([]*redis.IntCmd, 0, len(exclude))
+	for _, m := range exclude {
+		cmds = append(cmds, pipeline.ZRevRank(key, m))
+	}
+	// 不在集合中的成员返回redis.Nil
+	if _, err := pipeline.Exec(); err != nil && err != redis.Nil {
+		return nil, false, err
+	}
+	ranks := make([]int64, 0, len(cmds))
+	for _, cmd := range cmds {
+		if r, err := cmd.Result(); err == nil {
+			ranks = append(ranks, r)
+		}
+	}
+	sort.Slice(ranks, func(i, j int) bool { return ranks[i] < ranks[j] })
 	start := (page - 1) * size
-	end := start + size - 1
-	return client.ZRevRange(key, start, end).Result()
+	for _, r := range ranks {
+		if r <= start {
+			start++
+		}
+	}
+	all, err := client.ZRevRange(key, start, start+size+int64(len(ranks))).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	skip := make(map[string]struct{}, len(exclude))
+	for _, m := range exclude {
+		skip[m] = struct{}{}
+	}
+	ids := make([]string, 0, size+1)
+	for _, m := range all {
+		if _, ok := skip[m]; !ok {
+			ids = append(ids, m)
+		}
+	}
+	if int64(len(ids)) > size {
+		return ids[:size], true, nil
+	}
+	return ids, false, nil
 }
 
-func GetPostIDsInOrder(p *models.ParamPostList) ([]string, error) {
-	key := getRedisKey(KeyPostTimeZSet)
-	if p.Order == models.OrderScore {
-		key = getRedisKey(KeyPostScoreZSet)
+// getOrderKey 根据排序方式返回对应的有序集合
+func getOrderKey(order string) string {
+	switch order {
+	case models.OrderScore:
+		return getRedisKey(KeyPostScoreZSet)
+	case models.OrderHot:
+		return getRedisKey(KeyPostHotZSet)
+	case models.OrderControversial:
+		return getRedisKey(KeyPostControZSet)
+	default:
+		return getRedisKey(KeyPostTimeZSet)
 	}
-	return getIDsFromKey(key, p.Page, p.Size)
+}
+
+func GetPostIDsInOrder(p *models.ParamPostList) ([]string, bool, error) {
+	return getIDsFromKey(getOrderKey(p.Order), p.Page, p.Size)
 }
 
 func GetPostVoteData(ids []string) (data []int64, err error) {
+	return getUpVoteCounts(KeyPostVotedZSetPF, ids)
+}
+
+// GetCommentVoteData 每个评论的赞成票数
+func GetCommentVoteData(ids []string) (data []int64, err error) {
+	return getUpVoteCounts(KeyCommentVotedPF, ids)
+}
+
+// GetCommentScores 每个评论的得分，即赞成票数减反对票数
+// 得分是在投票时累加的，之前投过票但还没有得分的评论按投票记录计算
+func GetCommentScores(ids []string) (scores []int64, err error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
 	pipeline := client.Pipeline()
+	hmget := pipeline.HMGet(getRedisKey(KeyCommentScoreHash), ids...)
+	ups := make([]*redis.IntCmd, 0, len(ids))
+	downs := make([]*redis.IntCmd, 0, len(ids))
 	for _, id := range ids {
-		key := getRedisKey(KeyPostVotedZSetPF + id)
+		key := getRedisKey(KeyCommentVotedPF + id)
+		ups = append(ups, pipeline.ZCount(key, "1", "1"))
+		downs = append(downs, pipeline.ZCount(key, "-1", "-1"))
+	}
+	if _, err = pipeline.Exec(); err != nil {
+		return nil, err
+	}
+	scores = make([]int64, len(ids))
+	for i, v := range hmget.Val() {
+		if s, ok := v.(string); ok {
+			if scores[i], err = strconv.ParseInt(s, 10, 64); err == nil {
+				continue
+			}
+		}
+		scores[i] = ups[i].Val() - downs[i].Val()
+	}
+	return scores, nil
+}
+
+// GetCommentVoteCounts 每个评论的赞成和反对票数，顺序与ids一致
+// 还没有分开计数的评论按投票记录计算
+func GetCommentVoteCounts(ids []string) (up, down []int64, err error) {
+	if len(ids) == 0 {
+		return nil, nil, nil
+	}
+	pipeline := client.Pipeline()
+	counts := make([]*redis.SliceCmd, 0, len(ids))
+	ups := make([]*redis.IntCmd, 0, len(ids))
+	downs := make([]*redis.IntCmd, 0, len(ids))
+	for _, id := range ids {
+		counts = append(counts, pipeline.HMGet(getRedisKey(KeyCommentUpDownPF+id), "up", "down"))
+		key := getRedisKey(KeyCommentVotedPF + id)
+		ups = append(ups, pipeline.ZCount(key, "1", "1"))
+		downs = append(downs, pipeline.ZCount(key, "-1", "-1"))
+	}
+	if _, err = pipeline.Exec(); err != nil {
+		return nil, nil, err
+	}
+	up, down = make([]int64, len(ids)), make([]int64, len(ids))
+	for i, cmd := range counts {
+		vals := cmd.Val()
+		u, uok := vals[0].(string)
+		d, dok := vals[1].(string)
+		if uok && dok {
+			var e1, e2 error
+			up[i], e1 = strconv.ParseInt(u, 10, 64)
+			down[i], e2 = strconv.ParseInt(d, 10, 64)
+			if e1 == nil && e2 == nil {
+				continue
+			}
+		}
+		up[i], down[i] = ups[i].Val(), downs[i].Val()
+	}
+	return up, down, nil
+}
+
+func getUpVoteCounts(prefix string, ids []string) (data []int64, err error) {
+	pipeline := client.Pipeline()
+	for _, id := range ids {
+		key := getRedisKey(prefix + id)
 		pipeline.ZCount(key, "1", "1")
 	}
 	cmders, err := pipeline.Exec()
 	if err != nil {
-		return nil, err
+		return cachedUpVoteCounts(prefix, ids, err)
 	}
 	data = make([]int64, 0, len(cmders))
-	for _, cmders := range cmders {
+	for i, cmders := range cmders {
 		v := cmders.(*redis.IntCmd).Val()
+		fallback.Add(getRedisKey(prefix+ids[i]), v)
 		data = append(data, v)
 	}
 	return
 }
 
-func GetCommunityPostIDsInOrder(p *models.ParamPostList) ([]string, error) {
-	orderkey := getRedisKey(KeyPostTimeZSet)
-	if p.Order == models.OrderScore {
-		orderkey = getRedisKey(KeyPostScoreZSet)
+// cachedUpVoteCounts redis不可用时使用之前读取的票数，任意一个没有缓存时返回原来的错误
+func cachedUpVoteCounts(prefix string, ids []string, err error) ([]int64, error) {
+	if err != ErrUnavailable && !isConnError(err) {
+		return nil, err
+	}
+	data := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		v, ok := fallback.Get(getRedisKey(prefix + id))
+		if !ok {
+			return nil, err
+		}
+		data = append(data, v.(int64))
+	}
+	return data, nil
+}
+
+// GetUserPostVotes 用户对每个帖子的投票，顺序与ids一致，没有投票的为0
+func GetUserPostVotes(userID string, ids []string) (data []int8, err error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	pipeline := client.Pipeline()
+	cmds := make([]*redis.FloatCmd, 0, len(ids))
+	for _, id := range ids {
+		cmds = append(cmds, pipeline.ZScore(getRedisKey(KeyPostVotedZSetPF+id), userID))
+	}
+	// 没有投票的帖子返回redis.Nil，按每条命令单独判断
+	if _, err = pipeline.Exec(); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	data = make([]int8, 0, len(cmds))
+	for _, cmd := range cmds {
+		v, err := cmd.Result()
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+		data = append(data, int8(v))
+	}
+	return data, nil
+}
+
+// GetCommunityPostIDsInOrder 社区的帖子按p.Order分页，exclude中的帖子(置顶的帖子)不出现在列表中，也不占用分页的位置
+func GetCommunityPostIDsInOrder(p *models.ParamPostList, exclude []string) ([]string, bool, error) {
+	var key string
+	var err error
+	if p.Flair != "" {
+		key, err = communityFlairPostsInOrder(p.Order, p.CommunityID, p.Flair)
+	} else {
+		key, err = communityPostsInOrder(p.Order, p.CommunityID)
 	}
-	communityKey := getRedisKey(KeyCommunitySetPF + strconv.Itoa(int(p.CommunityID)))
-	key := orderkey + strconv.Itoa(int(p.CommunityID))
+	if err != nil {
+		return nil, false, err
+	}
+	if len(exclude) == 0 {
+		return getIDsFromKey(key, p.Page, p.Size)
+	}
+	return getIDsFromKeyExcluding(key, p.Page, p.Size, exclude)
+}
+
+// communityPostsInOrder 返回社区的帖子按order排序后的有序集合，不存在时重新计算，结果缓存一分钟
+func communityPostsInOrder(order string, communityID int64) (string, error) {
+	orderkey := getOrderKey(order)
+	key := getCommunityOrderKey(orderkey, communityID)
 	if client.Exists(key).Val() < 1 {
 		pipeline := client.Pipeline()
 		pipeline.ZInterStore(key, redis.ZStore{
 			Aggregate: "MAX",
-		}, communityKey, orderkey)
+		}, getCommunitySetKey(communityID), orderkey)
 		pipeline.Expire(key, 60*time.Second)
-		_, err := pipeline.Exec()
-		if err != nil {
-			return nil, err
+		if _, err := pipeline.Exec(); err != nil {
+			return "", err
+		}
+	}
+	return key, nil
+}
+
+// communityFlairPostsInOrder 同communityPostsInOrder，只包含带flair的帖子，被移出社区的帖子不在社区的集合中
+// 两个集合的权重为0，结果的分数与排序的有序集合一致
+func communityFlairPostsInOrder(order string, communityID int64, flair string) (string, error) {
+	orderkey := getOrderKey(order)
+	key := getCommunityOrderKey(orderkey, communityID) + ":flair:" + flair
+	if client.Exists(key).Val() < 1 {
+		pipeline := client.Pipeline()
+		pipeline.ZInterStore(key, redis.ZStore{
+			Weights:   []float64{0, 0, 1},
+			Aggregate: "SUM",
+		}, getCommunitySetKey(communityID), getCommunityFlairSetKey(communityID, flair), orderkey)
+		pipeline.Expire(key, 60*time.Second)
+		if _, err := pipeline.Exec(); err != nil {
+			return "", err
 		}
 	}
-	return getIDsFromKey(key, p.Page, p.Size)
+	return key, nil
+}
+
+// GetPostNetVotes 每个帖子的赞成票数减反对票数
+func GetPostNetVotes(ids []string) (data []int64, err error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	pipeline := client.Pipeline()
+	ups := make([]*redis.IntCmd, 0, len(ids))
+	downs := make([]*redis.IntCmd, 0, len(ids))
+	for _, id := range ids {
+		key := getRedisKey(KeyPostVotedZSetPF + id)
+		ups = append(ups, pipeline.ZCount(key, "1", "1"))
+		downs = append(downs, pipeline.ZCount(key, "-1", "-1"))
+	}
+	if _, err = pipeline.Exec(); err != nil {
+		return nil, err
+	}
+	data = make([]int64, 0, len(ids))
+	for i := range ids {
+		data = append(data, ups[i].Val()-downs[i].Val())
+	}
+	return data, nil
 }
@@ -0,0 +1,77 @@
+package redis
+
+import (
+	"encoding/json"
+	"go-web-app/models"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+func getPostDetailKey(pid int64) string {
+	return getRedisKey(KeyPostDetailPF + strconv.FormatInt(pid, 10))
+}
+
+// postDetailCache 内容的哈希和详情一起缓存，计算ETag时不需要重新序列化详情
+type postDetailCache struct {
+	Hash   string             `json:"hash"`
+	Detail *models.PostDetail `json:"detail"`
+}
+
+// GetPostDetailCache 读取缓存的帖子详情，没有缓存时返回nil
+func GetPostDetailCache(pid int64) (*models.PostDetail, error) {
+	b, err := client.Get(getPostDetailKey(pid)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cache := new(postDetailCache)
+	if err = json.Unmarshal(b, cache); err != nil {
+		return nil, err
+	}
+	// 旧格式的缓存当作未命中
+	if cache.Detail == nil || cache.Detail.Post == nil {
+		return nil, nil
+	}
+	cache.Detail.ContentHash = cache.Hash
+	return cache.Detail, nil
+}
+
+func SetPostDetailCache(data *models.PostDetail, ttl time.Duration) error {
+	b, err := json.Marshal(&postDetailCache{Hash: data.ContentHash, Detail: data})
+	if err != nil {
+		return err
+	}
+	return client.Set(getPostDetailKey(data.PostID), b, ttl).Err()
+}
+
+// DeletePostDetailCache 帖子被编辑、删除或者投票后删除缓存，下次读取时重新加载
+func DeletePostDetailCache(pid int64) error {
+	return client.Del(getPostDetailKey(pid)).Err()
+}
+
+// DeleteUserPostDetailCaches 用户改名或注销后删除用户所有帖子的详情缓存，详情中缓存了作者名
+func DeleteUserPostDetailCaches(uid int64) error {
+	ids, err := GetUserPostIDs(uid)
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	const batch = 500
+	for start := 0; start < len(ids); start += batch {
+		end := start + batch
+		if end > len(ids) {
+			end = len(ids)
+		}
+		keys := make([]string, 0, end-start)
+		for _, id := range ids[start:end] {
+			keys = append(keys, getRedisKey(KeyPostDetailPF+id))
+		}
+		if err := client.Del(keys...).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package redis
+
+import (
+	"go-web-app/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteUserPostDetailCaches(t *testing.T) {
+	mr := useMiniredis(t)
+	for _, pid := range []int64{1, 2, 3} {
+		d := &models.PostDetail{AuthorName: "alice", Post: &models.Post{PostID: pid}}
+		require.NoError(t, SetPostDetailCache(d, time.Minute))
+	}
+	_, err := mr.ZAdd(getRedisKey(KeyUserPostZSetPF+"7"), 1, "1")
+	require.NoError(t, err)
+	_, err = mr.ZAdd(getRedisKey(KeyUserPostZSetPF+"7"), 2, "2")
+	require.NoError(t, err)
+
+	// 只删除这个用户的帖子的缓存
+	require.NoError(t, DeleteUserPostDetailCaches(7))
+	for pid, cached := range map[int64]bool{1: false, 2: false, 3: true} {
+		d, err := GetPostDetailCache(pid)
+		require.NoError(t, err)
+		assert.Equal(t, cached, d != nil, pid)
+	}
+	// 没有帖子的用户什么也不做
+	require.NoError(t, DeleteUserPostDetailCaches(8))
+
+	mr.Close()
+	assert.Error(t, DeleteUserPostDetailCaches(7))
+}
@@ -0,0 +1,86 @@
+package redis
+
+import (
+	"go-web-app/models"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// postScoreLoadBatch 重建排序时每个pipeline写入多少个帖子
+const postScoreLoadBatch = 1000
+
+// PostScoresLoaded 帖子分数的zset是否存在，不存在说明redis的数据丢失了，需要从mysql重建
+func PostScoresLoaded() (bool, error) {
+	n, err := client.Exists(getRedisKey(KeyPostScoreZSet)).Result()
+	return n > 0, err
+}
+
+// LoadPostScores 从mysql重建发布时间、分数和热度的排序，已经存在的帖子不会被覆盖
+// 没有写回过分数的帖子按没有投票计算，热度按分数中的净票数计算
+func LoadPostScores(list []*models.PostScore, gravity float64) error {
+	now := time.Now()
+	for start := 0; start < len(list); start += postScoreLoadBatch {
+		end := start + postScoreLoadBatch
+		if end > len(list) {
+			end = len(list)
+		}
+		pipeline := client.Pipeline()
+		for _, p := range list[start:end] {
+			publishTime := float64(p.PublishTime.Unix())
+			score := p.Score
+			if score == 0 {
+				score = publishTime
+			}
+			netVotes := int64((score - publishTime) / scorePerVote)
+			pipeline.ZAddNX(getRedisKey(KeyPostTimeZSet), redis.Z{Score: publishTime, Member: p.PostID})
+			pipeline.ZAddNX(getRedisKey(KeyPostScoreZSet), redis.Z{Score: score, Member: p.PostID})
+			pipeline.ZAddNX(getRedisKey(KeyPostHotZSet), redis.Z{
+				Score:  HotScore(netVotes, now.Sub(p.PublishTime), gravity),
+				Member: p.PostID,
+			})
+		}
+		if _, err := pipeline.Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PopDirtyPostScores 取出最多count个待写回的帖子和它们当前的分数，已经不在排序中的帖子不返回
+func PopDirtyPostScores(count int64) (map[int64]float64, error) {
+	members, err := client.SPopN(getRedisKey(KeyPostScoreDirtySet), count).Result()
+	if err != nil || len(members) == 0 {
+		return nil, err
+	}
+	pipeline := client.Pipeline()
+	cmds := make([]*redis.FloatCmd, 0, len(members))
+	for _, m := range members {
+		cmds = append(cmds, pipeline.ZScore(getRedisKey(KeyPostScoreZSet), m))
+	}
+	if _, err = pipeline.Exec(); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	scores := make(map[int64]float64, len(members))
+	for i, m := range members {
+		pid, err := strconv.ParseInt(m, 10, 64)
+		if err != nil || cmds[i].Err() != nil {
+			continue
+		}
+		scores[pid] = cmds[i].Val()
+	}
+	return scores, nil
+}
+
+// MarkPostScoresDirty 分数变化后标记为待写回，写回mysql失败时也用于重新标记
+func MarkPostScoresDirty(pids ...string) error {
+	if len(pids) == 0 {
+		return nil
+	}
+	members := make([]interface{}, 0, len(pids))
+	for _, pid := range pids {
+		members = append(members, pid)
+	}
+	return client.SAdd(getRedisKey(KeyPostScoreDirtySet), members...).Err()
+}
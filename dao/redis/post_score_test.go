@@ -0,0 +1,60 @@
+package redis
+
+import (
+	"go-web-app/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadPostScores(t *testing.T) {
+	useMiniredis(t)
+	loaded, err := PostScoresLoaded()
+	require.NoError(t, err)
+	assert.False(t, loaded)
+
+	publish := time.Now().Add(-time.Hour).Truncate(time.Second)
+	at := float64(publish.Unix())
+	require.NoError(t, LoadPostScores([]*models.PostScore{
+		{PostID: 1, Score: at + 3*scorePerVote, PublishTime: publish},
+		{PostID: 2, PublishTime: publish}, // 还没有写回过分数
+	}, 1.8))
+	loaded, err = PostScoresLoaded()
+	require.NoError(t, err)
+	assert.True(t, loaded)
+
+	score, err := client.ZScore(getRedisKey(KeyPostScoreZSet), "1").Result()
+	require.NoError(t, err)
+	assert.Equal(t, at+3*scorePerVote, score)
+	score, err = client.ZScore(getRedisKey(KeyPostScoreZSet), "2").Result()
+	require.NoError(t, err)
+	assert.Equal(t, at, score)
+	score, err = client.ZScore(getRedisKey(KeyPostTimeZSet), "1").Result()
+	require.NoError(t, err)
+	assert.Equal(t, at, score)
+	hot, err := client.ZScore(getRedisKey(KeyPostHotZSet), "1").Result()
+	require.NoError(t, err)
+	assert.InDelta(t, HotScore(3, time.Hour, 1.8), hot, 1e-3)
+}
+
+func TestPopDirtyPostScores(t *testing.T) {
+	useMiniredis(t)
+	require.NoError(t, CreatePost(1, nil, 10, nil))
+	_, _, err := VoteForPost("20", "1", 1, 1, time.Hour)
+	require.NoError(t, err)
+	// 已经不在排序中的帖子不返回
+	require.NoError(t, MarkPostScoresDirty("2"))
+
+	scores, err := PopDirtyPostScores(10)
+	require.NoError(t, err)
+	require.Len(t, scores, 1)
+	want, err := client.ZScore(getRedisKey(KeyPostScoreZSet), "1").Result()
+	require.NoError(t, err)
+	assert.Equal(t, want, scores[1])
+
+	scores, err = PopDirtyPostScores(10)
+	require.NoError(t, err)
+	assert.Empty(t, scores)
+}
@@ -0,0 +1,24 @@
+package redis
+
+import (
+	"go-web-app/models"
+	"strconv"
+)
+
+// PurgePosts 从全局列表和作者的列表中移除已彻底删除的帖子，并删除投票记录和详情缓存
+func PurgePosts(posts []*models.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	pipeline := client.TxPipeline()
+	for _, post := range posts {
+		pid := strconv.FormatInt(post.PostID, 10)
+		for _, key := range []string{KeyPostTimeZSet, KeyPostScoreZSet, KeyPostHotZSet, KeyPostControZSet} {
+			pipeline.ZRem(getRedisKey(key), pid)
+		}
+		pipeline.ZRem(getRedisKey(KeyUserPostZSetPF+strconv.FormatInt(post.AuthorId, 10)), pid)
+		pipeline.Del(getRedisKey(KeyPostVotedZSetPF+pid), getRedisKey(KeyPostVoteCountPF+pid), getRedisKey(KeyPostVoteWeightPF+pid), getPostDetailKey(post.PostID))
+	}
+	_, err := pipeline.Exec()
+	return err
+}
@@ -0,0 +1,42 @@
+package redis
+
+import (
+	"go-web-app/models"
+	"testing"
+
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPurgePosts(t *testing.T) {
+	useMiniredis(t)
+	for _, pid := range []string{"1", "2"} {
+		for _, key := range []string{KeyPostTimeZSet, KeyPostScoreZSet, KeyPostHotZSet, KeyPostControZSet, KeyUserPostZSetPF + "7"} {
+			require.NoError(t, client.ZAdd(getRedisKey(key), redis.Z{Score: 1, Member: pid}).Err())
+		}
+		require.NoError(t, client.ZAdd(getRedisKey(KeyPostVotedZSetPF+pid), redis.Z{Score: 1, Member: "9"}).Err())
+		require.NoError(t, client.HSet(getRedisKey(KeyPostVoteCountPF+pid), "up", 1).Err())
+	}
+
+	require.NoError(t, PurgePosts([]*models.Post{{PostID: 1, AuthorId: 7}}))
+	for _, key := range []string{KeyPostTimeZSet, KeyPostScoreZSet, KeyPostHotZSet, KeyPostControZSet, KeyUserPostZSetPF + "7"} {
+		members, err := client.ZRange(getRedisKey(key), 0, -1).Result()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"2"}, members, key)
+	}
+	n, err := client.Exists(getRedisKey(KeyPostVotedZSetPF+"1"), getRedisKey(KeyPostVoteCountPF+"1")).Result()
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	n, err = client.Exists(getRedisKey(KeyPostVotedZSetPF+"2"), getRedisKey(KeyPostVoteCountPF+"2")).Result()
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, n)
+
+	assert.NoError(t, PurgePosts(nil))
+}
+
+func TestPurgePostsRedisDown(t *testing.T) {
+	mr := useMiniredis(t)
+	mr.Close()
+	assert.Error(t, PurgePosts([]*models.Post{{PostID: 1, AuthorId: 7}}))
+}
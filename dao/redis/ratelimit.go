@@ -0,0 +1,94 @@
+package redis
+
+import (
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// 令牌桶：桶里最多burst个令牌，每秒补充rate个，每个请求消耗一个。
+// 返回 {是否允许, 需要等待的毫秒数, 剩余的整数令牌数, 桶重新装满需要的毫秒数}
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local data = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) * 1000 / rate)
+end
+redis.call("HMSET", KEYS[1], "tokens", tokens, "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return {allowed, wait, math.floor(tokens), math.ceil((burst - tokens) * 1000 / rate)}
+`)
+
+// TokenBucket 取令牌之后桶的状态，用于限流的响应头
+type TokenBucket struct {
+	Allowed    bool
+	Remaining  int64         // 桶里剩余的整数令牌数
+	RetryAfter time.Duration // 没有取到令牌时需要等待的时间
+	Reset      time.Duration // 桶重新装满需要的时间
+}
+
+// TakeToken 从key对应的令牌桶中取一个令牌，返回之后桶的状态
+func TakeToken(key string, rate float64, burst int64) (*TokenBucket, error) {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	ret, err := tokenBucketScript.Run(client, []string{getRedisKey(KeyRateLimitPF + key)}, rate, burst, now).Result()
+	if err != nil {
+		return nil, err
+	}
+	vals, ok := ret.([]interface{})
+	if !ok || len(vals) != 4 {
+		return &TokenBucket{Allowed: true, Remaining: burst}, nil
+	}
+	allowedVal, _ := vals[0].(int64)
+	waitVal, _ := vals[1].(int64)
+	remaining, _ := vals[2].(int64)
+	resetVal, _ := vals[3].(int64)
+	return &TokenBucket{
+		Allowed:    allowedVal == 1,
+		Remaining:  remaining,
+		RetryAfter: time.Duration(waitVal) * time.Millisecond,
+		Reset:      time.Duration(resetVal) * time.Millisecond,
+	}, nil
+}
+
+// 固定窗口计数，窗口内第一次计数时设置过期时间。返回 {当前计数, 窗口剩余的毫秒数}
+var windowCounterScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+	ttl = tonumber(ARGV[1])
+end
+return {count, ttl}
+`)
+
+// IncrWindowCounter key对应的计数加一，返回窗口内的计数和窗口结束前的剩余时间
+func IncrWindowCounter(key string, window time.Duration) (count int64, ttl time.Duration, err error) {
+	ret, err := windowCounterScript.Run(client, []string{getRedisKey(KeyRateLimitPF + key)}, window.Milliseconds()).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+	vals, ok := ret.([]interface{})
+	if !ok || len(vals) != 2 {
+		return 0, 0, nil
+	}
+	count, _ = vals[0].(int64)
+	ttlVal, _ := vals[1].(int64)
+	return count, time.Duration(ttlVal) * time.Millisecond, nil
+}
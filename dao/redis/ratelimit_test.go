@@ -0,0 +1,33 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTakeToken(t *testing.T) {
+	useMiniredis(t)
+	// 每秒补充一个，容量为3
+	for i := int64(2); i >= 0; i-- {
+		bucket, err := TakeToken("test:user:1", 1, 3)
+		require.NoError(t, err)
+		assert.True(t, bucket.Allowed)
+		assert.Equal(t, i, bucket.Remaining)
+		assert.InDelta(t, float64((3-i)*int64(time.Second)), float64(bucket.Reset), float64(100*time.Millisecond))
+	}
+	bucket, err := TakeToken("test:user:1", 1, 3)
+	require.NoError(t, err)
+	assert.False(t, bucket.Allowed)
+	assert.Equal(t, int64(0), bucket.Remaining)
+	assert.True(t, bucket.RetryAfter > 0 && bucket.RetryAfter <= time.Second)
+	assert.True(t, bucket.Reset > 2*time.Second && bucket.Reset <= 3*time.Second)
+
+	// 其他调用方的桶不受影响
+	bucket, err = TakeToken("test:user:2", 1, 3)
+	require.NoError(t, err)
+	assert.True(t, bucket.Allowed)
+	assert.Equal(t, int64(2), bucket.Remaining)
+}
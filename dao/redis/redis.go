@@ -1,8 +1,11 @@
 package redis
 
 import (
+	"context"
 	"fmt"
+	"go-web-app/pkg/tracing"
 	"go-web-app/settings"
+	"time"
 
 	"github.com/go-redis/redis"
 )
@@ -12,13 +15,17 @@ var (
 )
 
 // 初始化连接
+// 当前版本的客户端不支持通过ctx取消命令，卡住的redis由读写超时返回错误
 func Init(cfg *settings.RedisConfig) (err error) {
+	timeout := time.Duration(cfg.Timeout) * time.Millisecond
 	client = redis.NewClient(&redis.Options{
 		Addr:         fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
 		Password:     cfg.Password, // no password set
 		DB:           cfg.DB,       // use default DB
 		PoolSize:     cfg.PoolSize,
 		MinIdleConns: cfg.MinIdleConns,
+		ReadTimeout:  timeout,
+		WriteTimeout: timeout,
 	})
 	//client = redis.NewClient(&redis.Options{
 	//	Addr:     fmt.Sprintf("%s:%d", viper.GetString("redis.host"), viper.GetInt("redis.port")),
@@ -29,8 +36,11 @@ func Init(cfg *settings.RedisConfig) (err error) {
 
 	_, err = client.Ping().Result()
 	if err != nil {
+		// 启动时会重试，关闭这次创建的连接池
+		_ = client.Close()
 		return err
 	}
+	initBreaker(cfg.BreakerThreshold, time.Duration(cfg.BreakerCooldown)*time.Millisecond, cfg.FallbackCacheSize)
 	return
 }
 
@@ -38,6 +48,21 @@ func Close() {
 	_ = client.Close()
 }
 
+// PoolStats 返回连接池的统计信息
+func PoolStats() *redis.PoolStats {
+	return client.PoolStats()
+}
+
+// withContext 绑定ctx并记录span的客户端，需要ctx的命令都通过它执行
+func withContext(ctx context.Context) *redis.Client {
+	return tracing.WrapRedis(client.WithContext(ctx))
+}
+
+// Ping 检查redis连接是否可用
+func Ping(ctx context.Context) error {
+	return withContext(ctx).Ping().Err()
+}
+
 //func V8Example() {
 //	ctx := context.Background()
 //	if err := initClient(); err != nil {
@@ -0,0 +1,100 @@
+package redis
+
+import (
+	"encoding/json"
+	"go-web-app/models"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// RelatedCandidate 相关帖子的候选，SharedTags为与当前帖子相同的标签数
+type RelatedCandidate struct {
+	PostID        int64
+	SharedTags    int
+	SameCommunity bool
+}
+
+// GetRelatedCandidates 取出每个标签和每个社区最近的limit个帖子作为候选
+func GetRelatedCandidates(tags []string, communityIDs []int64, limit int64) (map[int64]*RelatedCandidate, error) {
+	keys := make([]string, 0, len(communityIDs))
+	for _, cid := range communityIDs {
+		key, err := communityPostsInOrder(models.OrderTime, cid)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	pipeline := client.Pipeline()
+	tagCmds := make([]*redis.StringSliceCmd, 0, len(tags))
+	for _, tag := range tags {
+		tagCmds = append(tagCmds, pipeline.ZRevRange(getRedisKey(KeyTagPostZSetPF+tag), 0, limit-1))
+	}
+	communityCmds := make([]*redis.StringSliceCmd, 0, len(keys))
+	for _, key := range keys {
+		communityCmds = append(communityCmds, pipeline.ZRevRange(key, 0, limit-1))
+	}
+	if _, err := pipeline.Exec(); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	candidates := make(map[int64]*RelatedCandidate)
+	get := func(id string) *RelatedCandidate {
+		pid, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return nil
+		}
+		c, ok := candidates[pid]
+		if !ok {
+			c = &RelatedCandidate{PostID: pid}
+			candidates[pid] = c
+		}
+		return c
+	}
+	for _, cmd := range tagCmds {
+		for _, id := range cmd.Val() {
+			if c := get(id); c != nil {
+				c.SharedTags++
+			}
+		}
+	}
+	for _, cmd := range communityCmds {
+		for _, id := range cmd.Val() {
+			if c := get(id); c != nil {
+				c.SameCommunity = true
+			}
+		}
+	}
+	return candidates, nil
+}
+
+func getRelatedKey(pid int64) string {
+	return getRedisKey(KeyPostRelatedPF + strconv.FormatInt(pid, 10))
+}
+
+// GetRelatedCache 缓存的相关帖子id，没有缓存时ok为false
+func GetRelatedCache(pid int64) (ids []int64, ok bool, err error) {
+	b, err := client.Get(getRelatedKey(pid)).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if err = json.Unmarshal(b, &ids); err != nil {
+		return nil, false, err
+	}
+	return ids, true, nil
+}
+
+// SetRelatedCache 只缓存id，投票数等在读取时获取最新的值
+func SetRelatedCache(pid int64, ids []int64, ttl time.Duration) error {
+	if ids == nil {
+		ids = []int64{}
+	}
+	b, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+	return client.Set(getRelatedKey(pid), b, ttl).Err()
+}
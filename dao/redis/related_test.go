@@ -0,0 +1,51 @@
+package redis
+
+import (
+	"go-web-app/models"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRelatedCandidates(t *testing.T) {
+	useMiniredis(t)
+	now := time.Now()
+	require.NoError(t, AddPostToTags(1, []string{"go", "web"}, now))
+	require.NoError(t, AddPostToTags(2, []string{"go"}, now))
+	require.NoError(t, AddPostToTags(3, []string{"rust"}, now))
+	// miniredis的ZINTERSTORE不支持普通集合，直接写入社区按时间排序后的结果
+	communityKey := getCommunityOrderKey(getOrderKey(models.OrderTime), 7)
+	for _, pid := range []int64{3, 4} {
+		require.NoError(t, client.ZAdd(communityKey, redis.Z{Score: float64(now.Unix()), Member: pid}).Err())
+	}
+
+	candidates, err := GetRelatedCandidates([]string{"go", "web"}, []int64{7}, 10)
+	require.NoError(t, err)
+	assert.Len(t, candidates, 4)
+	assert.Equal(t, 2, candidates[1].SharedTags)
+	assert.Equal(t, 1, candidates[2].SharedTags)
+	assert.False(t, candidates[2].SameCommunity)
+	assert.Equal(t, 0, candidates[3].SharedTags)
+	assert.True(t, candidates[3].SameCommunity)
+	assert.True(t, candidates[4].SameCommunity)
+}
+
+func TestRelatedCache(t *testing.T) {
+	useMiniredis(t)
+	_, ok, err := GetRelatedCache(1)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, SetRelatedCache(1, nil, time.Minute))
+	ids, ok, err := GetRelatedCache(1)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Empty(t, ids)
+
+	require.NoError(t, SetRelatedCache(1, []int64{3, 2}, time.Minute))
+	ids, _, _ = GetRelatedCache(1)
+	assert.Equal(t, []int64{3, 2}, ids)
+}
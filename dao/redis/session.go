@@ -0,0 +1,240 @@
+package redis
+
+import (
+	"go-web-app/models"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// 每次登录创建一个会话，会话的hash保存当前有效的refresh token和登录时选择的有效期(毫秒)
+// 用户的会话id保存在zset中，分数为会话的过期时间，zset的过期时间为最晚过期的会话
+// 创建会话时清理已经过期的会话id，返回剩余的会话数
+var createSessionScript = redis.NewScript(`
+redis.call("DEL", KEYS[1])
+redis.call("HMSET", KEYS[1], "token", ARGV[2], "lifetime", ARGV[3], "user_agent", ARGV[5], "ip", ARGV[6],
+	"create_time", ARGV[4], "last_seen", ARGV[4])
+redis.call("PEXPIRE", KEYS[1], ARGV[3])
+redis.call("ZADD", KEYS[2], tonumber(ARGV[4]) + tonumber(ARGV[3]), ARGV[1])
+redis.call("ZREMRANGEBYSCORE", KEYS[2], "-inf", ARGV[4])
+local last = redis.call("ZREVRANGE", KEYS[2], 0, 0, "WITHSCORES")
+redis.call("PEXPIREAT", KEYS[2], last[2])
+return redis.call("ZCARD", KEYS[2])
+`)
+
+// 只有会话当前的token与旧token一致时才替换，保证同一个refresh token只能使用一次，轮换时沿用登录时的有效期
+var rotateSessionScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], "token") ~= ARGV[2] then
+	return 0
+end
+local lifetime = tonumber(redis.call("HGET", KEYS[1], "lifetime"))
+redis.call("HMSET", KEYS[1], "token", ARGV[3], "last_seen", ARGV[4], "ip", ARGV[5], "user_agent", ARGV[6])
+redis.call("PEXPIRE", KEYS[1], lifetime)
+redis.call("ZADD", KEYS[2], tonumber(ARGV[4]) + lifetime, ARGV[1])
+local last = redis.call("ZREVRANGE", KEYS[2], 0, 0, "WITHSCORES")
+redis.call("PEXPIREAT", KEYS[2], last[2])
+return 1
+`)
+
+// 取出并删除没有会话id的旧refresh token，兼容直接保存为字符串的token，返回登录时选择的有效期(毫秒)，不匹配时返回-1
+var consumeLegacyRefreshTokenScript = redis.NewScript(`
+-- TYPE的状态回复在Lua中是{ok = 类型}的table
+local t = redis.call("TYPE", KEYS[1])
+if type(t) == "table" then
+	t = t.ok
+end
+local current, lifetime
+if t == "hash" then
+	current = redis.call("HGET", KEYS[1], "token")
+	lifetime = tonumber(redis.call("HGET", KEYS[1], "lifetime"))
+elseif t == "string" then
+	current = redis.call("GET", KEYS[1])
+end
+if current ~= ARGV[1] then
+	return -1
+end
+redis.call("DEL", KEYS[1])
+return lifetime or 0
+`)
+
+func getSessionKey(userID int64, sessionID string) string {
+	return getRedisKey(KeySessionPF + strconv.FormatInt(userID, 10) + ":" + sessionID)
+}
+
+func getUserSessionKey(userID int64) string {
+	return getRedisKey(KeyUserSessionZSetPF + strconv.FormatInt(userID, 10))
+}
+
+func getRefreshTokenKey(userID int64) string {
+	return getRedisKey(KeyRefreshTokenPF + strconv.FormatInt(userID, 10))
+}
+
+// CreateSession 保存新会话的refresh token，会话数超过max时删除最早过期的会话，max为0表示不限制
+func CreateSession(userID int64, sessionID, token string, lifetime time.Duration, info *models.ClientInfo, max int) error {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	count, err := createSessionScript.Run(client, []string{getSessionKey(userID, sessionID), getUserSessionKey(userID)},
+		sessionID, token, lifetime.Milliseconds(), now, info.UserAgent, info.IP).Int()
+	if err != nil || max <= 0 || count <= max {
+		return err
+	}
+	ids, err := client.ZRange(getUserSessionKey(userID), 0, int64(count-max)).Result()
+	if err != nil {
+		return err
+	}
+	evict := make([]string, 0, count-max)
+	for _, id := range ids {
+		if id != sessionID && len(evict) < count-max {
+			evict = append(evict, id)
+		}
+	}
+	return deleteSessions(userID, evict)
+}
+
+// GetSessionLifetime 会话登录时选择的refresh token有效期，会话不存在或者已经撤销时返回ErrRefreshTokenInvalid
+func GetSessionLifetime(userID int64, sessionID string) (time.Duration, error) {
+	ms, err := client.HGet(getSessionKey(userID, sessionID), "lifetime").Int64()
+	if err == redis.Nil {
+		return 0, ErrRefreshTokenInvalid
+	}
+	return time.Duration(ms) * time.Millisecond, err
+}
+
+// RotateSessionToken 用新的refresh token替换会话的旧token，并记录这次刷新的客户端
+// 旧token不存在或不匹配时返回ErrRefreshTokenInvalid
+func RotateSessionToken(userID int64, sessionID, oldToken, newToken string, info *models.ClientInfo) error {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	ok, err := rotateSessionScript.Run(client, []string{getSessionKey(userID, sessionID), getUserSessionKey(userID)},
+		sessionID, oldToken, newToken, now, info.IP, info.UserAgent).Int()
+	if err != nil {
+		return err
+	}
+	if ok != 1 {
+		return ErrRefreshTokenInvalid
+	}
+	return nil
+}
+
+// ConsumeLegacyRefreshToken 校验并删除没有会话id的旧refresh token，返回登录时选择的有效期，没有记录有效期时返回0
+func ConsumeLegacyRefreshToken(userID int64, token string) (time.Duration, error) {
+	ms, err := consumeLegacyRefreshTokenScript.Run(client, []string{getRefreshTokenKey(userID)}, token).Int64()
+	if err != nil {
+		return 0, err
+	}
+	if ms < 0 {
+		return 0, ErrRefreshTokenInvalid
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// SessionExists 会话是否还有效，撤销之后签发给它的access token也不能再使用
+func SessionExists(userID int64, sessionID string) (bool, error) {
+	n, err := client.Exists(getSessionKey(userID, sessionID)).Result()
+	return n > 0, err
+}
+
+// GetSessions 用户所有有效的会话，最近使用的在前
+func GetSessions(userID int64) ([]*models.Session, error) {
+	key := getUserSessionKey(userID)
+	now := time.Now()
+	ids, err := client.ZRangeByScore(key, redis.ZRangeBy{
+		Min: strconv.FormatInt(now.UnixNano()/int64(time.Millisecond), 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	pipeline := client.Pipeline()
+	cmds := make([]*redis.SliceCmd, 0, len(ids))
+	ttls := make([]*redis.DurationCmd, 0, len(ids))
+	for _, id := range ids {
+		cmds = append(cmds, pipeline.HMGet(getSessionKey(userID, id), "user_agent", "ip", "create_time", "last_seen"))
+		ttls = append(ttls, pipeline.PTTL(getSessionKey(userID, id)))
+	}
+	if len(ids) > 0 {
+		if _, err = pipeline.Exec(); err != nil {
+			return nil, err
+		}
+	}
+	sessions := make([]*models.Session, 0, len(ids))
+	for i, id := range ids {
+		vals := cmds[i].Val()
+		// 会话已经撤销或者过期
+		if len(vals) < 4 || vals[2] == nil {
+			continue
+		}
+		ua, _ := vals[0].(string)
+		ip, _ := vals[1].(string)
+		sessions = append(sessions, &models.Session{
+			ID:         id,
+			UserAgent:  ua,
+			IP:         ip,
+			CreateTime: msToTime(vals[2]),
+			LastSeen:   msToTime(vals[3]),
+			ExpireTime: now.Add(ttls[i].Val()),
+		})
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].LastSeen.After(sessions[j].LastSeen)
+	})
+	return sessions, nil
+}
+
+func msToTime(v interface{}) time.Time {
+	s, _ := v.(string)
+	ms, _ := strconv.ParseInt(s, 10, 64)
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+// DeleteSession 撤销一个会话，会话不存在时返回false
+func DeleteSession(userID int64, sessionID string) (bool, error) {
+	pipeline := client.TxPipeline()
+	del := pipeline.Del(getSessionKey(userID, sessionID))
+	pipeline.ZRem(getUserSessionKey(userID), sessionID)
+	if _, err := pipeline.Exec(); err != nil {
+		return false, err
+	}
+	return del.Val() > 0, nil
+}
+
+// DeleteOtherSessions 撤销除了keep之外的所有会话和旧的refresh token，返回撤销的会话数
+func DeleteOtherSessions(userID int64, keep string) (int, error) {
+	ids, err := client.ZRange(getUserSessionKey(userID), 0, -1).Result()
+	if err != nil {
+		return 0, err
+	}
+	others := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id != keep {
+			others = append(others, id)
+		}
+	}
+	if err = client.Del(getRefreshTokenKey(userID)).Err(); err != nil {
+		return 0, err
+	}
+	return len(others), deleteSessions(userID, others)
+}
+
+// DeleteSessions 撤销用户所有的会话，用于退出所有设备、重置密码、注销和封禁
+func DeleteSessions(userID int64) error {
+	_, err := DeleteOtherSessions(userID, "")
+	return err
+}
+
+func deleteSessions(userID int64, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(ids))
+	members := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, getSessionKey(userID, id))
+		members = append(members, id)
+	}
+	pipeline := client.TxPipeline()
+	pipeline.Del(keys...)
+	pipeline.ZRem(getUserSessionKey(userID), members...)
+	_, err := pipeline.Exec()
+	return err
+}
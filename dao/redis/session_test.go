@@ -0,0 +1,98 @@
+package redis
+
+import (
+	"go-web-app/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessions(t *testing.T) {
+	mr := useMiniredis(t)
+	phone := &models.ClientInfo{IP: "1.1.1.1", UserAgent: "phone"}
+	laptop := &models.ClientInfo{IP: "2.2.2.2", UserAgent: "laptop"}
+	require.NoError(t, CreateSession(1, "a", "ta", time.Hour, phone, 0))
+	require.NoError(t, CreateSession(1, "b", "tb", 24*time.Hour, laptop, 0))
+	// 用户的会话列表在最晚过期的会话之后过期
+	assert.InDelta(t, float64(24*time.Hour), float64(mr.TTL(getUserSessionKey(1))), float64(time.Minute))
+
+	lifetime, err := GetSessionLifetime(1, "b")
+	require.NoError(t, err)
+	assert.Equal(t, 24*time.Hour, lifetime)
+
+	// 旧token只能使用一次，刷新时记录新的客户端
+	require.NoError(t, RotateSessionToken(1, "a", "ta", "ta2", laptop))
+	assert.Equal(t, ErrRefreshTokenInvalid, RotateSessionToken(1, "a", "ta", "ta3", laptop))
+	assert.Equal(t, ErrRefreshTokenInvalid, RotateSessionToken(1, "c", "tc", "tc2", laptop))
+
+	sessions, err := GetSessions(1)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, "a", sessions[0].ID)
+	assert.Equal(t, "laptop", sessions[0].UserAgent)
+	assert.Equal(t, "2.2.2.2", sessions[0].IP)
+	assert.Equal(t, "b", sessions[1].ID)
+	assert.WithinDuration(t, time.Now(), sessions[1].CreateTime, time.Minute)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), sessions[1].ExpireTime, time.Minute)
+
+	ok, err := DeleteSession(1, "a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	exists, err := SessionExists(1, "a")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	assert.Equal(t, ErrRefreshTokenInvalid, RotateSessionToken(1, "a", "ta2", "ta3", laptop))
+	_, err = GetSessionLifetime(1, "a")
+	assert.Equal(t, ErrRefreshTokenInvalid, err)
+
+	require.NoError(t, CreateSession(1, "c", "tc", time.Hour, phone, 0))
+	n, err := DeleteOtherSessions(1, "c")
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	sessions, err = GetSessions(1)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "c", sessions[0].ID)
+
+	require.NoError(t, DeleteSessions(1))
+	sessions, err = GetSessions(1)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+}
+
+func TestCreateSessionEvictsOldest(t *testing.T) {
+	useMiniredis(t)
+	info := &models.ClientInfo{}
+	require.NoError(t, CreateSession(1, "a", "ta", time.Hour, info, 2))
+	require.NoError(t, CreateSession(1, "b", "tb", 2*time.Hour, info, 2))
+	// 新会话比已有的会话先过期时也不会被删除
+	require.NoError(t, CreateSession(1, "c", "tc", time.Minute, info, 2))
+	sessions, err := GetSessions(1)
+	require.NoError(t, err)
+	ids := make([]string, 0, len(sessions))
+	for _, s := range sessions {
+		ids = append(ids, s.ID)
+	}
+	assert.ElementsMatch(t, []string{"b", "c"}, ids)
+}
+
+func TestConsumeLegacyRefreshToken(t *testing.T) {
+	mr := useMiniredis(t)
+	require.NoError(t, mr.Set(getRefreshTokenKey(1), "old"))
+	mr.HSet(getRefreshTokenKey(2), "token", "hashed")
+	mr.HSet(getRefreshTokenKey(2), "lifetime", "60000")
+
+	_, err := ConsumeLegacyRefreshToken(1, "wrong")
+	assert.Equal(t, ErrRefreshTokenInvalid, err)
+	lifetime, err := ConsumeLegacyRefreshToken(1, "old")
+	require.NoError(t, err)
+	assert.Zero(t, lifetime)
+	_, err = ConsumeLegacyRefreshToken(1, "old")
+	assert.Equal(t, ErrRefreshTokenInvalid, err)
+
+	lifetime, err = ConsumeLegacyRefreshToken(2, "hashed")
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, lifetime)
+}
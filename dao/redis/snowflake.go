@@ -0,0 +1,51 @@
+package redis
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+var ErrNoMachineID = errors.New("No machine id available. ")
+
+func getMachineIDKey(id int64) string {
+	return getRedisKey(KeyMachineIDPF + strconv.FormatInt(id, 10))
+}
+
+// LeaseMachineID 租用一个[0, max]范围内没有被占用的机器id。
+// 计数器递增后取模作为起点，依次尝试，所有id都被占用时返回ErrNoMachineID
+func LeaseMachineID(max int64, owner string, ttl time.Duration) (int64, error) {
+	start, err := client.Incr(getRedisKey(KeyMachineIDCounter)).Result()
+	if err != nil {
+		return 0, err
+	}
+	for i := int64(0); i <= max; i++ {
+		id := (start + i) % (max + 1)
+		ok, err := client.SetNX(getMachineIDKey(id), owner, ttl).Result()
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return id, nil
+		}
+	}
+	return 0, ErrNoMachineID
+}
+
+// RenewMachineID 续期租约，租约已经不属于owner时返回ErrNoMachineID
+// 租约和锁一样，只有值仍是owner时才续期或释放
+func RenewMachineID(id int64, owner string, ttl time.Duration) error {
+	ok, err := renewLockScript.Run(client, []string{getMachineIDKey(id)}, owner, ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if ok != 1 {
+		return ErrNoMachineID
+	}
+	return nil
+}
+
+// ReleaseMachineID 释放租约，其他实例可以马上使用这个id
+func ReleaseMachineID(id int64, owner string) error {
+	return releaseLockScript.Run(client, []string{getMachineIDKey(id)}, owner).Err()
+}
@@ -0,0 +1,116 @@
+package redis
+
+import (
+	"go-web-app/models"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// GetTagPostIDs 按发布时间倒序取出标签下的帖子id
+func GetTagPostIDs(tag string, page, size int64) ([]string, bool, error) {
+	return getIDsFromKey(getRedisKey(KeyTagPostZSetPF+tag), page, size)
+}
+
+// AddPostToTags 把帖子加入各个标签的有序集合，恢复被删除的帖子时使用
+func AddPostToTags(postID int64, tags []string, createTime time.Time) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	pipeline := client.TxPipeline()
+	for _, tag := range tags {
+		pipeline.ZAdd(getRedisKey(KeyTagPostZSetPF+tag), redis.Z{
+			Score:  float64(createTime.Unix()),
+			Member: postID,
+		})
+	}
+	_, err := pipeline.Exec()
+	return err
+}
+
+// RemovePostFromTags 帖子被删除后不再计入标签
+func RemovePostFromTags(postID int64, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	pipeline := client.TxPipeline()
+	for _, tag := range tags {
+		pipeline.ZRem(getRedisKey(KeyTagPostZSetPF+tag), postID)
+	}
+	_, err := pipeline.Exec()
+	return err
+}
+
+// GetTagPostCounts 返回每个标签下的帖子数，顺序与tags一致
+func GetTagPostCounts(tags []string) (counts []int64, err error) {
+	counts = make([]int64, 0, len(tags))
+	if len(tags) == 0 {
+		return
+	}
+	pipeline := client.Pipeline()
+	for _, tag := range tags {
+		pipeline.ZCard(getRedisKey(KeyTagPostZSetPF + tag))
+	}
+	cmders, err := pipeline.Exec()
+	if err != nil {
+		return nil, err
+	}
+	for _, cmder := range cmders {
+		counts = append(counts, cmder.(*redis.IntCmd).Val())
+	}
+	return
+}
+
+// tagBucketKey 每小时一个有序集合，member为标签，分数为这一小时内的活跃次数
+func tagBucketKey(t time.Time) string {
+	return getRedisKey(KeyTagTrendingPF + strconv.FormatInt(t.Unix()/3600, 10))
+}
+
+// IncrTagActivity 标签下有新帖子或新投票时计数加一，分桶在统计窗口之后自动过期
+func IncrTagActivity(tags []string, window time.Duration) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	key := tagBucketKey(time.Now())
+	pipeline := client.TxPipeline()
+	for _, tag := range tags {
+		pipeline.ZIncrBy(key, 1, tag)
+	}
+	pipeline.Expire(key, window+time.Hour)
+	_, err := pipeline.Exec()
+	return err
+}
+
+// GetTrendingTags 合并最近window个小时的分桶，返回活跃次数最多的size个标签
+// 合并结果缓存一分钟，避免每次请求都做ZUNIONSTORE
+func GetTrendingTags(window int, size int64) ([]*models.TrendingTag, error) {
+	now := time.Now()
+	keys := make([]string, 0, window)
+	for i := 0; i < window; i++ {
+		keys = append(keys, tagBucketKey(now.Add(-time.Duration(i)*time.Hour)))
+	}
+	key := getRedisKey(KeyTagTrendingPF + "top:" + strconv.Itoa(window))
+	exists, err := client.Exists(key).Result()
+	if err != nil {
+		return nil, err
+	}
+	if exists < 1 {
+		pipeline := client.Pipeline()
+		pipeline.ZUnionStore(key, redis.ZStore{}, keys...)
+		pipeline.Expire(key, 60*time.Second)
+		if _, err := pipeline.Exec(); err != nil {
+			return nil, err
+		}
+	}
+	zs, err := client.ZRevRangeWithScores(key, 0, size-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]*models.TrendingTag, 0, len(zs))
+	for _, z := range zs {
+		name, _ := z.Member.(string)
+		tags = append(tags, &models.TrendingTag{Name: name, Activity: int64(z.Score)})
+	}
+	return tags, nil
+}
@@ -0,0 +1,97 @@
+package redis
+
+import (
+	"go-web-app/models"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+func getUserTagKey(uid int64) string {
+	return getRedisKey(KeyUserTagSetPF + strconv.FormatInt(uid, 10))
+}
+
+// AddTagFollow 关注标签时同时更新用户关注的标签和标签的关注者
+func AddTagFollow(uid int64, tag string) error {
+	pipeline := client.TxPipeline()
+	pipeline.SAdd(getUserTagKey(uid), tag)
+	pipeline.SAdd(getRedisKey(KeyTagFollowerSetPF+tag), uid)
+	_, err := pipeline.Exec()
+	return err
+}
+
+// RemoveTagFollow 取消关注标签
+func RemoveTagFollow(uid int64, tag string) error {
+	pipeline := client.TxPipeline()
+	pipeline.SRem(getUserTagKey(uid), tag)
+	pipeline.SRem(getRedisKey(KeyTagFollowerSetPF+tag), uid)
+	_, err := pipeline.Exec()
+	return err
+}
+
+// GetFollowedTags 用户关注的标签，没有顺序
+func GetFollowedTags(uid int64) ([]string, error) {
+	return client.SMembers(getUserTagKey(uid)).Result()
+}
+
+// GetTagFollowers 一次取出多个标签的关注者，关注了其中多个标签的用户只返回一次
+func GetTagFollowers(tags []string) ([]int64, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		keys = append(keys, getRedisKey(KeyTagFollowerSetPF+tag))
+	}
+	members, err := client.SUnion(keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	uids := make([]int64, 0, len(members))
+	for _, m := range members {
+		uid, err := strconv.ParseInt(m, 10, 64)
+		if err != nil {
+			continue
+		}
+		uids = append(uids, uid)
+	}
+	return uids, nil
+}
+
+// TagFollowsLoaded 标签的关注关系是否已经从mysql重建，标记丢失说明redis的数据丢失了
+func TagFollowsLoaded() (bool, error) {
+	n, err := client.Exists(getRedisKey(KeyTagFollowsLoaded)).Result()
+	return n > 0, err
+}
+
+// LoadTagFollows 从mysql重建标签的关注关系，完成后设置重建标记
+func LoadTagFollows(follows []*models.TagFollow) error {
+	pipeline := client.TxPipeline()
+	for _, f := range follows {
+		pipeline.SAdd(getUserTagKey(f.UserID), f.Tag)
+		pipeline.SAdd(getRedisKey(KeyTagFollowerSetPF+f.Tag), f.UserID)
+	}
+	pipeline.Set(getRedisKey(KeyTagFollowsLoaded), 1, 0)
+	_, err := pipeline.Exec()
+	return err
+}
+
+// GetHotPostIDsAbove 热度分数不低于min的帖子id，分数高的在前，最多limit个
+func GetHotPostIDsAbove(min float64, limit int64) ([]string, error) {
+	return client.ZRevRangeByScore(getRedisKey(KeyPostHotZSet), redis.ZRangeBy{
+		Min:   strconv.FormatFloat(min, 'f', -1, 64),
+		Max:   "+inf",
+		Count: limit,
+	}).Result()
+}
+
+// MarkHotPostNotified 记录帖子已经通知过关注者，ttl内再次标记时返回false
+func MarkHotPostNotified(postID string, ttl time.Duration) (bool, error) {
+	return client.SetNX(getRedisKey(KeyTagHotNotifiedPF+postID), 1, ttl).Result()
+}
+
+// UnmarkHotPostNotified 通知失败时删除记录，下次检查时重新通知
+func UnmarkHotPostNotified(postID string) error {
+	return client.Del(getRedisKey(KeyTagHotNotifiedPF + postID)).Err()
+}
@@ -0,0 +1,58 @@
+package redis
+
+import (
+	"go-web-app/models"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagFollow(t *testing.T) {
+	useMiniredis(t)
+	loaded, err := TagFollowsLoaded()
+	require.NoError(t, err)
+	assert.False(t, loaded)
+	require.NoError(t, LoadTagFollows([]*models.TagFollow{{UserID: 1, Tag: "go"}}))
+	loaded, err = TagFollowsLoaded()
+	require.NoError(t, err)
+	assert.True(t, loaded)
+
+	require.NoError(t, AddTagFollow(1, "rust"))
+	require.NoError(t, AddTagFollow(2, "rust"))
+	require.NoError(t, AddTagFollow(3, "python"))
+	require.NoError(t, RemoveTagFollow(3, "python"))
+
+	tags, err := GetFollowedTags(1)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"go", "rust"}, tags)
+	uids, err := GetTagFollowers([]string{"go", "rust", "python"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{1, 2}, uids)
+}
+
+func TestHotPostNotified(t *testing.T) {
+	mr := useMiniredis(t)
+	client.ZAdd(getRedisKey(KeyPostHotZSet), redis.Z{Score: 1, Member: 1}, redis.Z{Score: 3, Member: 2}, redis.Z{Score: 5, Member: 3})
+	ids, err := GetHotPostIDsAbove(3, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"3", "2"}, ids)
+
+	ok, err := MarkHotPostNotified("3", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = MarkHotPostNotified("3", time.Hour)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	mr.FastForward(time.Hour)
+	ok, err = MarkHotPostNotified("3", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	require.NoError(t, UnmarkHotPostNotified("3"))
+	ok, err = MarkHotPostNotified("3", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
@@ -0,0 +1,61 @@
+package redis
+
+import (
+	"go-web-app/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTrendingTags(t *testing.T) {
+	mr := useMiniredis(t)
+	require.NoError(t, IncrTagActivity([]string{"go", "rust"}, 24*time.Hour))
+	require.NoError(t, IncrTagActivity([]string{"go"}, 24*time.Hour))
+
+	tags, err := GetTrendingTags(24, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []*models.TrendingTag{{Name: "go", Activity: 2}, {Name: "rust", Activity: 1}}, tags)
+
+	// 合并结果缓存期间不重新合并
+	require.NoError(t, IncrTagActivity([]string{"rust", "rust"}, 24*time.Hour))
+	tags, err = GetTrendingTags(24, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []*models.TrendingTag{{Name: "go", Activity: 2}}, tags)
+
+	// redis不可用时返回错误，而不是当作缓存不存在
+	mr.Close()
+	_, err = GetTrendingTags(24, 10)
+	assert.Error(t, err)
+}
+
+func TestTagPosts(t *testing.T) {
+	useMiniredis(t)
+	now := time.Now()
+	require.NoError(t, AddPostToTags(1, []string{"go", "rust"}, now.Add(-time.Hour)))
+	require.NoError(t, AddPostToTags(2, []string{"go"}, now))
+	require.NoError(t, AddPostToTags(3, nil, now))
+
+	// 按发布时间倒序
+	ids, hasMore, err := GetTagPostIDs("go", 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2"}, ids)
+	assert.True(t, hasMore)
+	ids, hasMore, err = GetTagPostIDs("go", 2, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1"}, ids)
+	assert.False(t, hasMore)
+
+	counts, err := GetTagPostCounts([]string{"go", "rust", "missing"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2, 1, 0}, counts)
+
+	require.NoError(t, RemovePostFromTags(1, []string{"go", "rust"}))
+	counts, err = GetTagPostCounts([]string{"go", "rust"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 0}, counts)
+	counts, err = GetTagPostCounts(nil)
+	require.NoError(t, err)
+	assert.Empty(t, counts)
+}
@@ -0,0 +1,69 @@
+package redis
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+var ErrTwoFactorChallengeInvalid = errors.New("Two-factor token is invalid or expired. ")
+
+// 增加临时token的尝试次数，token不存在时返回-1，不会创建没有过期时间的key
+var twoFactorAttemptScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return -1
+end
+return redis.call("HINCRBY", KEYS[1], "attempts", 1)
+`)
+
+func getTwoFactorChallengeKey(token string) string {
+	return getRedisKey(KeyTwoFactorChallengePF + token)
+}
+
+// SetTwoFactorChallenge 保存密码校验通过之后的临时token，lifetime为验证通过后refresh token的有效期
+func SetTwoFactorChallenge(token string, userID int64, lifetime, expiration time.Duration) error {
+	key := getTwoFactorChallengeKey(token)
+	pipeline := client.TxPipeline()
+	pipeline.HMSet(key, map[string]interface{}{"uid": userID, "lifetime": int64(lifetime / time.Second)})
+	pipeline.Expire(key, expiration)
+	_, err := pipeline.Exec()
+	return err
+}
+
+// GetTwoFactorChallenge 临时token对应的用户和refresh token的有效期
+func GetTwoFactorChallenge(token string) (userID int64, lifetime time.Duration, err error) {
+	vals, err := client.HMGet(getTwoFactorChallengeKey(token), "uid", "lifetime").Result()
+	if err != nil {
+		return 0, 0, err
+	}
+	uid, _ := vals[0].(string)
+	if userID, err = strconv.ParseInt(uid, 10, 64); err != nil {
+		return 0, 0, ErrTwoFactorChallengeInvalid
+	}
+	seconds, _ := vals[1].(string)
+	n, _ := strconv.ParseInt(seconds, 10, 64)
+	return userID, time.Duration(n) * time.Second, nil
+}
+
+// IncrTwoFactorAttempts 记录一次验证码尝试，返回包括这一次在内的次数
+func IncrTwoFactorAttempts(token string) (int64, error) {
+	n, err := twoFactorAttemptScript.Run(client, []string{getTwoFactorChallengeKey(token)}).Int64()
+	if err == nil && n < 0 {
+		err = ErrTwoFactorChallengeInvalid
+	}
+	return n, err
+}
+
+// ConsumeTwoFactorChallenge 删除临时token，只有一个请求能删除成功，保证token只能换取一次正式的token
+func ConsumeTwoFactorChallenge(token string) (bool, error) {
+	n, err := client.Del(getTwoFactorChallengeKey(token)).Result()
+	return n > 0, err
+}
+
+// MarkTOTPUsed 记录验证码已经使用，ttl内同一个周期的验证码再次标记时返回false
+func MarkTOTPUsed(userID, step int64, ttl time.Duration) (bool, error) {
+	key := getRedisKey(KeyTOTPUsedPF + strconv.FormatInt(userID, 10) + ":" + strconv.FormatInt(step, 10))
+	return client.SetNX(key, 1, ttl).Result()
+}
@@ -0,0 +1,55 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTwoFactorChallenge(t *testing.T) {
+	mr := useMiniredis(t)
+	require.NoError(t, SetTwoFactorChallenge("tok", 7, 24*time.Hour, 5*time.Minute))
+	uid, lifetime, err := GetTwoFactorChallenge("tok")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), uid)
+	assert.Equal(t, 24*time.Hour, lifetime)
+
+	for i := int64(1); i <= 2; i++ {
+		n, err := IncrTwoFactorAttempts("tok")
+		require.NoError(t, err)
+		assert.Equal(t, i, n)
+	}
+	assert.Equal(t, 5*time.Minute, mr.TTL(getRedisKey(KeyTwoFactorChallengePF+"tok")))
+
+	ok, err := ConsumeTwoFactorChallenge("tok")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = ConsumeTwoFactorChallenge("tok")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// token不存在时不会重新创建
+	_, _, err = GetTwoFactorChallenge("tok")
+	assert.Equal(t, ErrTwoFactorChallengeInvalid, err)
+	_, err = IncrTwoFactorAttempts("tok")
+	assert.Equal(t, ErrTwoFactorChallengeInvalid, err)
+	assert.False(t, mr.Exists(getRedisKey(KeyTwoFactorChallengePF+"tok")))
+}
+
+func TestMarkTOTPUsed(t *testing.T) {
+	useMiniredis(t)
+	ok, err := MarkTOTPUsed(1, 100, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = MarkTOTPUsed(1, 100, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = MarkTOTPUsed(1, 101, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = MarkTOTPUsed(2, 100, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
@@ -0,0 +1,110 @@
+package redis
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+var (
+	ErrRefreshTokenInvalid       = errors.New("Refresh token is invalid or has been revoked. ")
+	ErrPasswordResetTokenInvalid = errors.New("Password reset token is invalid or expired. ")
+	ErrVerifyTokenInvalid        = errors.New("Verification token is invalid or expired. ")
+	ErrReactivateTokenInvalid    = errors.New("Reactivation token is invalid or expired. ")
+)
+
+// SetPasswordResetToken 保存密码重置token对应的用户
+func SetPasswordResetToken(token string, userID int64, expiration time.Duration) error {
+	return client.Set(getRedisKey(KeyPasswordResetPF+token), userID, expiration).Err()
+}
+
+// ConsumePasswordResetToken 取出token对应的用户并删除token，保证token只能使用一次
+func ConsumePasswordResetToken(token string) (int64, error) {
+	userID, err := consumeUserToken(getRedisKey(KeyPasswordResetPF + token))
+	if err == redis.Nil {
+		err = ErrPasswordResetTokenInvalid
+	}
+	return userID, err
+}
+
+// SetReactivateToken 保存恢复账号token对应的用户
+func SetReactivateToken(token string, userID int64, expiration time.Duration) error {
+	return client.Set(getRedisKey(KeyReactivatePF+token), userID, expiration).Err()
+}
+
+// ConsumeReactivateToken 取出恢复账号token对应的用户并删除token
+func ConsumeReactivateToken(token string) (int64, error) {
+	userID, err := consumeUserToken(getRedisKey(KeyReactivatePF + token))
+	if err == redis.Nil {
+		err = ErrReactivateTokenInvalid
+	}
+	return userID, err
+}
+
+// SetDeactivated 记录或移除已注销的用户，注销后已经签发的access token也不能再使用
+func SetDeactivated(userID int64, deactivated bool) error {
+	key := getRedisKey(KeyUserDeactivatedSet)
+	if deactivated {
+		return client.SAdd(key, userID).Err()
+	}
+	return client.SRem(key, userID).Err()
+}
+
+func IsDeactivated(userID int64) (bool, error) {
+	return client.SIsMember(getRedisKey(KeyUserDeactivatedSet), userID).Result()
+}
+
+// SetBanned 记录或移除被封禁的用户，封禁后已经签发的access token也不能再使用
+func SetBanned(userID int64, banned bool) error {
+	key := getRedisKey(KeyUserBannedSet)
+	if banned {
+		return client.SAdd(key, userID).Err()
+	}
+	return client.SRem(key, userID).Err()
+}
+
+func IsBanned(userID int64) (bool, error) {
+	return client.SIsMember(getRedisKey(KeyUserBannedSet), userID).Result()
+}
+
+// SetVerifyEmailToken 保存邮箱验证token对应的用户
+func SetVerifyEmailToken(token string, userID int64, expiration time.Duration) error {
+	return client.Set(getRedisKey(KeyVerifyEmailPF+token), userID, expiration).Err()
+}
+
+// ConsumeVerifyEmailToken 取出邮箱验证token对应的用户并删除token
+func ConsumeVerifyEmailToken(token string) (int64, error) {
+	userID, err := consumeUserToken(getRedisKey(KeyVerifyEmailPF + token))
+	if err == redis.Nil {
+		err = ErrVerifyTokenInvalid
+	}
+	return userID, err
+}
+
+// AllowVerifyResend 同一个邮箱在interval内只允许重发一次验证邮件
+func AllowVerifyResend(email string, interval time.Duration) (bool, error) {
+	return client.SetNX(getRedisKey(KeyVerifyResendPF+email), 1, interval).Result()
+}
+
+// consumeUserToken 在一个事务中读取并删除token，token不存在时返回redis.Nil
+func consumeUserToken(key string) (userID int64, err error) {
+	pipeline := client.TxPipeline()
+	get := pipeline.Get(key)
+	pipeline.Del(key)
+	if _, err = pipeline.Exec(); err != nil && err != redis.Nil {
+		return
+	}
+	return get.Int64()
+}
+
+// SetOAuthState 保存第三方登录跳转时生成的state
+func SetOAuthState(state string, expiration time.Duration) error {
+	return client.Set(getRedisKey(KeyOAuthStatePF+state), 1, expiration).Err()
+}
+
+// ConsumeOAuthState 校验并删除state，每个state只能使用一次
+func ConsumeOAuthState(state string) (bool, error) {
+	n, err := client.Del(getRedisKey(KeyOAuthStatePF + state)).Result()
+	return n == 1, err
+}
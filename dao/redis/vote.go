@@ -2,7 +2,7 @@ package redis
 
 import (
 	"errors"
-	"math"
+	"fmt"
 	"strconv"
 	"time"
 
@@ -12,8 +12,7 @@ import (
 )
 
 const (
-	scorePerVote     float64 = 432 // 每一票值多少分
-	oneWeekInSeconds         = 7 * 24 * 3600 * 2
+	scorePerVote float64 = 432 // 每一票值多少分
 )
 
 var (
@@ -21,7 +20,8 @@ var (
 	ErrVoteRepeated   = errors.New("Repeated vote. ")
 )
 
-func CreatePost(postID, communityID int64) (err error) {
+// CreatePost 把新帖子加入各个列表，authorID为0时不加入作者的帖子列表，匿名帖子不出现在用户主页和关注动态中
+func CreatePost(postID int64, communityIDs []int64, authorID int64, tags []string) (err error) {
 	pipeline := client.TxPipeline()
 	// 帖子时间
 	pipeline.ZAdd(getRedisKey(KeyPostTimeZSet), redis.Z{
@@ -34,49 +34,333 @@ func CreatePost(postID, communityID int64) (err error) {
 		Score:  float64(time.Now().Unix()),
 		Member: postID,
 	})
-	//// 更新：把帖子id加到社区的set
-	cKey := getRedisKey(KeyCommunitySetPF + strconv.Itoa(int(communityID)))
-	pipeline.SAdd(cKey, postID)
+	// 分数定期写回mysql
+	pipeline.SAdd(getRedisKey(KeyPostScoreDirtySet), postID)
+
+	// 热度分数，新帖子还没有投票
+	pipeline.ZAdd(getRedisKey(KeyPostHotZSet), redis.Z{
+		Score:  0,
+		Member: postID,
+	})
+	//// 更新：把帖子id加到社区的set，同时发布到多个社区时每个社区都加入
+	for _, communityID := range communityIDs {
+		pipeline.SAdd(getCommunitySetKey(communityID), postID)
+		addCommunityFeed(pipeline, communityID, postID)
+	}
+	// 作者发布的帖子，用于关注动态
+	if authorID != 0 {
+		pipeline.ZAdd(getRedisKey(KeyUserPostZSetPF+strconv.FormatInt(authorID, 10)), redis.Z{
+			Score:  feedScore(postID),
+			Member: postID,
+		})
+	}
+	// 帖子的标签，用于按标签浏览
+	for _, tag := range tags {
+		pipeline.ZAdd(getRedisKey(KeyTagPostZSetPF+tag), redis.Z{
+			Score:  float64(time.Now().Unix()),
+			Member: postID,
+		})
+	}
 	_, err = pipeline.Exec()
 	return err
 }
 
-func VoteForPost(userID, postID string, value float64) error {
+// VoteScoreDelta 用户的投票从oldValue改为newValue时帖子分数的变化
+// 投票值为1(赞成)、0(未投票)、-1(反对)，例如由赞成改为反对时分数减少2票
+func VoteScoreDelta(oldValue, newValue float64) float64 {
+	return (newValue - oldValue) * scorePerVote
+}
+
+// WeightedVoteScoreDelta 同VoteScoreDelta，每一票按投票时的权重计算，oldWeight为之前那一票的权重
+func WeightedVoteScoreDelta(oldValue, oldWeight, newValue, newWeight float64) float64 {
+	return (newValue*newWeight - oldValue*oldWeight) * scorePerVote
+}
+
+// VoteWeight 用户的票计入排序分数时的权重，声望从0到fullKarma时权重从minWeight线性增加到1，
+// 注册时间不满newAccountAge的账号按minWeight计算
+func VoteWeight(karma int64, age time.Duration, minWeight float64, fullKarma int64, newAccountAge time.Duration) float64 {
+	if minWeight >= 1 {
+		return 1
+	}
+	if minWeight < 0 {
+		minWeight = 0
+	}
+	if age < newAccountAge || karma <= 0 {
+		return minWeight
+	}
+	if karma >= fullKarma {
+		return 1
+	}
+	return minWeight + (1-minWeight)*float64(karma)/float64(fullKarma)
+}
+
+// voteMaxRetries 并发投票导致事务失败时的重试次数
+const voteMaxRetries = 3
+
+// VoteForPost 帖子发布超过voteWindow之后不允许再投票，返回用户之前的投票值
+// 读取旧的投票和更新分数放在WATCH事务中，同一用户并发投票时不会重复计分
+// weight 这一票计入排序分数的权重，改票或取消时按之前记录的权重扣除，赞成和反对票数不受权重影响
+// oldWeight 之前那一票的权重，撤销投票时按这个权重恢复
+func VoteForPost(userID, postID string, value, weight float64, voteWindow time.Duration) (oldValue, oldWeight float64, err error) {
 	// 1. 判断投票限制
 	// 去redis取帖子发布时间
-	postTime := client.ZScore(getRedisKey(KeyPostTimeZSet), postID).Val()
+	postTime, err := client.ZScore(getRedisKey(KeyPostTimeZSet), postID).Result()
+	if err != nil && err != redis.Nil {
+		// redis不可用时不要当成超过了投票时间
+		return 0, 0, err
+	}
 	zap.L().Debug("postTime: ", zap.Any("posttime", postTime))
-	if float64(time.Now().Unix())-postTime > oneWeekInSeconds {
-		return ErrVoteTimeExpire
+	if float64(time.Now().Unix())-postTime > voteWindow.Seconds() {
+		return 0, 0, ErrVoteTimeExpire
+	}
+	if err = initPostVoteCount(postID); err != nil {
+		return 0, 0, err
+	}
+	votedKey := getRedisKey(KeyPostVotedZSetPF + postID)
+	countKey := getRedisKey(KeyPostVoteCountPF + postID)
+	weightKey := getRedisKey(KeyPostVoteWeightPF + postID)
+	now := float64(time.Now().UnixNano() / int64(time.Millisecond))
+	oldValue, err = vote(votedKey, userID, value, func(tx *redis.Tx, pipe redis.Pipeliner, ov float64) error {
+		// 之前那一票的权重，没有记录时是加权之前的投票，按1计算
+		ow, err := tx.HGet(weightKey, userID).Float64()
+		if err == redis.Nil {
+			ow, err = 1, nil
+		}
+		if err != nil {
+			return err
+		}
+		oldWeight = ow
+		// 更新贴子的分数
+		pipe.ZIncrBy(getRedisKey(KeyPostScoreZSet), WeightedVoteScoreDelta(ov, ow, value, weight), postID)
+		pipe.SAdd(getRedisKey(KeyPostScoreDirtySet), postID)
+		if value == 0 {
+			pipe.HDel(weightKey, userID)
+		} else {
+			pipe.HSet(weightKey, userID, weight)
+		}
+		// 分别更新赞成和反对票数
+		if field := voteCountField(ov); field != "" {
+			pipe.HIncrBy(countKey, field, -1)
+		}
+		if field := voteCountField(value); field != "" {
+			pipe.HIncrBy(countKey, field, 1)
+		}
+		// 用户自己的投票记录
+		if dir := voteCountField(ov); dir != "" {
+			pipe.ZRem(getUserVotedKey(userID, dir), postID)
+		}
+		if dir := voteCountField(value); dir != "" {
+			pipe.ZAdd(getUserVotedKey(userID, dir), redis.Z{Score: now, Member: postID})
+		}
+		return nil
+	})
+	return oldValue, oldWeight, err
+}
+
+// getUserVotedKey dir为up或down
+func getUserVotedKey(userID, dir string) string {
+	return getRedisKey(KeyUserVotedZSetPF + userID + ":" + dir)
+}
+
+// GetUserVotedPosts 用户赞成或反对过的帖子id，最近投票的在前，dir为up或down
+// 记录可能落后于帖子的投票记录(例如合并账号之后)，调用方需要用GetUserPostVotes确认
+func GetUserVotedPosts(userID int64, dir string, page, size int64) (ids []string, total int64, err error) {
+	key := getUserVotedKey(strconv.FormatInt(userID, 10), dir)
+	pipeline := client.Pipeline()
+	count := pipeline.ZCard(key)
+	start := (page - 1) * size
+	list := pipeline.ZRevRange(key, start, start+size-1)
+	if _, err = pipeline.Exec(); err != nil {
+		return nil, 0, err
 	}
-	// 2和3需要放到一个pipeline事务中操作
+	return list.Val(), count.Val(), nil
+}
 
-	// 2. 更新贴子的分数
-	// 先查当前用户给当前帖子的投票记录
-	ov := client.ZScore(getRedisKey(KeyPostVotedZSetPF+postID), userID).Val()
-	// 更新：如果这一次投票的值和之前保存的值一致，就提示不允许重复投票
-	if value == ov {
-		return ErrVoteRepeated
+// BackfillUserVotes 按帖子的投票记录补充用户的投票记录，投票时间未知时使用帖子的发布时间，
+// 只在第一次调用时执行，返回是否执行了补充
+func BackfillUserVotes() (bool, error) {
+	done, err := client.Exists(getRedisKey(KeyUserVotedBackfilled)).Result()
+	if err != nil || done > 0 {
+		return false, err
 	}
-	var op float64
-	if value > ov {
-		op = 1
-	} else {
-		op = -1
+	err = scanKeys(KeyPostVotedZSetPF, func(pid string) error {
+		pipeline := client.Pipeline()
+		votes := pipeline.ZRangeWithScores(getRedisKey(KeyPostVotedZSetPF+pid), 0, -1)
+		postTime := pipeline.ZScore(getRedisKey(KeyPostTimeZSet), pid)
+		if _, err := pipeline.Exec(); err != nil && err != redis.Nil {
+			return err
+		}
+		score := postTime.Val() * 1000
+		pipeline = client.Pipeline()
+		for _, z := range votes.Val() {
+			if dir := voteCountField(z.Score); dir != "" {
+				pipeline.ZAddNX(getUserVotedKey(z.Member.(string), dir), redis.Z{Score: score, Member: pid})
+			}
+		}
+		_, err := pipeline.Exec()
+		return err
+	})
+	if err != nil {
+		return false, err
 	}
-	diff := math.Abs(ov - value) // 计算两次投票的差值
-	pipeline := client.TxPipeline()
-	pipeline.ZIncrBy(getRedisKey(KeyPostScoreZSet), op*diff*scorePerVote, postID)
-
-	// 3. 记录用户为该贴子投票的数据
-	if value == 0 {
-		pipeline.ZRem(getRedisKey(KeyPostVotedZSetPF+postID), userID)
-	} else {
-		pipeline.ZAdd(getRedisKey(KeyPostVotedZSetPF+postID), redis.Z{
-			Score:  value, // 赞成票还是反对票
-			Member: userID,
-		})
+	return true, client.Set(getRedisKey(KeyUserVotedBackfilled), 1, 0).Err()
+}
+
+func voteCountField(value float64) string {
+	switch {
+	case value > 0:
+		return "up"
+	case value < 0:
+		return "down"
 	}
-	_, err := pipeline.Exec()
+	return ""
+}
+
+// initPostVoteCount 之前投过票但还没有分开计数的帖子先按投票记录计算票数
+func initPostVoteCount(postID string) error {
+	return initVoteCount(getRedisKey(KeyPostVotedZSetPF+postID), getRedisKey(KeyPostVoteCountPF+postID))
+}
+
+// initVoteCount 没有分开计数时按votedKey中的投票记录计算赞成和反对票数，帖子和评论共用
+func initVoteCount(votedKey, countKey string) error {
+	exists, err := client.Exists(countKey).Result()
+	if err != nil || exists > 0 {
+		return err
+	}
+	up, err := client.ZCount(votedKey, "1", "1").Result()
+	if err != nil {
+		return err
+	}
+	down, err := client.ZCount(votedKey, "-1", "-1").Result()
+	if err != nil {
+		return err
+	}
+	pipeline := client.Pipeline()
+	pipeline.HSetNX(countKey, "up", up)
+	pipeline.HSetNX(countKey, "down", down)
+	_, err = pipeline.Exec()
 	return err
 }
+
+// GetPostVoteCount 帖子的赞成和反对票数
+func GetPostVoteCount(postID string) (up, down int64, err error) {
+	key := getRedisKey(KeyPostVoteCountPF + postID)
+	vals, err := client.HMGet(key, "up", "down").Result()
+	if err == nil {
+		up, _ = strconv.ParseInt(fmt.Sprint(vals[0]), 10, 64)
+		down, _ = strconv.ParseInt(fmt.Sprint(vals[1]), 10, 64)
+	}
+	v, err := cached(key, [2]int64{up, down}, err)
+	if err != nil {
+		return 0, 0, err
+	}
+	counts := v.([2]int64)
+	return counts[0], counts[1], nil
+}
+
+// GetPostVoters 帖子的赞成和反对用户
+func GetPostVoters(postID string) (up, down []int64, err error) {
+	votes, err := client.ZRangeWithScores(getRedisKey(KeyPostVotedZSetPF+postID), 0, -1).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, z := range votes {
+		uid, err := strconv.ParseInt(z.Member.(string), 10, 64)
+		if err != nil {
+			continue
+		}
+		if z.Score > 0 {
+			up = append(up, uid)
+		} else if z.Score < 0 {
+			down = append(down, uid)
+		}
+	}
+	return up, down, nil
+}
+
+// VoteForComment 给评论投票，返回用户之前的投票值，同时更新评论的得分和赞成、反对票数
+func VoteForComment(userID, commentID string, value float64) (oldValue float64, err error) {
+	if err = initCommentScore(commentID); err != nil {
+		return 0, err
+	}
+	votedKey := getRedisKey(KeyCommentVotedPF + commentID)
+	countKey := getRedisKey(KeyCommentUpDownPF + commentID)
+	if err = initVoteCount(votedKey, countKey); err != nil {
+		return 0, err
+	}
+	return vote(votedKey, userID, value, func(_ *redis.Tx, pipe redis.Pipeliner, ov float64) error {
+		pipe.HIncrBy(getRedisKey(KeyCommentScoreHash), commentID, int64(value-ov))
+		// 分别更新赞成和反对票数，用于评论的排序
+		if field := voteCountField(ov); field != "" {
+			pipe.HIncrBy(countKey, field, -1)
+		}
+		if field := voteCountField(value); field != "" {
+			pipe.HIncrBy(countKey, field, 1)
+		}
+		return nil
+	})
+}
+
+// initCommentScore 之前投过票但还没有得分的评论先按投票记录计算得分，之后的投票在此基础上累加
+func initCommentScore(commentID string) error {
+	scoreKey := getRedisKey(KeyCommentScoreHash)
+	exists, err := client.HExists(scoreKey, commentID).Result()
+	if err != nil || exists {
+		return err
+	}
+	votedKey := getRedisKey(KeyCommentVotedPF + commentID)
+	up, err := client.ZCount(votedKey, "1", "1").Result()
+	if err != nil {
+		return err
+	}
+	down, err := client.ZCount(votedKey, "-1", "-1").Result()
+	if err != nil {
+		return err
+	}
+	return client.HSetNX(scoreKey, commentID, up-down).Err()
+}
+
+// vote 在WATCH事务中更新用户的投票记录，onChange用于在同一个事务中更新其他的数据，需要读取时使用tx
+func vote(votedKey, userID string, value float64, onChange func(tx *redis.Tx, pipe redis.Pipeliner, oldValue float64) error) (oldValue float64, err error) {
+	txf := func(tx *redis.Tx) error {
+		// 先查当前用户的投票记录
+		// 更新：如果这一次投票的值和之前保存的值一致，就提示不允许重复投票
+		ov, err := tx.ZScore(votedKey, userID).Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if value == ov {
+			return ErrVoteRepeated
+		}
+		_, err = tx.TxPipelined(func(pipe redis.Pipeliner) error {
+			if onChange != nil {
+				if err := onChange(tx, pipe, ov); err != nil {
+					return err
+				}
+			}
+			// 记录用户的投票数据
+			if value == 0 {
+				pipe.ZRem(votedKey, userID)
+			} else {
+				pipe.ZAdd(votedKey, redis.Z{
+					Score:  value, // 赞成票还是反对票
+					Member: userID,
+				})
+			}
+			return nil
+		})
+		oldValue = ov
+		return err
+	}
+	// 事务不经过熔断器
+	if err = checkAvailable(); err != nil {
+		return 0, err
+	}
+	for i := 0; i < voteMaxRetries; i++ {
+		err = client.Watch(txf, votedKey)
+		if err != redis.TxFailedErr {
+			return oldValue, err
+		}
+	}
+	return 0, redis.TxFailedErr
+}
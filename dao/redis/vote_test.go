@@ -0,0 +1,242 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVoteScoreDelta(t *testing.T) {
+	tests := []struct {
+		oldValue, newValue float64
+		votes              float64 // 分数变化了多少票
+	}{
+		{0, 1, 1},
+		{0, -1, -1},
+		{1, 0, -1},
+		{-1, 0, 1},
+		{1, -1, -2},
+		{-1, 1, 2},
+		{0, 0, 0},
+		{1, 1, 0},
+		{-1, -1, 0},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.votes*scorePerVote, VoteScoreDelta(tt.oldValue, tt.newValue), "%v -> %v", tt.oldValue, tt.newValue)
+	}
+}
+
+func TestVoteForPostCounts(t *testing.T) {
+	useMiniredis(t)
+	require.NoError(t, client.ZAdd(getRedisKey(KeyPostTimeZSet), redis.Z{Score: float64(time.Now().Unix()), Member: "1"}).Err())
+	// 分开计数之前已有的投票按投票记录初始化
+	require.NoError(t, client.ZAdd(getRedisKey(KeyPostVotedZSetPF+"1"), redis.Z{Score: -1, Member: "9"}).Err())
+	vote := func(userID string, value float64) {
+		_, _, err := VoteForPost(userID, "1", value, 1, time.Hour)
+		require.NoError(t, err)
+	}
+	vote("10", 1)
+	vote("11", 1)
+	vote("12", -1)
+	vote("11", -1)
+	vote("10", 0)
+	up, down, err := GetPostVoteCount("1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), up)
+	assert.Equal(t, int64(3), down)
+
+	require.NoError(t, UpdatePostControversyScore("1", 5))
+	assert.Equal(t, redis.Nil, client.ZScore(getRedisKey(KeyPostControZSet), "1").Err())
+	vote("10", 1)
+	vote("13", 1)
+	require.NoError(t, UpdatePostControversyScore("1", 5))
+	assert.InDelta(t, ControversyScore(2, 3), client.ZScore(getRedisKey(KeyPostControZSet), "1").Val(), 1e-9)
+}
+
+func TestCommentVoteCounts(t *testing.T) {
+	useMiniredis(t)
+	// 分开计数之前已有的投票按投票记录计算
+	require.NoError(t, client.ZAdd(getRedisKey(KeyCommentVotedPF+"1"), redis.Z{Score: 1, Member: "9"}).Err())
+	require.NoError(t, client.ZAdd(getRedisKey(KeyCommentVotedPF+"2"), redis.Z{Score: -1, Member: "9"}).Err())
+	up, down, err := GetCommentVoteCounts([]string{"1", "2", "3"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 0, 0}, up)
+	assert.Equal(t, []int64{0, 1, 0}, down)
+
+	vote := func(userID string, value float64) {
+		_, err := VoteForComment(userID, "1", value)
+		require.NoError(t, err)
+	}
+	vote("10", 1)
+	vote("11", -1)
+	vote("12", 1)
+	vote("12", -1)
+	vote("10", 0)
+	up, down, err = GetCommentVoteCounts([]string{"1"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, up)
+	assert.Equal(t, []int64{2}, down)
+	assert.Equal(t, "2", client.HGet(getRedisKey(KeyCommentUpDownPF+"1"), "down").Val())
+}
+
+func TestVoteWeight(t *testing.T) {
+	const week = 7 * 24 * time.Hour
+	tests := []struct {
+		karma  int64
+		age    time.Duration
+		weight float64
+	}{
+		{-20, week, 0.25},
+		{0, week, 0.25},
+		{10, week, 0.325},
+		{50, week, 0.625},
+		{99, week, 0.9925},
+		{100, week, 1},
+		{5000, week, 1},
+		// 注册不满72小时的账号不论声望都按最低权重计算
+		{5000, time.Hour, 0.25},
+		{50, 71 * time.Hour, 0.25},
+		{50, 72 * time.Hour, 0.625},
+	}
+	for _, tt := range tests {
+		assert.InDelta(t, tt.weight, VoteWeight(tt.karma, tt.age, 0.25, 100, 72*time.Hour), 1e-9, "karma %d, age %s", tt.karma, tt.age)
+	}
+	assert.Equal(t, float64(1), VoteWeight(0, time.Hour, 1, 100, 72*time.Hour))
+	assert.Equal(t, float64(0), VoteWeight(0, week, -1, 100, 0))
+}
+
+func TestVoteForPostWeighted(t *testing.T) {
+	useMiniredis(t)
+	created := float64(time.Now().Unix())
+	require.NoError(t, client.ZAdd(getRedisKey(KeyPostTimeZSet), redis.Z{Score: created, Member: "1"}).Err())
+	require.NoError(t, client.ZAdd(getRedisKey(KeyPostScoreZSet), redis.Z{Score: created, Member: "1"}).Err())
+	// 加权之前的投票按1扣除
+	require.NoError(t, client.ZAdd(getRedisKey(KeyPostVotedZSetPF+"1"), redis.Z{Score: 1, Member: "9"}).Err())
+	require.NoError(t, client.ZIncrBy(getRedisKey(KeyPostScoreZSet), scorePerVote, "1").Err())
+	vote := func(userID string, value, weight float64) {
+		_, _, err := VoteForPost(userID, "1", value, weight, time.Hour)
+		require.NoError(t, err)
+	}
+	netVotes := func() float64 {
+		return (client.ZScore(getRedisKey(KeyPostScoreZSet), "1").Val() - created) / scorePerVote
+	}
+	vote("10", 1, 0.5)
+	vote("11", -1, 0.25)
+	assert.InDelta(t, 1.25, netVotes(), 1e-9)
+	// 改票时按之前记录的权重扣除，按新的权重计分
+	vote("10", -1, 1)
+	assert.InDelta(t, -0.25, netVotes(), 1e-9)
+	vote("9", 0, 0.25)
+	vote("10", 0, 0.25)
+	assert.InDelta(t, -0.25, netVotes(), 1e-9)
+	assert.Equal(t, []string{"11"}, client.HKeys(getRedisKey(KeyPostVoteWeightPF+"1")).Val())
+
+	// 显示的票数不受权重影响
+	up, down, err := GetPostVoteCount("1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), up)
+	assert.Equal(t, int64(1), down)
+}
+
+func TestGetPostVoters(t *testing.T) {
+	useMiniredis(t)
+	require.NoError(t, client.ZAdd(getRedisKey(KeyPostVotedZSetPF+"1"),
+		redis.Z{Score: 1, Member: "10"}, redis.Z{Score: -1, Member: "11"}, redis.Z{Score: 1, Member: "12"}).Err())
+	up, down, err := GetPostVoters("1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{10, 12}, up)
+	assert.Equal(t, []int64{11}, down)
+
+	require.NoError(t, client.ZAdd(getRedisKey(KeyUserKarmaZSet), redis.Z{Score: 42, Member: "10"}).Err())
+	karma, err := GetKarmas([]int64{10, 11})
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]int64{10: 42, 11: 0}, karma)
+}
+
+func TestUserVotedPosts(t *testing.T) {
+	useMiniredis(t)
+	for _, pid := range []string{"1", "2", "3"} {
+		require.NoError(t, client.ZAdd(getRedisKey(KeyPostTimeZSet), redis.Z{Score: float64(time.Now().Unix()), Member: pid}).Err())
+	}
+	vote := func(postID string, value float64) {
+		_, _, err := VoteForPost("10", postID, value, 1, time.Hour)
+		require.NoError(t, err)
+	}
+	vote("1", 1)
+	vote("2", 1)
+	vote("3", -1)
+	vote("2", -1)
+	ids, total, err := GetUserVotedPosts(10, "up", 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1"}, ids)
+	assert.Equal(t, int64(1), total)
+	ids, total, err = GetUserVotedPosts(10, "down", 1, 1)
+	require.NoError(t, err)
+	assert.Len(t, ids, 1)
+	assert.Equal(t, int64(2), total)
+	vote("1", 0)
+	_, total, err = GetUserVotedPosts(10, "up", 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), total)
+}
+
+func TestBackfillUserVotes(t *testing.T) {
+	useMiniredis(t)
+	require.NoError(t, client.ZAdd(getRedisKey(KeyPostTimeZSet), redis.Z{Score: 100, Member: "1"}).Err())
+	require.NoError(t, client.ZAdd(getRedisKey(KeyPostVotedZSetPF+"1"),
+		redis.Z{Score: 1, Member: "10"}, redis.Z{Score: -1, Member: "11"}).Err())
+	done, err := BackfillUserVotes()
+	require.NoError(t, err)
+	assert.True(t, done)
+	assert.Equal(t, float64(100000), client.ZScore(getUserVotedKey("10", "up"), "1").Val())
+	ids, _, err := GetUserVotedPosts(11, "down", 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1"}, ids)
+
+	// 只执行一次
+	done, err = BackfillUserVotes()
+	require.NoError(t, err)
+	assert.False(t, done)
+}
+
+func TestCommentScores(t *testing.T) {
+	useMiniredis(t)
+	// 还没有得分的评论按已有的投票记录计算，之后的投票在此基础上累加
+	require.NoError(t, client.ZAdd(getRedisKey(KeyCommentVotedPF+"1"),
+		redis.Z{Score: -1, Member: "8"}, redis.Z{Score: -1, Member: "9"}).Err())
+	scores, err := GetCommentScores([]string{"1", "2"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{-2, 0}, scores)
+
+	vote := func(userID, commentID string, value float64) {
+		_, err := VoteForComment(userID, commentID, value)
+		require.NoError(t, err)
+	}
+	vote("10", "1", -1)
+	vote("9", "1", 1)
+	vote("10", "2", 1)
+	vote("11", "2", 1)
+	vote("11", "2", 0)
+	scores, err = GetCommentScores([]string{"1", "2"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{-1, 1}, scores)
+	score, err := client.HGet(getRedisKey(KeyCommentScoreHash), "1").Int64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(-1), score)
+
+	scores, err = GetCommentScores(nil)
+	require.NoError(t, err)
+	assert.Empty(t, scores)
+}
+
+func TestCommentScoresRedisDown(t *testing.T) {
+	mr := useMiniredis(t)
+	mr.Close()
+	_, err := GetCommentScores([]string{"1"})
+	assert.Error(t, err)
+	_, err = VoteForComment("10", "1", 1)
+	assert.Error(t, err)
+}
@@ -0,0 +1,45 @@
+package redis
+
+import (
+	"encoding/json"
+	"errors"
+	"go-web-app/models"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+var ErrVoteUndoExpired = errors.New("Vote can no longer be undone. ")
+
+// getVoteUndoKey target为post:帖子id或comment:评论id
+func getVoteUndoKey(target string, userID int64) string {
+	return getRedisKey(KeyVoteUndoPF + target + ":" + strconv.FormatInt(userID, 10))
+}
+
+// SetVoteUndo 保存用户最近一次投票之前的状态，window之后不能再撤销，同一个目标再次投票时覆盖
+func SetVoteUndo(target string, userID int64, undo *models.VoteUndo, window time.Duration) error {
+	b, err := json.Marshal(undo)
+	if err != nil {
+		return err
+	}
+	return client.Set(getVoteUndoKey(target, userID), b, window).Err()
+}
+
+// TakeVoteUndo 取出并删除撤销的状态，同一次投票只能撤销一次，已经过期或者没有投票时返回ErrVoteUndoExpired
+func TakeVoteUndo(target string, userID int64) (*models.VoteUndo, error) {
+	key := getVoteUndoKey(target, userID)
+	pipeline := client.TxPipeline()
+	get := pipeline.Get(key)
+	pipeline.Del(key)
+	if _, err := pipeline.Exec(); err == redis.Nil {
+		return nil, ErrVoteUndoExpired
+	} else if err != nil {
+		return nil, err
+	}
+	undo := new(models.VoteUndo)
+	if err := json.Unmarshal([]byte(get.Val()), undo); err != nil {
+		return nil, err
+	}
+	return undo, nil
+}
@@ -0,0 +1,54 @@
+package redis
+
+import (
+	"go-web-app/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVoteUndo(t *testing.T) {
+	mr := useMiniredis(t)
+	undo := &models.VoteUndo{OldValue: -1, OldWeight: 0.5, NewValue: 1, KarmaWeight: 2}
+	require.NoError(t, SetVoteUndo("post:1", 10, undo, 5*time.Second))
+
+	// 其他用户和其他目标不受影响
+	_, err := TakeVoteUndo("post:1", 20)
+	assert.Equal(t, ErrVoteUndoExpired, err)
+	_, err = TakeVoteUndo("comment:1", 10)
+	assert.Equal(t, ErrVoteUndoExpired, err)
+
+	got, err := TakeVoteUndo("post:1", 10)
+	require.NoError(t, err)
+	assert.Equal(t, undo, got)
+	// 只能撤销一次
+	_, err = TakeVoteUndo("post:1", 10)
+	assert.Equal(t, ErrVoteUndoExpired, err)
+
+	require.NoError(t, SetVoteUndo("post:1", 10, undo, 5*time.Second))
+	mr.FastForward(6 * time.Second)
+	_, err = TakeVoteUndo("post:1", 10)
+	assert.Equal(t, ErrVoteUndoExpired, err)
+}
+
+func TestUndoPostVoteRestoresScore(t *testing.T) {
+	mr := useMiniredis(t)
+	mr.ZAdd(getRedisKey(KeyPostTimeZSet), float64(time.Now().Unix()), "1")
+	mr.ZAdd(getRedisKey(KeyPostScoreZSet), 100, "1")
+	_, _, err := VoteForPost("10", "1", -1, 0.5, time.Hour)
+	require.NoError(t, err)
+	before, _ := mr.ZScore(getRedisKey(KeyPostScoreZSet), "1")
+
+	oldValue, oldWeight, err := VoteForPost("10", "1", 1, 1, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, -1.0, oldValue)
+	assert.Equal(t, 0.5, oldWeight)
+
+	// 按之前的值和权重重新投票，分数回到改票之前
+	_, _, err = VoteForPost("10", "1", oldValue, oldWeight, time.Hour)
+	require.NoError(t, err)
+	after, _ := mr.ZScore(getRedisKey(KeyPostScoreZSet), "1")
+	assert.InDelta(t, before, after, 1e-9)
+}
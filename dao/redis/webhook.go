@@ -0,0 +1,69 @@
+package redis
+
+import (
+	"encoding/json"
+	"go-web-app/models"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// webhookDeadMax 死信列表最多保留多少个通知
+const webhookDeadMax = 1000
+
+// webhookPromoteBatch 每次最多把多少个到时间的重试通知放回队列
+const webhookPromoteBatch = 100
+
+// EnqueueWebhook 把通知加入队列的左边
+func EnqueueWebhook(job *models.WebhookJob) error {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return client.LPush(getRedisKey(KeyWebhookQueue), b).Err()
+}
+
+// PopWebhook 从队列的右边取出最早加入的通知，队列为空时返回nil
+func PopWebhook() (*models.WebhookJob, error) {
+	b, err := client.RPop(getRedisKey(KeyWebhookQueue)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	job := new(models.WebhookJob)
+	if err = json.Unmarshal(b, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// RetryWebhook 通知在at之后重新加入队列
+func RetryWebhook(job *models.WebhookJob, at time.Time) error {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return client.ZAdd(getRedisKey(KeyWebhookRetryZSet), redis.Z{Score: float64(at.Unix()), Member: b}).Err()
+}
+
+// PromoteDueWebhooks 把到了重试时间的通知放回队列，返回移动的数量
+func PromoteDueWebhooks(now time.Time) (int, error) {
+	keys := []string{getRedisKey(KeyWebhookRetryZSet), getRedisKey(KeyWebhookQueue)}
+	return promoteDueScript.Run(client, keys, now.Unix(), webhookPromoteBatch).Int()
+}
+
+// DeadLetterWebhook 不再重试的通知放入死信列表，只保留最近的webhookDeadMax个
+func DeadLetterWebhook(job *models.WebhookJob) error {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	key := getRedisKey(KeyWebhookDeadList)
+	pipeline := client.TxPipeline()
+	pipeline.LPush(key, b)
+	pipeline.LTrim(key, 0, webhookDeadMax-1)
+	_, err = pipeline.Exec()
+	return err
+}
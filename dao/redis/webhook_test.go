@@ -0,0 +1,39 @@
+package redis
+
+import (
+	"go-web-app/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookQueue(t *testing.T) {
+	useMiniredis(t)
+	require.NoError(t, EnqueueWebhook(&models.WebhookJob{ID: "1", WebhookID: 10, Payload: []byte(`{"event":"ping"}`)}))
+	require.NoError(t, EnqueueWebhook(&models.WebhookJob{ID: "2", WebhookID: 10}))
+	job, err := PopWebhook()
+	require.NoError(t, err)
+	assert.Equal(t, "1", job.ID)
+	assert.JSONEq(t, `{"event":"ping"}`, string(job.Payload))
+
+	// 邮件和webhook的重试队列互不影响
+	now := time.Now()
+	require.NoError(t, RetryEmail(&models.EmailJob{ID: "e"}, now))
+	job.Attempts = 1
+	require.NoError(t, RetryWebhook(job, now))
+	n, err := PromoteDueWebhooks(now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, int64(1), client.ZCard(getRedisKey(KeyEmailRetryZSet)).Val())
+
+	job, _ = PopWebhook()
+	assert.Equal(t, "2", job.ID)
+	job, _ = PopWebhook()
+	assert.Equal(t, "1", job.ID)
+	assert.Equal(t, 1, job.Attempts)
+	job, err = PopWebhook()
+	require.NoError(t, err)
+	assert.Nil(t, job)
+}
@@ -1,12 +1,15 @@
 package logger
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"go-web-app/pkg/snowflake"
 	"go-web-app/settings"
-	"net"
-	"net/http"
-	"net/http/httputil"
+	"io"
+	"io/ioutil"
 	"os"
-	"runtime/debug"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,116 +21,229 @@ import (
 
 //var lg *zap.Logger
 
+// accessLogger 单独记录访问日志，级别可以和全局日志分开配置
+var (
+	accessLogger = zap.NewNop()
+	// 日志级别，配置文件修改后可以在运行时调整
+	level       = zap.NewAtomicLevel()
+	accessLevel = zap.NewAtomicLevel()
+)
+
+const (
+	encodingJSON    = "json"
+	encodingConsole = "console"
+)
+
+const (
+	HeaderRequestID     = "X-Request-ID"
+	ContextRequestIDKey = "requestID"
+)
+
+type requestIDKey struct{}
+
 // init Logger
 //func Init(cfg *config.LogConfig) (err error) {
 func Init(cfg *settings.LogConfig, mode string) (err error) {
-	writeSyncer := getLogWriter(cfg.Filename, cfg.MaxSize, cfg.MaxBackups, cfg.MaxAge)
-	encoder := getEncoder()
-	var l = new(zapcore.Level)
-	err = l.UnmarshalText([]byte(cfg.Level))
+	writeSyncer := zapcore.AddSync(newRotateLogger(cfg.Filename, cfg))
+	// 没有单独配置访问日志的文件时和应用日志共用一个，避免两个lumberjack同时轮转同一个文件
+	accessSyncer := writeSyncer
+	if cfg.AccessFilename != "" && cfg.AccessFilename != cfg.Filename {
+		accessSyncer = zapcore.AddSync(newRotateLogger(cfg.AccessFilename, cfg))
+	}
+	encoder, err := getEncoder(logEncoding(cfg.Encoding, mode))
 	if err != nil {
 		return
 	}
-	var core zapcore.Core
+	if err = setLevels(cfg); err != nil {
+		return
+	}
+	var opts []zap.Option
+	if cfg.Caller {
+		opts = append(opts, zap.AddCaller())
+	}
+	if cfg.StacktraceLevel != "" {
+		var sl zapcore.Level
+		if err = sl.UnmarshalText([]byte(cfg.StacktraceLevel)); err != nil {
+			return
+		}
+		opts = append(opts, zap.AddStacktrace(sl))
+	}
+	var core, accessCore zapcore.Core
 	if mode == "dev" {
 		consoleEncoder := zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
 		core = zapcore.NewTee(
-			zapcore.NewCore(encoder, writeSyncer, l),
+			zapcore.NewCore(encoder, writeSyncer, level),
 			zapcore.NewCore(consoleEncoder, zapcore.Lock(os.Stdout), zapcore.DebugLevel),
 		)
+		accessCore = zapcore.NewTee(
+			zapcore.NewCore(encoder, accessSyncer, accessLevel),
+			zapcore.NewCore(consoleEncoder, zapcore.Lock(os.Stdout), accessLevel),
+		)
 	} else {
-		core = zapcore.NewCore(encoder, writeSyncer, l)
+		core = zapcore.NewCore(encoder, writeSyncer, level)
+		accessCore = zapcore.NewCore(encoder, accessSyncer, accessLevel)
 	}
-	lg := zap.New(core, zap.AddCaller())
+	lg := zap.New(core, opts...)
 	zap.ReplaceGlobals(lg) // 替换zap包中全局的logger实例，后续在其他包中只需使用zap.L()调用即可
+	accessLogger = zap.New(accessCore).Named("access")
+
+	// 配置文件修改后更新日志级别
+	settings.OnReload(func(conf *settings.AppConfig) {
+		if err := setLevels(conf.LogConfig); err != nil {
+			zap.L().Error("reload log level failed", zap.Error(err))
+			return
+		}
+		zap.L().Info("log level reloaded",
+			zap.String("level", level.String()),
+			zap.String("access_level", accessLevel.String()))
+	})
 	return
 }
 
-func getEncoder() zapcore.Encoder {
+// setLevels 解析配置中的日志级别，两个级别都合法时才生效
+func setLevels(cfg *settings.LogConfig) error {
+	if cfg == nil {
+		return nil
+	}
+	var l, al zapcore.Level
+	if err := l.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return err
+	}
+	if err := al.UnmarshalText([]byte(cfg.AccessLevel)); err != nil {
+		return err
+	}
+	level.SetLevel(l)
+	accessLevel.SetLevel(al)
+	return nil
+}
+
+// WithRequestID 把请求id放进context，供下游的函数使用
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID 从context中取出请求id，没有时返回空字符串
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// L 返回带有请求id字段的logger，便于把同一个请求的日志串起来
+func L(ctx context.Context) *zap.Logger {
+	if id := RequestID(ctx); id != "" {
+		return zap.L().With(zap.String("request_id", id))
+	}
+	return zap.L()
+}
+
+// logEncoding 配置中没有指定时，release模式使用json便于日志采集，其他模式使用console便于阅读
+func logEncoding(encoding, mode string) string {
+	if encoding != "" {
+		return encoding
+	}
+	if mode == gin.ReleaseMode {
+		return encodingJSON
+	}
+	return encodingConsole
+}
+
+func getEncoder(encoding string) (zapcore.Encoder, error) {
 	encoderConfig := zap.NewProductionEncoderConfig()
 	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 	encoderConfig.TimeKey = "time"
 	encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
 	encoderConfig.EncodeDuration = zapcore.SecondsDurationEncoder
 	encoderConfig.EncodeCaller = zapcore.ShortCallerEncoder
-	return zapcore.NewJSONEncoder(encoderConfig)
+	switch encoding {
+	case encodingJSON:
+		return zapcore.NewJSONEncoder(encoderConfig), nil
+	case encodingConsole:
+		return zapcore.NewConsoleEncoder(encoderConfig), nil
+	}
+	return nil, fmt.Errorf("unknown log encoding %q", encoding)
 }
 
-func getLogWriter(filename string, maxSize, maxBackup, maxAge int) zapcore.WriteSyncer {
-	lumberJackLogger := &lumberjack.Logger{
+// newRotateLogger 按配置中的大小、个数和天数轮转filename
+func newRotateLogger(filename string, cfg *settings.LogConfig) *lumberjack.Logger {
+	return &lumberjack.Logger{
 		Filename:   filename,
-		MaxSize:    maxSize,
-		MaxBackups: maxBackup,
-		MaxAge:     maxAge,
+		MaxSize:    cfg.MaxSize,
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     cfg.MaxAge,
+		Compress:   cfg.Compress,
 	}
-	return zapcore.AddSync(lumberJackLogger)
 }
 
-// GinLogger 接收gin框架默认的日志
+// GinLogger 接收gin框架默认的日志，并为每个请求分配请求id
+// 请求id会写入响应头X-Request-ID，并放进请求的context中
+// 查询参数和请求体中的敏感字段按log.redact_fields遮盖，Authorization头只记录认证方式
 func GinLogger() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
 		path := c.Request.URL.Path
-		query := c.Request.URL.RawQuery
+		cfg := settings.Current().LogConfig
+		r := newRedactor(nil)
+		if cfg != nil {
+			r = newRedactor(cfg.RedactFields)
+		}
+		query := r.Query(c.Request.URL.RawQuery)
+		var body string
+		if cfg != nil && cfg.LogBody {
+			body = readBody(c, r, cfg.MaxBodyLog)
+		}
+
+		requestID := strconv.FormatInt(snowflake.GenID(), 10)
+		c.Set(ContextRequestIDKey, requestID)
+		c.Request = c.Request.WithContext(WithRequestID(c.Request.Context(), requestID))
+		c.Header(HeaderRequestID, requestID)
+
 		c.Next()
 
 		cost := time.Since(start)
-		zap.L().Info(path,
+		accessLogger.Info(path,
+			zap.String("request_id", requestID),
 			zap.Int("status", c.Writer.Status()),
 			zap.String("method", c.Request.Method),
 			zap.String("path", path),
 			zap.String("query", query),
 			zap.String("ip", c.ClientIP()),
 			zap.String("user-agent", c.Request.UserAgent()),
+			zap.String("authorization", redactAuthorization(c.GetHeader("Authorization"))),
+			zap.String("body", body),
 			zap.String("errors", c.Errors.ByType(gin.ErrorTypePrivate).String()),
 			zap.Duration("cost", cost),
 		)
 	}
 }
 
-// GinRecovery recover掉项目可能出现的panic，并使用zap记录相关日志
-func GinRecovery(stack bool) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		defer func() {
-			if err := recover(); err != nil {
-				// Check for a broken connection, as it is not really a
-				// condition that warrants a panic stack trace.
-				var brokenPipe bool
-				if ne, ok := err.(*net.OpError); ok {
-					if se, ok := ne.Err.(*os.SyscallError); ok {
-						if strings.Contains(strings.ToLower(se.Error()), "broken pipe") || strings.Contains(strings.ToLower(se.Error()), "connection reset by peer") {
-							brokenPipe = true
-						}
-					}
-				}
-
-				httpRequest, _ := httputil.DumpRequest(c.Request, false)
-				if brokenPipe {
-					zap.L().Error(c.Request.URL.Path,
-						zap.Any("error", err),
-						zap.String("request", string(httpRequest)),
-					)
-					// If the connection is dead, we can't write a status to it.
-					c.Error(err.(error)) // nolint: errcheck
-					c.Abort()
-					return
-				}
-
-				if stack {
-					zap.L().Error("[Recovery from panic]",
-						zap.Any("error", err),
-						zap.String("request", string(httpRequest)),
-						zap.String("stack", string(debug.Stack())),
-					)
-				} else {
-					zap.L().Error("[Recovery from panic]",
-						zap.Any("error", err),
-						zap.String("request", string(httpRequest)),
-					)
-				}
-				c.AbortWithStatus(http.StatusInternalServerError)
-			}
-		}()
-		c.Next()
+// readBody 读取JSON请求体用于记录日志，读过的内容放回去供后面的处理函数使用
+// 不是JSON或超过max字节时不记录原文，只记录占位的说明
+func readBody(c *gin.Context, r redactor, max int) string {
+	if c.Request.Body == nil || c.Request.ContentLength == 0 {
+		return ""
+	}
+	if !strings.HasPrefix(c.ContentType(), "application/json") {
+		return "[non-json body omitted]"
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(c.Request.Body, int64(max)+1))
+	c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(b), c.Request.Body), c.Request.Body}
+	if err != nil {
+		return "[unreadable body omitted]"
+	}
+	if len(b) > max {
+		return "[body too large, omitted]"
+	}
+	redacted, ok := r.JSON(b)
+	if !ok {
+		return "[invalid json body omitted]"
 	}
+	return redacted
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
 }
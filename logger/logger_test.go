@@ -0,0 +1,61 @@
+package logger
+
+import (
+	"go-web-app/settings"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotateLoggerMaxBackups(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "app.log")
+	l := newRotateLogger(filename, &settings.LogConfig{MaxSize: 1, MaxBackups: 2, Compress: true})
+	defer l.Close()
+
+	for i := 0; i < 5; i++ {
+		_, err := l.Write([]byte("line\n"))
+		assert.NoError(t, err)
+		// 轮转后的文件名精确到毫秒
+		time.Sleep(2 * time.Millisecond)
+		assert.NoError(t, l.Rotate())
+	}
+
+	// 清理旧文件和压缩在后台进行
+	backups := func() (n int, compressed bool) {
+		files, _ := ioutil.ReadDir(dir)
+		compressed = true
+		for _, f := range files {
+			if f.Name() == "app.log" {
+				continue
+			}
+			n++
+			compressed = compressed && strings.HasSuffix(f.Name(), ".gz")
+		}
+		return
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		n, compressed := backups()
+		if (n == 2 && compressed) || time.Now().After(deadline) {
+			assert.Equal(t, 2, n)
+			assert.True(t, compressed)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLogEncoding(t *testing.T) {
+	assert.Equal(t, "json", logEncoding("", "release"))
+	assert.Equal(t, "console", logEncoding("", "dev"))
+	assert.Equal(t, "console", logEncoding("console", "release"))
+	assert.Equal(t, "json", logEncoding("json", "dev"))
+
+	_, err := getEncoder("xml")
+	assert.Error(t, err)
+}
@@ -0,0 +1,86 @@
+package logger
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+const redactedValue = "***"
+
+// redactor 遮盖日志中的敏感字段，字段名不区分大小写
+type redactor map[string]struct{}
+
+func newRedactor(fields []string) redactor {
+	r := make(redactor, len(fields))
+	for _, f := range fields {
+		if f = strings.ToLower(strings.TrimSpace(f)); f != "" {
+			r[f] = struct{}{}
+		}
+	}
+	return r
+}
+
+func (r redactor) sensitive(name string) bool {
+	_, ok := r[strings.ToLower(name)]
+	return ok
+}
+
+// JSON 遮盖JSON中任意层级的敏感字段，不是合法的JSON时返回ok=false，调用方不应该记录原文
+func (r redactor) JSON(body []byte) (redacted string, ok bool) {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return "", false
+	}
+	b, err := json.Marshal(r.value(v))
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
+
+func (r redactor) value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if r.sensitive(k) {
+				v[k] = redactedValue
+				continue
+			}
+			v[k] = r.value(child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = r.value(child)
+		}
+	}
+	return v
+}
+
+// Query 遮盖查询参数中的敏感字段，例如websocket连接的token参数，无法解析时整个遮盖
+func (r redactor) Query(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return redactedValue
+	}
+	for k := range values {
+		if r.sensitive(k) {
+			values[k] = []string{redactedValue}
+		}
+	}
+	return values.Encode()
+}
+
+// redactAuthorization 只保留认证方式，例如"Bearer ***"，凭证本身从不记录
+func redactAuthorization(h string) string {
+	if h == "" {
+		return ""
+	}
+	if i := strings.IndexByte(h, ' '); i > 0 {
+		return h[:i] + " " + redactedValue
+	}
+	return redactedValue
+}
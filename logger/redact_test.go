@@ -0,0 +1,86 @@
+package logger
+
+import (
+	"go-web-app/pkg/snowflake"
+	"go-web-app/settings"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRedactJSON(t *testing.T) {
+	r := newRedactor([]string{"password", " Token ", ""})
+	got, ok := r.JSON([]byte(`{"username":"alice","PASSWORD":"secret","nested":{"token":"abc","list":[{"token":1}]},"n":1}`))
+	require.True(t, ok)
+	assert.JSONEq(t, `{"username":"alice","PASSWORD":"***","nested":{"token":"***","list":[{"token":"***"}]},"n":1}`, got)
+
+	_, ok = r.JSON([]byte(`password=secret`))
+	assert.False(t, ok)
+}
+
+func TestRedactQuery(t *testing.T) {
+	r := newRedactor([]string{"token"})
+	assert.Equal(t, "page=2&token=%2A%2A%2A", r.Query("token=abc&page=2"))
+	assert.Equal(t, "", r.Query(""))
+	assert.Equal(t, redactedValue, r.Query("%zz"))
+}
+
+func TestRedactAuthorization(t *testing.T) {
+	assert.Equal(t, "Bearer ***", redactAuthorization("Bearer eyJhbGciOi.abc.def"))
+	assert.Equal(t, "***", redactAuthorization("eyJhbGciOi.abc.def"))
+	assert.Equal(t, "", redactAuthorization(""))
+}
+
+func TestGinLoggerRedactsLoginBody(t *testing.T) {
+	require.NoError(t, snowflake.Init("2020-01-01", 1))
+	old := settings.Conf.LogConfig
+	settings.Conf.LogConfig = &settings.LogConfig{
+		RedactFields: []string{"password", "token"},
+		LogBody:      true,
+		MaxBodyLog:   1024,
+	}
+	core, logs := observer.New(zapcore.InfoLevel)
+	oldLogger := accessLogger
+	accessLogger = zap.New(core)
+	defer func() {
+		settings.Conf.LogConfig = old
+		accessLogger = oldLogger
+	}()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(GinLogger())
+	var received string
+	r.POST("/api/v1/login", func(c *gin.Context) {
+		b, _ := ioutil.ReadAll(c.Request.Body)
+		received = string(b)
+		c.Status(http.StatusOK)
+	})
+	body := `{"username":"alice","password":"hunter2"}`
+	req := httptest.NewRequest("POST", "/api/v1/login?token=abc", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer secret-access-token")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	// 处理函数读到的仍然是原始的请求体
+	assert.Equal(t, body, received)
+	require.Equal(t, 1, logs.Len())
+	fields := logs.All()[0].ContextMap()
+	assert.JSONEq(t, `{"username":"alice","password":"***"}`, fields["body"].(string))
+	assert.Equal(t, "Bearer ***", fields["authorization"])
+	assert.Equal(t, "token=%2A%2A%2A", fields["query"])
+	for k, v := range fields {
+		s, _ := v.(string)
+		assert.NotContains(t, s, "hunter2", k)
+		assert.NotContains(t, s, "secret-access-token", k)
+	}
+}
@@ -0,0 +1,99 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"go-web-app/dao/mysql"
+	"go-web-app/pkg/mention"
+	"go-web-app/pkg/snowflake"
+	"go-web-app/settings"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 受注册时间限制的操作，对应settings.AccountAgeConfig中的字段
+const (
+	AccountActionPost     = "post"
+	AccountActionDownvote = "downvote"
+	AccountActionMention  = "mention"
+)
+
+var ErrorAccountTooNew = errors.New("Account is too new. ")
+
+// AccountTooNewError 注册时间不够，Wait为距离允许执行的时间
+type AccountTooNewError struct {
+	Action string
+	MinAge time.Duration
+	Wait   time.Duration
+}
+
+func (e *AccountTooNewError) Error() string {
+	return fmt.Sprintf("Your account must be at least %s old to %s, please try again in %s",
+		formatAge(e.MinAge), e.Action, formatAge(e.Wait))
+}
+
+func (e *AccountTooNewError) Is(target error) bool {
+	return target == ErrorAccountTooNew
+}
+
+// formatAge 向上取整到分钟，例如23h5m、24h
+func formatAge(d time.Duration) string {
+	if r := d % time.Minute; r != 0 {
+		d += time.Minute - r
+	}
+	s := strings.TrimSuffix(d.String(), "0s")
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// CheckAccountAge 检查用户的注册时间是否满足action的要求，注册时间从用户id中取出
+// 配置了ExemptVerifiedEdu时，只有在注册时间不够时才查询邮箱
+func CheckAccountAge(userID int64, action string) error {
+	cfg := settings.Current().AccountAgeConfig
+	if cfg == nil {
+		return nil
+	}
+	var hours int
+	switch action {
+	case AccountActionPost:
+		hours = cfg.Post
+	case AccountActionDownvote:
+		hours = cfg.Downvote
+	case AccountActionMention:
+		hours = cfg.Mention
+	}
+	if hours <= 0 {
+		return nil
+	}
+	minAge := time.Duration(hours) * time.Hour
+	age := time.Since(snowflake.Time(userID))
+	if age >= minAge {
+		return nil
+	}
+	if cfg.ExemptVerifiedEdu && isVerifiedEdu(userID) {
+		return nil
+	}
+	return &AccountTooNewError{Action: action, MinAge: minAge, Wait: minAge - age}
+}
+
+// isVerifiedEdu 查询失败时按不免除处理
+func isVerifiedEdu(userID int64) bool {
+	user, err := mysql.GetUserEmail(userID)
+	if err != nil {
+		zap.L().Warn("mysql.GetUserEmail failed", zap.Int64("userID", userID), zap.Error(err))
+		return false
+	}
+	return user.EmailVerified && strings.HasSuffix(strings.ToLower(user.Email), ".edu")
+}
+
+// checkMentionAge 内容中@了其他用户时检查注册时间，不查询被@的用户是否存在
+func checkMentionAge(userID int64, content string) error {
+	if len(mention.Parse(content, settings.Current().MentionConfig.MaxPerItem)) == 0 {
+		return nil
+	}
+	return CheckAccountAge(userID, AccountActionMention)
+}
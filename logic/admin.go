@@ -0,0 +1,46 @@
+package logic
+
+import (
+	"go-web-app/dao/mongodb"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+)
+
+// GetRecentUsers 最近注册的用户
+func GetRecentUsers(page, size int64) ([]*models.AdminUser, error) {
+	return mysql.GetRecentUsers(page, size)
+}
+
+// GetSiteStats 用户、帖子和评论的总数
+func GetSiteStats() (stats *models.SiteStats, err error) {
+	stats = new(models.SiteStats)
+	if stats.TotalUsers, err = mysql.GetUserCount(); err != nil {
+		return nil, err
+	}
+	if stats.TotalPosts, err = mysql.GetPostCount(); err != nil {
+		return nil, err
+	}
+	if stats.TotalComments, err = mongodb.CountComments(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// BanUser 封禁用户，拒绝登录并作废所有登录状态，管理员不能被封禁
+func BanUser(uid int64) error {
+	role, err := mysql.GetUserRole(uid)
+	if err != nil {
+		return err
+	}
+	if role == models.RoleAdmin {
+		return ErrorNoPermission
+	}
+	if err := mysql.SetBanned(uid, true); err != nil {
+		return err
+	}
+	if err := redis.SetBanned(uid, true); err != nil {
+		return err
+	}
+	return redis.DeleteSessions(uid)
+}
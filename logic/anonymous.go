@@ -0,0 +1,80 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"go-web-app/dao/mysql"
+	"go-web-app/models"
+)
+
+var ErrorAnonymousNotAllowed = errors.New("Anonymous posts are not allowed in this community. ")
+
+// checkAnonymousAllowed 匿名帖子发布到的每个社区都必须允许匿名发帖
+func checkAnonymousAllowed(ctx context.Context, anonymous bool, ids []int64) error {
+	if !anonymous {
+		return nil
+	}
+	communities, err := GetCommunitiesByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if !communities[id].AllowAnonymous {
+			return ErrorAnonymousNotAllowed
+		}
+	}
+	return nil
+}
+
+// anonymizePost 返回给客户端之前去掉匿名帖子的作者，帖子详情的缓存中也不保存作者
+// 作者id为0，屏蔽列表不会过滤匿名帖子，否则可以通过屏蔽来确认作者
+func anonymizePost(d *models.PostDetail) {
+	if d.Post == nil || !d.Anonymous {
+		return
+	}
+	d.AuthorName = models.AnonymousUserName
+	d.AuthorId = 0
+}
+
+// listedAuthor 公开统计中使用的作者，匿名帖子不出现在用户主页和关注动态中，收到的投票也不计入声望
+func listedAuthor(post *models.Post) int64 {
+	if post.Anonymous {
+		return 0
+	}
+	return post.AuthorId
+}
+
+// notifyPostMentions 通知帖子中@到的用户，匿名帖子不发送，通知中的操作者会暴露作者
+func notifyPostMentions(post *models.Post, mentions []*models.Mention) {
+	if post.Anonymous {
+		return
+	}
+	notifyMentions(mentions, post.AuthorId, models.NotificationMentionPost, post.PostID)
+}
+
+// SetCommunityAllowAnonymous 版主修改社区是否允许匿名发帖，关闭后已有的匿名帖子不受影响
+func SetCommunityAllowAnonymous(communityID int64, allow bool) error {
+	if err := mysql.SetCommunityAllowAnonymous(communityID, allow); err != nil {
+		return err
+	}
+	InvalidateCommunityCache(communityID)
+	return nil
+}
+
+// RevealPostAuthor 版主查看本社区中匿名帖子的真实作者，不是匿名帖子时同样返回作者
+// 帖子不属于该社区时视为不存在
+func RevealPostAuthor(ctx context.Context, communityID, pid int64) (*models.AuthorProfile, error) {
+	post, err := getCommunityPost(communityID, pid)
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := getAuthorProfiles(ctx, []int64{post.AuthorId})
+	if err != nil {
+		return nil, err
+	}
+	profile, ok := profiles[post.AuthorId]
+	if !ok {
+		return nil, mysql.ErrorUserNotExist
+	}
+	return profile, nil
+}
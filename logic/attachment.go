@@ -0,0 +1,169 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-web-app/dao/mysql"
+	"go-web-app/models"
+	"go-web-app/pkg/filetype"
+	"go-web-app/pkg/snowflake"
+	"go-web-app/settings"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrorAttachmentType      = errors.New("This file type is not allowed. ")
+	ErrorTooManyAttachments  = errors.New("Too many attachments on this post. ")
+	ErrorAttachmentsTooLarge = errors.New("Attachments on this post are too large in total. ")
+)
+
+// attachmentPurgeBatch 每次清理多少个帖子已经被彻底删除的附件
+const attachmentPurgeBatch = 200
+
+// UploadAttachment 帖子作者上传附件，文件类型按文件头判断
+func UploadAttachment(userID, pid int64, fileName string, data []byte) (*models.Attachment, error) {
+	post, err := mysql.GetPostById(pid)
+	if err != nil {
+		return nil, err
+	}
+	if post.AuthorId != userID {
+		return nil, ErrorNoPermission
+	}
+	cfg := settings.Current().AttachmentConfig
+	contentType := filetype.Detect(data)
+	ext := filetype.Ext(contentType)
+	if ext == "" || !attachmentTypeAllowed(cfg.AllowedTypes, contentType) {
+		return nil, ErrorAttachmentType
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, err
+	}
+	a := &models.Attachment{
+		AttachmentID: snowflake.GenID(),
+		PostID:       pid,
+		UploaderID:   userID,
+		FileName:     attachmentFileName(fileName, ext),
+		ContentType:  contentType,
+		Size:         int64(len(data)),
+	}
+	a.StoredName = strconv.FormatInt(a.AttachmentID, 10) + ext
+	path := filepath.Join(cfg.Dir, a.StoredName)
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return nil, err
+	}
+	err = mysql.AddPostAttachment(a, func(count, size int64) error {
+		if cfg.MaxCount > 0 && count >= int64(cfg.MaxCount) {
+			return ErrorTooManyAttachments
+		}
+		if cfg.MaxTotalSize > 0 && size+a.Size > cfg.MaxTotalSize*1024 {
+			return ErrorAttachmentsTooLarge
+		}
+		return nil
+	})
+	if err != nil {
+		_ = os.Remove(path)
+		return nil, err
+	}
+	a.URL = attachmentURL(a.AttachmentID)
+	return a, nil
+}
+
+// GetPostAttachments 帖子的附件和下载地址
+func GetPostAttachments(ctx context.Context, userID, pid int64) ([]*models.Attachment, error) {
+	post, err := mysql.GetPostById(pid)
+	if err != nil {
+		return nil, err
+	}
+	if err = CheckPostAccess(ctx, userID, post); err != nil {
+		return nil, err
+	}
+	list, err := mysql.GetPostAttachments(pid)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range list {
+		a.URL = attachmentURL(a.AttachmentID)
+	}
+	return list, nil
+}
+
+// GetAttachmentFile 下载附件时检查权限并返回本地文件的路径，帖子被删除后不能下载
+func GetAttachmentFile(ctx context.Context, userID, id int64) (*models.Attachment, string, error) {
+	a, err := mysql.GetAttachment(id)
+	if err != nil {
+		return nil, "", err
+	}
+	post, err := mysql.GetPostById(a.PostID)
+	if err != nil {
+		return nil, "", err
+	}
+	if err = CheckPostAccess(ctx, userID, post); err != nil {
+		return nil, "", err
+	}
+	return a, filepath.Join(settings.Current().AttachmentConfig.Dir, a.StoredName), nil
+}
+
+// purgeOrphanAttachments 删除帖子已经被彻底删除的附件，先删除文件再删除记录，删除文件失败的附件下次再清理
+func purgeOrphanAttachments(result *models.PurgeResult) error {
+	dir := settings.Current().AttachmentConfig.Dir
+	for {
+		list, err := mysql.GetOrphanAttachments(attachmentPurgeBatch)
+		if err != nil {
+			return err
+		}
+		ids := make([]int64, 0, len(list))
+		for _, a := range list {
+			if err := os.Remove(filepath.Join(dir, a.StoredName)); err != nil && !os.IsNotExist(err) {
+				zap.L().Error("remove attachment failed", zap.Int64("id", a.AttachmentID), zap.Error(err))
+				continue
+			}
+			ids = append(ids, a.AttachmentID)
+		}
+		if err = mysql.DeleteAttachments(ids); err != nil {
+			return err
+		}
+		result.Attachments += int64(len(ids))
+		// 有文件删除失败时不再继续，避免重复查询到同一批附件
+		if len(list) < attachmentPurgeBatch || len(ids) < len(list) {
+			return nil
+		}
+	}
+}
+
+func attachmentTypeAllowed(allowed []string, contentType string) bool {
+	for _, t := range allowed {
+		if t == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+func attachmentURL(id int64) string {
+	return fmt.Sprintf("/api/v1/attachment/%d", id)
+}
+
+// attachmentFileName 下载时使用的文件名，去掉路径和控制字符，扩展名改为与文件类型一致
+func attachmentFileName(name, ext string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == '"' || r == '\\' {
+			return -1
+		}
+		return r
+	}, filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	if name == "" || name == "." || name == "/" {
+		name = "attachment"
+	}
+	if r := []rune(name); len(r) > 200 {
+		name = string(r[:200])
+	}
+	return name + ext
+}
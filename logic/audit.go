@@ -0,0 +1,109 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/dao/mongodb"
+	"go-web-app/models"
+	"go-web-app/pkg/snowflake"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// auditBufferSize 等待写入的审计日志上限，超过时丢弃新的日志，不阻塞请求
+const auditBufferSize = 1024
+
+var (
+	auditQueue = make(chan *models.AuditLog, auditBufferSize)
+	auditStop  = make(chan struct{})
+	auditDone  = make(chan struct{})
+)
+
+// auditRetries 同步写入审计日志的尝试次数，每次失败后等待的时间递增
+const auditRetries = 3
+
+var auditRetryDelay = 100 * time.Millisecond
+
+func newAuditLog(actorID int64, action, target, ip string) *models.AuditLog {
+	return &models.AuditLog{
+		AuditID:    snowflake.GenID(),
+		ActorID:    actorID,
+		Action:     action,
+		Target:     target,
+		IP:         ip,
+		CreateTime: time.Now(),
+	}
+}
+
+// RecordAudit 异步记录一条审计日志，返回日志的id，缓冲满时丢弃日志
+func RecordAudit(actorID int64, action, target, ip string) int64 {
+	entry := newAuditLog(actorID, action, target, ip)
+	select {
+	case auditQueue <- entry:
+	default:
+		zap.L().Warn("audit queue is full, entry dropped", zap.Any("entry", entry))
+	}
+	return entry.AuditID
+}
+
+// StartAuditWriter 在后台把审计日志写入mongodb，返回的函数会写完缓冲中剩余的日志后退出
+func StartAuditWriter() (stop func(ctx context.Context) error) {
+	go func() {
+		defer close(auditDone)
+		for {
+			select {
+			case entry := <-auditQueue:
+				writeAudit(entry)
+			case <-auditStop:
+				for {
+					select {
+					case entry := <-auditQueue:
+						writeAudit(entry)
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+	return func(ctx context.Context) error {
+		close(auditStop)
+		select {
+		case <-auditDone:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// recordAuditSync 同步写入审计日志，失败时重试，其他记录中关联了日志id时使用，保证日志不会被丢弃
+func recordAuditSync(actorID int64, action, target, ip string) (int64, error) {
+	entry := newAuditLog(actorID, action, target, ip)
+	var err error
+	for i := 0; i < auditRetries; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(i) * auditRetryDelay)
+		}
+		if err = mongodb.InsertAuditLog(entry); err == nil {
+			return entry.AuditID, nil
+		}
+		zap.L().Warn("mongodb.InsertAuditLog failed", zap.Int64("audit_id", entry.AuditID), zap.Int("attempt", i+1), zap.Error(err))
+	}
+	return 0, err
+}
+
+func writeAudit(entry *models.AuditLog) {
+	if err := mongodb.InsertAuditLog(entry); err != nil {
+		zap.L().Error("mongodb.InsertAuditLog failed", zap.Any("entry", entry), zap.Error(err))
+	}
+}
+
+// GetAuditLogs 查询审计日志
+func GetAuditLogs(p *models.ParamAuditQuery) (list []*models.AuditLog, total int64, err error) {
+	if total, err = mongodb.CountAuditLogs(p); err != nil {
+		return nil, 0, err
+	}
+	list, err = mongodb.GetAuditLogs(p)
+	return
+}
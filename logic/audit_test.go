@@ -0,0 +1,65 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// useAuditQueue 换成容量为size的队列和新的停止信号，测试结束后恢复
+func useAuditQueue(t *testing.T, size int) {
+	oldQueue, oldStop, oldDone := auditQueue, auditStop, auditDone
+	auditQueue = make(chan *models.AuditLog, size)
+	auditStop = make(chan struct{})
+	auditDone = make(chan struct{})
+	t.Cleanup(func() {
+		auditQueue, auditStop, auditDone = oldQueue, oldStop, oldDone
+	})
+}
+
+func TestRecordAuditDropsWhenFull(t *testing.T) {
+	useAuditQueue(t, 2)
+	core, logs := observer.New(zapcore.WarnLevel)
+	restore := zap.ReplaceGlobals(zap.New(core))
+	t.Cleanup(restore)
+
+	ids := make([]int64, 0, 3)
+	for i := 0; i < 3; i++ {
+		// 队列满时不阻塞请求
+		ids = append(ids, RecordAudit(1, models.AuditActionLogin, "user:1", "127.0.0.1"))
+	}
+	assert.Len(t, auditQueue, 2)
+	assert.NotEqual(t, ids[0], ids[2])
+	require.Equal(t, 1, logs.FilterMessage("audit queue is full, entry dropped").Len())
+
+	// 排队的是先记录的日志，丢弃的是缓冲满之后的
+	assert.Equal(t, ids[0], (<-auditQueue).AuditID)
+	assert.Equal(t, ids[1], (<-auditQueue).AuditID)
+}
+
+func TestStartAuditWriterDrains(t *testing.T) {
+	useMongo(t)
+	useAuditQueue(t, 16)
+	actor := time.Now().UnixNano()
+	for i := 0; i < 5; i++ {
+		RecordAudit(actor, models.AuditActionLogin, "user:1", "127.0.0.1")
+	}
+	// 停止时写完缓冲中剩余的日志
+	stop := StartAuditWriter()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, stop(ctx))
+	assert.Empty(t, auditQueue)
+
+	list, total, err := GetAuditLogs(&models.ParamAuditQuery{ActorID: actor, Page: 1, Size: 10})
+	require.NoError(t, err)
+	assert.EqualValues(t, 5, total)
+	assert.Len(t, list, 5)
+}
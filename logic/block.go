@@ -0,0 +1,118 @@
+package logic
+
+import (
+	"errors"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"time"
+
+	goredis "github.com/go-redis/redis"
+	"go.uber.org/zap"
+)
+
+const blockedCacheExpire = 24 * time.Hour
+
+var ErrorBlockSelf = errors.New("Can't block yourself. ")
+
+// Block 屏蔽用户，屏蔽是单向的，被屏蔽的人不会收到任何提示
+func Block(userID, targetID int64) error {
+	if userID == targetID {
+		return ErrorBlockSelf
+	}
+	if err := mysql.Block(userID, targetID); err != nil {
+		return err
+	}
+	return redis.DeleteBlockedIDs(userID)
+}
+
+func Unblock(userID, targetID int64) error {
+	if userID == targetID {
+		return ErrorBlockSelf
+	}
+	if err := mysql.Unblock(userID, targetID); err != nil {
+		return err
+	}
+	return redis.DeleteBlockedIDs(userID)
+}
+
+// getBlockedSet 返回userID屏蔽的用户集合，优先读redis缓存
+func getBlockedSet(userID int64) (map[int64]struct{}, error) {
+	ids, err := redis.GetBlockedIDs(userID)
+	if err == goredis.Nil {
+		ids, err = mysql.GetBlockedIDs(userID)
+		if err != nil {
+			return nil, err
+		}
+		if err := redis.SetBlockedIDs(userID, ids, blockedCacheExpire); err != nil {
+			zap.L().Warn("redis.SetBlockedIDs failed", zap.Int64("userID", userID), zap.Error(err))
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	set := make(map[int64]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	return set, nil
+}
+
+// IsBlocked 判断blockerID是否屏蔽了userID
+func IsBlocked(blockerID, userID int64) (bool, error) {
+	set, err := getBlockedSet(blockerID)
+	if err != nil {
+		return false, err
+	}
+	_, ok := set[userID]
+	return ok, nil
+}
+
+// FilterBlockedPosts 去掉userID屏蔽的用户发布的帖子，读取屏蔽列表失败时原样返回
+func FilterBlockedPosts(userID int64, posts []*models.PostDetail) []*models.PostDetail {
+	set, err := getBlockedSet(userID)
+	if err != nil {
+		zap.L().Error("getBlockedSet failed", zap.Int64("userID", userID), zap.Error(err))
+		return posts
+	}
+	if len(set) == 0 {
+		return posts
+	}
+	filtered := make([]*models.PostDetail, 0, len(posts))
+	for _, post := range posts {
+		if _, ok := set[post.AuthorId]; ok {
+			continue
+		}
+		filtered = append(filtered, post)
+	}
+	return filtered
+}
+
+// FilterBlockedComments 去掉userID屏蔽的用户发布的评论以及它下面的回复
+func FilterBlockedComments(userID int64, nodes []*models.CommentNode) []*models.CommentNode {
+	set, err := getBlockedSet(userID)
+	if err != nil {
+		zap.L().Error("getBlockedSet failed", zap.Int64("userID", userID), zap.Error(err))
+		return nodes
+	}
+	if len(set) == 0 {
+		return nodes
+	}
+	return filterCommentNodes(nodes, set)
+}
+
+// 评论树的深度有上限，这里递归是安全的
+func filterCommentNodes(nodes []*models.CommentNode, blocked map[int64]struct{}) []*models.CommentNode {
+	filtered := make([]*models.CommentNode, 0, len(nodes))
+	for _, node := range nodes {
+		if _, ok := blocked[node.AuthorID]; ok {
+			continue
+		}
+		node.Children = filterCommentNodes(node.Children, blocked)
+		node.ReplyCount = 0
+		for _, child := range node.Children {
+			node.ReplyCount += child.ReplyCount + 1
+		}
+		filtered = append(filtered, node)
+	}
+	return filtered
+}
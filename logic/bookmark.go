@@ -0,0 +1,41 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/dao/mysql"
+	"go-web-app/models"
+)
+
+// SavePost 收藏帖子，帖子不存在或已删除时返回sql.ErrNoRows
+func SavePost(userID, pid int64) error {
+	if _, err := mysql.GetPostById(pid); err != nil {
+		return err
+	}
+	return mysql.SavePost(userID, pid)
+}
+
+func UnsavePost(userID, pid int64) error {
+	return mysql.UnsavePost(userID, pid)
+}
+
+func IsPostSaved(userID, pid int64) (bool, error) {
+	return mysql.IsPostSaved(userID, pid)
+}
+
+// GetSavedPosts 用户收藏的帖子，最近收藏的在前
+func GetSavedPosts(ctx context.Context, userID, page, size int64) (data []*models.PostDetail, total int64, err error) {
+	if total, err = mysql.GetSavedPostCount(userID); err != nil {
+		return nil, 0, err
+	}
+	posts, err := mysql.GetSavedPosts(userID, page, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	if data, err = getPostDetailList(ctx, posts); err != nil {
+		return nil, 0, err
+	}
+	for _, post := range data {
+		post.IsSaved = true
+	}
+	return
+}
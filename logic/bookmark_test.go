@@ -0,0 +1,71 @@
+package logic
+
+import (
+	"context"
+	"database/sql"
+	"go-web-app/dao/mysql"
+	"go-web-app/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSavePost(t *testing.T) {
+	useMySQL(t)
+	useMongo(t)
+	useMiniredis(t)
+	user := createTestUser(t)
+	community := createTestCommunity(t, models.CommunityVisibilityPublic)
+	first := createTestPost(t, user.UserID, community)
+	second := createTestPost(t, user.UserID, community)
+
+	require.NoError(t, SavePost(user.UserID, first.PostID))
+	require.NoError(t, SavePost(user.UserID, second.PostID))
+	// 重复收藏不报错，也不会重复计数
+	require.NoError(t, SavePost(user.UserID, second.PostID))
+	saved, err := IsPostSaved(user.UserID, first.PostID)
+	require.NoError(t, err)
+	assert.True(t, saved)
+
+	data, total, err := GetSavedPosts(context.Background(), user.UserID, 1, 10)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, total)
+	require.Len(t, data, 2)
+	assert.Equal(t, second.PostID, data[0].PostID)
+	assert.Equal(t, first.PostID, data[1].PostID)
+	for _, post := range data {
+		assert.True(t, post.IsSaved)
+	}
+
+	// 取消收藏和已删除的帖子都不再出现在列表中
+	require.NoError(t, UnsavePost(user.UserID, first.PostID))
+	require.NoError(t, mysql.DeletePost(second.PostID))
+	saved, err = IsPostSaved(user.UserID, first.PostID)
+	require.NoError(t, err)
+	assert.False(t, saved)
+	data, total, err = GetSavedPosts(context.Background(), user.UserID, 1, 10)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+	assert.Empty(t, data)
+}
+
+func TestSavePostErrors(t *testing.T) {
+	useMySQL(t)
+	mr := useMiniredis(t)
+	user := createTestUser(t)
+	community := createTestCommunity(t, models.CommunityVisibilityPublic)
+	post := createTestPost(t, user.UserID, community)
+
+	// 不存在和已删除的帖子不能收藏
+	assert.ErrorIs(t, SavePost(user.UserID, 1<<60), sql.ErrNoRows)
+	deleted := createTestPost(t, user.UserID, community)
+	require.NoError(t, mysql.DeletePost(deleted.PostID))
+	assert.ErrorIs(t, SavePost(user.UserID, deleted.PostID), sql.ErrNoRows)
+
+	// 投票数据读取失败时整个列表返回错误
+	require.NoError(t, SavePost(user.UserID, post.PostID))
+	mr.Close()
+	_, _, err := GetSavedPosts(context.Background(), user.UserID, 1, 10)
+	assert.Error(t, err)
+}
@@ -0,0 +1,41 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"go-web-app/pkg/captcha"
+	"go-web-app/settings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrorCaptchaRequired = errors.New("captcha token is required")
+	ErrorCaptchaInvalid  = errors.New("captcha verification failed")
+)
+
+// VerifyCaptcha 没有开启人机验证时直接通过
+// 校验接口超时或不可用时同样拒绝，避免校验服务出问题时被批量注册
+func VerifyCaptcha(ctx context.Context, token, remoteIP string) error {
+	cfg := settings.Current().CaptchaConfig
+	if cfg == nil || !cfg.Enable {
+		return nil
+	}
+	if token == "" {
+		return ErrorCaptchaRequired
+	}
+	verifyURL := cfg.VerifyURL
+	if verifyURL == "" {
+		verifyURL = captcha.VerifyURL(cfg.Provider)
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(cfg.Timeout)*time.Millisecond)
+	defer cancel()
+	if err := captcha.Verify(ctx, verifyURL, cfg.Secret, token, remoteIP); err != nil {
+		if !errors.Is(err, captcha.ErrInvalid) {
+			zap.L().Error("captcha.Verify failed", zap.String("provider", cfg.Provider), zap.Error(err))
+		}
+		return ErrorCaptchaInvalid
+	}
+	return nil
+}
@@ -0,0 +1,357 @@
+package logic
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"go-web-app/dao/mongodb"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/pkg/snowflake"
+	"go-web-app/settings"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrorNoPermission  = errors.New("No permission. ")
+	ErrorCommentLocked = errors.New("Comments are locked. ")
+	ErrorThreadClosed  = errors.New("This thread is closed to new comments. ")
+	// ErrorCommentEditExpired 超过comment.edit_window之后不能再编辑
+	ErrorCommentEditExpired = errors.New("This comment can no longer be edited. ")
+)
+
+func CreateComment(ctx context.Context, userID int64, p *models.ParamCreateComment) (comment *models.Comment, err error) {
+	if err = checkMentionAge(userID, p.Content); err != nil {
+		return nil, err
+	}
+	// 评论没有隐藏的状态，没有通过链接检查时直接拒绝
+	if checkSpamLinks(userID, p.Content) != nil {
+		return nil, ErrorSpamLink
+	}
+	post, err := mysql.GetPostById(p.PostID)
+	if err != nil {
+		zap.L().Error("mysql.GetPostById(pid) failed", zap.Int64("pid", p.PostID), zap.Error(err))
+		return nil, err
+	}
+	if isUnpublished(post) {
+		return nil, sql.ErrNoRows
+	}
+	if err = CheckPostAccess(ctx, userID, post); err != nil {
+		return nil, err
+	}
+	if err = checkCommentsOpen(post, time.Now()); err != nil {
+		return nil, err
+	}
+	// 被帖子作者屏蔽的用户不能评论
+	if err = checkNotBlocked(post.AuthorId, userID); err != nil {
+		return nil, err
+	}
+	recipientID := post.AuthorId
+	if p.ParentID != 0 {
+		parent, err := mongodb.GetCommentByID(p.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		if parent.PostID != p.PostID {
+			return nil, mongodb.ErrorCommentNotExist
+		}
+		if err = checkNotBlocked(parent.AuthorID, userID); err != nil {
+			return nil, err
+		}
+		recipientID = parent.AuthorID
+	}
+	comment = &models.Comment{
+		CommentID:  snowflake.GenID(),
+		PostID:     p.PostID,
+		AuthorID:   userID,
+		ParentID:   p.ParentID,
+		Content:    p.Content,
+		Mentions:   resolveMentions(p.Content),
+		CreateTime: time.Now(),
+	}
+	if err = mongodb.CreateComment(comment); err != nil {
+		return nil, err
+	}
+	// 直接评论通知帖子作者，回复评论通知被回复的人，被@的人已经收到回复通知时不再通知
+	notify(recipientID, userID, models.NotificationReply, comment.CommentID, false)
+	notifyMentions(comment.Mentions, userID, models.NotificationMentionComment, comment.CommentID, recipientID)
+	publishComment(comment)
+	return
+}
+
+// GetCommentList 返回帖子的评论树，按order排序之后按顶层评论分页，total为顶层评论数
+func GetCommentList(ctx context.Context, pid, page, size int64, order string) ([]*models.CommentNode, int64, error) {
+	rootIDs, total, err := topLevelCommentPage(pid, page, size, order, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	nodes, err := mongodb.GetCommentTrees(pid, rootIDs, settings.Current().CommentConfig.MaxDepth)
+	if err != nil {
+		return nil, 0, err
+	}
+	// 顶层评论已经是这一页的顺序，这里排序每个评论下面的回复
+	sortCommentTree(nodes, order)
+	fillCommentVotes(nodes)
+	fillCommentAuthors(ctx, nodes)
+	return nodes, total, nil
+}
+
+// topLevelCommentPage 帖子按order排序后第page页顶层评论的id和顶层评论总数，不包括excludeAuthors发布的评论
+// 按时间排序时在查询中分页；按投票排序时只读取顶层评论的id，按redis中的票数排序之后取出这一页
+// page或size小于1时只返回总数
+func topLevelCommentPage(pid, page, size int64, order string, excludeAuthors []int64) ([]int64, int64, error) {
+	skip := (page - 1) * size
+	empty := page < 1 || size < 1
+	if order == models.CommentSortOld || order == models.CommentSortNew {
+		total, err := mongodb.CountTopLevelComments(pid, excludeAuthors)
+		if err != nil || empty || skip >= total {
+			return nil, total, err
+		}
+		ids, err := mongodb.GetTopLevelCommentIDs(pid, excludeAuthors, skip, size, order == models.CommentSortNew)
+		return ids, total, err
+	}
+	ids, err := mongodb.GetTopLevelCommentIDs(pid, excludeAuthors, 0, 0, false)
+	if err != nil {
+		return nil, 0, err
+	}
+	total := int64(len(ids))
+	if empty || skip >= total {
+		return nil, total, nil
+	}
+	nodes := make([]*models.CommentNode, 0, len(ids))
+	for _, id := range ids {
+		nodes = append(nodes, &models.CommentNode{Comment: &models.Comment{CommentID: id}})
+	}
+	sortCommentTree(nodes, order)
+	end := skip + size
+	if end > total {
+		end = total
+	}
+	res := make([]int64, 0, end-skip)
+	for _, n := range nodes[skip:end] {
+		res = append(res, n.CommentID)
+	}
+	return res, total, nil
+}
+
+// GetTopComments 按order排序之后帖子的前comment.embed_size个顶层评论，每个只保留排在前面的comment.embed_replies条直接回复，更深的回复不返回
+// 先去掉userID屏蔽的用户的评论，total为之后的顶层评论数
+func GetTopComments(ctx context.Context, userID, pid int64, order string) (nodes []*models.CommentNode, total int64, err error) {
+	cfg := settings.Current().CommentConfig
+	size, replies := int64(cfg.EmbedSize), cfg.EmbedReplies
+	var blocked []int64
+	if set, err := getBlockedSet(userID); err != nil {
+		zap.L().Error("getBlockedSet failed", zap.Int64("userID", userID), zap.Error(err))
+	} else {
+		for id := range set {
+			blocked = append(blocked, id)
+		}
+	}
+	rootIDs, total, err := topLevelCommentPage(pid, 1, size, order, blocked)
+	if err != nil {
+		return nil, 0, err
+	}
+	tree, err := mongodb.GetCommentTrees(pid, rootIDs, settings.Current().CommentConfig.MaxDepth)
+	if err != nil {
+		return nil, 0, err
+	}
+	// 顶层评论已经在查询中去掉，这里去掉回复中屏蔽的用户
+	tree = FilterBlockedComments(userID, tree)
+	sortCommentTree(tree, order)
+	for _, node := range tree {
+		if replies >= 0 && len(node.Children) > replies {
+			node.Children = node.Children[:replies]
+		}
+		for _, child := range node.Children {
+			child.Children = []*models.CommentNode{}
+		}
+	}
+	fillCommentVotes(tree)
+	fillCommentAuthors(ctx, tree)
+	return tree, total, nil
+}
+
+// flattenComments 评论树中的所有评论，父评论在子评论之前
+func flattenComments(nodes []*models.CommentNode) []*models.Comment {
+	var comments []*models.Comment
+	var walk func([]*models.CommentNode)
+	walk = func(nodes []*models.CommentNode) {
+		for _, n := range nodes {
+			comments = append(comments, n.Comment)
+			walk(n.Children)
+		}
+	}
+	walk(nodes)
+	return comments
+}
+
+// fillCommentAuthors 一次查询评论树中所有作者的公开信息，失败时只记录日志，作者信息为空
+func fillCommentAuthors(ctx context.Context, nodes []*models.CommentNode) {
+	comments := flattenComments(nodes)
+	ids := make([]int64, 0, len(comments))
+	for _, c := range comments {
+		ids = append(ids, c.AuthorID)
+	}
+	profiles, err := getAuthorProfiles(ctx, uniqueIDs(ids))
+	if err != nil {
+		zap.L().Error("getAuthorProfiles failed", zap.Error(err))
+		return
+	}
+	for _, c := range comments {
+		c.Author = profiles[c.AuthorID]
+	}
+}
+
+// getAuthorProfiles 先读取redis中的缓存，没有缓存的用户从mysql批量查询后写入缓存
+// 已注销的用户只保留id，显示为models.DeactivatedUserName
+func getAuthorProfiles(ctx context.Context, ids []int64) (map[int64]*models.AuthorProfile, error) {
+	ttl := time.Duration(settings.Current().CommentConfig.AuthorCacheTTL) * time.Second
+	profiles := make(map[int64]*models.AuthorProfile, len(ids))
+	missing := ids
+	if ttl > 0 {
+		cached, rest, err := redis.GetAuthorProfiles(ids)
+		if err != nil {
+			zap.L().Warn("redis.GetAuthorProfiles failed", zap.Error(err))
+		} else {
+			profiles, missing = cached, rest
+		}
+	}
+	loaded, err := mysql.GetAuthorProfiles(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	for uid, profile := range loaded {
+		if profile.Deactivated {
+			loaded[uid] = &models.AuthorProfile{UserID: uid, Username: models.DeactivatedUserName}
+		}
+		profiles[uid] = loaded[uid]
+	}
+	if ttl > 0 {
+		if err := redis.SetAuthorProfiles(loaded, ttl); err != nil {
+			zap.L().Warn("redis.SetAuthorProfiles failed", zap.Error(err))
+		}
+	}
+	return profiles, nil
+}
+
+// invalidateAuthorProfile 用户名或头像修改后删除作者信息的缓存，失败时等缓存过期
+func invalidateAuthorProfile(uid int64) {
+	if err := redis.DeleteAuthorProfile(uid); err != nil {
+		zap.L().Warn("redis.DeleteAuthorProfile failed", zap.Int64("uid", uid), zap.Error(err))
+	}
+}
+
+// invalidateAuthorPosts 作者名变化后删除作者所有帖子的详情缓存，失败时等缓存过期
+func invalidateAuthorPosts(uid int64) {
+	if err := redis.DeleteUserPostDetailCaches(uid); err != nil {
+		zap.L().Warn("redis.DeleteUserPostDetailCaches failed", zap.Int64("uid", uid), zap.Error(err))
+	}
+}
+
+// fillCommentVotes 填充评论树中每个评论的赞成票数和得分，得分过低的评论标记为折叠，失败时票数为0
+func fillCommentVotes(nodes []*models.CommentNode) {
+	comments := flattenComments(nodes)
+	ids := make([]string, 0, len(comments))
+	for _, c := range comments {
+		ids = append(ids, strconv.FormatInt(c.CommentID, 10))
+	}
+	votes, err := redis.GetCommentVoteData(ids)
+	if err != nil {
+		zap.L().Error("redis.GetCommentVoteData failed", zap.Error(err))
+		return
+	}
+	for i, v := range votes {
+		comments[i].VoteNum = v
+	}
+	scores, err := redis.GetCommentScores(ids)
+	if err != nil {
+		zap.L().Error("redis.GetCommentScores failed", zap.Error(err))
+		return
+	}
+	threshold := settings.Current().CommentConfig.CollapseThreshold
+	for i, s := range scores {
+		comments[i].Score = s
+		comments[i].Collapsed = threshold < 0 && s <= threshold
+	}
+}
+
+// UpdateComment 作者在comment.edit_window内编辑评论，帖子锁定评论后不能编辑
+// 只通知编辑后新@到的用户
+func UpdateComment(userID, cid int64, p *models.ParamUpdateComment) (*models.Comment, error) {
+	comment, err := mongodb.GetCommentByID(cid)
+	if err != nil {
+		return nil, err
+	}
+	if comment.AuthorID != userID {
+		return nil, ErrorNoPermission
+	}
+	now := time.Now()
+	if window := settings.Current().CommentConfig.EditWindow; window > 0 &&
+		now.Sub(comment.CreateTime) > time.Duration(window)*time.Minute {
+		return nil, ErrorCommentEditExpired
+	}
+	if err = checkMentionAge(userID, p.Content); err != nil {
+		return nil, err
+	}
+	if checkSpamLinks(userID, p.Content) != nil {
+		return nil, ErrorSpamLink
+	}
+	post, err := mysql.GetPostById(comment.PostID)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkCommentsOpen(post, time.Now()); err != nil {
+		return nil, err
+	}
+	mentions := resolveMentions(p.Content)
+	if err = mongodb.UpdateComment(cid, p.Content, mentions, now); err != nil {
+		return nil, err
+	}
+	notified := make([]int64, 0, len(comment.Mentions))
+	for _, m := range comment.Mentions {
+		notified = append(notified, m.UserID)
+	}
+	notifyMentions(mentions, userID, models.NotificationMentionComment, cid, notified...)
+	comment.Content = p.Content
+	comment.Mentions = mentions
+	comment.EditedAt = &now
+	return comment, nil
+}
+
+func DeleteComment(userID, cid int64) error {
+	comment, err := mongodb.GetCommentByID(cid)
+	if err != nil {
+		return err
+	}
+	if comment.AuthorID != userID {
+		return ErrorNoPermission
+	}
+	return mongodb.DeleteComment(cid)
+}
+
+func checkNotBlocked(blockerID, userID int64) error {
+	blocked, err := IsBlocked(blockerID, userID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return ErrorNoPermission
+	}
+	return nil
+}
+
+// checkCommentsOpen 版主锁定的帖子返回ErrorCommentLocked，发布超过comment.close_after天并且没有被版主解锁的帖子返回ErrorThreadClosed
+func checkCommentsOpen(post *models.Post, now time.Time) error {
+	if post.CommentLocked {
+		return ErrorCommentLocked
+	}
+	days := settings.Current().CommentConfig.CloseAfter
+	if days > 0 && !post.CommentUnlocked && now.Sub(post.CreateTime) > time.Duration(days)*24*time.Hour {
+		return ErrorThreadClosed
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package logic
+
+import (
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"sort"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// sortCommentTree 按order排序评论树的每一层，不改变回复所在的层级，order为空时按best排序
+// 读取票数失败时只记录日志，保持按时间从早到晚的顺序
+func sortCommentTree(nodes []*models.CommentNode, order string) {
+	if order == "" {
+		order = models.CommentSortBest
+	}
+	if order == models.CommentSortOld {
+		// 评论树本来就是按发布时间从早到晚组装的
+		return
+	}
+	var key func(n *models.CommentNode) float64
+	switch order {
+	case models.CommentSortNew:
+		key = func(n *models.CommentNode) float64 { return float64(n.CreateTime.UnixNano()) }
+	case models.CommentSortBest, models.CommentSortControversial:
+		scores, err := commentSortScores(nodes, order)
+		if err != nil {
+			zap.L().Error("load comment vote counts failed", zap.String("sort", order), zap.Error(err))
+			return
+		}
+		key = func(n *models.CommentNode) float64 { return scores[n.CommentID] }
+	default:
+		return
+	}
+	var walk func([]*models.CommentNode)
+	walk = func(level []*models.CommentNode) {
+		// 分数相同时保持原来的时间顺序
+		sort.SliceStable(level, func(i, j int) bool { return key(level[i]) > key(level[j]) })
+		for _, n := range level {
+			walk(n.Children)
+		}
+	}
+	walk(nodes)
+}
+
+// commentSortScores 评论树中每个评论的排序分数，key为评论id
+func commentSortScores(nodes []*models.CommentNode, order string) (map[int64]float64, error) {
+	comments := flattenComments(nodes)
+	ids := make([]string, 0, len(comments))
+	for _, c := range comments {
+		ids = append(ids, strconv.FormatInt(c.CommentID, 10))
+	}
+	up, down, err := redis.GetCommentVoteCounts(ids)
+	if err != nil {
+		return nil, err
+	}
+	scores := make(map[int64]float64, len(comments))
+	for i, c := range comments {
+		if order == models.CommentSortControversial {
+			scores[c.CommentID] = redis.ControversyScore(up[i], down[i])
+		} else {
+			scores[c.CommentID] = redis.WilsonScore(up[i], down[i])
+		}
+	}
+	return scores, nil
+}
@@ -0,0 +1,192 @@
+package logic
+
+import (
+	"go-web-app/dao/mongodb"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/pkg/snowflake"
+	"go-web-app/settings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useCommentConfig(t *testing.T, cfg *settings.CommentConfig) {
+	old := settings.Conf.CommentConfig
+	settings.Conf.CommentConfig = cfg
+	t.Cleanup(func() { settings.Conf.CommentConfig = old })
+}
+
+func useMentionConfig(t *testing.T) {
+	old := settings.Conf.MentionConfig
+	settings.Conf.MentionConfig = &settings.MentionConfig{MaxPerItem: 10}
+	t.Cleanup(func() { settings.Conf.MentionConfig = old })
+}
+
+func testCommentTree() []*models.CommentNode {
+	reply := &models.CommentNode{Comment: &models.Comment{CommentID: 2}}
+	return []*models.CommentNode{
+		{Comment: &models.Comment{CommentID: 1}, Children: []*models.CommentNode{reply}},
+		{Comment: &models.Comment{CommentID: 3}},
+	}
+}
+
+func TestFillCommentVotesCollapse(t *testing.T) {
+	useMiniredis(t)
+	useCommentConfig(t, &settings.CommentConfig{CollapseThreshold: -2})
+	for _, v := range []struct {
+		user, comment string
+		value         float64
+	}{
+		{"10", "1", 1}, {"11", "1", 1},
+		{"10", "2", -1}, {"11", "2", -1},
+		{"10", "3", -1},
+	} {
+		_, err := redis.VoteForComment(v.user, v.comment, v.value)
+		require.NoError(t, err)
+	}
+
+	nodes := testCommentTree()
+	fillCommentVotes(nodes)
+	reply := nodes[0].Children[0]
+	assert.Equal(t, int64(2), nodes[0].VoteNum)
+	assert.Equal(t, int64(2), nodes[0].Score)
+	assert.False(t, nodes[0].Collapsed)
+	// 回复中得分过低的评论也会折叠
+	assert.Equal(t, int64(-2), reply.Score)
+	assert.True(t, reply.Collapsed)
+	assert.Equal(t, int64(-1), nodes[1].Score)
+	assert.False(t, nodes[1].Collapsed)
+
+	// 阈值为0时不折叠
+	useCommentConfig(t, &settings.CommentConfig{})
+	nodes = testCommentTree()
+	fillCommentVotes(nodes)
+	assert.Equal(t, int64(-2), nodes[0].Children[0].Score)
+	assert.False(t, nodes[0].Children[0].Collapsed)
+}
+
+func TestFillCommentVotesRedisDown(t *testing.T) {
+	mr := useMiniredis(t)
+	useCommentConfig(t, &settings.CommentConfig{CollapseThreshold: -1})
+	mr.Close()
+	// redis出错时票数和得分为0，不折叠
+	nodes := testCommentTree()
+	fillCommentVotes(nodes)
+	for _, c := range flattenComments(nodes) {
+		assert.Zero(t, c.VoteNum)
+		assert.Zero(t, c.Score)
+		assert.False(t, c.Collapsed)
+	}
+}
+
+func createTestComment(t *testing.T, postID, authorID int64, createTime time.Time) *models.Comment {
+	comment := &models.Comment{
+		CommentID:  snowflake.GenID(),
+		PostID:     postID,
+		AuthorID:   authorID,
+		Content:    "original",
+		CreateTime: createTime,
+	}
+	require.NoError(t, mongodb.CreateComment(comment))
+	return comment
+}
+
+func TestUpdateComment(t *testing.T) {
+	useMySQL(t)
+	useMongo(t)
+	useMiniredis(t)
+	useCommentConfig(t, &settings.CommentConfig{EditWindow: 60})
+	useMentionConfig(t)
+	author := createTestUser(t)
+	community := createTestCommunity(t, models.CommunityVisibilityPublic)
+	post := createTestPost(t, author.UserID, community)
+	comment := createTestComment(t, post.PostID, author.UserID, time.Now().Add(-30*time.Minute))
+
+	updated, err := UpdateComment(author.UserID, comment.CommentID, &models.ParamUpdateComment{Content: "changed"})
+	require.NoError(t, err)
+	assert.Equal(t, "changed", updated.Content)
+	require.NotNil(t, updated.EditedAt)
+	stored, err := mongodb.GetCommentByID(comment.CommentID)
+	require.NoError(t, err)
+	assert.Equal(t, "changed", stored.Content)
+	assert.NotNil(t, stored.EditedAt)
+}
+
+func TestUpdateCommentErrors(t *testing.T) {
+	useMySQL(t)
+	useMongo(t)
+	useMiniredis(t)
+	useCommentConfig(t, &settings.CommentConfig{EditWindow: 60})
+	useMentionConfig(t)
+	author, other := createTestUser(t), createTestUser(t)
+	community := createTestCommunity(t, models.CommunityVisibilityPublic)
+	post := createTestPost(t, author.UserID, community)
+	p := &models.ParamUpdateComment{Content: "changed"}
+
+	recent := createTestComment(t, post.PostID, author.UserID, time.Now())
+	_, err := UpdateComment(other.UserID, recent.CommentID, p)
+	assert.ErrorIs(t, err, ErrorNoPermission)
+	_, err = UpdateComment(author.UserID, 1<<60, p)
+	assert.ErrorIs(t, err, mongodb.ErrorCommentNotExist)
+	// 超过编辑时间
+	old := createTestComment(t, post.PostID, author.UserID, time.Now().Add(-2*time.Hour))
+	_, err = UpdateComment(author.UserID, old.CommentID, p)
+	assert.ErrorIs(t, err, ErrorCommentEditExpired)
+	// 帖子锁定评论之后不能编辑
+	require.NoError(t, mysql.SetCommentLocked(post.PostID, true))
+	_, err = UpdateComment(author.UserID, recent.CommentID, p)
+	assert.ErrorIs(t, err, ErrorCommentLocked)
+
+	for _, c := range []*models.Comment{recent, old} {
+		stored, err := mongodb.GetCommentByID(c.CommentID)
+		require.NoError(t, err)
+		assert.Equal(t, "original", stored.Content)
+		assert.Nil(t, stored.EditedAt)
+	}
+}
+
+func TestCheckCommentsOpen(t *testing.T) {
+	useCommentConfig(t, &settings.CommentConfig{CloseAfter: 30})
+	now := time.Now()
+	recent := &models.Post{CreateTime: now.Add(-29 * 24 * time.Hour)}
+	old := &models.Post{CreateTime: now.Add(-31 * 24 * time.Hour)}
+	assert.NoError(t, checkCommentsOpen(recent, now))
+	assert.ErrorIs(t, checkCommentsOpen(old, now), ErrorThreadClosed)
+	// 版主手动解锁的帖子不受发布时间限制，锁定优先于自动关闭
+	assert.NoError(t, checkCommentsOpen(&models.Post{CreateTime: old.CreateTime, CommentUnlocked: true}, now))
+	assert.ErrorIs(t, checkCommentsOpen(&models.Post{CreateTime: now, CommentLocked: true}, now), ErrorCommentLocked)
+	assert.ErrorIs(t, checkCommentsOpen(&models.Post{CreateTime: old.CreateTime, CommentLocked: true}, now), ErrorCommentLocked)
+
+	// 为0时不自动关闭
+	useCommentConfig(t, &settings.CommentConfig{})
+	assert.NoError(t, checkCommentsOpen(old, now))
+}
+
+func TestCommentUnlockedReopensThread(t *testing.T) {
+	useMySQL(t)
+	useCommentConfig(t, &settings.CommentConfig{CloseAfter: 30})
+	author := createTestUser(t)
+	community := createTestCommunity(t, models.CommunityVisibilityPublic)
+	post := createTestPost(t, author.UserID, community)
+	later := time.Now().Add(31 * 24 * time.Hour)
+
+	stored, err := mysql.GetPostById(post.PostID)
+	require.NoError(t, err)
+	assert.ErrorIs(t, checkCommentsOpen(stored, later), ErrorThreadClosed)
+
+	// 解锁之后不再自动关闭，重新锁定之后不能评论
+	require.NoError(t, mysql.SetCommentLocked(post.PostID, true))
+	require.NoError(t, mysql.SetCommentLocked(post.PostID, false))
+	stored, err = mysql.GetPostById(post.PostID)
+	require.NoError(t, err)
+	assert.True(t, stored.CommentUnlocked)
+	assert.NoError(t, checkCommentsOpen(stored, later))
+	require.NoError(t, mysql.SetCommentLocked(post.PostID, true))
+	stored, err = mysql.GetPostById(post.PostID)
+	require.NoError(t, err)
+	assert.ErrorIs(t, checkCommentsOpen(stored, later), ErrorCommentLocked)
+}
@@ -1,14 +1,379 @@
 package logic
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
 	"go-web-app/models"
+	"go-web-app/settings"
+	"strconv"
+
+	goredis "github.com/go-redis/redis"
+	"go.uber.org/zap"
 )
 
-func GetCommunityList() ([]*models.Community, error) {
-	return mysql.GetCommunityList()
+// GetCommunityList 返回所有社区，并标记userID是否已经加入
+func GetCommunityList(userID int64) ([]*models.Community, error) {
+	list, err := mysql.GetCommunityList()
+	if err != nil {
+		return nil, err
+	}
+	joined, err := mysql.GetJoinedCommunityIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[int64]struct{}, len(joined))
+	for _, id := range joined {
+		set[id] = struct{}{}
+	}
+	for _, c := range list {
+		_, c.IsMember = set[c.ID]
+	}
+	return list, nil
+}
+
+func GetCommunityDetail(ctx context.Context, id int64) (*models.CommunityInfo, error) {
+	detail, err := getCommunityDetail(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	count, err := GetCommunityMemberCount(id)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := mysql.GetCommunityRules(id)
+	if err != nil {
+		return nil, err
+	}
+	return &models.CommunityInfo{
+		CommunityDetail:    detail,
+		MemberCount:        count,
+		RequiredPostLength: requiredPostLength(detail),
+		CommunityRules:     rules,
+	}, nil
 }
 
-func GetCommunityDetail(id int64) (*models.CommunityDetail, error) {
+// CreateCommunity 创建社区，名称已被使用时返回mysql.ErrorCommunityExist
+func CreateCommunity(p *models.ParamCreateCommunity) (*models.CommunityDetail, error) {
+	visibility := p.Visibility
+	if visibility == "" {
+		visibility = models.CommunityVisibilityPublic
+	}
+	id, err := mysql.CreateCommunity(p.Name, p.Introduction, visibility)
+	if err != nil {
+		return nil, err
+	}
 	return mysql.GetCommunityDetailByID(id)
 }
+
+var ErrorKarmaTooLow = errors.New("Karma is too low. ")
+
+// KarmaTooLowError 声望不够创建社区，Required为需要的声望
+type KarmaTooLowError struct {
+	Required int64
+	Current  int64
+}
+
+func (e *KarmaTooLowError) Error() string {
+	return fmt.Sprintf("You need at least %d karma to create a community, you have %d", e.Required, e.Current)
+}
+
+func (e *KarmaTooLowError) Is(target error) bool {
+	return target == ErrorKarmaTooLow
+}
+
+// CheckCreateCommunityKarma 普通用户创建社区前检查声望，不够时返回*KarmaTooLowError
+// 站点管理员和关闭了community.karma_gate时不检查
+func CheckCreateCommunityKarma(userID int64) error {
+	cfg := settings.Current().CommunityConfig
+	if !cfg.KarmaGate || cfg.CreateMinKarma <= 0 {
+		return nil
+	}
+	admin, err := IsAdmin(userID)
+	if err != nil || admin {
+		return err
+	}
+	karma, err := GetKarma(userID)
+	if err != nil {
+		return err
+	}
+	if karma < cfg.CreateMinKarma {
+		return &KarmaTooLowError{Required: cfg.CreateMinKarma, Current: karma}
+	}
+	return nil
+}
+
+// CreateUserCommunity 用户创建社区，创建者加入社区并成为社区管理员
+func CreateUserCommunity(userID int64, p *models.ParamCreateCommunity) (*models.CommunityDetail, error) {
+	detail, err := CreateCommunity(p)
+	if err != nil {
+		return nil, err
+	}
+	_, err = mysql.JoinCommunity(userID, detail.ID, func() error {
+		return redis.IncrCommunityMemberCount(detail.ID, 1)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = mysql.SetCommunityRole(userID, detail.ID, models.CommunityRoleAdmin); err != nil {
+		return nil, err
+	}
+	return detail, nil
+}
+
+// ImportCommunities 批量创建已经通过校验的社区，已经存在的社区跳过，重复导入不会修改已有的社区
+func ImportCommunities(list []*models.ParamCreateCommunity) (ids []int64, created []bool, err error) {
+	if len(list) == 0 {
+		return nil, nil, nil
+	}
+	for _, p := range list {
+		if p.Visibility == "" {
+			p.Visibility = models.CommunityVisibilityPublic
+		}
+	}
+	return mysql.ImportCommunities(list)
+}
+
+// GetCommunityFeed 返回社区下的帖子，社区不存在时返回mysql.ErrorInvalidID，
+// 不是私有社区的成员时返回ErrorPrivateCommunity
+func GetCommunityFeed(ctx context.Context, userID int64, p *models.ParamPostList) ([]*models.PostDetail, bool, error) {
+	community, err := getCommunityDetail(ctx, p.CommunityID)
+	if err != nil {
+		return nil, false, err
+	}
+	if err = checkCommunityAccess(userID, community); err != nil {
+		return nil, false, err
+	}
+	return GetCommunityPostList(ctx, p)
+}
+
+// JoinCommunity 加入社区，重复加入不会报错，社区不存在时返回mysql.ErrorInvalidID
+// 私有社区需要先被邀请，否则返回ErrorJoinApprovalRequired
+func JoinCommunity(ctx context.Context, userID, communityID int64) error {
+	community, err := getCommunityDetail(ctx, communityID)
+	if err != nil {
+		return err
+	}
+	if community.IsPrivate() {
+		member, err := isCommunityMember(userID, communityID)
+		if err != nil || member {
+			return err
+		}
+		joined, err := acceptInvite(userID, communityID)
+		if err != nil {
+			return err
+		}
+		if !joined {
+			return ErrorJoinApprovalRequired
+		}
+		return nil
+	}
+	joined, err := mysql.JoinCommunity(userID, communityID, func() error {
+		return redis.IncrCommunityMemberCount(communityID, 1)
+	})
+	if joined {
+		recordCommunityJoin(ctx, communityID)
+	}
+	return err
+}
+
+// LeaveCommunity 退出社区，未加入时不会报错
+func LeaveCommunity(userID, communityID int64) error {
+	_, err := mysql.LeaveCommunity(userID, communityID, func() error {
+		return redis.IncrCommunityMemberCount(communityID, -1)
+	})
+	return err
+}
+
+// GetCommunityRole 返回用户在社区中的角色，站点管理员视为所有社区的管理员
+func GetCommunityRole(userID, communityID int64) (int8, error) {
+	admin, err := IsAdmin(userID)
+	if err != nil {
+		return models.CommunityRoleNone, err
+	}
+	if admin {
+		return models.CommunityRoleAdmin, nil
+	}
+	return mysql.GetCommunityRole(userID, communityID)
+}
+
+// PromoteModerator 把社区成员设为版主，社区管理员保持不变
+func PromoteModerator(communityID, userID int64) error {
+	role, err := mysql.GetCommunityRole(userID, communityID)
+	if err != nil {
+		return err
+	}
+	if role >= models.CommunityRoleModerator {
+		return nil
+	}
+	return mysql.SetCommunityRole(userID, communityID, models.CommunityRoleModerator)
+}
+
+// DemoteModerator 把版主降为普通成员，不是版主时不做任何修改
+func DemoteModerator(communityID, userID int64) error {
+	role, err := mysql.GetCommunityRole(userID, communityID)
+	if err != nil {
+		return err
+	}
+	if role != models.CommunityRoleModerator {
+		return nil
+	}
+	return mysql.SetCommunityRole(userID, communityID, models.CommunityRoleMember)
+}
+
+var ErrorLastCommunityAdmin = errors.New("A community must keep at least one admin. ")
+
+// PromoteCommunityAdmin 把社区成员设为社区管理员
+func PromoteCommunityAdmin(communityID, userID int64) error {
+	role, err := mysql.GetCommunityRole(userID, communityID)
+	if err != nil {
+		return err
+	}
+	if role == models.CommunityRoleAdmin {
+		return nil
+	}
+	return mysql.SetCommunityRole(userID, communityID, models.CommunityRoleAdmin)
+}
+
+// DemoteCommunityAdmin 把社区管理员降为普通成员，社区至少保留一个管理员
+func DemoteCommunityAdmin(communityID, userID int64) error {
+	role, err := mysql.GetCommunityRole(userID, communityID)
+	if err != nil {
+		return err
+	}
+	if role != models.CommunityRoleAdmin {
+		return nil
+	}
+	count, err := mysql.CountCommunityAdmins(communityID)
+	if err != nil {
+		return err
+	}
+	if count <= 1 {
+		return ErrorLastCommunityAdmin
+	}
+	return mysql.SetCommunityRole(userID, communityID, models.CommunityRoleMember)
+}
+
+// getCommunityPost 返回属于该社区的帖子，其他社区的帖子视为不存在，
+// 避免版主通过自己的社区去管理别的社区的帖子
+func getCommunityPost(communityID, pid int64) (*models.Post, error) {
+	post, err := mysql.GetPostById(pid)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, mysql.ErrorInvalidID
+		}
+		return nil, err
+	}
+	in, err := mysql.IsPostInCommunity(pid, communityID)
+	if err != nil {
+		return nil, err
+	}
+	if !in {
+		return nil, mysql.ErrorInvalidID
+	}
+	return post, nil
+}
+
+// RemoveCommunityPost 版主删除本社区的帖子
+// 同时发布到其他社区的帖子只从本社区移除，其他社区不受影响
+func RemoveCommunityPost(communityID, pid int64) error {
+	post, err := getCommunityPost(communityID, pid)
+	if err != nil {
+		return err
+	}
+	if err = fillPostCommunities(post); err != nil {
+		return err
+	}
+	if remaining := diffIDs(post.CommunityIDs, []int64{communityID}); len(remaining) > 0 {
+		if err = mysql.SetPostCommunities(pid, remaining); err != nil {
+			return err
+		}
+		if err = redis.RemovePostFromCommunities(pid, []int64{communityID}); err != nil {
+			return err
+		}
+		invalidatePostDetail(pid)
+		return nil
+	}
+	if err = mysql.DeletePost(pid); err != nil {
+		return err
+	}
+	removePostFromTags(pid)
+	removePostFromCommunities(post)
+	invalidatePostDetail(pid)
+	return nil
+}
+
+// SetCommentLocked 版主锁定或解锁本社区帖子的评论
+func SetCommentLocked(communityID, pid int64, locked bool) error {
+	if _, err := getCommunityPost(communityID, pid); err != nil {
+		return err
+	}
+	if err := mysql.SetCommentLocked(pid, locked); err != nil {
+		return err
+	}
+	invalidatePostDetail(pid)
+	return nil
+}
+
+// ErrorTooManyPinned 社区置顶的帖子达到上限
+var ErrorTooManyPinned = errors.New("Too many pinned posts in this community. ")
+
+// PinPost 版主在本社区置顶帖子，只影响该社区的帖子列表
+func PinPost(communityID, pid int64) error {
+	if _, err := getCommunityPost(communityID, pid); err != nil {
+		return err
+	}
+	ok, err := redis.PinPost(communityID, pid, settings.Current().CommunityConfig.MaxPinned)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrorTooManyPinned
+	}
+	return nil
+}
+
+// UnpinPost 版主取消置顶本社区的帖子
+func UnpinPost(communityID, pid int64) error {
+	if _, err := getCommunityPost(communityID, pid); err != nil {
+		return err
+	}
+	return redis.UnpinPost(communityID, pid)
+}
+
+// markPinned 标记帖子列表中置顶的帖子
+func markPinned(data []*models.PostDetail, pinned []string) {
+	set := make(map[int64]struct{}, len(pinned))
+	for _, id := range pinned {
+		if pid, err := strconv.ParseInt(id, 10, 64); err == nil {
+			set[pid] = struct{}{}
+		}
+	}
+	for _, d := range data {
+		if _, ok := set[d.PostID]; ok {
+			d.Pinned = true
+		}
+	}
+}
+
+// GetCommunityMemberCount 优先读redis中的计数，不存在时从数据库统计并写回redis
+func GetCommunityMemberCount(communityID int64) (int64, error) {
+	count, err := redis.GetCommunityMemberCount(communityID)
+	if err == nil {
+		return count, nil
+	}
+	if err != goredis.Nil {
+		return 0, err
+	}
+	count, err = mysql.GetCommunityMemberCount(communityID)
+	if err != nil {
+		return 0, err
+	}
+	if err := redis.SetCommunityMemberCount(communityID, count); err != nil {
+		zap.L().Warn("redis.SetCommunityMemberCount failed", zap.Int64("community_id", communityID), zap.Error(err))
+	}
+	return count, nil
+}
@@ -0,0 +1,220 @@
+package logic
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+)
+
+var (
+	ErrorPrivateCommunity     = errors.New("This community is private. ")
+	ErrorJoinApprovalRequired = errors.New("Joining this community requires an invite or approval. ")
+	ErrorPrivateCrossPost     = errors.New("Posts in a private community cannot be posted to other communities. ")
+	ErrorNoJoinRequest        = errors.New("Join request does not exist or is already handled. ")
+	ErrorPostNotAllowed       = errors.New("You are not allowed to post in this community. ")
+)
+
+// isCommunityMember 站点管理员视为所有社区的成员
+func isCommunityMember(userID, communityID int64) (bool, error) {
+	role, err := GetCommunityRole(userID, communityID)
+	if err != nil {
+		return false, err
+	}
+	return role >= models.CommunityRoleMember, nil
+}
+
+// checkCommunityAccess 私有社区只有成员可以访问，否则返回ErrorPrivateCommunity
+func checkCommunityAccess(userID int64, community *models.CommunityDetail) error {
+	if !community.IsPrivate() {
+		return nil
+	}
+	member, err := isCommunityMember(userID, community.ID)
+	if err != nil {
+		return err
+	}
+	if !member {
+		return ErrorPrivateCommunity
+	}
+	return nil
+}
+
+// CheckPostAccess 私有社区的帖子只有成员可以查看和评论
+// 帖子详情的缓存中可能是修改可见性之前的社区，所以重新读取社区信息
+func CheckPostAccess(ctx context.Context, userID int64, post *models.Post) error {
+	community, err := getCommunityDetail(ctx, post.CommunityID)
+	if err != nil {
+		return err
+	}
+	return checkCommunityAccess(userID, community)
+}
+
+// CheckPostIDAccess 同CheckPostAccess，帖子不存在时不返回错误，由调用方按原来的逻辑处理
+func CheckPostIDAccess(ctx context.Context, userID, pid int64) error {
+	post, err := mysql.GetPostById(pid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return CheckPostAccess(ctx, userID, post)
+}
+
+// filterPublicPosts 全站的列表中去掉私有社区的帖子
+func filterPublicPosts(data []*models.PostDetail) []*models.PostDetail {
+	res := data[:0]
+	for _, d := range data {
+		if d.CommunityDetail == nil || !d.CommunityDetail.IsPrivate() {
+			res = append(res, d)
+		}
+	}
+	return res
+}
+
+// filterVisiblePosts 去掉userID不是成员的私有社区的帖子
+func filterVisiblePosts(userID int64, data []*models.PostDetail) ([]*models.PostDetail, error) {
+	member := make(map[int64]bool)
+	res := data[:0]
+	for _, d := range data {
+		if d.CommunityDetail != nil && d.CommunityDetail.IsPrivate() {
+			ok, checked := member[d.CommunityDetail.ID]
+			if !checked {
+				var err error
+				if ok, err = isCommunityMember(userID, d.CommunityDetail.ID); err != nil {
+					return nil, err
+				}
+				member[d.CommunityDetail.ID] = ok
+			}
+			if !ok {
+				continue
+			}
+		}
+		res = append(res, d)
+	}
+	return res, nil
+}
+
+// SetCommunityVisibility 社区管理员修改社区的可见性，已有的帖子按新的可见性处理
+func SetCommunityVisibility(communityID int64, visibility string) error {
+	if err := mysql.SetCommunityVisibility(communityID, visibility); err != nil {
+		return err
+	}
+	InvalidateCommunityCache(communityID)
+	return nil
+}
+
+// SetCommunityMinPostLength 版主修改社区中帖子内容的最少字符数，已有的帖子不受影响
+func SetCommunityMinPostLength(communityID int64, length int) error {
+	if err := mysql.SetCommunityMinPostLength(communityID, length); err != nil {
+		return err
+	}
+	InvalidateCommunityCache(communityID)
+	return nil
+}
+
+// SetCommunityPostPermission 社区管理员修改谁可以在社区中发帖，已有的帖子不受影响
+func SetCommunityPostPermission(communityID int64, permission string) error {
+	if err := mysql.SetCommunityPostPermission(communityID, permission); err != nil {
+		return err
+	}
+	InvalidateCommunityCache(communityID)
+	return nil
+}
+
+// RequestJoinCommunity 申请加入社区，公开社区和已被邀请时直接加入，已经是成员时不做修改
+func RequestJoinCommunity(ctx context.Context, userID, communityID int64) (*models.JoinResult, error) {
+	community, err := getCommunityDetail(ctx, communityID)
+	if err != nil {
+		return nil, err
+	}
+	if !community.IsPrivate() {
+		if err = JoinCommunity(ctx, userID, communityID); err != nil {
+			return nil, err
+		}
+		return &models.JoinResult{Joined: true}, nil
+	}
+	role, err := mysql.GetCommunityRole(userID, communityID)
+	if err != nil {
+		return nil, err
+	}
+	if role >= models.CommunityRoleMember {
+		return &models.JoinResult{Joined: true}, nil
+	}
+	joined, err := acceptInvite(userID, communityID)
+	if err != nil || joined {
+		return &models.JoinResult{Joined: joined}, err
+	}
+	if err = mysql.SaveJoinRequest(communityID, userID, models.JoinRequestPending, 0); err != nil {
+		return nil, err
+	}
+	return &models.JoinResult{Status: models.JoinRequestPending}, nil
+}
+
+// acceptInvite 有邀请时加入社区，返回是否加入
+func acceptInvite(userID, communityID int64) (bool, error) {
+	return mysql.ApproveJoinRequest(communityID, userID, models.JoinRequestInvited, 0, func() error {
+		return redis.IncrCommunityMemberCount(communityID, 1)
+	})
+}
+
+// InviteToCommunity 版主邀请用户加入私有社区，用户已经申请时直接通过
+func InviteToCommunity(communityID, moderatorID, userID int64) error {
+	if _, err := mysql.GetUserByID(userID); err != nil {
+		return err
+	}
+	role, err := mysql.GetCommunityRole(userID, communityID)
+	if err != nil || role >= models.CommunityRoleMember {
+		return err
+	}
+	approved, err := approveJoinRequest(communityID, moderatorID, userID)
+	if err != nil || approved {
+		return err
+	}
+	return mysql.SaveJoinRequest(communityID, userID, models.JoinRequestInvited, moderatorID)
+}
+
+// ApproveJoinRequest 版主通过加入申请，没有申请中的请求时返回ErrorNoJoinRequest
+func ApproveJoinRequest(communityID, moderatorID, userID int64) error {
+	approved, err := approveJoinRequest(communityID, moderatorID, userID)
+	if err != nil {
+		return err
+	}
+	if !approved {
+		return ErrorNoJoinRequest
+	}
+	return nil
+}
+
+func approveJoinRequest(communityID, moderatorID, userID int64) (bool, error) {
+	return mysql.ApproveJoinRequest(communityID, userID, models.JoinRequestPending, moderatorID, func() error {
+		return redis.IncrCommunityMemberCount(communityID, 1)
+	})
+}
+
+// DenyJoinRequest 版主拒绝加入申请，用户之后可以重新申请
+func DenyJoinRequest(communityID, moderatorID, userID int64) error {
+	changed, err := mysql.DenyJoinRequest(communityID, userID, moderatorID)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		return ErrorNoJoinRequest
+	}
+	return nil
+}
+
+// GetJoinRequests 社区中等待处理的加入申请
+func GetJoinRequests(communityID, page, size int64) ([]*models.JoinRequest, int64, error) {
+	total, err := mysql.CountPendingJoinRequests(communityID)
+	if err != nil {
+		return nil, 0, err
+	}
+	list, err := mysql.GetPendingJoinRequests(communityID, page, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
@@ -0,0 +1,54 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrivateCommentVoteNonMember(t *testing.T) {
+	useMySQL(t)
+	useMongo(t)
+	useMiniredis(t)
+
+	author, outsider := createTestUser(t), createTestUser(t)
+	post := createTestPost(t, author.UserID, createTestCommunity(t, models.CommunityVisibilityPrivate))
+	comment := createTestComment(t, post.PostID, author.UserID, time.Now())
+
+	err := VoteForComment(context.Background(), outsider.UserID, comment.CommentID, &models.ParamCommentVote{Direction: 1})
+	assert.ErrorIs(t, err, ErrorPrivateCommunity)
+	// 被拒绝的票没有写入redis
+	up, _, err := redis.GetCommentVoteCounts([]string{strconv.FormatInt(comment.CommentID, 10)})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{0}, up)
+}
+
+func TestPrivatePostNonMember(t *testing.T) {
+	useMySQL(t)
+	useMiniredis(t)
+	old := settings.Conf.PostConfig
+	settings.Conf.PostConfig = &settings.PostConfig{VoteWindow: 24 * 7}
+	defer func() { settings.Conf.PostConfig = old }()
+
+	author, outsider := createTestUser(t), createTestUser(t)
+	post := createTestPost(t, author.UserID, createTestCommunity(t, models.CommunityVisibilityPrivate))
+
+	_, err := GetPostHistory(context.Background(), outsider.UserID, post.PostID)
+	assert.ErrorIs(t, err, ErrorPrivateCommunity)
+
+	err = VoteForPost(context.Background(), outsider.UserID, &models.ParamVoteData{PostId: strconv.FormatInt(post.PostID, 10), Direction: 1})
+	assert.ErrorIs(t, err, ErrorPrivateCommunity)
+
+	_, err = VotePoll(context.Background(), outsider.UserID, post.PostID, 0)
+	assert.ErrorIs(t, err, ErrorPrivateCommunity)
+
+	require.ErrorIs(t, VoteForPost(context.Background(), outsider.UserID, &models.ParamVoteData{PostId: "abc", Direction: 1}), mysql.ErrorInvalidID)
+}
@@ -0,0 +1,166 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+// communityCacheLookups 按结果统计社区信息的查询次数，local和redis为命中，mysql为未命中
+var communityCacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "community_cache_lookups_total",
+	Help: "Number of community lookups by where they were served from.",
+}, []string{"source"})
+
+func init() {
+	prometheus.MustRegister(communityCacheLookups)
+}
+
+type communityCacheEntry struct {
+	detail   *models.CommunityDetail
+	expireAt time.Time
+}
+
+// communityCache 进程内的社区信息缓存，社区数量少且很少修改，过期之前不检查变化
+// 缓存的对象会被多个请求共享，调用方不能修改
+var communityCache = struct {
+	sync.RWMutex
+	entries map[int64]communityCacheEntry
+}{entries: make(map[int64]communityCacheEntry)}
+
+// communityLoadGroup 同一批社区同时未命中时只查询一次redis和mysql
+var communityLoadGroup singleflight.Group
+
+// GetCommunitiesByIDs 批量查询社区信息，依次读取进程内缓存、redis和mysql，不存在的社区不在结果中
+func GetCommunitiesByIDs(ctx context.Context, ids []int64) (map[int64]*models.CommunityDetail, error) {
+	ttl := time.Duration(settings.Current().CommunityConfig.CacheTTL) * time.Second
+	if ttl <= 0 {
+		return mysql.GetCommunityDetailsByIDs(ctx, ids)
+	}
+	communities := make(map[int64]*models.CommunityDetail, len(ids))
+	var missing []int64
+	now := time.Now()
+	communityCache.RLock()
+	for _, id := range ids {
+		if _, ok := communities[id]; ok {
+			continue
+		}
+		if e, ok := communityCache.entries[id]; ok && now.Before(e.expireAt) {
+			communities[id] = e.detail
+			continue
+		}
+		missing = append(missing, id)
+	}
+	communityCache.RUnlock()
+	communityCacheLookups.WithLabelValues("local").Add(float64(len(communities)))
+	if len(missing) == 0 {
+		return communities, nil
+	}
+	missing = uniqueIDs(missing)
+	// 多个请求共享同一次查询，不随其中某一个请求取消，避免它取消后其他请求一起失败
+	v, err, _ := communityLoadGroup.Do(idsKey(missing), func() (interface{}, error) {
+		return loadCommunities(detachContext(ctx), missing, ttl)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for id, detail := range v.(map[int64]*models.CommunityDetail) {
+		communities[id] = detail
+	}
+	return communities, nil
+}
+
+// getCommunityDetail 查询一个社区，不存在时返回mysql.ErrorInvalidID
+func getCommunityDetail(ctx context.Context, id int64) (*models.CommunityDetail, error) {
+	communities, err := GetCommunitiesByIDs(ctx, []int64{id})
+	if err != nil {
+		return nil, err
+	}
+	detail, ok := communities[id]
+	if !ok {
+		return nil, mysql.ErrorInvalidID
+	}
+	return detail, nil
+}
+
+// loadCommunities 从redis和mysql中读取进程内没有缓存的社区，并写回两级缓存
+// redis出错时直接查询mysql
+func loadCommunities(ctx context.Context, ids []int64, ttl time.Duration) (map[int64]*models.CommunityDetail, error) {
+	communities, err := redis.GetCommunityDetailCaches(ids)
+	if err != nil {
+		zap.L().Warn("redis.GetCommunityDetailCaches failed", zap.Error(err))
+		communities = make(map[int64]*models.CommunityDetail, len(ids))
+	}
+	communityCacheLookups.WithLabelValues("redis").Add(float64(len(communities)))
+	var missing []int64
+	for _, id := range ids {
+		if _, ok := communities[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		communityCacheLookups.WithLabelValues("mysql").Add(float64(len(missing)))
+		loaded, err := mysql.GetCommunityDetailsByIDs(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		if err = redis.SetCommunityDetailCaches(loaded, ttl); err != nil {
+			zap.L().Warn("redis.SetCommunityDetailCaches failed", zap.Error(err))
+		}
+		for id, detail := range loaded {
+			communities[id] = detail
+		}
+	}
+	expireAt := time.Now().Add(ttl)
+	communityCache.Lock()
+	for id, detail := range communities {
+		communityCache.entries[id] = communityCacheEntry{detail: detail, expireAt: expireAt}
+	}
+	communityCache.Unlock()
+	return communities, nil
+}
+
+// InvalidateCommunityCache 社区信息修改后调用，其他实例的进程内缓存在cache_ttl之后过期
+// 热门社区的缓存中包含社区信息，一起清除
+func InvalidateCommunityCache(ids ...int64) {
+	BustResponseCache(CacheTrendingCommunities)
+	communityCache.Lock()
+	for _, id := range ids {
+		delete(communityCache.entries, id)
+	}
+	communityCache.Unlock()
+	if err := redis.DeleteCommunityDetailCaches(ids...); err != nil {
+		zap.L().Error("redis.DeleteCommunityDetailCaches failed", zap.Int64s("ids", ids), zap.Error(err))
+	}
+}
+
+// uniqueIDs 去重并排序，相同的一批社区得到相同的singleflight的key
+func uniqueIDs(ids []int64) []int64 {
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	unique := ids[:0]
+	for i, id := range ids {
+		if i == 0 || id != ids[i-1] {
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+func idsKey(ids []int64) string {
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, strconv.FormatInt(id, 10))
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,52 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCommunitiesByIDsCached(t *testing.T) {
+	mr := useMiniredis(t)
+	useCommunityConfig(t, &settings.CommunityConfig{CacheTTL: 60})
+	const goID, rustID = 1 << 50, 1<<50 + 1
+	t.Cleanup(func() { InvalidateCommunityCache(goID, rustID) })
+	require.NoError(t, redis.SetCommunityDetailCaches(map[int64]*models.CommunityDetail{
+		goID:   {ID: goID, Name: "go"},
+		rustID: {ID: rustID, Name: "rust"},
+	}, time.Minute))
+
+	communities, err := GetCommunitiesByIDs(context.Background(), []int64{rustID, goID, rustID})
+	require.NoError(t, err)
+	require.Len(t, communities, 2)
+	assert.Equal(t, "go", communities[goID].Name)
+
+	// redis中读到的社区写入进程内缓存，之后不再读取redis
+	mr.Close()
+	detail, err := getCommunityDetail(context.Background(), rustID)
+	require.NoError(t, err)
+	assert.Equal(t, "rust", detail.Name)
+}
+
+func TestGetCommunitiesByIDsRedisDown(t *testing.T) {
+	useMySQL(t)
+	mr := useMiniredis(t)
+	useCommunityConfig(t, &settings.CommunityConfig{CacheTTL: 60})
+	id := createTestCommunity(t, models.CommunityVisibilityPublic)
+	t.Cleanup(func() { InvalidateCommunityCache(id) })
+	mr.Close()
+
+	// redis出错时直接查询mysql，不存在的社区返回ErrorInvalidID
+	detail, err := getCommunityDetail(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, id, detail.ID)
+	_, err = getCommunityDetail(context.Background(), 1<<60)
+	assert.ErrorIs(t, err, mysql.ErrorInvalidID)
+}
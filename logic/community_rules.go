@@ -0,0 +1,49 @@
+package logic
+
+import (
+	"errors"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+)
+
+var ErrorInvalidFlair = errors.New("Flair is not allowed in this community. ")
+
+// GetCommunityRules 社区的规则和允许的flair
+func GetCommunityRules(communityID int64) (*models.CommunityRules, error) {
+	return mysql.GetCommunityRules(communityID)
+}
+
+// SetCommunityRules 版主修改社区的规则和允许的flair，已有帖子的flair不受影响
+func SetCommunityRules(communityID int64, p *models.ParamCommunityRules) error {
+	flairs := p.Flairs
+	if flairs == nil {
+		flairs = []string{}
+	}
+	return mysql.SetCommunityRules(communityID, &models.CommunityRules{Rules: p.Rules, Flairs: flairs})
+}
+
+// checkPostFlair flair必须是主社区允许的flair之一，区分大小写
+func checkPostFlair(communityID int64, flair string) error {
+	if flair == "" {
+		return nil
+	}
+	rules, err := mysql.GetCommunityRules(communityID)
+	if err != nil {
+		return err
+	}
+	for _, f := range rules.Flairs {
+		if f == flair {
+			return nil
+		}
+	}
+	return ErrorInvalidFlair
+}
+
+// addPostFlair 帖子加入列表时记录flair，用于按flair筛选社区的帖子
+func addPostFlair(post *models.Post) error {
+	if post.Flair == "" {
+		return nil
+	}
+	return redis.AddPostFlair(post.CommunityID, post.PostID, post.Flair)
+}
@@ -0,0 +1,52 @@
+package logic
+
+import (
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommunityRulesFlair(t *testing.T) {
+	useMySQL(t)
+	community := createTestCommunity(t, models.CommunityVisibilityPublic)
+	rules, err := GetCommunityRules(community)
+	require.NoError(t, err)
+	assert.Equal(t, &models.CommunityRules{Flairs: []string{}}, rules)
+	// 没有设置flair时帖子不能带flair
+	assert.ErrorIs(t, checkPostFlair(community, "Question"), ErrorInvalidFlair)
+
+	require.NoError(t, SetCommunityRules(community, &models.ParamCommunityRules{Rules: "be nice", Flairs: []string{"Question", "News"}}))
+	rules, err = GetCommunityRules(community)
+	require.NoError(t, err)
+	assert.Equal(t, &models.CommunityRules{Rules: "be nice", Flairs: []string{"Question", "News"}}, rules)
+	assert.NoError(t, checkPostFlair(community, "Question"))
+	assert.NoError(t, checkPostFlair(community, ""))
+	// 区分大小写
+	assert.ErrorIs(t, checkPostFlair(community, "question"), ErrorInvalidFlair)
+
+	require.NoError(t, SetCommunityRules(community, &models.ParamCommunityRules{}))
+	rules, err = GetCommunityRules(community)
+	require.NoError(t, err)
+	assert.Empty(t, rules.Flairs)
+
+	_, err = GetCommunityRules(1 << 60)
+	assert.ErrorIs(t, err, mysql.ErrorInvalidID)
+	assert.ErrorIs(t, checkPostFlair(1<<60, "Question"), mysql.ErrorInvalidID)
+}
+
+func TestAddPostFlair(t *testing.T) {
+	mr := useMiniredis(t)
+	require.NoError(t, addPostFlair(&models.Post{PostID: 1, CommunityID: 7, Flair: "News"}))
+	members, err := mr.SMembers(redis.Prefix + redis.KeyCommunityFlairSetPF + "7:News")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1"}, members)
+
+	// 没有flair的帖子不写入redis
+	mr.Close()
+	assert.NoError(t, addPostFlair(&models.Post{PostID: 2, CommunityID: 7}))
+	assert.Error(t, addPostFlair(&models.Post{PostID: 3, CommunityID: 7, Flair: "News"}))
+}
@@ -0,0 +1,93 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommunityAdminRole(t *testing.T) {
+	useMySQL(t)
+	useMiniredis(t)
+	owner, member, outsider := createTestUser(t), createTestUser(t), createTestUser(t)
+	cid := createTestCommunity(t, models.CommunityVisibilityPublic)
+	for _, u := range []*models.User{owner, member} {
+		_, err := mysql.JoinCommunity(u.UserID, cid, func() error { return nil })
+		require.NoError(t, err)
+	}
+	require.NoError(t, mysql.SetCommunityRole(owner.UserID, cid, models.CommunityRoleAdmin))
+
+	// 不是成员时不能设为管理员
+	assert.ErrorIs(t, PromoteCommunityAdmin(cid, outsider.UserID), mysql.ErrorNotMember)
+
+	require.NoError(t, PromoteCommunityAdmin(cid, member.UserID))
+	role, err := mysql.GetCommunityRole(member.UserID, cid)
+	require.NoError(t, err)
+	assert.Equal(t, models.CommunityRoleAdmin, role)
+
+	require.NoError(t, DemoteCommunityAdmin(cid, owner.UserID))
+	role, err = mysql.GetCommunityRole(owner.UserID, cid)
+	require.NoError(t, err)
+	assert.Equal(t, models.CommunityRoleMember, role)
+
+	// 最后一个管理员不能被撤销
+	assert.ErrorIs(t, DemoteCommunityAdmin(cid, member.UserID), ErrorLastCommunityAdmin)
+	role, err = mysql.GetCommunityRole(member.UserID, cid)
+	require.NoError(t, err)
+	assert.Equal(t, models.CommunityRoleAdmin, role)
+}
+
+func TestJoinLeaveCommunityMemberCount(t *testing.T) {
+	useMySQL(t)
+	useMiniredis(t)
+	useCommunityConfig(t, &settings.CommunityConfig{})
+	ctx := context.Background()
+	a, b := createTestUser(t), createTestUser(t)
+	cid := createTestCommunity(t, models.CommunityVisibilityPublic)
+
+	// 计数不存在时从数据库统计
+	require.NoError(t, JoinCommunity(ctx, a.UserID, cid))
+	count, err := GetCommunityMemberCount(cid)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, count)
+
+	// 重复加入不重复计数
+	require.NoError(t, JoinCommunity(ctx, a.UserID, cid))
+	require.NoError(t, JoinCommunity(ctx, b.UserID, cid))
+	count, err = GetCommunityMemberCount(cid)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, count)
+
+	require.NoError(t, LeaveCommunity(a.UserID, cid))
+	require.NoError(t, LeaveCommunity(a.UserID, cid))
+	count, err = GetCommunityMemberCount(cid)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, count)
+
+	assert.ErrorIs(t, JoinCommunity(ctx, a.UserID, -1), mysql.ErrorInvalidID)
+}
+
+func TestGetCommunityMemberCountRedisError(t *testing.T) {
+	mr := useMiniredis(t)
+	require.NoError(t, redis.SetCommunityMemberCount(7, 3))
+	count, err := GetCommunityMemberCount(7)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, count)
+
+	// redis出错时不从数据库重建计数
+	mr.Close()
+	_, err = GetCommunityMemberCount(7)
+	assert.Error(t, err)
+}
+
+func useCommunityConfig(t *testing.T, cfg *settings.CommunityConfig) {
+	old := settings.Conf.CommunityConfig
+	settings.Conf.CommunityConfig = cfg
+	t.Cleanup(func() { settings.Conf.CommunityConfig = old })
+}
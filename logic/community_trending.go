@@ -0,0 +1,82 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// recordCommunityActivity 社区的活跃度加weight，私有社区不出现在热门社区中，不计数，失败只记录日志
+func recordCommunityActivity(ctx context.Context, ids []int64, weight int64) {
+	cfg := settings.Current().CommunityConfig
+	if cfg.TrendingWindow < 1 || weight == 0 || len(ids) == 0 {
+		return
+	}
+	communities, err := GetCommunitiesByIDs(ctx, ids)
+	if err != nil {
+		zap.L().Error("GetCommunitiesByIDs failed", zap.Int64s("community_ids", ids), zap.Error(err))
+		return
+	}
+	public := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if c, ok := communities[id]; ok && !c.IsPrivate() {
+			public = append(public, id)
+		}
+	}
+	window := time.Duration(cfg.TrendingWindow) * time.Hour
+	if err := redis.IncrCommunityActivity(public, weight, window); err != nil {
+		zap.L().Error("redis.IncrCommunityActivity failed", zap.Int64s("community_ids", public), zap.Error(err))
+	}
+}
+
+// recordCommunityPost 帖子发布到的每个社区都计一次新帖子
+func recordCommunityPost(ctx context.Context, ids []int64) {
+	recordCommunityActivity(ctx, ids, settings.Current().CommunityConfig.TrendingPostWeight)
+}
+
+// recordCommunityJoin 新成员加入社区
+func recordCommunityJoin(ctx context.Context, communityID int64) {
+	recordCommunityActivity(ctx, []int64{communityID}, settings.Current().CommunityConfig.TrendingJoinWeight)
+}
+
+// GetTrendingCommunities 最近一段时间内新帖子和新成员最多的社区，附带当前的成员数
+// 统计之后改为私有的社区不返回，这时返回的社区可能少于community.trending_size
+func GetTrendingCommunities(ctx context.Context) ([]*models.TrendingCommunity, error) {
+	cfg := settings.Current().CommunityConfig
+	if cfg.TrendingWindow < 1 || cfg.TrendingSize < 1 {
+		return []*models.TrendingCommunity{}, nil
+	}
+	ttl := time.Duration(cfg.TrendingCacheTTL) * time.Second
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+	list, err := redis.GetTrendingCommunities(cfg.TrendingWindow, cfg.TrendingSize, ttl)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(list))
+	for _, t := range list {
+		ids = append(ids, t.ID)
+	}
+	communities, err := GetCommunitiesByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*models.TrendingCommunity, 0, len(list))
+	for _, t := range list {
+		c, ok := communities[t.ID]
+		if !ok || c.IsPrivate() {
+			continue
+		}
+		if t.MemberCount, err = GetCommunityMemberCount(t.ID); err != nil {
+			return nil, err
+		}
+		t.Name = c.Name
+		res = append(res, t)
+	}
+	return res, nil
+}
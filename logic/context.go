@@ -0,0 +1,24 @@
+package logic
+
+import (
+	"context"
+	"time"
+)
+
+// detachedContext 保留ctx中的值(trace、request id等)，但不随ctx取消，也没有截止时间
+// 用于被多个请求共享或在请求结束后继续执行的加载，go1.21之后可以换成context.WithoutCancel
+type detachedContext struct {
+	parent context.Context
+}
+
+func detachContext(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return detachedContext{ctx}
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
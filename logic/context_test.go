@@ -0,0 +1,53 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/settings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCtxKey struct{}
+
+func TestDetachContext(t *testing.T) {
+	parent, cancel := context.WithTimeout(context.WithValue(context.Background(), testCtxKey{}, "v"), time.Minute)
+	ctx := detachContext(parent)
+	cancel()
+
+	assert.Error(t, parent.Err())
+	assert.NoError(t, ctx.Err())
+	assert.Nil(t, ctx.Done())
+	_, ok := ctx.Deadline()
+	assert.False(t, ok)
+	assert.Equal(t, "v", ctx.Value(testCtxKey{}))
+}
+
+func TestCachedResponseContext(t *testing.T) {
+	old := settings.Conf.ResponseCacheConfig
+	settings.Conf.ResponseCacheConfig = &settings.ResponseCacheConfig{
+		Size:      16,
+		TTL:       60,
+		Endpoints: map[string]*settings.ResponseCachePolicy{"test_uncached": {}},
+	}
+	t.Cleanup(func() { settings.Conf.ResponseCacheConfig = old })
+	parent, cancel := context.WithCancel(context.WithValue(context.Background(), testCtxKey{}, "v"))
+	cancel()
+	load := func(ctx context.Context) (interface{}, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return ctx.Value(testCtxKey{}), nil
+	}
+
+	// 缓存的结果会被其他请求共享，加载时不随请求取消
+	v, err := CachedResponse(parent, "test_cached", "k", load)
+	require.NoError(t, err)
+	assert.Equal(t, "v", v)
+
+	// 不缓存的接口直接使用请求的ctx
+	_, err = CachedResponse(parent, "test_uncached", "k", load)
+	assert.ErrorIs(t, err, context.Canceled)
+}
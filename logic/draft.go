@@ -0,0 +1,43 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"go-web-app/dao/mysql"
+	"go-web-app/models"
+)
+
+var ErrorNotDraft = errors.New("Post is not a draft. ")
+
+// GetDrafts 作者自己的草稿
+func GetDrafts(userID int64) ([]*models.Post, error) {
+	posts, err := mysql.GetDraftPosts(userID)
+	if err != nil {
+		return nil, err
+	}
+	fillPostTags(posts...)
+	return posts, nil
+}
+
+// PublishDraft 发布草稿，只有作者可以发布
+func PublishDraft(ctx context.Context, userID, pid int64) error {
+	post, err := mysql.GetPostById(pid)
+	if err != nil {
+		return err
+	}
+	if post.AuthorId != userID {
+		return ErrorNoPermission
+	}
+	if post.Status != models.PostStatusDraft {
+		return ErrorNotDraft
+	}
+	published, err := publishPost(ctx, post, models.PostStatusDraft)
+	if err != nil {
+		return err
+	}
+	if !published {
+		// 同时发布了两次，另一个请求已经完成
+		return ErrorNotDraft
+	}
+	return nil
+}
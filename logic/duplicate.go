@@ -0,0 +1,107 @@
+package logic
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/pkg/markdown"
+	"go-web-app/settings"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var ErrorDuplicatePost = errors.New("You have already posted this recently. ")
+
+// DuplicatePostError 同一个用户在post.duplicate_window内发布了相同的内容，PostID为之前的帖子
+type DuplicatePostError struct {
+	PostID int64
+}
+
+func (e *DuplicatePostError) Error() string {
+	return ErrorDuplicatePost.Error()
+}
+
+func (e *DuplicatePostError) Is(target error) bool {
+	return target == ErrorDuplicatePost
+}
+
+// postDigests 标题和正文规范化之后的摘要，正文中有外部链接时再加上链接的摘要，
+// 这样换了标题重复分享同一个链接也能发现
+func postDigests(p *models.Post) []string {
+	digests := []string{digest("text:" + normalizeText(p.Title) + "\n" + normalizeText(p.Content))}
+	if _, link := markdown.FirstMedia(p.Content); link != "" {
+		if u := normalizeLink(link); u != "" {
+			digests = append(digests, digest("link:"+u))
+		}
+	}
+	return digests
+}
+
+func digest(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeText 忽略大小写和空白的差异
+func normalizeText(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+// normalizeLink 忽略协议、主机名的大小写、www前缀、锚点、末尾的斜杠和utm_开头的跟踪参数
+func normalizeLink(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	query := u.Query()
+	for k := range query {
+		if strings.HasPrefix(strings.ToLower(k), "utm_") {
+			query.Del(k)
+		}
+	}
+	s := host + strings.TrimRight(u.EscapedPath(), "/")
+	if q := query.Encode(); q != "" {
+		s += "?" + q
+	}
+	return s
+}
+
+// checkDuplicatePost 同一个用户最近发布过相同内容时返回DuplicatePostError；
+// 开启了post.duplicate_cross_user时，其他用户最近发布的相同内容的帖子记录在p.DuplicateOf中
+func checkDuplicatePost(p *models.Post) ([]string, error) {
+	cfg := settings.Current().PostConfig
+	if cfg.DuplicateWindow <= 0 || p.Status == models.PostStatusDraft {
+		return nil, nil
+	}
+	digests := postDigests(p)
+	own, other, err := redis.FindDuplicatePost(p.AuthorId, digests)
+	if err != nil {
+		// 检查失败不影响发帖
+		zap.L().Warn("redis.FindDuplicatePost failed", zap.Int64("userID", p.AuthorId), zap.Error(err))
+		return digests, nil
+	}
+	if own != 0 {
+		return nil, &DuplicatePostError{PostID: own}
+	}
+	if cfg.DuplicateCrossUser {
+		p.DuplicateOf = other
+	}
+	return digests, nil
+}
+
+// recordPostDigests 帖子创建成功后记录摘要，记录失败只影响之后的检查
+func recordPostDigests(p *models.Post, digests []string) {
+	window := time.Duration(settings.Current().PostConfig.DuplicateWindow) * time.Hour
+	if len(digests) == 0 || window <= 0 {
+		return
+	}
+	if err := redis.RecordPostDigests(p.AuthorId, p.PostID, digests, window); err != nil {
+		zap.L().Warn("redis.RecordPostDigests failed", zap.Int64("pid", p.PostID), zap.Error(err))
+	}
+}
@@ -0,0 +1,129 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/pkg/email"
+	"go-web-app/pkg/snowflake"
+	"go-web-app/settings"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// queueEmail 把邮件加入发送队列，由StartEmailWorker按配置的频率发送，失败时记录日志并返回错误
+func queueEmail(to, subject, body string) error {
+	return queueEmailAt(to, subject, body, time.Time{})
+}
+
+// queueEmailAt at不为零值时在at之后才加入发送队列
+func queueEmailAt(to, subject, body string, at time.Time) error {
+	id, err := snowflake.TryGenID()
+	if err != nil {
+		zap.L().Error("queue email failed", zap.String("subject", subject), zap.Error(err))
+		return err
+	}
+	job := &models.EmailJob{
+		ID:         strconv.FormatInt(id, 10),
+		To:         to,
+		Subject:    subject,
+		Body:       body,
+		CreateTime: time.Now(),
+	}
+	if at.IsZero() {
+		err = redis.EnqueueEmail(job)
+	} else {
+		err = redis.ScheduleEmail(job, at)
+	}
+	if err != nil {
+		zap.L().Error("queue email failed", zap.String("subject", subject), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// StartEmailWorker 在后台按email.rate_per_minute发送队列中的邮件，返回的函数用于退出时停止
+// 频率限制是每个实例单独计算的，多实例部署时需要按实例数调小
+func StartEmailWorker() (stop func(ctx context.Context) error) {
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		rate := settings.Current().EmailConfig.RatePerMinute
+		if rate <= 0 {
+			rate = 60
+		}
+		ticker := time.NewTicker(time.Minute / time.Duration(rate))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				sendNextEmail()
+			case <-quit:
+				return
+			}
+		}
+	}()
+	return func(ctx context.Context) error {
+		close(quit)
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// sendNextEmail 发送队列中的一封邮件，到时间的重试邮件先放回队列
+func sendNextEmail() {
+	if _, err := redis.PromoteDueEmails(time.Now()); err != nil {
+		zap.L().Error("redis.PromoteDueEmails failed", zap.Error(err))
+	}
+	job, err := redis.PopEmail()
+	if err != nil {
+		zap.L().Error("redis.PopEmail failed", zap.Error(err))
+		return
+	}
+	if job == nil {
+		return
+	}
+	err = email.Send(job.To, job.Subject, job.Body)
+	if err == nil {
+		return
+	}
+	job.Attempts++
+	job.LastError = err.Error()
+	cfg := settings.Current().EmailConfig
+	if email.IsPermanent(err) || job.Attempts >= cfg.MaxAttempts {
+		zap.L().Error("email dropped to dead letter list",
+			zap.String("id", job.ID), zap.String("subject", job.Subject), zap.Int("attempts", job.Attempts), zap.Error(err))
+		if err := redis.DeadLetterEmail(job); err != nil {
+			zap.L().Error("redis.DeadLetterEmail failed", zap.String("id", job.ID), zap.Error(err))
+		}
+		return
+	}
+	wait := retryBackoff(time.Duration(cfg.RetryBackoff)*time.Second, job.Attempts)
+	zap.L().Warn("send email failed, will retry",
+		zap.String("id", job.ID), zap.Int("attempts", job.Attempts), zap.Duration("wait", wait), zap.Error(err))
+	if err := redis.RetryEmail(job, time.Now().Add(wait)); err != nil {
+		zap.L().Error("redis.RetryEmail failed", zap.String("id", job.ID), zap.Error(err))
+	}
+}
+
+// retryBackoff 第attempts次失败之后的等待时间，每次翻倍，最多等待一天，邮件和webhook共用
+func retryBackoff(base time.Duration, attempts int) time.Duration {
+	if base <= 0 {
+		base = 30 * time.Second
+	}
+	wait := base
+	for i := 1; i < attempts && wait < 24*time.Hour; i++ {
+		wait *= 2
+	}
+	if wait > 24*time.Hour {
+		wait = 24 * time.Hour
+	}
+	return wait
+}
@@ -0,0 +1,175 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"go-web-app/dao/mongodb"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/pkg/email"
+	"go-web-app/settings"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	digestJobName = "email:digest"
+	// digestBatch 每次查询多少个用户的邮箱
+	digestBatch = 200
+	// digestLockTTL 发送过程中会自动续期，实例退出时锁在这段时间之后失效
+	digestLockTTL   = time.Minute
+	digestDayLayout = "20060102"
+)
+
+// digestSections 摘要中每一行统计的通知类型，其他类型只计入总数
+var digestSections = []struct {
+	types []string
+	label string
+}{
+	{[]string{models.NotificationReply}, "new replies"},
+	{[]string{models.NotificationMentionPost, models.NotificationMentionComment}, "mentions"},
+	{[]string{models.NotificationVote}, "upvotes on your posts"},
+	{[]string{models.NotificationFollow}, "new followers"},
+	{[]string{models.NotificationTrendingTag}, "hot posts in tags you follow"},
+}
+
+// StartDigestJob 在后台每分钟检查一次，到了email.digest_time之后发送当天的未读通知摘要，返回的函数用于退出时停止
+func StartDigestJob() (stop func(ctx context.Context) error) {
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				at, ok := digestDue(time.Now(), settings.Current().EmailConfig.DigestTime)
+				if !ok {
+					continue
+				}
+				if err := runDigest(at); err != nil {
+					zap.L().Error("send digest failed", zap.Error(err))
+				}
+			case <-quit:
+				return
+			}
+		}
+	}()
+	return func(ctx context.Context) error {
+		close(quit)
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// digestDue 返回今天的发送时间，还没到时间或没有配置时ok为false
+func digestDue(now time.Time, sendTime string) (at time.Time, ok bool) {
+	if sendTime == "" {
+		return at, false
+	}
+	t, err := time.Parse("15:04", sendTime)
+	if err != nil {
+		zap.L().Warn("invalid email.digest_time", zap.String("digest_time", sendTime))
+		return at, false
+	}
+	at = time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+	return at, !now.Before(at)
+}
+
+// runDigest 发送at之前一天内的未读通知摘要，只有一个实例会执行
+// 每个用户发送前先在redis中记录，重启或失败后再次执行时不会重复发送
+func runDigest(at time.Time) error {
+	day := at.Format(digestDayLayout)
+	if finished, err := redis.IsDigestDone(day); err != nil || finished {
+		return err
+	}
+	lock, ok, err := redis.AcquireLock(context.Background(), digestJobName, digestLockTTL)
+	if err != nil || !ok {
+		return err
+	}
+	ctx, cancel := lock.AutoRenewContext(context.Background())
+	defer cancel()
+	defer func() {
+		if err := redis.ReleaseLock(context.Background(), lock); err != nil {
+			zap.L().Warn("redis.ReleaseLock failed", zap.String("key", digestJobName), zap.Error(err))
+		}
+	}()
+	// 获得锁之前其他实例可能刚刚发送完
+	if finished, err := redis.IsDigestDone(day); err != nil || finished {
+		return err
+	}
+
+	counts, err := mongodb.GetUnreadNotificationCounts(at.Add(-24*time.Hour), at)
+	if err != nil {
+		return err
+	}
+	ids := make([]int64, 0, len(counts))
+	for uid := range counts {
+		ids = append(ids, uid)
+	}
+	sent := 0
+	for start := 0; start < len(ids); start += digestBatch {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := start + digestBatch
+		if end > len(ids) {
+			end = len(ids)
+		}
+		users, err := mysql.GetDigestRecipients(ids[start:end])
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			added, err := redis.MarkDigestSent(day, user.UserID)
+			if err != nil {
+				return err
+			}
+			if !added {
+				continue
+			}
+			subject, body := renderDigest(user, counts[user.UserID])
+			// 免打扰期间的摘要在结束后发送
+			at := quietHoursEnd(user.UserID, quietHoursDigest, time.Now())
+			if err = queueEmailAt(user.Email, subject, body, at); err != nil {
+				if e := redis.UnmarkDigestSent(day, user.UserID); e != nil {
+					zap.L().Error("redis.UnmarkDigestSent failed", zap.Int64("uid", user.UserID), zap.Error(e))
+				}
+				return err
+			}
+			sent++
+		}
+	}
+	zap.L().Info("digest sent", zap.String("day", day), zap.Int("recipients", sent))
+	return redis.SetDigestDone(day)
+}
+
+// renderDigest 摘要邮件的标题和正文
+func renderDigest(user *models.User, counts map[string]int64) (subject, body string) {
+	var total int64
+	for _, n := range counts {
+		total += n
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Hi %s,\n\nYou have %d unread notifications from the last day:\n\n", displayName(user), total)
+	for _, section := range digestSections {
+		var n int64
+		for _, typ := range section.types {
+			n += counts[typ]
+		}
+		if n > 0 {
+			fmt.Fprintf(&b, "- %d %s\n", n, section.label)
+		}
+	}
+	b.WriteString("\nSee them at " + email.Link("/notifications", nil) + "\n\n")
+	b.WriteString("You can turn off this daily email in your account settings.\n")
+	return fmt.Sprintf("You have %d unread notifications", total), b.String()
+}
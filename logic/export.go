@@ -0,0 +1,254 @@
+package logic
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"go-web-app/dao/mongodb"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"io"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var ErrorExportTooFrequent = errors.New("Data was exported recently. ")
+
+const (
+	// exportRunTimeout 超过这个时间没有结束的导出不再阻止用户重新导出
+	exportRunTimeout   = 30 * time.Minute
+	exportCleanJobName = "export:clean"
+	exportCleanLockTTL = time.Minute
+)
+
+// StartExport 在后台导出用户的帖子、评论和账号信息，返回的token用于查询状态和下载
+// 导出成功之后才开始计算export.interval，正在导出或者最近导出过时返回ErrorExportTooFrequent
+func StartExport(userID int64, p *models.ParamExport) (*models.ExportJob, error) {
+	cfg := settings.Current().ExportConfig
+	ok, err := redis.TryStartExport(userID, exportRunTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrorExportTooFrequent
+	}
+	job, err := newExportJob(userID, p, time.Duration(cfg.TTL)*time.Hour)
+	if err != nil {
+		finishExport(userID, false)
+		return nil, err
+	}
+	go runExport(job)
+	return job, nil
+}
+
+func newExportJob(userID int64, p *models.ParamExport, ttl time.Duration) (*models.ExportJob, error) {
+	token, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	job := &models.ExportJob{
+		Token:      token,
+		UserID:     userID,
+		Status:     models.ExportStatusPending,
+		CSV:        p.CSV,
+		CreateTime: now,
+		ExpireTime: now.Add(ttl),
+	}
+	if err = redis.SetExportJob(job, ttl); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// GetExport 查询导出的状态，已完成时返回压缩包和文件的大小，调用方负责关闭
+// 导出不存在、已过期或者不属于当前用户时返回mysql.ErrorInvalidID
+func GetExport(userID int64, token string) (job *models.ExportJob, file io.ReadCloser, size int64, err error) {
+	job, err = redis.GetExportJob(token)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if job == nil || job.UserID != userID {
+		return nil, nil, 0, mysql.ErrorInvalidID
+	}
+	if job.Status != models.ExportStatusReady {
+		return job, nil, 0, nil
+	}
+	stream, size, err := mongodb.OpenExportFile(token)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if stream == nil {
+		return nil, nil, 0, mysql.ErrorInvalidID
+	}
+	return job, stream, size, nil
+}
+
+// runExport 压缩包完整写入GridFS之后才标记为完成，失败的导出不占用导出的次数
+func runExport(job *models.ExportJob) {
+	status := models.ExportStatusReady
+	err := writeExport(job)
+	if err != nil {
+		zap.L().Error("export user data failed", zap.Int64("userID", job.UserID), zap.Error(err))
+		status = models.ExportStatusFailed
+	}
+	finishExport(job.UserID, err == nil)
+	if err := redis.UpdateExportStatus(job.Token, status); err != nil {
+		zap.L().Error("redis.UpdateExportStatus failed", zap.String("status", status), zap.Error(err))
+	}
+}
+
+func finishExport(userID int64, success bool) {
+	interval := time.Duration(settings.Current().ExportConfig.Interval) * time.Hour
+	if err := redis.FinishExport(userID, success, interval); err != nil {
+		zap.L().Error("redis.FinishExport failed", zap.Int64("userID", userID), zap.Bool("success", success), zap.Error(err))
+	}
+}
+
+func writeExport(job *models.ExportJob) error {
+	data, err := collectExport(job.UserID)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	err = writeExportJSON(zw, data)
+	if err == nil && job.CSV {
+		err = writeExportCSV(zw, data)
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		return err
+	}
+	return mongodb.SaveExportFile(job.Token, job.UserID, job.ExpireTime, buf.Bytes())
+}
+
+func collectExport(userID int64) (*models.UserExport, error) {
+	profile, err := mysql.GetExportProfile(userID)
+	if err != nil {
+		return nil, err
+	}
+	posts, err := mysql.GetPostsByAuthor(userID)
+	if err != nil {
+		return nil, err
+	}
+	fillPostTags(posts...)
+	comments, err := mongodb.GetCommentsByAuthor(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.UserExport{
+		Profile:    profile,
+		Posts:      posts,
+		Comments:   comments,
+		ExportTime: time.Now(),
+	}, nil
+}
+
+func writeExportJSON(zw *zip.Writer, data *models.UserExport) error {
+	w, err := zw.Create("data.json")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(data)
+}
+
+// writeExportCSV 帖子和评论各一个CSV文件，账号信息只在data.json中
+func writeExportCSV(zw *zip.Writer, data *models.UserExport) error {
+	rows := [][]string{{"post_id", "title", "content", "community_id", "status", "create_time"}}
+	for _, p := range data.Posts {
+		rows = append(rows, []string{
+			strconv.FormatInt(p.PostID, 10), p.Title, p.Content, strconv.FormatInt(p.CommunityID, 10),
+			strconv.FormatInt(int64(p.Status), 10), p.CreateTime.Format(time.RFC3339),
+		})
+	}
+	if err := writeCSV(zw, "posts.csv", rows); err != nil {
+		return err
+	}
+	rows = [][]string{{"comment_id", "post_id", "parent_id", "content", "create_time"}}
+	for _, c := range data.Comments {
+		rows = append(rows, []string{
+			strconv.FormatInt(c.CommentID, 10), strconv.FormatInt(c.PostID, 10),
+			strconv.FormatInt(c.ParentID, 10), c.Content, c.CreateTime.Format(time.RFC3339),
+		})
+	}
+	return writeCSV(zw, "comments.csv", rows)
+}
+
+func writeCSV(zw *zip.Writer, name string, rows [][]string) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err = cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// StartExportCleanup 定期删除过期的导出文件，返回的函数用于退出时停止
+func StartExportCleanup() (stop func(ctx context.Context) error) {
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		interval := time.Duration(settings.Current().ExportConfig.CleanInterval) * time.Second
+		if interval <= 0 {
+			interval = 10 * time.Minute
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := cleanExpiredExports(); err != nil {
+					zap.L().Error("clean expired exports failed", zap.Error(err))
+				}
+			case <-quit:
+				return
+			}
+		}
+	}()
+	return func(ctx context.Context) error {
+		close(quit)
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// cleanExpiredExports 只有一个实例会执行
+func cleanExpiredExports() error {
+	lock, ok, err := redis.AcquireLock(context.Background(), exportCleanJobName, exportCleanLockTTL)
+	if err != nil || !ok {
+		return err
+	}
+	ctx, cancel := lock.AutoRenewContext(context.Background())
+	defer cancel()
+	defer func() {
+		if err := redis.ReleaseLock(context.Background(), lock); err != nil {
+			zap.L().Warn("redis.ReleaseLock failed", zap.String("key", exportCleanJobName), zap.Error(err))
+		}
+	}()
+	n, err := mongodb.CleanExpiredExportFiles(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	zap.L().Debug("clean expired exports", zap.Int("files", n))
+	return nil
+}
@@ -0,0 +1,52 @@
+package logic
+
+import (
+	"archive/zip"
+	"bytes"
+	"go-web-app/dao/mysql"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunExport(t *testing.T) {
+	useMySQL(t)
+	useMongo(t)
+	useMiniredis(t)
+	old := settings.Conf.ExportConfig
+	settings.Conf.ExportConfig = &settings.ExportConfig{TTL: 1, Interval: 24}
+	t.Cleanup(func() { settings.Conf.ExportConfig = old })
+	user := createTestUser(t)
+	createTestPost(t, user.UserID, createTestCommunity(t, models.CommunityVisibilityPublic))
+
+	job, err := newExportJob(user.UserID, &models.ParamExport{CSV: true}, time.Hour)
+	require.NoError(t, err)
+	runExport(job)
+
+	got, file, size, err := GetExport(user.UserID, job.Token)
+	require.NoError(t, err)
+	require.NotNil(t, file)
+	defer file.Close()
+	assert.Equal(t, models.ExportStatusReady, got.Status)
+	b, err := ioutil.ReadAll(file)
+	require.NoError(t, err)
+	assert.Equal(t, size, int64(len(b)))
+	zr, err := zip.NewReader(bytes.NewReader(b), size)
+	require.NoError(t, err)
+	names := make([]string, 0, len(zr.File))
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{"data.json", "posts.csv", "comments.csv"}, names)
+
+	// 成功之后才计算导出的间隔，其他用户不能下载
+	_, err = StartExport(user.UserID, &models.ParamExport{})
+	assert.ErrorIs(t, err, ErrorExportTooFrequent)
+	_, _, _, err = GetExport(createTestUser(t).UserID, job.Token)
+	assert.ErrorIs(t, err, mysql.ErrorInvalidID)
+}
@@ -0,0 +1,117 @@
+package logic
+
+import (
+	"errors"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var ErrorInvalidFeatureFlag = errors.New("Feature flag names may only contain lowercase letters, digits, '_', '-' and '.'. ")
+
+// featureFlagName 开关的名字，例如 new_feed
+var featureFlagName = regexp.MustCompile(`^[a-z0-9_.-]{1,64}$`)
+
+// featureCacheTTL 开关在进程内缓存一小段时间，修改后其他实例最多延迟这么久生效
+const featureCacheTTL = 10 * time.Second
+
+var featureCache struct {
+	sync.Mutex
+	flags    map[string]*models.FeatureFlag
+	expireAt time.Time
+}
+
+// getFeatureFlag 读取缓存的开关，不存在时返回nil
+// 读取redis失败时沿用上一次的开关，避免redis抖动时功能被开关
+func getFeatureFlag(name string) *models.FeatureFlag {
+	featureCache.Lock()
+	defer featureCache.Unlock()
+	now := time.Now()
+	if !now.Before(featureCache.expireAt) {
+		flags, err := redis.GetFeatureFlags()
+		if err != nil {
+			zap.L().Error("redis.GetFeatureFlags failed", zap.Error(err))
+		} else {
+			featureCache.flags = flags
+		}
+		featureCache.expireAt = now.Add(featureCacheTTL)
+	}
+	return featureCache.flags[name]
+}
+
+// IsEnabled 功能对userID是否开启，不存在的开关视为关闭
+// 部分开启时按开关名和用户id的哈希分组，同一个用户的结果不变；未登录的用户(userID为0)只在全部开启时可用
+func IsEnabled(flag string, userID int64) bool {
+	f := getFeatureFlag(flag)
+	if f == nil || !f.Enabled {
+		return false
+	}
+	if f.Percent >= 100 {
+		return true
+	}
+	if userID == 0 || f.Percent <= 0 {
+		return false
+	}
+	return featureBucket(flag, userID) < f.Percent
+}
+
+// featureBucket 用户在开关中的分组，取值为[0, 100)，不同开关的分组互不相关
+func featureBucket(flag string, userID int64) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(flag + ":" + strconv.FormatInt(userID, 10)))
+	return int(h.Sum32() % 100)
+}
+
+// GetFeatureFlags 所有开关，按名字排序，直接读取redis
+func GetFeatureFlags() ([]*models.FeatureFlag, error) {
+	flags, err := redis.GetFeatureFlags()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*models.FeatureFlag, 0, len(flags))
+	for _, f := range flags {
+		list = append(list, f)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// SetFeatureFlag 创建或修改开关，当前实例立即生效
+func SetFeatureFlag(name string, p *models.ParamFeatureFlag) (*models.FeatureFlag, error) {
+	if !featureFlagName.MatchString(name) {
+		return nil, ErrorInvalidFeatureFlag
+	}
+	f := &models.FeatureFlag{Name: name, Enabled: *p.Enabled, Percent: 100, UpdateTime: time.Now()}
+	if p.Percent != nil {
+		f.Percent = *p.Percent
+	}
+	if err := redis.SetFeatureFlag(f); err != nil {
+		return nil, err
+	}
+	expireFeatureCache()
+	zap.L().Warn("feature flag set", zap.String("name", name), zap.Bool("enabled", f.Enabled), zap.Int("percent", f.Percent))
+	return f, nil
+}
+
+// DeleteFeatureFlag 删除开关，当前实例立即生效
+func DeleteFeatureFlag(name string) error {
+	if err := redis.DeleteFeatureFlag(name); err != nil {
+		return err
+	}
+	expireFeatureCache()
+	zap.L().Warn("feature flag deleted", zap.String("name", name))
+	return nil
+}
+
+func expireFeatureCache() {
+	featureCache.Lock()
+	featureCache.expireAt = time.Time{}
+	featureCache.Unlock()
+}
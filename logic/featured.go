@@ -0,0 +1,106 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrorTooManyFeatured 推荐的社区达到community.max_featured
+var ErrorTooManyFeatured = errors.New("Too many featured communities. ")
+
+// GetFeaturedCommunities 首页推荐的社区，按管理员设置的顺序，已经不存在的社区会被跳过
+func GetFeaturedCommunities(ctx context.Context) ([]*models.CommunityInfo, error) {
+	ids, err := getFeaturedCommunityIDs()
+	if err != nil {
+		return nil, err
+	}
+	communities, err := GetCommunitiesByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*models.CommunityInfo, 0, len(ids))
+	for _, id := range ids {
+		detail, ok := communities[id]
+		if !ok {
+			continue
+		}
+		count, err := GetCommunityMemberCount(id)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, &models.CommunityInfo{CommunityDetail: detail, MemberCount: count})
+	}
+	return list, nil
+}
+
+// getFeaturedCommunityIDs 优先读redis中的缓存，缓存时间与社区信息相同
+func getFeaturedCommunityIDs() ([]int64, error) {
+	ttl := time.Duration(settings.Current().CommunityConfig.CacheTTL) * time.Second
+	if ttl <= 0 {
+		return mysql.GetFeaturedCommunityIDs()
+	}
+	ids, ok, err := redis.GetFeaturedCommunityCache()
+	if err != nil {
+		zap.L().Warn("redis.GetFeaturedCommunityCache failed", zap.Error(err))
+	}
+	if ok {
+		return ids, nil
+	}
+	if ids, err = mysql.GetFeaturedCommunityIDs(); err != nil {
+		return nil, err
+	}
+	if err := redis.SetFeaturedCommunityCache(ids, ttl); err != nil {
+		zap.L().Warn("redis.SetFeaturedCommunityCache failed", zap.Error(err))
+	}
+	return ids, nil
+}
+
+// invalidateFeaturedCommunities 推荐列表修改后删除缓存
+func invalidateFeaturedCommunities() {
+	if err := redis.DeleteFeaturedCommunityCache(); err != nil {
+		zap.L().Error("redis.DeleteFeaturedCommunityCache failed", zap.Error(err))
+	}
+}
+
+// AddFeaturedCommunity 把社区加到推荐列表的最后，已经推荐时不做修改
+func AddFeaturedCommunity(communityID int64) error {
+	added, full, err := mysql.AddFeaturedCommunity(communityID, settings.Current().CommunityConfig.MaxFeatured)
+	if err != nil {
+		return err
+	}
+	if full {
+		return ErrorTooManyFeatured
+	}
+	if added {
+		invalidateFeaturedCommunities()
+	}
+	return nil
+}
+
+// RemoveFeaturedCommunity 取消推荐，不在列表中时不报错
+func RemoveFeaturedCommunity(communityID int64) error {
+	removed, err := mysql.RemoveFeaturedCommunity(communityID)
+	if err != nil {
+		return err
+	}
+	if removed {
+		invalidateFeaturedCommunities()
+	}
+	return nil
+}
+
+// ReorderFeaturedCommunities 按ids的顺序重新排列推荐的社区
+func ReorderFeaturedCommunities(ids []int64) error {
+	if err := mysql.ReorderFeaturedCommunities(ids); err != nil {
+		return err
+	}
+	invalidateFeaturedCommunities()
+	return nil
+}
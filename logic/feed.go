@@ -0,0 +1,92 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/pkg/snowflake"
+	"strconv"
+)
+
+// GetHomeFeed 关注用户和加入的社区的最新帖子，读时合并(fan-out-on-read)。
+// 新用户没有关注任何人时返回活跃社区的热门帖子，既没有关注用户也没有加入社区时返回全站热门帖子。
+func GetHomeFeed(ctx context.Context, userID int64, p *models.ParamFeed) (*models.PostFeed, error) {
+	followees, err := mysql.GetFollowingIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(followees) == 0 && isNewUser(userID) {
+		return getOnboardingFeed(ctx, p)
+	}
+	communities, err := mysql.GetJoinedCommunityIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(followees) == 0 && len(communities) == 0 {
+		return getHotFeed(ctx, p)
+	}
+	cursor := feedCursor(p)
+	ids, err := redis.GetFeedPostIDs(followees, communities, cursor, p.Size)
+	if err != nil {
+		return nil, err
+	}
+	feed, err := buildPostFeed(ctx, ids, p.Size)
+	if err != nil {
+		return nil, err
+	}
+	// 关注的用户在私有社区的帖子只有同是成员时可见，游标仍然按过滤之前的最后一个帖子计算
+	if feed.List, err = filterVisiblePosts(userID, feed.List); err != nil {
+		return nil, err
+	}
+	return feed, nil
+}
+
+// feedCursor 返回分页的起点id，没有cursor时用before换算出对应的id
+func feedCursor(p *models.ParamFeed) int64 {
+	if p.Cursor == 0 && !p.Before.IsZero() {
+		if id := snowflake.MinID(p.Before); id > 0 {
+			return id
+		}
+		// 早于snowflake的起始时间，不会有任何帖子；cursor为0表示从最新的开始，所以返回1
+		return 1
+	}
+	return p.Cursor
+}
+
+func getHotFeed(ctx context.Context, p *models.ParamFeed) (*models.PostFeed, error) {
+	feed := &models.PostFeed{List: make([]*models.PostDetail, 0)}
+	// 热门列表不支持游标，只返回第一页
+	if feedCursor(p) != 0 {
+		return feed, nil
+	}
+	data, _, err := GetPostList2(ctx, &models.ParamPostList{Page: 1, Size: p.Size, Order: models.OrderHot})
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		feed.List = data
+	}
+	return feed, nil
+}
+
+func buildPostFeed(ctx context.Context, ids []int64, size int64) (*models.PostFeed, error) {
+	feed := &models.PostFeed{List: make([]*models.PostDetail, 0)}
+	if len(ids) == 0 {
+		return feed, nil
+	}
+	posts, err := mysql.GetPostsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	data, err := getPostDetailList(ctx, posts)
+	if err != nil {
+		return nil, err
+	}
+	feed.List = data
+	// 取满一页时才可能有下一页，游标使用本页最后一个帖子的id
+	if int64(len(ids)) == size {
+		feed.NextCursor = strconv.FormatInt(ids[len(ids)-1], 10)
+	}
+	return feed, nil
+}
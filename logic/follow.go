@@ -0,0 +1,91 @@
+package logic
+
+import (
+	"database/sql"
+	"errors"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+
+	goredis "github.com/go-redis/redis"
+	"go.uber.org/zap"
+)
+
+var ErrorFollowSelf = errors.New("Can't follow yourself. ")
+
+// Follow 关注用户，重复关注不会报错
+func Follow(userID, targetID int64) error {
+	if userID == targetID {
+		return ErrorFollowSelf
+	}
+	target, err := mysql.GetUserByID(targetID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return mysql.ErrorUserNotExist
+		}
+		return err
+	}
+	if target.Deactivated {
+		return mysql.ErrorUserNotExist
+	}
+	changed, err := mysql.Follow(userID, targetID, func() error {
+		return redis.IncrFollowCount(userID, targetID, 1)
+	})
+	if err != nil {
+		return err
+	}
+	if changed {
+		notify(targetID, userID, models.NotificationFollow, userID, true)
+	}
+	return nil
+}
+
+// Unfollow 取消关注，未关注时不会报错
+func Unfollow(userID, targetID int64) error {
+	if userID == targetID {
+		return ErrorFollowSelf
+	}
+	_, err := mysql.Unfollow(userID, targetID, func() error {
+		return redis.IncrFollowCount(userID, targetID, -1)
+	})
+	return err
+}
+
+// GetFollowers 粉丝列表，总数使用缓存的计数
+func GetFollowers(uid, page, size int64) ([]*models.FollowUser, int64, error) {
+	count, err := GetFollowCount(uid)
+	if err != nil {
+		return nil, 0, err
+	}
+	users, err := mysql.GetFollowers(uid, page, size)
+	return users, count.Followers, err
+}
+
+// GetFollowing 关注列表，总数使用缓存的计数
+func GetFollowing(uid, page, size int64) ([]*models.FollowUser, int64, error) {
+	count, err := GetFollowCount(uid)
+	if err != nil {
+		return nil, 0, err
+	}
+	users, err := mysql.GetFollowing(uid, page, size)
+	return users, count.Following, err
+}
+
+// GetFollowCount 优先读redis中的计数，不存在时从数据库统计并写回redis
+func GetFollowCount(uid int64) (*models.FollowCount, error) {
+	count, err := redis.GetFollowCount(uid)
+	if err == nil {
+		return count, nil
+	}
+	if err != goredis.Nil {
+		return nil, err
+	}
+	count, err = mysql.GetFollowCount(uid)
+	if err != nil {
+		return nil, err
+	}
+	if err := redis.SetFollowCount(uid, count); err != nil {
+		zap.L().Warn("redis.SetFollowCount failed", zap.Int64("uid", uid), zap.Error(err))
+	}
+	return count, nil
+}
@@ -0,0 +1,57 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/dao/mongodb"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const readinessTimeout = 2 * time.Second
+
+const (
+	DependencyUp   = "up"
+	DependencyDown = "down"
+)
+
+// CheckReadiness 并发检查各个依赖是否可用，返回每个依赖的状态以及是否全部可用
+func CheckReadiness(ctx context.Context) (status map[string]string, ready bool) {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	checks := map[string]func(context.Context) error{
+		"mysql":   mysql.Ping,
+		"redis":   redis.Ping,
+		"mongodb": mongodb.Ping,
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	status = make(map[string]string, len(checks))
+	ready = true
+	for name, ping := range checks {
+		wg.Add(1)
+		go func(name string, ping func(context.Context) error) {
+			defer wg.Done()
+			state := DependencyUp
+			if err := ping(ctx); err != nil {
+				zap.L().Warn("readiness check failed", zap.String("dependency", name), zap.Error(err))
+				state = DependencyDown
+			}
+			mu.Lock()
+			status[name] = state
+			if state != DependencyUp {
+				ready = false
+			}
+			mu.Unlock()
+		}(name, ping)
+	}
+	wg.Wait()
+	return
+}
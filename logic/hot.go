@@ -0,0 +1,76 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/dao/redis"
+	"go-web-app/settings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	hotRefreshJobName = "post:hot:refresh"
+	hotRefreshLockTTL = time.Minute
+)
+
+// StartHotRefresh 定期按帖子的年龄重新计算热度，返回的函数用于退出时停止
+func StartHotRefresh() (stop func(ctx context.Context) error) {
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		interval := time.Duration(settings.Current().PostConfig.HotRefreshInterval) * time.Second
+		if interval <= 0 {
+			interval = 5 * time.Minute
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := refreshHotScores(); err != nil {
+					zap.L().Error("refresh hot scores failed", zap.Error(err))
+				}
+			case <-quit:
+				return
+			}
+		}
+	}()
+	return func(ctx context.Context) error {
+		close(quit)
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// refreshHotScores 只有一个实例会执行。超过post.vote_window的帖子不会再有投票，
+// 离开窗口前最后一次计算的热度已经衰减得很低，不再重新计算
+func refreshHotScores() error {
+	lock, ok, err := redis.AcquireLock(context.Background(), hotRefreshJobName, hotRefreshLockTTL)
+	if err != nil || !ok {
+		return err
+	}
+	ctx, cancel := lock.AutoRenewContext(context.Background())
+	defer cancel()
+	defer func() {
+		if err := redis.ReleaseLock(context.Background(), lock); err != nil {
+			zap.L().Warn("redis.ReleaseLock failed", zap.String("key", hotRefreshJobName), zap.Error(err))
+		}
+	}()
+	cfg := settings.Current().PostConfig
+	var since time.Time
+	if cfg.VoteWindow > 0 {
+		since = time.Now().Add(-time.Duration(cfg.VoteWindow) * time.Hour)
+	}
+	n, err := redis.RefreshHotScores(ctx, since, cfg.HotGravity)
+	if err != nil {
+		return err
+	}
+	zap.L().Debug("refresh hot scores", zap.Int("posts", n))
+	return nil
+}
@@ -0,0 +1,55 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreatePostIdempotentRetry(t *testing.T) {
+	useMiniredis(t)
+	old := settings.Conf.PostConfig
+	settings.Conf.PostConfig = &settings.PostConfig{IdempotencyTTL: 24}
+	t.Cleanup(func() { settings.Conf.PostConfig = old })
+	p := &models.Post{AuthorId: 1, CommunityID: 2, Title: "title", Content: "content"}
+	fingerprint := postFingerprint(p)
+
+	// 第一次的请求还在处理中
+	reserved, _, _, err := redis.ReserveIdempotencyKey(1, "k", fingerprint, time.Hour)
+	require.NoError(t, err)
+	require.True(t, reserved)
+	_, err = CreatePostIdempotent(context.Background(), p, "k")
+	assert.ErrorIs(t, err, ErrorIdempotencyConflict)
+
+	// 第一次已经创建成功时直接返回之前的帖子id，不再创建
+	require.NoError(t, redis.SetIdempotencyResult(1, "k", fingerprint, 42, time.Hour))
+	postID, err := CreatePostIdempotent(context.Background(), p, "k")
+	require.NoError(t, err)
+	assert.EqualValues(t, 42, postID)
+	assert.Zero(t, p.PostID)
+
+	// 同一个键对应不同的内容
+	changed := *p
+	changed.Title = "changed"
+	_, err = CreatePostIdempotent(context.Background(), &changed, "k")
+	assert.ErrorIs(t, err, ErrorIdempotencyConflict)
+}
+
+func TestCreatePostIdempotentRedisDown(t *testing.T) {
+	mr := useMiniredis(t)
+	old := settings.Conf.PostConfig
+	settings.Conf.PostConfig = &settings.PostConfig{IdempotencyTTL: 24}
+	t.Cleanup(func() { settings.Conf.PostConfig = old })
+	mr.Close()
+
+	p := &models.Post{AuthorId: 1, CommunityID: 2, Title: "title", Content: "content"}
+	_, err := CreatePostIdempotent(context.Background(), p, "k")
+	assert.Error(t, err)
+	assert.Zero(t, p.PostID)
+}
@@ -0,0 +1,130 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/settings"
+	"time"
+
+	goredis "github.com/go-redis/redis"
+	"go.uber.org/zap"
+)
+
+const (
+	karmaPersistBatch = 500 // 每次最多写回多少个用户的声望
+	karmaRecentDays   = 7   // 最近几天的排行榜统计的天数
+)
+
+// karmaBucketTTL 按天的分桶在统计窗口之后自动过期
+const karmaBucketTTL = (karmaRecentDays + 1) * 24 * time.Hour
+
+// updateKarma 用户的投票从oldValue改为newValue时作者声望的变化，给自己投票不计入声望
+// 失败时只记录日志，不影响投票本身
+func updateKarma(authorID, voterID, weight int64, oldValue, newValue float64) {
+	if authorID == voterID || authorID == 0 {
+		return
+	}
+	delta := int64(newValue-oldValue) * weight
+	if delta == 0 {
+		return
+	}
+	_, err := redis.IncrKarma(authorID, delta, nil, karmaBucketTTL)
+	if err == goredis.Nil {
+		// redis中没有这个用户，从数据库中保存的值开始计数
+		var base int64
+		if base, err = mysql.GetUserKarma(authorID); err == nil {
+			_, err = redis.IncrKarma(authorID, delta, &base, karmaBucketTTL)
+		}
+	}
+	if err != nil {
+		zap.L().Error("update karma failed", zap.Int64("uid", authorID), zap.Int64("delta", delta), zap.Error(err))
+	}
+}
+
+// GetKarma 用户的声望，redis中没有时读取数据库
+func GetKarma(uid int64) (int64, error) {
+	karma, err := redis.GetKarma(uid)
+	if err == goredis.Nil {
+		return mysql.GetUserKarma(uid)
+	}
+	return karma, err
+}
+
+// StartKarmaSync 启动时redis中没有声望则从数据库重建，之后定期把变化的声望写回数据库
+// 返回的函数会在退出前再写回一次
+func StartKarmaSync() (stop func(ctx context.Context) error, err error) {
+	if err = rebuildKarma(); err != nil {
+		return nil, err
+	}
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		interval := time.Duration(settings.Current().KarmaConfig.PersistInterval) * time.Second
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				persistKarma()
+			case <-quit:
+				persistKarma()
+				return
+			}
+		}
+	}()
+	return func(ctx context.Context) error {
+		close(quit)
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}, nil
+}
+
+func rebuildKarma() error {
+	loaded, err := redis.KarmaLoaded()
+	if err != nil || loaded {
+		return err
+	}
+	karma, err := mysql.GetAllKarma()
+	if err != nil {
+		return err
+	}
+	zap.L().Info("rebuild karma from mysql", zap.Int("users", len(karma)))
+	return redis.LoadKarma(karma)
+}
+
+// persistKarma 写回所有待写回的用户，失败的用户重新标记后等下一次
+func persistKarma() {
+	for {
+		karma, err := redis.PopDirtyKarma(karmaPersistBatch)
+		if err != nil {
+			zap.L().Error("redis.PopDirtyKarma failed", zap.Error(err))
+			return
+		}
+		if len(karma) == 0 {
+			return
+		}
+		if err = mysql.SaveKarma(karma); err != nil {
+			zap.L().Error("mysql.SaveKarma failed", zap.Int("users", len(karma)), zap.Error(err))
+			uids := make([]int64, 0, len(karma))
+			for uid := range karma {
+				uids = append(uids, uid)
+			}
+			if err := redis.MarkKarmaDirty(uids); err != nil {
+				zap.L().Error("redis.MarkKarmaDirty failed", zap.Error(err))
+			}
+			return
+		}
+		if len(karma) < karmaPersistBatch {
+			return
+		}
+	}
+}
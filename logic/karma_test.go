@@ -0,0 +1,69 @@
+package logic
+
+import (
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnonymousPostVotesSkipKarma(t *testing.T) {
+	useMiniredis(t)
+	const author, voter = 1, 2
+	var base int64
+	_, err := redis.IncrKarma(author, 0, &base, karmaBucketTTL)
+	require.NoError(t, err)
+
+	updateKarma(listedAuthor(&models.Post{AuthorId: author, Anonymous: true}), voter, 2, 0, 1)
+	karma, err := redis.GetKarma(author)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), karma)
+
+	updateKarma(listedAuthor(&models.Post{AuthorId: author}), voter, 2, 0, 1)
+	karma, err = redis.GetKarma(author)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), karma)
+}
+
+func TestUpdateKarma(t *testing.T) {
+	useMiniredis(t)
+	const author, voter = 1, 2
+	var base int64 = 5
+	_, err := redis.IncrKarma(author, 0, &base, karmaBucketTTL)
+	require.NoError(t, err)
+
+	// 给自己投票不计入声望，改票只计算差值
+	updateKarma(author, author, 1, 0, 1)
+	updateKarma(author, voter, 1, 0, 1)
+	updateKarma(author, voter, 1, 1, -1)
+	karma, err := GetKarma(author)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), karma)
+}
+
+func TestGetKarmaRedisDown(t *testing.T) {
+	mr := useMiniredis(t)
+	mr.Close()
+	// redis出错时不回退到数据库
+	_, err := GetKarma(1)
+	assert.Error(t, err)
+}
+
+func TestPersistKarma(t *testing.T) {
+	useMySQL(t)
+	useMiniredis(t)
+	user := createTestUser(t)
+	updateKarma(user.UserID, user.UserID+1, 2, 0, 1)
+	updateKarma(user.UserID, user.UserID+2, 1, 0, -1)
+
+	persistKarma()
+	karma, err := mysql.GetUserKarma(user.UserID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), karma)
+	dirty, err := redis.PopDirtyKarma(karmaPersistBatch)
+	require.NoError(t, err)
+	assert.Empty(t, dirty)
+}
@@ -0,0 +1,80 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"strconv"
+
+	goredis "github.com/go-redis/redis"
+)
+
+func leaderboardDays(window string) int {
+	if window == models.LeaderboardWeek {
+		return karmaRecentDays
+	}
+	return 0
+}
+
+// GetLeaderboard 声望最高的用户，不包含当前用户自己的排名，所有用户看到的结果相同
+func GetLeaderboard(ctx context.Context, p *models.ParamLeaderboard) (*models.Leaderboard, error) {
+	zs, err := redis.GetKarmaLeaderboard(leaderboardDays(p.Window), p.Size)
+	if err != nil {
+		return nil, err
+	}
+	board := &models.Leaderboard{
+		Window: p.Window,
+		List:   make([]*models.LeaderboardEntry, 0, len(zs)),
+	}
+	for i, z := range zs {
+		member, _ := z.Member.(string)
+		uid, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			continue
+		}
+		board.List = append(board.List, &models.LeaderboardEntry{
+			Rank:   int64(i) + 1,
+			UserID: uid,
+			Karma:  int64(z.Score),
+		})
+	}
+	if err = fillLeaderboardNames(ctx, board.List); err != nil {
+		return nil, err
+	}
+	return board, nil
+}
+
+// GetLeaderboardRank 用户自己的排名，还没有声望时返回nil
+func GetLeaderboardRank(ctx context.Context, userID int64, window string) (*models.LeaderboardEntry, error) {
+	rank, karma, err := redis.GetKarmaRank(leaderboardDays(window), userID)
+	if err == goredis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	me := &models.LeaderboardEntry{Rank: rank, UserID: userID, Karma: karma}
+	if err = fillLeaderboardNames(ctx, []*models.LeaderboardEntry{me}); err != nil {
+		return nil, err
+	}
+	return me, nil
+}
+
+func fillLeaderboardNames(ctx context.Context, entries []*models.LeaderboardEntry) error {
+	ids := make([]int64, 0, len(entries))
+	for _, e := range entries {
+		ids = append(ids, e.UserID)
+	}
+	users, err := mysql.GetUsersByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		e.Username = models.DeactivatedUserName
+		if u, ok := users[e.UserID]; ok && !u.Deactivated {
+			e.Username = u.Username
+		}
+	}
+	return nil
+}
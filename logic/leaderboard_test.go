@@ -0,0 +1,49 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetLeaderboard(t *testing.T) {
+	useMySQL(t)
+	useMiniredis(t)
+	top, second, gone := createTestUser(t), createTestUser(t), createTestUser(t)
+	require.NoError(t, redis.LoadKarma(map[int64]int64{top.UserID: 30, second.UserID: 20, gone.UserID: 10}))
+	require.NoError(t, mysql.SetDeactivated(gone.UserID, true))
+
+	board, err := GetLeaderboard(context.Background(), &models.ParamLeaderboard{Window: models.LeaderboardAllTime, Size: 10})
+	require.NoError(t, err)
+	require.Len(t, board.List, 3)
+	assert.Equal(t, &models.LeaderboardEntry{Rank: 1, UserID: top.UserID, Username: top.Username, Karma: 30}, board.List[0])
+	assert.Equal(t, second.Username, board.List[1].Username)
+	// 注销的用户不显示用户名
+	assert.Equal(t, models.DeactivatedUserName, board.List[2].Username)
+
+	me, err := GetLeaderboardRank(context.Background(), second.UserID, models.LeaderboardAllTime)
+	require.NoError(t, err)
+	assert.Equal(t, &models.LeaderboardEntry{Rank: 2, UserID: second.UserID, Username: second.Username, Karma: 20}, me)
+}
+
+func TestGetLeaderboardRank(t *testing.T) {
+	mr := useMiniredis(t)
+	// 还没有声望的用户不在榜上
+	me, err := GetLeaderboardRank(context.Background(), 1, models.LeaderboardWeek)
+	require.NoError(t, err)
+	assert.Nil(t, me)
+	board, err := GetLeaderboard(context.Background(), &models.ParamLeaderboard{Window: models.LeaderboardWeek, Size: 10})
+	require.NoError(t, err)
+	assert.Empty(t, board.List)
+
+	mr.Close()
+	_, err = GetLeaderboardRank(context.Background(), 1, models.LeaderboardAllTime)
+	assert.Error(t, err)
+	_, err = GetLeaderboard(context.Background(), &models.ParamLeaderboard{Window: models.LeaderboardAllTime, Size: 10})
+	assert.Error(t, err)
+}
@@ -0,0 +1,57 @@
+package logic
+
+import (
+	"go-web-app/dao/redis"
+	"go-web-app/pkg/snowflake"
+	"go-web-app/settings"
+	"strconv"
+	"time"
+)
+
+// 限制创建频率的内容类型
+const (
+	CreationKindPost    = "post"
+	CreationKindComment = "comment"
+)
+
+// CreationLimit 超出频率限制时的信息
+type CreationLimit struct {
+	Limit      int64         // 窗口内允许的次数
+	Window     time.Duration // 统计窗口
+	RetryAfter time.Duration // 距离下一次允许创建的时间
+}
+
+// CheckCreationLimit 记录一次创建并检查是否超出限制，超出时返回限制的信息
+// 注册时间从用户id中取出，不需要查询数据库
+func CheckCreationLimit(userID int64, kind string) (*CreationLimit, error) {
+	cfg := settings.Current().CreationLimitConfig
+	if cfg == nil {
+		return nil, nil
+	}
+	newAccount := time.Since(snowflake.Time(userID)) < time.Duration(cfg.NewAccountAge)*time.Hour
+	var limit int64
+	var window time.Duration
+	switch kind {
+	case CreationKindPost:
+		limit, window = cfg.PostsPerHour, time.Hour
+		if newAccount {
+			limit = cfg.NewPostsPerHour
+		}
+	case CreationKindComment:
+		limit, window = cfg.CommentsPerMinute, time.Minute
+		if newAccount {
+			limit = cfg.NewCommentsPerMinute
+		}
+	}
+	if limit <= 0 {
+		return nil, nil
+	}
+	count, ttl, err := redis.IncrWindowCounter("create:"+kind+":"+strconv.FormatInt(userID, 10), window)
+	if err != nil {
+		return nil, err
+	}
+	if count <= limit {
+		return nil, nil
+	}
+	return &CreationLimit{Limit: limit, Window: window, RetryAfter: ttl}, nil
+}
@@ -0,0 +1,76 @@
+package logic
+
+import (
+	"go-web-app/pkg/snowflake"
+	"go-web-app/settings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useCreationLimit(t *testing.T, cfg *settings.CreationLimitConfig) {
+	old := settings.Conf.CreationLimitConfig
+	settings.Conf.CreationLimitConfig = cfg
+	t.Cleanup(func() { settings.Conf.CreationLimitConfig = old })
+}
+
+func TestCheckCreationLimit(t *testing.T) {
+	useMiniredis(t)
+	useCreationLimit(t, &settings.CreationLimitConfig{
+		PostsPerHour:         2,
+		CommentsPerMinute:    3,
+		NewAccountAge:        24,
+		NewPostsPerHour:      1,
+		NewCommentsPerMinute: 0,
+	})
+	oldUser := snowflake.MinID(time.Now().Add(-48 * time.Hour))
+	newUser := snowflake.GenID()
+
+	for i := 0; i < 2; i++ {
+		limit, err := CheckCreationLimit(oldUser, CreationKindPost)
+		require.NoError(t, err)
+		assert.Nil(t, limit)
+	}
+	limit, err := CheckCreationLimit(oldUser, CreationKindPost)
+	require.NoError(t, err)
+	require.NotNil(t, limit)
+	assert.EqualValues(t, 2, limit.Limit)
+	assert.Equal(t, time.Hour, limit.Window)
+	assert.True(t, limit.RetryAfter > 0 && limit.RetryAfter <= time.Hour)
+
+	// 评论单独计数
+	limit, err = CheckCreationLimit(oldUser, CreationKindComment)
+	require.NoError(t, err)
+	assert.Nil(t, limit)
+
+	// 新注册的账号使用更严格的限制，限制为0表示不限制
+	limit, err = CheckCreationLimit(newUser, CreationKindPost)
+	require.NoError(t, err)
+	assert.Nil(t, limit)
+	limit, err = CheckCreationLimit(newUser, CreationKindPost)
+	require.NoError(t, err)
+	require.NotNil(t, limit)
+	assert.EqualValues(t, 1, limit.Limit)
+	for i := 0; i < 5; i++ {
+		limit, err = CheckCreationLimit(newUser, CreationKindComment)
+		require.NoError(t, err)
+		assert.Nil(t, limit)
+	}
+}
+
+func TestCheckCreationLimitRedisDown(t *testing.T) {
+	mr := useMiniredis(t)
+	useCreationLimit(t, &settings.CreationLimitConfig{PostsPerHour: 1})
+	mr.Close()
+	limit, err := CheckCreationLimit(snowflake.GenID(), CreationKindPost)
+	assert.Error(t, err)
+	assert.Nil(t, limit)
+
+	// 没有配置时不检查，也不访问redis
+	useCreationLimit(t, nil)
+	limit, err = CheckCreationLimit(snowflake.GenID(), CreationKindPost)
+	assert.NoError(t, err)
+	assert.Nil(t, limit)
+}
@@ -0,0 +1,117 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"go-web-app/dao/mongodb"
+	"go-web-app/dao/mysql"
+	"go-web-app/models"
+	"go-web-app/pkg/linkfilter"
+	"go-web-app/pkg/snowflake"
+	"go-web-app/settings"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	LinkFilterReject = "reject"
+	LinkFilterHold   = "hold"
+)
+
+// alwaysAllowedDomains 学校的域名不受黑名单和新账号链接数的限制
+var alwaysAllowedDomains = []string{"rutgers.edu"}
+
+var ErrorSpamLink = errors.New("Content contains links that are not allowed. ")
+
+// linkFilter 当前的链接过滤器，配置重新加载后整体替换
+var linkFilter atomic.Value // *linkfilter.Filter
+
+// InitLinkFilter 根据配置创建链接过滤器，并在配置文件修改后重建
+func InitLinkFilter() {
+	linkFilter.Store(newLinkFilter(settings.Current().LinkFilterConfig))
+	settings.OnReload(func(conf *settings.AppConfig) {
+		linkFilter.Store(newLinkFilter(conf.LinkFilterConfig))
+	})
+}
+
+func newLinkFilter(cfg *settings.LinkFilterConfig) *linkfilter.Filter {
+	if cfg == nil {
+		return linkfilter.New(nil, alwaysAllowedDomains)
+	}
+	allowed := append(append([]string{}, alwaysAllowedDomains...), cfg.Allowlist...)
+	return linkfilter.New(cfg.Blocklist, allowed)
+}
+
+// spamLinks 内容没有通过链接检查的原因，提交到举报队列时给版主参考
+type spamLinks struct {
+	domain string
+	detail string
+}
+
+// checkSpamLinks 检查内容中的链接，没有问题时返回nil
+func checkSpamLinks(userID int64, texts ...string) *spamLinks {
+	cfg := settings.Current().LinkFilterConfig
+	f, ok := linkFilter.Load().(*linkfilter.Filter)
+	if cfg == nil || !cfg.Enable || !ok {
+		return nil
+	}
+	res := f.Check(texts...)
+	if len(res.Blocked) > 0 {
+		return &spamLinks{
+			domain: res.Blocked[0],
+			detail: "links to blocked domains: " + strings.Join(res.Blocked, ", "),
+		}
+	}
+	if cfg.NewAccountMaxLinks > 0 && res.Links > cfg.NewAccountMaxLinks &&
+		time.Since(snowflake.Time(userID)) < time.Duration(cfg.NewAccountAge)*time.Hour {
+		return &spamLinks{
+			detail: fmt.Sprintf("account younger than %d hours posted %d links, limit is %d",
+				cfg.NewAccountAge, res.Links, cfg.NewAccountMaxLinks),
+		}
+	}
+	return nil
+}
+
+// holdSpamLinks 是否把没有通过检查的帖子隐藏等待审核，否则直接拒绝
+// 草稿和定时帖子发布时不会再次检查，总是拒绝
+func holdSpamLinks(status int32) bool {
+	cfg := settings.Current().LinkFilterConfig
+	return cfg != nil && cfg.Action == LinkFilterHold && status != models.PostStatusDraft && status != models.PostStatusScheduled
+}
+
+// reportSpamLinks 隐藏的帖子提交到举报队列和审核队列，失败时只记录日志，帖子保持隐藏
+func reportSpamLinks(post *models.Post, spam *spamLinks) {
+	zap.L().Warn("post held for spam links", zap.Int64("pid", post.PostID), zap.String("detail", spam.detail))
+	id, err := snowflake.TryGenID()
+	if err != nil {
+		zap.L().Error("snowflake.TryGenID failed", zap.Int64("pid", post.PostID), zap.Error(err))
+		return
+	}
+	r := &models.Report{
+		ReportID:    id,
+		PostID:      post.PostID,
+		CommunityID: post.CommunityID,
+		Reason:      models.ReportReasonSpam,
+		Detail:      spam.detail,
+		Domain:      spam.domain,
+		Status:      models.ReportStatusOpen,
+		CreateTime:  time.Now(),
+	}
+	if err := mongodb.UpsertSystemReport(r); err != nil {
+		zap.L().Error("mongodb.UpsertSystemReport failed", zap.Int64("pid", post.PostID), zap.Error(err))
+	}
+	enqueueModQueue(models.ModQueueTypeHeldPost, r)
+}
+
+// holdPost 编辑后没有通过检查的帖子改为隐藏并提交到举报队列
+func holdPost(post *models.Post, spam *spamLinks) error {
+	if err := mysql.SetPostStatus(post.PostID, models.PostStatusHidden); err != nil {
+		return err
+	}
+	reportSpamLinks(post, spam)
+	invalidatePostDetail(post.PostID)
+	return nil
+}
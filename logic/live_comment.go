@@ -0,0 +1,119 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/pkg/hub"
+	"go-web-app/settings"
+	"sync"
+
+	goredis "github.com/go-redis/redis"
+	"go.uber.org/zap"
+)
+
+// commentHub 本实例上正在查看帖子的websocket连接，按帖子id分组
+var commentHub = hub.New()
+
+// commentSubs 本实例订阅了哪些帖子的频道，第一个连接进来时订阅，最后一个断开时退订
+// 所有帖子共用一个PubSub连接
+var commentSubs = struct {
+	sync.Mutex
+	ps     *goredis.PubSub
+	counts map[int64]int
+}{counts: make(map[int64]int)}
+
+// ConnectPostComments 为帖子登记一个接收新评论的连接，帖子不存在时返回错误，
+// 超过单个帖子的连接数上限时返回hub.ErrTooManyConnections。连接断开后调用方必须调用release
+func ConnectPostComments(ctx context.Context, pid int64) (client *hub.Client, release func(), err error) {
+	if _, err = GetPostById(ctx, pid); err != nil {
+		return nil, nil, err
+	}
+	client, err = commentHub.Register(pid, settings.Current().WebSocketConfig.MaxSubscribersPerPost)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = subscribePostComments(pid); err != nil {
+		client.Close()
+		return nil, nil, err
+	}
+	var once sync.Once
+	release = func() {
+		once.Do(func() {
+			client.Close()
+			unsubscribePostComments(pid)
+		})
+	}
+	return client, release, nil
+}
+
+func subscribePostComments(pid int64) error {
+	commentSubs.Lock()
+	defer commentSubs.Unlock()
+	if commentSubs.ps == nil {
+		return hub.ErrShuttingDown
+	}
+	if commentSubs.counts[pid] == 0 {
+		if err := commentSubs.ps.Subscribe(redis.PostCommentChannel(pid)); err != nil {
+			return err
+		}
+	}
+	commentSubs.counts[pid]++
+	return nil
+}
+
+func unsubscribePostComments(pid int64) {
+	commentSubs.Lock()
+	defer commentSubs.Unlock()
+	commentSubs.counts[pid]--
+	if commentSubs.counts[pid] > 0 {
+		return
+	}
+	delete(commentSubs.counts, pid)
+	if commentSubs.ps == nil {
+		return
+	}
+	if err := commentSubs.ps.Unsubscribe(redis.PostCommentChannel(pid)); err != nil {
+		zap.L().Warn("unsubscribe post comments failed", zap.Int64("pid", pid), zap.Error(err))
+	}
+}
+
+// publishComment 广播新评论，格式与评论列表中的节点相同
+func publishComment(comment *models.Comment) {
+	payload, err := json.Marshal(&models.CommentNode{Comment: comment, Children: []*models.CommentNode{}})
+	if err != nil {
+		return
+	}
+	if err := redis.PublishPostComment(comment.PostID, payload); err != nil {
+		zap.L().Warn("redis.PublishPostComment failed", zap.Int64("pid", comment.PostID), zap.Error(err))
+	}
+}
+
+// StartCommentPush 接收订阅的帖子的新评论并推送给本实例上的连接，返回停止订阅的函数
+func StartCommentPush() (stop func()) {
+	ps := redis.NewPostCommentSubscriber()
+	commentSubs.Lock()
+	commentSubs.ps = ps
+	commentSubs.Unlock()
+	go func() {
+		for msg := range ps.Channel() {
+			pid, ok := redis.ParsePostCommentChannel(msg.Channel)
+			if !ok {
+				continue
+			}
+			commentHub.Send(pid, []byte(msg.Payload))
+		}
+	}()
+	return func() {
+		commentSubs.Lock()
+		commentSubs.ps = nil
+		commentSubs.Unlock()
+		_ = ps.Close()
+	}
+}
+
+// DrainPostComments 关闭服务前通知所有查看帖子的客户端断开，返回超时后仍未断开的连接数
+func DrainPostComments(ctx context.Context) int {
+	return commentHub.Drain(ctx)
+}
@@ -0,0 +1,44 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/pkg/loader"
+	"strconv"
+)
+
+var loaderFetchers = loader.Fetchers{
+	Users:       mysql.GetUsersByIDs,
+	Communities: GetCommunitiesByIDs,
+	Votes:       getUserPostVotes,
+}
+
+// WithLoader 为一个请求创建数据加载器，请求内组装响应时用户、社区和投票只查询一次
+func WithLoader(ctx context.Context) context.Context {
+	return loader.NewContext(ctx, loader.New(loaderFetchers))
+}
+
+// requestLoader 请求中没有加载器时返回只在本次调用中使用的加载器，与直接查询一样
+func requestLoader(ctx context.Context) *loader.Loader {
+	if l := loader.FromContext(ctx); l != nil {
+		return l
+	}
+	return loader.New(loaderFetchers)
+}
+
+func getUserPostVotes(_ context.Context, userID int64, pids []int64) (map[int64]int8, error) {
+	ids := make([]string, 0, len(pids))
+	for _, pid := range pids {
+		ids = append(ids, strconv.FormatInt(pid, 10))
+	}
+	data, err := redis.GetUserPostVotes(strconv.FormatInt(userID, 10), ids)
+	if err != nil {
+		return nil, err
+	}
+	votes := make(map[int64]int8, len(pids))
+	for i, pid := range pids {
+		votes[pid] = data[i]
+	}
+	return votes, nil
+}
@@ -0,0 +1,15 @@
+package logic
+
+import (
+	"go-web-app/pkg/snowflake"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// 审计日志等地方会生成id
+	if err := snowflake.Init("2020-01-01", 1); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
@@ -0,0 +1,76 @@
+package logic
+
+import (
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// maintenanceCacheTTL 每个请求都要检查维护模式，进程内缓存一小段时间，开关后其他实例最多延迟这么久生效
+const maintenanceCacheTTL = 2 * time.Second
+
+var maintenanceCache struct {
+	sync.Mutex
+	m        *models.Maintenance
+	expireAt time.Time
+}
+
+// GetMaintenance 当前的维护模式，没有开启时返回nil
+// 读取redis失败时沿用上一次的状态，避免redis抖动时所有请求都被拦截或放行
+func GetMaintenance() *models.Maintenance {
+	maintenanceCache.Lock()
+	defer maintenanceCache.Unlock()
+	now := time.Now()
+	if now.Before(maintenanceCache.expireAt) {
+		return maintenanceCache.m
+	}
+	m, err := redis.GetMaintenance()
+	if err != nil {
+		zap.L().Error("redis.GetMaintenance failed", zap.Error(err))
+	} else {
+		// 其他实例修改的模式在这里第一次被看到
+		if from, to := maintenanceMode(maintenanceCache.m), maintenanceMode(m); from != to {
+			zap.L().Warn("maintenance mode changed", zap.String("from", from), zap.String("to", to))
+		}
+		maintenanceCache.m = m
+	}
+	maintenanceCache.expireAt = now.Add(maintenanceCacheTTL)
+	return maintenanceCache.m
+}
+
+// SetMaintenance 开启或关闭维护模式，当前实例立即生效
+func SetMaintenance(p *models.ParamMaintenance) (m *models.Maintenance, err error) {
+	if p.Mode == models.MaintenanceModeOff {
+		err = redis.ClearMaintenance()
+	} else {
+		m = &models.Maintenance{Mode: p.Mode, Message: p.Message, StartTime: time.Now()}
+		err = redis.SetMaintenance(m)
+	}
+	if err != nil {
+		return nil, err
+	}
+	maintenanceCache.Lock()
+	zap.L().Warn("maintenance mode set",
+		zap.String("from", maintenanceMode(maintenanceCache.m)),
+		zap.String("to", p.Mode),
+		zap.String("message", p.Message))
+	maintenanceCache.m = m
+	maintenanceCache.expireAt = time.Now().Add(maintenanceCacheTTL)
+	maintenanceCache.Unlock()
+	return m, nil
+}
+
+// MaintenanceMode 当前维护模式的名字，没有开启时为off
+func MaintenanceMode() string {
+	return maintenanceMode(GetMaintenance())
+}
+
+func maintenanceMode(m *models.Maintenance) string {
+	if m == nil {
+		return models.MaintenanceModeOff
+	}
+	return m.Mode
+}
@@ -0,0 +1,54 @@
+package logic
+
+import (
+	"go-web-app/dao/mysql"
+	"go-web-app/models"
+	"go-web-app/pkg/mention"
+	"go-web-app/settings"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// resolveMentions 解析内容中的@username并查询对应的用户，按在内容中出现的顺序返回
+// 不存在和已注销的用户按普通文本处理；查询失败时返回nil，不影响保存内容
+func resolveMentions(content string) []*models.Mention {
+	names := mention.Parse(content, settings.Current().MentionConfig.MaxPerItem)
+	if len(names) == 0 {
+		return nil
+	}
+	users, err := mysql.GetUsersByUsernames(names)
+	if err != nil {
+		zap.L().Error("mysql.GetUsersByUsernames failed", zap.Strings("names", names), zap.Error(err))
+		return nil
+	}
+	byName := make(map[string]*models.User, len(users))
+	for _, u := range users {
+		if !u.Deactivated {
+			byName[strings.ToLower(u.Username)] = u
+		}
+	}
+	var mentions []*models.Mention
+	for _, name := range names {
+		if u, ok := byName[strings.ToLower(name)]; ok {
+			mentions = append(mentions, &models.Mention{UserID: u.UserID, Username: u.Username})
+		}
+	}
+	return mentions
+}
+
+// notifyMentions 通知被@的用户，skip中的用户已经收到了其他通知，不再重复通知
+// 同一个目标对同一个人只通知一次，编辑内容后不会重复通知
+func notifyMentions(mentions []*models.Mention, actorID int64, typ string, targetID int64, skip ...int64) {
+	notified := make(map[int64]struct{}, len(mentions)+len(skip))
+	for _, id := range skip {
+		notified[id] = struct{}{}
+	}
+	for _, m := range mentions {
+		if _, ok := notified[m.UserID]; ok {
+			continue
+		}
+		notified[m.UserID] = struct{}{}
+		notify(m.UserID, actorID, typ, targetID, true)
+	}
+}
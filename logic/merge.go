@@ -0,0 +1,204 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"go-web-app/dao/mongodb"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	mergeTokenExpire = 10 * time.Minute
+	mergeLockTTL     = time.Minute
+)
+
+var (
+	ErrorMergeEmailMismatch = errors.New("Both accounts must have the same verified email. ")
+	ErrorMergeRunning       = errors.New("Account merge is already in progress. ")
+)
+
+// RequestMerge 在要被合并的账号上生成确认token，之后登录目标账号提交token完成合并，两个账号都需要登录过
+func RequestMerge(userID int64) (*models.MergeToken, error) {
+	token, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := redis.SetMergeToken(token, userID, mergeTokenExpire); err != nil {
+		return nil, err
+	}
+	return &models.MergeToken{Token: token, ExpireTime: time.Now().Add(mergeTokenExpire)}, nil
+}
+
+// ConfirmMerge 把token对应的账号合并到当前登录的账号，两个账号必须是同一个已验证的邮箱
+// 合并失败时token仍然有效，可以重试
+func ConfirmMerge(userID int64, token string) (*models.MergeResult, error) {
+	fromUID, err := redis.GetMergeToken(token)
+	if err != nil {
+		return nil, err
+	}
+	from, err := mysql.GetUserEmail(fromUID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := mysql.GetUserEmail(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !from.EmailVerified || !to.EmailVerified || from.Email == "" || !strings.EqualFold(from.Email, to.Email) {
+		return nil, ErrorMergeEmailMismatch
+	}
+	result, err := MergeUsers(fromUID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := redis.DeleteMergeToken(token); err != nil {
+		zap.L().Warn("redis.DeleteMergeToken failed", zap.Int64("from", fromUID), zap.Error(err))
+	}
+	return result, nil
+}
+
+// MergeUsers 把fromUID的帖子、评论、投票和关注等转给toUID，然后注销fromUID，管理员账号不能被合并。
+// mysql中的数据在一个事务中转移，之后的步骤失败时可以重试，已经完成的步骤重复执行不会有变化
+func MergeUsers(fromUID, toUID int64) (*models.MergeResult, error) {
+	role, err := mysql.GetUserRole(fromUID)
+	if err != nil {
+		return nil, err
+	}
+	if role == models.RoleAdmin {
+		return nil, ErrorNoPermission
+	}
+	lockKey := "user-merge:" + strconv.FormatInt(fromUID, 10)
+	lock, ok, err := redis.AcquireLock(context.Background(), lockKey, mergeLockTTL)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrorMergeRunning
+	}
+	ctx, cancel := lock.AutoRenewContext(context.Background())
+	defer cancel()
+	defer func() {
+		if err := redis.ReleaseLock(context.Background(), lock); err != nil {
+			zap.L().Warn("redis.ReleaseLock failed", zap.String("key", lockKey), zap.Error(err))
+		}
+	}()
+
+	// 帖子的作者变了，详情缓存中的作者名也要更新
+	postIDs, err := redis.GetUserPostIDs(fromUID)
+	if err != nil {
+		return nil, err
+	}
+	if err := mysql.MergeUser(fromUID, toUID); err != nil {
+		return nil, err
+	}
+	// 先让来源账号的登录状态失效，避免合并过程中继续投票
+	if err := redis.SetDeactivated(fromUID, true); err != nil {
+		return nil, err
+	}
+	if err := redis.DeleteSessions(fromUID); err != nil {
+		return nil, err
+	}
+	result := &models.MergeResult{FromUserID: fromUID, ToUserID: toUID}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if result.Comments, err = mongodb.ReassignComments(fromUID, toUID); err != nil {
+		return nil, err
+	}
+	if err := mergeVotes(fromUID, toUID); err != nil {
+		return nil, err
+	}
+	if err := redis.MergeUserPosts(fromUID, toUID); err != nil {
+		return nil, err
+	}
+	for _, id := range postIDs {
+		pid, _ := strconv.ParseInt(id, 10, 64)
+		if err := redis.DeletePostDetailCache(pid); err != nil {
+			zap.L().Warn("redis.DeletePostDetailCache failed", zap.Int64("pid", pid), zap.Error(err))
+		}
+	}
+	if err := mergeKarma(fromUID, toUID); err != nil {
+		return nil, err
+	}
+	if err := refreshMergedCounts(fromUID, toUID); err != nil {
+		return nil, err
+	}
+	BustResponseCache(CacheLeaderboard)
+	return result, nil
+}
+
+// mergeVotes 转移投票，撤销了重复投票的帖子重新计算热度和争议度
+func mergeVotes(fromUID, toUID int64) error {
+	changed, err := redis.MergeUserVotes(fromUID, toUID)
+	if err != nil {
+		return err
+	}
+	cfg := settings.Current().PostConfig
+	for _, pid := range changed {
+		if err := redis.UpdatePostHotScore(pid, cfg.HotGravity); err != nil {
+			return err
+		}
+		if err := redis.UpdatePostControversyScore(pid, cfg.ControversialMinVotes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mergeKarma(fromUID, toUID int64) error {
+	fromBase, err := mysql.GetUserKarma(fromUID)
+	if err != nil {
+		return err
+	}
+	toBase, err := mysql.GetUserKarma(toUID)
+	if err != nil {
+		return err
+	}
+	_, err = redis.MergeKarma(fromUID, toUID, fromBase, toBase)
+	return err
+}
+
+// refreshMergedCounts 合并时删除了重复的关注和社区成员，相关的计数从数据库重新统计。
+// 受影响的用户和社区合并后都与toUID有关系，所以只需要重新统计toUID的关注对象、粉丝和加入的社区
+func refreshMergedCounts(fromUID, toUID int64) error {
+	following, err := mysql.GetFollowingIDs(toUID)
+	if err != nil {
+		return err
+	}
+	followers, err := mysql.GetFollowerIDs(toUID)
+	if err != nil {
+		return err
+	}
+	uids := append([]int64{fromUID, toUID}, following...)
+	for _, uid := range append(uids, followers...) {
+		count, err := mysql.GetFollowCount(uid)
+		if err != nil {
+			return err
+		}
+		if err := redis.SetFollowCount(uid, count); err != nil {
+			return err
+		}
+	}
+	communities, err := mysql.GetJoinedCommunityIDs(toUID)
+	if err != nil {
+		return err
+	}
+	for _, cid := range communities {
+		count, err := mysql.GetCommunityMemberCount(cid)
+		if err != nil {
+			return err
+		}
+		if err := redis.SetCommunityMemberCount(cid, count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
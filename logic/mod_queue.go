@@ -0,0 +1,128 @@
+package logic
+
+import (
+	"errors"
+	"go-web-app/dao/mongodb"
+	"go-web-app/dao/mysql"
+	"go-web-app/models"
+	"go-web-app/pkg/snowflake"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var ErrorModQueueConflict = errors.New("Item is already handled by another moderator. ")
+
+// enqueueModQueue 把举报提交到审核队列，失败时只记录日志
+func enqueueModQueue(typ string, r *models.Report) {
+	id, err := snowflake.TryGenID()
+	if err != nil {
+		zap.L().Error("snowflake.TryGenID failed", zap.String("type", typ), zap.Int64("pid", r.PostID), zap.Error(err))
+		return
+	}
+	now := time.Now()
+	item := &models.ModQueueItem{
+		ItemID:      id,
+		Type:        typ,
+		PostID:      r.PostID,
+		CommentID:   r.CommentID,
+		CommunityID: r.CommunityID,
+		Reason:      r.Reason,
+		Detail:      r.Detail,
+		CreateTime:  now,
+		UpdateTime:  now,
+	}
+	if err := mongodb.EnqueueModQueueItem(item); err != nil {
+		zap.L().Error("mongodb.EnqueueModQueueItem failed", zap.String("type", typ), zap.Int64("pid", r.PostID), zap.Error(err))
+	}
+}
+
+// GetModQueue 用户管理的社区中的审核队列，网站管理员可以看到所有社区，status为空时返回未认领的条目
+func GetModQueue(userID int64, p *models.ParamModQueue, page, size int64) (list []*models.ModQueueItem, hasMore bool, err error) {
+	communityIDs, err := moderatedCommunityIDs(userID)
+	if err != nil {
+		return nil, false, err
+	}
+	status := p.Status
+	if status == "" {
+		status = models.ModQueueStatusOpen
+	}
+	var assigneeID int64
+	if p.Mine {
+		assigneeID = userID
+	}
+	list, err = mongodb.GetModQueueItems(communityIDs, status, assigneeID, (page-1)*size, size+1)
+	if err != nil {
+		return nil, false, err
+	}
+	if hasMore = int64(len(list)) > size; hasMore {
+		list = list[:size]
+	}
+	return list, hasMore, nil
+}
+
+// ClaimModQueueItem 版主认领条目，认领之后其他版主不能处理，条目已经被其他版主认领或者已经处理时返回ErrorModQueueConflict
+func ClaimModQueueItem(userID, itemID int64) (*models.ModQueueItem, error) {
+	item, err := mongodb.GetModQueueItem(itemID)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkModerator(userID, item.CommunityID); err != nil {
+		return nil, err
+	}
+	ok, err := mongodb.ClaimModQueueItem(itemID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrorModQueueConflict
+	}
+	if item.Status == models.ModQueueStatusOpen {
+		now := time.Now()
+		item.Status, item.AssigneeID, item.AssignTime = models.ModQueueStatusAssigned, userID, &now
+	}
+	return item, nil
+}
+
+// ResolveModQueueItem 处理条目，还没有认领的条目先认领，处理结果关联到这次操作的审计日志
+// 同一个目标其他来源的条目一起处理
+func ResolveModQueueItem(userID, itemID int64, p *models.ParamResolveModQueue, ip string) (*models.ModQueueItem, error) {
+	item, err := ClaimModQueueItem(userID, itemID)
+	if err != nil {
+		return nil, err
+	}
+	auditID, err := moderateTarget(userID, item, p.Action, ip)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := mongodb.ResolveModQueueItem(itemID, userID, p.Action, p.Note, auditID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrorModQueueConflict
+	}
+	if err = mongodb.ResolveTargetModQueueItems(item.PostID, item.CommentID, userID, p.Action, p.Note, auditID); err != nil {
+		zap.L().Error("mongodb.ResolveTargetModQueueItems failed", zap.Int64("itemID", itemID), zap.Error(err))
+	}
+	return mongodb.GetModQueueItem(itemID)
+}
+
+// moderateTarget 条目的目标是评论时删除评论或者保留评论，否则按举报处理帖子，返回审计日志的id
+func moderateTarget(userID int64, item *models.ModQueueItem, action, ip string) (int64, error) {
+	if item.CommentID == 0 {
+		post, err := mysql.GetPostById(item.PostID)
+		if err != nil {
+			return 0, err
+		}
+		return moderatePost(userID, post, action, ip)
+	}
+	auditAction := models.AuditActionDismissReport
+	if action == models.ReportActionRemove {
+		auditAction = models.AuditActionRemoveComment
+		if err := mongodb.DeleteComment(item.CommentID); err != nil {
+			return 0, err
+		}
+	}
+	return recordAuditSync(userID, auditAction, commentTarget(item.CommentID), ip)
+}
@@ -0,0 +1,78 @@
+package logic
+
+import (
+	"go-web-app/dao/mongodb"
+	"go-web-app/dao/mysql"
+	"go-web-app/models"
+	"go-web-app/pkg/snowflake"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveModQueueItemConflicts(t *testing.T) {
+	useMySQL(t)
+	useMongo(t)
+	cid := createTestCommunity(t, models.CommunityVisibilityPublic)
+	modA, modB := createTestUser(t), createTestUser(t)
+	for _, u := range []*models.User{modA, modB} {
+		_, err := mysql.JoinCommunity(u.UserID, cid, func() error { return nil })
+		require.NoError(t, err)
+		require.NoError(t, mysql.SetCommunityRole(u.UserID, cid, models.CommunityRoleModerator))
+	}
+	now := time.Now()
+	item := &models.ModQueueItem{
+		ItemID:      snowflake.GenID(),
+		Type:        models.ModQueueTypeAutoFlag,
+		PostID:      snowflake.GenID(),
+		CommentID:   snowflake.GenID(),
+		CommunityID: cid,
+		CreateTime:  now,
+		UpdateTime:  now,
+	}
+	require.NoError(t, mongodb.EnqueueModQueueItem(item))
+	dismiss := &models.ParamResolveModQueue{Action: models.ReportActionDismiss}
+
+	claimed, err := ClaimModQueueItem(modA.UserID, item.ItemID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ModQueueStatusAssigned, claimed.Status)
+	// 重复认领自己的条目不算冲突，其他版主不能认领或处理
+	_, err = ClaimModQueueItem(modA.UserID, item.ItemID)
+	require.NoError(t, err)
+	_, err = ClaimModQueueItem(modB.UserID, item.ItemID)
+	assert.ErrorIs(t, err, ErrorModQueueConflict)
+	_, err = ResolveModQueueItem(modB.UserID, item.ItemID, dismiss, "127.0.0.1")
+	assert.ErrorIs(t, err, ErrorModQueueConflict)
+
+	resolved, err := ResolveModQueueItem(modA.UserID, item.ItemID, dismiss, "127.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, models.ModQueueStatusResolved, resolved.Status)
+	// 审计日志同步写入，处理结果中关联的日志可以立即查到
+	logs, err := mongodb.GetAuditLogs(&models.ParamAuditQuery{AuditID: resolved.AuditID, Page: 1, Size: 10})
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	assert.Equal(t, models.AuditActionDismissReport, logs[0].Action)
+
+	// 处理过的条目不能再次认领
+	_, err = ResolveModQueueItem(modA.UserID, item.ItemID, dismiss, "127.0.0.1")
+	assert.ErrorIs(t, err, ErrorModQueueConflict)
+}
+
+func TestRecordAuditSyncRetry(t *testing.T) {
+	useMongo(t)
+	old := auditRetryDelay
+	auditRetryDelay = 0
+	t.Cleanup(func() { auditRetryDelay = old })
+
+	id, err := recordAuditSync(1, models.AuditActionDismissReport, "post:1", "127.0.0.1")
+	require.NoError(t, err)
+	assert.NotZero(t, id)
+
+	// 连接断开时重试之后返回错误，不返回日志id
+	require.NoError(t, mongodb.Close())
+	id, err = recordAuditSync(1, models.AuditActionDismissReport, "post:1", "127.0.0.1")
+	assert.Error(t, err)
+	assert.Zero(t, id)
+}
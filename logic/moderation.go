@@ -0,0 +1,95 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"go-web-app/dao/mongodb"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var ErrorRemoveContentRunning = errors.New("Content of this user is already being removed. ")
+
+// removeContentLockTTL 删除过程中会自动续期
+const removeContentLockTTL = time.Minute
+
+// RemoveUserContent 批量软删除用户在时间范围内发表的帖子和评论，communityID为0时删除所有社区中的内容，
+// 否则只删除该社区的帖子和该社区帖子下的评论，同时发布到其他社区的帖子只从该社区移除
+// 帖子在一个事务中删除，同一个用户同时只能有一个删除在进行
+func RemoveUserContent(uid, communityID int64, p *models.ParamRemoveContent) (*models.ContentRemoval, error) {
+	key := "content:remove:" + strconv.FormatInt(uid, 10)
+	lock, ok, err := redis.AcquireLock(context.Background(), key, removeContentLockTTL)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrorRemoveContentRunning
+	}
+	ctx, cancel := lock.AutoRenewContext(context.Background())
+	defer cancel()
+	defer func() {
+		if err := redis.ReleaseLock(context.Background(), lock); err != nil {
+			zap.L().Warn("redis.ReleaseLock failed", zap.String("key", key), zap.Error(err))
+		}
+	}()
+
+	deleted, detached, err := mysql.DeleteUserPosts(uid, communityID, p.From, p.To)
+	if err != nil {
+		return nil, err
+	}
+	result := &models.ContentRemoval{Posts: int64(len(deleted)), Detached: int64(len(detached))}
+	for _, post := range deleted {
+		removePostFromTags(post.PostID)
+		removePostFromCommunities(post)
+		invalidatePostDetail(post.PostID)
+	}
+	for _, pid := range detached {
+		if err := redis.RemovePostFromCommunities(pid, []int64{communityID}); err != nil {
+			zap.L().Error("remove post from community failed", zap.Int64("pid", pid), zap.Error(err))
+		}
+		invalidatePostDetail(pid)
+	}
+	// 只有全局删除会移出首页和关注动态，版主删除时帖子在其他社区中可能仍然可见
+	if communityID == 0 {
+		if err := redis.RemovePostsFromFeeds(deleted); err != nil {
+			zap.L().Error("redis.RemovePostsFromFeeds failed", zap.Int64("uid", uid), zap.Error(err))
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	var pids []int64
+	if communityID != 0 {
+		commented, err := mongodb.GetCommentedPostIDs(uid, p.From, p.To)
+		if err != nil {
+			return result, err
+		}
+		if pids, err = mysql.GetCommunityPostIDsIn(communityID, commented); err != nil {
+			return result, err
+		}
+	}
+	if result.Comments, err = mongodb.DeleteCommentsByAuthor(uid, pids, p.From, p.To); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// restorePostFeeds 恢复的帖子重新加入首页和作者的列表，批量删除时它们被移除了
+func restorePostFeeds(post *models.Post) error {
+	if err := redis.RestorePostToFeeds(post); err != nil {
+		return err
+	}
+	cfg := settings.Current().PostConfig
+	pid := strconv.FormatInt(post.PostID, 10)
+	if err := redis.UpdatePostHotScore(pid, cfg.HotGravity); err != nil {
+		return err
+	}
+	return redis.UpdatePostControversyScore(pid, cfg.ControversialMinVotes)
+}
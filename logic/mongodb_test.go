@@ -0,0 +1,23 @@
+package logic
+
+import (
+	"fmt"
+	"go-web-app/dao/mongodb"
+	"go-web-app/settings"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// useMongo 连接MONGODB_TEST_URI指定的MongoDB，每个测试使用新的数据库，没有设置时跳过
+func useMongo(t *testing.T) {
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI not set")
+	}
+	db := fmt.Sprintf("bluebell_test_%d", time.Now().UnixNano())
+	require.NoError(t, mongodb.Init(&settings.MongodbConfig{Host: uri, DB: db}))
+	t.Cleanup(func() { _ = mongodb.Close() })
+}
@@ -0,0 +1,47 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+
+	"go.uber.org/zap"
+)
+
+// MuteCommunity 在信息流中隐藏社区，社区不存在时返回mysql.ErrorInvalidID
+// 隐藏的社区只保存在redis中，读取信息流时不需要查询数据库
+func MuteCommunity(ctx context.Context, userID, communityID int64) error {
+	if _, err := getCommunityDetail(ctx, communityID); err != nil {
+		return err
+	}
+	return redis.MuteCommunity(userID, communityID)
+}
+
+func UnmuteCommunity(userID, communityID int64) error {
+	return redis.UnmuteCommunity(userID, communityID)
+}
+
+// FilterMutedPosts 去掉主社区被userID隐藏的帖子，读取隐藏列表失败时原样返回
+// 只用于全站和首页的信息流，直接访问社区时不过滤
+func FilterMutedPosts(userID int64, posts []*models.PostDetail) []*models.PostDetail {
+	ids, err := redis.GetMutedCommunityIDs(userID)
+	if err != nil {
+		zap.L().Error("redis.GetMutedCommunityIDs failed", zap.Int64("userID", userID), zap.Error(err))
+		return posts
+	}
+	if len(ids) == 0 {
+		return posts
+	}
+	muted := make(map[int64]struct{}, len(ids))
+	for _, id := range ids {
+		muted[id] = struct{}{}
+	}
+	filtered := make([]*models.PostDetail, 0, len(posts))
+	for _, post := range posts {
+		if _, ok := muted[post.CommunityID]; ok {
+			continue
+		}
+		filtered = append(filtered, post)
+	}
+	return filtered
+}
@@ -0,0 +1,72 @@
+package logic
+
+import (
+	"fmt"
+	"go-web-app/dao/mysql"
+	"go-web-app/models"
+	"go-web-app/pkg/snowflake"
+	"go-web-app/settings"
+	"net"
+	"os"
+	"strconv"
+	"testing"
+
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/require"
+)
+
+// useMySQL 连接MYSQL_TEST_DSN指定的测试库并执行迁移，没有设置时跳过
+// 测试数据使用新生成的id，不会和已有的数据冲突
+func useMySQL(t *testing.T) {
+	dsn := os.Getenv("MYSQL_TEST_DSN")
+	if dsn == "" {
+		t.Skip("MYSQL_TEST_DSN not set")
+	}
+	cfg, err := gomysql.ParseDSN(dsn)
+	require.NoError(t, err)
+	host, port, err := net.SplitHostPort(cfg.Addr)
+	require.NoError(t, err)
+	portNum, err := strconv.Atoi(port)
+	require.NoError(t, err)
+	require.NoError(t, mysql.Init(&settings.MySQLConfig{
+		Host:         host,
+		Port:         portNum,
+		User:         cfg.User,
+		Password:     cfg.Passwd,
+		DB:           cfg.DBName,
+		MaxOpenConns: 4,
+		MaxIdleConns: 4,
+		AutoMigrate:  true,
+	}))
+	t.Cleanup(mysql.Close)
+}
+
+func createTestUser(t *testing.T) *models.User {
+	id := snowflake.GenID()
+	user := &models.User{
+		UserID:   id,
+		Username: fmt.Sprintf("test_%d", id),
+		Password: "password",
+		Email:    fmt.Sprintf("test_%d@rutgers.edu", id),
+	}
+	require.NoError(t, mysql.InsertUser(user, nil, nil))
+	return user
+}
+
+func createTestCommunity(t *testing.T, visibility string) int64 {
+	id, err := mysql.CreateCommunity(fmt.Sprintf("test_%d", snowflake.GenID()), "test", visibility)
+	require.NoError(t, err)
+	return id
+}
+
+func createTestPost(t *testing.T, authorID, communityID int64) *models.Post {
+	post := &models.Post{
+		PostID:      snowflake.GenID(),
+		AuthorId:    authorID,
+		CommunityID: communityID,
+		Title:       "test",
+		Content:     "just a test",
+	}
+	require.NoError(t, mysql.CreatePost(post))
+	return post
+}
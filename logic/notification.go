@@ -0,0 +1,142 @@
+package logic
+
+import (
+	"errors"
+	"go-web-app/dao/mongodb"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/pkg/snowflake"
+	"time"
+
+	goredis "github.com/go-redis/redis"
+	"go.uber.org/zap"
+)
+
+// notify 给recipientID发送一条通知，自己的操作、被屏蔽用户的操作和用户关闭的类型不发通知。
+// 通知失败只记录日志，不影响触发通知的操作。
+func notify(recipientID, actorID int64, typ string, targetID int64, once bool) {
+	if recipientID == actorID || recipientID == 0 {
+		return
+	}
+	if !notificationAllowed(recipientID, typ) {
+		return
+	}
+	if blocked, err := IsBlocked(recipientID, actorID); err != nil || blocked {
+		return
+	}
+	id, err := snowflake.TryGenID()
+	if err != nil {
+		zap.L().Error("snowflake.TryGenID failed", zap.Int64("recipient", recipientID), zap.String("type", typ), zap.Error(err))
+		return
+	}
+	n := &models.Notification{
+		NotificationID: id,
+		RecipientID:    recipientID,
+		Type:           typ,
+		ActorID:        actorID,
+		TargetID:       targetID,
+		CreateTime:     time.Now(),
+	}
+	created := true
+	if once {
+		created, err = mongodb.CreateNotificationOnce(n)
+	} else {
+		err = mongodb.CreateNotification(n)
+	}
+	if err != nil {
+		zap.L().Error("create notification failed", zap.Int64("recipient", recipientID), zap.String("type", typ), zap.Error(err))
+		return
+	}
+	if !created {
+		return
+	}
+	if err := redis.IncrUnreadCount(recipientID); err != nil {
+		zap.L().Warn("redis.IncrUnreadCount failed", zap.Int64("recipient", recipientID), zap.Error(err))
+	}
+	// 免打扰期间只创建通知，结束后再推送
+	if until := quietHoursEnd(recipientID, typ, n.CreateTime); !until.IsZero() {
+		deferNotification(n, until)
+		return
+	}
+	publishNotification(n)
+}
+
+var ErrorInvalidNotificationType = errors.New("Invalid notification type. ")
+
+// notificationTypeFilters 列表中可以使用的类型过滤，mention包含帖子和评论中的@
+var notificationTypeFilters = map[string][]string{
+	models.NotificationReply:  {models.NotificationReply},
+	models.NotificationVote:   {models.NotificationVote},
+	models.NotificationFollow: {models.NotificationFollow},
+	"mention":                 {models.NotificationMentionPost, models.NotificationMentionComment},
+	"trending":                {models.NotificationTrendingTag},
+}
+
+// GetNotifications typ为空时返回所有类型的通知
+func GetNotifications(userID int64, typ string, page, size int64) ([]*models.Notification, int64, error) {
+	var types []string
+	if typ != "" {
+		var ok bool
+		if types, ok = notificationTypeFilters[typ]; !ok {
+			return nil, 0, ErrorInvalidNotificationType
+		}
+	}
+	total, err := mongodb.CountNotifications(userID, types)
+	if err != nil {
+		return nil, 0, err
+	}
+	list, err := mongodb.GetNotifications(userID, types, page, size)
+	return list, total, err
+}
+
+// MarkNotificationsRead 标记已读后从mongodb重新统计未读数，避免计数漂移
+func MarkNotificationsRead(userID int64, ids []int64) error {
+	if err := mongodb.MarkNotificationsRead(userID, ids); err != nil {
+		return err
+	}
+	_, err := rebuildUnreadCount(userID)
+	return err
+}
+
+// MarkAllNotificationsRead 把所有通知标记为已读，返回之后的未读数
+// 删除redis中的计数而不是写入统计的结果，并发调用或同时有新通知时计数都不会出错
+func MarkAllNotificationsRead(userID int64) (*models.UnreadCount, error) {
+	if err := mongodb.MarkNotificationsRead(userID, nil); err != nil {
+		return nil, err
+	}
+	if err := redis.DeleteUnreadCount(userID); err != nil {
+		return nil, err
+	}
+	count, err := mongodb.CountUnreadNotifications(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.UnreadCount{Unread: count}, nil
+}
+
+// GetUnreadCount 优先读redis中的计数，不存在时从mongodb统计并写回redis
+func GetUnreadCount(userID int64) (*models.UnreadCount, error) {
+	count, err := redis.GetUnreadCount(userID)
+	if err == nil {
+		return &models.UnreadCount{Unread: count}, nil
+	}
+	if err != goredis.Nil {
+		return nil, err
+	}
+	count, err = rebuildUnreadCount(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.UnreadCount{Unread: count}, nil
+}
+
+func rebuildUnreadCount(userID int64) (int64, error) {
+	count, err := mongodb.CountUnreadNotifications(userID)
+	if err != nil {
+		return 0, err
+	}
+	if err := redis.SetUnreadCount(userID, count); err != nil {
+		zap.L().Warn("redis.SetUnreadCount failed", zap.Int64("userID", userID), zap.Error(err))
+	}
+	return count, nil
+}
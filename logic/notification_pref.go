@@ -0,0 +1,75 @@
+package logic
+
+import (
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+
+	"go.uber.org/zap"
+)
+
+// GetNotificationPref 优先读redis中的缓存，没有缓存时从mysql读取并写回
+func GetNotificationPref(userID int64) (*models.NotificationPref, error) {
+	pref, err := redis.GetNotificationPrefCache(userID)
+	if err != nil {
+		zap.L().Warn("redis.GetNotificationPrefCache failed", zap.Int64("uid", userID), zap.Error(err))
+	}
+	if pref != nil {
+		return pref, nil
+	}
+	pref, err = mysql.GetNotificationPref(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := redis.SetNotificationPrefCache(userID, pref); err != nil {
+		zap.L().Warn("redis.SetNotificationPrefCache failed", zap.Int64("uid", userID), zap.Error(err))
+	}
+	return pref, nil
+}
+
+// UpdateNotificationPref 修改传了的类型和免打扰时段，保存后删除缓存
+func UpdateNotificationPref(userID int64, p *models.ParamNotificationPref) (*models.NotificationPref, error) {
+	pref, err := mysql.GetNotificationPref(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range []struct {
+		param *bool
+		field *bool
+	}{
+		{p.Reply, &pref.Reply},
+		{p.Vote, &pref.Vote},
+		{p.Follow, &pref.Follow},
+		{p.Mention, &pref.Mention},
+		{p.Digest, &pref.Digest},
+		{p.Trending, &pref.Trending},
+	} {
+		if f.param != nil {
+			*f.field = *f.param
+		}
+	}
+	if q := p.QuietHours; q != nil {
+		if q.Start == "" {
+			pref.QuietStart, pref.QuietEnd, pref.QuietTimezone = "", "", ""
+		} else {
+			pref.QuietStart, pref.QuietEnd, pref.QuietTimezone = q.Start, q.End, q.Timezone
+		}
+	}
+	if err = mysql.SaveNotificationPref(userID, pref); err != nil {
+		return nil, err
+	}
+	if err := redis.DeleteNotificationPrefCache(userID); err != nil {
+		zap.L().Warn("redis.DeleteNotificationPrefCache failed", zap.Int64("uid", userID), zap.Error(err))
+	}
+	return pref, nil
+}
+
+// notificationAllowed 用户是否接收typ类型的通知，读取设置失败时照常发送
+func notificationAllowed(userID int64, typ string) bool {
+	pref, err := GetNotificationPref(userID)
+	if err != nil {
+		zap.L().Warn("GetNotificationPref failed", zap.Int64("uid", userID), zap.Error(err))
+		return true
+	}
+	return pref.Allows(typ)
+}
@@ -0,0 +1,53 @@
+package logic
+
+import (
+	"go-web-app/dao/mongodb"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"testing"
+	"time"
+
+	goredis "github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetNotificationsInvalidType(t *testing.T) {
+	// 只能使用过滤的名称，不能直接使用通知的类型
+	_, _, err := GetNotifications(1, models.NotificationMentionPost, 1, 10)
+	assert.ErrorIs(t, err, ErrorInvalidNotificationType)
+	_, _, err = GetNotifications(1, "unknown", 1, 10)
+	assert.ErrorIs(t, err, ErrorInvalidNotificationType)
+}
+
+func TestMarkAllNotificationsRead(t *testing.T) {
+	useMongo(t)
+	mr := useMiniredis(t)
+	now := time.Now()
+	for i, typ := range []string{models.NotificationMentionPost, models.NotificationMentionComment, models.NotificationVote} {
+		require.NoError(t, mongodb.CreateNotification(&models.Notification{
+			NotificationID: int64(i + 1),
+			RecipientID:    7,
+			Type:           typ,
+			CreateTime:     now,
+		}))
+	}
+	require.NoError(t, redis.SetUnreadCount(7, 3))
+
+	// mention包含帖子和评论中的@
+	list, total, err := GetNotifications(7, "mention", 1, 10)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, total)
+	assert.Len(t, list, 2)
+
+	count, err := MarkAllNotificationsRead(7)
+	require.NoError(t, err)
+	assert.Zero(t, count.Unread)
+	_, err = redis.GetUnreadCount(7)
+	assert.Equal(t, goredis.Nil, err)
+
+	// redis不可用时返回错误，计数在下次读取时重新统计
+	mr.Close()
+	_, err = MarkAllNotificationsRead(7)
+	assert.Error(t, err)
+}
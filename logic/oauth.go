@@ -0,0 +1,275 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/pkg/jwt"
+	"go-web-app/pkg/snowflake"
+	"go-web-app/settings"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/endpoints"
+)
+
+const (
+	oauthStateExpire   = 10 * time.Minute
+	oauthFetchTimeout  = 10 * time.Second
+	googleUserInfoURL  = "https://openidconnect.googleapis.com/v1/userinfo"
+	maxUsernameRetries = 5
+)
+
+var (
+	ErrorOAuthNotConfigured    = errors.New("OAuth login is not configured. ")
+	ErrorOAuthStateInvalid     = errors.New("OAuth state is invalid or expired. ")
+	ErrorOAuthEmailNotVerified = errors.New("OAuth email is not verified. ")
+	ErrorOAuthEmailDomain      = errors.New("Only Rutgers email accounts can sign in. ")
+)
+
+// oauthAccountAction 第三方登录时对本地账号的处理方式
+type oauthAccountAction int
+
+const (
+	oauthCreateAccount oauthAccountAction = iota // 邮箱没有注册过，创建新账号
+	oauthLinkAccount                             // 原账号邮箱已验证，直接绑定
+	oauthLinkAndReset                            // 原账号邮箱未验证，绑定后重置密码并踢下线
+)
+
+// decideOAuthAccount 决定第三方登录的用户如何对应到本地账号，existing为邮箱相同的已有账号
+// 未验证的邮箱可能是别人抢注的，直接绑定会让抢注者保留密码登录，所以绑定前要重置密码
+func decideOAuthAccount(u *models.OAuthUser, existing *models.User, domains []string) (oauthAccountAction, error) {
+	if !u.EmailVerified {
+		return 0, ErrorOAuthEmailNotVerified
+	}
+	if !IsAllowedEmailDomain(u.Email, domains) {
+		return 0, ErrorOAuthEmailDomain
+	}
+	switch {
+	case existing == nil:
+		return oauthCreateAccount, nil
+	case existing.EmailVerified:
+		return oauthLinkAccount, nil
+	default:
+		return oauthLinkAndReset, nil
+	}
+}
+
+func googleOAuthConfig() (*oauth2.Config, error) {
+	cfg := settings.Current().OAuthConfig
+	if cfg == nil || cfg.GoogleClientID == "" {
+		return nil, ErrorOAuthNotConfigured
+	}
+	return &oauth2.Config{
+		ClientID:     cfg.GoogleClientID,
+		ClientSecret: cfg.GoogleClientSecret,
+		RedirectURL:  cfg.GoogleRedirectURL,
+		Endpoint:     endpoints.Google,
+		Scopes:       []string{"openid", "email", "profile"},
+	}, nil
+}
+
+// GoogleLoginURL 生成跳转到Google授权页面的地址，state同时需要由客户端保存，回调时校验
+func GoogleLoginURL() (authURL, state string, err error) {
+	conf, err := googleOAuthConfig()
+	if err != nil {
+		return "", "", err
+	}
+	if state, err = randomToken(); err != nil {
+		return "", "", err
+	}
+	if err = redis.SetOAuthState(state, oauthStateExpire); err != nil {
+		return "", "", err
+	}
+	return conf.AuthCodeURL(state, oauth2.SetAuthURLParam("prompt", "select_account")), state, nil
+}
+
+// GoogleCallback 校验state并用code换取Google的用户信息
+func GoogleCallback(ctx context.Context, state, code string) (*models.OAuthUser, error) {
+	conf, err := googleOAuthConfig()
+	if err != nil {
+		return nil, err
+	}
+	ok, err := redis.ConsumeOAuthState(state)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrorOAuthStateInvalid
+	}
+	ctx, cancel := context.WithTimeout(ctx, oauthFetchTimeout)
+	defer cancel()
+	token, err := conf.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("exchange code: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, googleUserInfoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := conf.Client(ctx, token).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch userinfo: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch userinfo: unexpected status %s", resp.Status)
+	}
+	var info struct {
+		Sub           string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("decode userinfo: %w", err)
+	}
+	if info.Sub == "" || info.Email == "" {
+		return nil, errors.New("userinfo is missing sub or email")
+	}
+	return &models.OAuthUser{
+		Provider:      models.OAuthProviderGoogle,
+		Subject:       info.Sub,
+		Email:         strings.ToLower(info.Email),
+		EmailVerified: info.EmailVerified,
+		Name:          info.Name,
+	}, nil
+}
+
+// OAuthLogin 第三方登录：已绑定时直接登录；邮箱已经注册时绑定到原账号；否则创建新账号
+func OAuthLogin(ctx context.Context, u *models.OAuthUser, client *models.ClientInfo) (userID int64, token *models.Token, err error) {
+	if _, err = decideOAuthAccount(u, nil, allowedEmailDomains()); err != nil {
+		return 0, nil, err
+	}
+	user, err := mysql.GetUserByOAuth(u.Provider, u.Subject)
+	if errors.Is(err, mysql.ErrorUserNotExist) {
+		user, err = linkOrCreateOAuthUser(ctx, u)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	if user.Deactivated {
+		return 0, nil, ErrorAccountDeactivated
+	}
+	if user.Banned {
+		return 0, nil, ErrorAccountBanned
+	}
+	token, err = issueToken(user.UserID, user.Username, jwt.RefreshTokenExpire(), client)
+	return user.UserID, token, err
+}
+
+func linkOrCreateOAuthUser(ctx context.Context, u *models.OAuthUser) (*models.User, error) {
+	existing, err := mysql.GetUserByEmail(u.Email)
+	if err != nil && !errors.Is(err, mysql.ErrorUserNotExist) {
+		return nil, err
+	}
+	action, err := decideOAuthAccount(u, existing, allowedEmailDomains())
+	if err != nil {
+		return nil, err
+	}
+	switch action {
+	case oauthLinkAccount:
+		// 邮箱已经用密码注册过，绑定到原账号而不是再创建一个
+		if err = mysql.LinkOAuth(existing.UserID, u.Provider, u.Subject, ""); err != nil {
+			return nil, err
+		}
+		zap.L().Info("oauth account linked", zap.Int64("userID", existing.UserID), zap.String("provider", u.Provider))
+		return existing, nil
+	case oauthLinkAndReset:
+		password, err := randomToken()
+		if err != nil {
+			return nil, err
+		}
+		if err = mysql.LinkOAuth(existing.UserID, u.Provider, u.Subject, password); err != nil {
+			return nil, err
+		}
+		if err = redis.DeleteSessions(existing.UserID); err != nil {
+			return nil, err
+		}
+		zap.L().Warn("oauth account linked to unverified email, password reset",
+			zap.Int64("userID", existing.UserID), zap.String("provider", u.Provider))
+		existing.EmailVerified = true
+		return existing, nil
+	}
+	username, err := availableUsername(u.Email)
+	if err != nil {
+		return nil, err
+	}
+	// 随机密码，用户需要时可以通过找回密码设置
+	password, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	user := &models.User{
+		UserID:   snowflake.GenID(),
+		Username: username,
+		Password: password,
+		Email:    u.Email,
+	}
+	if err = mysql.InsertOAuthUser(user, u.Provider, u.Subject, signupCommunities(), afterSignupJoin(ctx, user.UserID)); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func allowedEmailDomains() []string {
+	if cfg := settings.Current().AuthConfig; cfg != nil {
+		return cfg.AllowedEmailDomains
+	}
+	return nil
+}
+
+// IsAllowedEmailDomain 邮箱域名是否在允许注册的列表中，不区分大小写
+func IsAllowedEmailDomain(email string, domains []string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, d := range domains {
+		if domain == strings.ToLower(strings.TrimPrefix(d, "@")) {
+			return true
+		}
+	}
+	return false
+}
+
+// availableUsername 用邮箱的用户名部分作为用户名，已经被占用时加上随机数字
+func availableUsername(email string) (string, error) {
+	base := email
+	if at := strings.Index(email, "@"); at >= 0 {
+		base = email[:at]
+	}
+	base = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '.' {
+			return r
+		}
+		return -1
+	}, strings.ToLower(base))
+	if base == "" {
+		base = "user"
+	}
+	if len(base) > 48 {
+		base = base[:48]
+	}
+	name := base
+	for i := 0; i < maxUsernameRetries; i++ {
+		err := checkUsernameAvailable(0, name)
+		if err == nil {
+			return name, nil
+		}
+		if !errors.Is(err, mysql.ErrorUserExist) {
+			return "", err
+		}
+		name = fmt.Sprintf("%s_%04d", base, rand.Intn(10000))
+	}
+	return "", mysql.ErrorUserExist
+}
@@ -0,0 +1,39 @@
+package logic
+
+import (
+	"go-web-app/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecideOAuthAccount(t *testing.T) {
+	domains := []string{"rutgers.edu", "@scarletmail.rutgers.edu"}
+	google := func(email string) *models.OAuthUser {
+		return &models.OAuthUser{Provider: models.OAuthProviderGoogle, Subject: "sub", Email: email, EmailVerified: true}
+	}
+
+	action, err := decideOAuthAccount(google("new@rutgers.edu"), nil, domains)
+	require.NoError(t, err)
+	assert.Equal(t, oauthCreateAccount, action)
+
+	action, err = decideOAuthAccount(google("old@Scarletmail.Rutgers.edu"), &models.User{UserID: 1, EmailVerified: true}, domains)
+	require.NoError(t, err)
+	assert.Equal(t, oauthLinkAccount, action)
+
+	// 邮箱没验证过的账号可能是抢注的，绑定时要重置密码
+	action, err = decideOAuthAccount(google("old@rutgers.edu"), &models.User{UserID: 1}, domains)
+	require.NoError(t, err)
+	assert.Equal(t, oauthLinkAndReset, action)
+
+	_, err = decideOAuthAccount(google("someone@gmail.com"), nil, domains)
+	assert.ErrorIs(t, err, ErrorOAuthEmailDomain)
+	_, err = decideOAuthAccount(google("someone@evilrutgers.edu"), &models.User{UserID: 1, EmailVerified: true}, domains)
+	assert.ErrorIs(t, err, ErrorOAuthEmailDomain)
+
+	u := google("new@rutgers.edu")
+	u.EmailVerified = false
+	_, err = decideOAuthAccount(u, nil, domains)
+	assert.ErrorIs(t, err, ErrorOAuthEmailNotVerified)
+}
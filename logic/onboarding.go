@@ -0,0 +1,175 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/pkg/snowflake"
+	"go-web-app/settings"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// onboardingCandidates 每个社区最多取多少个最近的帖子参与排序
+const onboardingCandidates = 50
+
+// isNewUser 注册不满feed.onboarding_age小时的用户，调用方已经确认没有关注任何人
+// 注册时会自动加入默认社区，所以不能按是否加入了社区判断
+func isNewUser(userID int64) bool {
+	age := settings.Current().FeedConfig.OnboardingAge
+	return age > 0 && time.Since(snowflake.Time(userID)) < time.Duration(age)*time.Hour
+}
+
+// getOnboardingFeed 新用户的首页，取最近最活跃的几个社区中赞成票最多的帖子，各社区轮流排列
+// 没有活跃的社区时返回全站热门帖子
+func getOnboardingFeed(ctx context.Context, p *models.ParamFeed) (*models.PostFeed, error) {
+	cfg := settings.Current().FeedConfig
+	if cfg.OnboardingCommunities <= 0 {
+		return getHotFeed(ctx, p)
+	}
+	feed := &models.PostFeed{List: make([]*models.PostDetail, 0)}
+	// 和热门列表一样不支持游标，只返回第一页
+	if feedCursor(p) != 0 {
+		return feed, nil
+	}
+	since := time.Now().Add(-time.Duration(cfg.OnboardingWindow) * time.Hour)
+	communityIDs, err := mysql.GetActiveCommunityIDs(since, cfg.OnboardingCommunities)
+	if err != nil {
+		return nil, err
+	}
+	if len(communityIDs) == 0 {
+		return getHotFeed(ctx, p)
+	}
+	ranked := make([][]string, 0, len(communityIDs))
+	for _, cid := range communityIDs {
+		ids, err := topCommunityPostIDs(cid, since)
+		if err != nil {
+			zap.L().Warn("topCommunityPostIDs failed", zap.Int64("community_id", cid), zap.Error(err))
+			continue
+		}
+		ranked = append(ranked, ids)
+	}
+	ids := interleaveIDs(ranked, int(p.Size))
+	if len(ids) == 0 {
+		return getHotFeed(ctx, p)
+	}
+	data, err := getPostDetailListByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	feed.List = filterPublicPosts(data)
+	return feed, nil
+}
+
+// topCommunityPostIDs 社区中since之后发布的帖子，按赞成票数从多到少排列
+func topCommunityPostIDs(communityID int64, since time.Time) ([]string, error) {
+	ids, err := redis.GetCommunityPostIDsSince(communityID, since, onboardingCandidates)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	votes, err := redis.GetPostVoteData(ids)
+	if err != nil {
+		return nil, err
+	}
+	idx := make([]int, len(ids))
+	for i := range idx {
+		idx[i] = i
+	}
+	// 票数相同时保持时间倒序
+	sort.SliceStable(idx, func(a, b int) bool { return votes[idx[a]] > votes[idx[b]] })
+	sorted := make([]string, 0, len(ids))
+	for _, i := range idx {
+		sorted = append(sorted, ids[i])
+	}
+	return sorted, nil
+}
+
+// interleaveIDs 依次从每个列表中取一个，跳过重复的id(同时发布到多个社区的帖子)，最多取size个
+func interleaveIDs(lists [][]string, size int) []string {
+	res := make([]string, 0, size)
+	seen := make(map[string]struct{}, size)
+	for i := 0; len(res) < size; i++ {
+		more := false
+		for _, list := range lists {
+			if i >= len(list) {
+				continue
+			}
+			more = true
+			if _, ok := seen[list[i]]; ok {
+				continue
+			}
+			seen[list[i]] = struct{}{}
+			res = append(res, list[i])
+			if len(res) == size {
+				break
+			}
+		}
+		if !more {
+			break
+		}
+	}
+	return res
+}
+
+// signupCommunities 新用户注册时自动加入的社区，community.auto_join为false时返回nil
+func signupCommunities() []int64 {
+	cfg := settings.Current().CommunityConfig
+	if !cfg.AutoJoin {
+		return nil
+	}
+	return cfg.DefaultCommunities
+}
+
+// afterSignupJoin 注册的事务中加入社区之后更新成员数，已经不存在的社区被跳过时记录警告
+// 成员数更新失败时不影响注册，计数会在下次重建时修正
+func afterSignupJoin(ctx context.Context, userID int64) func(joined []int64) error {
+	return func(joined []int64) error {
+		set := make(map[int64]bool, len(joined))
+		for _, cid := range joined {
+			set[cid] = true
+			if err := redis.IncrCommunityMemberCount(cid, 1); err != nil {
+				zap.L().Warn("redis.IncrCommunityMemberCount failed", zap.Int64("community_id", cid), zap.Error(err))
+			}
+			recordCommunityJoin(ctx, cid)
+		}
+		for _, cid := range signupCommunities() {
+			if !set[cid] {
+				zap.L().Warn("community.default_communities contains a missing community, skipped",
+					zap.Int64("community_id", cid), zap.Int64("userID", userID))
+			}
+		}
+		return nil
+	}
+}
+
+// checkSignupCommunities 启动时检查注册时自动加入的社区是否存在，返回配置的问题，查询失败时只记录警告
+func checkSignupCommunities(ctx context.Context) (problems []string) {
+	ids := signupCommunities()
+	if len(ids) == 0 {
+		return nil
+	}
+	communities, err := GetCommunitiesByIDs(ctx, ids)
+	if err != nil {
+		zap.L().Warn("check default communities failed", zap.Error(err))
+		return nil
+	}
+	for _, id := range ids {
+		if _, ok := communities[id]; !ok {
+			problems = append(problems, fmt.Sprintf("community.default_communities: community %d does not exist", id))
+		}
+	}
+	return problems
+}
+
+// CheckCommunityConfig 连接mysql之后检查配置中的社区是否存在，所有的问题一起返回
+func CheckCommunityConfig(ctx context.Context) error {
+	problems := append(checkDefaultCommunity(ctx), checkSignupCommunities(ctx)...)
+	if len(problems) > 0 {
+		return &settings.ValidationError{Problems: problems}
+	}
+	return nil
+}
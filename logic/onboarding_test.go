@@ -0,0 +1,69 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/pkg/snowflake"
+	"go-web-app/settings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsNewUser(t *testing.T) {
+	old := settings.Conf.FeedConfig
+	settings.Conf.FeedConfig = &settings.FeedConfig{OnboardingAge: 72}
+	t.Cleanup(func() { settings.Conf.FeedConfig = old })
+
+	// 注册时自动加入了社区也按注册时间判断
+	assert.True(t, isNewUser(snowflake.MinID(time.Now().Add(-time.Hour))))
+	assert.False(t, isNewUser(snowflake.MinID(time.Now().Add(-73*time.Hour))))
+
+	settings.Conf.FeedConfig.OnboardingAge = 0
+	assert.False(t, isNewUser(snowflake.MinID(time.Now().Add(-time.Hour))))
+}
+
+func TestCheckCommunityConfigCached(t *testing.T) {
+	useMiniredis(t)
+	oldPost := settings.Conf.PostConfig
+	settings.Conf.PostConfig = &settings.PostConfig{DefaultCommunity: 1 << 50}
+	t.Cleanup(func() { settings.Conf.PostConfig = oldPost })
+	useCommunityConfig(t, &settings.CommunityConfig{CacheTTL: 60, AutoJoin: true, DefaultCommunities: []int64{1<<50 + 1}})
+	t.Cleanup(func() { InvalidateCommunityCache(1<<50, 1<<50+1) })
+	// 缓存中的社区不需要查询数据库
+	require.NoError(t, redis.SetCommunityDetailCaches(map[int64]*models.CommunityDetail{
+		1 << 50:   {ID: 1 << 50, Name: "general"},
+		1<<50 + 1: {ID: 1<<50 + 1, Name: "welcome"},
+	}, time.Minute))
+	assert.NoError(t, CheckCommunityConfig(context.Background()))
+
+	// 关闭自动加入和要求选择社区时不检查
+	settings.Conf.PostConfig.RequireCommunity = true
+	settings.Conf.CommunityConfig.AutoJoin = false
+	settings.Conf.CommunityConfig.DefaultCommunities = []int64{1 << 60}
+	assert.NoError(t, CheckCommunityConfig(context.Background()))
+}
+
+func TestCheckCommunityConfigMissing(t *testing.T) {
+	useMySQL(t)
+	useMiniredis(t)
+	existing := createTestCommunity(t, models.CommunityVisibilityPublic)
+	oldPost := settings.Conf.PostConfig
+	settings.Conf.PostConfig = &settings.PostConfig{DefaultCommunity: 1 << 60}
+	t.Cleanup(func() { settings.Conf.PostConfig = oldPost })
+	useCommunityConfig(t, &settings.CommunityConfig{AutoJoin: true, DefaultCommunities: []int64{existing, 1<<60 + 1}})
+
+	// 所有不存在的社区一起报告
+	err := CheckCommunityConfig(context.Background())
+	var verr *settings.ValidationError
+	require.True(t, errors.As(err, &verr))
+	assert.Equal(t, []string{
+		fmt.Sprintf("post.default_community: community %d does not exist", int64(1<<60)),
+		fmt.Sprintf("community.default_communities: community %d does not exist", int64(1<<60+1)),
+	}, verr.Problems)
+}
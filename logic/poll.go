@@ -0,0 +1,125 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"go-web-app/dao/mongodb"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"strings"
+	"time"
+)
+
+var (
+	ErrorTooManyPollOptions  = errors.New("Too many poll options. ")
+	ErrorDuplicatePollOption = errors.New("Duplicate poll options. ")
+	ErrorEmptyPollOption     = errors.New("Poll options cannot be empty. ")
+	ErrorPollExpireInPast    = errors.New("Poll expire time is in the past. ")
+	ErrorPollClosed          = errors.New("Poll is closed. ")
+	ErrorInvalidPollOption   = errors.New("Invalid poll option. ")
+)
+
+// checkPoll 检查创建帖子时附带的投票，选项去掉首尾空白后不能为空，也不能重复
+func checkPoll(p *models.ParamPoll) error {
+	if p == nil {
+		return nil
+	}
+	if max := settings.Current().PostConfig.PollMaxOptions; max > 0 && len(p.Options) > max {
+		return ErrorTooManyPollOptions
+	}
+	seen := make(map[string]struct{}, len(p.Options))
+	for i, o := range p.Options {
+		o = strings.TrimSpace(o)
+		if o == "" {
+			return ErrorEmptyPollOption
+		}
+		if _, ok := seen[o]; ok {
+			return ErrorDuplicatePollOption
+		}
+		seen[o] = struct{}{}
+		p.Options[i] = o
+	}
+	p.Question = strings.TrimSpace(p.Question)
+	if p.ExpireAt != nil && !p.ExpireAt.After(time.Now()) {
+		return ErrorPollExpireInPast
+	}
+	return nil
+}
+
+// createPoll 保存帖子附带的投票，在帖子写入数据库之前调用，
+// 帖子创建失败时留下的投票不会被查询到
+func createPoll(pid int64, p *models.ParamPoll) error {
+	if p == nil {
+		return nil
+	}
+	return mongodb.CreatePoll(&models.Poll{
+		PostID:     pid,
+		Question:   p.Question,
+		Options:    p.Options,
+		ExpireAt:   p.ExpireAt,
+		CreateTime: time.Now(),
+	})
+}
+
+// GetPollResult 帖子投票的当前结果，帖子没有投票时返回nil，userID为0表示未登录
+func GetPollResult(pid, userID int64) (*models.PollResult, error) {
+	poll, err := mongodb.GetPoll(pid)
+	if err != nil || poll == nil {
+		return nil, err
+	}
+	return pollResult(poll, userID)
+}
+
+func pollResult(poll *models.Poll, userID int64) (*models.PollResult, error) {
+	votes, choice, err := redis.GetPollTally(poll.PostID, userID, len(poll.Options))
+	if err != nil {
+		return nil, err
+	}
+	res := &models.PollResult{
+		Question:   poll.Question,
+		Options:    make([]*models.PollOption, 0, len(poll.Options)),
+		ExpireAt:   poll.ExpireAt,
+		Closed:     pollClosed(poll),
+		UserChoice: choice,
+	}
+	for i, text := range poll.Options {
+		res.Options = append(res.Options, &models.PollOption{Text: text, Votes: votes[i]})
+		res.TotalVotes += votes[i]
+	}
+	return res, nil
+}
+
+func pollClosed(poll *models.Poll) bool {
+	return poll.ExpireAt != nil && !time.Now().Before(*poll.ExpireAt)
+}
+
+// VotePoll 用户在帖子的投票中选择一个选项，每个用户只能投一次，截止之后不能再投
+func VotePoll(ctx context.Context, userID, pid int64, option int) (*models.PollResult, error) {
+	// 隐藏、未发布的帖子和不存在的帖子一样处理
+	post, err := GetPostById(ctx, pid)
+	if err != nil {
+		return nil, err
+	}
+	if err = CheckPostAccess(ctx, userID, post.Post); err != nil {
+		return nil, err
+	}
+	poll, err := mongodb.GetPoll(pid)
+	if err != nil {
+		return nil, err
+	}
+	if poll == nil {
+		return nil, mysql.ErrorInvalidID
+	}
+	if pollClosed(poll) {
+		return nil, ErrorPollClosed
+	}
+	if option < 0 || option >= len(poll.Options) {
+		return nil, ErrorInvalidPollOption
+	}
+	if err = redis.VotePoll(pid, userID, option); err != nil {
+		return nil, err
+	}
+	return pollResult(poll, userID)
+}
@@ -0,0 +1,29 @@
+package logic
+
+import (
+	"go-web-app/models"
+	"go-web-app/settings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckPoll(t *testing.T) {
+	old := settings.Conf.PostConfig
+	settings.Conf.PostConfig = &settings.PostConfig{PollMaxOptions: 3}
+	t.Cleanup(func() { settings.Conf.PostConfig = old })
+
+	p := &models.ParamPoll{Question: " lunch? ", Options: []string{" pizza ", "tacos"}}
+	require.NoError(t, checkPoll(p))
+	assert.Equal(t, "lunch?", p.Question)
+	assert.Equal(t, []string{"pizza", "tacos"}, p.Options)
+
+	// 去掉空白之后为空或者重复的选项都不允许
+	p = &models.ParamPoll{Options: []string{"pizza", "   "}}
+	assert.ErrorIs(t, checkPoll(p), ErrorEmptyPollOption)
+	p = &models.ParamPoll{Options: []string{"pizza", " pizza"}}
+	assert.ErrorIs(t, checkPoll(p), ErrorDuplicatePollOption)
+	p = &models.ParamPoll{Options: []string{"a", "b", "c", "d"}}
+	assert.ErrorIs(t, checkPoll(p), ErrorTooManyPollOptions)
+}
@@ -1,79 +1,344 @@
 package logic
 
 import (
-	"fmt"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"go-web-app/dao/mongodb"
 	"go-web-app/dao/mysql"
 	"go-web-app/dao/redis"
 	"go-web-app/models"
+	"go-web-app/pkg/highlight"
+	"go-web-app/pkg/markdown"
 	"go-web-app/pkg/snowflake"
+	"go-web-app/settings"
+	"html"
+	"strconv"
+	"time"
 
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 )
 
-func CreatePost(p *models.Post) (err error) {
+func CreatePost(ctx context.Context, p *models.Post) (err error) {
+	if err = CheckAccountAge(p.AuthorId, AccountActionPost); err != nil {
+		return err
+	}
+	if err = checkMentionAge(p.AuthorId, p.Content); err != nil {
+		return err
+	}
+	if p.Tags, err = normalizeTags(p.Tags); err != nil {
+		return err
+	}
+	if err = checkPublishAt(p); err != nil {
+		return err
+	}
+	if err = checkPoll(p.Poll); err != nil {
+		return err
+	}
+	ids, err := normalizePostCommunities(p.CommunityID, p.CommunityIDs)
+	if errors.Is(err, ErrorNoCommunity) {
+		ids, err = defaultPostCommunities()
+	}
+	if err != nil {
+		return err
+	}
+	if err = checkPostCommunities(ctx, p.AuthorId, ids); err != nil {
+		return err
+	}
+	if err = checkPostPermission(ctx, p.AuthorId, ids); err != nil {
+		return err
+	}
+	if err = checkAnonymousAllowed(ctx, p.Anonymous, ids); err != nil {
+		return err
+	}
+	if err = checkPostLength(ctx, p.Content, ids); err != nil {
+		return err
+	}
+	p.CommunityID, p.CommunityIDs = ids[0], ids
+	if err = checkPostFlair(p.CommunityID, p.Flair); err != nil {
+		return err
+	}
+	spam := checkSpamLinks(p.AuthorId, p.Title, p.Content)
+	if spam != nil {
+		if !holdSpamLinks(p.Status) {
+			return ErrorSpamLink
+		}
+		p.Status = models.PostStatusHidden
+	}
+	digests, err := checkDuplicatePost(p)
+	if err != nil {
+		return err
+	}
 	p.PostID = snowflake.GenID()
+	p.Slug = postSlug(p.Title)
+	if err = createPoll(p.PostID, p.Poll); err != nil {
+		return err
+	}
 	err = mysql.CreatePost(p)
 	if err != nil {
 		return err
 	}
-	err = redis.CreatePost(p.PostID, p.CommunityID)
-	return
+	recordPostDigests(p, digests)
+	// 草稿在发布时、定时帖子在到发布时间之后再加入列表
+	if isUnpublished(p) {
+		renderPost(p.PostID, p.Content)
+		return nil
+	}
+	if err = redis.CreatePost(p.PostID, p.CommunityIDs, listedAuthor(p), p.Tags); err != nil {
+		return err
+	}
+	if err = addPostFlair(p); err != nil {
+		return err
+	}
+	// 隐藏的帖子在版主处理之前不计入热度，也不通知被@的用户
+	if spam != nil {
+		reportSpamLinks(p, spam)
+		renderPost(p.PostID, p.Content)
+		return nil
+	}
+	recordTagActivity(p.Tags)
+	recordCommunityPost(ctx, p.CommunityIDs)
+	r := renderPost(p.PostID, p.Content)
+	notifyPostMentions(p, r.Mentions)
+	announcePost(ctx, p, r.Excerpt)
+	return nil
+}
+
+var ErrorIdempotencyConflict = errors.New("Idempotency key conflict. ")
+
+// CreatePostIdempotent 带幂等键创建帖子，同一个键重试时返回第一次创建的帖子id。
+// 同一个键对应不同的请求内容，或者第一次的请求还没处理完时返回ErrorIdempotencyConflict
+func CreatePostIdempotent(ctx context.Context, p *models.Post, key string) (int64, error) {
+	fingerprint := postFingerprint(p)
+	ttl := time.Duration(settings.Current().PostConfig.IdempotencyTTL) * time.Hour
+	reserved, oldFingerprint, postID, err := redis.ReserveIdempotencyKey(p.AuthorId, key, fingerprint, ttl)
+	if err != nil {
+		return 0, err
+	}
+	if !reserved {
+		if oldFingerprint != fingerprint || postID == 0 {
+			return 0, ErrorIdempotencyConflict
+		}
+		return postID, nil
+	}
+	if err = CreatePost(ctx, p); err != nil {
+		// 创建失败时释放幂等键，客户端可以用同一个键重试
+		if e := redis.ReleaseIdempotencyKey(p.AuthorId, key); e != nil {
+			zap.L().Error("redis.ReleaseIdempotencyKey failed", zap.String("key", key), zap.Error(e))
+		}
+		return 0, err
+	}
+	if err = redis.SetIdempotencyResult(p.AuthorId, key, fingerprint, p.PostID, ttl); err != nil {
+		// 帖子已经创建成功，保存结果失败只记录日志
+		zap.L().Error("redis.SetIdempotencyResult failed", zap.String("key", key), zap.Error(err))
+	}
+	return p.PostID, nil
+}
+
+// postFingerprint 请求内容的摘要，用来判断重试的请求和第一次的请求是否一致
+func postFingerprint(p *models.Post) string {
+	fields := []interface{}{p.CommunityID, p.CommunityIDs, p.Title, p.Content, p.Tags, p.PublishAt, p.Status}
+	// 没有投票时保持原来的摘要，升级前保存的幂等键仍然有效
+	if p.Poll != nil {
+		fields = append(fields, p.Poll)
+	}
+	if p.Flair != "" {
+		fields = append(fields, p.Flair)
+	}
+	b, _ := json.Marshal(fields)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// postDetailGroup 同一个帖子的缓存未命中时只有一个请求去数据库加载
+var postDetailGroup singleflight.Group
+
+// GetPostById 帖子详情，优先读redis缓存，缓存时间见post.detail_cache_ttl
+// 返回值可以被调用方修改，不会影响其他请求
+func GetPostById(ctx context.Context, pid int64) (*models.PostDetail, error) {
+	cached, err := redis.GetPostDetailCache(pid)
+	if err != nil {
+		zap.L().Warn("redis.GetPostDetailCache failed", zap.Int64("pid", pid), zap.Error(err))
+	}
+	if cached != nil {
+		return cached, nil
+	}
+	// 同时等待的请求共享同一次加载，不随其中某一个请求取消
+	v, err, _ := postDetailGroup.Do(strconv.FormatInt(pid, 10), func() (interface{}, error) {
+		data, err := loadPostDetail(detachContext(ctx), pid)
+		if err != nil {
+			return nil, err
+		}
+		if ttl := settings.Current().PostConfig.DetailCacheTTL; ttl > 0 {
+			if err := redis.SetPostDetailCache(data, time.Duration(ttl)*time.Second); err != nil {
+				zap.L().Warn("redis.SetPostDetailCache failed", zap.Int64("pid", pid), zap.Error(err))
+			}
+		}
+		return data, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// 同时等待的请求拿到的是同一个对象，复制一份再返回
+	return clonePostDetail(v.(*models.PostDetail)), nil
+}
+
+// clonePostDetail 深复制帖子详情，包括其中的帖子、社区和切片，调用方可以随意修改返回的对象
+func clonePostDetail(d *models.PostDetail) *models.PostDetail {
+	cp := *d
+	if d.Post != nil {
+		post := *d.Post
+		post.CommunityIDs = append([]int64(nil), d.Post.CommunityIDs...)
+		post.Tags = append([]string(nil), d.Post.Tags...)
+		if d.Post.PublishAt != nil {
+			at := *d.Post.PublishAt
+			post.PublishAt = &at
+		}
+		if d.Post.Poll != nil {
+			poll := *d.Post.Poll
+			poll.Options = append([]string(nil), d.Post.Poll.Options...)
+			if d.Post.Poll.ExpireAt != nil {
+				at := *d.Post.Poll.ExpireAt
+				poll.ExpireAt = &at
+			}
+			post.Poll = &poll
+		}
+		cp.Post = &post
+	}
+	if d.CommunityDetail != nil {
+		community := *d.CommunityDetail
+		cp.CommunityDetail = &community
+	}
+	if d.Preview != nil {
+		preview := *d.Preview
+		cp.Preview = &preview
+	}
+	if d.Mentions != nil {
+		cp.Mentions = make([]*models.Mention, 0, len(d.Mentions))
+		for _, m := range d.Mentions {
+			mention := *m
+			cp.Mentions = append(cp.Mentions, &mention)
+		}
+	}
+	return &cp
 }
 
-func GetPostById(pid int64) (data *models.PostDetail, err error) {
+// invalidatePostDetail 帖子的内容、状态或分数变化后删除缓存
+func invalidatePostDetail(pid int64) {
+	if err := redis.DeletePostDetailCache(pid); err != nil {
+		zap.L().Error("redis.DeletePostDetailCache failed", zap.Int64("pid", pid), zap.Error(err))
+	}
+}
+
+// loadPostDetail 从数据库加载帖子、作者和社区，以及redis中的投票数
+func loadPostDetail(ctx context.Context, pid int64) (data *models.PostDetail, err error) {
 	post, err := mysql.GetPostById(pid)
 	if err != nil {
 		zap.L().Error("mysql.GetPostById(pid) failed", zap.Int64("pid", pid), zap.Error(err))
 		return
 	}
+	// 被举报隐藏的帖子在版主处理之前不能查看，草稿和定时帖子在发布之前不能查看
+	if post.Status == models.PostStatusHidden || isUnpublished(post) {
+		return nil, sql.ErrNoRows
+	}
+	return buildPostDetail(ctx, post)
+}
+
+// GetPostByIdForUser 和GetPostById一样，但作者可以查看自己的草稿和定时帖子，草稿不写入缓存
+func GetPostByIdForUser(ctx context.Context, pid, userID int64) (*models.PostDetail, error) {
+	data, err := GetPostById(ctx, pid)
+	if !errors.Is(err, sql.ErrNoRows) || userID == 0 {
+		return data, err
+	}
+	post, perr := mysql.GetPostById(pid)
+	if perr != nil || post.AuthorId != userID || post.Status == models.PostStatusHidden || !isUnpublished(post) {
+		return nil, err
+	}
+	return buildPostDetail(ctx, post)
+}
+
+// buildPostDetail 填充帖子的作者、社区、投票数和渲染结果
+func buildPostDetail(ctx context.Context, post *models.Post) (data *models.PostDetail, err error) {
+	pid := post.PostID
+	fillPostTags(post)
+	if err = fillPostCommunities(post); err != nil {
+		zap.L().Error("fillPostCommunities failed", zap.Int64("pid", pid), zap.Error(err))
+		return
+	}
 	user, err := mysql.GetUserByID(post.AuthorId)
 	if err != nil {
 		zap.L().Error("mysql.GetUserById(post.AuthorId) failed", zap.Int64("author", post.AuthorId), zap.Error(err))
 		return
 	}
-	communityDetail, err := mysql.GetCommunityDetailByID(post.CommunityID)
+	communityDetail, err := getCommunityDetail(ctx, post.CommunityID)
+	if err != nil {
+		zap.L().Error("getCommunityDetail(ctx, post.CommunityID) failed", zap.Int64("CommunityID", post.CommunityID), zap.Error(err))
+		return
+	}
+	voteData, err := redis.GetPostVoteData([]string{strconv.FormatInt(pid, 10)})
 	if err != nil {
-		zap.L().Error("mysql.GetCommunityDetailByID(post.CommunityID) failed", zap.Int64("CommunityID", post.CommunityID), zap.Error(err))
 		return
 	}
 	data = &models.PostDetail{
-		AuthorName:      user.Username,
+		AuthorName:      displayName(user),
+		VoteNum:         voteData[0],
 		Post:            post,
 		CommunityDetail: communityDetail,
+		// 自动关闭和发布时间有关，缓存过期之前可能和实际状态不一致，发表评论时会重新检查
+		CommentsLocked: checkCommentsOpen(post, time.Now()) != nil,
 	}
+	render := loadPostRender(post)
+	data.HTML, data.Excerpt, data.Preview, data.Mentions = render.HTML, render.Excerpt, render.Preview, render.Mentions
+	anonymizePost(data)
+	data.ContentHash = postContentHash(data)
 	return
 }
 
-func GetPostList(page int64, size int64) (data []*models.PostDetail, err error) {
+// postContentHash 帖子详情中除投票数和收藏状态之外的内容的哈希
+func postContentHash(d *models.PostDetail) string {
+	content := *d
+	content.VoteNum, content.IsSaved = 0, false
+	b, _ := json.Marshal(&content)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:12])
+}
+
+func GetPostList(ctx context.Context, page int64, size int64) (data []*models.PostDetail, total int64, err error) {
+	if total, err = mysql.GetPublicPostCount(); err != nil {
+		return nil, 0, err
+	}
 	posts, err := mysql.GetPostList(page, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err = getPostDetailList(ctx, posts)
+	return
+}
+
+// GetPostListByCursor 基于游标的帖子列表，新帖子不会导致翻页时重复或遗漏
+func GetPostListByCursor(ctx context.Context, p *models.ParamFeed) (*models.PostFeed, error) {
+	posts, hasMore, err := mysql.GetPostListBefore(feedCursor(p), p.Size)
 	if err != nil {
 		return nil, err
 	}
-	data = make([]*models.PostDetail, 0, len(posts))
-	for _, post := range posts {
-		user, err := mysql.GetUserByID(post.AuthorId)
-		if err != nil {
-			zap.L().Error("mysql.GetUserByID() failed", zap.String("author_id", fmt.Sprint(post.AuthorId)), zap.Error(err))
-			continue
-		}
-		//post.AuthorName = user.UserName
-		community, err := mysql.GetCommunityDetailByID(post.CommunityID)
-		if err != nil {
-			zap.L().Error("mysql.GetCommunityByID() failed", zap.String("community_id", fmt.Sprint(post.CommunityID)), zap.Error(err))
-			continue
-		}
-		postDetail := &models.PostDetail{
-			AuthorName:      user.Username,
-			Post:            post,
-			CommunityDetail: community,
-		}
-		data = append(data, postDetail)
+	data, err := getPostDetailList(ctx, posts)
+	if err != nil {
+		return nil, err
 	}
-	return
+	feed := &models.PostFeed{List: data}
+	if hasMore {
+		feed.NextCursor = strconv.FormatInt(posts[len(posts)-1].PostID, 10)
+	}
+	return feed, nil
 }
 
-func GetPostList2(p *models.ParamPostList) (data []*models.PostDetail, err error) {
-	ids, err := redis.GetPostIDsInOrder(p)
+// GetPostList2 全站的帖子列表，不包括私有社区的帖子
+func GetPostList2(ctx context.Context, p *models.ParamPostList) (data []*models.PostDetail, hasMore bool, err error) {
+	ids, hasMore, err := redis.GetPostIDsInOrder(p)
 	if err != nil {
 		return
 	}
@@ -81,89 +346,336 @@ func GetPostList2(p *models.ParamPostList) (data []*models.PostDetail, err error
 		zap.L().Warn("redis.GetPostIDsInRoder(p) return empty dataset")
 		return
 	}
-	posts, err := mysql.GetPostListByIDs(ids)
-	if err != nil {
+	if data, err = getPostDetailListByIDs(ctx, ids); err != nil {
 		return
 	}
-	voteData, err := redis.GetPostVoteData(ids)
+	data = filterPublicPosts(data)
+	return
+}
+
+// GetCommunityPostList 置顶的帖子不受排序方式影响，只出现在第一页的最前面
+func GetCommunityPostList(ctx context.Context, p *models.ParamPostList) (data []*models.PostDetail, hasMore bool, err error) {
+	// 按flair筛选时置顶的帖子按正常的顺序出现
+	var pinned []string
+	if p.Flair == "" {
+		var perr error
+		if pinned, perr = redis.GetPinnedPostIDs(p.CommunityID); perr != nil {
+			zap.L().Warn("redis.GetPinnedPostIDs failed", zap.Int64("community_id", p.CommunityID), zap.Error(perr))
+			pinned = nil
+		}
+	}
+	// 置顶的帖子在查询时就排除，之后的每一页都是完整的
+	ids, hasMore, err := redis.GetCommunityPostIDsInOrder(p, pinned)
 	if err != nil {
 		return
 	}
-	for idx, post := range posts {
-		user, err := mysql.GetUserByID(post.AuthorId)
-		if err != nil {
-			zap.L().Error("mysql.GetUserByID() failed", zap.String("author_id", fmt.Sprint(post.AuthorId)), zap.Error(err))
-			continue
+	if p.Page == 1 {
+		ids = append(pinned, ids...)
+	}
+	if len(ids) == 0 {
+		zap.L().Warn("redis.GetPostIDsInRoder(p) return empty dataset")
+		return
+	}
+	if data, err = getPostDetailListByIDs(ctx, ids); err != nil {
+		return
+	}
+	if p.Page == 1 && len(pinned) > 0 {
+		markPinned(data, pinned)
+	}
+	return
+}
+
+func GetPostListNew(ctx context.Context, p *models.ParamPostList) (data []*models.PostDetail, hasMore bool, err error) {
+	// 根据请求参数的不同，执行不同的逻辑。
+	if p.CommunityID == 0 {
+		// query all communities
+		data, hasMore, err = GetPostList2(ctx, p)
+	} else {
+		// query with a single communityID
+		data, hasMore, err = GetCommunityPostList(ctx, p)
+	}
+	if err != nil {
+		zap.L().Error("GetPostListNew failed", zap.Error(err))
+		return nil, false, err
+	}
+	return
+}
+
+func SearchPosts(ctx context.Context, p *models.ParamSearch) (data []*models.PostDetail, total int64, err error) {
+	if total, err = mysql.SearchPostCount(ctx, p.Query); err != nil {
+		return nil, 0, err
+	}
+	posts, err := mysql.SearchPosts(ctx, p.Query, p.Page, p.Size)
+	if err != nil {
+		return nil, 0, err
+	}
+	// 列表中不返回内容，先生成片段
+	terms := highlight.Terms(p.Query)
+	maxLen := settings.Current().PostConfig.SearchSnippetLength
+	snippets := make(map[int64]string, len(posts))
+	for _, post := range posts {
+		snippets[post.PostID] = highlight.Snippet(markdown.PlainText(post.Content), terms, maxLen)
+	}
+	if data, err = getPostDetailList(ctx, posts); err != nil {
+		return nil, 0, err
+	}
+	for _, d := range data {
+		if d.Highlight = snippets[d.PostID]; d.Highlight == "" {
+			d.Highlight = html.EscapeString(d.Excerpt)
 		}
-		//post.AuthorName = user.UserName
-		community, err := mysql.GetCommunityDetailByID(post.CommunityID)
+	}
+	return data, total, nil
+}
+
+// getPostDetailListByIDs 按redis中的顺序取出帖子详情，已删除的帖子会被跳过
+func getPostDetailListByIDs(ctx context.Context, ids []string) ([]*models.PostDetail, error) {
+	pids := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		pid, err := strconv.ParseInt(id, 10, 64)
 		if err != nil {
-			zap.L().Error("mysql.GetCommunityByID() failed", zap.String("community_id", fmt.Sprint(post.CommunityID)), zap.Error(err))
 			continue
 		}
-		postDetail := &models.PostDetail{
-			AuthorName:      user.Username,
-			VoteNum:         voteData[idx],
-			Post:            post,
-			CommunityDetail: community,
-		}
-		data = append(data, postDetail)
+		pids = append(pids, pid)
 	}
-	return
+	posts, err := mysql.GetPostsByIDs(ctx, pids)
+	if err != nil {
+		return nil, err
+	}
+	return getPostDetailList(ctx, posts)
 }
 
-func GetCommunityPostList(p *models.ParamPostList) (data []*models.PostDetail, err error) {
-	ids, err := redis.GetCommunityPostIDsInOrder(p)
-	if err != nil {
+// getPostDetailList 为帖子补充作者、社区和投票数据，顺序与posts保持一致
+// 作者和社区通过请求的加载器批量取出，避免每个帖子查询一次数据库
+func getPostDetailList(ctx context.Context, posts []*models.Post) (data []*models.PostDetail, err error) {
+	data = make([]*models.PostDetail, 0, len(posts))
+	if len(posts) == 0 {
 		return
 	}
-	if len(ids) == 0 {
-		zap.L().Warn("redis.GetPostIDsInRoder(p) return empty dataset")
-		return
+	voteData, err := redis.GetPostVoteData(postIDs(posts))
+	if err != nil {
+		return nil, err
+	}
+	fillPostTags(posts...)
+	pids := make([]int64, 0, len(posts))
+	authorIDs := make([]int64, 0, len(posts))
+	communityIDs := make([]int64, 0, len(posts))
+	for _, post := range posts {
+		pids = append(pids, post.PostID)
+		authorIDs = append(authorIDs, post.AuthorId)
+		communityIDs = append(communityIDs, post.CommunityID)
+	}
+	// 作者和社区先登记到请求的加载器中，一起查询，同一个请求中已经查询过的不再查询
+	l := requestLoader(ctx)
+	l.PrimeUsers(authorIDs...)
+	l.PrimeCommunities(communityIDs...)
+	if err = l.Flush(ctx); err != nil {
+		return nil, err
 	}
-	posts, err := mysql.GetPostListByIDs(ids)
+	users, err := l.Users(ctx, authorIDs)
 	if err != nil {
-		return
+		return nil, err
 	}
-	voteData, err := redis.GetPostVoteData(ids)
+	communities, err := l.Communities(ctx, communityIDs)
 	if err != nil {
-		return
+		return nil, err
+	}
+	// 列表只返回摘要和预览，客户端不需要下载完整的内容
+	renders, err := mongodb.GetPostRenderSummaries(ctx, pids)
+	if err != nil {
+		zap.L().Warn("mongodb.GetPostRenderSummaries failed", zap.Error(err))
+		renders = map[int64]*models.PostRender{}
 	}
 	for idx, post := range posts {
-		user, err := mysql.GetUserByID(post.AuthorId)
-		if err != nil {
-			zap.L().Error("mysql.GetUserByID() failed", zap.String("author_id", fmt.Sprint(post.AuthorId)), zap.Error(err))
+		user, ok := users[post.AuthorId]
+		if !ok {
+			zap.L().Error("author of post not found", zap.Int64("post_id", post.PostID), zap.Int64("author_id", post.AuthorId))
 			continue
 		}
-		//post.AuthorName = user.UserName
-		community, err := mysql.GetCommunityDetailByID(post.CommunityID)
-		if err != nil {
-			zap.L().Error("mysql.GetCommunityByID() failed", zap.String("community_id", fmt.Sprint(post.CommunityID)), zap.Error(err))
+		community, ok := communities[post.CommunityID]
+		if !ok {
+			zap.L().Error("community of post not found", zap.Int64("post_id", post.PostID), zap.Int64("community_id", post.CommunityID))
 			continue
 		}
+		render, ok := renders[post.PostID]
+		if !ok {
+			render = newPostRender(post.PostID, post.Content, false)
+		}
+		post.Content = ""
 		postDetail := &models.PostDetail{
-			AuthorName:      user.Username,
+			AuthorName:      displayName(user),
 			VoteNum:         voteData[idx],
+			Excerpt:         render.Excerpt,
+			Preview:         render.Preview,
 			Post:            post,
 			CommunityDetail: community,
 		}
+		anonymizePost(postDetail)
 		data = append(data, postDetail)
 	}
 	return
 }
 
-func GetPostListNew(p *models.ParamPostList) (data []*models.PostDetail, err error) {
-	// 根据请求参数的不同，执行不同的逻辑。
-	if p.CommunityID == 0 {
-		// query all communities
-		data, err = GetPostList2(p)
-	} else {
-		// query with a single communityID
-		data, err = GetCommunityPostList(p)
+var ErrorVersionConflict = errors.New("Post was modified by someone else. ")
+
+// VersionConflictError 编辑时客户端的版本不是最新的，Current为服务端当前的版本
+type VersionConflictError struct {
+	Current int64
+}
+
+func (e *VersionConflictError) Error() string {
+	return ErrorVersionConflict.Error()
+}
+
+func (e *VersionConflictError) Is(target error) bool {
+	return target == ErrorVersionConflict
+}
+
+// UpdatePost 编辑帖子，编辑前的版本写入历史记录，返回编辑后的版本号
+// p.Version与当前版本不一致时返回VersionConflictError，避免覆盖其他人的修改
+func UpdatePost(ctx context.Context, userID, pid int64, p *models.ParamUpdatePost) (version int64, err error) {
+	post, err := mysql.GetPostById(pid)
+	if err != nil {
+		return 0, err
+	}
+	if post.AuthorId != userID {
+		return 0, ErrorNoPermission
+	}
+	if err = checkMentionAge(userID, p.Content); err != nil {
+		return 0, err
+	}
+	spam := checkSpamLinks(userID, p.Title, p.Content)
+	if spam != nil && !holdSpamLinks(post.Status) {
+		return 0, ErrorSpamLink
+	}
+	// 先检查一次，版本不一致时不写入历史记录
+	if p.Version != 0 && p.Version != post.Version {
+		return 0, &VersionConflictError{Current: post.Version}
 	}
+	// 社区在写入之前检查，不合法时内容和社区都不修改
+	var communityIDs []int64
+	if len(p.CommunityIDs) > 0 {
+		if communityIDs, err = preparePostCommunities(ctx, userID, post, p.CommunityIDs); err != nil {
+			return 0, err
+		}
+	}
+	// 先保存历史版本，保证原内容不会因为更新而丢失
+	revision := &models.PostRevision{
+		PostID:   post.PostID,
+		Title:    post.Title,
+		Content:  post.Content,
+		EditorID: userID,
+		EditTime: time.Now(),
+	}
+	if err = mongodb.AddPostRevision(revision, settings.Current().PostConfig.MaxRevisions); err != nil {
+		zap.L().Error("mongodb.AddPostRevision failed", zap.Int64("pid", pid), zap.Error(err))
+		return 0, err
+	}
+	// 检查之后被其他请求修改时，由数据库中的条件更新发现冲突；内容和社区在同一个事务中修改
+	updated, err := mysql.UpdatePostWithCommunities(pid, p.Version, p.Title, p.Content, communityIDs)
+	if err != nil {
+		return 0, err
+	}
+	if !updated {
+		current, err := mysql.GetPostById(pid)
+		if err != nil {
+			return 0, err
+		}
+		return 0, &VersionConflictError{Current: current.Version}
+	}
+	if len(communityIDs) > 0 {
+		if err = syncPostCommunityLists(post, communityIDs); err != nil {
+			return 0, err
+		}
+	}
+	updatePostSlug(pid, post.Title, p.Title)
+	if spam != nil {
+		if err = holdPost(post, spam); err != nil {
+			return 0, err
+		}
+	}
+	r := renderPost(pid, p.Content)
+	// 草稿和定时帖子在发布时再通知被@的用户
+	if !isUnpublished(post) && spam == nil {
+		notifyPostMentions(post, r.Mentions)
+	}
+	invalidatePostDetail(pid)
+	// 相关帖子的列表中包含标题和摘要
+	BustResponseCache(CacheRelatedPosts)
+	return post.Version + 1, nil
+}
+
+func GetPostHistory(ctx context.Context, userID, pid int64) ([]*models.PostRevision, error) {
+	post, err := mysql.GetPostById(pid)
 	if err != nil {
-		zap.L().Error("GetPostListNew failed", zap.Error(err))
 		return nil, err
 	}
-	return
+	if isUnpublished(post) && post.AuthorId != userID {
+		return nil, sql.ErrNoRows
+	}
+	if err = CheckPostAccess(ctx, userID, post); err != nil {
+		return nil, err
+	}
+	return mongodb.GetPostRevisions(pid)
+}
+
+// DeletePost 作者或管理员可以删除帖子，删除为软删除
+func DeletePost(userID, pid int64) error {
+	post, err := mysql.GetPostById(pid)
+	if err != nil {
+		return err
+	}
+	if post.AuthorId != userID {
+		admin, err := IsAdmin(userID)
+		if err != nil {
+			return err
+		}
+		if !admin {
+			return ErrorNoPermission
+		}
+	}
+	if err = mysql.DeletePost(pid); err != nil {
+		return err
+	}
+	removePostFromTags(pid)
+	removePostFromCommunities(post)
+	removeUserPost(post)
+	invalidatePostDetail(pid)
+	return nil
+}
+
+// RestorePost 恢复帖子，并按原发布时间重新加入首页、标签和所属的社区
+func RestorePost(pid int64) error {
+	if err := mysql.RestorePost(pid); err != nil {
+		return err
+	}
+	post, err := mysql.GetPostById(pid)
+	if err != nil {
+		return err
+	}
+	if isUnpublished(post) {
+		return nil
+	}
+	if err = restorePostFeeds(post); err != nil {
+		return err
+	}
+	if err = fillPostCommunities(post); err != nil {
+		return err
+	}
+	if err = redis.AddPostToCommunities(pid, post.CommunityIDs); err != nil {
+		return err
+	}
+	tags, err := mysql.GetPostTags(pid)
+	if err != nil {
+		return err
+	}
+	return redis.AddPostToTags(pid, tags, post.CreateTime)
+}
+
+// postIDs 返回帖子id列表，顺序与posts一致
+func postIDs(posts []*models.Post) []string {
+	ids := make([]string, 0, len(posts))
+	for _, post := range posts {
+		ids = append(ids, strconv.FormatInt(post.PostID, 10))
+	}
+	return ids
 }
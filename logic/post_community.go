@@ -0,0 +1,239 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrorNoCommunity        = errors.New("Community is required. ")
+	ErrorTooManyCommunities = errors.New("Too many communities. ")
+	ErrorPostTooShort       = errors.New("Post content is too short. ")
+)
+
+// PostTooShortError 帖子内容少于社区要求的字符数，Min为要求的最少字符数
+type PostTooShortError struct {
+	Min int
+}
+
+func (e *PostTooShortError) Error() string {
+	return ErrorPostTooShort.Error()
+}
+
+func (e *PostTooShortError) Is(target error) bool {
+	return target == ErrorPostTooShort
+}
+
+// normalizePostCommunities 合并community_id和community_ids并去重，主社区放在第一个，
+// 没有指定主社区时使用community_ids中的第一个
+func normalizePostCommunities(primary int64, ids []int64) ([]int64, error) {
+	normalized := make([]int64, 0, len(ids)+1)
+	seen := make(map[int64]struct{}, len(ids)+1)
+	for _, id := range append([]int64{primary}, ids...) {
+		if id <= 0 {
+			continue
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		normalized = append(normalized, id)
+	}
+	if len(normalized) == 0 {
+		return nil, ErrorNoCommunity
+	}
+	if max := settings.Current().PostConfig.MaxCommunities; max > 0 && len(normalized) > max {
+		return nil, ErrorTooManyCommunities
+	}
+	return normalized, nil
+}
+
+// defaultPostCommunities 发帖时没有选择社区时使用post.default_community，
+// 没有配置或开启了post.require_community时返回ErrorNoCommunity
+func defaultPostCommunities() ([]int64, error) {
+	cfg := settings.Current().PostConfig
+	if cfg.RequireCommunity || cfg.DefaultCommunity <= 0 {
+		return nil, ErrorNoCommunity
+	}
+	return []int64{cfg.DefaultCommunity}, nil
+}
+
+// checkDefaultCommunity 启动时检查配置的默认社区是否存在，返回配置的问题，查询失败时只记录警告
+func checkDefaultCommunity(ctx context.Context) []string {
+	cfg := settings.Current().PostConfig
+	if cfg.RequireCommunity || cfg.DefaultCommunity <= 0 {
+		return nil
+	}
+	communities, err := GetCommunitiesByIDs(ctx, []int64{cfg.DefaultCommunity})
+	if err != nil {
+		zap.L().Warn("check default community failed", zap.Int64("community_id", cfg.DefaultCommunity), zap.Error(err))
+		return nil
+	}
+	if _, ok := communities[cfg.DefaultCommunity]; !ok {
+		return []string{fmt.Sprintf("post.default_community: community %d does not exist", cfg.DefaultCommunity)}
+	}
+	return nil
+}
+
+// checkPostCommunities 社区都必须存在，不存在时返回mysql.ErrorInvalidID
+// 同时发布到多个社区或发布到私有社区时作者必须是每个社区的成员，否则返回mysql.ErrorNotMember
+// 私有社区的帖子不能同时发布到其他社区，否则返回ErrorPrivateCrossPost
+func checkPostCommunities(ctx context.Context, userID int64, ids []int64) error {
+	communities, err := GetCommunitiesByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	if len(communities) != len(ids) {
+		return mysql.ErrorInvalidID
+	}
+	if len(ids) == 1 && !communities[ids[0]].IsPrivate() {
+		return nil
+	}
+	for _, id := range ids {
+		if len(ids) > 1 && communities[id].IsPrivate() {
+			return ErrorPrivateCrossPost
+		}
+	}
+	joined, err := mysql.GetJoinedCommunityIDs(userID)
+	if err != nil {
+		return err
+	}
+	set := make(map[int64]struct{}, len(joined))
+	for _, id := range joined {
+		set[id] = struct{}{}
+	}
+	for _, id := range ids {
+		if _, ok := set[id]; !ok {
+			return mysql.ErrorNotMember
+		}
+	}
+	return nil
+}
+
+// checkPostPermission 作者在每个社区中的角色都要满足社区的发帖权限，否则返回ErrorPostNotAllowed
+func checkPostPermission(ctx context.Context, userID int64, ids []int64) error {
+	communities, err := GetCommunitiesByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		required := communities[id].RequiredPostRole()
+		if required == models.CommunityRoleNone {
+			continue
+		}
+		role, err := GetCommunityRole(userID, id)
+		if err != nil {
+			return err
+		}
+		if role < required {
+			return ErrorPostNotAllowed
+		}
+	}
+	return nil
+}
+
+// requiredPostLength 社区中帖子内容的最少字符数，社区没有设置时使用post.min_content_length
+func requiredPostLength(community *models.CommunityDetail) int {
+	if community.MinPostLength > 0 {
+		return community.MinPostLength
+	}
+	return settings.Current().PostConfig.MinContentLength
+}
+
+// checkPostLength 同时发布到多个社区时按要求最高的社区检查，内容太短时返回*PostTooShortError
+func checkPostLength(ctx context.Context, content string, ids []int64) error {
+	communities, err := GetCommunitiesByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	min := 0
+	for _, community := range communities {
+		if n := requiredPostLength(community); n > min {
+			min = n
+		}
+	}
+	if utf8.RuneCountInString(strings.TrimSpace(content)) < min {
+		return &PostTooShortError{Min: min}
+	}
+	return nil
+}
+
+// fillPostCommunities 补充帖子所属的所有社区，迁移之前的帖子只有主社区
+func fillPostCommunities(post *models.Post) error {
+	ids, err := mysql.GetPostCommunities(post.PostID)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		ids = []int64{post.CommunityID}
+	}
+	post.CommunityIDs = ids
+	return nil
+}
+
+// preparePostCommunities 编辑帖子时整理并检查新的社区，同时补充帖子当前所属的社区，在写入之前调用
+func preparePostCommunities(ctx context.Context, userID int64, post *models.Post, ids []int64) ([]int64, error) {
+	ids, err := normalizePostCommunities(0, ids)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkPostCommunities(ctx, userID, ids); err != nil {
+		return nil, err
+	}
+	if err = checkPostPermission(ctx, userID, ids); err != nil {
+		return nil, err
+	}
+	if err = checkAnonymousAllowed(ctx, post.Anonymous, ids); err != nil {
+		return nil, err
+	}
+	if err = fillPostCommunities(post); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// syncPostCommunityLists 数据库中替换了帖子的社区之后，已发布的帖子同步更新每个社区的列表
+func syncPostCommunityLists(post *models.Post, ids []int64) error {
+	if isUnpublished(post) {
+		return nil
+	}
+	if err := redis.RemovePostFromCommunities(post.PostID, diffIDs(post.CommunityIDs, ids)); err != nil {
+		return err
+	}
+	return redis.AddPostToCommunities(post.PostID, diffIDs(ids, post.CommunityIDs))
+}
+
+// removePostFromCommunities 帖子被删除后从所有社区的列表中移除
+func removePostFromCommunities(post *models.Post) {
+	err := fillPostCommunities(post)
+	if err == nil {
+		err = redis.RemovePostFromCommunities(post.PostID, post.CommunityIDs)
+	}
+	if err != nil {
+		zap.L().Error("remove post from communities failed", zap.Int64("pid", post.PostID), zap.Error(err))
+	}
+}
+
+// diffIDs 在a中但不在b中的id
+func diffIDs(a, b []int64) []int64 {
+	set := make(map[int64]struct{}, len(b))
+	for _, id := range b {
+		set[id] = struct{}{}
+	}
+	var diff []int64
+	for _, id := range a {
+		if _, ok := set[id]; !ok {
+			diff = append(diff, id)
+		}
+	}
+	return diff
+}
@@ -0,0 +1,81 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/dao/mongodb"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/pkg/dbtimeout"
+	"strconv"
+)
+
+// postExportBatch 每读取多少行帖子查询一次摘要和票数，并把结果写给客户端
+const postExportBatch = 100
+
+// ExportPosts 按条件逐行导出帖子，摘要和票数按批从mongodb和redis中补充
+// 每处理完一批调用一次flush，emit或flush返回错误时停止
+// 导出的时间随数据量增长，不使用mysql的默认超时，客户端断开时停止
+func ExportPosts(ctx context.Context, p *models.ParamPostExport, emit func(row *models.PostExport) error, flush func() error) error {
+	ctx = dbtimeout.WithTimeout(ctx, 0)
+	batch := make([]*models.Post, 0, postExportBatch)
+	send := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := emitPostBatch(ctx, batch, p.MinScore, emit); err != nil {
+			return err
+		}
+		batch = batch[:0]
+		return flush()
+	}
+	err := mysql.StreamPosts(ctx, p, func(post *models.Post) error {
+		batch = append(batch, post)
+		if len(batch) < postExportBatch {
+			return nil
+		}
+		return send()
+	})
+	if err != nil {
+		return err
+	}
+	return send()
+}
+
+func emitPostBatch(ctx context.Context, posts []*models.Post, minScore *int64, emit func(row *models.PostExport) error) error {
+	pids := make([]int64, 0, len(posts))
+	ids := make([]string, 0, len(posts))
+	for _, post := range posts {
+		pids = append(pids, post.PostID)
+		ids = append(ids, strconv.FormatInt(post.PostID, 10))
+	}
+	scores, err := redis.GetPostNetVotes(ids)
+	if err != nil {
+		return err
+	}
+	renders, err := mongodb.GetPostRenderSummaries(ctx, pids)
+	if err != nil {
+		return err
+	}
+	for i, post := range posts {
+		if minScore != nil && scores[i] < *minScore {
+			continue
+		}
+		row := &models.PostExport{
+			PostID:      post.PostID,
+			AuthorID:    post.AuthorId,
+			CommunityID: post.CommunityID,
+			Title:       post.Title,
+			Content:     post.Content,
+			Score:       scores[i],
+			CreateTime:  post.CreateTime,
+		}
+		if r, ok := renders[post.PostID]; ok {
+			row.Excerpt = r.Excerpt
+		}
+		if err = emit(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/settings"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	scoreSyncJobName = "post:score:sync"
+	// scoreSyncLockTTL 写回过程中会自动续期，实例退出时锁在这段时间之后失效
+	scoreSyncLockTTL = time.Minute
+)
+
+// StartScoreSync 启动时redis中没有帖子分数则从数据库重建，之后定期把变化的分数写回数据库
+// 返回的函数会在退出前再写回一次
+func StartScoreSync() (stop func(ctx context.Context) error, err error) {
+	if err = rebuildPostScores(); err != nil {
+		return nil, err
+	}
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		interval := time.Duration(settings.Current().PostConfig.ScoreSyncInterval) * time.Second
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				syncPostScores()
+			case <-quit:
+				syncPostScores()
+				return
+			}
+		}
+	}()
+	return func(ctx context.Context) error {
+		close(quit)
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}, nil
+}
+
+func rebuildPostScores() error {
+	loaded, err := redis.PostScoresLoaded()
+	if err != nil || loaded {
+		return err
+	}
+	list, err := mysql.GetPostScores()
+	if err != nil {
+		return err
+	}
+	zap.L().Info("rebuild post scores from mysql", zap.Int("posts", len(list)))
+	return redis.LoadPostScores(list, settings.Current().PostConfig.HotGravity)
+}
+
+// syncPostScores 写回所有待写回的帖子，只有一个实例会执行，失败的帖子重新标记后等下一次
+func syncPostScores() {
+	lock, ok, err := redis.AcquireLock(context.Background(), scoreSyncJobName, scoreSyncLockTTL)
+	if err != nil {
+		zap.L().Error("redis.AcquireLock failed", zap.String("key", scoreSyncJobName), zap.Error(err))
+		return
+	}
+	if !ok {
+		return
+	}
+	ctx, cancel := lock.AutoRenewContext(context.Background())
+	defer cancel()
+	defer func() {
+		if err := redis.ReleaseLock(context.Background(), lock); err != nil {
+			zap.L().Warn("redis.ReleaseLock failed", zap.String("key", scoreSyncJobName), zap.Error(err))
+		}
+	}()
+	batch := settings.Current().PostConfig.ScoreSyncBatch
+	if batch <= 0 {
+		batch = 500
+	}
+	for ctx.Err() == nil {
+		scores, err := redis.PopDirtyPostScores(batch)
+		if err != nil {
+			zap.L().Error("redis.PopDirtyPostScores failed", zap.Error(err))
+			return
+		}
+		if len(scores) == 0 {
+			return
+		}
+		if err = mysql.SavePostScores(scores); err != nil {
+			zap.L().Error("mysql.SavePostScores failed", zap.Int("posts", len(scores)), zap.Error(err))
+			pids := make([]string, 0, len(scores))
+			for pid := range scores {
+				pids = append(pids, strconv.FormatInt(pid, 10))
+			}
+			if err := redis.MarkPostScoresDirty(pids...); err != nil {
+				zap.L().Error("redis.MarkPostScoresDirty failed", zap.Error(err))
+			}
+			return
+		}
+		if int64(len(scores)) < batch {
+			return
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package logic
+
+import (
+	"go-web-app/dao/mysql"
+	"go-web-app/pkg/slug"
+	"go-web-app/settings"
+
+	"go.uber.org/zap"
+)
+
+const slugBackfillBatch = 500
+
+func postSlug(title string) string {
+	return slug.Make(title, settings.Current().PostConfig.SlugMaxLength)
+}
+
+// ResolvePostSlug 根据slug查找帖子，slug不是帖子当前的slug时canonical为当前的slug，调用方重定向
+func ResolvePostSlug(s string) (pid int64, canonical string, err error) {
+	return mysql.GetPostBySlug(s)
+}
+
+// updatePostSlug 开启了post.slug_follow_title时编辑标题后生成新的slug，失败时只记录日志，保留原来的slug
+func updatePostSlug(pid int64, oldTitle, newTitle string) {
+	if !settings.Current().PostConfig.SlugFollowTitle || oldTitle == newTitle {
+		return
+	}
+	if _, err := mysql.UpdatePostSlug(pid, postSlug(newTitle)); err != nil {
+		zap.L().Error("mysql.UpdatePostSlug failed", zap.Int64("pid", pid), zap.Error(err))
+	}
+}
+
+// BackfillPostSlugs 启动时为添加slug之前发布的帖子生成slug，失败时只记录日志
+func BackfillPostSlugs() {
+	total := 0
+	for {
+		posts, err := mysql.GetPostsWithoutSlug(slugBackfillBatch)
+		if err != nil {
+			zap.L().Error("mysql.GetPostsWithoutSlug failed", zap.Error(err))
+			return
+		}
+		for _, p := range posts {
+			if _, err = mysql.UpdatePostSlug(p.PostID, postSlug(p.Title)); err != nil {
+				zap.L().Error("mysql.UpdatePostSlug failed", zap.Int64("pid", p.PostID), zap.Error(err))
+				return
+			}
+		}
+		total += len(posts)
+		if len(posts) < slugBackfillBatch {
+			break
+		}
+	}
+	if total > 0 {
+		zap.L().Info("post slugs backfilled", zap.Int("count", total))
+	}
+}
@@ -0,0 +1,50 @@
+package logic
+
+import (
+	"go-web-app/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClonePostDetail(t *testing.T) {
+	at := time.Now()
+	orig := &models.PostDetail{
+		AuthorName: "alice",
+		Preview:    &models.PostPreview{URL: "https://rutgers.edu"},
+		Mentions:   []*models.Mention{{UserID: 2, Username: "bob"}},
+		Post: &models.Post{
+			PostID:       1,
+			Title:        "title",
+			CommunityIDs: []int64{1, 2},
+			Tags:         []string{"go"},
+			PublishAt:    &at,
+			Poll:         &models.ParamPoll{Options: []string{"a", "b"}},
+		},
+		CommunityDetail: &models.CommunityDetail{ID: 1, Name: "go"},
+	}
+	cp := clonePostDetail(orig)
+	assert.Equal(t, orig, cp)
+
+	// 修改复制出来的对象不影响原来的对象
+	cp.Post.Title = "changed"
+	cp.Post.CommunityIDs[0] = 9
+	cp.Post.Tags[0] = "rust"
+	*cp.Post.PublishAt = at.Add(time.Hour)
+	cp.Post.Poll.Options[0] = "c"
+	cp.CommunityDetail.Name = "rust"
+	cp.Preview.URL = ""
+	cp.Mentions[0].Username = "carol"
+	assert.Equal(t, "title", orig.Post.Title)
+	assert.Equal(t, []int64{1, 2}, orig.Post.CommunityIDs)
+	assert.Equal(t, []string{"go"}, orig.Post.Tags)
+	assert.True(t, orig.Post.PublishAt.Equal(at))
+	assert.Equal(t, []string{"a", "b"}, orig.Post.Poll.Options)
+	assert.Equal(t, "go", orig.CommunityDetail.Name)
+	assert.Equal(t, "https://rutgers.edu", orig.Preview.URL)
+	assert.Equal(t, "bob", orig.Mentions[0].Username)
+
+	// 没有帖子和社区时不会出错
+	assert.Equal(t, &models.PostDetail{AuthorName: "alice"}, clonePostDetail(&models.PostDetail{AuthorName: "alice"}))
+}
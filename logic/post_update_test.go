@@ -0,0 +1,48 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/dao/mysql"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdatePostInvalidCommunities(t *testing.T) {
+	useMySQL(t)
+	useMiniredis(t)
+	oldPost, oldMention := settings.Conf.PostConfig, settings.Conf.MentionConfig
+	settings.Conf.PostConfig = &settings.PostConfig{MaxCommunities: 3}
+	settings.Conf.MentionConfig = &settings.MentionConfig{MaxPerItem: 10}
+	t.Cleanup(func() { settings.Conf.PostConfig, settings.Conf.MentionConfig = oldPost, oldMention })
+
+	author := createTestUser(t)
+	community := createTestCommunity(t, models.CommunityVisibilityPublic)
+	post := createTestPost(t, author.UserID, community)
+
+	// 社区不存在时内容也不修改
+	_, err := UpdatePost(context.Background(), author.UserID, post.PostID, &models.ParamUpdatePost{
+		Title:        "changed",
+		Content:      "changed content",
+		CommunityIDs: []int64{community, 1 << 60},
+	})
+	assert.ErrorIs(t, err, mysql.ErrorInvalidID)
+	// 去重之后超过上限
+	_, err = UpdatePost(context.Background(), author.UserID, post.PostID, &models.ParamUpdatePost{
+		Title:        "changed",
+		Content:      "changed content",
+		CommunityIDs: []int64{community, 1, 2, 3, community},
+	})
+	assert.ErrorIs(t, err, ErrorTooManyCommunities)
+
+	current, err := mysql.GetPostById(post.PostID)
+	require.NoError(t, err)
+	assert.Equal(t, post.Title, current.Title)
+	assert.Equal(t, post.Content, current.Content)
+	ids, err := mysql.GetPostCommunities(post.PostID)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{community}, ids)
+}
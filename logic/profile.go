@@ -0,0 +1,61 @@
+package logic
+
+import (
+	"go-web-app/dao/mongodb"
+	"go-web-app/dao/mysql"
+	"go-web-app/models"
+)
+
+// GetUserProfile 用户的公开主页和统计数据
+// viewerID为当前登录的用户，查看别人的主页时返回与对方的关系
+func GetUserProfile(viewerID, uid int64) (*models.UserProfile, error) {
+	profile, err := mysql.GetUserProfile(uid)
+	if err != nil {
+		return nil, err
+	}
+	if profile.Deactivated {
+		return nil, mysql.ErrorUserNotExist
+	}
+	if profile.Stats, err = getUserStats(uid); err != nil {
+		return nil, err
+	}
+	if viewerID != 0 && viewerID != uid {
+		rel := new(models.UserRelationship)
+		if rel.Following, err = mysql.IsFollowing(viewerID, uid); err != nil {
+			return nil, err
+		}
+		if rel.Blocked, err = IsBlocked(viewerID, uid); err != nil {
+			return nil, err
+		}
+		profile.Relationship = rel
+	}
+	return profile, nil
+}
+
+// getUserStats 关注数和声望使用redis中维护的计数，帖子和评论数从数据库统计
+// 用户帖子的zset不会移除已删除的帖子，所以帖子数不能直接用它计算
+func getUserStats(uid int64) (*models.UserStats, error) {
+	follow, err := GetFollowCount(uid)
+	if err != nil {
+		return nil, err
+	}
+	posts, err := mysql.GetUserPostCount(uid)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := mongodb.CountUserComments(uid)
+	if err != nil {
+		return nil, err
+	}
+	karma, err := GetKarma(uid)
+	if err != nil {
+		return nil, err
+	}
+	return &models.UserStats{
+		Posts:     posts,
+		Comments:  comments,
+		Karma:     karma,
+		Followers: follow.Followers,
+		Following: follow.Following,
+	}, nil
+}
@@ -0,0 +1,167 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"go-web-app/dao/mongodb"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var ErrorPurgeRunning = errors.New("Purge is already running. ")
+
+const (
+	purgeJobName = "purge"
+	// purgeBatch 每次彻底删除多少个帖子
+	purgeBatch = 200
+	// purgeLockTTL 清理过程中会自动续期，实例退出时锁在这段时间之后失效
+	purgeLockTTL = time.Minute
+)
+
+// StartPurgeJob 在后台定期清理过期的数据，返回的函数用于退出时停止
+func StartPurgeJob() (stop func(ctx context.Context) error) {
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		interval := time.Duration(settings.Current().PurgeConfig.Interval) * time.Hour
+		if interval <= 0 {
+			interval = 24 * time.Hour
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if !settings.Current().PurgeConfig.Enable {
+					continue
+				}
+				if _, err := RunPurge(); err != nil && !errors.Is(err, ErrorPurgeRunning) {
+					zap.L().Error("purge failed", zap.Error(err))
+				}
+			case <-quit:
+				return
+			}
+		}
+	}()
+	return func(ctx context.Context) error {
+		close(quit)
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// RunPurge 执行一次清理，其他实例正在清理时返回ErrorPurgeRunning
+// 某一类数据清理失败时返回错误，已经删除的条数仍然在结果中
+func RunPurge() (*models.PurgeResult, error) {
+	lock, ok, err := redis.AcquireLock(context.Background(), purgeJobName, purgeLockTTL)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrorPurgeRunning
+	}
+	ctx, cancel := lock.AutoRenewContext(context.Background())
+	defer cancel()
+	defer func() {
+		if err := redis.ReleaseLock(context.Background(), lock); err != nil {
+			zap.L().Warn("redis.ReleaseLock failed", zap.String("key", purgeJobName), zap.Error(err))
+		}
+	}()
+
+	cfg := settings.Current()
+	result := new(models.PurgeResult)
+	start := time.Now()
+	defer func() {
+		zap.L().Info("purge finished",
+			zap.Int64("posts", result.Posts),
+			zap.Int64("comments", result.Comments),
+			zap.Int64("revisions", result.Revisions),
+			zap.Int64("notifications", result.Notifications),
+			zap.Int64("attachments", result.Attachments),
+			zap.Duration("elapsed", time.Since(start)))
+	}()
+	if days := cfg.PurgeConfig.DeletedPostRetention; days > 0 {
+		if err = purgeDeletedPosts(ctx, start.AddDate(0, 0, -days), result); err != nil {
+			return result, err
+		}
+	}
+	if err = purgeOrphanAttachments(result); err != nil {
+		return result, err
+	}
+	if err = ctx.Err(); err != nil {
+		return result, err
+	}
+	if result.Revisions, err = mongodb.TrimPostRevisions(cfg.PostConfig.MaxRevisions); err != nil {
+		return result, err
+	}
+	if err = ctx.Err(); err != nil {
+		return result, err
+	}
+	if days := cfg.PurgeConfig.ReadNotificationRetention; days > 0 {
+		if result.Notifications, err = mongodb.PurgeReadNotifications(start.AddDate(0, 0, -days)); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// purgeDeletedPosts 分批彻底删除before之前软删除的帖子，先删除mysql中的记录，再清理mongodb和redis中的数据
+func purgeDeletedPosts(ctx context.Context, before time.Time, result *models.PurgeResult) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		posts, err := mysql.GetPurgeablePosts(before, purgeBatch)
+		if err != nil {
+			return err
+		}
+		if len(posts) == 0 {
+			return nil
+		}
+		ids := make([]int64, 0, len(posts))
+		for _, post := range posts {
+			ids = append(ids, post.PostID)
+		}
+		deleted, err := mysql.PurgePosts(ids)
+		if err != nil {
+			return err
+		}
+		result.Posts += int64(len(deleted))
+		comments, err := mongodb.PurgePostData(deleted)
+		result.Comments += comments
+		if err != nil {
+			return err
+		}
+		if err = redis.PurgePosts(postsIn(posts, deleted)); err != nil {
+			return err
+		}
+		if len(posts) < purgeBatch {
+			return nil
+		}
+	}
+}
+
+// postsIn posts中id在ids中的帖子
+func postsIn(posts []*models.Post, ids []int64) []*models.Post {
+	set := make(map[int64]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	var in []*models.Post
+	for _, post := range posts {
+		if _, ok := set[post.PostID]; ok {
+			in = append(in, post)
+		}
+	}
+	return in
+}
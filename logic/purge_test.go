@@ -0,0 +1,54 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPurgeDeletedPosts(t *testing.T) {
+	useMySQL(t)
+	useMongo(t)
+	useMiniredis(t)
+	author := createTestUser(t)
+	community := createTestCommunity(t, models.CommunityVisibilityPublic)
+	deleted := createTestPost(t, author.UserID, community)
+	kept := createTestPost(t, author.UserID, community)
+	require.NoError(t, mysql.DeletePost(deleted.PostID))
+
+	result := new(models.PurgeResult)
+	require.NoError(t, purgeDeletedPosts(context.Background(), time.Now().Add(time.Minute), result))
+	assert.True(t, result.Posts >= 1)
+	// 彻底删除之后不能再恢复，没有删除的帖子不受影响
+	assert.ErrorIs(t, mysql.RestorePost(deleted.PostID), mysql.ErrorInvalidID)
+	_, err := mysql.GetPostById(kept.PostID)
+	assert.NoError(t, err)
+}
+
+func TestRunPurgeLocked(t *testing.T) {
+	mr := useMiniredis(t)
+	// 其他实例正在清理
+	lock, ok, err := redis.AcquireLock(context.Background(), purgeJobName, purgeLockTTL)
+	require.NoError(t, err)
+	require.True(t, ok)
+	_, err = RunPurge()
+	assert.ErrorIs(t, err, ErrorPurgeRunning)
+	require.NoError(t, redis.ReleaseLock(context.Background(), lock))
+
+	mr.Close()
+	_, err = RunPurge()
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrorPurgeRunning)
+}
+
+func TestPostsIn(t *testing.T) {
+	posts := []*models.Post{{PostID: 1}, {PostID: 2}, {PostID: 3}}
+	assert.Equal(t, []*models.Post{posts[0], posts[2]}, postsIn(posts, []int64{3, 1}))
+	assert.Empty(t, postsIn(posts, nil))
+}
@@ -0,0 +1,118 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/pkg/hub"
+	"go-web-app/settings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// deferredPushBatch 每次从redis中取出多少个推迟的通知
+const deferredPushBatch = 100
+
+// notificationHub 本实例上所有用户的websocket连接
+var notificationHub = hub.New()
+
+// ConnectNotifications 为用户登记一个websocket连接，超过上限时返回hub.ErrTooManyConnections
+func ConnectNotifications(userID int64) (*hub.Client, error) {
+	return notificationHub.Register(userID, settings.Current().WebSocketConfig.MaxConnsPerUser)
+}
+
+// publishNotification 通过redis广播新通知，由连接所在的实例推送给用户
+func publishNotification(n *models.Notification) {
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return
+	}
+	if err := redis.PublishNotification(payload); err != nil {
+		zap.L().Warn("redis.PublishNotification failed", zap.Int64("recipient", n.RecipientID), zap.Error(err))
+	}
+}
+
+// deferNotification 免打扰期间的通知在at之后由StartDeferredPush推送
+func deferNotification(n *models.Notification, at time.Time) {
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return
+	}
+	if err := redis.DeferNotification(payload, at); err != nil {
+		zap.L().Warn("redis.DeferNotification failed", zap.Int64("recipient", n.RecipientID), zap.Error(err))
+	}
+}
+
+// StartDeferredPush 定期推送免打扰结束后到时间的通知，返回的函数用于退出时停止
+func StartDeferredPush() (stop func(ctx context.Context) error) {
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		interval := time.Duration(settings.Current().QuietHoursConfig.FlushInterval) * time.Second
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				flushDeferredNotifications()
+			case <-quit:
+				return
+			}
+		}
+	}()
+	return func(ctx context.Context) error {
+		close(quit)
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// flushDeferredNotifications 推送所有到时间的通知，每个通知只会被一个实例取出
+func flushDeferredNotifications() {
+	for {
+		payloads, err := redis.PopDueNotifications(time.Now(), deferredPushBatch)
+		if err != nil {
+			zap.L().Error("redis.PopDueNotifications failed", zap.Error(err))
+			return
+		}
+		for _, payload := range payloads {
+			if err := redis.PublishNotification([]byte(payload)); err != nil {
+				zap.L().Warn("redis.PublishNotification failed", zap.Error(err))
+			}
+		}
+		if len(payloads) < deferredPushBatch {
+			return
+		}
+	}
+}
+
+// StartNotificationPush 订阅redis中的新通知并推送给本实例上的连接，返回停止订阅的函数
+func StartNotificationPush() (stop func()) {
+	ps := redis.SubscribeNotifications()
+	go func() {
+		for msg := range ps.Channel() {
+			n := new(models.Notification)
+			if err := json.Unmarshal([]byte(msg.Payload), n); err != nil {
+				zap.L().Warn("invalid notification payload", zap.Error(err))
+				continue
+			}
+			notificationHub.Send(n.RecipientID, []byte(msg.Payload))
+		}
+	}()
+	return func() { _ = ps.Close() }
+}
+
+// DrainNotifications 关闭服务前通知所有websocket客户端断开，返回超时后仍未断开的连接数
+func DrainNotifications(ctx context.Context) int {
+	return notificationHub.Drain(ctx)
+}
@@ -0,0 +1,48 @@
+package logic
+
+import (
+	"go-web-app/models"
+	"go-web-app/pkg/quiethours"
+	"go-web-app/settings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// quietHoursDigest 摘要邮件在quiet_hours.critical_types中的类型名
+const quietHoursDigest = "digest"
+
+// quietHoursEnd 用户在now处于免打扰时段时返回这一段结束的时间，否则返回零值
+// typ在quiet_hours.critical_types中时照常发送，读取设置失败时也照常发送
+func quietHoursEnd(userID int64, typ string, now time.Time) time.Time {
+	for _, t := range settings.Current().QuietHoursConfig.CriticalTypes {
+		if t == typ {
+			return time.Time{}
+		}
+	}
+	pref, err := GetNotificationPref(userID)
+	if err != nil {
+		zap.L().Warn("GetNotificationPref failed", zap.Int64("uid", userID), zap.Error(err))
+		return time.Time{}
+	}
+	w := quietWindow(pref)
+	if w == nil || !w.Contains(now) {
+		return time.Time{}
+	}
+	return w.End(now)
+}
+
+// quietWindow 没有启用免打扰时返回nil
+func quietWindow(pref *models.NotificationPref) *quiethours.Window {
+	if pref.QuietStart == "" {
+		return nil
+	}
+	w, err := quiethours.New(pref.QuietStart, pref.QuietEnd, pref.QuietTimezone)
+	if err != nil {
+		// 保存前已经校验过，只有数据库中的数据被直接修改时才会出现
+		zap.L().Warn("invalid quiet hours", zap.String("start", pref.QuietStart),
+			zap.String("end", pref.QuietEnd), zap.String("timezone", pref.QuietTimezone), zap.Error(err))
+		return nil
+	}
+	return w
+}
@@ -0,0 +1,94 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// relatedCandidateLimit 每个标签和社区取最近的多少个帖子作为候选
+const relatedCandidateLimit = 50
+
+// GetRelatedPosts 与帖子有相同标签或在同一个社区的帖子，不包含帖子本身和同一个作者的帖子
+// 按相同标签数、是否同一个社区和发布时间依次排序，结果的id缓存一段时间
+func GetRelatedPosts(ctx context.Context, pid int64) ([]*models.PostDetail, error) {
+	cfg := settings.Current().PostConfig
+	ids, ok, err := redis.GetRelatedCache(pid)
+	if err != nil {
+		zap.L().Warn("redis.GetRelatedCache failed", zap.Int64("pid", pid), zap.Error(err))
+	}
+	if !ok {
+		if ids, err = rankRelatedPosts(ctx, pid, cfg.RelatedSize); err != nil {
+			return nil, err
+		}
+		if cfg.RelatedTTL > 0 {
+			if err := redis.SetRelatedCache(pid, ids, time.Duration(cfg.RelatedTTL)*time.Second); err != nil {
+				zap.L().Warn("redis.SetRelatedCache failed", zap.Int64("pid", pid), zap.Error(err))
+			}
+		}
+	}
+	// 缓存期间被删除或隐藏的帖子会被跳过
+	posts, err := mysql.GetPostsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	data, err := getPostDetailList(ctx, posts)
+	if err != nil {
+		return nil, err
+	}
+	return filterPublicPosts(data), nil
+}
+
+func rankRelatedPosts(ctx context.Context, pid int64, size int) ([]int64, error) {
+	post, err := GetPostById(ctx, pid)
+	if err != nil {
+		return nil, err
+	}
+	communityIDs := post.CommunityIDs
+	if len(communityIDs) == 0 {
+		communityIDs = []int64{post.CommunityID}
+	}
+	candidates, err := redis.GetRelatedCandidates(post.Tags, communityIDs, relatedCandidateLimit)
+	if err != nil {
+		return nil, err
+	}
+	delete(candidates, pid)
+	ids := make([]int64, 0, len(candidates))
+	for id := range candidates {
+		ids = append(ids, id)
+	}
+	posts, err := mysql.GetPostsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	related := posts[:0]
+	for _, p := range posts {
+		if p.AuthorId != post.AuthorId {
+			related = append(related, p)
+		}
+	}
+	sort.Slice(related, func(i, j int) bool {
+		a, b := candidates[related[i].PostID], candidates[related[j].PostID]
+		if a.SharedTags != b.SharedTags {
+			return a.SharedTags > b.SharedTags
+		}
+		if a.SameCommunity != b.SameCommunity {
+			return a.SameCommunity
+		}
+		return related[i].CreateTime.After(related[j].CreateTime)
+	})
+	if size > 0 && len(related) > size {
+		related = related[:size]
+	}
+	ids = ids[:0]
+	for _, p := range related {
+		ids = append(ids, p.PostID)
+	}
+	return ids, nil
+}
@@ -0,0 +1,97 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/dao/mongodb"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/pkg/markdown"
+	"go-web-app/pkg/opengraph"
+	"go-web-app/settings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// newPostRender 渲染帖子的markdown，withHTML为false时只提取摘要和预览
+func newPostRender(pid int64, content string, withHTML bool) *models.PostRender {
+	r := &models.PostRender{PostID: pid, UpdateTime: time.Now()}
+	if withHTML {
+		safeHTML, excerpt, err := markdown.Render(content)
+		if err != nil {
+			zap.L().Error("markdown.Render failed", zap.Int64("pid", pid), zap.Error(err))
+			excerpt = markdown.Excerpt(content)
+		}
+		r.HTML, r.Excerpt = safeHTML, excerpt
+	} else {
+		r.Excerpt = markdown.Excerpt(content)
+	}
+	if image, link := markdown.FirstMedia(content); image != "" {
+		r.Preview = &models.PostPreview{Type: models.PreviewTypeImage, URL: image}
+	} else if link != "" {
+		r.Preview = &models.PostPreview{Type: models.PreviewTypeLink, URL: link}
+	}
+	return r
+}
+
+// renderPost 渲染帖子并解析@提及后保存，失败时详情和列表会在读取时重新渲染
+func renderPost(pid int64, content string) *models.PostRender {
+	r := newPostRender(pid, content, true)
+	r.Mentions = resolveMentions(content)
+	if err := mongodb.SavePostRender(r); err != nil {
+		zap.L().Error("mongodb.SavePostRender failed", zap.Int64("pid", pid), zap.Error(err))
+		return r
+	}
+	if r.Preview != nil && r.Preview.Type == models.PreviewTypeLink && settings.Current().PreviewConfig.FetchOpenGraph {
+		go fillLinkPreview(pid, r.Preview.URL)
+	}
+	return r
+}
+
+// loadPostRender 读取保存的渲染结果，没有时现场渲染
+func loadPostRender(post *models.Post) *models.PostRender {
+	r, err := mongodb.GetPostRender(post.PostID)
+	if err != nil {
+		zap.L().Warn("mongodb.GetPostRender failed", zap.Int64("pid", post.PostID), zap.Error(err))
+	}
+	if r != nil {
+		return r
+	}
+	return newPostRender(post.PostID, post.Content, true)
+}
+
+// fillLinkPreview 抓取链接的OpenGraph信息补充到帖子的预览中
+// 结果按链接缓存在redis中，抓取失败也会缓存，避免反复请求同一个不可用的网站
+func fillLinkPreview(pid int64, url string) {
+	if !opengraph.IsValidURL(url) {
+		return
+	}
+	cfg := settings.Current().PreviewConfig
+	title, image, ok, err := redis.GetLinkMetaCache(url)
+	if err != nil {
+		zap.L().Warn("redis.GetLinkMetaCache failed", zap.String("url", url), zap.Error(err))
+	}
+	if !ok {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.FetchTimeout)*time.Millisecond)
+		meta, err := opengraph.Fetch(ctx, url)
+		cancel()
+		ttl := time.Duration(cfg.CacheTTL) * time.Hour
+		if err != nil {
+			zap.L().Info("opengraph.Fetch failed", zap.String("url", url), zap.Error(err))
+			meta, ttl = new(opengraph.Meta), time.Duration(cfg.FailureCacheTTL)*time.Minute
+		}
+		title, image = meta.Title, meta.Image
+		if err := redis.SetLinkMetaCache(url, title, image, ttl); err != nil {
+			zap.L().Warn("redis.SetLinkMetaCache failed", zap.String("url", url), zap.Error(err))
+		}
+	}
+	if title == "" && image == "" {
+		return
+	}
+	preview := &models.PostPreview{Type: models.PreviewTypeLink, URL: url, Title: title, Image: image}
+	if err := mongodb.SetLinkPreview(pid, preview); err != nil {
+		zap.L().Error("mongodb.SetLinkPreview failed", zap.Int64("pid", pid), zap.Error(err))
+		return
+	}
+	invalidatePostDetail(pid)
+}
@@ -0,0 +1,156 @@
+package logic
+
+import (
+	"errors"
+	"go-web-app/dao/mongodb"
+	"go-web-app/dao/mysql"
+	"go-web-app/models"
+	"go-web-app/pkg/snowflake"
+	"go-web-app/settings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var ErrorReportExists = errors.New("Post already reported. ")
+
+// ReportPost 举报帖子，同一个用户只能举报一次
+// 待处理的举报达到report.hide_threshold时帖子被隐藏，等待版主处理
+func ReportPost(userID, pid int64, p *models.ParamReport) error {
+	post, err := mysql.GetPostById(pid)
+	if err != nil {
+		return err
+	}
+	report := &models.Report{
+		ReportID:    snowflake.GenID(),
+		PostID:      pid,
+		CommunityID: post.CommunityID,
+		ReporterID:  userID,
+		Reason:      p.Reason,
+		Detail:      p.Detail,
+		Status:      models.ReportStatusOpen,
+		CreateTime:  time.Now(),
+	}
+	created, err := mongodb.CreateReport(report)
+	if err != nil {
+		return err
+	}
+	if !created {
+		return ErrorReportExists
+	}
+	enqueueModQueue(models.ModQueueTypeReport, report)
+	threshold := settings.Current().ReportConfig.HideThreshold
+	if threshold <= 0 || post.Status == models.PostStatusHidden {
+		return nil
+	}
+	count, err := mongodb.CountOpenReports(pid)
+	if err != nil {
+		return err
+	}
+	if count >= threshold {
+		zap.L().Info("post hidden pending review", zap.Int64("pid", pid), zap.Int64("reports", count))
+		if err = mysql.SetPostStatus(pid, models.PostStatusHidden); err != nil {
+			return err
+		}
+		invalidatePostDetail(pid)
+	}
+	return nil
+}
+
+// moderatedCommunityIDs 用户管理的社区，网站管理员返回nil表示所有社区，不是版主时返回ErrorNoPermission
+func moderatedCommunityIDs(userID int64) ([]int64, error) {
+	admin, err := IsAdmin(userID)
+	if err != nil || admin {
+		return nil, err
+	}
+	communityIDs, err := mysql.GetModeratedCommunityIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(communityIDs) == 0 {
+		return nil, ErrorNoPermission
+	}
+	return communityIDs, nil
+}
+
+// GetReports 返回用户管理的社区中待处理的举报，网站管理员可以看到所有社区
+func GetReports(userID, page, size int64) ([]*models.ReportSummary, error) {
+	communityIDs, err := moderatedCommunityIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+	list, err := mongodb.GetOpenReportSummaries(communityIDs, page, size)
+	if err != nil {
+		return nil, err
+	}
+	for _, summary := range list {
+		post, err := mysql.GetPostById(summary.PostID)
+		if err != nil {
+			zap.L().Warn("mysql.GetPostById failed", zap.Int64("pid", summary.PostID), zap.Error(err))
+			continue
+		}
+		summary.Title = post.Title
+		summary.Hidden = post.Status == models.PostStatusHidden
+	}
+	return list, nil
+}
+
+// ResolveReport 版主处理帖子的举报，dismiss恢复帖子的显示，remove删除帖子
+// 审核队列中帖子的条目一起处理，条目已经被其他版主认领时返回ErrorModQueueConflict
+func ResolveReport(userID, pid int64, action, ip string) error {
+	post, err := mysql.GetPostById(pid)
+	if err != nil {
+		return err
+	}
+	if err = checkModerator(userID, post.CommunityID); err != nil {
+		return err
+	}
+	busy, err := mongodb.HasOtherModQueueAssignee(pid, 0, userID)
+	if err != nil {
+		return err
+	}
+	if busy {
+		return ErrorModQueueConflict
+	}
+	auditID, err := moderatePost(userID, post, action, ip)
+	if err != nil {
+		return err
+	}
+	if err = mongodb.ResolveTargetModQueueItems(pid, 0, userID, action, "", auditID); err != nil {
+		zap.L().Error("mongodb.ResolveTargetModQueueItems failed", zap.Int64("pid", pid), zap.Error(err))
+	}
+	return nil
+}
+
+func checkModerator(userID, communityID int64) error {
+	role, err := GetCommunityRole(userID, communityID)
+	if err != nil {
+		return err
+	}
+	if role < models.CommunityRoleModerator {
+		return ErrorNoPermission
+	}
+	return nil
+}
+
+// moderatePost 处理帖子所有待处理的举报并记录审计日志，返回审计日志的id
+func moderatePost(userID int64, post *models.Post, action, ip string) (int64, error) {
+	pid := post.PostID
+	status, auditAction := models.ReportStatusDismissed, models.AuditActionDismissReport
+	if action == models.ReportActionRemove {
+		status, auditAction = models.ReportStatusActioned, models.AuditActionRemovePost
+		if err := mysql.DeletePost(pid); err != nil {
+			return 0, err
+		}
+		removePostFromTags(pid)
+		invalidatePostDetail(pid)
+	} else if post.Status == models.PostStatusHidden {
+		if err := mysql.SetPostStatus(pid, models.PostStatusNormal); err != nil {
+			return 0, err
+		}
+	}
+	if err := mongodb.ResolveReports(pid, status, userID); err != nil {
+		return 0, err
+	}
+	return recordAuditSync(userID, auditAction, postTarget(pid), ip)
+}
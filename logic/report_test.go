@@ -0,0 +1,70 @@
+package logic
+
+import (
+	"database/sql"
+	"go-web-app/dao/mysql"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useReportConfig(t *testing.T, cfg *settings.ReportConfig) {
+	old := settings.Conf.ReportConfig
+	settings.Conf.ReportConfig = cfg
+	t.Cleanup(func() { settings.Conf.ReportConfig = old })
+}
+
+func TestReportPostHidesAtThreshold(t *testing.T) {
+	useMySQL(t)
+	useMongo(t)
+	useMiniredis(t)
+	useReportConfig(t, &settings.ReportConfig{HideThreshold: 2})
+	author := createTestUser(t)
+	community := createTestCommunity(t, models.CommunityVisibilityPublic)
+	post := createTestPost(t, author.UserID, community)
+	p := &models.ParamReport{Reason: models.ReportReasonSpam}
+
+	first, second := createTestUser(t), createTestUser(t)
+	require.NoError(t, ReportPost(first.UserID, post.PostID, p))
+	// 同一个用户重复举报不计数
+	assert.ErrorIs(t, ReportPost(first.UserID, post.PostID, p), ErrorReportExists)
+	current, err := mysql.GetPostById(post.PostID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PostStatusNormal, current.Status)
+
+	require.NoError(t, ReportPost(second.UserID, post.PostID, p))
+	current, err = mysql.GetPostById(post.PostID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PostStatusHidden, current.Status)
+
+	// 版主驳回举报之后帖子恢复显示
+	mod := createTestUser(t)
+	_, err = mysql.JoinCommunity(mod.UserID, community, func() error { return nil })
+	require.NoError(t, err)
+	require.NoError(t, mysql.SetCommunityRole(mod.UserID, community, models.CommunityRoleModerator))
+	require.NoError(t, ResolveReport(mod.UserID, post.PostID, models.ReportActionDismiss, "127.0.0.1"))
+	current, err = mysql.GetPostById(post.PostID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PostStatusNormal, current.Status)
+}
+
+func TestReportPostErrors(t *testing.T) {
+	useMySQL(t)
+	useMongo(t)
+	useMiniredis(t)
+	useReportConfig(t, &settings.ReportConfig{})
+	author := createTestUser(t)
+	community := createTestCommunity(t, models.CommunityVisibilityPublic)
+	post := createTestPost(t, author.UserID, community)
+	p := &models.ParamReport{Reason: models.ReportReasonOther, Detail: "test"}
+
+	assert.ErrorIs(t, ReportPost(author.UserID, 1<<60, p), sql.ErrNoRows)
+	// 不是版主不能处理举报
+	require.NoError(t, ReportPost(author.UserID, post.PostID, p))
+	assert.ErrorIs(t, ResolveReport(author.UserID, post.PostID, models.ReportActionRemove, "127.0.0.1"), ErrorNoPermission)
+	_, err := mysql.GetPostById(post.PostID)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,85 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/pkg/swr"
+	"go-web-app/settings"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 使用响应缓存的接口，也是response_cache.endpoints中的名字
+const (
+	CacheTrendingCommunities = "trending_communities"
+	CacheTrendingTags        = "trending_tags"
+	CacheLeaderboard         = "leaderboard"
+	CacheRelatedPosts        = "related_posts"
+)
+
+// responseCacheLookups 按接口和结果统计响应缓存的查询次数，fresh和stale为命中，miss为等待加载
+var responseCacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "response_cache_lookups_total",
+	Help: "Number of response cache lookups by endpoint and result.",
+}, []string{"endpoint", "result"})
+
+func init() {
+	prometheus.MustRegister(responseCacheLookups)
+}
+
+var (
+	responseCache     *swr.Cache
+	responseCacheOnce sync.Once
+
+	// responseCacheGen 每个接口的缓存版本，清除缓存时加一，旧版本的结果不再被读取，之后被LRU淘汰
+	responseCacheGen = struct {
+		sync.Mutex
+		gens map[string]int64
+	}{gens: make(map[string]int64)}
+)
+
+// responseCachePolicy 接口的ttl和grace，没有单独配置的接口使用默认值
+func responseCachePolicy(name string) swr.Policy {
+	cfg := settings.Current().ResponseCacheConfig
+	if cfg == nil {
+		return swr.Policy{}
+	}
+	ttl, grace := cfg.TTL, cfg.Grace
+	if p, ok := cfg.Endpoints[name]; ok && p != nil {
+		ttl, grace = p.TTL, p.Grace
+	}
+	return swr.Policy{TTL: time.Duration(ttl) * time.Second, Grace: time.Duration(grace) * time.Second}
+}
+
+// CachedResponse 按接口的策略缓存load的结果，key由调用方根据影响结果的参数生成
+// load可能在请求结束后在后台执行，传给load的ctx保留请求ctx中的值但不随请求取消；返回的结果会被多个请求共享，调用方不能修改
+func CachedResponse(ctx context.Context, name, key string, load func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	policy := responseCachePolicy(name)
+	if policy.TTL <= 0 {
+		return load(ctx)
+	}
+	ctx = detachContext(ctx)
+	responseCacheOnce.Do(func() {
+		responseCache = swr.New(settings.Current().ResponseCacheConfig.Size)
+	})
+	responseCacheGen.Lock()
+	gen := responseCacheGen.gens[name]
+	responseCacheGen.Unlock()
+	v, result, err := responseCache.Get(name+":"+strconv.FormatInt(gen, 10)+":"+key, policy, func() (interface{}, error) {
+		return load(ctx)
+	})
+	responseCacheLookups.WithLabelValues(name, result.String()).Inc()
+	return v, err
+}
+
+// BustResponseCache 接口依赖的数据变化后调用，下次请求等待重新加载
+// 其他实例的缓存在ttl之后刷新
+func BustResponseCache(names ...string) {
+	responseCacheGen.Lock()
+	for _, name := range names {
+		responseCacheGen.gens[name]++
+	}
+	responseCacheGen.Unlock()
+}
@@ -0,0 +1,142 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var ErrorPublishAtInPast = errors.New("Publish time is in the past. ")
+
+// schedulePublishBatch 每次最多发布多少个到期的帖子
+const schedulePublishBatch = 100
+
+// checkPublishAt 设置帖子的状态，publish_at在将来时为定时发布
+// 早于当前时间但在允许的误差之内时立即发布，草稿不使用publish_at
+func checkPublishAt(p *models.Post) error {
+	if p.Status == models.PostStatusDraft {
+		p.PublishAt = nil
+		return nil
+	}
+	p.Status = models.PostStatusNormal
+	if p.PublishAt == nil {
+		return nil
+	}
+	skew := time.Duration(settings.Current().PostConfig.ScheduleSkew) * time.Second
+	now := time.Now()
+	if p.PublishAt.Before(now.Add(-skew)) {
+		return ErrorPublishAtInPast
+	}
+	if !p.PublishAt.After(now) {
+		p.PublishAt = nil
+		return nil
+	}
+	p.Status = models.PostStatusScheduled
+	return nil
+}
+
+// StartSchedulePublisher 在后台定时发布到期的帖子，返回的函数用于退出时停止
+func StartSchedulePublisher() (stop func(ctx context.Context) error) {
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tick := time.Duration(settings.Current().PostConfig.ScheduleTick) * time.Second
+		if tick <= 0 {
+			tick = 30 * time.Second
+		}
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				publishDuePosts()
+			case <-quit:
+				return
+			}
+		}
+	}()
+	return func(ctx context.Context) error {
+		close(quit)
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func publishDuePosts() {
+	posts, err := mysql.GetDuePosts(schedulePublishBatch)
+	if err != nil {
+		zap.L().Error("mysql.GetDuePosts failed", zap.Error(err))
+		return
+	}
+	for _, post := range posts {
+		if _, err := publishPost(context.Background(), post, models.PostStatusScheduled); err != nil {
+			zap.L().Error("publish scheduled post failed", zap.Int64("pid", post.PostID), zap.Error(err))
+		}
+	}
+}
+
+// publishPost 先在数据库中改为已发布，只有成功修改的请求把帖子加入redis的列表
+// 在列表中的位置按发布的时间计算；加入列表失败时改回原来的状态，返回false，定时帖子在下一次检查时重新发布
+func publishPost(ctx context.Context, post *models.Post, from int32) (published bool, err error) {
+	published, err = mysql.PublishPost(post.PostID, from)
+	if err != nil || !published {
+		return
+	}
+	if err = listPublishedPost(post); err != nil {
+		if uerr := mysql.UnpublishPost(post.PostID, from, post.CreateTime); uerr != nil {
+			zap.L().Error("mysql.UnpublishPost failed", zap.Int64("pid", post.PostID), zap.Error(uerr))
+		}
+		return false, err
+	}
+	recordTagActivity(post.Tags)
+	recordCommunityPost(ctx, post.CommunityIDs)
+	render := loadPostRender(post)
+	notifyPostMentions(post, render.Mentions)
+	announcePost(ctx, post, render.Excerpt)
+	invalidatePostDetail(post.PostID)
+	zap.L().Info("post published", zap.Int64("pid", post.PostID), zap.Int32("from", from))
+	return
+}
+
+// listPublishedPost 把刚发布的帖子加入redis中的各个列表，重复加入不会出错
+func listPublishedPost(post *models.Post) error {
+	fillPostTags(post)
+	if err := fillPostCommunities(post); err != nil {
+		return err
+	}
+	if err := redis.CreatePost(post.PostID, post.CommunityIDs, listedAuthor(post), post.Tags); err != nil {
+		return err
+	}
+	return addPostFlair(post)
+}
+
+// isUnpublished 草稿和还没有到时间的定时帖子只有作者可见
+func isUnpublished(post *models.Post) bool {
+	return post.Status == models.PostStatusDraft || post.Status == models.PostStatusScheduled
+}
+
+// GetScheduledPosts 作者还没有发布的定时帖子
+func GetScheduledPosts(userID int64) ([]*models.Post, error) {
+	posts, err := mysql.GetScheduledPosts(userID)
+	if err != nil {
+		return nil, err
+	}
+	fillPostTags(posts...)
+	return posts, nil
+}
+
+// CancelScheduledPost 取消定时帖子，已经发布的帖子需要通过删除接口处理
+func CancelScheduledPost(userID, pid int64) error {
+	return mysql.CancelScheduledPost(userID, pid)
+}
@@ -0,0 +1,109 @@
+package logic
+
+import (
+	"context"
+	"database/sql"
+	"go-web-app/dao/mysql"
+	"go-web-app/models"
+	"go-web-app/pkg/snowflake"
+	"go-web-app/settings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckPublishAt(t *testing.T) {
+	old := settings.Conf.PostConfig
+	settings.Conf.PostConfig = &settings.PostConfig{ScheduleSkew: 60}
+	t.Cleanup(func() { settings.Conf.PostConfig = old })
+	at := func(d time.Duration) *time.Time {
+		t := time.Now().Add(d)
+		return &t
+	}
+
+	// 草稿不使用publish_at
+	p := &models.Post{Status: models.PostStatusDraft, PublishAt: at(time.Hour)}
+	require.NoError(t, checkPublishAt(p))
+	assert.Equal(t, models.PostStatusDraft, p.Status)
+	assert.Nil(t, p.PublishAt)
+
+	p = &models.Post{}
+	require.NoError(t, checkPublishAt(p))
+	assert.Equal(t, models.PostStatusNormal, p.Status)
+
+	p = &models.Post{PublishAt: at(time.Hour)}
+	require.NoError(t, checkPublishAt(p))
+	assert.Equal(t, models.PostStatusScheduled, p.Status)
+	assert.NotNil(t, p.PublishAt)
+
+	// 在允许的误差之内立即发布
+	p = &models.Post{PublishAt: at(-30 * time.Second)}
+	require.NoError(t, checkPublishAt(p))
+	assert.Equal(t, models.PostStatusNormal, p.Status)
+	assert.Nil(t, p.PublishAt)
+
+	p = &models.Post{PublishAt: at(-2 * time.Minute)}
+	assert.ErrorIs(t, checkPublishAt(p), ErrorPublishAtInPast)
+
+	settings.Conf.PostConfig.ScheduleSkew = 0
+	p = &models.Post{PublishAt: at(-time.Second)}
+	assert.ErrorIs(t, checkPublishAt(p), ErrorPublishAtInPast)
+}
+
+func TestPublishPostRedisFailure(t *testing.T) {
+	useMySQL(t)
+	mr := useMiniredis(t)
+	author := createTestUser(t)
+	post := &models.Post{
+		PostID:      snowflake.GenID(),
+		AuthorId:    author.UserID,
+		CommunityID: createTestCommunity(t, models.CommunityVisibilityPublic),
+		Title:       "scheduled",
+		Content:     "scheduled content",
+		Status:      models.PostStatusScheduled,
+		PublishAt:   func() *time.Time { t := time.Now().Add(-time.Minute); return &t }(),
+	}
+	require.NoError(t, mysql.CreatePost(post))
+	current, err := mysql.GetPostById(post.PostID)
+	require.NoError(t, err)
+
+	// 加入redis的列表失败时不算发布，保持定时的状态，下一次检查时重新发布
+	mr.Close()
+	published, err := publishPost(context.Background(), current, models.PostStatusScheduled)
+	assert.Error(t, err)
+	assert.False(t, published)
+	after, err := mysql.GetPostById(post.PostID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PostStatusScheduled, after.Status)
+	assert.True(t, after.CreateTime.Equal(current.CreateTime))
+}
+
+func TestGetPostByIdForUserDraft(t *testing.T) {
+	useMySQL(t)
+	useMiniredis(t)
+	old := settings.Conf.PostConfig
+	settings.Conf.PostConfig = &settings.PostConfig{DetailCacheTTL: 60}
+	t.Cleanup(func() { settings.Conf.PostConfig = old })
+	author := createTestUser(t)
+	other := createTestUser(t)
+	post := &models.Post{
+		PostID:      snowflake.GenID(),
+		AuthorId:    author.UserID,
+		CommunityID: createTestCommunity(t, models.CommunityVisibilityPublic),
+		Title:       "draft",
+		Content:     "draft content",
+		Status:      models.PostStatusDraft,
+	}
+	require.NoError(t, mysql.CreatePost(post))
+
+	// 作者可以看到自己的草稿，其他人和未登录的用户看不到
+	data, err := GetPostByIdForUser(context.Background(), post.PostID, author.UserID)
+	require.NoError(t, err)
+	assert.Equal(t, post.PostID, data.Post.PostID)
+	_, err = GetPostByIdForUser(context.Background(), post.PostID, other.UserID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	_, err = GetPostByIdForUser(context.Background(), post.PostID, 0)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
@@ -0,0 +1,116 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/dao/mongodb"
+	"go-web-app/dao/mysql"
+	"go-web-app/models"
+	"go-web-app/pkg/highlight"
+	"go-web-app/pkg/markdown"
+	"go-web-app/settings"
+	"html"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// SearchTypeEnabled 类型是否在search.types中，all总是可以使用，只搜索开启的类型
+func SearchTypeEnabled(t string) bool {
+	if t == models.SearchTypeAll {
+		return true
+	}
+	cfg := settings.Current().SearchConfig
+	if cfg == nil {
+		return true
+	}
+	for _, enabled := range cfg.Types {
+		if enabled == t {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchComments 在评论中全文检索，只返回公开可见的帖子下的评论，去掉userID屏蔽的用户的评论
+// 先分页再过滤，一页的结果可能少于size条，hasMore按过滤前的结果判断
+func SearchComments(ctx context.Context, userID int64, p *models.ParamSearch) (list []*models.CommentSearchResult, hasMore bool, err error) {
+	comments, err := mongodb.SearchComments(ctx, p.Query, (p.Page-1)*p.Size, p.Size+1)
+	if err != nil {
+		return nil, false, err
+	}
+	if hasMore = int64(len(comments)) > p.Size; hasMore {
+		comments = comments[:p.Size]
+	}
+	pids := make([]int64, 0, len(comments))
+	for _, c := range comments {
+		pids = append(pids, c.PostID)
+	}
+	titles, err := mysql.GetPublicPostTitles(ctx, uniqueIDs(pids))
+	if err != nil {
+		return nil, false, err
+	}
+	blocked := searchBlockedSet(userID)
+	terms := highlight.Terms(p.Query)
+	maxLen := settings.Current().PostConfig.SearchSnippetLength
+	list = make([]*models.CommentSearchResult, 0, len(comments))
+	authorIDs := make([]int64, 0, len(comments))
+	for _, c := range comments {
+		title, ok := titles[c.PostID]
+		if _, isBlocked := blocked[c.AuthorID]; !ok || isBlocked {
+			continue
+		}
+		snippet := highlight.Snippet(markdown.PlainText(c.Content), terms, maxLen)
+		if snippet == "" {
+			snippet = html.EscapeString(markdown.Excerpt(c.Content))
+		}
+		list = append(list, &models.CommentSearchResult{Comment: c, PostTitle: title, Highlight: snippet})
+		authorIDs = append(authorIDs, c.AuthorID)
+	}
+	profiles, err := getAuthorProfiles(ctx, uniqueIDs(authorIDs))
+	if err != nil {
+		zap.L().Error("getAuthorProfiles failed", zap.Error(err))
+		return list, hasMore, nil
+	}
+	for _, r := range list {
+		r.Author = profiles[r.AuthorID]
+	}
+	return list, hasMore, nil
+}
+
+// SearchUsers 按用户名前缀查找用户，开头的@会被去掉，去掉userID屏蔽的用户
+func SearchUsers(ctx context.Context, userID int64, p *models.ParamSearch) (users []*models.AuthorProfile, hasMore bool, err error) {
+	prefix := strings.TrimPrefix(p.Query, "@")
+	if prefix == "" {
+		return []*models.AuthorProfile{}, false, nil
+	}
+	users, err = mysql.SearchUsersByPrefix(ctx, prefix, (p.Page-1)*p.Size, p.Size+1)
+	if err != nil {
+		return nil, false, err
+	}
+	if hasMore = int64(len(users)) > p.Size; hasMore {
+		users = users[:p.Size]
+	}
+	blocked := searchBlockedSet(userID)
+	if len(blocked) == 0 {
+		return users, hasMore, nil
+	}
+	filtered := make([]*models.AuthorProfile, 0, len(users))
+	for _, u := range users {
+		if _, ok := blocked[u.UserID]; !ok {
+			filtered = append(filtered, u)
+		}
+	}
+	return filtered, hasMore, nil
+}
+
+// searchBlockedSet 未登录时为空，读取屏蔽列表失败时只记录日志，不过滤
+func searchBlockedSet(userID int64) map[int64]struct{} {
+	if userID == 0 {
+		return nil
+	}
+	set, err := getBlockedSet(userID)
+	if err != nil {
+		zap.L().Error("getBlockedSet failed", zap.Int64("userID", userID), zap.Error(err))
+	}
+	return set
+}
@@ -0,0 +1,62 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/dao/mysql"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useSearchConfig(t *testing.T, cfg *settings.SearchConfig) {
+	old := settings.Conf.SearchConfig
+	settings.Conf.SearchConfig = cfg
+	t.Cleanup(func() { settings.Conf.SearchConfig = old })
+}
+
+func TestSearchTypeEnabled(t *testing.T) {
+	// 没有配置时所有类型都可以搜索
+	useSearchConfig(t, nil)
+	assert.True(t, SearchTypeEnabled(models.SearchTypeUsers))
+
+	useSearchConfig(t, &settings.SearchConfig{Types: []string{models.SearchTypePosts, models.SearchTypeComments}})
+	assert.True(t, SearchTypeEnabled(models.SearchTypePosts))
+	assert.True(t, SearchTypeEnabled(models.SearchTypeAll))
+	assert.False(t, SearchTypeEnabled(models.SearchTypeUsers))
+	assert.False(t, SearchTypeEnabled("unknown"))
+}
+
+func TestSearchUsers(t *testing.T) {
+	useMySQL(t)
+	useMiniredis(t)
+	first, second := createTestUser(t), createTestUser(t)
+	prefix := first.Username[:len(first.Username)-3]
+	// 开头的@会被去掉
+	users, _, err := SearchUsers(context.Background(), 0, &models.ParamSearch{Query: "@" + first.Username, Page: 1, Size: 10})
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, first.UserID, users[0].UserID)
+
+	// 注销的用户不出现在结果中
+	require.NoError(t, mysql.SetDeactivated(second.UserID, true))
+	users, _, err = SearchUsers(context.Background(), 0, &models.ParamSearch{Query: prefix, Page: 1, Size: 100})
+	require.NoError(t, err)
+	for _, u := range users {
+		assert.NotEqual(t, second.UserID, u.UserID)
+	}
+	// %和_按字面匹配
+	users, _, err = SearchUsers(context.Background(), 0, &models.ParamSearch{Query: "test%", Page: 1, Size: 10})
+	require.NoError(t, err)
+	assert.Empty(t, users)
+}
+
+func TestSearchUsersEmptyQuery(t *testing.T) {
+	users, hasMore, err := SearchUsers(context.Background(), 0, &models.ParamSearch{Query: "@", Page: 1, Size: 10})
+	require.NoError(t, err)
+	assert.Empty(t, users)
+	assert.NotNil(t, users)
+	assert.False(t, hasMore)
+}
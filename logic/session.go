@@ -0,0 +1,79 @@
+package logic
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/pkg/jwt"
+
+	"go.uber.org/zap"
+)
+
+var ErrorSessionNotFound = errors.New("Session not found. ")
+
+// CheckAccessToken 解析access token并确认账号和会话仍然有效，http接口和websocket共用
+// 注销、封禁或者会话被退出后，已经签发的token立即失效；redis出问题时放行
+func CheckAccessToken(token string) (*jwt.MyClaims, error) {
+	mc, err := jwt.ParseToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if deactivated, err := redis.IsDeactivated(mc.UserID); err != nil {
+		zap.L().Error("redis.IsDeactivated failed", zap.Int64("userID", mc.UserID), zap.Error(err))
+	} else if deactivated {
+		return nil, ErrorAccountDeactivated
+	}
+	if banned, err := redis.IsBanned(mc.UserID); err != nil {
+		zap.L().Error("redis.IsBanned failed", zap.Int64("userID", mc.UserID), zap.Error(err))
+	} else if banned {
+		return nil, ErrorAccountBanned
+	}
+	// 没有会话之前签发的token不检查
+	if mc.SessionID != "" {
+		if exists, err := redis.SessionExists(mc.UserID, mc.SessionID); err != nil {
+			zap.L().Error("redis.SessionExists failed", zap.Int64("userID", mc.UserID), zap.Error(err))
+		} else if !exists {
+			return nil, jwt.ErrInvalidToken
+		}
+	}
+	return mc, nil
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// GetSessions 用户所有有效的登录会话，current为发起请求的会话
+func GetSessions(userID int64, current string) ([]*models.Session, error) {
+	sessions, err := redis.GetSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sessions {
+		s.Current = s.ID == current
+	}
+	return sessions, nil
+}
+
+// RevokeSession 退出一个会话，它的refresh token和access token立即失效
+func RevokeSession(userID int64, sessionID string) error {
+	ok, err := redis.DeleteSession(userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrorSessionNotFound
+	}
+	return nil
+}
+
+// RevokeOtherSessions 退出除了current之外的所有会话，返回退出的会话数
+func RevokeOtherSessions(userID int64, current string) (int, error) {
+	return redis.DeleteOtherSessions(userID, current)
+}
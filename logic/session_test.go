@@ -0,0 +1,42 @@
+package logic
+
+import (
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/pkg/jwt"
+	"go-web-app/settings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckAccessToken(t *testing.T) {
+	useMiniredis(t)
+	old := settings.Conf.AuthConfig
+	settings.Conf.AuthConfig = &settings.AuthConfig{JwtExpire: 1}
+	defer func() { settings.Conf.AuthConfig = old }()
+
+	token, _, err := jwt.GenSessionToken(1, "alice", "s1", time.Hour)
+	require.NoError(t, err)
+	// 会话已经退出
+	_, err = CheckAccessToken(token)
+	assert.ErrorIs(t, err, jwt.ErrInvalidToken)
+
+	require.NoError(t, redis.CreateSession(1, "s1", "refresh", time.Hour, &models.ClientInfo{}, 0))
+	mc, err := CheckAccessToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), mc.UserID)
+
+	require.NoError(t, redis.SetBanned(1, true))
+	_, err = CheckAccessToken(token)
+	assert.ErrorIs(t, err, ErrorAccountBanned)
+
+	require.NoError(t, redis.SetDeactivated(1, true))
+	_, err = CheckAccessToken(token)
+	assert.ErrorIs(t, err, ErrorAccountDeactivated)
+
+	_, err = CheckAccessToken("not-a-token")
+	assert.ErrorIs(t, err, jwt.ErrInvalidToken)
+}
@@ -0,0 +1,61 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"go-web-app/dao/redis"
+	"go-web-app/pkg/snowflake"
+	"go-web-app/settings"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// LeaseMachineID 从redis租用一个不重复的机器id，并在后台定时续期。
+// 租约丢失时关闭lost，返回的release用于退出时停止续期并释放租约
+func LeaseMachineID() (id int64, lost <-chan struct{}, release func() error, err error) {
+	hostname, _ := os.Hostname()
+	owner := fmt.Sprintf("%s:%d:%d", hostname, os.Getpid(), time.Now().UnixNano())
+	ttl := time.Duration(settings.Current().SnowflakeConfig.LeaseTTL) * time.Second
+	if ttl < 3*time.Second {
+		return 0, nil, nil, fmt.Errorf("snowflake.lease_ttl must be at least 3 seconds, got %v", ttl)
+	}
+	if id, err = redis.LeaseMachineID(snowflake.MaxMachineID(), owner, ttl); err != nil {
+		return 0, nil, nil, err
+	}
+	stop := make(chan struct{})
+	lostCh := make(chan struct{})
+	go renewMachineID(id, owner, ttl, stop, lostCh)
+	release = func() error {
+		close(stop)
+		return redis.ReleaseMachineID(id, owner)
+	}
+	return id, lostCh, release, nil
+}
+
+// renewMachineID 定期续期租约。租约被其他实例拿走，或者下一次续期之前租约就会过期时，
+// 其他实例可能会使用同一个id，关闭lost，调用方需要停止生成id
+func renewMachineID(id int64, owner string, ttl time.Duration, stop <-chan struct{}, lost chan<- struct{}) {
+	interval := ttl / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			err := redis.RenewMachineID(id, owner, ttl)
+			if err == nil {
+				renewed = time.Now()
+				continue
+			}
+			zap.L().Error("renew machine id lease failed", zap.Int64("machine_id", id), zap.Error(err))
+			if errors.Is(err, redis.ErrNoMachineID) || time.Since(renewed)+interval >= ttl {
+				close(lost)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package logic
+
+import (
+	"go-web-app/dao/redis"
+	"go-web-app/pkg/snowflake"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func waitLost(t *testing.T, lost <-chan struct{}, timeout time.Duration) bool {
+	t.Helper()
+	select {
+	case <-lost:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func TestRenewMachineIDLost(t *testing.T) {
+	mr := useMiniredis(t)
+	ttl := 300 * time.Millisecond
+	id, err := redis.LeaseMachineID(3, "me", ttl)
+	require.NoError(t, err)
+
+	// 正常续期时不会丢失租约
+	stop, lost := make(chan struct{}), make(chan struct{})
+	go renewMachineID(id, "me", ttl, stop, lost)
+	assert.False(t, waitLost(t, lost, 2*ttl))
+
+	// 租约被其他实例拿走时立即通知
+	var key string
+	for _, k := range mr.Keys() {
+		if owner, _ := mr.Get(k); owner == "me" {
+			key = k
+		}
+	}
+	require.NotEmpty(t, key)
+	require.NoError(t, mr.Set(key, "other"))
+	assert.True(t, waitLost(t, lost, ttl))
+	close(stop)
+
+	// redis不可用时，在租约过期之前通知
+	require.NoError(t, mr.Set(key, "me"))
+	stop, lost = make(chan struct{}), make(chan struct{})
+	defer close(stop)
+	start := time.Now()
+	go renewMachineID(id, "me", ttl, stop, lost)
+	mr.Close()
+	assert.True(t, waitLost(t, lost, 2*ttl))
+	assert.Less(t, int64(time.Since(start)), int64(ttl))
+}
+
+func TestBackgroundWorkSkipsAfterSnowflakeStop(t *testing.T) {
+	mr := useMiniredis(t)
+	snowflake.Stop()
+	t.Cleanup(func() { require.NoError(t, snowflake.Init("2020-01-01", 1)) })
+
+	// 租约丢失后后台任务还在运行，生成id的地方跳过而不是panic
+	assert.NotPanics(t, func() {
+		assert.ErrorIs(t, queueEmail("a@example.com", "subject", "body"), snowflake.ErrStopped)
+	})
+	assert.Empty(t, mr.Keys())
+}
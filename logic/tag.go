@@ -0,0 +1,162 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+const (
+	maxTagLength      = 32 // 与tag表name字段长度一致
+	tagSuggestionSize = 10 // 自动补全最多返回的标签数
+)
+
+var (
+	ErrorInvalidTag  = errors.New("Invalid tag. ")
+	ErrorTooManyTags = errors.New("Too many tags. ")
+)
+
+// NormalizeTag 标签统一转为小写并去掉首尾空白
+// 只允许字母、数字和 - _ + # . ，这样c++、c#、node.js之类的标签可以直接使用
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" || utf8.RuneCountInString(tag) > maxTagLength {
+		return "", ErrorInvalidTag
+	}
+	for _, r := range tag {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("-_+#.", r) {
+			continue
+		}
+		return "", ErrorInvalidTag
+	}
+	return tag, nil
+}
+
+// normalizeTags 规范化并去重，超过post.max_tags时返回ErrorTooManyTags
+func normalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]struct{}, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		t, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[t]; ok {
+			continue
+		}
+		seen[t] = struct{}{}
+		normalized = append(normalized, t)
+	}
+	if len(normalized) > settings.Current().PostConfig.MaxTags {
+		return nil, ErrorTooManyTags
+	}
+	return normalized, nil
+}
+
+// fillPostTags 为帖子补充标签，查询失败时只记录日志，不影响帖子的展示
+func fillPostTags(posts ...*models.Post) {
+	ids := make([]int64, 0, len(posts))
+	for _, post := range posts {
+		ids = append(ids, post.PostID)
+	}
+	tags, err := mysql.GetTagsByPostIDs(ids)
+	if err != nil {
+		zap.L().Error("mysql.GetTagsByPostIDs failed", zap.Error(err))
+		return
+	}
+	for _, post := range posts {
+		post.Tags = tags[post.PostID]
+	}
+}
+
+// removePostFromTags 帖子被删除后从标签的有序集合中移除，热门标签和相关帖子的缓存一起清除
+func removePostFromTags(pid int64) {
+	BustResponseCache(CacheTrendingTags, CacheRelatedPosts)
+	tags, err := mysql.GetPostTags(pid)
+	if err == nil {
+		err = redis.RemovePostFromTags(pid, tags)
+	}
+	if err != nil {
+		zap.L().Error("remove post from tags failed", zap.Int64("pid", pid), zap.Error(err))
+	}
+}
+
+// GetTagPostList 标签下的帖子，按发布时间倒序，不包括私有社区的帖子
+func GetTagPostList(ctx context.Context, tag string, page, size int64) (data []*models.PostDetail, hasMore bool, err error) {
+	ids, hasMore, err := redis.GetTagPostIDs(tag, page, size)
+	if err != nil || len(ids) == 0 {
+		return nil, hasMore, err
+	}
+	if data, err = getPostDetailListByIDs(ctx, ids); err != nil {
+		return
+	}
+	data = filterPublicPosts(data)
+	return
+}
+
+// GetTag 返回标签下的帖子数
+func GetTag(tag string) (*models.Tag, error) {
+	counts, err := redis.GetTagPostCounts([]string{tag})
+	if err != nil {
+		return nil, err
+	}
+	return &models.Tag{Name: tag, PostCount: counts[0]}, nil
+}
+
+// SuggestTags 按前缀补全标签，附带每个标签的帖子数
+func SuggestTags(prefix string) ([]*models.Tag, error) {
+	names, err := mysql.SearchTagsByPrefix(prefix, tagSuggestionSize)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := redis.GetTagPostCounts(names)
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]*models.Tag, 0, len(names))
+	for i, name := range names {
+		tags = append(tags, &models.Tag{Name: name, PostCount: counts[i]})
+	}
+	return tags, nil
+}
+
+// recordTagActivity 记录标签的活跃次数，失败只记录日志
+func recordTagActivity(tags []string) {
+	window := time.Duration(settings.Current().TagConfig.TrendingWindow) * time.Hour
+	if err := redis.IncrTagActivity(tags, window); err != nil {
+		zap.L().Error("redis.IncrTagActivity failed", zap.Strings("tags", tags), zap.Error(err))
+	}
+}
+
+// recordVoteActivity 帖子被投票时，它的每个标签都计一次活跃
+func recordVoteActivity(postID string) {
+	pid, err := strconv.ParseInt(postID, 10, 64)
+	if err != nil {
+		return
+	}
+	tags, err := mysql.GetPostTags(pid)
+	if err != nil {
+		zap.L().Error("mysql.GetPostTags failed", zap.Int64("pid", pid), zap.Error(err))
+		return
+	}
+	recordTagActivity(tags)
+}
+
+// GetTrendingTags 最近一段时间内新帖子和投票最多的标签
+func GetTrendingTags() ([]*models.TrendingTag, error) {
+	cfg := settings.Current().TagConfig
+	if cfg.TrendingWindow < 1 || cfg.TrendingSize < 1 {
+		return []*models.TrendingTag{}, nil
+	}
+	return redis.GetTrendingTags(cfg.TrendingWindow, cfg.TrendingSize)
+}
@@ -0,0 +1,205 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	tagHotJobName = "tag:hot:notify"
+	tagHotLockTTL = time.Minute
+	// tagHotBatch 每次检查最多处理的热门帖子数
+	tagHotBatch = 100
+	// tagHotNotifiedKeep 帖子通知过之后的记录保留时间，热度分数随时间衰减，过期后基本不会再超过阈值
+	tagHotNotifiedKeep = 7 * 24 * time.Hour
+)
+
+var ErrorTooManyFollowedTags = errors.New("Too many followed tags. ")
+
+// FollowTag 关注标签，已经关注时不做修改，超过tag.max_followed时返回ErrorTooManyFollowedTags
+func FollowTag(userID int64, tag string) error {
+	tags, err := mysql.GetFollowedTags(userID)
+	if err != nil {
+		return err
+	}
+	for _, t := range tags {
+		if t == tag {
+			return nil
+		}
+	}
+	if max := settings.Current().TagConfig.MaxFollowed; max > 0 && len(tags) >= max {
+		return ErrorTooManyFollowedTags
+	}
+	_, err = mysql.FollowTag(userID, tag, func() error {
+		return redis.AddTagFollow(userID, tag)
+	})
+	return err
+}
+
+// UnfollowTag 取消关注标签，没有关注时不做修改
+func UnfollowTag(userID int64, tag string) error {
+	_, err := mysql.UnfollowTag(userID, tag, func() error {
+		return redis.RemoveTagFollow(userID, tag)
+	})
+	return err
+}
+
+// GetFollowedTags 用户关注的标签，附带每个标签的帖子数
+func GetFollowedTags(userID int64) ([]*models.Tag, error) {
+	names, err := mysql.GetFollowedTags(userID)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := redis.GetTagPostCounts(names)
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]*models.Tag, 0, len(names))
+	for i, name := range names {
+		tags = append(tags, &models.Tag{Name: name, PostCount: counts[i]})
+	}
+	return tags, nil
+}
+
+// StartTagHotNotifier 定期检查热度分数超过tag.hot_threshold的帖子，通知帖子标签的关注者，返回的函数用于退出时停止
+// 是否接收、免打扰时段由notify按用户的通知设置处理，邮件通过每天的摘要发送
+func StartTagHotNotifier() (stop func(ctx context.Context) error) {
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := rebuildTagFollows(); err != nil {
+			zap.L().Error("rebuild tag follows failed", zap.Error(err))
+		}
+		interval := time.Duration(settings.Current().TagConfig.HotInterval) * time.Second
+		if interval <= 0 {
+			interval = 5 * time.Minute
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if !settings.Current().TagConfig.NotifyHot {
+					continue
+				}
+				if err := notifyHotTagPosts(); err != nil {
+					zap.L().Error("notify hot tag posts failed", zap.Error(err))
+				}
+			case <-quit:
+				return
+			}
+		}
+	}()
+	return func(ctx context.Context) error {
+		close(quit)
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// rebuildTagFollows redis的数据丢失时从mysql重建标签的关注关系
+func rebuildTagFollows() error {
+	loaded, err := redis.TagFollowsLoaded()
+	if err != nil || loaded {
+		return err
+	}
+	follows, err := mysql.GetAllTagFollows()
+	if err != nil {
+		return err
+	}
+	zap.L().Info("rebuild tag follows from mysql", zap.Int("follows", len(follows)))
+	return redis.LoadTagFollows(follows)
+}
+
+// notifyHotTagPosts 只有一个实例会执行，每个帖子只处理一次
+// 重复处理时CreateNotificationOnce保证同一个用户不会收到同一个帖子的多条通知
+func notifyHotTagPosts() error {
+	lock, ok, err := redis.AcquireLock(context.Background(), tagHotJobName, tagHotLockTTL)
+	if err != nil || !ok {
+		return err
+	}
+	ctx, cancel := lock.AutoRenewContext(context.Background())
+	defer cancel()
+	defer func() {
+		if err := redis.ReleaseLock(context.Background(), lock); err != nil {
+			zap.L().Warn("redis.ReleaseLock failed", zap.String("key", tagHotJobName), zap.Error(err))
+		}
+	}()
+
+	ids, err := redis.GetHotPostIDsAbove(settings.Current().TagConfig.HotThreshold, tagHotBatch)
+	if err != nil {
+		return err
+	}
+	pids := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		added, err := redis.MarkHotPostNotified(id, tagHotNotifiedKeep)
+		if err != nil {
+			return err
+		}
+		if !added {
+			continue
+		}
+		if pid, err := strconv.ParseInt(id, 10, 64); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	if len(pids) == 0 {
+		return nil
+	}
+	posts, err := mysql.GetPostsByIDs(ctx, pids)
+	if err != nil {
+		for _, pid := range pids {
+			if e := redis.UnmarkHotPostNotified(strconv.FormatInt(pid, 10)); e != nil {
+				zap.L().Error("redis.UnmarkHotPostNotified failed", zap.Int64("pid", pid), zap.Error(e))
+			}
+		}
+		return err
+	}
+	fillPostTags(posts...)
+	sent := 0
+	for _, post := range posts {
+		if ctx.Err() != nil {
+			break
+		}
+		sent += notifyTagFollowers(ctx, post)
+	}
+	zap.L().Info("hot tag posts notified", zap.Int("posts", len(posts)), zap.Int("notifications", sent))
+	return nil
+}
+
+// notifyTagFollowers 通知关注了帖子任意一个标签的用户，私有社区的帖子不通知，返回通知的用户数
+func notifyTagFollowers(ctx context.Context, post *models.Post) int {
+	if len(post.Tags) == 0 {
+		return 0
+	}
+	community, err := getCommunityDetail(ctx, post.CommunityID)
+	if err != nil {
+		zap.L().Error("getCommunityDetail failed", zap.Int64("community_id", post.CommunityID), zap.Error(err))
+		return 0
+	}
+	if community.IsPrivate() {
+		return 0
+	}
+	uids, err := redis.GetTagFollowers(post.Tags)
+	if err != nil {
+		zap.L().Error("redis.GetTagFollowers failed", zap.Int64("pid", post.PostID), zap.Error(err))
+		return 0
+	}
+	for _, uid := range uids {
+		notify(uid, listedAuthor(post), models.NotificationTrendingTag, post.PostID, true)
+	}
+	return len(uids)
+}
@@ -0,0 +1,60 @@
+package logic
+
+import (
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	for _, c := range []struct{ in, want string }{
+		{" Go ", "go"},
+		{"C++", "c++"},
+		{"c#", "c#"},
+		{"Node.js", "node.js"},
+		{"机器学习", "机器学习"},
+	} {
+		got, err := NormalizeTag(c.in)
+		require.NoError(t, err, c.in)
+		assert.Equal(t, c.want, got)
+	}
+	for _, in := range []string{"", "  ", "two words", "a/b", strings.Repeat("a", maxTagLength+1)} {
+		_, err := NormalizeTag(in)
+		assert.ErrorIs(t, err, ErrorInvalidTag, in)
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	old := settings.Conf.PostConfig
+	settings.Conf.PostConfig = &settings.PostConfig{MaxTags: 2}
+	t.Cleanup(func() { settings.Conf.PostConfig = old })
+
+	// 规范化之后重复的标签只保留一个
+	tags, err := normalizeTags([]string{"Go", "go ", "rust"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"go", "rust"}, tags)
+
+	_, err = normalizeTags([]string{"go", "rust", "zig"})
+	assert.ErrorIs(t, err, ErrorTooManyTags)
+	_, err = normalizeTags([]string{"go", "a b"})
+	assert.ErrorIs(t, err, ErrorInvalidTag)
+}
+
+func TestGetTag(t *testing.T) {
+	mr := useMiniredis(t)
+	require.NoError(t, redis.AddPostToTags(1, []string{"go"}, time.Now()))
+	require.NoError(t, redis.AddPostToTags(2, []string{"go"}, time.Now()))
+	tag, err := GetTag("go")
+	require.NoError(t, err)
+	assert.Equal(t, &models.Tag{Name: "go", PostCount: 2}, tag)
+
+	mr.Close()
+	_, err = GetTag("go")
+	assert.Error(t, err)
+}
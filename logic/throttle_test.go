@@ -0,0 +1,79 @@
+package logic
+
+import (
+	"go-web-app/dao/mysql"
+	"go-web-app/settings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useLoginThrottle(t *testing.T, cfg *settings.LoginThrottleConfig) {
+	old := settings.Conf.LoginThrottleConfig
+	settings.Conf.LoginThrottleConfig = cfg
+	t.Cleanup(func() { settings.Conf.LoginThrottleConfig = old })
+}
+
+func TestLoginThrottleAccountAcrossIPs(t *testing.T) {
+	mr := useMiniredis(t)
+	useLoginThrottle(t, &settings.LoginThrottleConfig{Window: 15, Cooldown: 15, AccountThreshold: 3, AccountCooldown: 5, IPThreshold: 100})
+
+	// 换IP不能绕过账号的计数
+	for _, ip := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"} {
+		require.NoError(t, checkLoginLocked("alice", ip))
+		recordLoginResult("alice", ip, mysql.ErrorInvalidPassword)
+	}
+	err := checkLoginLocked("alice", "4.4.4.4")
+	var locked *LoginLockedError
+	require.ErrorAs(t, err, &locked)
+	assert.ErrorIs(t, err, ErrorLoginLocked)
+	// 账号的锁定时间使用account_cooldown
+	assert.LessOrEqual(t, locked.RetryAfter, 5*time.Minute)
+	assert.NoError(t, checkLoginLocked("bob", "4.4.4.4"))
+
+	mr.FastForward(5 * time.Minute)
+	assert.NoError(t, checkLoginLocked("alice", "4.4.4.4"))
+}
+
+func TestLoginThrottleAccountIgnoresCase(t *testing.T) {
+	useMiniredis(t)
+	useLoginThrottle(t, &settings.LoginThrottleConfig{Window: 15, Cooldown: 15, AccountThreshold: 3, IPThreshold: 100})
+
+	// 数据库中这些写法是同一个账号，计数也要算在一起
+	for _, name := range []string{"alice", "Alice", "ALICE "} {
+		require.NoError(t, checkLoginLocked(name, "1.1.1.1"))
+		recordLoginResult(name, "1.1.1.1", mysql.ErrorInvalidPassword)
+	}
+	assert.ErrorIs(t, checkLoginLocked(" aLiCe", "2.2.2.2"), ErrorLoginLocked)
+}
+
+func TestLoginThrottleSuccessKeepsIPCount(t *testing.T) {
+	useMiniredis(t)
+	useLoginThrottle(t, &settings.LoginThrottleConfig{Window: 15, Cooldown: 15, AccountThreshold: 3, IPThreshold: 3})
+
+	ip := "1.1.1.1"
+	recordLoginResult("alice", ip, mysql.ErrorInvalidPassword)
+	recordLoginResult("alice", ip, mysql.ErrorInvalidPassword)
+	// 用自己的账号登录成功只清空自己账号的计数
+	recordLoginResult("mallory", ip, nil)
+	recordLoginResult("alice", ip, nil)
+	recordLoginResult("carol", ip, mysql.ErrorUserNotExist)
+	assert.ErrorIs(t, checkLoginLocked("dave", ip), ErrorLoginLocked)
+	assert.NoError(t, checkLoginLocked("alice", "2.2.2.2"))
+}
+
+func TestLoginThrottleIgnoresOtherErrors(t *testing.T) {
+	useMiniredis(t)
+	useLoginThrottle(t, &settings.LoginThrottleConfig{Window: 15, Cooldown: 15, AccountThreshold: 1, IPThreshold: 1})
+
+	recordLoginResult("alice", "1.1.1.1", ErrorEmailNotVerified)
+	assert.NoError(t, checkLoginLocked("alice", "1.1.1.1"))
+}
+
+func TestAccountCooldown(t *testing.T) {
+	assert.Equal(t, 5*time.Minute, accountCooldown(&settings.LoginThrottleConfig{Cooldown: 15, AccountCooldown: 5}))
+	assert.Equal(t, 15*time.Minute, accountCooldown(&settings.LoginThrottleConfig{Cooldown: 15}))
+	assert.Equal(t, maxAccountCooldown, accountCooldown(&settings.LoginThrottleConfig{Cooldown: 600}))
+}
@@ -0,0 +1,341 @@
+package logic
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/pkg/totp"
+	"go-web-app/settings"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// totpSkew 允许前后一个周期的时钟误差
+	totpSkew = 1
+	// totpUsedKeep 验证码使用过的记录保留到它不可能再通过校验
+	totpUsedKeep = (2*totpSkew + 1) * totp.Period * time.Second
+	// recoveryCodeBytes 每个恢复码的随机字节数，显示为xxxxx-xxxxx
+	recoveryCodeBytes = 5
+)
+
+var (
+	ErrorTwoFactorNotConfigured = errors.New("Two-factor authentication is not configured. ")
+	ErrorTwoFactorEnabled       = errors.New("Two-factor authentication is already enabled. ")
+	ErrorTwoFactorNotEnabled    = errors.New("Two-factor authentication is not enabled. ")
+	ErrorTwoFactorNotSetup      = errors.New("Generate a two-factor secret first. ")
+	ErrorTwoFactorCodeInvalid   = errors.New("Two-factor code is invalid. ")
+	ErrorTwoFactorRequired      = errors.New("Two-factor code is required. ")
+)
+
+// TwoFactorRequiredError 密码正确但是账号开启了两步验证，客户端用Challenge中的临时token提交验证码
+type TwoFactorRequiredError struct {
+	Challenge *models.TwoFactorChallenge
+}
+
+func (e *TwoFactorRequiredError) Error() string {
+	return ErrorTwoFactorRequired.Error()
+}
+
+func (e *TwoFactorRequiredError) Is(target error) bool {
+	return target == ErrorTwoFactorRequired
+}
+
+func twoFactorConfig() (*settings.TwoFactorConfig, error) {
+	cfg := settings.Current().TwoFactorConfig
+	if cfg == nil || cfg.SecretKey == "" {
+		return nil, ErrorTwoFactorNotConfigured
+	}
+	return cfg, nil
+}
+
+// GetTwoFactorStatus 是否开启了两步验证和剩余的恢复码数量
+func GetTwoFactorStatus(userID int64) (*models.TwoFactorStatus, error) {
+	enabled, err := mysql.TOTPEnabled(userID)
+	if err != nil || !enabled {
+		return &models.TwoFactorStatus{}, err
+	}
+	n, err := mysql.CountRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.TwoFactorStatus{Enabled: true, RecoveryCodes: n}, nil
+}
+
+// SetupTwoFactor 生成新的密钥，提交第一个验证码之前两步验证不会开启，重复调用时替换之前的密钥
+func SetupTwoFactor(userID int64) (*models.TwoFactorSetup, error) {
+	cfg, err := twoFactorConfig()
+	if err != nil {
+		return nil, err
+	}
+	enabled, err := mysql.TOTPEnabled(userID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrorTwoFactorEnabled
+	}
+	user, err := mysql.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err = mysql.SaveTOTPSecret(userID, secret); err != nil {
+		return nil, err
+	}
+	return &models.TwoFactorSetup{
+		Secret: secret,
+		URI:    totp.ProvisioningURI(cfg.Issuer, user.Username, secret),
+	}, nil
+}
+
+// EnableTwoFactor 校验验证器应用中的第一个验证码，通过后开启两步验证并返回恢复码
+func EnableTwoFactor(userID int64, code string) (*models.RecoveryCodes, error) {
+	cfg, err := twoFactorConfig()
+	if err != nil {
+		return nil, err
+	}
+	t, err := mysql.GetTOTP(userID)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, ErrorTwoFactorNotSetup
+	}
+	if t.Enabled {
+		return nil, ErrorTwoFactorEnabled
+	}
+	if err = verifyTwoFactorCode(t, code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newRecoveryCodes(cfg.RecoveryCodes)
+	if err != nil {
+		return nil, err
+	}
+	enabled, err := mysql.EnableTOTP(userID, hashes)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, ErrorTwoFactorEnabled
+	}
+	zap.L().Info("two-factor authentication enabled", zap.Int64("userID", userID))
+	return &models.RecoveryCodes{Codes: codes}, nil
+}
+
+// DisableTwoFactor 关闭两步验证，需要一个验证码或恢复码
+func DisableTwoFactor(userID int64, code string) error {
+	t, err := enabledTOTP(userID)
+	if err != nil {
+		return err
+	}
+	if err = verifyTwoFactorCode(t, code); err != nil {
+		return err
+	}
+	zap.L().Info("two-factor authentication disabled", zap.Int64("userID", userID))
+	return mysql.DisableTOTP(userID)
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，之前的恢复码全部失效
+func RegenerateRecoveryCodes(userID int64, code string) (*models.RecoveryCodes, error) {
+	cfg, err := twoFactorConfig()
+	if err != nil {
+		return nil, err
+	}
+	t, err := enabledTOTP(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err = verifyTwoFactorCode(t, code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newRecoveryCodes(cfg.RecoveryCodes)
+	if err != nil {
+		return nil, err
+	}
+	if err = mysql.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return &models.RecoveryCodes{Codes: codes}, nil
+}
+
+func enabledTOTP(userID int64) (*models.UserTOTP, error) {
+	t, err := mysql.GetTOTP(userID)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil || !t.Enabled {
+		return nil, ErrorTwoFactorNotEnabled
+	}
+	return t, nil
+}
+
+// newTwoFactorChallenge 密码校验通过之后生成临时token，lifetime为验证通过后refresh token的有效期
+func newTwoFactorChallenge(userID int64, lifetime time.Duration) (*TwoFactorRequiredError, error) {
+	ttl := 5 * time.Minute
+	if cfg := settings.Current().TwoFactorConfig; cfg != nil && cfg.ChallengeTTL > 0 {
+		ttl = time.Duration(cfg.ChallengeTTL) * time.Second
+	}
+	token, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	if err = redis.SetTwoFactorChallenge(token, userID, lifetime, ttl); err != nil {
+		return nil, err
+	}
+	return &TwoFactorRequiredError{Challenge: &models.TwoFactorChallenge{
+		Token:      token,
+		ExpireTime: time.Now().Add(ttl),
+	}}, nil
+}
+
+// LoginTwoFactor 用登录时返回的临时token和验证码换取正式的token
+// 每个临时token最多尝试two_factor.max_attempts次，超过后需要重新输入密码
+func LoginTwoFactor(p *models.ParamTwoFactorLogin, client *models.ClientInfo) (userID int64, token *models.Token, err error) {
+	userID, lifetime, err := redis.GetTwoFactorChallenge(p.Token)
+	if err != nil {
+		return 0, nil, err
+	}
+	attempts, err := redis.IncrTwoFactorAttempts(p.Token)
+	if err != nil {
+		return 0, nil, err
+	}
+	if cfg := settings.Current().TwoFactorConfig; cfg != nil && cfg.MaxAttempts > 0 && attempts > cfg.MaxAttempts {
+		if _, err := redis.ConsumeTwoFactorChallenge(p.Token); err != nil {
+			zap.L().Error("redis.ConsumeTwoFactorChallenge failed", zap.Int64("userID", userID), zap.Error(err))
+		}
+		return 0, nil, redis.ErrTwoFactorChallengeInvalid
+	}
+	t, err := mysql.GetTOTP(userID)
+	if err != nil {
+		return 0, nil, err
+	}
+	// 登录过程中关闭了两步验证
+	if t == nil || !t.Enabled {
+		return 0, nil, redis.ErrTwoFactorChallengeInvalid
+	}
+	if err = verifyTwoFactorCode(t, p.Code); err != nil {
+		return 0, nil, err
+	}
+	ok, err := redis.ConsumeTwoFactorChallenge(p.Token)
+	if err != nil {
+		return 0, nil, err
+	}
+	if !ok {
+		return 0, nil, redis.ErrTwoFactorChallengeInvalid
+	}
+	user, err := mysql.GetUserByID(userID)
+	if err != nil {
+		return 0, nil, err
+	}
+	// 输入密码之后到输入验证码之前，账号可能被封禁或注销
+	if err = checkLoginAllowed(user); err != nil {
+		return 0, nil, err
+	}
+	token, err = issueToken(user.UserID, user.Username, lifetime, client)
+	return user.UserID, token, err
+}
+
+func twoFactorThrottleKey(userID int64) string {
+	return "2fa:" + strconv.FormatInt(userID, 10)
+}
+
+// verifyTwoFactorCode 校验验证码或恢复码，账号的错误次数过多时在锁定期间直接返回LoginLockedError
+func verifyTwoFactorCode(t *models.UserTOTP, code string) error {
+	key := twoFactorThrottleKey(t.UserID)
+	ttl, err := redis.GetLoginLockTTL(key)
+	if err != nil {
+		zap.L().Error("redis.GetLoginLockTTL failed", zap.Int64("userID", t.UserID), zap.Error(err))
+	}
+	if ttl > 0 {
+		return &LoginLockedError{RetryAfter: ttl}
+	}
+	ok, err := checkTwoFactorCode(t, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		recordTwoFactorFailure(key)
+		return ErrorTwoFactorCodeInvalid
+	}
+	if err := redis.ResetLoginFailures(key); err != nil {
+		zap.L().Error("redis.ResetLoginFailures failed", zap.Int64("userID", t.UserID), zap.Error(err))
+	}
+	return nil
+}
+
+// checkTwoFactorCode 6位数字按TOTP校验，每个验证码只能使用一次；其他的按恢复码校验
+func checkTwoFactorCode(t *models.UserTOTP, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits && isDigits(code) {
+		step, ok := totp.Validate(t.Secret, code, time.Now(), totpSkew)
+		if !ok {
+			return false, nil
+		}
+		return redis.MarkTOTPUsed(t.UserID, step, totpUsedKeep)
+	}
+	return mysql.UseRecoveryCode(t.UserID, hashRecoveryCode(code))
+}
+
+// recordTwoFactorFailure 错误次数按login_throttle的窗口统计，达到two_factor.fail_threshold时锁定
+func recordTwoFactorFailure(key string) {
+	cfg, throttle := settings.Current().TwoFactorConfig, settings.Current().LoginThrottleConfig
+	if cfg == nil || throttle == nil || cfg.FailThreshold <= 0 {
+		return
+	}
+	window := time.Duration(throttle.Window) * time.Minute
+	cooldown := time.Duration(throttle.Cooldown) * time.Minute
+	locked, err := redis.RecordLoginFailure(key, window, cfg.FailThreshold, cooldown)
+	if err != nil {
+		zap.L().Error("redis.RecordLoginFailure failed", zap.String("key", key), zap.Error(err))
+		return
+	}
+	if locked {
+		zap.L().Warn("two-factor locked after repeated failures", zap.String("key", key))
+	}
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRecoveryCodes 生成n个恢复码，返回给用户的明文和保存的哈希
+func newRecoveryCodes(n int) (codes, hashes []string, err error) {
+	if n <= 0 {
+		n = 10
+	}
+	b := make([]byte, recoveryCodeBytes)
+	for i := 0; i < n; i++ {
+		if _, err = rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		s := hex.EncodeToString(b)
+		code := s[:len(s)/2] + "-" + s[len(s)/2:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode 恢复码是随机生成的，不需要加盐；忽略大小写、空格和连字符
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,36 +1,405 @@
 package logic
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
 	"go-web-app/models"
+	"go-web-app/pkg/avatar"
+	"go-web-app/pkg/email"
 	"go-web-app/pkg/jwt"
 	"go-web-app/pkg/snowflake"
+	"go-web-app/settings"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	passwordResetTokenExpire = 15 * time.Minute
+	verifyEmailTokenExpire   = 24 * time.Hour
+	verifyResendInterval     = time.Minute
+	reactivateTokenExpire    = time.Hour
+)
+
+var (
+	ErrorEmailNotVerified        = errors.New("Email is not verified. ")
+	ErrorVerifyResendTooFrequent = errors.New("Verification email was sent recently. ")
+	ErrorAccountDeactivated      = errors.New("Account is deactivated. ")
+	ErrorAccountBanned           = errors.New("Account is banned. ")
 )
 
-func SignUp(p *models.ParamSignUp) (err error) {
+func SignUp(ctx context.Context, p *models.ParamSignUp) (user *models.User, err error) {
 	// check if user existed
-	if err := mysql.CheckUserExist(p.Username); err != nil {
-		return err
+	if err := checkUsernameAvailable(0, p.Username); err != nil {
+		return nil, err
+	}
+	if err := mysql.CheckEmailExist(p.Email); err != nil {
+		return nil, err
 	}
 	// generate UID
 	userID := snowflake.GenID()
-	user := &models.User{
+	user = &models.User{
 		UserID:   userID,
 		Username: p.Username,
 		Password: p.Password,
+		Email:    p.Email,
 	}
 
 	// write into database
-	return mysql.InsertUser(user)
+	if err := mysql.InsertUser(user, signupCommunities(), afterSignupJoin(ctx, user.UserID)); err != nil {
+		return nil, err
+	}
+	// 账号在邮箱验证之前处于未验证状态，验证邮件发送失败可以通过重发接口补发
+	if err := sendVerifyEmail(user); err != nil {
+		zap.L().Error("sendVerifyEmail failed", zap.Int64("userID", user.UserID), zap.Error(err))
+	}
+	return user, nil
+}
+
+// checkLoginAllowed 密码和两步验证都通过后，账号还需要是正常状态并且验证过邮箱
+func checkLoginAllowed(user *models.User) error {
+	if user.Deactivated {
+		return ErrorAccountDeactivated
+	}
+	if user.Banned {
+		return ErrorAccountBanned
+	}
+	if !user.EmailVerified {
+		return ErrorEmailNotVerified
+	}
+	return nil
 }
 
-func Login(p *models.ParamLogin) (token string, err error) {
+// Login 校验用户名和密码，同一个账号或同一个IP失败次数过多时临时锁定，见login_throttle
+func Login(p *models.ParamLogin, client *models.ClientInfo) (userID int64, token *models.Token, err error) {
+	if err := checkLoginLocked(p.Username, client.IP); err != nil {
+		return 0, nil, err
+	}
 	user := &models.User{
 		Username: p.Username,
 		Password: p.Password,
 	}
-	if err := mysql.Login(user); err != nil {
+	err = mysql.Login(user)
+	recordLoginResult(p.Username, client.IP, err)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err = checkLoginAllowed(user); err != nil {
+		return 0, nil, err
+	}
+	lifetime := jwt.RefreshTokenExpire()
+	if p.Remember {
+		lifetime = jwt.RememberTokenExpire()
+	}
+	// 开启了两步验证时先返回临时token，验证码通过后再发放token
+	enabled, err := mysql.TOTPEnabled(user.UserID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if enabled {
+		required, err := newTwoFactorChallenge(user.UserID, lifetime)
+		if err != nil {
+			return 0, nil, err
+		}
+		return user.UserID, nil, required
+	}
+	token, err = issueToken(user.UserID, user.Username, lifetime, client)
+	return user.UserID, token, err
+}
+
+// RefreshToken 校验refresh token并在它的会话中轮换，返回新的token对
+func RefreshToken(p *models.ParamRefreshToken, client *models.ClientInfo) (token *models.Token, err error) {
+	claims, err := jwt.ParseRefreshToken(p.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	// 没有会话之前签发的token，校验通过后换成一个新的会话
+	if claims.SessionID == "" {
+		lifetime, err := redis.ConsumeLegacyRefreshToken(claims.UserID, p.RefreshToken)
+		if err != nil {
+			return nil, err
+		}
+		if lifetime <= 0 {
+			lifetime = jwt.RefreshTokenExpire()
+		}
+		return issueToken(claims.UserID, claims.Username, lifetime, client)
+	}
+	// 沿用登录时选择的有效期
+	lifetime, err := redis.GetSessionLifetime(claims.UserID, claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	aToken, rToken, err := jwt.GenSessionToken(claims.UserID, claims.Username, claims.SessionID, lifetime)
+	if err != nil {
+		return nil, err
+	}
+	if err := redis.RotateSessionToken(claims.UserID, claims.SessionID, p.RefreshToken, rToken, client); err != nil {
+		return nil, err
+	}
+	return &models.Token{AccessToken: aToken, RefreshToken: rToken}, nil
+}
+
+// Logout 退出当前会话，sessionID为空时（没有会话之前签发的token）退出所有会话
+func Logout(userID int64, sessionID string) error {
+	if sessionID == "" {
+		return redis.DeleteSessions(userID)
+	}
+	_, err := redis.DeleteSession(userID, sessionID)
+	return err
+}
+
+// issueToken 登录成功后创建一个新的会话，会话数超过auth.max_sessions时最早过期的会话被退出
+func issueToken(userID int64, username string, lifetime time.Duration, client *models.ClientInfo) (*models.Token, error) {
+	sessionID, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+	aToken, rToken, err := jwt.GenSessionToken(userID, username, sessionID, lifetime)
+	if err != nil {
+		return nil, err
+	}
+	var max int
+	if cfg := settings.Current().AuthConfig; cfg != nil {
+		max = cfg.MaxSessions
+	}
+	if err := redis.CreateSession(userID, sessionID, rToken, lifetime, client, max); err != nil {
+		return nil, err
+	}
+	return &models.Token{AccessToken: aToken, RefreshToken: rToken}, nil
+}
+
+// ForgotPassword 生成一次性的密码重置token并发送邮件
+// 邮箱不存在时同样返回nil，避免泄露哪些邮箱已经注册
+func ForgotPassword(p *models.ParamForgotPassword) error {
+	user, err := mysql.GetUserByEmail(p.Email)
+	if errors.Is(err, mysql.ErrorUserNotExist) {
+		zap.L().Info("password reset requested for unknown email")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	token, err := randomToken()
+	if err != nil {
+		return err
+	}
+	if err := redis.SetPasswordResetToken(token, user.UserID, passwordResetTokenExpire); err != nil {
+		return err
+	}
+	link := email.Link("/password/reset", url.Values{"token": {token}})
+	body := "Hi " + user.Username + ",\n\n" +
+		"Use the link below to reset your password. It expires in 15 minutes.\n\n" + link + "\n"
+	// 放入队列异步发送，响应时间不随邮箱是否存在而变化
+	queueEmail(user.Email, "Reset your password", body)
+	return nil
+}
+
+// ResetPassword 校验重置token并更新密码，成功后token失效，返回token对应的用户
+func ResetPassword(p *models.ParamResetPassword) (userID int64, err error) {
+	userID, err = redis.ConsumePasswordResetToken(p.Token)
+	if err != nil {
+		return 0, err
+	}
+	if err := mysql.UpdatePassword(userID, p.Password); err != nil {
+		return 0, err
+	}
+	// 修改密码后让已登录的会话重新登录
+	return userID, redis.DeleteSessions(userID)
+}
+
+// VerifyEmail 校验邮箱验证token并将账号标记为已验证
+func VerifyEmail(token string) error {
+	userID, err := redis.ConsumeVerifyEmailToken(token)
+	if err != nil {
+		return err
+	}
+	return mysql.SetEmailVerified(userID)
+}
+
+// ResendVerification 重发验证邮件，同一邮箱有发送频率限制
+// 邮箱不存在或已验证时同样返回nil
+func ResendVerification(p *models.ParamResendVerification) error {
+	ok, err := redis.AllowVerifyResend(p.Email, verifyResendInterval)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrorVerifyResendTooFrequent
+	}
+	user, err := mysql.GetUserByEmail(p.Email)
+	if errors.Is(err, mysql.ErrorUserNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return nil
+	}
+	return sendVerifyEmail(user)
+}
+
+func sendVerifyEmail(user *models.User) error {
+	token, err := randomToken()
+	if err != nil {
+		return err
+	}
+	if err := redis.SetVerifyEmailToken(token, user.UserID, verifyEmailTokenExpire); err != nil {
+		return err
+	}
+	link := email.Link("/api/v1/verify", url.Values{"token": {token}})
+	body := "Hi " + user.Username + ",\n\n" +
+		"Welcome to the Rutgers student community! Please verify your email address:\n\n" + link + "\n"
+	queueEmail(user.Email, "Verify your email", body)
+	return nil
+}
+
+// Deactivate 注销账号，保留用户的内容，作废所有登录状态
+func Deactivate(userID int64) error {
+	if err := mysql.SetDeactivated(userID, true); err != nil {
+		return err
+	}
+	if err := redis.SetDeactivated(userID, true); err != nil {
+		return err
+	}
+	// 帖子详情中的作者名改为占位的名字
+	invalidateAuthorPosts(userID)
+	// 排行榜中已注销的用户不显示用户名
+	BustResponseCache(CacheLeaderboard)
+	return redis.DeleteSessions(userID)
+}
+
+// RequestReactivation 给已注销账号的邮箱发送恢复链接
+// 邮箱不存在或账号未注销时同样返回nil
+func RequestReactivation(p *models.ParamReactivate) error {
+	user, err := mysql.GetUserByEmail(p.Email)
+	if errors.Is(err, mysql.ErrorUserNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !user.Deactivated {
+		return nil
+	}
+	token, err := randomToken()
+	if err != nil {
+		return err
+	}
+	if err := redis.SetReactivateToken(token, user.UserID, reactivateTokenExpire); err != nil {
+		return err
+	}
+	link := email.Link("/account/reactivate", url.Values{"token": {token}})
+	body := "Hi " + user.Username + ",\n\n" +
+		"Use the link below to reactivate your account. It expires in 1 hour.\n\n" + link + "\n"
+	queueEmail(user.Email, "Reactivate your account", body)
+	return nil
+}
+
+// ConfirmReactivation 校验恢复token并恢复账号，之后需要重新登录
+func ConfirmReactivation(p *models.ParamConfirmReactivate) error {
+	userID, err := redis.ConsumeReactivateToken(p.Token)
+	if err != nil {
+		return err
+	}
+	if err := mysql.SetDeactivated(userID, false); err != nil {
+		return err
+	}
+	if err := redis.SetDeactivated(userID, false); err != nil {
+		return err
+	}
+	invalidateAuthorPosts(userID)
+	return nil
+}
+
+// displayName 已注销用户的内容使用占位的作者名
+func displayName(user *models.User) string {
+	if user.Deactivated {
+		return models.DeactivatedUserName
+	}
+	return user.Username
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
 		return "", err
 	}
-	return jwt.GenToken(user.UserID, user.Username)
+	return hex.EncodeToString(b), nil
+}
+
+// IsAdmin 判断用户是否为管理员
+func IsAdmin(userID int64) (bool, error) {
+	role, err := mysql.GetUserRole(userID)
+	if err != nil {
+		return false, err
+	}
+	return role == models.RoleAdmin, nil
+}
+
+// UpdateAvatar 处理上传的头像并保存到本地，返回头像的访问地址
+func UpdateAvatar(userID int64, data []byte) (string, error) {
+	cfg := settings.Current().AvatarConfig
+	out, ext, err := avatar.Process(data, cfg.Size)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return "", err
+	}
+	// 每次上传使用新的文件名，避免客户端缓存旧头像
+	name := strconv.FormatInt(userID, 10) + "_" + strconv.FormatInt(snowflake.GenID(), 10) + ext
+	if err := ioutil.WriteFile(filepath.Join(cfg.Dir, name), out, 0644); err != nil {
+		return "", err
+	}
+	avatarURL := path.Join(cfg.URLPrefix, name)
+	if err := mysql.UpdateAvatar(userID, avatarURL); err != nil {
+		return "", err
+	}
+	invalidateAuthorProfile(userID)
+	return avatarURL, nil
+}
+
+// ErrorTooManyIDs 批量查询的id超过pagination.max_batch_ids
+var ErrorTooManyIDs = errors.New("Too many ids in one request. ")
+
+// GetUserMiniProfiles 批量查询用户的公开信息，按ids的顺序返回，重复的id只返回一次，不存在和已注销的用户不返回
+// 和评论列表共用作者信息的缓存
+func GetUserMiniProfiles(ctx context.Context, ids []int64) ([]*models.AuthorProfile, error) {
+	if max := settings.Current().PaginationConfig.MaxBatchIDs; max > 0 && len(ids) > max {
+		return nil, ErrorTooManyIDs
+	}
+	// uniqueIDs会排序，这里需要保留原来的顺序
+	seen := make(map[int64]bool, len(ids))
+	ordered := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			ordered = append(ordered, id)
+		}
+	}
+	profiles, err := getAuthorProfiles(ctx, ordered)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*models.AuthorProfile, 0, len(ordered))
+	for _, id := range ordered {
+		profile, ok := profiles[id]
+		// 已注销的用户在缓存中只保留id和占位的用户名
+		if !ok || profile.Deactivated || profile.Username == models.DeactivatedUserName {
+			continue
+		}
+		list = append(list, profile)
+	}
+	return list, nil
 }
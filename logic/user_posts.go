@@ -0,0 +1,68 @@
+package logic
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+
+	"go.uber.org/zap"
+)
+
+// GetUserPosts 用户在所有社区发布的帖子，不包含草稿和已删除的帖子
+// viewerID屏蔽了作者时返回空列表，私有社区的帖子只有同是成员时可见
+// 作者本人查看并且includeDrafts为true时，第一页的最前面是自己的草稿，草稿不计入总数
+func GetUserPosts(ctx context.Context, viewerID, uid int64, p *models.ParamUserPosts, page, size int64) ([]*models.PostDetail, int64, error) {
+	author, err := mysql.GetUserByID(uid)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, 0, mysql.ErrorUserNotExist
+		}
+		return nil, 0, err
+	}
+	if viewerID != uid {
+		blocked, err := IsBlocked(viewerID, author.UserID)
+		if err != nil {
+			return nil, 0, err
+		}
+		if blocked {
+			return []*models.PostDetail{}, 0, nil
+		}
+	}
+	order := p.Order
+	if order == "" {
+		order = models.OrderTime
+	}
+	ids, total, err := redis.GetUserPostIDsInOrder(uid, order, page, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := getPostDetailListByIDs(ctx, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	if data, err = filterVisiblePosts(viewerID, data); err != nil {
+		return nil, 0, err
+	}
+	if viewerID == uid && p.IncludeDrafts && page == 1 {
+		drafts, err := GetDrafts(uid)
+		if err != nil {
+			return nil, 0, err
+		}
+		list, err := getPostDetailList(ctx, drafts)
+		if err != nil {
+			return nil, 0, err
+		}
+		data = append(list, data...)
+	}
+	return data, total, nil
+}
+
+// removeUserPost 作者的帖子列表中去掉已删除的帖子，失败时只影响总数，读取列表时已删除的帖子仍会被过滤
+func removeUserPost(post *models.Post) {
+	if err := redis.RemoveUserPost(post.AuthorId, post.PostID); err != nil {
+		zap.L().Error("redis.RemoveUserPost failed", zap.Int64("pid", post.PostID), zap.Error(err))
+	}
+}
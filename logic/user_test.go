@@ -0,0 +1,15 @@
+package logic
+
+import (
+	"go-web-app/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckLoginAllowed(t *testing.T) {
+	assert.NoError(t, checkLoginAllowed(&models.User{EmailVerified: true}))
+	assert.ErrorIs(t, checkLoginAllowed(&models.User{EmailVerified: true, Banned: true}), ErrorAccountBanned)
+	assert.ErrorIs(t, checkLoginAllowed(&models.User{EmailVerified: true, Deactivated: true}), ErrorAccountDeactivated)
+	assert.ErrorIs(t, checkLoginAllowed(&models.User{}), ErrorEmailNotVerified)
+}
@@ -0,0 +1,75 @@
+package logic
+
+import (
+	"errors"
+	"go-web-app/dao/mysql"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"time"
+)
+
+var ErrorUsernameChangeTooFrequent = errors.New("Username was changed recently. ")
+
+// ChangeUsername 修改当前用户的用户名，旧用户名写入历史记录
+// 在保留期内被其他用户改掉的用户名视为已被使用，防止冒充
+func ChangeUsername(userID int64, p *models.ParamChangeUsername) error {
+	user, err := mysql.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.Username == p.Username {
+		return nil
+	}
+	if cooldown := settings.Current().UsernameConfig.ChangeCooldown; cooldown > 0 {
+		last, ok, err := mysql.GetLastUsernameChange(userID)
+		if err != nil {
+			return err
+		}
+		if ok && time.Since(last) < time.Duration(cooldown)*24*time.Hour {
+			return ErrorUsernameChangeTooFrequent
+		}
+	}
+	if err = checkUsernameAvailable(userID, p.Username); err != nil {
+		return err
+	}
+	if err = mysql.ChangeUsername(userID, user.Username, p.Username); err != nil {
+		return err
+	}
+	invalidateAuthorProfile(userID)
+	invalidateAuthorPosts(userID)
+	return nil
+}
+
+// checkUsernameAvailable 用户名没有被其他用户使用，也不在其他用户改名后的保留期内，
+// 否则返回mysql.ErrorUserExist；注册时userID为0
+func checkUsernameAvailable(userID int64, username string) error {
+	if err := mysql.CheckUsernameAvailable(userID, username); err != nil {
+		return err
+	}
+	days := settings.Current().UsernameConfig.ReserveDays
+	if days <= 0 {
+		return nil
+	}
+	since := time.Now().Add(-time.Duration(days) * 24 * time.Hour)
+	reserved, err := mysql.IsUsernameReserved(userID, username, since)
+	if err != nil {
+		return err
+	}
+	if reserved {
+		return mysql.ErrorUserExist
+	}
+	return nil
+}
+
+// GetUserProfileByUsername 按用户名查看主页，用户名已被改掉时返回改名之后的用户，
+// 返回的主页中是当前的用户名，前端据此跳转到新的链接
+func GetUserProfileByUsername(viewerID int64, username string) (*models.UserProfile, error) {
+	uid, err := mysql.GetUserIDByUsername(username)
+	if errors.Is(err, mysql.ErrorUserNotExist) {
+		uid, err = mysql.GetUserIDByOldUsername(username)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return GetUserProfile(viewerID, uid)
+}
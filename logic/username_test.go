@@ -0,0 +1,35 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeUsernameInvalidatesPostDetail(t *testing.T) {
+	useMySQL(t)
+	useMiniredis(t)
+	oldPost, oldName := settings.Conf.PostConfig, settings.Conf.UsernameConfig
+	settings.Conf.PostConfig = &settings.PostConfig{DetailCacheTTL: 60}
+	settings.Conf.UsernameConfig = &settings.UsernameConfig{}
+	t.Cleanup(func() { settings.Conf.PostConfig, settings.Conf.UsernameConfig = oldPost, oldName })
+	author := createTestUser(t)
+	post := createTestPost(t, author.UserID, createTestCommunity(t, models.CommunityVisibilityPublic))
+	require.NoError(t, redis.CreatePost(post.PostID, []int64{post.CommunityID}, author.UserID, nil))
+
+	data, err := GetPostById(context.Background(), post.PostID)
+	require.NoError(t, err)
+	assert.Equal(t, author.Username, data.AuthorName)
+
+	// 改名之后帖子详情的缓存被删除，重新加载时是新的用户名
+	name := author.Username + "_new"
+	require.NoError(t, ChangeUsername(author.UserID, &models.ParamChangeUsername{Username: name}))
+	data, err = GetPostById(context.Background(), post.PostID)
+	require.NoError(t, err)
+	assert.Equal(t, name, data.AuthorName)
+}
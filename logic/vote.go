@@ -1,9 +1,15 @@
 package logic
 
 import (
+	"context"
+	"go-web-app/dao/mongodb"
+	"go-web-app/dao/mysql"
 	"go-web-app/dao/redis"
 	"go-web-app/models"
+	"go-web-app/pkg/snowflake"
+	"go-web-app/settings"
 	"strconv"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -24,10 +30,136 @@ Limitations:
 1. After one week the post posted, make user unable to vote anymore
 2. After one week the post posted, delete KeyPostVotedZSetPF
 */
-func VoteForPost(userID int64, p *models.ParamVoteData) error {
+func VoteForPost(ctx context.Context, userID int64, p *models.ParamVoteData) error {
 	zap.L().Debug("VoteForPost",
 		zap.Int64("userID", userID),
 		zap.String("postID", p.PostId),
 		zap.Int8("direction", p.Direction))
-	return redis.VoteForPost(strconv.Itoa(int(userID)), p.PostId, float64(p.Direction))
+	pid, err := strconv.ParseInt(p.PostId, 10, 64)
+	if err != nil {
+		return mysql.ErrorInvalidID
+	}
+	// 私有社区的帖子只有成员可以投票
+	if err = CheckPostIDAccess(ctx, userID, pid); err != nil {
+		return err
+	}
+	if p.Direction == -1 {
+		if err := CheckAccountAge(userID, AccountActionDownvote); err != nil {
+			return err
+		}
+	}
+	cfg := settings.Current().PostConfig
+	voteWindow := time.Duration(cfg.VoteWindow) * time.Hour
+	oldValue, oldWeight, err := redis.VoteForPost(strconv.Itoa(int(userID)), p.PostId, float64(p.Direction), postVoteWeight(userID), voteWindow)
+	if err != nil {
+		return err
+	}
+	if err := redis.UpdatePostHotScore(p.PostId, cfg.HotGravity); err != nil {
+		return err
+	}
+	if err := redis.UpdatePostControversyScore(p.PostId, settings.Current().PostConfig.ControversialMinVotes); err != nil {
+		return err
+	}
+	if p.Direction != 0 {
+		recordVoteActivity(p.PostId)
+	}
+	invalidatePostDetail(pid)
+	post, err := mysql.GetPostById(pid)
+	if err != nil {
+		zap.L().Warn("mysql.GetPostById failed", zap.Int64("pid", pid), zap.Error(err))
+		return nil
+	}
+	weight := settings.Current().KarmaConfig.PostVoteWeight
+	if checkBrigading(postTarget(pid), userID, p.Direction, func() *models.Report {
+		return &models.Report{PostID: pid, CommunityID: post.CommunityID}
+	}) {
+		weight = 0
+	}
+	updateKarma(listedAuthor(post), userID, weight, oldValue, float64(p.Direction))
+	saveVoteUndo(postTarget(pid), userID, &models.VoteUndo{
+		OldValue:    oldValue,
+		OldWeight:   oldWeight,
+		NewValue:    float64(p.Direction),
+		KarmaWeight: weight,
+	})
+	if p.Direction == 1 {
+		// 同一个人对同一个帖子只通知一次
+		notify(post.AuthorId, userID, models.NotificationVote, pid, true)
+	}
+	return nil
+}
+
+// postVoteWeight 用户的票计入帖子排序分数的权重，没有开启或读取声望失败时按1计算
+func postVoteWeight(userID int64) float64 {
+	cfg := settings.Current().VoteWeightConfig
+	if cfg == nil || !cfg.Enable {
+		return 1
+	}
+	karma, err := GetKarma(userID)
+	if err != nil {
+		zap.L().Warn("GetKarma failed, vote counts with full weight", zap.Int64("userID", userID), zap.Error(err))
+		return 1
+	}
+	return redis.VoteWeight(karma, time.Since(snowflake.Time(userID)), cfg.MinWeight, cfg.FullKarma,
+		time.Duration(cfg.NewAccountAge)*time.Hour)
+}
+
+// VoteForComment 给评论投票，评论不计算热度，只影响作者的声望
+func VoteForComment(ctx context.Context, userID, cid int64, p *models.ParamCommentVote) error {
+	if p.Direction == -1 {
+		if err := CheckAccountAge(userID, AccountActionDownvote); err != nil {
+			return err
+		}
+	}
+	comment, err := mongodb.GetCommentByID(cid)
+	if err != nil {
+		return err
+	}
+	// 和帖子一样，私有社区的评论只有成员可以投票
+	if err = CheckPostIDAccess(ctx, userID, comment.PostID); err != nil {
+		return err
+	}
+	oldValue, err := redis.VoteForComment(strconv.FormatInt(userID, 10), strconv.FormatInt(cid, 10), float64(p.Direction))
+	if err != nil {
+		return err
+	}
+	weight := settings.Current().KarmaConfig.CommentVoteWeight
+	if checkBrigading(commentTarget(cid), userID, p.Direction, func() *models.Report {
+		post, err := mysql.GetPostById(comment.PostID)
+		if err != nil {
+			zap.L().Warn("mysql.GetPostById failed", zap.Int64("pid", comment.PostID), zap.Error(err))
+			return nil
+		}
+		return &models.Report{PostID: comment.PostID, CommentID: cid, CommunityID: post.CommunityID}
+	}) {
+		weight = 0
+	}
+	updateKarma(comment.AuthorID, userID, weight, oldValue, float64(p.Direction))
+	saveVoteUndo(commentTarget(cid), userID, &models.VoteUndo{
+		OldValue:    oldValue,
+		NewValue:    float64(p.Direction),
+		KarmaWeight: weight,
+	})
+	return nil
+}
+
+// FillUserVotes 补充userID对每个帖子的投票，读取失败时只记录日志，不影响列表的返回
+// 投票通过请求的加载器读取，同一个请求中已经读取过的帖子不再查询
+func FillUserVotes(ctx context.Context, userID int64, posts []*models.PostDetail) {
+	if len(posts) == 0 {
+		return
+	}
+	pids := make([]int64, 0, len(posts))
+	for _, p := range posts {
+		pids = append(pids, p.PostID)
+	}
+	votes, err := requestLoader(ctx).PostVotes(ctx, userID, pids)
+	if err != nil {
+		zap.L().Error("load user post votes failed", zap.Int64("userID", userID), zap.Error(err))
+		return
+	}
+	for i, p := range posts {
+		v := votes[i]
+		p.UserVote = &v
+	}
 }
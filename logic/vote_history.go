@@ -0,0 +1,53 @@
+package logic
+
+import (
+	"context"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// GetUserVotedPosts 用户自己赞成或反对过的帖子，最近投票的在前，dir为up或down，已删除的帖子不在结果中
+func GetUserVotedPosts(ctx context.Context, userID int64, dir string, page, size int64) ([]*models.PostDetail, int64, error) {
+	ids, total, err := redis.GetUserVotedPosts(userID, dir, page, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	// 只保留当前的投票方向和记录一致的帖子
+	votes, err := redis.GetUserPostVotes(strconv.FormatInt(userID, 10), ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	want := int8(1)
+	if dir == models.VoteDirDown {
+		want = -1
+	}
+	current := ids[:0]
+	for i, id := range ids {
+		if votes[i] == want {
+			current = append(current, id)
+		}
+	}
+	data, err := getPostDetailListByIDs(ctx, current)
+	if err != nil {
+		return nil, 0, err
+	}
+	if data, err = filterVisiblePosts(userID, data); err != nil {
+		return nil, 0, err
+	}
+	return data, total, nil
+}
+
+// BackfillUserVotes 启动时按帖子的投票记录补充用户的投票记录，只在第一次启动时执行，失败时只记录日志
+func BackfillUserVotes() {
+	done, err := redis.BackfillUserVotes()
+	if err != nil {
+		zap.L().Error("redis.BackfillUserVotes failed", zap.Error(err))
+		return
+	}
+	if done {
+		zap.L().Info("user vote history backfilled")
+	}
+}
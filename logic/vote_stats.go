@@ -0,0 +1,99 @@
+package logic
+
+import (
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/pkg/snowflake"
+	"strconv"
+	"time"
+)
+
+// voteAgeBuckets 按注册时间分组的上限，最后一组没有上限
+var voteAgeBuckets = []struct {
+	label string
+	max   time.Duration
+}{
+	{"<1d", 24 * time.Hour},
+	{"1d-7d", 7 * 24 * time.Hour},
+	{"7d-30d", 30 * 24 * time.Hour},
+	{"30d-1y", 365 * 24 * time.Hour},
+	{">=1y", 0},
+}
+
+// voteKarmaBuckets 按声望分组的上限(不包含)，最后一组没有上限
+var voteKarmaBuckets = []struct {
+	label string
+	max   int64
+}{
+	{"<0", 0},
+	{"0-9", 10},
+	{"10-99", 100},
+	{"100-999", 1000},
+	{">=1000", 0},
+}
+
+// GetPostVoteStats 帖子所在社区的版主查看投票的汇总，按投票者的注册时间和声望分组，不返回投票者
+func GetPostVoteStats(userID, pid int64) (*models.PostVoteStats, error) {
+	post, err := mysql.GetPostById(pid)
+	if err != nil {
+		return nil, err
+	}
+	role, err := GetCommunityRole(userID, post.CommunityID)
+	if err != nil {
+		return nil, err
+	}
+	if role < models.CommunityRoleModerator {
+		return nil, ErrorNoPermission
+	}
+	up, down, err := redis.GetPostVoters(strconv.FormatInt(pid, 10))
+	if err != nil {
+		return nil, err
+	}
+	karma, err := redis.GetKarmas(append(append([]int64{}, up...), down...))
+	if err != nil {
+		return nil, err
+	}
+	stats := &models.PostVoteStats{
+		PostID:       pid,
+		Up:           int64(len(up)),
+		Down:         int64(len(down)),
+		ByAccountAge: make([]*models.VoteBucket, len(voteAgeBuckets)),
+		ByKarma:      make([]*models.VoteBucket, len(voteKarmaBuckets)),
+	}
+	for i, b := range voteAgeBuckets {
+		stats.ByAccountAge[i] = &models.VoteBucket{Label: b.label}
+	}
+	for i, b := range voteKarmaBuckets {
+		stats.ByKarma[i] = &models.VoteBucket{Label: b.label}
+	}
+	count := func(uid int64, inc func(b *models.VoteBucket)) {
+		inc(stats.ByAccountAge[voteAgeBucket(time.Since(snowflake.Time(uid)))])
+		inc(stats.ByKarma[voteKarmaBucket(karma[uid])])
+	}
+	for _, uid := range up {
+		count(uid, func(b *models.VoteBucket) { b.Up++ })
+	}
+	for _, uid := range down {
+		count(uid, func(b *models.VoteBucket) { b.Down++ })
+	}
+	return stats, nil
+}
+
+func voteAgeBucket(age time.Duration) int {
+	for i, b := range voteAgeBuckets[:len(voteAgeBuckets)-1] {
+		if age < b.max {
+			return i
+		}
+	}
+	return len(voteAgeBuckets) - 1
+}
+
+func voteKarmaBucket(karma int64) int {
+	for i, b := range voteKarmaBuckets[:len(voteKarmaBuckets)-1] {
+		if karma < b.max {
+			return i
+		}
+	}
+	return len(voteKarmaBuckets) - 1
+}
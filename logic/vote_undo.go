@@ -0,0 +1,92 @@
+package logic
+
+import (
+	"go-web-app/dao/mongodb"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/settings"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// VoteUndoWindow 投票后可以撤销的时间，为0时不能撤销
+func VoteUndoWindow() time.Duration {
+	cfg := settings.Current().VoteConfig
+	if cfg == nil || cfg.UndoWindow <= 0 {
+		return 0
+	}
+	return time.Duration(cfg.UndoWindow) * time.Second
+}
+
+// saveVoteUndo 记录投票之前的状态，失败时只记录日志，这一票不能撤销
+func saveVoteUndo(target string, userID int64, undo *models.VoteUndo) {
+	window := VoteUndoWindow()
+	if window <= 0 {
+		return
+	}
+	if err := redis.SetVoteUndo(target, userID, undo, window); err != nil {
+		zap.L().Error("redis.SetVoteUndo failed", zap.String("target", target), zap.Int64("userID", userID), zap.Error(err))
+	}
+}
+
+// UndoPostVote 撤销窗口内恢复之前的投票和分数，按原来的权重恢复，不计入标签热度和刷票检测
+// 窗口已经结束或者这一票已经撤销过时返回redis.ErrVoteUndoExpired
+func UndoPostVote(userID int64, p *models.ParamUndoVote) error {
+	pid, err := strconv.ParseInt(p.PostId, 10, 64)
+	if err != nil {
+		return redis.ErrVoteUndoExpired
+	}
+	undo, err := redis.TakeVoteUndo(postTarget(pid), userID)
+	if err != nil {
+		return err
+	}
+	cfg := settings.Current().PostConfig
+	voteWindow := time.Duration(cfg.VoteWindow) * time.Hour
+	current, _, err := redis.VoteForPost(strconv.FormatInt(userID, 10), p.PostId, undo.OldValue, undo.OldWeight, voteWindow)
+	if err == redis.ErrVoteRepeated {
+		// 撤销之前已经改回了原来的投票
+		return redis.ErrVoteUndoExpired
+	}
+	if err != nil {
+		return err
+	}
+	if err := redis.UpdatePostHotScore(p.PostId, cfg.HotGravity); err != nil {
+		return err
+	}
+	if err := redis.UpdatePostControversyScore(p.PostId, cfg.ControversialMinVotes); err != nil {
+		return err
+	}
+	invalidatePostDetail(pid)
+	post, err := mysql.GetPostById(pid)
+	if err != nil {
+		zap.L().Warn("mysql.GetPostById failed", zap.Int64("pid", pid), zap.Error(err))
+		return nil
+	}
+	updateKarma(listedAuthor(post), userID, undo.KarmaWeight, current, undo.OldValue)
+	return nil
+}
+
+// UndoCommentVote 撤销窗口内恢复之前对评论的投票
+func UndoCommentVote(userID, cid int64) error {
+	undo, err := redis.TakeVoteUndo(commentTarget(cid), userID)
+	if err != nil {
+		return err
+	}
+	current, err := redis.VoteForComment(strconv.FormatInt(userID, 10), strconv.FormatInt(cid, 10), undo.OldValue)
+	if err == redis.ErrVoteRepeated {
+		return redis.ErrVoteUndoExpired
+	}
+	if err != nil {
+		return err
+	}
+	comment, err := mongodb.GetCommentByID(cid)
+	if err != nil {
+		zap.L().Warn("mongodb.GetCommentByID failed", zap.Int64("cid", cid), zap.Error(err))
+		return nil
+	}
+	updateKarma(comment.AuthorID, userID, undo.KarmaWeight, current, undo.OldValue)
+	return nil
+}
@@ -0,0 +1,280 @@
+package logic
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"go-web-app/models"
+	"go-web-app/pkg/email"
+	"go-web-app/pkg/opengraph"
+	"go-web-app/pkg/snowflake"
+	"go-web-app/pkg/webhook"
+	"go-web-app/settings"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrorTooManyWebhooks   = errors.New("Too many webhooks in this community. ")
+	ErrorInvalidWebhookURL = errors.New("Webhook URL must be an http or https address. ")
+	ErrorWebhookNotFound   = errors.New("Webhook does not exist. ")
+)
+
+// webhookBatch 每秒最多发送多少个通知，发送是串行的，超时时间决定了最慢的情况
+const webhookBatch = 20
+
+// GetWebhooks 社区的webhook，不返回密钥
+func GetWebhooks(communityID int64) ([]*models.Webhook, error) {
+	list, err := mysql.GetWebhooks(communityID)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range list {
+		w.HasSecret = w.Secret != ""
+	}
+	return list, nil
+}
+
+// AddWebhook 版主为社区添加webhook，每个社区最多webhook.max_per_community个
+func AddWebhook(communityID, userID int64, p *models.ParamWebhook) (*models.Webhook, error) {
+	if !opengraph.IsValidURL(p.URL) {
+		return nil, ErrorInvalidWebhookURL
+	}
+	limit := settings.Current().WebhookConfig.MaxPerCommunity
+	w := &models.Webhook{
+		WebhookID:   snowflake.GenID(),
+		CommunityID: communityID,
+		URL:         p.URL,
+		Secret:      p.Secret,
+		HasSecret:   p.Secret != "",
+		CreatorID:   userID,
+		CreateTime:  time.Now(),
+	}
+	err := mysql.AddWebhook(w, func(count int64) error {
+		if limit > 0 && count >= int64(limit) {
+			return ErrorTooManyWebhooks
+		}
+		return nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		err = mysql.ErrorInvalidID
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// DeleteWebhook 删除社区的webhook，队列中还没发送的通知在发送时丢弃
+func DeleteWebhook(communityID, id int64) error {
+	deleted, err := mysql.DeleteWebhook(communityID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrorWebhookNotFound
+	}
+	return nil
+}
+
+// TestWebhook 立即发送一个ping事件，不经过队列也不重试，返回接收方的响应状态
+func TestWebhook(ctx context.Context, communityID, id int64) (*models.WebhookTestResult, error) {
+	w, err := getCommunityWebhook(communityID, id)
+	if err != nil {
+		return nil, err
+	}
+	community, err := getCommunityDetail(ctx, communityID)
+	if err != nil {
+		return nil, err
+	}
+	text := fmt.Sprintf("Webhook test from %s", community.Name)
+	body, err := json.Marshal(&models.WebhookEvent{
+		Event:     models.WebhookEventPing,
+		Text:      text,
+		Content:   text,
+		Community: &models.WebhookCommunity{ID: community.ID, Name: community.Name},
+		Time:      time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	status, err := sendWebhook(w, models.WebhookEventPing, strconv.FormatInt(snowflake.GenID(), 10), body)
+	res := &models.WebhookTestResult{OK: err == nil, Status: status}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	return res, nil
+}
+
+func getCommunityWebhook(communityID, id int64) (*models.Webhook, error) {
+	w, err := mysql.GetWebhook(id)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && w.CommunityID != communityID) {
+		return nil, ErrorWebhookNotFound
+	}
+	return w, err
+}
+
+// announcePost 帖子发布后为所在社区的每个webhook加入一个通知，失败时只记录日志，不影响发帖
+func announcePost(ctx context.Context, post *models.Post, excerpt string) {
+	hooks, err := mysql.GetWebhooksByCommunities(post.CommunityIDs)
+	if err != nil {
+		zap.L().Error("mysql.GetWebhooksByCommunities failed", zap.Int64("pid", post.PostID), zap.Error(err))
+		return
+	}
+	if len(hooks) == 0 {
+		return
+	}
+	authorName := ""
+	if post.Anonymous {
+		authorName = models.AnonymousUserName
+	} else if author, err := mysql.GetUserByID(post.AuthorId); err == nil {
+		authorName = displayName(author)
+	}
+	createTime := post.CreateTime
+	if createTime.IsZero() {
+		createTime = time.Now()
+	}
+	summary := &models.WebhookPostSummary{
+		PostID:     post.PostID,
+		Title:      post.Title,
+		Excerpt:    excerpt,
+		Flair:      post.Flair,
+		AuthorName: authorName,
+		URL:        email.Link("/post/"+strconv.FormatInt(post.PostID, 10), nil),
+		CreateTime: createTime,
+	}
+	for _, w := range hooks {
+		community, err := getCommunityDetail(ctx, w.CommunityID)
+		if err != nil {
+			zap.L().Error("getCommunityDetail failed", zap.Int64("community_id", w.CommunityID), zap.Error(err))
+			continue
+		}
+		text := fmt.Sprintf("New post in %s by %s: %s\n%s", community.Name, authorName, post.Title, summary.URL)
+		payload, err := json.Marshal(&models.WebhookEvent{
+			Event:     models.WebhookEventPostCreated,
+			Text:      text,
+			Content:   text,
+			Community: &models.WebhookCommunity{ID: community.ID, Name: community.Name},
+			Post:      summary,
+			Time:      time.Now(),
+		})
+		if err != nil {
+			zap.L().Error("marshal webhook event failed", zap.Int64("pid", post.PostID), zap.Error(err))
+			continue
+		}
+		id, err := snowflake.TryGenID()
+		if err != nil {
+			zap.L().Error("snowflake.TryGenID failed", zap.Int64("webhook_id", w.WebhookID), zap.Error(err))
+			return
+		}
+		job := &models.WebhookJob{
+			ID:         strconv.FormatInt(id, 10),
+			WebhookID:  w.WebhookID,
+			Event:      models.WebhookEventPostCreated,
+			Payload:    payload,
+			CreateTime: time.Now(),
+		}
+		if err = redis.EnqueueWebhook(job); err != nil {
+			zap.L().Error("redis.EnqueueWebhook failed", zap.Int64("webhook_id", w.WebhookID), zap.Error(err))
+		}
+	}
+}
+
+// StartWebhookWorker 在后台每秒发送队列中的webhook通知，返回的函数用于退出时停止
+func StartWebhookWorker() (stop func(ctx context.Context) error) {
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				sendWebhooks(quit)
+			case <-quit:
+				return
+			}
+		}
+	}()
+	return func(ctx context.Context) error {
+		close(quit)
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// sendWebhooks 发送队列中最多webhookBatch个通知，到时间的重试通知先放回队列
+func sendWebhooks(quit <-chan struct{}) {
+	if _, err := redis.PromoteDueWebhooks(time.Now()); err != nil {
+		zap.L().Error("redis.PromoteDueWebhooks failed", zap.Error(err))
+	}
+	for i := 0; i < webhookBatch; i++ {
+		select {
+		case <-quit:
+			return
+		default:
+		}
+		job, err := redis.PopWebhook()
+		if err != nil {
+			zap.L().Error("redis.PopWebhook failed", zap.Error(err))
+			return
+		}
+		if job == nil {
+			return
+		}
+		deliverWebhook(job)
+	}
+}
+
+func deliverWebhook(job *models.WebhookJob) {
+	w, err := mysql.GetWebhook(job.WebhookID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// webhook已经被删除
+		return
+	}
+	if err == nil {
+		_, err = sendWebhook(w, job.Event, job.ID, job.Payload)
+		if err == nil {
+			return
+		}
+	}
+	job.Attempts++
+	job.LastError = err.Error()
+	cfg := settings.Current().WebhookConfig
+	if webhook.IsPermanent(err) || job.Attempts >= cfg.MaxAttempts {
+		zap.L().Warn("webhook dropped to dead letter list",
+			zap.String("id", job.ID), zap.Int64("webhook_id", job.WebhookID), zap.Int("attempts", job.Attempts), zap.Error(err))
+		if err := redis.DeadLetterWebhook(job); err != nil {
+			zap.L().Error("redis.DeadLetterWebhook failed", zap.String("id", job.ID), zap.Error(err))
+		}
+		return
+	}
+	wait := retryBackoff(time.Duration(cfg.RetryBackoff)*time.Second, job.Attempts)
+	zap.L().Info("send webhook failed, will retry",
+		zap.String("id", job.ID), zap.Int64("webhook_id", job.WebhookID), zap.Int("attempts", job.Attempts), zap.Duration("wait", wait), zap.Error(err))
+	if err := redis.RetryWebhook(job, time.Now().Add(wait)); err != nil {
+		zap.L().Error("redis.RetryWebhook failed", zap.String("id", job.ID), zap.Error(err))
+	}
+}
+
+// sendWebhook 按webhook.timeout的超时时间发送一次
+func sendWebhook(w *models.Webhook, event, id string, body []byte) (int, error) {
+	timeout := time.Duration(settings.Current().WebhookConfig.Timeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return webhook.Send(ctx, w.URL, w.Secret, event, id, body)
+}
@@ -2,13 +2,20 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"go-web-app/controller"
 	"go-web-app/dao/mongodb"
 	"go-web-app/dao/mysql"
 	"go-web-app/dao/redis"
 	"go-web-app/logger"
+	"go-web-app/logic"
+	"go-web-app/middlewares"
+	"go-web-app/pkg/email"
+	"go-web-app/pkg/lifecycle"
+	"go-web-app/pkg/retry"
 	"go-web-app/pkg/snowflake"
+	"go-web-app/pkg/tracing"
 	"go-web-app/routes"
 	"go-web-app/settings"
 	"log"
@@ -21,55 +28,164 @@ import (
 	"go.uber.org/zap"
 )
 
+// checkConfig 只检查配置文件，不连接依赖也不启动服务，用于部署之前检查配置
+var checkConfig = flag.Bool("check-config", false, "validate conf/config.yaml and exit")
+
 func main() {
+	flag.Parse()
 	// 1. load config files
 	if err := settings.Init(); err != nil {
 		fmt.Printf("Init settings failed, err:%v\n", err)
 		return
 	}
+	// 在初始化任何依赖之前检查配置，所有的问题一起列出来
+	if err := settings.Validate(settings.Current()); err != nil {
+		fmt.Printf("Invalid config, err:%v\n", err)
+		os.Exit(1)
+	}
+	if *checkConfig {
+		fmt.Println("config ok")
+		return
+	}
 	// 2. init uber/zap logger
-	if err := logger.Init(settings.Conf.LogConfig, settings.Conf.Mode); err != nil {
+	if err := logger.Init(settings.Current().LogConfig, settings.Current().Mode); err != nil {
 		fmt.Printf("Init logger failed, err:%v\n", err)
 		return
 	}
 	defer zap.L().Sync()
 	zap.L().Debug("logger init success")
+	// 关闭操作按初始化的逆序执行：HTTP、MongoDB、Redis、MySQL
+	lm := lifecycle.New()
+	shutdownTracing, err := tracing.Init(settings.Current().TracingConfig, settings.Current().Name, settings.Current().Version)
+	if err != nil {
+		fmt.Printf("Init tracing failed, err:%v\n", err)
+		return
+	}
+	// 最后关闭，把关闭过程中产生的span也上报出去
+	lm.OnShutdown("tracing", shutdownTracing)
 	// 3. init mysql
-	if err := mysql.Init(settings.Conf.MySQLConfig); err != nil {
+	// 容器中依赖的服务可能比应用启动得晚，连接失败时按startup中的配置重试
+	startup := settings.Current().StartupConfig
+	policy := retry.Policy{
+		Attempts:   startup.RetryAttempts,
+		Backoff:    time.Duration(startup.RetryBackoff) * time.Millisecond,
+		MaxBackoff: time.Duration(startup.RetryMaxBackoff) * time.Millisecond,
+	}
+	if err := retry.Do("mysql", policy, func() error { return mysql.Init(settings.Current().MySQLConfig) }); err != nil {
 		fmt.Printf("Init mysql failed, err:%v\n", err)
 		return
 	}
-	defer mysql.Close()
+	lm.OnShutdown("mysql", func(context.Context) error {
+		mysql.Close()
+		return nil
+	})
 	// 4. init redis
-	if err := redis.Init(settings.Conf.RedisConfig); err != nil {
+	if err := retry.Do("redis", policy, func() error { return redis.Init(settings.Current().RedisConfig) }); err != nil {
 		fmt.Printf("Init redis failed, err:%v\n", err)
+		shutdown(lm)
 		return
 	}
-	defer redis.Close()
+	lm.OnShutdown("redis", func(context.Context) error {
+		redis.Close()
+		return nil
+	})
+	// 配置中的社区是否存在需要查询数据库
+	if err := logic.CheckCommunityConfig(context.Background()); err != nil {
+		fmt.Printf("Invalid config, err:%v\n", err)
+		shutdown(lm)
+		os.Exit(1)
+	}
+	stopPush := logic.StartNotificationPush()
+	lm.OnShutdown("notification push", func(context.Context) error {
+		stopPush()
+		return nil
+	})
+	stopCommentPush := logic.StartCommentPush()
+	lm.OnShutdown("comment push", func(context.Context) error {
+		stopCommentPush()
+		return nil
+	})
 
 	// 5. init mongodb
-	if err := mongodb.Init(settings.Conf.MongodbConfig); err != nil {
+	if err := retry.Do("mongodb", policy, func() error { return mongodb.Init(settings.Current().MongodbConfig) }); err != nil {
 		fmt.Printf("Init mongodb failed, err:%v\n", err)
+		shutdown(lm)
+		return
+	}
+	lm.OnShutdown("mongodb", func(context.Context) error {
+		return mongodb.Close()
+	})
+	// 审计日志在mongodb关闭之前写完
+	lm.OnShutdown("audit writer", logic.StartAuditWriter())
+
+	// 声望在mysql和redis关闭之前写回
+	stopKarma, err := logic.StartKarmaSync()
+	if err != nil {
+		fmt.Printf("Start karma sync failed, err:%v\n", err)
+		shutdown(lm)
+		return
+	}
+	lm.OnShutdown("karma sync", stopKarma)
+	// 帖子分数同样在mysql和redis关闭之前写回
+	stopScoreSync, err := logic.StartScoreSync()
+	if err != nil {
+		fmt.Printf("Start post score sync failed, err:%v\n", err)
+		shutdown(lm)
 		return
 	}
-	defer mongodb.Close()
+	lm.OnShutdown("post score sync", stopScoreSync)
+	lm.OnShutdown("hot refresh", logic.StartHotRefresh())
+	lm.OnShutdown("schedule publisher", logic.StartSchedulePublisher())
+	lm.OnShutdown("deferred push", logic.StartDeferredPush())
+	lm.OnShutdown("purge job", logic.StartPurgeJob())
+	lm.OnShutdown("export cleanup", logic.StartExportCleanup())
+	logic.BackfillUserVotes()
+	logic.BackfillPostSlugs()
 
 	// 5. init snowflake
-	if err := snowflake.Init(settings.Conf.StartTime, settings.Conf.MachineID); err != nil {
+	// 多实例部署时从redis租用机器id，否则使用配置中的machine_id
+	machineID := settings.Current().MachineID
+	var leaseLost <-chan struct{}
+	if settings.Current().SnowflakeConfig.LeaseMachineID {
+		id, lost, release, err := logic.LeaseMachineID()
+		if err != nil {
+			fmt.Printf("Lease machine id failed, err:%v\n", err)
+			shutdown(lm)
+			return
+		}
+		machineID, leaseLost = id, lost
+		lm.OnShutdown("machine id lease", func(context.Context) error {
+			return release()
+		})
+	}
+	if err := snowflake.Init(settings.Current().StartTime, machineID); err != nil {
 		fmt.Printf("Init snowflake failed, err:%v\n", err)
+		shutdown(lm)
 		return
 	}
+	zap.L().Info("snowflake initialized",
+		zap.Int64("machine_id", machineID),
+		zap.Bool("leased", settings.Current().SnowflakeConfig.LeaseMachineID))
+
+	email.Init(settings.Current().EmailConfig)
+	lm.OnShutdown("email worker", logic.StartEmailWorker())
+	lm.OnShutdown("digest job", logic.StartDigestJob())
+	lm.OnShutdown("webhook worker", logic.StartWebhookWorker())
+	lm.OnShutdown("tag hot notifier", logic.StartTagHotNotifier())
 
-	if err := controller.InitValidator("en"); err != nil {
+	if err := controller.InitValidator(settings.Current().Locale); err != nil {
 		fmt.Printf("init validator trans failed, err:%v\n", err)
+		shutdown(lm)
 		return
 	}
+	controller.InitContentFilter()
+	logic.InitLinkFilter()
 
 	// 6. register routers
-	r := routes.Setup(settings.Conf.Mode)
+	r := routes.Setup(settings.Current().Mode)
 	// 7. setup shutdown gracefully
 	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%d", settings.Conf.Port),
+		Addr:    fmt.Sprintf(":%d", settings.Current().Port),
 		Handler: r,
 	}
 
@@ -79,8 +195,11 @@ func main() {
 			log.Fatalf("listen: %s\n", err)
 		}
 	}()
+	lm.OnShutdown("http", func(ctx context.Context) error {
+		return shutdownHTTP(ctx, srv)
+	})
 
-	// 等待中断信号来优雅地关闭服务器，为关闭服务器操作设置一个5秒的超时
+	// 等待中断信号来优雅地关闭服务器，超时时间见shutdown.timeout
 	// wait for kill signal
 	quit := make(chan os.Signal, 1) // 创建一个接收信号的通道
 	// kill 默认会发送 syscall.SIGTERM 信号
@@ -88,16 +207,48 @@ func main() {
 	// kill -9 发送 syscall.SIGKILL 信号，但是不能被捕获，所以不需要添加它
 	// signal.Notify把收到的 syscall.SIGINT或syscall.SIGTERM 信号转发给quit
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM) // 此处不会阻塞
-	<-quit                                               // 阻塞在此，当接收到上述两种信号时才会往下执行
+	// 阻塞在此，当接收到上述两种信号时才会往下执行
+	// 机器id的租约丢失时其他实例可能在使用同一个id，立即停止生成id并退出
+	select {
+	case <-quit:
+	case <-leaseLost:
+		snowflake.Stop()
+		zap.L().Error("machine id lease lost, shutting down", zap.Int64("machine_id", machineID))
+	}
 	zap.L().Info("Shutdown Server ...")
-	// 创建一个5秒超时的context
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	shutdown(lm)
+	zap.L().Info("Server exiting")
+}
+
+// shutdown 在shutdown.timeout内执行所有关闭操作
+func shutdown(lm *lifecycle.Manager) {
+	timeout := time.Duration(settings.Current().ShutdownConfig.Timeout) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
-	// 5秒内优雅关闭服务（将未处理完的请求处理完再关闭服务），超过5秒就超时退出
-	// shutdown gracefully in 5 seconds
-	if err := srv.Shutdown(ctx); err != nil {
-		zap.L().Fatal("Server Shutdown: ", zap.Error(err))
+	if err := lm.Shutdown(ctx); err != nil {
+		zap.L().Error("shutdown finished with errors", zap.Error(err))
 	}
+}
 
-	zap.L().Info("Server exiting")
+// shutdownHTTP 先通知websocket客户端断开，再等待未处理完的请求
+// 超时时记录被放弃的请求，方便排查是哪些接口处理得太慢
+func shutdownHTTP(ctx context.Context, srv *http.Server) error {
+	// Shutdown不会等待被websocket接管的连接，先给客户端发送关闭帧，等它们主动断开
+	drainTimeout := time.Duration(settings.Current().ShutdownConfig.DrainTimeout) * time.Second
+	drainCtx, cancel := context.WithTimeout(ctx, drainTimeout)
+	if n := logic.DrainNotifications(drainCtx) + logic.DrainPostComments(drainCtx); n > 0 {
+		zap.L().Warn("websocket connections not closed in time", zap.Int("count", n))
+	}
+	cancel()
+	err := srv.Shutdown(ctx)
+	if err == context.DeadlineExceeded {
+		for _, req := range middlewares.InFlightRequests() {
+			zap.L().Warn("request abandoned",
+				zap.String("request_id", req.RequestID),
+				zap.String("method", req.Method),
+				zap.String("path", req.Path),
+				zap.Duration("elapsed", time.Since(req.Start)))
+		}
+	}
+	return err
 }
@@ -2,10 +2,11 @@ package middlewares
 
 import (
 	"go-web-app/controller"
-	"go-web-app/pkg/jwt"
+	"go-web-app/logic"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 // JWTAuthMiddleware 基于JWT的认证中间件
@@ -27,15 +28,41 @@ func JWTAuthMiddleware() func(c *gin.Context) {
 			c.Abort()
 			return
 		}
-		// parts[1]是获取到的tokenString，我们使用之前定义好的解析JWT的函数来解析它
-		mc, err := jwt.ParseToken(parts[1])
+		// parts[1]是获取到的tokenString，解析后还要确认账号和会话仍然有效
+		mc, err := logic.CheckAccessToken(parts[1])
 		if err != nil {
-			controller.ResponseError(c, controller.CodeInvalidToken)
+			controller.ResponseErrorFrom(c, err)
 			c.Abort()
 			return
 		}
 		// 将当前请求的username信息保存到请求的上下文c上
 		c.Set(controller.ContextUserIDKey, mc.UserID)
+		c.Set(controller.ContextSessionIDKey, mc.SessionID)
 		c.Next() // 后续的处理函数可以用过c.Get("ContextUserIDKey")来获取当前请求的用户信息
 	}
 }
+
+// AdminMiddleware 只允许管理员访问，需要放在JWTAuthMiddleware之后
+func AdminMiddleware() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID, err := controller.GetCurrentUserID(c)
+		if err != nil {
+			controller.ResponseError(c, controller.CodeNeedLogin)
+			c.Abort()
+			return
+		}
+		admin, err := logic.IsAdmin(userID)
+		if err != nil {
+			zap.L().Error("logic.IsAdmin failed", zap.Int64("userID", userID), zap.Error(err))
+			controller.ResponseError(c, controller.CodeServerBusy)
+			c.Abort()
+			return
+		}
+		if !admin {
+			controller.ResponseError(c, controller.CodeNoPermission)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
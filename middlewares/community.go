@@ -0,0 +1,45 @@
+package middlewares
+
+import (
+	"go-web-app/controller"
+	"go-web-app/logic"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// getCommunityRole 查询用户在社区中的角色，测试时可以替换
+var getCommunityRole = logic.GetCommunityRole
+
+// CommunityRoleMiddleware 要求当前用户在路由参数:id指定的社区中至少拥有minRole角色，
+// 需要放在JWTAuthMiddleware之后
+func CommunityRoleMiddleware(minRole int8) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userID, err := controller.GetCurrentUserID(c)
+		if err != nil {
+			controller.ResponseError(c, controller.CodeNeedLogin)
+			c.Abort()
+			return
+		}
+		communityID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			controller.ResponseError(c, controller.CodeInvalidParam)
+			c.Abort()
+			return
+		}
+		role, err := getCommunityRole(userID, communityID)
+		if err != nil {
+			zap.L().Error("get community role failed", zap.Int64("userID", userID), zap.Int64("community_id", communityID), zap.Error(err))
+			controller.ResponseError(c, controller.CodeServerBusy)
+			c.Abort()
+			return
+		}
+		if role < minRole {
+			controller.ResponseError(c, controller.CodeNoPermission)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,84 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"go-web-app/controller"
+	"go-web-app/models"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type membership struct {
+	userID, communityID int64
+}
+
+func setupCommunityRoleTest(t *testing.T, roles map[membership]int8) *gin.Engine {
+	old := getCommunityRole
+	getCommunityRole = func(userID, communityID int64) (int8, error) {
+		return roles[membership{userID, communityID}], nil
+	}
+	t.Cleanup(func() { getCommunityRole = old })
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	// 模拟JWTAuthMiddleware，从请求头中取用户id
+	r.Use(func(c *gin.Context) {
+		if id, err := strconv.ParseInt(c.GetHeader("X-Test-User"), 10, 64); err == nil {
+			c.Set(controller.ContextUserIDKey, id)
+		}
+	})
+	ok := func(c *gin.Context) { controller.ResponseSuccess(c, nil) }
+	r.POST("/community/:id/moderators/:uid", CommunityRoleMiddleware(models.CommunityRoleAdmin), ok)
+	r.DELETE("/community/:id/posts/:pid", CommunityRoleMiddleware(models.CommunityRoleModerator), ok)
+	return r
+}
+
+func doRoleRequest(r *gin.Engine, method, url, user string) controller.ResCode {
+	req, _ := http.NewRequest(method, url, nil)
+	if user != "" {
+		req.Header.Set("X-Test-User", user)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var rd controller.ResponseData
+	_ = json.Unmarshal(w.Body.Bytes(), &rd)
+	return rd.Code
+}
+
+func TestCommunityRoleMiddleware(t *testing.T) {
+	r := setupCommunityRoleTest(t, map[membership]int8{
+		{1, 1}: models.CommunityRoleAdmin,
+		{2, 1}: models.CommunityRoleModerator,
+		{3, 1}: models.CommunityRoleMember,
+		{2, 2}: models.CommunityRoleMember,
+	})
+
+	cases := []struct {
+		name   string
+		method string
+		url    string
+		user   string
+		want   controller.ResCode
+	}{
+		{"admin promotes in own community", http.MethodPost, "/community/1/moderators/3", "1", controller.CodeSuccess},
+		{"moderator cannot promote", http.MethodPost, "/community/1/moderators/3", "2", controller.CodeNoPermission},
+		{"admin of another community cannot promote", http.MethodPost, "/community/2/moderators/3", "1", controller.CodeNoPermission},
+		{"moderator removes post in own community", http.MethodDelete, "/community/1/posts/100", "2", controller.CodeSuccess},
+		{"admin can moderate own community", http.MethodDelete, "/community/1/posts/100", "1", controller.CodeSuccess},
+		{"moderator cannot moderate another community", http.MethodDelete, "/community/2/posts/100", "2", controller.CodeNoPermission},
+		{"member cannot moderate", http.MethodDelete, "/community/1/posts/100", "3", controller.CodeNoPermission},
+		{"non member cannot moderate", http.MethodDelete, "/community/1/posts/100", "4", controller.CodeNoPermission},
+		{"invalid community id", http.MethodDelete, "/community/abc/posts/100", "2", controller.CodeInvalidParam},
+		{"not logged in", http.MethodDelete, "/community/1/posts/100", "", controller.CodeNeedLogin},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, doRoleRequest(r, tc.method, tc.url, tc.user))
+		})
+	}
+}
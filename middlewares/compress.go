@@ -0,0 +1,208 @@
+package middlewares
+
+import (
+	"compress/gzip"
+	"go-web-app/settings"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	encodingGzip   = "gzip"
+	encodingBrotli = "br"
+)
+
+var (
+	gzipPool   = sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }}
+	brotliPool = sync.Pool{New: func() interface{} { return brotli.NewWriter(nil) }}
+)
+
+// CompressMiddleware 根据Accept-Encoding压缩响应，支持br和gzip
+// 小于compress.min_size的响应、已经压缩过的内容类型、已经设置了Content-Encoding的响应不会被压缩。
+// 需要放在TimeoutMiddleware之后，压缩后的内容写入超时中间件的缓冲区；websocket和Range请求不处理。
+func CompressMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := settings.Current().CompressConfig
+		if cfg == nil || !cfg.Enable || c.Request.Method == http.MethodHead ||
+			c.IsWebsocket() || c.GetHeader("Range") != "" {
+			c.Next()
+			return
+		}
+		// 是否压缩取决于请求头，缓存需要区分
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"), cfg.Brotli)
+		if encoding == "" {
+			c.Next()
+			return
+		}
+		cw := &compressWriter{ResponseWriter: c.Writer, encoding: encoding, minSize: cfg.MinSize}
+		c.Writer = cw
+		defer func() {
+			cw.close()
+			c.Writer = cw.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+// negotiateEncoding 选择客户端接受的编码，q=0表示不接受
+func negotiateEncoding(header string, allowBrotli bool) string {
+	var gzipOK, brOK bool
+	for _, part := range strings.Split(header, ",") {
+		name, q := part, 1.0
+		if i := strings.IndexByte(part, ';'); i >= 0 {
+			name = part[:i]
+			param := strings.TrimSpace(part[i+1:])
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case encodingGzip, "*":
+			gzipOK = true
+		case encodingBrotli:
+			brOK = true
+		}
+	}
+	if brOK && allowBrotli {
+		return encodingBrotli
+	}
+	if gzipOK {
+		return encodingGzip
+	}
+	return ""
+}
+
+// compressible 文本类的内容才压缩，图片、视频、压缩包等本身已经压缩过
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/javascript", "application/xml", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// compressWriter 先缓冲不超过minSize的内容，超过后根据响应头决定是否压缩
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	minSize  int
+	buf      []byte
+	decided  bool
+	enc      io.WriteCloser
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		if len(w.buf)+len(b) < w.minSize {
+			w.buf = append(w.buf, b...)
+			return len(b), nil
+		}
+		w.buf = append(w.buf, b...)
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if w.enc != nil {
+		return w.enc.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow 在决定是否压缩之前不能把响应头发出去
+func (w *compressWriter) WriteHeaderNow() {}
+
+func (w *compressWriter) Written() bool {
+	return w.decided || len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+// Flush 流式响应不再等待凑够minSize
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		_ = w.decide(len(w.buf) > 0)
+	}
+	if f, ok := w.enc.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide 决定是否压缩并写出缓冲的内容，big表示响应已经达到压缩的大小
+func (w *compressWriter) decide(big bool) error {
+	w.decided = true
+	h := w.Header()
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	status := w.Status()
+	if big && h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type")) &&
+		status != http.StatusNoContent && status != http.StatusNotModified && status != http.StatusPartialContent {
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+		w.enc = w.newEncoder()
+	}
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.enc != nil {
+		_, err := w.enc.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+func (w *compressWriter) newEncoder() io.WriteCloser {
+	if w.encoding == encodingBrotli {
+		bw := brotliPool.Get().(*brotli.Writer)
+		bw.Reset(w.ResponseWriter)
+		return bw
+	}
+	gw := gzipPool.Get().(*gzip.Writer)
+	gw.Reset(w.ResponseWriter)
+	return gw
+}
+
+// close 处理函数返回后写出剩余的内容，没有达到minSize的响应原样写出
+func (w *compressWriter) close() {
+	if !w.decided {
+		_ = w.decide(false)
+	}
+	if w.enc == nil {
+		return
+	}
+	_ = w.enc.Close()
+	switch enc := w.enc.(type) {
+	case *gzip.Writer:
+		gzipPool.Put(enc)
+	case *brotli.Writer:
+		brotliPool.Put(enc)
+	}
+	w.enc = nil
+}
@@ -0,0 +1,90 @@
+package middlewares
+
+import (
+	"bytes"
+	"compress/gzip"
+	"go-web-app/settings"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	assert.Equal(t, "br", negotiateEncoding("gzip, deflate, br", true))
+	assert.Equal(t, "gzip", negotiateEncoding("gzip, deflate, br", false))
+	assert.Equal(t, "gzip", negotiateEncoding("br;q=0, gzip;q=0.5", true))
+	assert.Equal(t, "gzip", negotiateEncoding("*", true))
+	assert.Equal(t, "", negotiateEncoding("gzip;q=0, identity", true))
+	assert.Equal(t, "", negotiateEncoding("", true))
+}
+
+func TestCompressMiddleware(t *testing.T) {
+	old := settings.Conf.CompressConfig
+	settings.Conf.CompressConfig = &settings.CompressConfig{Enable: true, MinSize: 64, Brotli: true}
+	t.Cleanup(func() { settings.Conf.CompressConfig = old })
+
+	big := strings.Repeat("post ", 100)
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	_, _ = gw.Write([]byte(big))
+	_ = gw.Close()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(TimeoutMiddleware(time.Second), CompressMiddleware())
+	r.GET("/big", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"data": big}) })
+	r.GET("/small", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"data": "ok"}) })
+	r.GET("/image", func(c *gin.Context) { c.Data(http.StatusOK, "image/png", []byte(big)) })
+	// 与promhttp一样自己压缩过的响应
+	r.GET("/encoded", func(c *gin.Context) {
+		c.Header("Content-Encoding", "gzip")
+		c.Data(http.StatusOK, "text/plain", gzipped.Bytes())
+	})
+
+	do := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if accept != "" {
+			req.Header.Set("Accept-Encoding", accept)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("/big", "gzip")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	zr, err := gzip.NewReader(w.Body)
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(zr)
+	assert.Contains(t, string(body), big)
+
+	w = do("/big", "gzip, br")
+	assert.Equal(t, "br", w.Header().Get("Content-Encoding"))
+	body, _ = ioutil.ReadAll(brotli.NewReader(w.Body))
+	assert.Contains(t, string(body), big)
+
+	w = do("/big", "")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	assert.Contains(t, w.Body.String(), big)
+
+	w = do("/small", "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, `{"data":"ok"}`, w.Body.String())
+
+	w = do("/image", "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, big, w.Body.String())
+
+	w = do("/encoded", "gzip")
+	assert.Equal(t, []string{"gzip"}, w.Header().Values("Content-Encoding"))
+	assert.Equal(t, gzipped.Bytes(), w.Body.Bytes())
+}
@@ -0,0 +1,62 @@
+package middlewares
+
+import (
+	"go-web-app/settings"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSMiddleware 根据配置处理跨域请求。
+// 不在白名单中的Origin返回403；release模式下忽略"*"，只认白名单中明确列出的Origin。
+func CORSMiddleware() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		cfg := settings.Current().CORSConfig
+		if cfg == nil || !originAllowed(cfg.AllowOrigins, origin, gin.Mode() != gin.ReleaseMode) {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		h := c.Writer.Header()
+		// 带凭证时不能返回"*"，总是回写请求的Origin
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Add("Vary", "Origin")
+		if cfg.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		// 预检请求
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", strings.Join(cfg.AllowMethods, ", "))
+			h.Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowHeaders, ", "))
+			if cfg.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(cfg.MaxAge))
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		if len(cfg.ExposeHeaders) > 0 {
+			h.Set("Access-Control-Expose-Headers", strings.Join(cfg.ExposeHeaders, ", "))
+		}
+		c.Next()
+	}
+}
+
+func originAllowed(allowed []string, origin string, allowWildcard bool) bool {
+	for _, o := range allowed {
+		if o == "*" && allowWildcard {
+			return true
+		}
+		if strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
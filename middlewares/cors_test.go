@@ -0,0 +1,68 @@
+package middlewares
+
+import (
+	"go-web-app/settings"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupCORSTest(t *testing.T, mode string, origins ...string) *gin.Engine {
+	old, oldMode := settings.Conf.CORSConfig, gin.Mode()
+	settings.Conf.CORSConfig = &settings.CORSConfig{
+		AllowOrigins:     origins,
+		AllowMethods:     []string{"GET", "POST"},
+		AllowHeaders:     []string{"Authorization", "Content-Type"},
+		AllowCredentials: true,
+		MaxAge:           600,
+	}
+	gin.SetMode(mode)
+	t.Cleanup(func() {
+		settings.Conf.CORSConfig = old
+		gin.SetMode(oldMode)
+	})
+	r := gin.New()
+	r.Use(CORSMiddleware())
+	r.GET("/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+	return r
+}
+
+func doCORSRequest(r *gin.Engine, method, origin string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, "/ping", nil)
+	req.Header.Set("Origin", origin)
+	if method == http.MethodOptions {
+		req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	r := setupCORSTest(t, gin.ReleaseMode, "https://app.example.com", "*")
+
+	w := doCORSRequest(r, http.MethodOptions, "https://app.example.com")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "GET, POST", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+
+	w = doCORSRequest(r, http.MethodGet, "https://app.example.com")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// release模式下"*"不生效
+	w = doCORSRequest(r, http.MethodGet, "https://evil.example.com")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSMiddlewareWildcardInDebug(t *testing.T) {
+	r := setupCORSTest(t, gin.DebugMode, "*")
+	w := doCORSRequest(r, http.MethodOptions, "http://localhost:8080")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "http://localhost:8080", w.Header().Get("Access-Control-Allow-Origin"))
+}
@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"go-web-app/controller"
+	"go-web-app/settings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CSRFMiddleware 开启csrf.enable后，修改请求需要在请求头中带上与cookie中相同的token(双重提交)
+// 带Authorization头的请求使用的不是浏览器自动携带的凭证，不需要校验
+func CSRFMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := settings.Current().CSRFConfig
+		if cfg == nil || !cfg.Enable || isReadOnlyMethod(c.Request.Method) || c.GetHeader("Authorization") != "" {
+			c.Next()
+			return
+		}
+		cookie, _ := c.Cookie(cfg.CookieName)
+		header := c.GetHeader(cfg.HeaderName)
+		if cookie == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) != 1 {
+			controller.ResponseError(c, controller.CodeInvalidCSRFToken)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,54 @@
+package middlewares
+
+import (
+	"go-web-app/settings"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSRFMiddleware(t *testing.T) {
+	old := settings.Conf.CSRFConfig
+	cfg := &settings.CSRFConfig{CookieName: "csrf_token", HeaderName: "X-CSRF-Token"}
+	settings.Conf.CSRFConfig = cfg
+	t.Cleanup(func() { settings.Conf.CSRFConfig = old })
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(CSRFMiddleware())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/posts", ok)
+	r.POST("/post", ok)
+
+	do := func(method, cookie, header, auth string) int {
+		req := httptest.NewRequest(method, map[string]string{http.MethodGet: "/posts", http.MethodPost: "/post"}[method], nil)
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "csrf_token", Value: cookie})
+		}
+		if header != "" {
+			req.Header.Set("X-CSRF-Token", header)
+		}
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 没有开启时不校验
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "", "", ""))
+
+	cfg.Enable = true
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "", "", ""))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "", "", ""))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "abc", "", ""))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "abc", "abd", ""))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "", "abc", ""))
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "abc", "abc", ""))
+	// Bearer token不是浏览器自动携带的，不需要CSRF token
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "", "", "Bearer x"))
+}
@@ -0,0 +1,37 @@
+package middlewares
+
+import (
+	"fmt"
+	"go-web-app/settings"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeprecationMiddleware 按api.deprecations中的配置为version的响应加上弃用相关的响应头
+// Deprecation使用RFC 9745的格式(@unix时间戳)，Sunset使用RFC 8594的HTTP日期
+func DeprecationMiddleware(version string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := settings.Current().APIConfig
+		if cfg != nil {
+			setDeprecationHeaders(c.Writer.Header(), cfg.Deprecations[version])
+		}
+		c.Next()
+	}
+}
+
+func setDeprecationHeaders(h http.Header, d *settings.APIDeprecation) {
+	if d == nil {
+		return
+	}
+	if t, err := time.Parse(time.RFC3339, d.Date); err == nil {
+		h.Set("Deprecation", fmt.Sprintf("@%d", t.Unix()))
+	}
+	if t, err := time.Parse(time.RFC3339, d.Sunset); err == nil {
+		h.Set("Sunset", t.UTC().Format(http.TimeFormat))
+	}
+	if d.Link != "" {
+		h.Set("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, d.Link))
+	}
+}
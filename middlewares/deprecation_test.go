@@ -0,0 +1,37 @@
+package middlewares
+
+import (
+	"go-web-app/settings"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeprecationMiddleware(t *testing.T) {
+	old := settings.Conf.APIConfig
+	settings.Conf.APIConfig = &settings.APIConfig{
+		Deprecations: map[string]*settings.APIDeprecation{
+			"v1": {Date: "2024-01-01T00:00:00Z", Sunset: "2024-07-01T00:00:00Z", Link: "/api/v2"},
+		},
+	}
+	t.Cleanup(func() { settings.Conf.APIConfig = old })
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/v1", DeprecationMiddleware("v1"), func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/v2", DeprecationMiddleware("v2"), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1", nil))
+	assert.Equal(t, "@1704067200", w.Header().Get("Deprecation"))
+	assert.Equal(t, "Mon, 01 Jul 2024 00:00:00 GMT", w.Header().Get("Sunset"))
+	assert.Equal(t, `</api/v2>; rel="successor-version"`, w.Header().Get("Link"))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2", nil))
+	assert.Empty(t, w.Header().Get("Deprecation"))
+	assert.Empty(t, w.Header().Get("Sunset"))
+}
@@ -0,0 +1,25 @@
+package middlewares
+
+import (
+	"go-web-app/controller"
+	"go-web-app/logic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// isFeatureEnabled 测试时替换，避免依赖redis
+var isFeatureEnabled = logic.IsEnabled
+
+// FeatureMiddleware 功能开关没有对当前用户开启时返回404，放在认证之后可以按用户部分开启
+// 未登录的请求按userID为0判断，只在开关全部开启时放行
+func FeatureMiddleware(flag string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := controller.GetCurrentUserID(c)
+		if !isFeatureEnabled(flag, userID) {
+			controller.ResponseError(c, controller.CodeNotFound)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
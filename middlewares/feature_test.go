@@ -0,0 +1,46 @@
+package middlewares
+
+import (
+	"go-web-app/controller"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureMiddleware(t *testing.T) {
+	old := isFeatureEnabled
+	isFeatureEnabled = func(flag string, userID int64) bool { return flag == "new_feed" && userID == 1 }
+	t.Cleanup(func() { isFeatureEnabled = old })
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if uid := c.GetHeader("X-User"); uid == "1" {
+			c.Set(controller.ContextUserIDKey, int64(1))
+		}
+	})
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/feed", FeatureMiddleware("new_feed"), ok)
+	r.GET("/other", FeatureMiddleware("other"), ok)
+
+	tests := []struct {
+		path, user string
+		want       int
+	}{
+		{"/feed", "1", http.StatusOK},
+		{"/feed", "", http.StatusNotFound},
+		{"/other", "1", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.user != "" {
+			req.Header.Set("X-User", tt.user)
+		}
+		r.ServeHTTP(w, req)
+		assert.Equal(t, tt.want, w.Code, "%s user=%q", tt.path, tt.user)
+	}
+}
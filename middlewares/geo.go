@@ -0,0 +1,108 @@
+package middlewares
+
+import (
+	"fmt"
+	"go-web-app/controller"
+	"go-web-app/pkg/geoip"
+	"go-web-app/settings"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	geoProviderHeader = "header"
+	geoProviderFile   = "file"
+)
+
+// lookupCountry 测试时替换，避免依赖请求头和网段文件
+var lookupCountry = countryFromConfig
+
+// SignupGeoMiddleware 开启signup_geo.enable后按IP所在的国家限制注册
+// 被拒绝时只返回通用的错误，具体原因只记录在日志中
+func SignupGeoMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := settings.Current().SignupGeoConfig
+		if cfg == nil || !cfg.Enable {
+			c.Next()
+			return
+		}
+		country, err := lookupCountry(c, cfg)
+		switch {
+		case err != nil && cfg.FailOpen:
+			zap.L().Warn("geo lookup failed, signup allowed", zap.String("ip", c.ClientIP()), zap.Error(err))
+			c.Next()
+			return
+		case err != nil:
+			zap.L().Warn("geo lookup failed, signup blocked", zap.String("ip", c.ClientIP()), zap.Error(err))
+		case !countryAllowed(country, cfg.Allow, cfg.Deny):
+			zap.L().Info("signup blocked by country", zap.String("ip", c.ClientIP()), zap.String("country", country))
+		default:
+			c.Next()
+			return
+		}
+		controller.ResponseError(c, controller.CodeSignupUnavailable)
+		c.Abort()
+	}
+}
+
+// countryAllowed 在deny中的国家不允许，allow不为空时只允许其中的国家，不区分大小写
+func countryAllowed(country string, allow, deny []string) bool {
+	for _, d := range deny {
+		if strings.EqualFold(d, country) {
+			return false
+		}
+	}
+	if len(allow) == 0 {
+		return true
+	}
+	for _, a := range allow {
+		if strings.EqualFold(a, country) {
+			return true
+		}
+	}
+	return false
+}
+
+func countryFromConfig(c *gin.Context, cfg *settings.SignupGeoConfig) (string, error) {
+	switch cfg.Provider {
+	case geoProviderHeader:
+		// Cloudflare对查不到的IP返回XX，Tor返回T1
+		country := strings.ToUpper(strings.TrimSpace(c.GetHeader(cfg.Header)))
+		if len(country) != 2 || country == "XX" {
+			return "", geoip.ErrUnknown
+		}
+		return country, nil
+	case geoProviderFile:
+		table, err := loadGeoTable(cfg.File)
+		if err != nil {
+			return "", err
+		}
+		// 不使用c.ClientIP()，客户端可以伪造X-Forwarded-For，代理转发的请求由RealIPMiddleware处理
+		return table.Country(remoteIP(c))
+	}
+	return "", fmt.Errorf("unknown signup_geo.provider %q", cfg.Provider)
+}
+
+// geoTable 网段文件只在路径变化后重新加载
+var geoTable struct {
+	sync.Mutex
+	path  string
+	table geoip.Resolver
+}
+
+func loadGeoTable(path string) (geoip.Resolver, error) {
+	geoTable.Lock()
+	defer geoTable.Unlock()
+	if geoTable.table != nil && geoTable.path == path {
+		return geoTable.table, nil
+	}
+	table, err := geoip.LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	geoTable.path, geoTable.table = path, table
+	return table, nil
+}
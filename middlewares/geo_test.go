@@ -0,0 +1,91 @@
+package middlewares
+
+import (
+	"errors"
+	"go-web-app/settings"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignupGeoMiddleware(t *testing.T) {
+	old := settings.Conf.SignupGeoConfig
+	cfg := &settings.SignupGeoConfig{Provider: geoProviderHeader, Header: "CF-IPCountry", Deny: []string{"kp"}}
+	settings.Conf.SignupGeoConfig = cfg
+	t.Cleanup(func() { settings.Conf.SignupGeoConfig = old })
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/signup", SignupGeoMiddleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	do := func(country string) int {
+		req := httptest.NewRequest(http.MethodPost, "/signup", nil)
+		if country != "" {
+			req.Header.Set("CF-IPCountry", country)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 没有开启时不检查
+	assert.Equal(t, http.StatusOK, do("KP"))
+
+	cfg.Enable = true
+	assert.Equal(t, http.StatusForbidden, do("KP"))
+	assert.Equal(t, http.StatusOK, do("us"))
+	cfg.Allow = []string{"US", "CA"}
+	assert.Equal(t, http.StatusOK, do("US"))
+	assert.Equal(t, http.StatusForbidden, do("DE"))
+
+	// 查不到国家时按fail_open处理
+	cfg.FailOpen = true
+	assert.Equal(t, http.StatusOK, do(""))
+	assert.Equal(t, http.StatusOK, do("XX"))
+	cfg.FailOpen = false
+	assert.Equal(t, http.StatusForbidden, do(""))
+
+	oldLookup := lookupCountry
+	lookupCountry = func(*gin.Context, *settings.SignupGeoConfig) (string, error) { return "", errors.New("lookup failed") }
+	t.Cleanup(func() { lookupCountry = oldLookup })
+	assert.Equal(t, http.StatusForbidden, do("US"))
+	cfg.FailOpen = true
+	assert.Equal(t, http.StatusOK, do("US"))
+}
+
+func TestSignupGeoFileIgnoresForwardedFor(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "geo.csv")
+	require.NoError(t, os.WriteFile(file, []byte("203.0.113.0/24,KP\n198.51.100.0/24,US\n"), 0o600))
+	old := settings.Conf.SignupGeoConfig
+	settings.Conf.SignupGeoConfig = &settings.SignupGeoConfig{Enable: true, Provider: geoProviderFile, File: file, Deny: []string{"KP"}}
+	t.Cleanup(func() { settings.Conf.SignupGeoConfig = old })
+
+	gin.SetMode(gin.TestMode)
+	newRouter := func(trusted ...string) *gin.Engine {
+		r := gin.New()
+		r.Use(RealIPMiddleware(trusted))
+		r.POST("/signup", SignupGeoMiddleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+		return r
+	}
+	do := func(r *gin.Engine, remote, xff string) int {
+		req := httptest.NewRequest(http.MethodPost, "/signup", nil)
+		req.RemoteAddr = remote
+		req.Header.Set("X-Forwarded-For", xff)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 即使engine信任请求头，伪造的X-Forwarded-For也不影响国家的判断
+	r := newRouter()
+	assert.Equal(t, http.StatusForbidden, do(r, "203.0.113.7:5000", "198.51.100.9"))
+	// 可信代理转发的请求使用代理记录的地址
+	r = newRouter("10.0.0.1")
+	assert.Equal(t, http.StatusOK, do(r, "10.0.0.1:5000", "198.51.100.9"))
+	assert.Equal(t, http.StatusForbidden, do(r, "10.0.0.1:5000", "203.0.113.7"))
+}
@@ -0,0 +1,44 @@
+package middlewares
+
+import (
+	"go-web-app/logger"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InFlightRequest 一个正在处理的请求
+type InFlightRequest struct {
+	RequestID string
+	Method    string
+	Path      string
+	Start     time.Time
+}
+
+var inFlight sync.Map // *gin.Context -> *InFlightRequest
+
+// InFlightMiddleware 记录正在处理的请求，关闭服务超时时用来定位没有处理完的请求
+// 需要注册在logger.GinLogger之后，才能拿到请求id
+func InFlightMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inFlight.Store(c, &InFlightRequest{
+			RequestID: c.GetString(logger.ContextRequestIDKey),
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			Start:     time.Now(),
+		})
+		defer inFlight.Delete(c)
+		c.Next()
+	}
+}
+
+// InFlightRequests 返回当前正在处理的请求
+func InFlightRequests() []*InFlightRequest {
+	var list []*InFlightRequest
+	inFlight.Range(func(_, v interface{}) bool {
+		list = append(list, v.(*InFlightRequest))
+		return true
+	})
+	return list
+}
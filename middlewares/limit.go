@@ -0,0 +1,186 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"go-web-app/controller"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func isExempt(c *gin.Context, exempt []string) bool {
+	path := c.FullPath()
+	for _, p := range exempt {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// BodyLimitMiddleware 限制请求体大小，超过limit字节时返回413。
+// 在Content-Length超限时直接拒绝；没有Content-Length时最多读取limit+1个字节，不会把超大的请求体读进内存。
+// exempt中的路由(例如上传文件)不受限制，由它们自己的中间件单独限制。
+func BodyLimitMiddleware(limit int64, exempt ...string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if limit <= 0 || c.Request.Body == nil || isExempt(c, exempt) {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > limit {
+			controller.ResponseErrorWithStatus(c, http.StatusRequestEntityTooLarge, controller.CodeRequestTooLarge)
+			c.Abort()
+			return
+		}
+		if c.Request.ContentLength < 0 {
+			data, err := ioutil.ReadAll(io.LimitReader(c.Request.Body, limit+1))
+			if err != nil {
+				controller.ResponseError(c, controller.CodeInvalidParam)
+				c.Abort()
+				return
+			}
+			if int64(len(data)) > limit {
+				controller.ResponseErrorWithStatus(c, http.StatusRequestEntityTooLarge, controller.CodeRequestTooLarge)
+				c.Abort()
+				return
+			}
+			c.Request.Body = ioutil.NopCloser(bytes.NewReader(data))
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+// TimeoutMiddleware 给请求的context设置超时，超时后立即返回503，处理函数之后写入的内容被丢弃。
+// 处理函数仍然在原来的goroutine中执行，使用c.Request.Context()的数据库调用会被取消。
+// websocket这类长连接需要放在exempt中。
+func TimeoutMiddleware(timeout time.Duration, exempt ...string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if timeout <= 0 || isExempt(c, exempt) {
+			c.Next()
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		tw := newTimeoutWriter(c.Writer)
+		c.Writer = tw
+		timer := time.AfterFunc(timeout, tw.timeout)
+		defer func() {
+			timer.Stop()
+			c.Writer = tw.ResponseWriter
+		}()
+		c.Next()
+		// 处理函数可能先于定时器发现超时并返回
+		if ctx.Err() == context.DeadlineExceeded {
+			tw.timeout()
+		}
+		tw.flush()
+	}
+}
+
+// timeoutWriter 先把响应写到缓冲区，处理完成后再写给客户端；超时后只写503
+type timeoutWriter struct {
+	gin.ResponseWriter
+	mu       sync.Mutex
+	header   http.Header
+	body     bytes.Buffer
+	status   int
+	timedOut bool
+	done     bool
+}
+
+func newTimeoutWriter(w gin.ResponseWriter) *timeoutWriter {
+	header := make(http.Header, len(w.Header()))
+	for k, v := range w.Header() {
+		header[k] = v
+	}
+	return &timeoutWriter{ResponseWriter: w, header: header, status: http.StatusOK}
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// 超时后直接丢弃，gin在写入失败时会panic
+	if w.timedOut {
+		return len(b), nil
+	}
+	return w.body.Write(b)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.timedOut && code > 0 {
+		w.status = code
+	}
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {}
+
+func (w *timeoutWriter) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+func (w *timeoutWriter) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.body.Len()
+}
+
+func (w *timeoutWriter) Written() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.body.Len() > 0
+}
+
+// timeout 在定时器的goroutine中执行，直接给客户端返回503
+func (w *timeoutWriter) timeout() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.done || w.timedOut {
+		return
+	}
+	w.timedOut = true
+	body, _ := json.Marshal(&controller.ResponseData{
+		Code: controller.CodeRequestTimeout,
+		Msg:  controller.CodeRequestTimeout.Msg(),
+	})
+	w.ResponseWriter.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.ResponseWriter.WriteHeader(http.StatusServiceUnavailable)
+	_, _ = w.ResponseWriter.Write(body)
+	w.ResponseWriter.Flush()
+}
+
+// flush 处理函数返回后把缓冲的响应写给客户端
+func (w *timeoutWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.done = true
+	if w.timedOut {
+		return
+	}
+	dst := w.ResponseWriter.Header()
+	for k, v := range w.header {
+		dst[k] = v
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	_, _ = w.ResponseWriter.Write(w.body.Bytes())
+}
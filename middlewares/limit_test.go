@@ -0,0 +1,87 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"go-web-app/controller"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(BodyLimitMiddleware(8, "/upload"))
+	echo := func(c *gin.Context) {
+		b, err := ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.String(http.StatusOK, string(b))
+	}
+	r.POST("/echo", echo)
+	r.POST("/upload", echo)
+
+	do := func(path string, body []byte, chunked bool) int {
+		req, _ := http.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+		if chunked {
+			req.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, do("/echo", []byte("12345678"), false))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, do("/echo", []byte("123456789"), false))
+	assert.Equal(t, http.StatusOK, do("/echo", []byte("1234"), true))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, do("/echo", []byte("123456789"), true))
+	assert.Equal(t, http.StatusOK, do("/upload", []byte("123456789"), false))
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(TimeoutMiddleware(50*time.Millisecond, "/ws"))
+	slow := func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+		case <-time.After(time.Second):
+		}
+		c.String(http.StatusOK, "late")
+	}
+	r.GET("/slow", slow)
+	r.GET("/ws", func(c *gin.Context) {
+		time.Sleep(100 * time.Millisecond)
+		c.String(http.StatusOK, "ok")
+	})
+	r.GET("/fast", func(c *gin.Context) {
+		c.Header("X-Test", "1")
+		c.String(http.StatusCreated, "fast")
+	})
+
+	do := func(path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	w := do("/slow")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	var rd controller.ResponseData
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &rd))
+	assert.Equal(t, controller.CodeRequestTimeout, rd.Code)
+
+	w = do("/fast")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "fast", w.Body.String())
+	assert.Equal(t, "1", w.Header().Get("X-Test"))
+
+	assert.Equal(t, http.StatusOK, do("/ws").Code)
+}
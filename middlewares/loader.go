@@ -0,0 +1,15 @@
+package middlewares
+
+import (
+	"go-web-app/logic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LoaderMiddleware 为每个请求创建数据加载器，组装响应时同一个用户、社区或投票只查询一次
+func LoaderMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(logic.WithLoader(c.Request.Context()))
+		c.Next()
+	}
+}
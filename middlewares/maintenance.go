@@ -0,0 +1,77 @@
+package middlewares
+
+import (
+	"go-web-app/controller"
+	"go-web-app/logic"
+	"go-web-app/models"
+	"go-web-app/pkg/jwt"
+	"go-web-app/settings"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// getMaintenance 测试时替换，避免依赖redis
+var getMaintenance = logic.GetMaintenance
+
+// MaintenanceMiddleware 维护模式下返回503，read_only模式只放行只读的请求和maintenance.read_only_allow中的写请求
+// exempt中的路径前缀总是放行，用于健康检查、管理接口和登录；带管理员token的请求也会放行
+func MaintenanceMiddleware(exempt ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m := getMaintenance()
+		if m == nil || m.Mode == models.MaintenanceModeOff {
+			c.Next()
+			return
+		}
+		path := c.Request.URL.Path
+		if m.Mode == models.MaintenanceModeReadOnly {
+			if isReadOnlyMethod(c.Request.Method) || hasPrefix(path, settings.Current().MaintenanceConfig.ReadOnlyAllow) {
+				c.Next()
+				return
+			}
+		}
+		if hasPrefix(path, exempt) || isAdminRequest(c) {
+			c.Next()
+			return
+		}
+		c.Header("Retry-After", "60")
+		c.AbortWithStatusJSON(controller.CodeMaintenance.Status(), &controller.ResponseData{
+			Code: controller.CodeMaintenance,
+			Msg:  controller.CodeMaintenance.Msg(),
+			Data: m,
+		})
+	}
+}
+
+func hasPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// isAdminRequest 请求带了有效的管理员token，只在维护模式下检查，不影响正常请求的开销
+func isAdminRequest(c *gin.Context) bool {
+	parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return false
+	}
+	mc, err := jwt.ParseToken(parts[1])
+	if err != nil {
+		return false
+	}
+	admin, err := logic.IsAdmin(mc.UserID)
+	if err != nil {
+		zap.L().Error("logic.IsAdmin failed", zap.Int64("userID", mc.UserID), zap.Error(err))
+		return false
+	}
+	return admin
+}
@@ -0,0 +1,59 @@
+package middlewares
+
+import (
+	"go-web-app/models"
+	"go-web-app/settings"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaintenanceMiddleware(t *testing.T) {
+	var current *models.Maintenance
+	old := getMaintenance
+	getMaintenance = func() *models.Maintenance { return current }
+	oldCfg := settings.Conf.MaintenanceConfig
+	settings.Conf.MaintenanceConfig = &settings.MaintenanceConfig{ReadOnlyAllow: []string{"/api/v1/logout"}}
+	t.Cleanup(func() {
+		getMaintenance = old
+		settings.Conf.MaintenanceConfig = oldCfg
+	})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(MaintenanceMiddleware("/healthz", "/api/v1/admin"))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/healthz", ok)
+	r.GET("/api/v1/posts", ok)
+	r.POST("/api/v1/post", ok)
+	r.POST("/api/v1/logout", ok)
+	r.PUT("/api/v1/admin/maintenance", ok)
+
+	tests := []struct {
+		mode         string
+		method, path string
+		want         int
+	}{
+		{models.MaintenanceModeOff, http.MethodPost, "/api/v1/post", http.StatusOK},
+		{models.MaintenanceModeFull, http.MethodGet, "/api/v1/posts", http.StatusServiceUnavailable},
+		{models.MaintenanceModeFull, http.MethodPost, "/api/v1/post", http.StatusServiceUnavailable},
+		{models.MaintenanceModeFull, http.MethodGet, "/healthz", http.StatusOK},
+		{models.MaintenanceModeFull, http.MethodPut, "/api/v1/admin/maintenance", http.StatusOK},
+		{models.MaintenanceModeReadOnly, http.MethodGet, "/api/v1/posts", http.StatusOK},
+		{models.MaintenanceModeReadOnly, http.MethodPost, "/api/v1/post", http.StatusServiceUnavailable},
+		{models.MaintenanceModeReadOnly, http.MethodPost, "/api/v1/logout", http.StatusOK},
+		{models.MaintenanceModeFull, http.MethodPost, "/api/v1/logout", http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		current = &models.Maintenance{Mode: tt.mode}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		assert.Equal(t, tt.want, w.Code, "%s %s %s", tt.mode, tt.method, tt.path)
+		if tt.want == http.StatusServiceUnavailable {
+			assert.Contains(t, w.Body.String(), `"mode":"`+tt.mode+`"`)
+		}
+	}
+}
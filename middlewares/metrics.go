@@ -0,0 +1,70 @@
+package middlewares
+
+import (
+	"go-web-app/dao/mysql"
+	"go-web-app/dao/redis"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Total number of HTTP requests by route and status.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		httpRequestsTotal,
+		httpRequestDuration,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "mysql_open_connections",
+			Help: "Number of established connections in the mysql pool.",
+		}, func() float64 { return float64(mysql.Stats().OpenConnections) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "mysql_in_use_connections",
+			Help: "Number of mysql connections currently in use.",
+		}, func() float64 { return float64(mysql.Stats().InUse) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "redis_total_connections",
+			Help: "Number of connections in the redis pool.",
+		}, func() float64 { return float64(redis.PoolStats().TotalConns) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "redis_idle_connections",
+			Help: "Number of idle connections in the redis pool.",
+		}, func() float64 { return float64(redis.PoolStats().IdleConns) }),
+	)
+}
+
+// MetricsMiddleware 按路由统计请求数和耗时
+// 使用路由模板而不是实际路径作为标签，避免/post/:id这类路径导致标签数量爆炸
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		httpRequestsTotal.WithLabelValues(c.Request.Method, route, status).Inc()
+		httpRequestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// MetricsHandler 暴露Prometheus格式的指标
+func MetricsHandler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
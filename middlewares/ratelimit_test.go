@@ -0,0 +1,49 @@
+package middlewares
+
+import (
+	"go-web-app/dao/redis"
+	"go-web-app/settings"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisRateLimitRulesAreSeparate(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+	port, err := strconv.Atoi(mr.Port())
+	require.NoError(t, err)
+	require.NoError(t, redis.Init(&settings.RedisConfig{Host: mr.Host(), Port: port, BreakerThreshold: 100, BreakerCooldown: 1000, FallbackCacheSize: 16}))
+	defer redis.Close()
+
+	old := settings.Conf.RateLimitConfig
+	settings.Conf.RateLimitConfig = &settings.RateLimitConfig{Rate: 10, Burst: 20, Rules: map[string]*settings.RateLimitRule{
+		"login":           {Rate: 0.01, Burst: 1},
+		"password_forgot": {Rate: 0.01, Burst: 1},
+	}}
+	defer func() { settings.Conf.RateLimitConfig = old }()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.POST("/login", RedisRateLimitMiddleware("login"), ok)
+	r.POST("/password/forgot", RedisRateLimitMiddleware("password_forgot"), ok)
+	do := func(path string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, do("/login"))
+	assert.Equal(t, http.StatusTooManyRequests, do("/login"))
+	// 登录用完了额度，找回密码不受影响
+	assert.Equal(t, http.StatusOK, do("/password/forgot"))
+	assert.Equal(t, http.StatusTooManyRequests, do("/password/forgot"))
+}
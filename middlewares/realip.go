@@ -0,0 +1,99 @@
+package middlewares
+
+import (
+	"net"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RealIPMiddleware 请求直接来自可信的代理时，按X-Forwarded-For找出客户端的地址并写回RemoteAddr，
+// 之后c.ClientIP()返回的就是客户端的地址。需要关闭engine.ForwardedByClientIP，否则gin直接信任请求头。
+// 从右往左跳过可信的代理，第一个不可信的地址是客户端，客户端自己伪造的部分在它的左边，不会被使用
+func RealIPMiddleware(trusted []string) gin.HandlerFunc {
+	nets := parseTrustedProxies(trusted)
+	return func(c *gin.Context) {
+		if len(nets) == 0 {
+			c.Next()
+			return
+		}
+		host, port, err := net.SplitHostPort(strings.TrimSpace(c.Request.RemoteAddr))
+		if err != nil || !ipTrusted(nets, net.ParseIP(host)) {
+			c.Next()
+			return
+		}
+		if ip := forwardedClientIP(nets, c.Request.Header.Values("X-Forwarded-For")); ip != "" {
+			c.Request.RemoteAddr = net.JoinHostPort(ip, port)
+		}
+		c.Next()
+	}
+}
+
+// remoteIP 经过RealIPMiddleware处理后的客户端地址，不受engine.ForwardedByClientIP的影响，
+// 用于按IP做访问控制的地方
+func remoteIP(c *gin.Context) net.IP {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(c.Request.RemoteAddr))
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+// forwardedClientIP 最右边不可信的地址，所有地址都可信时返回最左边的地址，格式不对时返回空字符串
+func forwardedClientIP(nets []*net.IPNet, headers []string) string {
+	var hops []string
+	for _, h := range headers {
+		hops = append(hops, strings.Split(h, ",")...)
+	}
+	var client string
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			// 无法解析时不再往左找，左边的内容不可信
+			return client
+		}
+		client = ip.String()
+		if !ipTrusted(nets, ip) {
+			return client
+		}
+	}
+	return client
+}
+
+func ipTrusted(nets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrustedProxies 单个IP按/32或/128处理，写错的条目记录日志后忽略
+func parseTrustedProxies(trusted []string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(trusted))
+	for _, t := range trusted {
+		t = strings.TrimSpace(t)
+		if !strings.Contains(t, "/") {
+			if ip := net.ParseIP(t); ip != nil {
+				bits := 8 * net.IPv6len
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 8*net.IPv4len
+				}
+				nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+		}
+		_, n, err := net.ParseCIDR(t)
+		if err != nil {
+			zap.L().Error("invalid request.trusted_proxies entry", zap.String("entry", t), zap.Error(err))
+			continue
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
@@ -0,0 +1,58 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRealIPMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newRouter := func(trusted ...string) *gin.Engine {
+		r := gin.New()
+		r.ForwardedByClientIP = false
+		r.Use(RealIPMiddleware(trusted))
+		r.GET("/", func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) })
+		return r
+	}
+	do := func(r *gin.Engine, remote string, xff ...string) string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remote
+		for _, h := range xff {
+			req.Header.Add("X-Forwarded-For", h)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	// 没有配置可信代理时请求头被忽略
+	r := newRouter()
+	assert.Equal(t, "203.0.113.7", do(r, "203.0.113.7:5000", "1.2.3.4"))
+
+	r = newRouter("10.0.0.1", "172.16.0.0/12")
+	// 不是来自可信代理，伪造的请求头无效
+	assert.Equal(t, "203.0.113.7", do(r, "203.0.113.7:5000", "1.2.3.4"))
+	// 来自可信代理时取最右边不可信的地址，客户端伪造的部分在左边
+	assert.Equal(t, "198.51.100.9", do(r, "10.0.0.1:5000", "1.2.3.4, 198.51.100.9"))
+	// 跳过中间的可信代理，多个请求头按顺序拼接
+	assert.Equal(t, "198.51.100.9", do(r, "10.0.0.1:5000", "1.2.3.4, 198.51.100.9", "172.16.3.4"))
+	// 格式不对的条目左边的内容不再使用
+	assert.Equal(t, "198.51.100.9", do(r, "10.0.0.1:5000", "1.2.3.4, garbage, 198.51.100.9"))
+	// 没有请求头或全是可信代理时
+	assert.Equal(t, "10.0.0.1", do(r, "10.0.0.1:5000"))
+	assert.Equal(t, "172.16.0.2", do(r, "10.0.0.1:5000", "172.16.0.2"))
+	assert.Equal(t, "10.0.0.1", do(r, "10.0.0.1:5000", "garbage"))
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	nets := parseTrustedProxies([]string{"10.0.0.1", " ::1 ", "192.168.0.0/16", "bad"})
+	assert.Len(t, nets, 3)
+	assert.True(t, ipTrusted(nets, []byte{10, 0, 0, 1}))
+	assert.False(t, ipTrusted(nets, []byte{10, 0, 0, 2}))
+	assert.True(t, ipTrusted(nets, []byte{192, 168, 5, 5}))
+	assert.False(t, ipTrusted(nets, nil))
+}
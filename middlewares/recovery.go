@@ -0,0 +1,72 @@
+package middlewares
+
+import (
+	"errors"
+	"fmt"
+	"go-web-app/controller"
+	"go-web-app/logger"
+	"net"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RecoveryMiddleware 捕获处理函数和其他中间件中的panic，记录调用栈后返回500
+// 需要注册在最外层；只有debug模式下才在响应中返回panic的内容
+func RecoveryMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			// 请求头和查询参数中可能有token，只记录路径
+			lg := logger.L(c.Request.Context()).With(
+				zap.Any("error", err),
+				zap.String("method", c.Request.Method),
+				zap.String("path", c.Request.URL.Path),
+			)
+			// 客户端断开连接时无法再写入响应
+			if isBrokenPipe(err) {
+				lg.Warn("[Recovery from panic] broken connection")
+				c.Abort()
+				return
+			}
+			lg.Error("[Recovery from panic]", zap.String("stack", string(debug.Stack())))
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			msg := controller.CodeServerBusy.Msg()
+			if gin.Mode() == gin.DebugMode {
+				msg = fmt.Sprint(err)
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, &controller.ResponseData{
+				Code: controller.CodeServerBusy,
+				Msg:  msg,
+			})
+		}()
+		c.Next()
+	}
+}
+
+func isBrokenPipe(err interface{}) bool {
+	e, ok := err.(error)
+	if !ok {
+		return false
+	}
+	var ne *net.OpError
+	if !errors.As(e, &ne) {
+		return false
+	}
+	var se *os.SyscallError
+	if !errors.As(ne, &se) {
+		return false
+	}
+	s := strings.ToLower(se.Error())
+	return strings.Contains(s, "broken pipe") || strings.Contains(s, "connection reset by peer")
+}
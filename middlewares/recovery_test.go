@@ -0,0 +1,45 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"go-web-app/controller"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	defer gin.SetMode(gin.TestMode)
+	newRouter := func() *gin.Engine {
+		r := gin.New()
+		r.Use(RecoveryMiddleware())
+		r.GET("/handler", func(c *gin.Context) { panic("secret internals") })
+		r.GET("/middleware", func(c *gin.Context) { panic("secret internals") }, func(c *gin.Context) {
+			c.String(http.StatusOK, "unreachable")
+		})
+		return r
+	}
+	do := func(r *gin.Engine, path string) (int, *controller.ResponseData) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		resp := new(controller.ResponseData)
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		return w.Code, resp
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	r := newRouter()
+	for _, path := range []string{"/handler", "/middleware"} {
+		code, resp := do(r, path)
+		assert.Equal(t, http.StatusInternalServerError, code)
+		assert.Equal(t, controller.CodeServerBusy, resp.Code)
+		assert.NotContains(t, resp.Msg, "secret")
+	}
+
+	gin.SetMode(gin.DebugMode)
+	_, resp := do(newRouter(), "/handler")
+	assert.Equal(t, "secret internals", resp.Msg)
+}
@@ -0,0 +1,106 @@
+package models
+
+import "time"
+
+// 记录在审计日志中的敏感操作
+const (
+	AuditActionLogin         = "login"
+	AuditActionLoginLockout  = "login_lockout"
+	AuditActionPasswordReset = "password_reset"
+	AuditActionEnable2FA     = "enable_2fa"
+	AuditActionDisable2FA    = "disable_2fa"
+	AuditActionPromoteMod    = "promote_moderator"
+	AuditActionDemoteMod     = "demote_moderator"
+	AuditActionPromoteAdmin  = "promote_community_admin"
+	AuditActionDemoteAdmin   = "demote_community_admin"
+	AuditActionBanUser       = "ban_user"
+	AuditActionRemovePost    = "remove_post"
+	AuditActionDismissReport = "dismiss_report"
+	AuditActionRemoveComment = "remove_comment"
+	AuditActionPurge         = "purge"
+	AuditActionMaintenance   = "maintenance"
+	AuditActionFeatureFlag   = "feature_flag"
+	AuditActionMergeUser     = "merge_user"
+	AuditActionRemoveContent = "remove_user_content"
+
+	AuditActionCommunityVisibility = "community_visibility"
+	AuditActionCreateCommunity     = "create_community"
+	AuditActionCommunityPostLength = "community_min_post_length"
+	AuditActionCommunityRules      = "community_rules"
+	AuditActionCommunityPostPerm   = "community_post_permission"
+	AuditActionCommunityAnonymous  = "community_allow_anonymous"
+	AuditActionRevealAuthor        = "reveal_anonymous_author"
+	AuditActionAddWebhook          = "add_webhook"
+	AuditActionDeleteWebhook       = "delete_webhook"
+)
+
+// AdminUser 管理后台中的用户信息
+type AdminUser struct {
+	UserID        int64     `json:"user_id" db:"user_id"`
+	Username      string    `json:"username" db:"username"`
+	Email         string    `json:"email" db:"email"`
+	EmailVerified bool      `json:"email_verified" db:"email_verified"`
+	IsAdmin       bool      `json:"is_admin" db:"is_admin"`
+	Banned        bool      `json:"banned" db:"banned"`
+	Deactivated   bool      `json:"deactivated" db:"deactivated"`
+	CreateTime    time.Time `json:"create_time" db:"create_time"`
+}
+
+// SiteStats 网站的总体数据
+type SiteStats struct {
+	TotalUsers    int64 `json:"total_users"`
+	TotalPosts    int64 `json:"total_posts"`
+	TotalComments int64 `json:"total_comments"`
+}
+
+// PurgeResult 一次清理中彻底删除的数据条数
+type PurgeResult struct {
+	Posts         int64 `json:"posts"`
+	Comments      int64 `json:"comments"` // 被删除的帖子下的评论
+	Revisions     int64 `json:"revisions"`
+	Notifications int64 `json:"notifications"`
+	Attachments   int64 `json:"attachments"` // 被删除的帖子的附件
+}
+
+// ParamRemoveContent 批量删除用户内容的时间范围[from, to)，为空时不限制
+type ParamRemoveContent struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// ContentRemoval 批量删除用户内容的结果
+type ContentRemoval struct {
+	Posts    int64 `json:"posts"`
+	Detached int64 `json:"detached"` // 同时发布到其他社区的帖子只从当前社区移除
+	Comments int64 `json:"comments"`
+}
+
+// AuditLog 审计日志，只追加不修改
+type AuditLog struct {
+	AuditID    int64     `json:"audit_id,omitempty" bson:"audit_id,omitempty"`
+	ActorID    int64     `json:"actor_id" bson:"actor_id"`
+	Action     string    `json:"action" bson:"action"`
+	Target     string    `json:"target" bson:"target"`
+	IP         string    `json:"ip" bson:"ip"`
+	CreateTime time.Time `json:"create_time" bson:"create_time"`
+}
+
+// ParamPostExport 管理员导出帖子的筛选条件，条件为空时不过滤
+type ParamPostExport struct {
+	CommunityID int64     `json:"community_id" form:"community_id"`
+	From        time.Time `json:"from" form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To          time.Time `json:"to" form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	MinScore    *int64    `json:"min_score" form:"min_score"` // 赞成票数减反对票数的下限
+}
+
+// PostExport 导出的一行帖子数据，每行一个JSON对象
+type PostExport struct {
+	PostID      int64     `json:"post_id,string"`
+	AuthorID    int64     `json:"author_id,string"`
+	CommunityID int64     `json:"community_id"`
+	Title       string    `json:"title"`
+	Content     string    `json:"content"`
+	Excerpt     string    `json:"excerpt"`
+	Score       int64     `json:"score"`
+	CreateTime  time.Time `json:"create_time"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Attachment 帖子的附件
+type Attachment struct {
+	AttachmentID int64     `json:"attachment_id" db:"attachment_id"`
+	PostID       int64     `json:"post_id" db:"post_id"`
+	UploaderID   int64     `json:"uploader_id" db:"uploader_id"`
+	FileName     string    `json:"file_name" db:"file_name"`
+	ContentType  string    `json:"content_type" db:"content_type"`
+	Size         int64     `json:"size" db:"size"`
+	StoredName   string    `json:"-" db:"stored_name"`
+	URL          string    `json:"url" db:"-"` // 下载地址，需要登录，私有社区的附件只有成员可以下载
+	CreateTime   time.Time `json:"create_time" db:"create_time"`
+}
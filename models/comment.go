@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+type Comment struct {
+	CommentID  int64          `json:"comment_id" bson:"comment_id"`
+	PostID     int64          `json:"post_id" bson:"post_id"`
+	AuthorID   int64          `json:"author_id" bson:"author_id"`
+	ParentID   int64          `json:"parent_id" bson:"parent_id"` // 0 表示直接评论帖子
+	Content    string         `json:"content" bson:"content"`
+	Mentions   []*Mention     `json:"mentions,omitempty" bson:"mentions,omitempty"`
+	Deleted    bool           `json:"-" bson:"deleted"`
+	VoteNum    int64          `json:"vote_num" bson:"-"`         // 赞成票数，保存在redis中
+	Score      int64          `json:"score" bson:"-"`            // 赞成票数减反对票数，保存在redis中
+	Collapsed  bool           `json:"collapsed" bson:"-"`        // 得分低于comment.collapse_threshold时默认折叠，内容照常返回
+	Author     *AuthorProfile `json:"author,omitempty" bson:"-"` // 作者的公开信息，列表中批量查询
+	CreateTime time.Time      `json:"create_time" bson:"create_time"`
+	EditedAt   *time.Time     `json:"edited_at,omitempty" bson:"edited_at,omitempty"` // 最后一次编辑的时间，没有编辑过时为空
+}
+
+// CommentNode 评论树中的一个节点
+type CommentNode struct {
+	*Comment
+	ReplyCount int            `json:"reply_count"` // 树中该节点下所有回复的数量
+	Children   []*CommentNode `json:"children"`
+}
+
+// 评论的排序方式，评论树的每一层分别排序
+const (
+	CommentSortBest          = "best" // 按赞成票比例的Wilson置信区间下限
+	CommentSortNew           = "new"
+	CommentSortOld           = "old"
+	CommentSortControversial = "controversial" // 与帖子的争议度相同
+)
+
+// ParamCommentSort 评论列表的排序，没有传时按best排序
+type ParamCommentSort struct {
+	Sort string `form:"sort" binding:"omitempty,oneof=best new old controversial"`
+}
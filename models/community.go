@@ -2,14 +2,166 @@ package models
 
 import "time"
 
+// 社区内的角色，数值越大权限越高
+const (
+	CommunityRoleNone      int8 = 0 // 未加入社区
+	CommunityRoleMember    int8 = 1
+	CommunityRoleModerator int8 = 2
+	CommunityRoleAdmin     int8 = 3
+)
+
+// 社区的可见性，私有社区的帖子只有成员可见
+const (
+	CommunityVisibilityPublic  = "public"
+	CommunityVisibilityPrivate = "private"
+)
+
+// 社区中谁可以发帖，站点管理员不受限制
+const (
+	CommunityPostEveryone   = "everyone"
+	CommunityPostMembers    = "members"
+	CommunityPostModerators = "moderators"
+)
+
+// 私有社区加入申请的状态
+const (
+	JoinRequestNone     int8 = 0 // 没有申请或邀请
+	JoinRequestPending  int8 = 1
+	JoinRequestInvited  int8 = 2
+	JoinRequestApproved int8 = 3
+	JoinRequestDenied   int8 = 4
+)
+
 type Community struct {
-	ID   int64  `json:"id" db:"community_id"`
-	Name string `json:"name" db:"community_name"`
+	ID         int64  `json:"id" db:"community_id"`
+	Name       string `json:"name" db:"community_name"`
+	Visibility string `json:"visibility" db:"visibility"`
+	IsMember   bool   `json:"is_member" db:"-"` // 当前用户是否已加入
 }
 
 type CommunityDetail struct {
-	ID           int64     `json:"id" db:"community_id"`
-	Name         string    `json:"name" db:"community_name"`
-	Introduction string    `json:"introduction,omitempty" db:"introduction"`
-	CreateTime   time.Time `json:"create_time" db:"create_time"`
+	ID           int64  `json:"id" db:"community_id"`
+	Name         string `json:"name" db:"community_name"`
+	Introduction string `json:"introduction,omitempty" db:"introduction"`
+	Visibility   string `json:"visibility" db:"visibility"`
+	// 版主设置的帖子内容最少字符数，0表示使用站点的默认值
+	MinPostLength int `json:"min_post_length,omitempty" db:"min_post_length"`
+	// 谁可以在社区中发帖，客户端据此隐藏发帖按钮
+	PostPermission string    `json:"post_permission" db:"post_permission"`
+	AllowAnonymous bool      `json:"allow_anonymous" db:"allow_anonymous"` // 是否允许匿名发帖，由版主设置
+	CreateTime     time.Time `json:"create_time" db:"create_time"`
+}
+
+// IsPrivate 缓存中迁移之前的社区没有可见性，视为公开
+func (c *CommunityDetail) IsPrivate() bool {
+	return c.Visibility == CommunityVisibilityPrivate
+}
+
+// RequiredPostRole 发帖需要的社区角色，缓存中迁移之前的社区没有设置，视为所有人都可以发帖
+func (c *CommunityDetail) RequiredPostRole() int8 {
+	switch c.PostPermission {
+	case CommunityPostMembers:
+		return CommunityRoleMember
+	case CommunityPostModerators:
+		return CommunityRoleModerator
+	}
+	return CommunityRoleNone
+}
+
+// CommunityInfo 社区详情接口返回的数据
+type CommunityInfo struct {
+	*CommunityDetail
+	MemberCount        int64 `json:"member_count"`
+	RequiredPostLength int   `json:"required_post_length"` // 在社区中发帖时内容至少多少个字符，0表示不限制
+	*CommunityRules
+}
+
+// ParamCreateCommunity 管理员创建社区，名称的格式和保留名见settings.CommunityConfig
+type ParamCreateCommunity struct {
+	Name         string `json:"name" binding:"required,communityname,notreserved"`
+	Introduction string `json:"introduction" binding:"max=256"`
+	Visibility   string `json:"visibility" binding:"omitempty,oneof=public private"`
+}
+
+// 批量导入社区时每一项的结果
+const (
+	CommunityImportCreated = "created"
+	CommunityImportExists  = "exists"  // 名称(忽略大小写)已经被使用，没有修改
+	CommunityImportInvalid = "invalid" // 没有通过和单个创建相同的校验
+)
+
+// CommunityImportItem 批量导入中一个社区的结果，顺序与请求中的一致
+type CommunityImportItem struct {
+	Name        string            `json:"name"`
+	Status      string            `json:"status"`
+	CommunityID int64             `json:"community_id,omitempty"`
+	Errors      map[string]string `json:"errors,omitempty"` // 校验失败的字段和原因
+}
+
+// CommunityImportResult 批量导入社区的结果
+type CommunityImportResult struct {
+	Created int                    `json:"created"`
+	Exists  int                    `json:"exists"`
+	Invalid int                    `json:"invalid"`
+	Items   []*CommunityImportItem `json:"items"`
+}
+
+// ParamCommunityVisibility 修改社区的可见性
+type ParamCommunityVisibility struct {
+	Visibility string `json:"visibility" binding:"required,oneof=public private"`
+}
+
+// ParamCommunityMinPostLength 版主设置社区中帖子内容的最少字符数，0表示使用站点的默认值
+type ParamCommunityMinPostLength struct {
+	MinPostLength *int `json:"min_post_length" binding:"required,min=0,max=10000"`
+}
+
+// ParamCommunityPostPermission 社区管理员设置谁可以在社区中发帖
+type ParamCommunityPostPermission struct {
+	PostPermission string `json:"post_permission" binding:"required,oneof=everyone members moderators"`
+}
+
+// ParamCommunityAnonymous 版主设置社区是否允许匿名发帖，关闭后已有的匿名帖子仍然匿名
+type ParamCommunityAnonymous struct {
+	AllowAnonymous *bool `json:"allow_anonymous" binding:"required"`
+}
+
+// CommunityRules 版主设置的社区规则和帖子可以使用的flair
+type CommunityRules struct {
+	Rules  string   `json:"rules"`
+	Flairs []string `json:"flairs"`
+}
+
+// ParamCommunityRules 版主修改社区规则，flairs为空时帖子不能带flair
+type ParamCommunityRules struct {
+	Rules  string   `json:"rules" binding:"max=10000"`
+	Flairs []string `json:"flairs" binding:"max=20,unique,dive,notblank,max=32"`
+}
+
+// JoinRequest 私有社区的加入申请
+type JoinRequest struct {
+	CommunityID int64     `json:"community_id" db:"community_id"`
+	UserID      int64     `json:"user_id,string" db:"user_id"`
+	Username    string    `json:"username" db:"username"`
+	Status      int8      `json:"status" db:"status"`
+	CreateTime  time.Time `json:"create_time" db:"create_time"`
+}
+
+// JoinResult 申请加入社区的结果，公开社区和已被邀请时直接加入
+type JoinResult struct {
+	Joined bool `json:"joined"`
+	Status int8 `json:"status"` // 没有直接加入时为申请的状态
+}
+
+// ParamFeaturedOrder 推荐社区的新顺序，必须包含当前推荐的所有社区
+type ParamFeaturedOrder struct {
+	CommunityIDs []int64 `json:"community_ids" binding:"required"`
+}
+
+// TrendingCommunity 最近一段时间内的社区活跃度，新帖子和新成员按配置的权重累加
+type TrendingCommunity struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Activity    int64  `json:"activity"`
+	MemberCount int64  `json:"member_count"`
 }
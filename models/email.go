@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// EmailJob 邮件队列中的一封邮件
+type EmailJob struct {
+	ID         string    `json:"id"`
+	To         string    `json:"to"`
+	Subject    string    `json:"subject"`
+	Body       string    `json:"body"`
+	Attempts   int       `json:"attempts"`             // 已经尝试发送的次数
+	LastError  string    `json:"last_error,omitempty"` // 最近一次发送失败的原因
+	CreateTime time.Time `json:"create_time"`
+}
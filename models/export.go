@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// 数据导出的状态
+const (
+	ExportStatusPending = "pending"
+	ExportStatusReady   = "ready"
+	ExportStatusFailed  = "failed"
+)
+
+// ParamExport CSV为true时在压缩包中额外包含posts.csv和comments.csv
+type ParamExport struct {
+	CSV bool `json:"csv"`
+}
+
+// ExportJob 一次数据导出，保存在redis中，过期后文件也会被删除
+type ExportJob struct {
+	Token      string    `json:"token"`
+	UserID     int64     `json:"user_id"`
+	Status     string    `json:"status"`
+	CSV        bool      `json:"csv"`
+	CreateTime time.Time `json:"create_time"`
+	ExpireTime time.Time `json:"expire_time"`
+}
+
+// ExportProfile 导出的账号信息
+type ExportProfile struct {
+	UserID   int64     `json:"user_id" db:"user_id"`
+	Username string    `json:"username" db:"username"`
+	Email    string    `json:"email" db:"email"`
+	Avatar   string    `json:"avatar" db:"avatar"`
+	JoinTime time.Time `json:"join_time" db:"create_time"`
+}
+
+// UserExport 导出文件data.json的内容
+type UserExport struct {
+	Profile    *ExportProfile `json:"profile"`
+	Posts      []*Post        `json:"posts"`
+	Comments   []*Comment     `json:"comments"`
+	ExportTime time.Time      `json:"export_time"`
+}
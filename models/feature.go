@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// FeatureFlag 功能开关，保存在redis中，所有实例共享
+// Enabled为false时对所有用户关闭，否则对Percent%的用户开启，按用户id的哈希分组，同一个用户的结果不变
+type FeatureFlag struct {
+	Name       string    `json:"name"`
+	Enabled    bool      `json:"enabled"`
+	Percent    int       `json:"percent"`
+	UpdateTime time.Time `json:"update_time"`
+}
+
+// ParamFeatureFlag 修改功能开关
+type ParamFeatureFlag struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+	Percent *int  `json:"percent" binding:"omitempty,min=0,max=100"` // 不传时为100
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// FollowUser 关注/粉丝列表中的一项
+type FollowUser struct {
+	UserID     int64     `json:"user_id" db:"user_id"`
+	Username   string    `json:"username" db:"username"`
+	FollowTime time.Time `json:"follow_time" db:"create_time"`
+}
+
+type FollowCount struct {
+	Followers int64 `json:"followers"`
+	Following int64 `json:"following"`
+}
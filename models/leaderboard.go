@@ -0,0 +1,20 @@
+package models
+
+// 排行榜的统计窗口
+const (
+	LeaderboardAllTime = "all"
+	LeaderboardWeek    = "week" // 最近7天
+)
+
+type LeaderboardEntry struct {
+	Rank     int64  `json:"rank"`
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	Karma    int64  `json:"karma"`
+}
+
+type Leaderboard struct {
+	Window string              `json:"window"`
+	List   []*LeaderboardEntry `json:"list"`
+	Me     *LeaderboardEntry   `json:"me"` // 当前用户的排名，不在榜上时为空
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+const (
+	MaintenanceModeOff      = "off"
+	MaintenanceModeFull     = "full"      // 除了管理员和健康检查，所有接口返回503
+	MaintenanceModeReadOnly = "read_only" // 只允许GET等只读的请求
+)
+
+// Maintenance 维护模式的状态，保存在redis中，所有实例共享
+type Maintenance struct {
+	Mode      string    `json:"mode"`
+	Message   string    `json:"message,omitempty"`
+	StartTime time.Time `json:"start_time"`
+}
+
+// ParamMaintenance 开启或关闭维护模式
+type ParamMaintenance struct {
+	Mode    string `json:"mode" binding:"required,oneof=off full read_only"`
+	Message string `json:"message" binding:"max=200"`
+}
@@ -0,0 +1,8 @@
+package models
+
+// Mention 内容中@username对应的用户，前端按username把文本替换为链接
+// 不存在的用户名不在列表中，按普通文本显示
+type Mention struct {
+	UserID   int64  `json:"user_id" bson:"user_id"`
+	Username string `json:"username" bson:"username"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// MergeToken 在要被合并的账号上生成，登录目标账号后提交以确认合并
+type MergeToken struct {
+	Token      string    `json:"token"`
+	ExpireTime time.Time `json:"expire_time"`
+}
+
+type ParamConfirmMerge struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ParamAdminMerge 管理员把路径中的用户合并到TargetID
+type ParamAdminMerge struct {
+	TargetID int64 `json:"target_id,string" binding:"required"`
+}
+
+// MergeResult 合并完成后来源账号已注销，它的内容都属于目标账号
+type MergeResult struct {
+	FromUserID int64 `json:"from_user_id,string"`
+	ToUserID   int64 `json:"to_user_id,string"`
+	Comments   int64 `json:"comments"` // 这一次转移的评论数，重试时已经转移过的不再计入
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// 审核队列中条目的来源
+const (
+	ModQueueTypeReport   = "report"    // 用户举报的帖子
+	ModQueueTypeAutoFlag = "auto_flag" // 系统检测到刷票的帖子或评论
+	ModQueueTypeHeldPost = "held_post" // 含有垃圾链接被隐藏的帖子
+)
+
+// 审核队列中条目的状态
+const (
+	ModQueueStatusOpen     = "open"
+	ModQueueStatusAssigned = "assigned" // 已经被版主认领，只有认领的版主可以处理
+	ModQueueStatusResolved = "resolved"
+)
+
+// ModQueueItem 审核队列中的条目，同一个目标同一种来源只保留一条未处理的条目
+type ModQueueItem struct {
+	ItemID      int64      `json:"item_id" bson:"item_id"`
+	Type        string     `json:"type" bson:"type"`
+	PostID      int64      `json:"post_id" bson:"post_id"`
+	CommentID   int64      `json:"comment_id,omitempty" bson:"comment_id"` // 目标是评论时不为0
+	CommunityID int64      `json:"community_id" bson:"community_id"`
+	Reason      int8       `json:"reason" bson:"reason"`
+	Detail      string     `json:"detail" bson:"detail"`
+	Count       int64      `json:"count" bson:"count"` // 进入队列的次数，例如举报的人数
+	Status      string     `json:"status" bson:"status"`
+	AssigneeID  int64      `json:"assignee_id,omitempty" bson:"assignee_id,omitempty"`
+	Action      string     `json:"action,omitempty" bson:"action,omitempty"`
+	Note        string     `json:"note,omitempty" bson:"note,omitempty"`
+	AuditID     int64      `json:"audit_id,omitempty" bson:"audit_id,omitempty"` // 处理时记录的审计日志
+	CreateTime  time.Time  `json:"create_time" bson:"create_time"`
+	UpdateTime  time.Time  `json:"update_time" bson:"update_time"`
+	AssignTime  *time.Time `json:"assign_time,omitempty" bson:"assign_time,omitempty"`
+	ResolveTime *time.Time `json:"resolve_time,omitempty" bson:"resolve_time,omitempty"`
+}
@@ -0,0 +1,87 @@
+package models
+
+import "time"
+
+// 通知的类型
+const (
+	NotificationReply  = "reply"  // 评论了帖子或回复了评论，TargetID为评论id
+	NotificationVote   = "vote"   // 给帖子点了赞，TargetID为帖子id
+	NotificationFollow = "follow" // 关注了用户，TargetID为关注者id
+	// 在帖子或评论中@了用户，TargetID分别为帖子id和评论id
+	NotificationMentionPost    = "mention_post"
+	NotificationMentionComment = "mention_comment"
+	// 关注的标签中有帖子变热门，TargetID为帖子id，ActorID为作者id，匿名帖子为0
+	NotificationTrendingTag = "trending_tag"
+)
+
+type Notification struct {
+	NotificationID int64     `json:"notification_id" bson:"notification_id"`
+	RecipientID    int64     `json:"recipient_id" bson:"recipient_id"`
+	Type           string    `json:"type" bson:"type"`
+	ActorID        int64     `json:"actor_id" bson:"actor_id"`
+	TargetID       int64     `json:"target_id" bson:"target_id"`
+	Read           bool      `json:"read" bson:"read"`
+	CreateTime     time.Time `json:"create_time" bson:"create_time"`
+}
+
+// UnreadCount 未读通知数
+type UnreadCount struct {
+	Unread int64 `json:"unread"`
+}
+
+// NotificationPref 用户的通知设置，false表示不接收该类型的通知
+type NotificationPref struct {
+	Reply   bool `json:"reply" db:"reply"`
+	Vote    bool `json:"vote" db:"vote"`
+	Follow  bool `json:"follow" db:"follow"`
+	Mention bool `json:"mention" db:"mention"`
+	Digest  bool `json:"digest" db:"digest"` // 每天的未读通知摘要邮件
+	// Trending 关注的标签中有帖子变热门
+	Trending bool `json:"trending" db:"trending"`
+	// 免打扰时段，按QuietTimezone计算，QuietStart为空表示不启用
+	// 免打扰期间仍然会创建通知，推送和邮件在结束后再发送
+	QuietStart    string `json:"quiet_start" db:"quiet_start"`
+	QuietEnd      string `json:"quiet_end" db:"quiet_end"`
+	QuietTimezone string `json:"quiet_timezone" db:"quiet_timezone"`
+}
+
+// DefaultNotificationPref 没有修改过设置的用户接收所有通知
+func DefaultNotificationPref() *NotificationPref {
+	return &NotificationPref{Reply: true, Vote: true, Follow: true, Mention: true, Digest: true, Trending: true}
+}
+
+// Allows 是否接收typ类型的通知，未知的类型总是接收
+func (p *NotificationPref) Allows(typ string) bool {
+	switch typ {
+	case NotificationReply:
+		return p.Reply
+	case NotificationVote:
+		return p.Vote
+	case NotificationFollow:
+		return p.Follow
+	case NotificationMentionPost, NotificationMentionComment:
+		return p.Mention
+	case NotificationTrendingTag:
+		return p.Trending
+	}
+	return true
+}
+
+// ParamNotificationPref 修改通知设置的参数，没有传的字段保持不变
+type ParamNotificationPref struct {
+	Reply    *bool `json:"reply"`
+	Vote     *bool `json:"vote"`
+	Follow   *bool `json:"follow"`
+	Mention  *bool `json:"mention"`
+	Digest   *bool `json:"digest"`
+	Trending *bool `json:"trending"`
+	// QuietHours 传了时覆盖免打扰时段，start为空表示关闭
+	QuietHours *ParamQuietHours `json:"quiet_hours"`
+}
+
+// ParamQuietHours 免打扰时段，start和end为HH:MM，end早于start表示跨过午夜
+type ParamQuietHours struct {
+	Start    string `json:"start" binding:"required_with=End,omitempty,clock"`
+	End      string `json:"end" binding:"required_with=Start,omitempty,clock,nefield=Start"`
+	Timezone string `json:"timezone" binding:"required_with=Start,omitempty,tzname"`
+}
@@ -1,16 +1,21 @@
 package models
 
+import "time"
+
 // use to define signup parameters
 type ParamSignUp struct {
-	Username   string `json:"username" binding:"required"`
-	Password   string `json:"password" binding:"required"`
-	RePassword string `json:"re_password" binding:"required,eqfield=Password"`
+	Username     string `json:"username" binding:"required"`
+	Password     string `json:"password" binding:"required"`
+	RePassword   string `json:"re_password" binding:"required,eqfield=Password"`
+	Email        string `json:"email" binding:"required,email,rutgersemail"`
+	CaptchaToken string `json:"captcha_token"` // 开启人机验证时必须提供，见captcha配置
 }
 
 // use to define login parameters
 type ParamLogin struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	Remember bool   `json:"remember"` // 使用更长的refresh token有效期，见auth.remember_expire
 }
 
 type ParamVoteData struct {
@@ -18,14 +23,131 @@ type ParamVoteData struct {
 	Direction int8   `json:"direction,string" binding:"oneof=-1 0 1"` // agree or disagree or neither disagree or agree
 }
 
+// ParamCommentVote 给评论投票，评论id在路径中
+type ParamCommentVote struct {
+	Direction int8 `json:"direction,string" binding:"oneof=-1 0 1"`
+}
+
+// ParamReport 举报帖子，reason见models.ReportReasonSpam等
+type ParamReport struct {
+	Reason int8   `json:"reason" binding:"required,oneof=1 2 3 4"`
+	Detail string `json:"detail" binding:"max=500"`
+}
+
+// ParamResolveReport 版主处理帖子的举报
+type ParamResolveReport struct {
+	Action string `json:"action" binding:"required,oneof=dismiss remove"`
+}
+
+// ParamModQueue 版主查看审核队列，status为空时返回未处理的条目
+type ParamModQueue struct {
+	Status string `json:"status" form:"status" binding:"omitempty,oneof=open assigned resolved"`
+	Mine   bool   `json:"mine" form:"mine"` // 只看自己认领的条目
+}
+
+// ParamResolveModQueue 版主处理认领的条目，note记录处理的说明
+type ParamResolveModQueue struct {
+	Action string `json:"action" binding:"required,oneof=dismiss remove"`
+	Note   string `json:"note" binding:"max=500"`
+}
+
 type ParamPostList struct {
 	CommunityID int64  `json:"community_id" form:"community_id"`
 	Page        int64  `json:"page" form:"page"`
 	Size        int64  `json:"size" form:"size"`
 	Order       string `json:"order" form:"order"`
+	Flair       string `json:"flair" form:"flair" binding:"omitempty,max=32"` // 只在社区的帖子列表中使用
 }
 
 const (
 	OrderTime  = "time"
 	OrderScore = "score"
+	OrderHot   = "hot"
+	// OrderControversial 赞成和反对票都多的帖子在前，票数太少的帖子不出现在列表中
+	OrderControversial = "controversial"
 )
+
+type ParamRefreshToken struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type ParamForgotPassword struct {
+	Email        string `json:"email" binding:"required,email"`
+	CaptchaToken string `json:"captcha_token"`
+}
+
+type ParamResendVerification struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ParamChangeUsername 新用户名的规则与注册时相同
+type ParamChangeUsername struct {
+	Username string `json:"username" binding:"required"`
+}
+
+type ParamReactivate struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ParamConfirmReactivate struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ParamResetPassword struct {
+	Token      string `json:"token" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+	RePassword string `json:"re_password" binding:"required,eqfield=Password"`
+}
+
+type ParamCreateComment struct {
+	PostID   int64  `json:"post_id" binding:"required"`
+	ParentID int64  `json:"parent_id"`
+	Content  string `json:"content" binding:"required,notblank,textlen=comment"`
+}
+
+// ParamUpdateComment 作者编辑评论
+type ParamUpdateComment struct {
+	Content string `json:"content" binding:"required,notblank,textlen=comment"`
+}
+
+type ParamSearch struct {
+	Query string `json:"q" form:"q" binding:"required,max=128"`
+	Type  string `json:"type" form:"type" binding:"omitempty,oneof=posts comments users all"` // 为空时只搜索帖子，返回帖子的分页列表
+	Page  int64  `json:"page" form:"page"`
+	Size  int64  `json:"size" form:"size"`
+}
+
+// ParamLeaderboard window见models.LeaderboardAllTime等
+type ParamLeaderboard struct {
+	Window string `json:"window" form:"window" binding:"omitempty,oneof=all week"`
+	Size   int64  `json:"size" form:"size"`
+}
+
+type ParamUpdatePost struct {
+	Title        string  `json:"title" binding:"required,notblank,textlen=title"`
+	Content      string  `json:"content" binding:"required,notblank,textlen=post"`
+	CommunityIDs []int64 `json:"community_ids" binding:"omitempty,dive,gt=0"` // 不为空时替换帖子所属的社区，第一个作为主社区
+	Version      int64   `json:"version" binding:"omitempty,gt=0"`            // 编辑前读到的版本，与当前版本不一致时不更新，为0时不检查
+}
+
+// ParamMarkRead ids为空时把所有通知标记为已读
+type ParamMarkRead struct {
+	IDs []int64 `json:"ids"`
+}
+
+type ParamFeed struct {
+	Cursor int64     `json:"cursor" form:"cursor"`                                         // 上一页最后一个帖子的id
+	Before time.Time `json:"before" form:"before" time_format:"2006-01-02T15:04:05Z07:00"` // 没有cursor时，只返回这个时间之前的帖子
+	Size   int64     `json:"size" form:"size"`
+}
+
+// ParamAuditQuery 查询审计日志，条件为空时不过滤
+type ParamAuditQuery struct {
+	ActorID int64     `json:"actor" form:"actor"`
+	Action  string    `json:"action" form:"action"`
+	AuditID int64     `json:"audit_id" form:"audit_id"`
+	From    time.Time `json:"from" form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To      time.Time `json:"to" form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Page    int64     `json:"page" form:"page"`
+	Size    int64     `json:"size" form:"size"`
+}
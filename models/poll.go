@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Poll 帖子附带的投票，创建帖子时一起创建，之后不能修改
+type Poll struct {
+	PostID     int64      `json:"post_id" bson:"post_id"`
+	Question   string     `json:"question" bson:"question"`
+	Options    []string   `json:"options" bson:"options"`
+	ExpireAt   *time.Time `json:"expire_at,omitempty" bson:"expire_at,omitempty"` // 为空表示不会截止
+	CreateTime time.Time  `json:"create_time" bson:"create_time"`
+}
+
+// ParamPoll 创建帖子时附带的投票，选项数的上限见post.poll_max_options
+type ParamPoll struct {
+	Question string     `json:"question" binding:"required,notblank,max=200"`
+	Options  []string   `json:"options" binding:"required,min=2,dive,required,notblank,max=100"`
+	ExpireAt *time.Time `json:"expire_at"`
+}
+
+// ParamPollVote 选项的下标，从0开始
+type ParamPollVote struct {
+	Option *int `json:"option" binding:"required,gte=0"`
+}
+
+// PollResult 投票的当前结果，截止之后仍然可以查看
+type PollResult struct {
+	Question   string        `json:"question"`
+	Options    []*PollOption `json:"options"`
+	TotalVotes int64         `json:"total_votes"`
+	ExpireAt   *time.Time    `json:"expire_at,omitempty"`
+	Closed     bool          `json:"closed"`
+	UserChoice *int          `json:"user_choice,omitempty"` // 当前用户选择的选项，未投票或未登录时为空
+}
+
+type PollOption struct {
+	Text  string `json:"text"`
+	Votes int64  `json:"votes"`
+}
@@ -2,20 +2,140 @@ package models
 
 import "time"
 
+// 帖子状态，隐藏的帖子不出现在列表中，等待版主处理
+const (
+	PostStatusNormal    int32 = 1
+	PostStatusHidden    int32 = 2
+	PostStatusScheduled int32 = 3 // 定时发布，到publish_at之后才出现在列表中
+	PostStatusDraft     int32 = 4 // 草稿，只有作者可见，发布时才加入列表
+)
+
 // Memory alignment
 type Post struct {
-	PostID      int64     `json:"post_id" db:"post_id"`
-	AuthorId    int64     `json:"author_id" db:"author_id"`
-	CommunityID int64     `json:"community_id" db:"community_id" binding:"required"`
-	Status      int32     `json:"status" db:"status"`
-	Title       string    `json:"title" db:"title" binding:"required"`
-	Content     string    `json:"content" db:"content" binding:"required"`
-	CreateTime  time.Time `json:"create_time" db:"create_time"`
+	PostID        int64   `json:"post_id" db:"post_id"`
+	AuthorId      int64   `json:"author_id" db:"author_id"`
+	Anonymous     bool    `json:"anonymous,omitempty" db:"anonymous"`                           // 匿名发布，公开返回时作者为空，只能发布到允许匿名的社区
+	CommunityID   int64   `json:"community_id" db:"community_id"`                               // 主社区
+	CommunityIDs  []int64 `json:"community_ids,omitempty" db:"-" binding:"omitempty,dive,gt=0"` // 同时发布到的所有社区，包含主社区
+	Flair         string  `json:"flair,omitempty" db:"flair" binding:"omitempty,max=32"`        // 必须是主社区允许的flair之一
+	Status        int32   `json:"status" db:"status"`
+	CommentLocked bool    `json:"comment_locked" db:"comment_locked"` // 版主锁定后不能再发表评论
+	// CommentUnlocked 版主手动解锁过，超过comment.close_after也可以评论
+	CommentUnlocked bool       `json:"-" db:"comment_unlocked"`
+	Version         int64      `json:"version,omitempty" db:"version"` // 每次编辑加1，编辑时用于检测冲突
+	Title           string     `json:"title" db:"title" binding:"required,notblank,textlen=title"`
+	Slug            string     `json:"slug,omitempty" db:"slug"`                                                // 由标题生成，编辑标题时默认不变
+	Content         string     `json:"content,omitempty" db:"content" binding:"required,notblank,textlen=post"` // 原始的markdown，列表中不返回
+	Tags            []string   `json:"tags,omitempty" db:"-"`                                                   // 数量上限见post.max_tags
+	PublishAt       *time.Time `json:"publish_at,omitempty" db:"publish_at"`                                    // 定时发布的时间，为空表示立即发布
+	Poll            *ParamPoll `json:"poll,omitempty" db:"-"`                                                   // 只在创建时使用，详情中的投票结果见PostDetail.Poll
+	DuplicateOf     int64      `json:"-" db:"-"`                                                                // 创建时发现的其他用户最近发布的相同内容的帖子
+	CreateTime      time.Time  `json:"create_time" db:"create_time"`
+}
+
+// PostRevision 帖子被编辑前的一个版本
+type PostRevision struct {
+	PostID   int64     `json:"post_id" bson:"post_id"`
+	Title    string    `json:"title" bson:"title"`
+	Content  string    `json:"content" bson:"content"`
+	EditorID int64     `json:"editor_id" bson:"editor_id"`
+	EditTime time.Time `json:"edit_time" bson:"edit_time"`
+}
+
+// PostRender 帖子markdown渲染的结果，创建和编辑帖子时更新
+type PostRender struct {
+	PostID     int64        `json:"post_id" bson:"post_id"`
+	HTML       string       `json:"html" bson:"html"` // 已经过滤掉脚本和危险属性
+	Excerpt    string       `json:"excerpt" bson:"excerpt"`
+	Preview    *PostPreview `json:"preview,omitempty" bson:"preview,omitempty"`
+	Mentions   []*Mention   `json:"mentions,omitempty" bson:"mentions,omitempty"`
+	UpdateTime time.Time    `json:"update_time" bson:"update_time"`
+}
+
+// 帖子预览的类型
+const (
+	PreviewTypeImage = "image"
+	PreviewTypeLink  = "link"
+)
+
+// PostPreview 列表中的缩略图或链接卡片，取内容中的第一张图片，没有图片时取第一个外部链接
+// 链接的标题和图片来自目标网页的OpenGraph信息，抓取失败时为空
+type PostPreview struct {
+	Type  string `json:"type" bson:"type"`
+	URL   string `json:"url" bson:"url"`
+	Title string `json:"title,omitempty" bson:"title,omitempty"`
+	Image string `json:"image,omitempty" bson:"image,omitempty"`
+}
+
+// PostFeed 基于游标分页的帖子列表，NextCursor为空表示没有更多数据
+type PostFeed struct {
+	List       []*PostDetail `json:"list"`
+	NextCursor string        `json:"next_cursor"`
 }
 
 type PostDetail struct {
-	AuthorName string `json:"author_name"`
-	VoteNum    int64  `json:"vote_num"`
+	AuthorName string       `json:"author_name"`
+	VoteNum    int64        `json:"vote_num"`
+	IsSaved    bool         `json:"is_saved"`            // 当前用户是否已收藏
+	UserVote   *int8        `json:"user_vote,omitempty"` // 当前用户的投票，1赞成，-1反对，0未投票，只在列表中返回
+	Excerpt    string       `json:"excerpt"`             // 纯文本摘要，列表中代替content
+	HTML       string       `json:"html,omitempty"`      // 渲染后的内容，只在详情中返回
+	Preview    *PostPreview `json:"preview,omitempty"`
+	Mentions   []*Mention   `json:"mentions,omitempty"` // 内容中@到的用户，只在详情中返回
+	Pinned     bool         `json:"pinned,omitempty"`   // 在社区中置顶，只在社区的帖子列表中返回
+	Poll       *PollResult  `json:"poll,omitempty"`     // 帖子附带的投票，只在详情中返回
+	// Highlight 搜索结果中包含关键词的片段，关键词用<mark>标出，其余内容已经转义，没有匹配时为转义后的摘要
+	Highlight string `json:"highlight,omitempty"`
+	// CommentsLocked 当前是否可以发表评论，包括版主锁定和超过comment.close_after自动关闭，只在详情中返回
+	CommentsLocked bool `json:"comments_locked"`
+	// ContentHash 不包含投票数和收藏状态的内容哈希，用于生成ETag
+	ContentHash string `json:"-"`
 	*Post
 	*CommunityDetail `json:"community"`
 }
+
+// PostFull 帖子详情和第一页的顶层评论，每个顶层评论只带最早的几条直接回复
+// 其余的回复数见ReplyCount，HasMoreComments为true时通过评论列表接口继续加载
+type PostFull struct {
+	Post            *PostDetail    `json:"post"`
+	Comments        []*CommentNode `json:"comments"`
+	CommentTotal    int64          `json:"comment_total"` // 顶层评论数
+	HasMoreComments bool           `json:"has_more_comments"`
+}
+
+// PostDetailV2 v2接口的帖子详情，id使用字符串避免前端丢失精度，作者和社区为嵌套的对象
+type PostDetailV2 struct {
+	PostID         int64        `json:"post_id,string"`
+	Title          string       `json:"title"`
+	Content        string       `json:"content"`
+	HTML           string       `json:"html"`
+	Excerpt        string       `json:"excerpt"`
+	Preview        *PostPreview `json:"preview,omitempty"`
+	Tags           []string     `json:"tags"`
+	VoteNum        int64        `json:"vote_num"`
+	IsSaved        bool         `json:"is_saved"`
+	CommentLocked  bool         `json:"comment_locked"`
+	CommentsLocked bool         `json:"comments_locked"`
+	Poll           *PollResult  `json:"poll,omitempty"`
+	Version        int64        `json:"version"`
+	Author         *AuthorV2    `json:"author"`
+	Community      *CommunityV2 `json:"community"`
+	CreateTime     time.Time    `json:"create_time"`
+}
+
+type AuthorV2 struct {
+	UserID   int64  `json:"user_id,string"`
+	Username string `json:"username"`
+}
+
+type CommunityV2 struct {
+	ID   int64  `json:"id,string"`
+	Name string `json:"name"`
+}
+
+// PostScore 数据库中保存的帖子排序分数，用于重建redis中的排序
+type PostScore struct {
+	PostID      int64     `db:"post_id"`
+	Score       float64   `db:"score"`        // 还没有写回过时为0
+	PublishTime time.Time `db:"publish_time"` // 定时发布的帖子为发布时间，否则为创建时间
+}
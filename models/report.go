@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// 举报原因
+const (
+	ReportReasonSpam          int8 = 1 + iota // 广告、刷屏
+	ReportReasonHarassment                    // 骚扰、人身攻击
+	ReportReasonInappropriate                 // 违规或不适宜的内容
+	ReportReasonOther                         // 其他，需要在detail中说明
+	ReportReasonVoteBrigading                 // 系统检测到的刷票，用户不能提交
+)
+
+// 举报的处理状态
+const (
+	ReportStatusOpen      = "open"
+	ReportStatusDismissed = "dismissed" // 举报不成立，帖子恢复显示
+	ReportStatusActioned  = "actioned"  // 帖子已被删除
+)
+
+// 版主处理举报的方式
+const (
+	ReportActionDismiss = "dismiss"
+	ReportActionRemove  = "remove"
+)
+
+type Report struct {
+	ReportID    int64      `json:"report_id" bson:"report_id"`
+	PostID      int64      `json:"post_id" bson:"post_id"`
+	CommentID   int64      `json:"comment_id,omitempty" bson:"comment_id,omitempty"` // 举报的是帖子下的评论时不为0
+	CommunityID int64      `json:"community_id" bson:"community_id"`
+	ReporterID  int64      `json:"reporter_id" bson:"reporter_id"` // 系统提交的举报为0
+	Reason      int8       `json:"reason" bson:"reason"`
+	Detail      string     `json:"detail" bson:"detail"`
+	Domain      string     `json:"domain,omitempty" bson:"domain,omitempty"` // 系统检测到的垃圾链接的域名
+	Status      string     `json:"status" bson:"status"`
+	HandlerID   int64      `json:"handler_id,omitempty" bson:"handler_id,omitempty"`
+	CreateTime  time.Time  `json:"create_time" bson:"create_time"`
+	HandleTime  *time.Time `json:"handle_time,omitempty" bson:"handle_time,omitempty"`
+}
+
+// ReportSummary 一个帖子下所有待处理的举报
+type ReportSummary struct {
+	PostID         int64     `json:"post_id" bson:"_id"`
+	CommunityID    int64     `json:"community_id" bson:"community_id"`
+	Title          string    `json:"title" bson:"-"`
+	Hidden         bool      `json:"hidden" bson:"-"` // 举报数达到阈值后帖子被自动隐藏
+	Count          int64     `json:"count" bson:"count"`
+	Reasons        []int8    `json:"reasons" bson:"reasons"`
+	Domains        []string  `json:"domains,omitempty" bson:"domains"` // 系统检测到的垃圾链接的域名
+	LastReportTime time.Time `json:"last_report_time" bson:"last_report_time"`
+}
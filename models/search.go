@@ -0,0 +1,16 @@
+package models
+
+// 全局搜索的类型
+const (
+	SearchTypePosts    = "posts"
+	SearchTypeComments = "comments"
+	SearchTypeUsers    = "users"
+	SearchTypeAll      = "all" // 每种类型各返回前search.all_size条
+)
+
+// CommentSearchResult 搜索结果中的评论，Highlight的格式同PostDetail.Highlight
+type CommentSearchResult struct {
+	*Comment
+	PostTitle string `json:"post_title"`
+	Highlight string `json:"highlight,omitempty"`
+}
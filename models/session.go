@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Session 一次登录的会话，刷新token时更新LastSeen、IP和UserAgent
+type Session struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreateTime time.Time `json:"create_time"`
+	LastSeen   time.Time `json:"last_seen"`
+	ExpireTime time.Time `json:"expire_time"`
+	Current    bool      `json:"current"` // 是否为发起请求的会话
+}
+
+// ClientInfo 登录和刷新token的客户端，记录在会话中
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
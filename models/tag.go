@@ -0,0 +1,19 @@
+package models
+
+// Tag 标签及其下的帖子数
+type Tag struct {
+	Name      string `json:"name"`
+	PostCount int64  `json:"post_count"`
+}
+
+// TrendingTag 最近一段时间内的标签活跃次数，新帖子和投票各计一次
+type TrendingTag struct {
+	Name     string `json:"name"`
+	Activity int64  `json:"activity"`
+}
+
+// TagFollow 用户关注的一个标签
+type TagFollow struct {
+	UserID int64  `db:"user_id"`
+	Tag    string `db:"tag"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// UserTOTP 用户的两步验证设置，Secret已经解密
+type UserTOTP struct {
+	UserID  int64
+	Secret  string
+	Enabled bool
+}
+
+// TwoFactorSetup 生成的密钥，URI用于生成验证器应用扫描的二维码
+type TwoFactorSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// TwoFactorStatus 当前用户的两步验证状态
+type TwoFactorStatus struct {
+	Enabled       bool `json:"enabled"`
+	RecoveryCodes int  `json:"recovery_codes"` // 剩余可用的恢复码数量
+}
+
+// TwoFactorChallenge 开启了两步验证的账号密码正确时返回的临时token，提交验证码后换取正式的token
+type TwoFactorChallenge struct {
+	Token      string    `json:"two_factor_token"`
+	ExpireTime time.Time `json:"expire_time"`
+}
+
+// RecoveryCodes 新生成的恢复码，只在生成时返回一次
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
+
+// ParamTwoFactorCode 验证器应用中的6位验证码或一个恢复码
+type ParamTwoFactorCode struct {
+	Code string `json:"code" binding:"required,max=32"`
+}
+
+type ParamTwoFactorLogin struct {
+	Token string `json:"two_factor_token" binding:"required"`
+	Code  string `json:"code" binding:"required,max=32"`
+}
@@ -1,8 +1,82 @@
 package models
 
+import "time"
+
+const (
+	RoleUser  int8 = 0
+	RoleAdmin int8 = 1
+)
+
+// 第三方登录的提供方
+const OAuthProviderGoogle = "google"
+
+// DeactivatedUserName 已注销用户的内容显示的作者名
+const DeactivatedUserName = "[deactivated user]"
+
+// AnonymousUserName 匿名帖子显示的作者名
+const AnonymousUserName = "[anonymous]"
+
 type User struct {
-	UserID   int64  `db:"user_id"`
-	Username string `db:"username"`
-	Password string `db:"password"`
-	//Email    string `db:"email"`
+	UserID        int64  `json:"user_id" db:"user_id"`
+	Username      string `json:"username" db:"username"`
+	Password      string `json:"-" db:"password"`
+	Email         string `json:"email" db:"email"`
+	EmailVerified bool   `json:"email_verified" db:"email_verified"`
+	Role          int8   `json:"role" db:"role"`
+	Deactivated   bool   `json:"-" db:"deactivated"`
+	Banned        bool   `json:"-" db:"banned"`
+}
+
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// OAuthUser 第三方登录返回的用户信息
+type OAuthUser struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// UserProfile 用户的公开主页，不包含邮箱等私人信息
+type UserProfile struct {
+	UserID       int64             `json:"user_id" db:"user_id"`
+	Username     string            `json:"username" db:"username"`
+	Avatar       string            `json:"avatar" db:"avatar"`
+	JoinTime     time.Time         `json:"join_time" db:"create_time"`
+	Deactivated  bool              `json:"-" db:"deactivated"`
+	Stats        *UserStats        `json:"stats"`
+	Relationship *UserRelationship `json:"relationship,omitempty"` // 查看自己的主页时为空
+}
+
+// AuthorProfile 评论列表中作者的公开信息，会缓存在redis中，已注销的用户只保留id
+type AuthorProfile struct {
+	UserID      int64  `json:"user_id" db:"user_id"`
+	Username    string `json:"username" db:"username"`
+	Avatar      string `json:"avatar" db:"avatar"`
+	Karma       int64  `json:"karma" db:"karma"`
+	Deactivated bool   `json:"-" db:"deactivated"`
+}
+
+// ParamUserBatch 按id批量查询用户的公开信息，数量上限见pagination.max_batch_ids
+type ParamUserBatch struct {
+	IDs []int64 `json:"ids" binding:"required,min=1,dive,gt=0"`
+}
+
+// UserStats 用户主页上的统计数据
+type UserStats struct {
+	Posts     int64 `json:"posts"`
+	Comments  int64 `json:"comments"`
+	Karma     int64 `json:"karma"` // 声望，帖子和评论被投票时变化
+	Followers int64 `json:"followers"`
+	Following int64 `json:"following"`
+}
+
+// UserRelationship 当前用户与主页用户的关系
+type UserRelationship struct {
+	Following bool `json:"following"`
+	Blocked   bool `json:"blocked"`
 }
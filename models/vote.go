@@ -0,0 +1,34 @@
+package models
+
+// 投票记录的方向，用于查询自己的投票记录
+const (
+	VoteDirUp   = "up"
+	VoteDirDown = "down"
+)
+
+// ParamUserVotes 查询自己投过票的帖子，dir为空时查询赞成的帖子
+type ParamUserVotes struct {
+	Dir string `form:"dir" binding:"omitempty,oneof=up down"`
+}
+
+// ParamUserPosts 查询用户发布的帖子，order为空时按时间排序，include_drafts只对作者本人有效
+type ParamUserPosts struct {
+	Order         string `form:"order" binding:"omitempty,oneof=time score"`
+	IncludeDrafts bool   `form:"include_drafts"`
+}
+
+// VoteBucket 一组投票者的赞成和反对票数
+type VoteBucket struct {
+	Label string `json:"label"`
+	Up    int64  `json:"up"`
+	Down  int64  `json:"down"`
+}
+
+// PostVoteStats 帖子投票的汇总，供版主判断是否有刷票，不包含投票者的身份
+type PostVoteStats struct {
+	PostID       int64         `json:"post_id,string"`
+	Up           int64         `json:"up"`
+	Down         int64         `json:"down"`
+	ByAccountAge []*VoteBucket `json:"by_account_age"` // 按投票者的注册时间分组
+	ByKarma      []*VoteBucket `json:"by_karma"`       // 按投票者的声望分组
+}
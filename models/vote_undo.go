@@ -0,0 +1,14 @@
+package models
+
+// VoteUndo 投票之后撤销窗口内保存的状态，撤销时恢复之前的投票，之前没有投票时OldValue为0
+type VoteUndo struct {
+	OldValue    float64 `json:"old_value"`
+	OldWeight   float64 `json:"old_weight"` // 之前那一票计入排序分数的权重，评论没有权重
+	NewValue    float64 `json:"new_value"`
+	KarmaWeight int64   `json:"karma_weight"` // 这一票计入作者声望的权重，刷票标记期间为0
+}
+
+// ParamUndoVote 撤销对帖子的投票
+type ParamUndoVote struct {
+	PostId string `json:"post_id" binding:"required"`
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// webhook的事件类型
+const (
+	WebhookEventPostCreated = "post.created"
+	WebhookEventPing        = "ping" // 版主测试webhook时发送
+)
+
+// Webhook 社区的webhook，密钥不会返回给客户端
+type Webhook struct {
+	WebhookID   int64     `json:"webhook_id,string" db:"webhook_id"`
+	CommunityID int64     `json:"community_id" db:"community_id"`
+	URL         string    `json:"url" db:"url"`
+	Secret      string    `json:"-" db:"secret"`
+	HasSecret   bool      `json:"has_secret" db:"-"`
+	CreatorID   int64     `json:"creator_id,string" db:"creator_id"`
+	CreateTime  time.Time `json:"create_time" db:"create_time"`
+}
+
+// ParamWebhook 添加webhook，secret为空时不签名
+type ParamWebhook struct {
+	URL    string `json:"url" binding:"required,url,max=512"`
+	Secret string `json:"secret" binding:"max=128"`
+}
+
+// WebhookJob 发送队列中的一次通知，发送时按WebhookID重新读取地址和密钥，webhook被删除后不再发送
+type WebhookJob struct {
+	ID         string          `json:"id"` // 重试时不变，接收方可以用来去重
+	WebhookID  int64           `json:"webhook_id"`
+	Event      string          `json:"event"`
+	Payload    json.RawMessage `json:"payload"`
+	Attempts   int             `json:"attempts"`
+	LastError  string          `json:"last_error,omitempty"`
+	CreateTime time.Time       `json:"create_time"`
+}
+
+// WebhookEvent 发送的请求体，text和content分别是Slack和Discord的webhook直接显示的内容
+type WebhookEvent struct {
+	Event     string              `json:"event"`
+	Text      string              `json:"text"`
+	Content   string              `json:"content"`
+	Community *WebhookCommunity   `json:"community"`
+	Post      *WebhookPostSummary `json:"post,omitempty"`
+	Time      time.Time           `json:"time"`
+}
+
+type WebhookCommunity struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// WebhookPostSummary 新帖子的摘要
+type WebhookPostSummary struct {
+	PostID     int64     `json:"post_id,string"`
+	Title      string    `json:"title"`
+	Excerpt    string    `json:"excerpt"`
+	Flair      string    `json:"flair,omitempty"`
+	AuthorName string    `json:"author_name"`
+	URL        string    `json:"url"`
+	CreateTime time.Time `json:"create_time"`
+}
+
+// WebhookTestResult 测试webhook的结果，Status为接收方返回的状态码，连接失败时为0
+type WebhookTestResult struct {
+	OK     bool   `json:"ok"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
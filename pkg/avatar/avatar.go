@@ -0,0 +1,98 @@
+package avatar
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const maxPixels = 40 * 1000 * 1000 // 防止解码超大尺寸图片耗尽内存
+
+var (
+	ErrUnsupportedFormat = errors.New("avatar must be a JPEG or PNG image")
+	ErrImageTooLarge     = errors.New("avatar dimensions are too large")
+)
+
+// Process 校验图片格式，居中裁剪成正方形并缩放到size*size。
+// 重新编码后的图片不包含EXIF等元数据。返回编码后的数据和文件扩展名。
+func Process(data []byte, size int) (out []byte, ext string, err error) {
+	// 根据文件头判断格式，而不是文件扩展名
+	contentType := http.DetectContentType(data)
+	if contentType != "image/jpeg" && contentType != "image/png" {
+		return nil, "", ErrUnsupportedFormat
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrUnsupportedFormat
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, "", ErrImageTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrUnsupportedFormat
+	}
+	thumb := squareThumbnail(img, size)
+
+	buf := new(bytes.Buffer)
+	if contentType == "image/png" {
+		err = png.Encode(buf, thumb)
+		ext = ".png"
+	} else {
+		err = jpeg.Encode(buf, thumb, &jpeg.Options{Quality: 90})
+		ext = ".jpg"
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), ext, nil
+}
+
+// squareThumbnail 居中裁剪后用区域平均的方式缩放
+func squareThumbnail(img image.Image, size int) *image.NRGBA {
+	b := img.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		sy0 := y0 + y*side/size
+		sy1 := y0 + (y+1)*side/size
+		if sy1 <= sy0 {
+			sy1 = sy0 + 1
+		}
+		for x := 0; x < size; x++ {
+			sx0 := x0 + x*side/size
+			sx1 := x0 + (x+1)*side/size
+			if sx1 <= sx0 {
+				sx1 = sx0 + 1
+			}
+			var r, g, bl, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					c := color.NRGBA64Model.Convert(img.At(sx, sy)).(color.NRGBA64)
+					r += uint64(c.R)
+					g += uint64(c.G)
+					bl += uint64(c.B)
+					a += uint64(c.A)
+					n++
+				}
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(bl / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
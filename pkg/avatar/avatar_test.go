@@ -0,0 +1,40 @@
+package avatar
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcess(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 300, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 300; x++ {
+			src.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, src); err != nil {
+		t.Fatal(err)
+	}
+
+	out, ext, err := Process(buf.Bytes(), 64)
+	if err != nil {
+		t.Fatalf("Process failed, err:%v\n", err)
+	}
+	assert.Equal(t, ".png", ext)
+	img, err := png.Decode(bytes.NewReader(out))
+	assert.Nil(t, err)
+	assert.Equal(t, image.Rect(0, 0, 64, 64), img.Bounds())
+	r, _, _, a := img.At(10, 10).RGBA()
+	assert.Equal(t, uint32(0xffff), r)
+	assert.Equal(t, uint32(0xffff), a)
+
+	// 扩展名伪装成图片的文本文件
+	_, _, err = Process([]byte("definitely not an image"), 64)
+	assert.Equal(t, ErrUnsupportedFormat, err)
+}
@@ -0,0 +1,98 @@
+// Package breaker 熔断器，依赖的服务连续失败达到阈值后短时间内直接拒绝请求，之后放行一个请求试探是否恢复
+// 实现了go-redis的Limiter接口，也可以单独使用
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrOpen = errors.New("circuit breaker is open")
+
+type State int
+
+const (
+	StateClosed   State = iota // 正常
+	StateOpen                  // 熔断中，直接拒绝
+	StateHalfOpen              // 熔断时间已过，放行一个请求试探
+)
+
+func (s State) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half_open"
+	}
+	return "closed"
+}
+
+type Breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	isFailure func(error) bool
+	now       func() time.Time
+
+	state    State
+	failures int       // 连续失败的次数
+	openedAt time.Time // 最近一次熔断的时间
+	probing  bool      // 半开状态下试探的请求还没有结果
+}
+
+// New 连续失败threshold次后熔断cooldown，threshold<=0时不熔断
+// isFailure判断哪些错误说明服务不可用，为nil时所有错误都算失败
+func New(threshold int, cooldown time.Duration, isFailure func(error) bool) *Breaker {
+	if isFailure == nil {
+		isFailure = func(error) bool { return true }
+	}
+	return &Breaker{threshold: threshold, cooldown: cooldown, isFailure: isFailure, now: time.Now}
+}
+
+// Allow 返回nil时调用方必须用ReportResult报告结果
+func (b *Breaker) Allow() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return ErrOpen
+		}
+		b.state = StateHalfOpen
+		b.probing = true
+		return nil
+	case StateHalfOpen:
+		if b.probing {
+			return ErrOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+func (b *Breaker) ReportResult(err error) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil || !b.isFailure(err) {
+		b.state, b.failures, b.probing = StateClosed, 0, false
+		return
+	}
+	b.failures++
+	if b.state == StateHalfOpen || b.failures >= b.threshold {
+		b.state, b.openedAt, b.probing = StateOpen, b.now(), false
+	}
+}
+
+// State 当前的状态，熔断时间已过但还没有请求时仍然为StateOpen
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
@@ -0,0 +1,59 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker(t *testing.T) {
+	errDown := errors.New("connection refused")
+	errNotFound := errors.New("not found")
+	now := time.Now()
+	b := New(3, time.Second, func(err error) bool { return err == errDown })
+	b.now = func() time.Time { return now }
+
+	call := func(result error) error {
+		if err := b.Allow(); err != nil {
+			return err
+		}
+		b.ReportResult(result)
+		return result
+	}
+	// 不算失败的错误会清零连续失败的次数
+	call(errDown)
+	call(errDown)
+	call(errNotFound)
+	call(errDown)
+	call(errDown)
+	assert.Equal(t, StateClosed, b.State())
+	call(errDown)
+	assert.Equal(t, StateOpen, b.State())
+	assert.Equal(t, ErrOpen, call(nil))
+
+	// 熔断时间过后只放行一个请求试探，失败后重新熔断
+	now = now.Add(time.Second)
+	assert.NoError(t, b.Allow())
+	assert.Equal(t, StateHalfOpen, b.State())
+	assert.Equal(t, ErrOpen, b.Allow())
+	b.ReportResult(errDown)
+	assert.Equal(t, StateOpen, b.State())
+	assert.Equal(t, ErrOpen, call(nil))
+
+	// 试探成功后恢复
+	now = now.Add(time.Second)
+	assert.NoError(t, call(nil))
+	assert.Equal(t, StateClosed, b.State())
+	assert.NoError(t, call(nil))
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	b := New(0, time.Second, nil)
+	for i := 0; i < 10; i++ {
+		b.ReportResult(errors.New("down"))
+	}
+	assert.NoError(t, b.Allow())
+	assert.Equal(t, StateClosed, b.State())
+}
@@ -0,0 +1,67 @@
+// Package captcha 在服务端校验客户端提交的hCaptcha或reCAPTCHA token，两者的校验接口格式相同
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	ProviderHCaptcha  = "hcaptcha"
+	ProviderReCaptcha = "recaptcha"
+)
+
+var verifyURLs = map[string]string{
+	ProviderHCaptcha:  "https://api.hcaptcha.com/siteverify",
+	ProviderReCaptcha: "https://www.google.com/recaptcha/api/siteverify",
+}
+
+// ErrInvalid 校验接口明确返回token无效，网络错误等其他错误原样返回
+var ErrInvalid = errors.New("captcha: verification failed")
+
+var client = &http.Client{}
+
+// VerifyURL 返回provider的校验接口地址，不认识的provider返回空字符串
+func VerifyURL(provider string) string {
+	return verifyURLs[provider]
+}
+
+type verifyResponse struct {
+	Success    bool     `json:"success"`
+	ErrorCodes []string `json:"error-codes"`
+}
+
+// Verify 调用verifyURL校验token，超时由ctx控制；remoteIP为空时不传给校验接口
+func Verify(ctx context.Context, verifyURL, secret, token, remoteIP string) error {
+	form := url.Values{"secret": {secret}, "response": {token}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	req, err := http.NewRequest(http.MethodPost, verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("captcha: unexpected status %d", resp.StatusCode)
+	}
+	var vr verifyResponse
+	if err = json.NewDecoder(resp.Body).Decode(&vr); err != nil {
+		return err
+	}
+	if !vr.Success {
+		return fmt.Errorf("%w: %s", ErrInvalid, strings.Join(vr.ErrorCodes, ","))
+	}
+	return nil
+}
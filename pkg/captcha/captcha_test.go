@@ -0,0 +1,42 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.FormValue("secret"))
+		assert.Equal(t, "1.2.3.4", r.FormValue("remoteip"))
+		switch r.FormValue("response") {
+		case "good":
+			w.Write([]byte(`{"success":true}`))
+		case "slow":
+			time.Sleep(200 * time.Millisecond)
+			w.Write([]byte(`{"success":true}`))
+		default:
+			w.Write([]byte(`{"success":false,"error-codes":["invalid-input-response"]}`))
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	assert.NoError(t, Verify(ctx, srv.URL, "secret", "good", "1.2.3.4"))
+
+	err := Verify(ctx, srv.URL, "secret", "bad", "1.2.3.4")
+	assert.True(t, errors.Is(err, ErrInvalid))
+	assert.Contains(t, err.Error(), "invalid-input-response")
+
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	err = Verify(ctx, srv.URL, "secret", "slow", "1.2.3.4")
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrInvalid))
+}
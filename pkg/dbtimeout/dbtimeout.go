@@ -0,0 +1,30 @@
+// Package dbtimeout 给dao调用加上超时，默认值来自各个存储的配置，已知耗时较长的操作可以通过ctx单独指定
+package dbtimeout
+
+import (
+	"context"
+	"time"
+)
+
+type overrideKey struct{}
+
+// WithTimeout 之后使用ctx的dao调用以d为超时，覆盖配置中的默认值，d<=0表示不限制(仍然会随ctx取消)
+func WithTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, overrideKey{}, d)
+}
+
+// Context 返回dao调用使用的ctx，优先使用WithTimeout设置的超时，否则为def
+// 超时<=0时只继承ctx的取消，调用结束后需要调用cancel
+func Context(ctx context.Context, def time.Duration) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	d := def
+	if v, ok := ctx.Value(overrideKey{}).(time.Duration); ok {
+		d = v
+	}
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
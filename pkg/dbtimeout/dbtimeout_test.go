@@ -0,0 +1,41 @@
+package dbtimeout
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContext(t *testing.T) {
+	ctx, cancel := Context(context.Background(), time.Second)
+	deadline, ok := ctx.Deadline()
+	cancel()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Second), deadline, 100*time.Millisecond)
+
+	// 覆盖默认值
+	ctx, cancel = Context(WithTimeout(context.Background(), time.Minute), time.Second)
+	deadline, ok = ctx.Deadline()
+	cancel()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, 100*time.Millisecond)
+
+	// 不限制时没有超时，但仍然随父ctx取消
+	parent, cancelParent := context.WithCancel(WithTimeout(context.Background(), 0))
+	ctx, cancel = Context(parent, time.Second)
+	defer cancel()
+	_, ok = ctx.Deadline()
+	assert.False(t, ok)
+	cancelParent()
+	assert.Equal(t, context.Canceled, ctx.Err())
+
+	// 父ctx的截止时间更早时以父ctx为准
+	parent, cancelParent = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelParent()
+	ctx, cancel = Context(parent, time.Minute)
+	defer cancel()
+	deadline, _ = ctx.Deadline()
+	assert.WithinDuration(t, time.Now().Add(10*time.Millisecond), deadline, 100*time.Millisecond)
+}
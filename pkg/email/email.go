@@ -0,0 +1,80 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"go-web-app/settings"
+	"net/smtp"
+	"net/textproto"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+var ErrNotConfigured = errors.New("email is not configured")
+
+// 发送邮件的方式
+const (
+	ModeSMTP = "smtp"
+	ModeLog  = "log" // 只写日志不发送，用于本地开发
+)
+
+var cfg *settings.EmailConfig
+
+func Init(c *settings.EmailConfig) {
+	cfg = c
+}
+
+// Send 发送纯文本邮件，log模式下只记录日志
+// 请求中不要直接调用，使用logic中的邮件队列，避免阻塞请求和超过服务商的频率限制
+func Send(to, subject, body string) error {
+	if cfg != nil && cfg.Mode == ModeLog {
+		zap.L().Info("email not sent in log mode",
+			zap.String("to", to), zap.String("subject", subject), zap.String("body", body))
+		return nil
+	}
+	if cfg == nil || cfg.Host == "" {
+		return ErrNotConfigured
+	}
+	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	msg := strings.Join([]string{
+		"From: " + cfg.From,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+	return smtp.SendMail(addr, auth, cfg.From, []string{to}, []byte(msg))
+}
+
+// IsPermanent 重试也不会成功的错误: 没有配置邮件，或者SMTP服务器返回5xx(例如收件人不存在)
+// 其余的错误(连接失败、4xx等)视为暂时的
+func IsPermanent(err error) bool {
+	if errors.Is(err, ErrNotConfigured) {
+		return true
+	}
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code >= 500
+	}
+	return false
+}
+
+// Link 拼接邮件中给用户点击的链接
+func Link(path string, query url.Values) string {
+	base := ""
+	if cfg != nil {
+		base = strings.TrimRight(cfg.BaseURL, "/")
+	}
+	if len(query) == 0 {
+		return base + path
+	}
+	return base + path + "?" + query.Encode()
+}
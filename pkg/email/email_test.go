@@ -0,0 +1,30 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"go-web-app/settings"
+	"net/textproto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPermanent(t *testing.T) {
+	assert.True(t, IsPermanent(ErrNotConfigured))
+	assert.True(t, IsPermanent(&textproto.Error{Code: 550, Msg: "mailbox unavailable"}))
+	assert.True(t, IsPermanent(fmt.Errorf("send: %w", &textproto.Error{Code: 553})))
+	assert.False(t, IsPermanent(&textproto.Error{Code: 421, Msg: "try again later"}))
+	assert.False(t, IsPermanent(errors.New("dial tcp: connection refused")))
+}
+
+func TestSendLogMode(t *testing.T) {
+	old := cfg
+	defer func() { cfg = old }()
+
+	Init(&settings.EmailConfig{})
+	assert.Equal(t, ErrNotConfigured, Send("a@rutgers.edu", "hi", "body"))
+	// log模式下不需要配置SMTP服务器
+	Init(&settings.EmailConfig{Mode: ModeLog})
+	assert.NoError(t, Send("a@rutgers.edu", "hi", "body"))
+}
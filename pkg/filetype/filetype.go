@@ -0,0 +1,31 @@
+// Package filetype 根据文件头判断上传文件的类型，不使用客户端提供的扩展名和Content-Type
+package filetype
+
+import (
+	"net/http"
+	"strings"
+)
+
+// extensions 保存文件时使用的扩展名，下载时按扩展名返回Content-Type
+var extensions = map[string]string{
+	"application/pdf": ".pdf",
+	"application/zip": ".zip",
+	"image/png":       ".png",
+	"image/jpeg":      ".jpg",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+}
+
+// Detect 按文件头判断类型，返回不带参数的MIME类型，无法识别时为application/octet-stream
+func Detect(data []byte) string {
+	contentType := http.DetectContentType(data)
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return contentType
+}
+
+// Ext 类型对应的扩展名，不支持的类型返回空字符串
+func Ext(contentType string) string {
+	return extensions[contentType]
+}
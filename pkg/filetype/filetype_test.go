@@ -0,0 +1,19 @@
+package filetype
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetect(t *testing.T) {
+	assert.Equal(t, "application/pdf", Detect([]byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")))
+	assert.Equal(t, "image/png", Detect([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")))
+	assert.Equal(t, "application/zip", Detect([]byte("PK\x03\x04\x14\x00\x00\x00")))
+	// 扩展名伪装成pdf的文本和可执行文件
+	assert.Equal(t, "text/plain", Detect([]byte("definitely not a pdf")))
+	assert.Equal(t, "application/octet-stream", Detect([]byte("MZ\x90\x00\x03\x00\x00\x00\x04\x00")))
+
+	assert.Equal(t, ".pdf", Ext("application/pdf"))
+	assert.Equal(t, "", Ext("text/plain"))
+}
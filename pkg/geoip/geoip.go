@@ -0,0 +1,112 @@
+// Package geoip 根据IP查询所在的国家，Resolver可以替换为其他数据源
+package geoip
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ErrUnknown 查不到IP所在的国家
+var ErrUnknown = errors.New("geoip: country unknown")
+
+// Resolver 返回IP所在国家的ISO 3166-1两位代码，大写
+type Resolver interface {
+	Country(ip net.IP) (string, error)
+}
+
+type ipRange struct {
+	start, end net.IP // 统一为16字节
+	country    string
+}
+
+// Table 按网段查询国家，从每行"网段,国家代码"的文件中加载
+type Table struct {
+	ranges []ipRange
+}
+
+// LoadFile 加载网段文件，空行和#开头的行忽略，网段嵌套时使用范围最小的网段
+func LoadFile(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	t := new(Table)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.Split(line, ",")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("geoip: %s:%d: expected cidr,country", path, n)
+		}
+		if err = t.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])); err != nil {
+			return nil, fmt.Errorf("geoip: %s:%d: %v", path, n, err)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Add 添加一个网段
+func (t *Table) Add(cidr, country string) error {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return err
+	}
+	if len(country) != 2 {
+		return fmt.Errorf("invalid country code %q", country)
+	}
+	start := network.IP.To16()
+	end := make(net.IP, len(start))
+	mask := network.Mask
+	if len(mask) == net.IPv4len {
+		mask = append(net.CIDRMask(96, 128)[:12], mask...)
+	}
+	for i := range start {
+		end[i] = start[i] | ^mask[i]
+	}
+	r := ipRange{start: start, end: end, country: strings.ToUpper(country)}
+	i := sort.Search(len(t.ranges), func(i int) bool { return compare(t.ranges[i].start, start) > 0 })
+	t.ranges = append(t.ranges, ipRange{})
+	copy(t.ranges[i+1:], t.ranges[i:])
+	t.ranges[i] = r
+	return nil
+}
+
+// Country 不在任何网段中时返回ErrUnknown
+func (t *Table) Country(ip net.IP) (string, error) {
+	ip = ip.To16()
+	if ip == nil {
+		return "", ErrUnknown
+	}
+	// 从最后一个起始地址不大于ip的网段往前找，网段嵌套时先找到的是范围最小的那个
+	i := sort.Search(len(t.ranges), func(i int) bool { return compare(t.ranges[i].start, ip) > 0 })
+	for i--; i >= 0; i-- {
+		if compare(ip, t.ranges[i].end) <= 0 {
+			return t.ranges[i].country, nil
+		}
+	}
+	return "", ErrUnknown
+}
+
+func compare(a, b net.IP) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
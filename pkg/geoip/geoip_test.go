@@ -0,0 +1,52 @@
+package geoip
+
+import (
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ranges.csv")
+	content := "# cidr,country\n10.0.0.0/8,us\n10.1.0.0/16 , CA\n\n192.168.1.0/24,DE\n2001:db8::/32,FR\n"
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	table, err := LoadFile(path)
+	require.NoError(t, err)
+
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"10.2.3.4", "US"},
+		{"10.255.255.255", "US"},
+		{"10.1.2.3", "CA"},
+		{"192.168.1.200", "DE"},
+		{"2001:db8::1", "FR"},
+		{"::ffff:192.168.1.1", "DE"},
+		{"11.0.0.0", ""},
+		{"192.168.2.1", ""},
+		{"2001:db9::1", ""},
+	}
+	for _, tt := range tests {
+		got, err := table.Country(net.ParseIP(tt.ip))
+		if tt.want == "" {
+			assert.ErrorIs(t, err, ErrUnknown, tt.ip)
+			continue
+		}
+		require.NoError(t, err, tt.ip)
+		assert.Equal(t, tt.want, got, tt.ip)
+	}
+	_, err = table.Country(nil)
+	assert.ErrorIs(t, err, ErrUnknown)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("10.0.0.0/8\n"), 0644))
+	_, err = LoadFile(path)
+	assert.Error(t, err)
+	require.NoError(t, ioutil.WriteFile(path, []byte("10.0.0.0/8,USA\n"), 0644))
+	_, err = LoadFile(path)
+	assert.Error(t, err)
+}
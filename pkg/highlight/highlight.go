@@ -0,0 +1,139 @@
+// Package highlight 从搜索命中的文本中截取包含关键词的片段，并用<mark>标出关键词
+package highlight
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// 关键词前后的标记，片段中除此之外的内容都经过HTML转义
+const (
+	MarkStart = "<mark>"
+	MarkEnd   = "</mark>"
+)
+
+// DefaultLength 没有指定长度时片段最多包含的字符数，不包括标记和省略号
+const DefaultLength = 160
+
+// Terms 把搜索词拆分为小写的关键词，去掉标点和重复的词
+func Terms(query string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, f := range strings.FieldsFunc(query, notWordRune) {
+		f = lower(f)
+		if !seen[f] {
+			seen[f] = true
+			terms = append(terms, f)
+		}
+	}
+	return terms
+}
+
+// Snippet 截取text中第一个关键词附近最多maxLen个字符，关键词从词的开头匹配，忽略大小写
+// 返回的片段已经转义，只包含MarkStart和MarkEnd两种标签，没有匹配的关键词时返回空字符串
+func Snippet(text string, terms []string, maxLen int) string {
+	if maxLen <= 0 {
+		maxLen = DefaultLength
+	}
+	src := []rune(text)
+	// 逐个字符转小写，保证和src的下标一一对应
+	low := make([]rune, len(src))
+	for i, r := range src {
+		low[i] = unicode.ToLower(r)
+	}
+	words := make([][]rune, 0, len(terms))
+	for _, t := range terms {
+		if t != "" {
+			words = append(words, []rune(t))
+		}
+	}
+	first := -1
+	for i := range low {
+		if matchAt(low, i, words) > 0 {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return ""
+	}
+
+	// 关键词放在片段的前三分之一，前面保留一些上下文
+	start := first - maxLen/3
+	if start < 0 {
+		start = 0
+	}
+	end := start + maxLen
+	if end > len(src) {
+		end = len(src)
+		if start = end - maxLen; start < 0 {
+			start = 0
+		}
+	}
+	// 不从词的中间开始和结束
+	for start > 0 && start < first && !notWordRune(src[start-1]) {
+		start++
+	}
+	for end < len(src) && end > first && !notWordRune(src[end]) {
+		end--
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	plain := start
+	for i := start; i < end; {
+		n := matchAt(low, i, words)
+		if n == 0 || i+n > end {
+			i++
+			continue
+		}
+		b.WriteString(html.EscapeString(string(src[plain:i])))
+		b.WriteString(MarkStart)
+		b.WriteString(html.EscapeString(string(src[i : i+n])))
+		b.WriteString(MarkEnd)
+		i += n
+		plain = i
+	}
+	b.WriteString(html.EscapeString(string(src[plain:end])))
+	if end < len(src) {
+		b.WriteString("…")
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// matchAt 在词的开头匹配最长的关键词，返回匹配的字符数，没有匹配时返回0
+func matchAt(low []rune, i int, words [][]rune) int {
+	if i > 0 && !notWordRune(low[i-1]) {
+		return 0
+	}
+	best := 0
+	for _, w := range words {
+		if len(w) > best && hasPrefix(low[i:], w) {
+			best = len(w)
+		}
+	}
+	return best
+}
+
+func hasPrefix(s, prefix []rune) bool {
+	if len(prefix) > len(s) {
+		return false
+	}
+	for i := range prefix {
+		if s[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func lower(s string) string {
+	return strings.Map(unicode.ToLower, s)
+}
+
+func notWordRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
@@ -0,0 +1,42 @@
+package highlight
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTerms(t *testing.T) {
+	assert.Equal(t, []string{"go", "web", "app"}, Terms("Go, web-app GO"))
+	assert.Empty(t, Terms("  !! "))
+}
+
+func TestSnippet(t *testing.T) {
+	terms := Terms("rutgers library")
+	assert.Equal(t, "The <mark>Rutgers</mark> <mark>library</mark> opens at 8", Snippet("The Rutgers library opens at 8", terms, 100))
+
+	// 只从词的开头匹配
+	assert.Equal(t, "", Snippet("interrutgers", terms, 100))
+	assert.Equal(t, "<mark>Rutgers</mark>ville", Snippet("Rutgersville", terms, 100))
+
+	// 内容中的标签被转义，只保留高亮的标记
+	out := Snippet(`<b onclick="x">rutgers</b> & co`, terms, 100)
+	assert.Equal(t, "&lt;b onclick=&#34;x&#34;&gt;<mark>rutgers</mark>&lt;/b&gt; &amp; co", out)
+
+	// 长文本截取关键词附近的内容，两边不截断单词
+	long := strings.Repeat("lorem ipsum ", 50) + "near the library entrance " + strings.Repeat("dolor sit ", 50)
+	out = Snippet(long, terms, 60)
+	assert.True(t, strings.HasPrefix(out, "…"))
+	assert.True(t, strings.HasSuffix(out, "…"))
+	assert.Contains(t, out, "<mark>library</mark> entrance")
+	plain := strings.NewReplacer(MarkStart, "", MarkEnd, "", "…", "").Replace(out)
+	assert.LessOrEqual(t, len([]rune(plain)), 60)
+	for _, w := range strings.Fields(plain) {
+		assert.Contains(t, []string{"lorem", "ipsum", "near", "the", "library", "entrance", "dolor", "sit"}, w)
+	}
+
+	// 没有匹配时返回空字符串，由调用方使用摘要
+	assert.Equal(t, "", Snippet("nothing here", terms, 100))
+	assert.Equal(t, "", Snippet("", terms, 100))
+}
@@ -0,0 +1,202 @@
+// Package hub 管理每个用户的websocket连接，并把消息推送给用户的所有连接
+package hub
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = pongWait * 9 / 10 // 必须小于pongWait
+	maxMessageSize = 512
+	sendBufferSize = 16
+	closeWait      = 5 * time.Second // 发送关闭帧后等待客户端回应的时间
+	drainInterval  = 50 * time.Millisecond
+)
+
+var (
+	ErrTooManyConnections = errors.New("too many connections")
+	ErrShuttingDown       = errors.New("server is shutting down")
+)
+
+type Hub struct {
+	serving  int64 // 正在Serve的连接数，原子操作
+	mu       sync.RWMutex
+	clients  map[int64]map[*Client]struct{}
+	draining bool
+}
+
+func New() *Hub {
+	return &Hub{clients: make(map[int64]map[*Client]struct{})}
+}
+
+// Client 用户的一个websocket连接
+type Client struct {
+	hub       *Hub
+	userID    int64
+	send      chan []byte
+	once      sync.Once
+	closeCode int // 关闭帧中的状态码，在send关闭之前写入
+}
+
+// Register 为用户登记一个新连接，maxPerUser大于0时限制每个用户的连接数
+func (h *Hub) Register(userID int64, maxPerUser int) (*Client, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.draining {
+		return nil, ErrShuttingDown
+	}
+	conns := h.clients[userID]
+	if maxPerUser > 0 && len(conns) >= maxPerUser {
+		return nil, ErrTooManyConnections
+	}
+	if conns == nil {
+		conns = make(map[*Client]struct{})
+		h.clients[userID] = conns
+	}
+	c := &Client{hub: h, userID: userID, send: make(chan []byte, sendBufferSize)}
+	conns[c] = struct{}{}
+	return c, nil
+}
+
+func (h *Hub) unregister(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	conns := h.clients[c.userID]
+	if _, ok := conns[c]; !ok {
+		return
+	}
+	delete(conns, c)
+	if len(conns) == 0 {
+		delete(h.clients, c.userID)
+	}
+	close(c.send)
+}
+
+// Send 把消息推给用户在本实例上的所有连接。
+// 发送缓冲区已满的连接被认为是卡住了，直接断开。
+func (h *Hub) Send(userID int64, msg []byte) {
+	var slow []*Client
+	h.mu.RLock()
+	for c := range h.clients[userID] {
+		select {
+		case c.send <- msg:
+		default:
+			slow = append(slow, c)
+		}
+	}
+	h.mu.RUnlock()
+	for _, c := range slow {
+		c.Close()
+	}
+}
+
+// Count 返回用户在本实例上的连接数
+func (h *Hub) Count(userID int64) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients[userID])
+}
+
+// Drain 停止接受新连接，给所有连接发送关闭帧，然后等待客户端断开。
+// ctx结束时不再等待，返回仍未断开的连接数。
+func (h *Hub) Drain(ctx context.Context) int {
+	h.mu.Lock()
+	h.draining = true
+	var all []*Client
+	for _, conns := range h.clients {
+		for c := range conns {
+			all = append(all, c)
+		}
+	}
+	h.mu.Unlock()
+	for _, c := range all {
+		c.closeWith(websocket.CloseGoingAway)
+	}
+	ticker := time.NewTicker(drainInterval)
+	defer ticker.Stop()
+	for {
+		n := atomic.LoadInt64(&h.serving)
+		if n == 0 {
+			return 0
+		}
+		select {
+		case <-ctx.Done():
+			return int(n)
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close 注销连接，可以重复调用
+func (c *Client) Close() {
+	c.closeWith(websocket.CloseNormalClosure)
+}
+
+func (c *Client) closeWith(code int) {
+	c.once.Do(func() {
+		c.closeCode = code
+		c.hub.unregister(c)
+	})
+}
+
+// Serve 在conn上收发消息，直到连接断开才返回。
+// 客户端不需要发送业务消息，读循环只用来处理pong和关闭帧。
+func (c *Client) Serve(conn *websocket.Conn) {
+	atomic.AddInt64(&c.hub.serving, 1)
+	defer atomic.AddInt64(&c.hub.serving, -1)
+	defer conn.Close()
+	defer c.Close()
+	go c.writePump(conn)
+	c.readPump(conn)
+}
+
+func (c *Client) readPump(conn *websocket.Conn) {
+	conn.SetReadLimit(maxMessageSize)
+	_ = conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func (c *Client) writePump(conn *websocket.Conn) {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg, ok := <-c.send:
+			_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				// 连接已注销，发送关闭帧后由读循环等待客户端回应关闭帧，
+				// 客户端一直不回应时读超时，Serve返回并关闭连接
+				_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(c.closeCode, ""))
+				_ = conn.SetReadDeadline(time.Now().Add(closeWait))
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				c.Close()
+				_ = conn.Close()
+				return
+			}
+		case <-ticker.C:
+			_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.Close()
+				_ = conn.Close()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package hub
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterLimit(t *testing.T) {
+	h := New()
+	c1, err := h.Register(1, 2)
+	assert.Nil(t, err)
+	_, err = h.Register(1, 2)
+	assert.Nil(t, err)
+	_, err = h.Register(1, 2)
+	assert.Equal(t, ErrTooManyConnections, err)
+
+	// 其他用户不受影响
+	_, err = h.Register(2, 2)
+	assert.Nil(t, err)
+
+	c1.Close()
+	c1.Close()
+	assert.Equal(t, 1, h.Count(1))
+	_, err = h.Register(1, 2)
+	assert.Nil(t, err)
+}
+
+func TestServeAndSend(t *testing.T) {
+	h := New()
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := h.Register(1, 0)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			c.Close()
+			return
+		}
+		c.Serve(conn)
+	}))
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer conn.Close()
+
+	assert.Eventually(t, func() bool { return h.Count(1) == 1 }, time.Second, 10*time.Millisecond)
+	h.Send(1, []byte("hello"))
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, msg, err := conn.ReadMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(msg))
+
+	// 客户端断开后连接被注销
+	_ = conn.Close()
+	assert.Eventually(t, func() bool { return h.Count(1) == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestDrain(t *testing.T) {
+	h := New()
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := h.Register(1, 0)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			c.Close()
+			return
+		}
+		c.Serve(conn)
+	}))
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer conn.Close()
+	assert.Eventually(t, func() bool { return h.Count(1) == 1 }, time.Second, 10*time.Millisecond)
+
+	done := make(chan int)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		done <- h.Drain(ctx)
+	}()
+
+	// 客户端收到going away关闭帧，读取时会自动回应关闭帧
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway))
+	assert.Equal(t, 0, <-done)
+
+	// 关闭过程中不再接受新连接
+	_, err = h.Register(2, 0)
+	assert.Equal(t, ErrShuttingDown, err)
+}
+
+func TestDrainTimeout(t *testing.T) {
+	h := New()
+	atomic.AddInt64(&h.serving, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, 1, h.Drain(ctx))
+}
@@ -1,31 +1,93 @@
 package jwt
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"go-web-app/settings"
 	"time"
 
-	"github.com/spf13/viper"
-
 	"github.com/dgrijalva/jwt-go"
 )
 
 var mySecret = []byte("mouse tail ale")
 
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
 type MyClaims struct {
-	UserID   int64  `json:"user_id"`
-	Username string `json:"username"`
+	UserID    int64  `json:"user_id"`
+	Username  string `json:"username"`
+	TokenType string `json:"token_type"`
+	// SessionID 登录会话，同一次登录轮换出的token相同；之前签发的token没有会话
+	SessionID string `json:"sid,omitempty"`
 	jwt.StandardClaims
 }
 
-// Generate JWT token
-func GenToken(userID int64, username string) (string, error) {
+// authConfig 从settings.Current()读取，热更新没有通过校验时继续使用之前的有效期
+func authConfig() *settings.AuthConfig {
+	if cfg := settings.Current().AuthConfig; cfg != nil {
+		return cfg
+	}
+	return new(settings.AuthConfig)
+}
+
+// AccessTokenExpire access token 有效期
+func AccessTokenExpire() time.Duration {
+	return time.Duration(authConfig().JwtExpire) * time.Hour
+}
+
+// RefreshTokenExpire refresh token 有效期
+func RefreshTokenExpire() time.Duration {
+	return time.Duration(authConfig().RefreshExpire) * time.Hour
+}
+
+// RememberTokenExpire 登录时选择"记住我"的refresh token有效期
+func RememberTokenExpire() time.Duration {
+	return time.Duration(authConfig().RememberExpire) * time.Hour
+}
+
+// GenToken generate access token and refresh token
+func GenToken(userID int64, username string) (aToken, rToken string, err error) {
+	return GenTokenWithLifetime(userID, username, RefreshTokenExpire())
+}
+
+// GenTokenWithLifetime 指定refresh token的有效期，access token的有效期不变
+func GenTokenWithLifetime(userID int64, username string, refreshExpire time.Duration) (aToken, rToken string, err error) {
+	return GenSessionToken(userID, username, "", refreshExpire)
+}
+
+// GenSessionToken 生成属于登录会话sessionID的token对
+func GenSessionToken(userID int64, username, sessionID string, refreshExpire time.Duration) (aToken, rToken string, err error) {
+	aToken, err = genToken(userID, username, sessionID, TokenTypeAccess, AccessTokenExpire())
+	if err != nil {
+		return
+	}
+	rToken, err = genToken(userID, username, sessionID, TokenTypeRefresh, refreshExpire)
+	return
+}
+
+func genToken(userID int64, username, sessionID, tokenType string, expire time.Duration) (string, error) {
+	// 每个token带一个随机id，保证轮换后新旧token一定不同
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
 	// 创建一个我们自己的声明
 	c := MyClaims{
 		userID,
-		"username", // 自定义字段
+		username, // 自定义字段
+		tokenType,
+		sessionID,
 		jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Duration(viper.GetInt("auth.jwt_expire")) * time.Hour).Unix(), // 过期时间
-			Issuer:    "Mufu",                                                                            // 签发人
+			Id:        hex.EncodeToString(jti),
+			ExpiresAt: time.Now().Add(expire).Unix(), // 过期时间
+			Issuer:    "Mufu",                        // 签发人
 		},
 	}
 	// 使用指定的签名方法创建签名对象
@@ -34,17 +96,41 @@ func GenToken(userID int64, username string) (string, error) {
 	return token.SignedString(mySecret)
 }
 
-// ParseToken 解析JWT
+// ParseToken 解析access token
 func ParseToken(tokenString string) (*MyClaims, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	// refresh token 不能当作 access token 使用
+	if claims.TokenType == TokenTypeRefresh {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// ParseRefreshToken 解析refresh token
+func ParseRefreshToken(tokenString string) (*MyClaims, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != TokenTypeRefresh {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+func parseToken(tokenString string) (*MyClaims, error) {
 	// 解析token
 	token, err := jwt.ParseWithClaims(tokenString, &MyClaims{}, func(token *jwt.Token) (i interface{}, err error) {
 		return mySecret, nil
 	})
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
 	}
 	if claims, ok := token.Claims.(*MyClaims); ok && token.Valid { // 校验token
 		return claims, nil
 	}
-	return nil, errors.New("invalid token")
+	return nil, ErrInvalidToken
 }
@@ -0,0 +1,88 @@
+package jwt
+
+import (
+	"errors"
+	"go-web-app/settings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func useAuthConfig(t *testing.T, cfg *settings.AuthConfig) {
+	old := settings.Conf.AuthConfig
+	settings.Conf.AuthConfig = cfg
+	t.Cleanup(func() { settings.Conf.AuthConfig = old })
+}
+
+func TestGenToken(t *testing.T) {
+	useAuthConfig(t, &settings.AuthConfig{JwtExpire: 1, RefreshExpire: 24})
+
+	aToken, rToken, err := GenToken(123, "mufu")
+	if err != nil {
+		t.Fatalf("GenToken failed, err:%v\n", err)
+	}
+
+	claims, err := ParseToken(aToken)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(123), claims.UserID)
+	assert.Equal(t, "mufu", claims.Username)
+
+	// refresh token 不能当作 access token 使用，反之亦然
+	_, err = ParseToken(rToken)
+	assert.True(t, errors.Is(err, ErrInvalidToken))
+	_, err = ParseRefreshToken(aToken)
+	assert.True(t, errors.Is(err, ErrInvalidToken))
+
+	claims, err = ParseRefreshToken(rToken)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(123), claims.UserID)
+
+	// 轮换后的 refresh token 必须与旧的不同
+	_, rToken2, err := GenToken(123, "mufu")
+	assert.Nil(t, err)
+	assert.NotEqual(t, rToken, rToken2)
+}
+
+func TestGenTokenWithLifetime(t *testing.T) {
+	useAuthConfig(t, &settings.AuthConfig{JwtExpire: 1})
+
+	aToken, rToken, err := GenTokenWithLifetime(123, "mufu", 30*24*time.Hour)
+	assert.Nil(t, err)
+
+	// 只有refresh token的有效期变长，access token保持不变
+	claims, err := ParseToken(aToken)
+	assert.Nil(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), time.Unix(claims.ExpiresAt, 0), time.Minute)
+	claims, err = ParseRefreshToken(rToken)
+	assert.Nil(t, err)
+	assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), time.Unix(claims.ExpiresAt, 0), time.Minute)
+}
+
+func TestGenSessionToken(t *testing.T) {
+	aToken, rToken, err := GenSessionToken(123, "mufu", "s1", time.Hour)
+	assert.Nil(t, err)
+	claims, err := ParseToken(aToken)
+	assert.Nil(t, err)
+	assert.Equal(t, "s1", claims.SessionID)
+	claims, err = ParseRefreshToken(rToken)
+	assert.Nil(t, err)
+	assert.Equal(t, "s1", claims.SessionID)
+
+	_, rToken, err = GenTokenWithLifetime(123, "mufu", time.Hour)
+	assert.Nil(t, err)
+	claims, err = ParseRefreshToken(rToken)
+	assert.Nil(t, err)
+	assert.Empty(t, claims.SessionID)
+}
+
+func TestTokenExpire(t *testing.T) {
+	useAuthConfig(t, &settings.AuthConfig{JwtExpire: 2, RefreshExpire: 24, RememberExpire: 720})
+	assert.Equal(t, 2*time.Hour, AccessTokenExpire())
+	assert.Equal(t, 24*time.Hour, RefreshTokenExpire())
+	assert.Equal(t, 720*time.Hour, RememberTokenExpire())
+
+	// 没有加载配置时有效期为0，和没有配置这些字段时一样
+	useAuthConfig(t, nil)
+	assert.Equal(t, time.Duration(0), AccessTokenExpire())
+}
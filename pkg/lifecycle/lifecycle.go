@@ -0,0 +1,53 @@
+// Package lifecycle 按初始化的逆序执行关闭操作，后初始化的组件可能依赖先初始化的组件
+package lifecycle
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type hook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+type Manager struct {
+	mu    sync.Mutex
+	hooks []hook
+}
+
+func New() *Manager {
+	return new(Manager)
+}
+
+// OnShutdown 登记一个关闭操作，应在对应组件初始化成功之后立即调用
+func (m *Manager) OnShutdown(name string, fn func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, hook{name: name, fn: fn})
+}
+
+// Shutdown 按登记的逆序执行所有关闭操作，某一个失败不影响后面的操作，返回第一个错误。
+// 所有操作共用ctx的超时时间。
+func (m *Manager) Shutdown(ctx context.Context) (err error) {
+	m.mu.Lock()
+	hooks := m.hooks
+	m.hooks = nil
+	m.mu.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		start := time.Now()
+		if e := h.fn(ctx); e != nil {
+			zap.L().Error("shutdown failed", zap.String("component", h.name), zap.Duration("cost", time.Since(start)), zap.Error(e))
+			if err == nil {
+				err = e
+			}
+			continue
+		}
+		zap.L().Info("shutdown success", zap.String("component", h.name), zap.Duration("cost", time.Since(start)))
+	}
+	return err
+}
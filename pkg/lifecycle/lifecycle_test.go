@@ -0,0 +1,34 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdownOrder(t *testing.T) {
+	m := New()
+	var order []string
+	record := func(name string, err error) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			order = append(order, name)
+			return err
+		}
+	}
+	m.OnShutdown("mysql", record("mysql", nil))
+	m.OnShutdown("redis", record("redis", errors.New("redis")))
+	m.OnShutdown("mongodb", record("mongodb", errors.New("mongodb")))
+	m.OnShutdown("http", record("http", nil))
+
+	// 逆序执行，失败的操作不影响后面的操作，返回第一个错误
+	err := m.Shutdown(context.Background())
+	assert.Equal(t, []string{"http", "mongodb", "redis", "mysql"}, order)
+	assert.EqualError(t, err, "mongodb")
+
+	// 所有操作只执行一次
+	order = nil
+	assert.Nil(t, m.Shutdown(context.Background()))
+	assert.Empty(t, order)
+}
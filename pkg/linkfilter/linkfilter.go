@@ -0,0 +1,85 @@
+// Package linkfilter 找出文本中的链接，按域名的黑名单和白名单检查
+package linkfilter
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// linkPattern 带http(s)://或者以www.开头的链接，markdown的括号和引号不算在链接内
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"'()\[\]]+`)
+
+type Filter struct {
+	blocked []string
+	allowed []string
+}
+
+// Result 检查的结果，白名单中的链接不计入
+type Result struct {
+	Links   int      // 链接数
+	Blocked []string // 命中黑名单的域名，按出现的顺序，不重复
+}
+
+// New 创建过滤器，域名包含所有子域名，可以写成example.com或*.example.com，白名单优先于黑名单
+func New(blocked, allowed []string) *Filter {
+	return &Filter{blocked: normalize(blocked), allowed: normalize(allowed)}
+}
+
+func normalize(domains []string) []string {
+	res := make([]string, 0, len(domains))
+	for _, d := range domains {
+		d = strings.Trim(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(d)), "*."), ".")
+		if d != "" {
+			res = append(res, d)
+		}
+	}
+	return res
+}
+
+// Hosts 文本中所有链接的域名，转为小写，按出现的顺序，可能重复
+func Hosts(text string) []string {
+	var hosts []string
+	for _, link := range linkPattern.FindAllString(text, -1) {
+		if !strings.Contains(link, "://") {
+			link = "http://" + link
+		}
+		u, err := url.Parse(link)
+		if err != nil {
+			continue
+		}
+		if host := strings.Trim(strings.ToLower(u.Hostname()), "."); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// Check 检查所有文本中的链接
+func (f *Filter) Check(texts ...string) Result {
+	var res Result
+	seen := make(map[string]bool)
+	for _, text := range texts {
+		for _, host := range Hosts(text) {
+			if match(host, f.allowed) != "" {
+				continue
+			}
+			res.Links++
+			if d := match(host, f.blocked); d != "" && !seen[d] {
+				seen[d] = true
+				res.Blocked = append(res.Blocked, d)
+			}
+		}
+	}
+	return res
+}
+
+// match 返回host所属的第一个域名，没有时返回空字符串
+func match(host string, domains []string) string {
+	for _, d := range domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return d
+		}
+	}
+	return ""
+}
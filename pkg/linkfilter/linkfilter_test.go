@@ -0,0 +1,25 @@
+package linkfilter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHosts(t *testing.T) {
+	text := "see [this](https://Spam.Example.com/a?b=1), www.foo.net/x and http://rutgers.edu. not a link: example.org"
+	assert.Equal(t, []string{"spam.example.com", "www.foo.net", "rutgers.edu"}, Hosts(text))
+	assert.Empty(t, Hosts("no links here"))
+}
+
+func TestCheck(t *testing.T) {
+	f := New([]string{"example.com", " *.Bad.io ", "edu"}, []string{"rutgers.edu"})
+
+	res := f.Check("https://cs.rutgers.edu/a https://rutgers.edu", "http://ok.org")
+	assert.Equal(t, 1, res.Links)
+	assert.Empty(t, res.Blocked)
+
+	res = f.Check("https://a.example.com https://example.com/b", "[x](http://x.bad.io) https://princeton.edu https://notexample.com")
+	assert.Equal(t, 5, res.Links)
+	assert.Equal(t, []string{"example.com", "bad.io", "edu"}, res.Blocked)
+}
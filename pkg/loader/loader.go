@@ -0,0 +1,215 @@
+// Package loader 一个请求内的数据加载器，同一个请求中多次需要的用户、社区和投票只查询一次
+// 先用Prime*登记需要的id，到Flush或第一次读取时每种数据用一次批量查询取出所有登记了还没有加载的id
+package loader
+
+import (
+	"context"
+	"go-web-app/models"
+	"sync"
+)
+
+// Fetchers 批量查询的函数，不存在的id不在结果中
+type Fetchers struct {
+	Users       func(ctx context.Context, ids []int64) (map[int64]*models.User, error)
+	Communities func(ctx context.Context, ids []int64) (map[int64]*models.CommunityDetail, error)
+	// Votes userID对每个帖子的投票，没有投票的帖子可以不在结果中
+	Votes func(ctx context.Context, userID int64, pids []int64) (map[int64]int8, error)
+}
+
+// Loader 只在一个请求内使用，缓存的对象会被请求中的多个调用方共享，调用方不能修改
+// 查询失败时不缓存结果，之后的读取会重新查询
+type Loader struct {
+	f  Fetchers
+	mu sync.Mutex
+
+	// 值为nil表示已经查询过但不存在
+	users        map[int64]*models.User
+	pendingUsers []int64
+
+	communities        map[int64]*models.CommunityDetail
+	pendingCommunities []int64
+
+	votes        map[int64]map[int64]int8 // 用户id -> 帖子id -> 投票
+	pendingVotes map[int64][]int64
+}
+
+func New(f Fetchers) *Loader {
+	return &Loader{
+		f:            f,
+		users:        make(map[int64]*models.User),
+		communities:  make(map[int64]*models.CommunityDetail),
+		votes:        make(map[int64]map[int64]int8),
+		pendingVotes: make(map[int64][]int64),
+	}
+}
+
+type ctxKey struct{}
+
+func NewContext(ctx context.Context, l *Loader) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext ctx中没有加载器时返回nil
+func FromContext(ctx context.Context) *Loader {
+	l, _ := ctx.Value(ctxKey{}).(*Loader)
+	return l
+}
+
+// PrimeUsers 登记需要的用户，下次Flush时一起查询
+func (l *Loader) PrimeUsers(ids ...int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.primeUsers(ids)
+}
+
+func (l *Loader) primeUsers(ids []int64) {
+	for _, id := range ids {
+		if _, ok := l.users[id]; !ok {
+			l.pendingUsers = append(l.pendingUsers, id)
+		}
+	}
+}
+
+// PrimeCommunities 登记需要的社区，下次Flush时一起查询
+func (l *Loader) PrimeCommunities(ids ...int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.primeCommunities(ids)
+}
+
+func (l *Loader) primeCommunities(ids []int64) {
+	for _, id := range ids {
+		if _, ok := l.communities[id]; !ok {
+			l.pendingCommunities = append(l.pendingCommunities, id)
+		}
+	}
+}
+
+// PrimeVotes 登记需要userID对哪些帖子的投票，下次Flush时一起查询
+func (l *Loader) PrimeVotes(userID int64, pids ...int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.primeVotes(userID, pids)
+}
+
+func (l *Loader) primeVotes(userID int64, pids []int64) {
+	loaded := l.votes[userID]
+	for _, pid := range pids {
+		if _, ok := loaded[pid]; !ok {
+			l.pendingVotes[userID] = append(l.pendingVotes[userID], pid)
+		}
+	}
+}
+
+// Flush 查询所有登记了还没有加载的数据，每种数据最多一次查询，投票每个用户一次
+func (l *Loader) Flush(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.flush(ctx)
+}
+
+func (l *Loader) flush(ctx context.Context) error {
+	if ids := unique(l.pendingUsers, func(id int64) bool { _, ok := l.users[id]; return ok }); len(ids) > 0 {
+		users, err := l.f.Users(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			l.users[id] = users[id]
+		}
+	}
+	l.pendingUsers = nil
+	if ids := unique(l.pendingCommunities, func(id int64) bool { _, ok := l.communities[id]; return ok }); len(ids) > 0 {
+		communities, err := l.f.Communities(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			l.communities[id] = communities[id]
+		}
+	}
+	l.pendingCommunities = nil
+	for userID, pending := range l.pendingVotes {
+		loaded := l.votes[userID]
+		pids := unique(pending, func(pid int64) bool { _, ok := loaded[pid]; return ok })
+		delete(l.pendingVotes, userID)
+		if len(pids) == 0 {
+			continue
+		}
+		votes, err := l.f.Votes(ctx, userID, pids)
+		if err != nil {
+			return err
+		}
+		if loaded == nil {
+			loaded = make(map[int64]int8, len(pids))
+			l.votes[userID] = loaded
+		}
+		for _, pid := range pids {
+			loaded[pid] = votes[pid]
+		}
+	}
+	return nil
+}
+
+// Users 查询用户，不存在的用户不在结果中
+func (l *Loader) Users(ctx context.Context, ids []int64) (map[int64]*models.User, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.primeUsers(ids)
+	if err := l.flush(ctx); err != nil {
+		return nil, err
+	}
+	users := make(map[int64]*models.User, len(ids))
+	for _, id := range ids {
+		if u := l.users[id]; u != nil {
+			users[id] = u
+		}
+	}
+	return users, nil
+}
+
+// Communities 查询社区，不存在的社区不在结果中
+func (l *Loader) Communities(ctx context.Context, ids []int64) (map[int64]*models.CommunityDetail, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.primeCommunities(ids)
+	if err := l.flush(ctx); err != nil {
+		return nil, err
+	}
+	communities := make(map[int64]*models.CommunityDetail, len(ids))
+	for _, id := range ids {
+		if c := l.communities[id]; c != nil {
+			communities[id] = c
+		}
+	}
+	return communities, nil
+}
+
+// PostVotes userID对每个帖子的投票，顺序与pids一致，没有投票时为0
+func (l *Loader) PostVotes(ctx context.Context, userID int64, pids []int64) ([]int8, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.primeVotes(userID, pids)
+	if err := l.flush(ctx); err != nil {
+		return nil, err
+	}
+	votes := make([]int8, 0, len(pids))
+	for _, pid := range pids {
+		votes = append(votes, l.votes[userID][pid])
+	}
+	return votes, nil
+}
+
+// unique 去掉重复的和已经加载的id
+func unique(ids []int64, loaded func(id int64) bool) []int64 {
+	seen := make(map[int64]struct{}, len(ids))
+	res := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok || loaded(id) {
+			continue
+		}
+		seen[id] = struct{}{}
+		res = append(res, id)
+	}
+	return res
+}
@@ -0,0 +1,184 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"go-web-app/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingFetchers 记录每次批量查询的id，用户1000不存在
+type countingFetchers struct {
+	users, communities, votes [][]int64
+	err                       error
+}
+
+func (f *countingFetchers) fetchers() Fetchers {
+	return Fetchers{
+		Users: func(_ context.Context, ids []int64) (map[int64]*models.User, error) {
+			f.users = append(f.users, ids)
+			if f.err != nil {
+				return nil, f.err
+			}
+			users := make(map[int64]*models.User, len(ids))
+			for _, id := range ids {
+				if id != 1000 {
+					users[id] = &models.User{UserID: id}
+				}
+			}
+			return users, nil
+		},
+		Communities: func(_ context.Context, ids []int64) (map[int64]*models.CommunityDetail, error) {
+			f.communities = append(f.communities, ids)
+			communities := make(map[int64]*models.CommunityDetail, len(ids))
+			for _, id := range ids {
+				communities[id] = &models.CommunityDetail{ID: id}
+			}
+			return communities, nil
+		},
+		Votes: func(_ context.Context, userID int64, pids []int64) (map[int64]int8, error) {
+			f.votes = append(f.votes, pids)
+			votes := make(map[int64]int8, len(pids))
+			for _, pid := range pids {
+				if pid%2 == 0 {
+					votes[pid] = 1
+				}
+			}
+			return votes, nil
+		},
+	}
+}
+
+func (f *countingFetchers) queries() int {
+	return len(f.users) + len(f.communities) + len(f.votes)
+}
+
+func TestLoaderBatchesAndMemoizes(t *testing.T) {
+	f := new(countingFetchers)
+	l := New(f.fetchers())
+	ctx := context.Background()
+	l.PrimeUsers(1, 2, 2)
+	l.PrimeCommunities(10)
+	require.NoError(t, l.Flush(ctx))
+	assert.Equal(t, [][]int64{{1, 2}}, f.users)
+	assert.Equal(t, [][]int64{{10}}, f.communities)
+
+	users, err := l.Users(ctx, []int64{2, 3, 1000})
+	require.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.Nil(t, users[1000])
+	// 只查询没有加载过的用户，不存在的用户也不会再查询
+	_, err = l.Users(ctx, []int64{1, 3, 1000})
+	require.NoError(t, err)
+	assert.Equal(t, [][]int64{{1, 2}, {3, 1000}}, f.users)
+
+	communities, err := l.Communities(ctx, []int64{10})
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), communities[10].ID)
+	assert.Len(t, f.communities, 1)
+
+	votes, err := l.PostVotes(ctx, 1, []int64{1, 2})
+	require.NoError(t, err)
+	assert.Equal(t, []int8{0, 1}, votes)
+	votes, err = l.PostVotes(ctx, 1, []int64{2, 4})
+	require.NoError(t, err)
+	assert.Equal(t, []int8{1, 1}, votes)
+	assert.Equal(t, [][]int64{{1, 2}, {4}}, f.votes)
+	// 投票按用户分别缓存
+	_, err = l.PostVotes(ctx, 2, []int64{2})
+	require.NoError(t, err)
+	assert.Len(t, f.votes, 3)
+}
+
+func TestLoaderDoesNotCacheErrors(t *testing.T) {
+	f := &countingFetchers{err: errors.New("db down")}
+	l := New(f.fetchers())
+	ctx := context.Background()
+	_, err := l.Users(ctx, []int64{1})
+	assert.Error(t, err)
+	f.err = nil
+	users, err := l.Users(ctx, []int64{1})
+	require.NoError(t, err)
+	assert.Len(t, users, 1)
+	assert.Len(t, f.users, 2)
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Nil(t, FromContext(context.Background()))
+	l := New(Fetchers{})
+	assert.Same(t, l, FromContext(NewContext(context.Background(), l)))
+}
+
+type feedSection struct {
+	pids, authors, communities []int64
+}
+
+// feedSections 置顶帖子和一页帖子，帖子比作者和社区多，同一个作者和社区会出现多次
+func feedSections() []feedSection {
+	pinned := []int64{1, 2, 3}
+	page := make([]int64, 0, 20)
+	for i := int64(1); i <= 20; i++ {
+		page = append(page, i)
+	}
+	sections := make([]feedSection, 0, 2)
+	for _, pids := range [][]int64{pinned, page} {
+		s := feedSection{pids: pids}
+		for _, pid := range pids {
+			s.authors = append(s.authors, pid%5)
+			s.communities = append(s.communities, pid%3)
+		}
+		sections = append(sections, s)
+	}
+	return sections
+}
+
+// renderFeed 模拟一次帖子列表的响应：每一部分补充作者、社区和当前用户的投票
+// newLoader每次返回同一个加载器时，先登记所有部分需要的数据再一起查询
+func renderFeed(b *testing.B, newLoader func() *Loader, shared bool) {
+	ctx := context.Background()
+	sections := feedSections()
+	if shared {
+		l := newLoader()
+		for _, s := range sections {
+			l.PrimeUsers(s.authors...)
+			l.PrimeCommunities(s.communities...)
+			l.PrimeVotes(42, s.pids...)
+		}
+		if err := l.Flush(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+	for _, s := range sections {
+		l := newLoader()
+		if _, err := l.Users(ctx, s.authors); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := l.Communities(ctx, s.communities); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := l.PostVotes(ctx, 42, s.pids); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkFeedRenderWithoutLoader 每一部分单独查询，与没有请求级加载器时一样
+func BenchmarkFeedRenderWithoutLoader(b *testing.B) {
+	f := new(countingFetchers)
+	for i := 0; i < b.N; i++ {
+		renderFeed(b, func() *Loader { return New(f.fetchers()) }, false)
+	}
+	b.ReportMetric(float64(f.queries())/float64(b.N), "queries/op")
+}
+
+func BenchmarkFeedRenderWithLoader(b *testing.B) {
+	f := new(countingFetchers)
+	for i := 0; i < b.N; i++ {
+		l := New(f.fetchers())
+		renderFeed(b, func() *Loader { return l }, true)
+	}
+	b.ReportMetric(float64(f.queries())/float64(b.N), "queries/op")
+}
@@ -0,0 +1,70 @@
+// Package lru 并发安全的固定大小的LRU缓存
+package lru
+
+import (
+	"container/list"
+	"sync"
+)
+
+type entry struct {
+	key   string
+	value interface{}
+}
+
+type Cache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+// New 最多保存size个值，size<=0时不保存任何值
+func New(size int) *Cache {
+	return &Cache{size: size, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (c *Cache) Get(key string) (value interface{}, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	return e.Value.(*entry).value, true
+}
+
+// Add 添加或更新key，超过大小时删除最久没有使用的值
+func (c *Cache) Add(key string, value interface{}) {
+	if c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		e.Value.(*entry).value = value
+		c.ll.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&entry{key, value})
+	if c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*entry).key)
+	}
+}
+
+func (c *Cache) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.ll.Remove(e)
+		delete(c.items, key)
+	}
+}
+
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
@@ -0,0 +1,33 @@
+package lru
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	c := New(2)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+	// b最久没有使用，被淘汰
+	c.Add("c", 3)
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+	c.Add("a", 10)
+	v, _ = c.Get("a")
+	assert.Equal(t, 10, v)
+	assert.Equal(t, 2, c.Len())
+	c.Remove("a")
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+
+	c = New(0)
+	c.Add("a", 1)
+	assert.Equal(t, 0, c.Len())
+}
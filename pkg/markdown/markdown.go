@@ -0,0 +1,107 @@
+// Package markdown 把帖子的markdown渲染为可以直接展示的HTML，并提取纯文本摘要
+package markdown
+
+import (
+	"bytes"
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+)
+
+// ExcerptLength 摘要最多包含的字符数
+const ExcerptLength = 200
+
+var (
+	md = goldmark.New(goldmark.WithExtensions(extension.GFM))
+	// ugcPolicy 允许常见的排版标签，去掉script、事件属性和javascript:链接
+	ugcPolicy = bluemonday.UGCPolicy().RequireNoFollowOnLinks(true)
+	// textPolicy 去掉所有标签，goldmark在块级标签之间输出了换行，不会把段落连在一起
+	textPolicy = bluemonday.StrictPolicy()
+)
+
+// Render 返回清理过的HTML和纯文本摘要
+func Render(src string) (safeHTML, excerpt string, err error) {
+	var buf bytes.Buffer
+	if err = md.Convert([]byte(src), &buf); err != nil {
+		return "", "", err
+	}
+	safeHTML = ugcPolicy.Sanitize(buf.String())
+	return safeHTML, excerptFromHTML(safeHTML), nil
+}
+
+// Excerpt 只提取纯文本摘要，渲染失败时使用原文
+func Excerpt(src string) string {
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(src), &buf); err != nil {
+		return truncate(collapseSpace(src), ExcerptLength)
+	}
+	return excerptFromHTML(buf.String())
+}
+
+// PlainText 不截断的纯文本，用于搜索结果的高亮片段，渲染失败时使用原文
+func PlainText(src string) string {
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(src), &buf); err != nil {
+		return collapseSpace(src)
+	}
+	return collapseSpace(html.UnescapeString(textPolicy.Sanitize(buf.String())))
+}
+
+// FirstMedia 返回内容中第一张图片和第一个外部链接的地址，只接受http和https的地址
+func FirstMedia(src string) (image, link string) {
+	source := []byte(src)
+	doc := md.Parser().Parse(text.NewReader(source))
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := n.(type) {
+		case *ast.Image:
+			if image == "" && isExternal(string(node.Destination)) {
+				image = string(node.Destination)
+			}
+		case *ast.Link:
+			if link == "" && isExternal(string(node.Destination)) {
+				link = string(node.Destination)
+			}
+		case *ast.AutoLink:
+			if u := string(node.URL(source)); link == "" && isExternal(u) {
+				link = u
+			}
+		}
+		if image != "" && link != "" {
+			return ast.WalkStop, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return
+}
+
+func isExternal(u string) bool {
+	u = strings.ToLower(u)
+	return strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://")
+}
+
+func excerptFromHTML(s string) string {
+	text := html.UnescapeString(textPolicy.Sanitize(s))
+	return truncate(collapseSpace(text), ExcerptLength)
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// truncate 按字符截断，截断时加上省略号
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	r := []rune(s)
+	return strings.TrimSpace(string(r[:n])) + "…"
+}
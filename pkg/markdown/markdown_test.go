@@ -0,0 +1,59 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	out, excerpt, err := Render("# Title\n\nSome **bold** text and [a link](https://rutgers.edu).")
+	assert.NoError(t, err)
+	assert.Contains(t, out, "<h1>Title</h1>")
+	assert.Contains(t, out, "<strong>bold</strong>")
+	assert.Contains(t, out, `rel="nofollow"`)
+	assert.Equal(t, "Title Some bold text and a link.", excerpt)
+}
+
+func TestRenderSanitize(t *testing.T) {
+	src := "<script>alert(1)</script>\n\n" +
+		`<img src="x" onerror="alert(1)">` + "\n\n" +
+		"[click](javascript:alert(1))\n\n" +
+		`<a href="https://rutgers.edu" onclick="alert(1)">ok</a>`
+	out, _, err := Render(src)
+	assert.NoError(t, err)
+	for _, bad := range []string{"<script", "onerror", "onclick", "javascript:"} {
+		assert.NotContains(t, out, bad)
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	long := strings.Repeat("很长的帖子 ", 100)
+	excerpt := Excerpt(long)
+	assert.True(t, strings.HasSuffix(excerpt, "…"))
+	assert.LessOrEqual(t, len([]rune(excerpt)), ExcerptLength+1)
+
+	assert.Equal(t, "a & b < c", Excerpt("a &amp; b < c"))
+	assert.Equal(t, "", Excerpt(""))
+}
+
+func TestPlainText(t *testing.T) {
+	long := strings.Repeat("word ", 100)
+	assert.Equal(t, strings.TrimSpace(long), PlainText(long))
+	assert.Equal(t, "Title a & b link", PlainText("# Title\n\na &amp; b [link](https://rutgers.edu)"))
+}
+
+func TestFirstMedia(t *testing.T) {
+	image, link := FirstMedia("see [docs](/relative) and [site](https://rutgers.edu)\n\n![pic](https://img.example.com/a.png) ![b](https://img.example.com/b.png)")
+	assert.Equal(t, "https://img.example.com/a.png", image)
+	assert.Equal(t, "https://rutgers.edu", link)
+
+	image, link = FirstMedia("autolink https://example.com/page and ![x](javascript:alert(1))")
+	assert.Equal(t, "", image)
+	assert.Equal(t, "https://example.com/page", link)
+
+	image, link = FirstMedia("plain text")
+	assert.Equal(t, "", image)
+	assert.Equal(t, "", link)
+}
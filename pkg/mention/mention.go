@@ -0,0 +1,53 @@
+// Package mention 提取文本中@username形式的提及
+package mention
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Parse 按出现的顺序返回提及的用户名，忽略大小写去重，最多返回max个，max<=0表示不限制
+// 用户名由字母、数字、下划线、点和减号组成，末尾的点和减号视为标点；
+// @前面紧跟字母或数字时不算提及，例如邮箱地址
+func Parse(content string, max int) []string {
+	var names []string
+	seen := make(map[string]struct{})
+	for i := 0; i < len(content); i++ {
+		if content[i] != '@' {
+			continue
+		}
+		if i > 0 {
+			if r, _ := utf8.DecodeLastRuneInString(content[:i]); isNameRune(r) {
+				continue
+			}
+		}
+		end := i + 1
+		for end < len(content) {
+			r, size := utf8.DecodeRuneInString(content[end:])
+			if !isNameRune(r) && r != '.' && r != '-' {
+				break
+			}
+			end += size
+		}
+		name := strings.TrimRight(content[i+1:end], ".-")
+		i = end - 1
+		if name == "" {
+			continue
+		}
+		key := strings.ToLower(name)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		names = append(names, name)
+		if max > 0 && len(names) >= max {
+			break
+		}
+	}
+	return names
+}
+
+func isNameRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
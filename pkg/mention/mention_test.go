@@ -0,0 +1,16 @@
+package mention
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	assert.Equal(t, []string{"alice", "bob.smith"}, Parse("hi @alice, ask @bob.smith.", 0))
+	assert.Equal(t, []string{"Alice"}, Parse("@Alice @alice @ALICE", 0))
+	assert.Equal(t, []string{"张三"}, Parse("（@张三）", 0))
+	assert.Nil(t, Parse("mail me at abc@rutgers.edu", 0))
+	assert.Nil(t, Parse("@ @- nothing", 0))
+	assert.Equal(t, []string{"a", "b"}, Parse("@a @b @c", 2))
+}
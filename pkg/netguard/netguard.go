@@ -0,0 +1,40 @@
+// Package netguard 拒绝向内网和本机地址发起连接，用于抓取用户提供的链接和发送webhook
+package netguard
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+var ErrForbiddenAddress = errors.New("address is not allowed")
+
+// CheckAddress 用作net.Dialer的Control，在解析域名之后、建立连接之前检查实际的地址，
+// 重定向和DNS重绑定之后的地址同样会被检查
+func CheckAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return ErrForbiddenAddress
+	}
+	for _, block := range privateBlocks {
+		if block.Contains(ip) {
+			return ErrForbiddenAddress
+		}
+	}
+	return nil
+}
+
+// privateBlocks 内网地址段
+var privateBlocks = func() []*net.IPNet {
+	var blocks []*net.IPNet
+	for _, cidr := range []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7"} {
+		_, block, _ := net.ParseCIDR(cidr)
+		blocks = append(blocks, block)
+	}
+	return blocks
+}()
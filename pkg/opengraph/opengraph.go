@@ -0,0 +1,140 @@
+// Package opengraph 抓取网页的OpenGraph标题和图片，用于帖子中外部链接的预览
+package opengraph
+
+import (
+	"context"
+	"errors"
+	"go-web-app/pkg/netguard"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// maxBodySize 只读取网页开头的这部分内容，OpenGraph标签都在head中
+const maxBodySize = 512 << 10
+
+var (
+	ErrForbiddenAddress = netguard.ErrForbiddenAddress
+	ErrNotHTML          = errors.New("opengraph: response is not html")
+)
+
+type Meta struct {
+	Title string `json:"title"`
+	Image string `json:"image"`
+}
+
+// client 拒绝连接内网和本机地址，避免用户通过链接探测内部服务，重定向后的地址同样会被检查
+var client = &http.Client{
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: netguard.CheckAddress,
+		}).DialContext,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     30 * time.Second,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 3 {
+			return errors.New("opengraph: too many redirects")
+		}
+		return nil
+	},
+}
+
+// Fetch 抓取网页并解析OpenGraph信息，超时由ctx控制
+func Fetch(ctx context.Context, rawURL string) (*Meta, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; RUCommunityBot/1.0)")
+	req.Header.Set("Accept", "text/html")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("opengraph: unexpected status " + resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.Contains(ct, "text/html") {
+		return nil, ErrNotHTML
+	}
+	meta := Parse(io.LimitReader(resp.Body, maxBodySize))
+	// og:image可能是相对地址
+	if meta.Image != "" {
+		if u, err := resp.Request.URL.Parse(meta.Image); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			meta.Image = u.String()
+		} else {
+			meta.Image = ""
+		}
+	}
+	return meta, nil
+}
+
+// Parse 从网页的head中读取og:title和og:image，没有og:title时使用title标签
+func Parse(r io.Reader) *Meta {
+	meta := new(Meta)
+	var title string
+	z := html.NewTokenizer(r)
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return finish(meta, title)
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := z.Token()
+			switch t.Data {
+			case "meta":
+				var key, content string
+				for _, attr := range t.Attr {
+					switch attr.Key {
+					case "property", "name":
+						key = strings.ToLower(attr.Val)
+					case "content":
+						content = strings.TrimSpace(attr.Val)
+					}
+				}
+				switch key {
+				case "og:title":
+					if meta.Title == "" {
+						meta.Title = content
+					}
+				case "og:image":
+					if meta.Image == "" {
+						meta.Image = content
+					}
+				}
+			case "title":
+				if tt == html.StartTagToken && z.Next() == html.TextToken {
+					title = strings.TrimSpace(string(z.Text()))
+				}
+			case "body":
+				return finish(meta, title)
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "head" {
+				return finish(meta, title)
+			}
+		}
+	}
+}
+
+func finish(meta *Meta, title string) *Meta {
+	if meta.Title == "" {
+		meta.Title = title
+	}
+	return meta
+}
+
+// IsValidURL 只抓取http和https的地址
+func IsValidURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,45 @@
+package opengraph
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	page := `<!doctype html><html><head>
+<title> Fallback title </title>
+<meta property="og:title" content="Rutgers News">
+<meta property="og:image" content="/img/cover.png" />
+</head><body><meta property="og:title" content="ignored"></body></html>`
+	meta := Parse(strings.NewReader(page))
+	assert.Equal(t, "Rutgers News", meta.Title)
+	assert.Equal(t, "/img/cover.png", meta.Image)
+
+	meta = Parse(strings.NewReader(`<html><head><title>Only title</title></head></html>`))
+	assert.Equal(t, "Only title", meta.Title)
+	assert.Equal(t, "", meta.Image)
+}
+
+func TestFetchRejectsLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<meta property="og:title" content="internal">`))
+	}))
+	defer srv.Close()
+
+	_, err := Fetch(context.Background(), srv.URL)
+	assert.True(t, errors.Is(err, ErrForbiddenAddress), "got %v", err)
+}
+
+func TestIsValidURL(t *testing.T) {
+	assert.True(t, IsValidURL("https://rutgers.edu/a?b=c"))
+	assert.False(t, IsValidURL("javascript:alert(1)"))
+	assert.False(t, IsValidURL("ftp://example.com"))
+	assert.False(t, IsValidURL("https://"))
+}
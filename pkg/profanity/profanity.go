@@ -0,0 +1,165 @@
+// Package profanity 检查文本中的敏感词，能识别用空格隔开的字母(f u c k)和重复的字母(fuuuck)
+package profanity
+
+import "unicode"
+
+// run 连续相同的字符
+type run struct {
+	r rune
+	n int
+}
+
+// toRuns 转为小写后把连续相同的字符合并
+func toRuns(s []rune) []run {
+	var runs []run
+	for _, r := range s {
+		r = unicode.ToLower(r)
+		if len(runs) > 0 && runs[len(runs)-1].r == r {
+			runs[len(runs)-1].n++
+			continue
+		}
+		runs = append(runs, run{r: r, n: 1})
+	}
+	return runs
+}
+
+// token 文本中连续的字母和数字，start和end是在[]rune中的下标
+type token struct {
+	start, end int
+}
+
+type Filter struct {
+	words [][]run
+}
+
+// New 用词表创建过滤器，词中的非字母数字字符会被忽略
+func New(words []string) *Filter {
+	f := new(Filter)
+	for _, w := range words {
+		var letters []rune
+		for _, r := range w {
+			if isLetter(r) {
+				letters = append(letters, r)
+			}
+		}
+		if len(letters) > 0 {
+			f.words = append(f.words, toRuns(letters))
+		}
+	}
+	return f
+}
+
+func isLetter(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Contains 文本中是否有敏感词
+func (f *Filter) Contains(text string) bool {
+	return len(f.find([]rune(text))) > 0
+}
+
+// Mask 把敏感词中的字母替换为*，其余字符保持不变
+func (f *Filter) Mask(text string) string {
+	rs := []rune(text)
+	spans := f.find(rs)
+	if len(spans) == 0 {
+		return text
+	}
+	for _, span := range spans {
+		for i := span.start; i < span.end; i++ {
+			if isLetter(rs[i]) {
+				rs[i] = '*'
+			}
+		}
+	}
+	return string(rs)
+}
+
+// find 返回所有命中敏感词的区间
+func (f *Filter) find(rs []rune) []token {
+	if len(f.words) == 0 {
+		return nil
+	}
+	tokens := tokenize(rs)
+	var spans []token
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.end-t.start > 1 {
+			if f.match(rs[t.start:t.end]) {
+				spans = append(spans, t)
+			}
+			continue
+		}
+		// 连续的单个字母可能是被拆开的词，检查其中每一段
+		j := i
+		for j+1 < len(tokens) && tokens[j+1].end-tokens[j+1].start == 1 {
+			j++
+		}
+		spans = append(spans, f.matchSpelled(rs, tokens[i:j+1])...)
+		i = j
+	}
+	return spans
+}
+
+func (f *Filter) matchSpelled(rs []rune, letters []token) []token {
+	var spans []token
+	for i := 0; i < len(letters); i++ {
+		for j := len(letters) - 1; j >= i; j-- {
+			word := make([]rune, 0, j-i+1)
+			for _, t := range letters[i : j+1] {
+				word = append(word, rs[t.start])
+			}
+			if f.match(word) {
+				spans = append(spans, token{start: letters[i].start, end: letters[j].end})
+				i = j
+				break
+			}
+		}
+	}
+	return spans
+}
+
+// match 词中每个字符重复的次数不少于敏感词中的次数时认为命中，
+// 这样fuuuck能匹配fuck，但as不会匹配ass
+func (f *Filter) match(word []rune) bool {
+	runs := toRuns(word)
+	for _, w := range f.words {
+		if matchRuns(runs, w) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchRuns(text, word []run) bool {
+	if len(text) != len(word) {
+		return false
+	}
+	for i := range text {
+		if text[i].r != word[i].r || text[i].n < word[i].n {
+			return false
+		}
+	}
+	return true
+}
+
+func tokenize(rs []rune) []token {
+	var tokens []token
+	start := -1
+	for i, r := range rs {
+		if isLetter(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, token{start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{start: start, end: len(rs)})
+	}
+	return tokens
+}
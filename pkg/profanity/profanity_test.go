@@ -0,0 +1,25 @@
+package profanity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter(t *testing.T) {
+	f := New([]string{"Darn", "ass", ""})
+
+	assert.False(t, f.Contains("a class assignment"))
+	assert.False(t, f.Contains("as far as I know"))
+	assert.True(t, f.Contains("oh DARN it"))
+	assert.True(t, f.Contains("daaaarn"))
+	assert.True(t, f.Contains("d a r n"))
+	assert.True(t, f.Contains("x d.a.r.n"))
+	assert.True(t, f.Contains("you asssss"))
+
+	assert.Equal(t, "oh **** it", f.Mask("oh darn it"))
+	assert.Equal(t, "* * * * you", f.Mask("d a r n you"))
+	assert.Equal(t, "nothing here", f.Mask("nothing here"))
+
+	assert.False(t, New(nil).Contains("darn"))
+}
@@ -0,0 +1,90 @@
+// Package quiethours 用户设置的免打扰时段，按用户所在的时区计算
+// 结束时间早于开始时间表示跨过午夜，例如22:00-07:00
+package quiethours
+
+import (
+	"errors"
+	"sync"
+	"time"
+	// 部署的镜像中可能没有时区数据
+	_ "time/tzdata"
+)
+
+var (
+	ErrInvalidClock    = errors.New("time must be in HH:MM format")
+	ErrInvalidTimezone = errors.New("invalid time zone")
+	ErrEmptyWindow     = errors.New("start and end must be different")
+)
+
+// locations LoadLocation每次都会读取时区数据，解析过的时区缓存起来
+var locations sync.Map
+
+type Window struct {
+	start, end int // 一天中的第几分钟
+	loc        *time.Location
+}
+
+// ParseClock 解析HH:MM，返回一天中的第几分钟
+func ParseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil || len(s) != 5 {
+		return 0, ErrInvalidClock
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// LoadLocation 只接受IANA时区名称，例如America/New_York，不接受空字符串和Local
+func LoadLocation(name string) (*time.Location, error) {
+	if v, ok := locations.Load(name); ok {
+		return v.(*time.Location), nil
+	}
+	if name == "" || name == "Local" {
+		return nil, ErrInvalidTimezone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, ErrInvalidTimezone
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// New start和end为HH:MM，tz为IANA时区名称
+func New(start, end, tz string) (*Window, error) {
+	s, err := ParseClock(start)
+	if err != nil {
+		return nil, err
+	}
+	e, err := ParseClock(end)
+	if err != nil {
+		return nil, err
+	}
+	if s == e {
+		return nil, ErrEmptyWindow
+	}
+	loc, err := LoadLocation(tz)
+	if err != nil {
+		return nil, err
+	}
+	return &Window{start: s, end: e, loc: loc}, nil
+}
+
+// Contains t是否在免打扰时段内，包含开始时间，不包含结束时间
+func (w *Window) Contains(t time.Time) bool {
+	local := t.In(w.loc)
+	m := local.Hour()*60 + local.Minute()
+	if w.start < w.end {
+		return m >= w.start && m < w.end
+	}
+	return m >= w.start || m < w.end
+}
+
+// End t之后最近的一次结束时间，t在时段内时即为这一段免打扰结束的时间
+func (w *Window) End(t time.Time) time.Time {
+	local := t.In(w.loc)
+	end := time.Date(local.Year(), local.Month(), local.Day(), w.end/60, w.end%60, 0, 0, w.loc)
+	if !end.After(t) {
+		end = time.Date(local.Year(), local.Month(), local.Day()+1, w.end/60, w.end%60, 0, 0, w.loc)
+	}
+	return end
+}
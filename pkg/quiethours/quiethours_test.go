@@ -0,0 +1,89 @@
+package quiethours
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	loc, err := LoadLocation(name)
+	require.NoError(t, err)
+	return loc
+}
+
+func TestNew(t *testing.T) {
+	_, err := New("22:00", "07:00", "America/New_York")
+	assert.NoError(t, err)
+	_, err = New("7:00", "08:00", "UTC")
+	assert.Equal(t, ErrInvalidClock, err)
+	_, err = New("24:00", "08:00", "UTC")
+	assert.Equal(t, ErrInvalidClock, err)
+	_, err = New("08:00", "08:00", "UTC")
+	assert.Equal(t, ErrEmptyWindow, err)
+	for _, tz := range []string{"", "Local", "Mars/Olympus_Mons"} {
+		_, err = New("22:00", "07:00", tz)
+		assert.Equal(t, ErrInvalidTimezone, err, tz)
+	}
+}
+
+func TestContainsWrapMidnight(t *testing.T) {
+	ny := mustLoad(t, "America/New_York")
+	w, err := New("22:00", "07:00", "America/New_York")
+	require.NoError(t, err)
+	tests := []struct {
+		at    time.Time
+		quiet bool
+	}{
+		{time.Date(2021, 1, 10, 21, 59, 0, 0, ny), false},
+		{time.Date(2021, 1, 10, 22, 0, 0, 0, ny), true},
+		{time.Date(2021, 1, 10, 23, 59, 0, 0, ny), true},
+		{time.Date(2021, 1, 11, 0, 0, 0, 0, ny), true},
+		{time.Date(2021, 1, 11, 3, 0, 0, 0, ny), true},
+		{time.Date(2021, 1, 11, 6, 59, 0, 0, ny), true},
+		{time.Date(2021, 1, 11, 7, 0, 0, 0, ny), false},
+		{time.Date(2021, 1, 11, 12, 0, 0, 0, ny), false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.quiet, w.Contains(tt.at), tt.at.String())
+		// 用其他时区表示的同一时刻结果相同
+		assert.Equal(t, tt.quiet, w.Contains(tt.at.UTC()), tt.at.UTC().String())
+	}
+}
+
+func TestContainsAcrossTimezones(t *testing.T) {
+	// 上海的23:00-06:00，UTC 15:00-22:00，在UTC中不跨午夜
+	w, err := New("23:00", "06:00", "Asia/Shanghai")
+	require.NoError(t, err)
+	assert.False(t, w.Contains(time.Date(2021, 6, 1, 14, 59, 0, 0, time.UTC)))
+	assert.True(t, w.Contains(time.Date(2021, 6, 1, 15, 0, 0, 0, time.UTC)))
+	assert.True(t, w.Contains(time.Date(2021, 6, 1, 21, 59, 0, 0, time.UTC)))
+	assert.False(t, w.Contains(time.Date(2021, 6, 1, 22, 0, 0, 0, time.UTC)))
+
+	// 不跨午夜的时段在UTC中跨过了日期: 纽约的20:00-23:00是UTC第二天的00:00-03:00(夏令时)
+	w, err = New("20:00", "23:00", "America/New_York")
+	require.NoError(t, err)
+	assert.True(t, w.Contains(time.Date(2021, 6, 2, 0, 30, 0, 0, time.UTC)))
+	assert.False(t, w.Contains(time.Date(2021, 6, 2, 3, 0, 0, 0, time.UTC)))
+	assert.False(t, w.Contains(time.Date(2021, 6, 1, 23, 59, 0, 0, time.UTC)))
+}
+
+func TestEnd(t *testing.T) {
+	ny := mustLoad(t, "America/New_York")
+	w, err := New("22:00", "07:00", "America/New_York")
+	require.NoError(t, err)
+	// 午夜之前开始的免打扰在第二天结束
+	assert.Equal(t, time.Date(2021, 1, 11, 7, 0, 0, 0, ny), w.End(time.Date(2021, 1, 10, 23, 0, 0, 0, ny)))
+	// 午夜之后在当天结束
+	assert.Equal(t, time.Date(2021, 1, 11, 7, 0, 0, 0, ny), w.End(time.Date(2021, 1, 11, 1, 0, 0, 0, ny)))
+	// 夏令时开始的那一夜少一个小时
+	end := w.End(time.Date(2021, 3, 13, 23, 0, 0, 0, ny))
+	assert.Equal(t, time.Date(2021, 3, 14, 7, 0, 0, 0, ny), end)
+	assert.Equal(t, 7*time.Hour, end.Sub(time.Date(2021, 3, 13, 23, 0, 0, 0, ny)))
+	// 东八区的结束时间在UTC中是前一天
+	w, err = New("23:00", "06:00", "Asia/Shanghai")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2021, 6, 1, 22, 0, 0, 0, time.UTC), w.End(time.Date(2021, 6, 1, 16, 0, 0, 0, time.UTC)).UTC())
+}
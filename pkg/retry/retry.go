@@ -0,0 +1,35 @@
+// Package retry 启动时等待依赖的服务就绪，失败后按指数退避重试
+package retry
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Policy 最多尝试Attempts次，第一次失败后等待Backoff，之后每次翻倍，不超过MaxBackoff
+type Policy struct {
+	Attempts   int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// sleep 测试中替换，避免真的等待
+var sleep = time.Sleep
+
+// Do 执行fn直到成功或用完重试次数，返回最后一次的错误，Attempts<=1时只执行一次
+func Do(name string, p Policy, fn func() error) (err error) {
+	wait := p.Backoff
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil || attempt >= p.Attempts {
+			return err
+		}
+		zap.L().Warn("dependency not ready, will retry",
+			zap.String("name", name), zap.Int("attempt", attempt), zap.Int("max_attempts", p.Attempts),
+			zap.Duration("wait", wait), zap.Error(err))
+		sleep(wait)
+		if wait *= 2; p.MaxBackoff > 0 && wait > p.MaxBackoff {
+			wait = p.MaxBackoff
+		}
+	}
+}
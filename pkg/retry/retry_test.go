@@ -0,0 +1,46 @@
+package retry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDo(t *testing.T) {
+	var waits []time.Duration
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+	defer func() { sleep = time.Sleep }()
+	p := Policy{Attempts: 5, Backoff: time.Second, MaxBackoff: 3 * time.Second}
+
+	errNotReady := errors.New("not ready")
+	calls := 0
+	err := Do("mysql", p, func() error {
+		if calls++; calls < 4 {
+			return errNotReady
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, calls)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}, waits)
+
+	// 用完重试次数后返回最后一次的错误
+	waits, calls = nil, 0
+	err = Do("mysql", p, func() error {
+		calls++
+		return errNotReady
+	})
+	assert.Equal(t, errNotReady, err)
+	assert.Equal(t, 5, calls)
+	assert.Len(t, waits, 4)
+
+	// 不重试
+	calls = 0
+	assert.Equal(t, errNotReady, Do("mysql", Policy{}, func() error {
+		calls++
+		return errNotReady
+	}))
+	assert.Equal(t, 1, calls)
+}
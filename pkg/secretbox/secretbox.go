@@ -0,0 +1,62 @@
+// Package secretbox 使用AES-256-GCM加密保存在数据库中的小段敏感数据，例如两步验证的密钥
+// 密文为base64编码的nonce和加密结果，附加数据（例如用户id）不同时无法解密，防止密文被复制到其他行
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+var (
+	ErrNoKey      = errors.New("secretbox: key is not configured")
+	ErrDecryption = errors.New("secretbox: decryption failed")
+)
+
+// Box 用配置的密钥加密和解密，可以被多个goroutine同时使用
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New 密钥可以是任意长度的字符串，通过SHA-256得到AES-256的密钥
+func New(key string) (*Box, error) {
+	if key == "" {
+		return nil, ErrNoKey
+	}
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+func (b *Box) Seal(plaintext, additional []byte) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, plaintext, additional)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open 密钥或附加数据不对、密文被修改时返回ErrDecryption
+func (b *Box) Open(ciphertext string, additional []byte) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil || len(sealed) < b.aead.NonceSize() {
+		return nil, ErrDecryption
+	}
+	n := b.aead.NonceSize()
+	plaintext, err := b.aead.Open(nil, sealed[:n], sealed[n:], additional)
+	if err != nil {
+		return nil, ErrDecryption
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,34 @@
+package secretbox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealOpen(t *testing.T) {
+	box, err := New("key")
+	require.NoError(t, err)
+	a, err := box.Seal([]byte("secret"), []byte("1"))
+	require.NoError(t, err)
+	b, err := box.Seal([]byte("secret"), []byte("1"))
+	require.NoError(t, err)
+	// 每次使用不同的nonce
+	assert.NotEqual(t, a, b)
+	plain, err := box.Open(a, []byte("1"))
+	require.NoError(t, err)
+	assert.Equal(t, "secret", string(plain))
+
+	_, err = box.Open(a, []byte("2"))
+	assert.Equal(t, ErrDecryption, err)
+	other, err := New("other key")
+	require.NoError(t, err)
+	_, err = other.Open(a, []byte("1"))
+	assert.Equal(t, ErrDecryption, err)
+	_, err = box.Open("bm90IGVub3VnaA", []byte("1"))
+	assert.Equal(t, ErrDecryption, err)
+
+	_, err = New("")
+	assert.Equal(t, ErrNoKey, err)
+}
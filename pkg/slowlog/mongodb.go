@@ -0,0 +1,77 @@
+package slowlog
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+const dbMongoDB = "mongodb"
+
+// filterFields 这些字段中的文档只记录字段名，不记录值
+var filterFields = []string{"filter", "q", "query"}
+
+// CommandMonitor 记录执行时间超过阈值的MongoDB命令
+// 开始和结束的回调都在发起命令的goroutine中执行，可以从调用栈中找到dao函数
+func CommandMonitor() *event.CommandMonitor {
+	var started sync.Map // request id -> statement
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, e *event.CommandStartedEvent) {
+			if Threshold() > 0 {
+				started.Store(e.RequestID, commandStatement(e))
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			finish(ctx, &started, &e.CommandFinishedEvent, nil)
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			finish(ctx, &started, &e.CommandFinishedEvent, errString(e.Failure))
+		},
+	}
+}
+
+func finish(ctx context.Context, started *sync.Map, e *event.CommandFinishedEvent, err error) {
+	v, ok := started.Load(e.RequestID)
+	if !ok {
+		return
+	}
+	started.Delete(e.RequestID)
+	Log(ctx, dbMongoDB, v.(string), time.Duration(e.DurationNanos), err)
+}
+
+// commandStatement 命令名、集合和查询条件中的字段名，例如 find comment {post_id, status}
+func commandStatement(e *event.CommandStartedEvent) string {
+	parts := []string{e.CommandName}
+	if coll, ok := e.Command.Lookup(e.CommandName).StringValueOK(); ok {
+		parts = append(parts, coll)
+	}
+	for _, name := range filterFields {
+		if doc, ok := e.Command.Lookup(name).DocumentOK(); ok {
+			parts = append(parts, "{"+strings.Join(documentKeys(doc), ", ")+"}")
+			break
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func documentKeys(doc bson.Raw) []string {
+	elems, err := doc.Elements()
+	if err != nil {
+		return nil
+	}
+	keys := make([]string, 0, len(elems))
+	for _, elem := range elems {
+		keys = append(keys, elem.Key())
+	}
+	return keys
+}
+
+type errString string
+
+func (e errString) Error() string {
+	return string(e)
+}
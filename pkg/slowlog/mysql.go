@@ -0,0 +1,164 @@
+package slowlog
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"time"
+)
+
+const dbMySQL = "mysql"
+
+var errNamedArgs = errors.New("slowlog: driver does not support named arguments")
+
+// WrapDriver 包装database/sql的驱动，记录执行时间超过阈值的语句
+// 查询只统计到返回结果为止，不包含读取每一行的时间
+func WrapDriver(d driver.Driver) driver.Driver {
+	return &wrappedDriver{d}
+}
+
+type wrappedDriver struct {
+	driver.Driver
+}
+
+func (d *wrappedDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{c}, nil
+}
+
+type conn struct {
+	driver.Conn
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		s   driver.Stmt
+		err error
+	)
+	if pc, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		s, err = pc.PrepareContext(ctx, query)
+	} else {
+		s, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{Stmt: s, query: query}, nil
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if bc, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return bc.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+// QueryContext 驱动不支持直接执行带参数的语句时返回driver.ErrSkip，database/sql改为先prepare再执行，由stmt统计
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	qc, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := qc.QueryContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		Log(ctx, dbMySQL, query, time.Since(start), err)
+	}
+	return rows, err
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	ec, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	ret, err := ec.ExecContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		Log(ctx, dbMySQL, query, time.Since(start), err)
+	}
+	return ret, err
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nc, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return nc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+type stmt struct {
+	driver.Stmt
+	query string
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var (
+		ret driver.Result
+		err error
+	)
+	if ec, ok := s.Stmt.(driver.StmtExecContext); ok {
+		ret, err = ec.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValues(args); err == nil {
+			ret, err = s.Stmt.Exec(values)
+		}
+	}
+	Log(ctx, dbMySQL, s.query, time.Since(start), err)
+	return ret, err
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var (
+		rows driver.Rows
+		err  error
+	)
+	if qc, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = qc.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValues(args); err == nil {
+			rows, err = s.Stmt.Query(values)
+		}
+	}
+	Log(ctx, dbMySQL, s.query, time.Since(start), err)
+	return rows, err
+}
+
+func (s *stmt) ColumnConverter(idx int) driver.ValueConverter {
+	if cc, ok := s.Stmt.(driver.ColumnConverter); ok {
+		return cc.ColumnConverter(idx)
+	}
+	return driver.DefaultParameterConverter
+}
+
+func namedValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errNamedArgs
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
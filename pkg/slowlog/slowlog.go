@@ -0,0 +1,60 @@
+// Package slowlog 记录超过阈值的数据库操作，与tracing不同，不依赖外部的采集端，默认开启
+// MySQL通过包装驱动实现，MongoDB通过CommandMonitor实现，dao层的代码不需要修改
+package slowlog
+
+import (
+	"context"
+	"go-web-app/logger"
+	"go-web-app/settings"
+	"runtime"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// daoPrefix 调用栈中第一个这个前缀的函数就是发起查询的dao函数
+const daoPrefix = "go-web-app/dao/"
+
+// Threshold 当前的阈值，关闭时返回0
+func Threshold() time.Duration {
+	cfg := settings.Current().SlowQueryConfig
+	if cfg == nil || !cfg.Enable || cfg.Threshold <= 0 {
+		return 0
+	}
+	return time.Duration(cfg.Threshold) * time.Millisecond
+}
+
+// Log cost超过阈值时记录一条警告日志，statement只包含占位符，不包含参数的值
+func Log(ctx context.Context, db, statement string, cost time.Duration, err error) {
+	threshold := Threshold()
+	if threshold <= 0 || cost < threshold {
+		return
+	}
+	fields := []zap.Field{
+		zap.String("db", db),
+		zap.String("caller", caller()),
+		zap.String("statement", statement),
+		zap.Duration("cost", cost),
+	}
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	logger.L(ctx).Warn("slow query", fields...)
+}
+
+// caller 只在查询超过阈值时调用，不影响正常查询的开销
+func caller() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if strings.HasPrefix(frame.Function, daoPrefix) {
+			return strings.TrimPrefix(frame.Function, daoPrefix)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
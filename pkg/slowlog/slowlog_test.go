@@ -0,0 +1,68 @@
+package slowlog
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"go-web-app/settings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// fakeDriver 执行时间由语句决定，slow开头的语句执行50毫秒
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, driver.ErrSkip }
+
+func (fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if query[:4] == "slow" {
+		time.Sleep(50 * time.Millisecond)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func TestWrapDriver(t *testing.T) {
+	old := settings.Conf.SlowQueryConfig
+	settings.Conf.SlowQueryConfig = &settings.SlowQueryConfig{Enable: true, Threshold: 20}
+	core, logs := observer.New(zapcore.WarnLevel)
+	restore := zap.ReplaceGlobals(zap.New(core))
+	t.Cleanup(func() {
+		settings.Conf.SlowQueryConfig = old
+		restore()
+	})
+
+	sql.Register("slowlog-test", WrapDriver(fakeDriver{}))
+	db, err := sql.Open("slowlog-test", "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("fast update")
+	require.NoError(t, err)
+	assert.Equal(t, 0, logs.Len())
+
+	_, err = db.Exec("slow update post set title = ? where post_id = ?", "secret title", 1)
+	require.NoError(t, err)
+	if assert.Equal(t, 1, logs.Len()) {
+		fields := logs.All()[0].ContextMap()
+		assert.Equal(t, "mysql", fields["db"])
+		assert.Equal(t, "slow update post set title = ? where post_id = ?", fields["statement"])
+	}
+
+	// 关闭后不再记录
+	settings.Conf.SlowQueryConfig.Enable = false
+	_, err = db.Exec("slow update")
+	require.NoError(t, err)
+	assert.Equal(t, 1, logs.Len())
+}
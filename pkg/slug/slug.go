@@ -0,0 +1,45 @@
+// Package slug 把帖子标题转换为URL中使用的slug
+package slug
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Fallback 标题中没有字母和数字时使用的slug
+const Fallback = "post"
+
+// Make 转为小写，字母和数字之外的字符替换为连字符，连续的连字符合并，最多保留maxLen个字符，maxLen为0表示不截断
+// 截断时尽量在连字符处断开，不留下半个单词
+func Make(title string, maxLen int) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			hyphen = false
+			b.WriteRune(r)
+			continue
+		}
+		hyphen = true
+	}
+	s := []rune(b.String())
+	if maxLen > 0 && len(s) > maxLen {
+		cut := maxLen
+		// 在后半部分找一个连字符断开，找不到时直接截断
+		for i := maxLen; i > maxLen/2; i-- {
+			if s[i] == '-' {
+				cut = i
+				break
+			}
+		}
+		s = s[:cut]
+	}
+	res := strings.Trim(string(s), "-")
+	if res == "" {
+		return Fallback
+	}
+	return res
+}
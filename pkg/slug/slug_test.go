@@ -0,0 +1,30 @@
+package slug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMake(t *testing.T) {
+	for title, want := range map[string]string{
+		"Hello, World!":               "hello-world",
+		"  CS 111 -- Midterm  Tips  ": "cs-111-midterm-tips",
+		"C++ & Go/Rust?":              "c-go-rust",
+		"Café au lait":                "café-au-lait",
+		"期中考试 复习":                     "期中考试-复习",
+		"!!!":                         Fallback,
+		"":                            Fallback,
+	} {
+		assert.Equal(t, want, Make(title, 60), title)
+	}
+}
+
+func TestMakeTruncates(t *testing.T) {
+	// 在连字符处断开
+	assert.Equal(t, "where-to-find", Make("Where to find cheap textbooks", 16))
+	// 后半部分没有连字符时直接截断
+	assert.Equal(t, "abcdefgh", Make("abcdefghijklmnop", 8))
+	assert.Equal(t, "ab-c", Make("ab cdefghijklmnop", 4))
+	assert.Equal(t, "abcdefghijklmnop", Make("abcdefghijklmnop", 0))
+}
@@ -1,12 +1,20 @@
 package snowflake
 
 import (
+	"errors"
+	"sync/atomic"
 	"time"
 
 	sf "github.com/bwmarrin/snowflake"
 )
 
-var node *sf.Node
+var (
+	node    *sf.Node
+	stopped int32
+)
+
+// ErrStopped 机器id的租约丢失后不再生成id
+var ErrStopped = errors.New("snowflake: machine id lease lost, id generation stopped")
 
 func Init(startTime string, machineID int64) (err error) {
 	var st time.Time
@@ -16,8 +24,62 @@ func Init(startTime string, machineID int64) (err error) {
 	}
 	sf.Epoch = st.UnixNano() / 1000000
 	node, err = sf.NewNode(machineID)
+	atomic.StoreInt32(&stopped, 0)
 	return
 }
+
+// Stop 停止生成id，之后调用GenID会panic、TryGenID返回ErrStopped，避免和拿到同一个机器id的其他实例生成重复的id
+func Stop() {
+	atomic.StoreInt32(&stopped, 1)
+}
+
+// MaxMachineID 机器id的最大值，取值范围为[0, MaxMachineID]
+func MaxMachineID() int64 {
+	return -1 ^ (-1 << sf.NodeBits)
+}
+
 func GenID() int64 {
-	return node.Generate().Int64()
+	id, err := TryGenID()
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// TryGenID 停止生成id后返回ErrStopped，用于后台任务等panic不会被recovery捕获的地方
+func TryGenID() (int64, error) {
+	if atomic.LoadInt32(&stopped) == 1 {
+		return 0, ErrStopped
+	}
+	return node.Generate().Int64(), nil
+}
+
+// Time 返回id生成的时间
+func Time(id int64) time.Time {
+	ms := sf.ParseInt64(id).Time()
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+// Millis 返回id生成时间的毫秒时间戳
+func Millis(id int64) int64 {
+	return sf.ParseInt64(id).Time()
+}
+
+// EpochMillis 和 TimeShift 用于在lua脚本中从id算出生成时间: (id >> TimeShift) + EpochMillis
+func EpochMillis() int64 {
+	return sf.Epoch
+}
+
+func TimeShift() uint8 {
+	return sf.NodeBits + sf.StepBits
+}
+
+// MinID 返回t这一毫秒内能生成的最小id，小于它的id都生成于t之前。
+// 按id范围查询就相当于按时间查询，早于Epoch的时间返回0
+func MinID(t time.Time) int64 {
+	ms := t.UnixNano()/int64(time.Millisecond) - sf.Epoch
+	if ms < 0 {
+		return 0
+	}
+	return ms << (sf.NodeBits + sf.StepBits)
 }
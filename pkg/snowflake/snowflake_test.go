@@ -0,0 +1,48 @@
+package snowflake
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeRoundTrip(t *testing.T) {
+	if !assert.Nil(t, Init("2020-07-01", 1)) {
+		return
+	}
+	before := time.Now().Truncate(time.Millisecond)
+	id := GenID()
+	after := time.Now()
+
+	// id中的时间戳是相对于StartTime的
+	ts := Time(id)
+	assert.False(t, ts.Before(before))
+	assert.False(t, ts.After(after))
+
+	// 同一毫秒内的最小id不大于生成的id，下一毫秒的最小id大于它
+	assert.LessOrEqual(t, MinID(ts), id)
+	assert.Greater(t, MinID(ts.Add(time.Millisecond)), id)
+	assert.True(t, Time(MinID(ts)).Equal(ts))
+
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.True(t, Time(MinID(at)).Equal(at))
+
+	// 早于StartTime的时间没有对应的id
+	assert.Equal(t, int64(0), MinID(time.Date(2020, 6, 30, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestStop(t *testing.T) {
+	if !assert.Nil(t, Init("2020-07-01", 1)) {
+		return
+	}
+	Stop()
+	assert.PanicsWithValue(t, ErrStopped, func() { GenID() })
+	_, err := TryGenID()
+	assert.Equal(t, ErrStopped, err)
+	// 重新初始化之后恢复
+	assert.Nil(t, Init("2020-07-01", 2))
+	assert.NotPanics(t, func() { GenID() })
+	_, err = TryGenID()
+	assert.Nil(t, err)
+}
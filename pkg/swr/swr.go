@@ -0,0 +1,118 @@
+// Package swr 进程内的stale-while-revalidate缓存
+// 结果在ttl内直接返回，过期后的grace内先返回旧的结果并在后台刷新，只有完全没有可用的结果时才等待加载
+package swr
+
+import (
+	"go-web-app/pkg/lru"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+// Policy TTL<=0时不缓存，每次都调用load
+type Policy struct {
+	TTL   time.Duration
+	Grace time.Duration
+}
+
+// Result 结果的来源
+type Result int
+
+const (
+	Miss  Result = iota // 没有可用的缓存，等待加载
+	Fresh               // 缓存还在ttl内
+	Stale               // 缓存已经过期，在后台刷新
+)
+
+func (r Result) String() string {
+	switch r {
+	case Fresh:
+		return "fresh"
+	case Stale:
+		return "stale"
+	}
+	return "miss"
+}
+
+type entry struct {
+	value    interface{}
+	storedAt time.Time
+}
+
+// Cache 缓存的值会被多个请求共享，调用方不能修改
+type Cache struct {
+	entries *lru.Cache
+	group   singleflight.Group
+	now     func() time.Time
+
+	mu         sync.Mutex
+	refreshing map[string]struct{}
+}
+
+// New 最多保存size个结果，超过时淘汰最久没有使用的结果
+func New(size int) *Cache {
+	return &Cache{entries: lru.New(size), now: time.Now, refreshing: make(map[string]struct{})}
+}
+
+// Get 按policy返回key的结果，同一个key同时未命中时只调用一次load
+// load也会在后台刷新时调用，不能使用请求的ctx；加载失败的结果不缓存
+func (c *Cache) Get(key string, p Policy, load func() (interface{}, error)) (interface{}, Result, error) {
+	if p.TTL <= 0 {
+		v, err := load()
+		return v, Miss, err
+	}
+	if v, ok := c.entries.Get(key); ok {
+		e := v.(*entry)
+		age := c.now().Sub(e.storedAt)
+		if age < p.TTL {
+			return e.value, Fresh, nil
+		}
+		if age < p.TTL+p.Grace {
+			c.refresh(key, load)
+			return e.value, Stale, nil
+		}
+	}
+	v, err, _ := c.group.Do(key, func() (interface{}, error) {
+		return c.load(key, load)
+	})
+	return v, Miss, err
+}
+
+func (c *Cache) load(key string, load func() (interface{}, error)) (interface{}, error) {
+	v, err := load()
+	if err != nil {
+		return nil, err
+	}
+	c.entries.Add(key, &entry{value: v, storedAt: c.now()})
+	return v, nil
+}
+
+// refresh 每个key同时只有一个后台刷新，刷新失败时继续返回旧的结果直到grace结束
+func (c *Cache) refresh(key string, load func() (interface{}, error)) {
+	c.mu.Lock()
+	if _, ok := c.refreshing[key]; ok {
+		c.mu.Unlock()
+		return
+	}
+	c.refreshing[key] = struct{}{}
+	c.mu.Unlock()
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.refreshing, key)
+			c.mu.Unlock()
+		}()
+		if _, err, _ := c.group.Do(key, func() (interface{}, error) {
+			return c.load(key, load)
+		}); err != nil {
+			zap.L().Warn("swr refresh failed", zap.String("key", key), zap.Error(err))
+		}
+	}()
+}
+
+// Delete 删除key的结果，下次读取时等待重新加载
+func (c *Cache) Delete(key string) {
+	c.entries.Remove(key)
+}
@@ -0,0 +1,72 @@
+package swr
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	now := time.Now()
+	c := New(10)
+	c.now = func() time.Time { return now }
+	p := Policy{TTL: time.Minute, Grace: time.Minute}
+	var calls int32
+	load := func() (interface{}, error) {
+		return atomic.AddInt32(&calls, 1), nil
+	}
+
+	v, r, err := c.Get("a", p, load)
+	assert.NoError(t, err)
+	assert.Equal(t, Miss, r)
+	assert.Equal(t, int32(1), v)
+	v, r, _ = c.Get("a", p, load)
+	assert.Equal(t, Fresh, r)
+	assert.Equal(t, int32(1), v)
+
+	// 过期后先返回旧的结果，后台刷新之后返回新的结果
+	now = now.Add(90 * time.Second)
+	v, r, _ = c.Get("a", p, load)
+	assert.Equal(t, Stale, r)
+	assert.Equal(t, int32(1), v)
+	assert.Eventually(t, func() bool {
+		v, r, _ = c.Get("a", p, load)
+		return r == Fresh && v == int32(2)
+	}, time.Second, time.Millisecond)
+
+	// 超过grace之后等待加载
+	now = now.Add(3 * time.Minute)
+	v, r, _ = c.Get("a", p, load)
+	assert.Equal(t, Miss, r)
+	assert.Equal(t, int32(3), v)
+
+	c.Delete("a")
+	_, r, _ = c.Get("a", p, load)
+	assert.Equal(t, Miss, r)
+}
+
+func TestCacheLoadError(t *testing.T) {
+	c := New(10)
+	p := Policy{TTL: time.Minute}
+	errLoad := errors.New("load failed")
+	_, _, err := c.Get("a", p, func() (interface{}, error) { return nil, errLoad })
+	assert.Equal(t, errLoad, err)
+	// 失败的结果不缓存
+	v, r, err := c.Get("a", p, func() (interface{}, error) { return 1, nil })
+	assert.NoError(t, err)
+	assert.Equal(t, Miss, r)
+	assert.Equal(t, 1, v)
+}
+
+func TestCacheDisabled(t *testing.T) {
+	c := New(10)
+	var calls int32
+	load := func() (interface{}, error) { return atomic.AddInt32(&calls, 1), nil }
+	c.Get("a", Policy{}, load)
+	_, r, _ := c.Get("a", Policy{}, load)
+	assert.Equal(t, Miss, r)
+	assert.Equal(t, int32(2), calls)
+}
@@ -0,0 +1,108 @@
+// Package totp 基于时间的一次性密码（RFC 6238），使用验证器应用默认的参数：HMAC-SHA1、6位数字、30秒一个周期
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	Digits = 6
+	Period = 30      // 秒
+	modulo = 1000000 // 10的Digits次方
+	// secretSize 密钥的字节数，RFC 4226建议至少160位
+	secretSize = 20
+)
+
+var ErrInvalidSecret = errors.New("totp: invalid secret")
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret 随机生成base32编码的密钥
+func GenerateSecret() (string, error) {
+	b := make([]byte, secretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// ProvisioningURI 验证器应用扫描的二维码内容，account通常为用户名
+func ProvisioningURI(issuer, account, secret string) string {
+	label := url.PathEscape(account)
+	if issuer != "" {
+		label = url.PathEscape(issuer) + ":" + label
+	}
+	q := url.Values{}
+	q.Set("secret", secret)
+	if issuer != "" {
+		q.Set("issuer", issuer)
+	}
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(Digits))
+	q.Set("period", fmt.Sprint(Period))
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// Step t所在的周期
+func Step(t time.Time) int64 {
+	return t.Unix() / Period
+}
+
+// Code 密钥在周期step的验证码
+func Code(secret string, step int64) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return code(key, step), nil
+}
+
+// Validate 校验验证码，允许前后skew个周期的时钟误差，通过时返回匹配的周期，调用方用它防止同一个验证码被重复使用
+func Validate(secret, passcode string, t time.Time, skew int) (step int64, ok bool) {
+	passcode = strings.TrimSpace(passcode)
+	if len(passcode) != Digits {
+		return 0, false
+	}
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, false
+	}
+	now := Step(t)
+	for i := -skew; i <= skew; i++ {
+		s := now + int64(i)
+		if hmac.Equal([]byte(code(key, s)), []byte(passcode)) {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	key, err := encoding.DecodeString(strings.TrimRight(secret, "="))
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	return key, nil
+}
+
+// code RFC 4226中的HOTP，计数器为周期
+func code(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	v := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, v%modulo)
+}
@@ -0,0 +1,74 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret RFC 6238附录B中SHA1使用的密钥"12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeRFCVectors(t *testing.T) {
+	// RFC中是8位验证码，6位验证码是它的后6位
+	for unix, want := range map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	} {
+		got, err := Code(rfcSecret, Step(time.Unix(unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, want, got, "time %d", unix)
+	}
+	_, err := Code("not base32!", 1)
+	assert.Equal(t, ErrInvalidSecret, err)
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step, ok := Validate(rfcSecret, "050471", now, 1)
+	assert.True(t, ok)
+	assert.Equal(t, Step(now), step)
+
+	// 上一个周期的验证码在允许的误差内
+	prev, err := Code(rfcSecret, Step(now)-1)
+	require.NoError(t, err)
+	step, ok = Validate(rfcSecret, prev, now, 1)
+	assert.True(t, ok)
+	assert.Equal(t, Step(now)-1, step)
+	_, ok = Validate(rfcSecret, prev, now, 0)
+	assert.False(t, ok)
+
+	_, ok = Validate(rfcSecret, "000000", now, 1)
+	assert.False(t, ok)
+	_, ok = Validate(rfcSecret, "50471", now, 1)
+	assert.False(t, ok)
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := GenerateSecret()
+	require.NoError(t, err)
+	b, err := GenerateSecret()
+	require.NoError(t, err)
+	assert.NotEqual(t, a, b)
+	assert.Len(t, a, 32)
+	_, err = Code(a, 1)
+	assert.NoError(t, err)
+}
+
+func TestProvisioningURI(t *testing.T) {
+	u, err := url.Parse(ProvisioningURI("RU Community", "alice", "ABC"))
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/RU Community:alice", u.Path)
+	assert.Equal(t, "ABC", u.Query().Get("secret"))
+	assert.Equal(t, "RU Community", u.Query().Get("issuer"))
+	assert.Equal(t, "6", u.Query().Get("digits"))
+}
@@ -0,0 +1,18 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startClient 开始一个访问外部服务的span，ctx中没有正在记录的span时不创建
+// 后台任务和启动时的查询没有父span，避免每条语句都产生一个单独的trace
+func startClient(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span, bool) {
+	if ctx == nil || !trace.SpanFromContext(ctx).IsRecording() {
+		return ctx, nil, false
+	}
+	ctx, span := Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return ctx, span, true
+}
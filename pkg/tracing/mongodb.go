@@ -0,0 +1,52 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// CommandMonitor 每个MongoDB命令记录一个span，父span从发起命令的ctx中获取
+func CommandMonitor() *event.CommandMonitor {
+	var spans sync.Map // request id -> span
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			attrs := []attribute.KeyValue{
+				semconv.DBSystemMongoDB,
+				semconv.DBNameKey.String(e.DatabaseName),
+				semconv.DBOperationKey.String(e.CommandName),
+			}
+			if coll, ok := e.Command.Lookup(e.CommandName).StringValueOK(); ok {
+				attrs = append(attrs, semconv.DBMongoDBCollectionKey.String(coll))
+			}
+			if _, span, ok := startClient(ctx, "mongodb."+e.CommandName, attrs...); ok {
+				spans.Store(e.RequestID, span)
+			}
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			endCommand(&spans, e.RequestID, nil)
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			endCommand(&spans, e.RequestID, commandError(e.Failure))
+		},
+	}
+}
+
+func endCommand(spans *sync.Map, requestID int64, err error) {
+	v, ok := spans.Load(requestID)
+	if !ok {
+		return
+	}
+	spans.Delete(requestID)
+	End(v.(trace.Span), err)
+}
+
+type commandError string
+
+func (e commandError) Error() string {
+	return string(e)
+}
@@ -0,0 +1,184 @@
+package tracing
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+)
+
+var errNamedArgs = errors.New("tracing: driver does not support named arguments")
+
+// WrapDriver 包装database/sql的驱动，每条语句记录一个span，dao层的代码不需要修改
+// 与slowlog.WrapDriver一样，查询只统计到返回结果为止
+func WrapDriver(d driver.Driver) driver.Driver {
+	return &wrappedDriver{d}
+}
+
+type wrappedDriver struct {
+	driver.Driver
+}
+
+func (d *wrappedDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{c}, nil
+}
+
+// startQuery span的名字是语句的类型，例如mysql.SELECT
+func startQuery(ctx context.Context, query string) (context.Context, func(error)) {
+	ctx, span, ok := startClient(ctx, "mysql."+operation(query),
+		semconv.DBSystemMySQL, semconv.DBStatementKey.String(query))
+	if !ok {
+		return ctx, func(error) {}
+	}
+	return ctx, func(err error) {
+		// 驱动返回ErrSkip时database/sql会改为prepare后再执行，由stmt记录
+		if err == driver.ErrSkip {
+			span.End()
+			return
+		}
+		End(span, err)
+	}
+}
+
+func operation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "query"
+	}
+	return strings.ToUpper(fields[0])
+}
+
+type conn struct {
+	driver.Conn
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		s   driver.Stmt
+		err error
+	)
+	if pc, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		s, err = pc.PrepareContext(ctx, query)
+	} else {
+		s, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{Stmt: s, query: query}, nil
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if bc, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return bc.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	qc, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, end := startQuery(ctx, query)
+	rows, err := qc.QueryContext(ctx, query, args)
+	end(err)
+	return rows, err
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	ec, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, end := startQuery(ctx, query)
+	ret, err := ec.ExecContext(ctx, query, args)
+	end(err)
+	return ret, err
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nc, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return nc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+type stmt struct {
+	driver.Stmt
+	query string
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	ctx, end := startQuery(ctx, s.query)
+	var (
+		ret driver.Result
+		err error
+	)
+	if ec, ok := s.Stmt.(driver.StmtExecContext); ok {
+		ret, err = ec.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValues(args); err == nil {
+			ret, err = s.Stmt.Exec(values)
+		}
+	}
+	end(err)
+	return ret, err
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	ctx, end := startQuery(ctx, s.query)
+	var (
+		rows driver.Rows
+		err  error
+	)
+	if qc, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = qc.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValues(args); err == nil {
+			rows, err = s.Stmt.Query(values)
+		}
+	}
+	end(err)
+	return rows, err
+}
+
+func (s *stmt) ColumnConverter(idx int) driver.ValueConverter {
+	if cc, ok := s.Stmt.(driver.ColumnConverter); ok {
+		return cc.ColumnConverter(idx)
+	}
+	return driver.DefaultParameterConverter
+}
+
+func namedValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errNamedArgs
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
@@ -0,0 +1,59 @@
+package tracing
+
+import (
+	"strings"
+
+	"github.com/go-redis/redis"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+)
+
+// WrapRedis 给WithContext返回的客户端加上记录span的hook，命令没有ctx，父span从客户端的ctx中获取
+// WithContext复制客户端时会重置hook，所以每次WithContext之后都要重新包装
+func WrapRedis(c *redis.Client) *redis.Client {
+	ctx := c.Context()
+	c.WrapProcess(func(old func(redis.Cmder) error) func(redis.Cmder) error {
+		return func(cmd redis.Cmder) error {
+			_, span, ok := startClient(ctx, "redis."+cmd.Name(),
+				semconv.DBSystemRedis, semconv.DBOperationKey.String(cmd.Name()))
+			if !ok {
+				return old(cmd)
+			}
+			err := old(cmd)
+			End(span, ignoreNil(err))
+			return err
+		}
+	})
+	c.WrapProcessPipeline(func(old func([]redis.Cmder) error) func([]redis.Cmder) error {
+		return func(cmds []redis.Cmder) error {
+			_, span, ok := startClient(ctx, "redis.pipeline",
+				semconv.DBSystemRedis, semconv.DBOperationKey.String(pipelineNames(cmds)))
+			if !ok {
+				return old(cmds)
+			}
+			err := old(cmds)
+			End(span, ignoreNil(err))
+			return err
+		}
+	})
+	return c
+}
+
+// ignoreNil key不存在时返回redis.Nil，不是错误
+func ignoreNil(err error) error {
+	if err == redis.Nil {
+		return nil
+	}
+	return err
+}
+
+func pipelineNames(cmds []redis.Cmder) string {
+	names := make([]string, 0, len(cmds))
+	seen := make(map[string]bool)
+	for _, cmd := range cmds {
+		if name := cmd.Name(); !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ",")
+}
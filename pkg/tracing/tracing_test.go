@@ -0,0 +1,136 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// useRecorder 把全局的TracerProvider换成记录结束的span的实现
+func useRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	old := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(old) })
+	return recorder
+}
+
+func spanNames(recorder *tracetest.SpanRecorder) []string {
+	var names []string
+	for _, s := range recorder.Ended() {
+		names = append(names, s.Name())
+	}
+	return names
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, driver.ErrSkip }
+
+func (fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if query == "fail" {
+		return nil, assert.AnError
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func TestWrapDriver(t *testing.T) {
+	recorder := useRecorder(t)
+	sql.Register("tracing-test", WrapDriver(fakeDriver{}))
+	db, err := sql.Open("tracing-test", "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	// 没有父span时不记录
+	_, err = db.ExecContext(context.Background(), "update post set title = ?", "t")
+	require.NoError(t, err)
+	assert.Empty(t, recorder.Ended())
+
+	ctx, parent := Start(context.Background(), "request")
+	_, err = db.ExecContext(ctx, "update post set title = ?", "t")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "fail")
+	require.Error(t, err)
+	parent.End()
+
+	ended := recorder.Ended()
+	require.Len(t, ended, 3)
+	assert.Equal(t, "mysql.UPDATE", ended[0].Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), ended[0].Parent().SpanID())
+	assert.Equal(t, codes.Unset, ended[0].Status().Code)
+	assert.Equal(t, "mysql.FAIL", ended[1].Name())
+	assert.Equal(t, codes.Error, ended[1].Status().Code)
+}
+
+func TestCommandMonitor(t *testing.T) {
+	recorder := useRecorder(t)
+	m := CommandMonitor()
+	cmd, err := bson.Marshal(bson.D{{Key: "find", Value: "comment"}})
+	require.NoError(t, err)
+
+	m.Started(context.Background(), &event.CommandStartedEvent{Command: cmd, CommandName: "find", RequestID: 1})
+	m.Succeeded(context.Background(), &event.CommandSucceededEvent{CommandFinishedEvent: event.CommandFinishedEvent{RequestID: 1}})
+	assert.Empty(t, recorder.Ended())
+
+	ctx, parent := Start(context.Background(), "request")
+	m.Started(ctx, &event.CommandStartedEvent{Command: cmd, CommandName: "find", RequestID: 2})
+	m.Started(ctx, &event.CommandStartedEvent{Command: cmd, CommandName: "find", RequestID: 3})
+	m.Succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: event.CommandFinishedEvent{RequestID: 2}})
+	m.Failed(ctx, &event.CommandFailedEvent{CommandFinishedEvent: event.CommandFinishedEvent{RequestID: 3}, Failure: "timeout"})
+	parent.End()
+
+	ended := recorder.Ended()
+	require.Len(t, ended, 3)
+	assert.Equal(t, "mongodb.find", ended[0].Name())
+	assert.Equal(t, codes.Unset, ended[0].Status().Code)
+	assert.Equal(t, codes.Error, ended[1].Status().Code)
+	assert.Equal(t, "timeout", ended[1].Status().Description)
+}
+
+func TestWrapRedis(t *testing.T) {
+	recorder := useRecorder(t)
+	s, err := miniredis.Run()
+	require.NoError(t, err)
+	defer s.Close()
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	defer client.Close()
+
+	require.NoError(t, WrapRedis(client.WithContext(context.Background())).Set("k", "v", 0).Err())
+	assert.Empty(t, recorder.Ended())
+
+	ctx, parent := Start(context.Background(), "request")
+	c := WrapRedis(client.WithContext(ctx))
+	// key不存在不算错误
+	assert.Equal(t, redis.Nil, c.Get("missing").Err())
+	require.Error(t, c.HSet("k", "f", "v").Err())
+	pipe := c.Pipeline()
+	pipe.Get("k")
+	pipe.Incr("k")
+	_, err = pipe.Exec()
+	require.Error(t, err)
+	parent.End()
+
+	assert.Equal(t, []string{"redis.get", "redis.hset", "redis.pipeline", "request"}, spanNames(recorder))
+	ended := recorder.Ended()
+	assert.Equal(t, codes.Unset, ended[0].Status().Code)
+	assert.Equal(t, codes.Error, ended[1].Status().Code)
+	assert.Equal(t, codes.Error, ended[2].Status().Code)
+}
@@ -0,0 +1,101 @@
+// Package webhook 向外部地址发送事件，设置了密钥时对请求体签名
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-web-app/pkg/netguard"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 请求中附带的头，接收方用时间戳和签名校验请求确实来自本站，拒绝时间相差太多的请求防止重放
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderID        = "X-Webhook-ID" // 同一个事件重试时不变，接收方可以用来去重
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// StatusError 接收方返回了非2xx的状态码
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return "webhook: unexpected status " + strconv.Itoa(e.Code)
+}
+
+// client 不跟随重定向，拒绝连接内网和本机地址，超时由调用方的ctx控制
+var client = &http.Client{
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: netguard.CheckAddress,
+		}).DialContext,
+		MaxIdleConns:        20,
+		IdleConnTimeout:     30 * time.Second,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// Sign 签名为"sha256="加上HMAC-SHA256(secret, "<时间戳>.<请求体>")的十六进制
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send POST JSON请求体到url，secret为空时不签名，返回接收方的状态码
+func Send(ctx context.Context, url, secret, event, id string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "bluebell-webhook/1.0")
+	req.Header.Set(HeaderEvent, event)
+	req.Header.Set(HeaderID, id)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now, 10))
+	if secret != "" {
+		req.Header.Set(HeaderSignature, Sign(secret, now, body))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	// 读完响应体以便复用连接，只读取一小部分
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, &StatusError{Code: resp.StatusCode}
+	}
+	return resp.StatusCode, nil
+}
+
+// IsPermanent 重试也不会成功的错误：地址不允许，或者接收方返回4xx(408和429除外)
+func IsPermanent(err error) bool {
+	if errors.Is(err, netguard.ErrForbiddenAddress) {
+		return true
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		code := statusErr.Code
+		return code >= 400 && code < 500 && code != http.StatusRequestTimeout && code != http.StatusTooManyRequests
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package webhook
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSend(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	// 默认的client拒绝连接本机地址
+	_, err := Send(context.Background(), srv.URL, "", "ping", "1", []byte(`{}`))
+	require.Error(t, err)
+	assert.True(t, IsPermanent(err), "got %v", err)
+
+	old := client
+	client = srv.Client()
+	defer func() { client = old }()
+
+	code, err := Send(context.Background(), srv.URL, "s3cret", "post.created", "42", []byte(`{"a":1}`))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, `{"a":1}`, string(body))
+	assert.Equal(t, "post.created", got.Header.Get(HeaderEvent))
+	assert.Equal(t, "42", got.Header.Get(HeaderID))
+	ts, err := strconv.ParseInt(got.Header.Get(HeaderTimestamp), 10, 64)
+	require.NoError(t, err)
+	assert.Equal(t, Sign("s3cret", ts, body), got.Header.Get(HeaderSignature))
+
+	// 没有密钥时不签名
+	_, err = Send(context.Background(), srv.URL, "", "ping", "1", []byte(`{}`))
+	require.NoError(t, err)
+	assert.Empty(t, got.Header.Get(HeaderSignature))
+
+	status = http.StatusNotFound
+	code, err = Send(context.Background(), srv.URL, "", "ping", "1", []byte(`{}`))
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.True(t, IsPermanent(err))
+	status = http.StatusTooManyRequests
+	_, err = Send(context.Background(), srv.URL, "", "ping", "1", []byte(`{}`))
+	assert.False(t, IsPermanent(err))
+	status = http.StatusBadGateway
+	_, err = Send(context.Background(), srv.URL, "", "ping", "1", []byte(`{}`))
+	assert.False(t, IsPermanent(err))
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '1700000000.{}' | openssl dgst -sha256 -hmac key
+	assert.Equal(t, "sha256=9d713ed406bb7076d4123f0dc2c39d2df5c654ed4b0cd56b52c8b4c940bd63ae", Sign("key", 1700000000, []byte("{}")))
+}
@@ -2,8 +2,12 @@ package routes
 
 import (
 	"go-web-app/controller"
+	"go-web-app/logger"
 	"go-web-app/middlewares"
+	"go-web-app/models"
+	"go-web-app/settings"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,52 +17,303 @@ func Setup(mode string) *gin.Engine {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	r := gin.New()
+	// 不直接信任X-Forwarded-For，只有来自可信代理的请求由RealIPMiddleware解析
+	r.ForwardedByClientIP = false
+	// recovery在最外层，其他中间件中的panic也能被捕获
+	r.Use(middlewares.RecoveryMiddleware(), middlewares.RealIPMiddleware(settings.Current().RequestConfig.TrustedProxies), logger.GinLogger(), middlewares.InFlightMiddleware(), middlewares.CORSMiddleware())
+	// 没有开启tracing时全局的tracer是no-op实现，开销可以忽略
+	r.Use(middlewares.TracingMiddleware())
+
+	// 注册在所有路由之前，新增的接口会自动被统计
+	if settings.Current().MetricsConfig.Enable {
+		r.Use(middlewares.MetricsMiddleware())
+		r.GET(settings.Current().MetricsConfig.Path, middlewares.MetricsHandler())
+	}
+
+	// 上传接口不受通用请求体大小的限制，websocket长连接和流式导出不受超时限制
+	// 压缩放在超时之后，压缩后的内容写入超时的缓冲区；/metrics在这之前注册，promhttp自己处理压缩
+	const (
+		avatarPath     = "/api/v1/account/avatar"
+		attachmentPath = "/api/v1/post/:id/attachments"
+		wsPath         = "/api/v1/ws/notifications"
+		commentWSPath  = "/api/v1/ws/post/:id/comments"
+		exportPath     = "/api/v1/admin/posts/export"
+	)
+	reqCfg := settings.Current().RequestConfig
+	r.Use(
+		middlewares.BodyLimitMiddleware(reqCfg.MaxBodySize*1024, avatarPath, attachmentPath),
+		middlewares.TimeoutMiddleware(time.Duration(reqCfg.Timeout)*time.Second, wsPath, commentWSPath, exportPath),
+		middlewares.CompressMiddleware(),
+	)
+
+	// 维护模式下管理员需要能登录并关闭维护模式，健康检查不受影响
+	r.Use(middlewares.MaintenanceMiddleware(
+		"/ping", "/healthz", "/readyz",
+		"/api/v1/admin", "/api/v1/login", "/api/v1/refresh",
+	))
+
+	// 只有开启csrf.enable后才校验，使用Bearer token的请求不受影响
+	r.Use(middlewares.CSRFMiddleware())
+
+	// 请求内的数据加载器，同一个请求中组装响应时重复需要的数据只查询一次
+	r.Use(middlewares.LoaderMiddleware())
 
 	// use token bucket for traffic shaping and rate limiting
-	//r.Use(logger.GinLogger(), logger.GinRecovery(true), middlewares.RateLimitMiddleware(2*time.Second, 1))
+	//r.Use(middlewares.RateLimitMiddleware(2*time.Second, 1))
 
 	r.GET("/ping", func(c *gin.Context) {
 		c.String(http.StatusOK, "pong")
 	})
 
-	v1 := r.Group("/api/v1")
+	// 健康检查，不经过认证和限流
+	r.GET("/healthz", controller.HealthzHandler)
+	r.GET("/readyz", controller.ReadyzHandler)
+
+	// 用户上传的头像
+	r.Static(settings.Current().AvatarConfig.URLPrefix, settings.Current().AvatarConfig.Dir)
+
+	// 每个版本的接口注册在各自的分组中，公共的中间件见authMiddlewares
+	// 弃用某个版本时在配置文件的api.deprecations中设置，响应会带上Deprecation和Sunset头
+	registerV1(r.Group("/api/v1", middlewares.DeprecationMiddleware("v1")))
+	registerV2(r.Group("/api/v2", middlewares.DeprecationMiddleware("v2")))
+
+	//r.GET("/", func(context *gin.Context) {
+	//	context.String(http.StatusOK, "ok")
+	//})
+
+	//r.GET("/ping", middlewares.JWTAuthMiddleware(), func(context *gin.Context) {
+	//	context.String(http.StatusOK, "pong")
+	//})
+
+	r.NoRoute(func(context *gin.Context) {
+		context.JSON(http.StatusNotFound, gin.H{
+			"msg": "404",
+		})
+	})
+	return r
+}
+
+// authMiddlewares 需要登录的接口共用的中间件，登录后的接口按用户限流
+func authMiddlewares() []gin.HandlerFunc {
+	return []gin.HandlerFunc{middlewares.JWTAuthMiddleware(), middlewares.RedisRateLimitMiddleware("default")}
+}
+
+func registerV1(v1 *gin.RouterGroup) {
+	// 未登录的接口按IP限流，登录和注册使用更严格的规则
+	defaultLimit := middlewares.RedisRateLimitMiddleware("default")
 
 	// user register
-	v1.POST("/signup", controller.SignUpHandler)
+	// signup_geo开启时按IP所在的国家限制注册
+	v1.POST("/signup", middlewares.SignupGeoMiddleware(), middlewares.RedisRateLimitMiddleware("signup"), controller.SignUpHandler)
 
 	// user login
-	v1.POST("/login", controller.LoginHandler)
+	v1.POST("/login", middlewares.RedisRateLimitMiddleware("login"), controller.LoginHandler)
+	// 开启了两步验证的账号用登录返回的临时token提交验证码
+	v1.POST("/login/2fa", middlewares.RedisRateLimitMiddleware("login"), controller.LoginTwoFactorHandler)
+
+	// 使用Google账号登录，只允许学校的邮箱
+	v1.GET("/oauth/google/login", middlewares.RedisRateLimitMiddleware("login"), controller.GoogleLoginHandler)
+	v1.GET("/oauth/google/callback", middlewares.RedisRateLimitMiddleware("login"), controller.GoogleCallbackHandler)
+
+	// refresh access token
+	v1.POST("/refresh", defaultLimit, controller.RefreshTokenHandler)
+
+	// email verification
+	v1.GET("/verify", defaultLimit, controller.VerifyEmailHandler)
+	v1.POST("/verify/resend", defaultLimit, controller.ResendVerificationHandler)
+
+	// password reset
+	v1.POST("/password/forgot", middlewares.RedisRateLimitMiddleware("password_forgot"), controller.ForgotPasswordHandler)
+	v1.POST("/password/reset", middlewares.RedisRateLimitMiddleware("password_reset"), controller.ResetPasswordHandler)
 
-	v1.Use(middlewares.JWTAuthMiddleware())
+	// 恢复已注销的账号，通过邮件确认身份
+	v1.POST("/account/reactivate", middlewares.RedisRateLimitMiddleware("login"), controller.ReactivateHandler)
+	v1.POST("/account/reactivate/confirm", middlewares.RedisRateLimitMiddleware("login"), controller.ConfirmReactivateHandler)
+
+	// websocket的token通过查询参数传递，自己完成认证
+	v1.GET("/ws/notifications", defaultLimit, controller.NotificationWSHandler)
+	v1.GET("/ws/post/:id/comments", defaultLimit, controller.PostCommentsWSHandler)
+
+	// 浏览器使用cookie认证时的CSRF token
+	v1.GET("/csrf", defaultLimit, controller.CSRFTokenHandler)
+	v1.POST("/csrf/rotate", defaultLimit, controller.RotateCSRFTokenHandler)
+
+	// 首页推荐的社区，未登录时也可以访问
+	v1.GET("/communities/featured", defaultLimit, controller.FeaturedCommunitiesHandler)
+
+	// 撤销投票不计入限流，只有刚投过的票可以撤销，次数不会超过投票的次数
+	v1.POST("/vote/undo", middlewares.JWTAuthMiddleware(), controller.UndoPostVoteHandler)
+	v1.POST("/comment/:id/vote/undo", middlewares.JWTAuthMiddleware(), controller.UndoCommentVoteHandler)
+
+	v1.Use(authMiddlewares()...)
 
 	{
+		v1.POST("/logout", controller.LogoutHandler)
+
 		v1.GET("/community", controller.CommunityHandler)
+		v1.POST("/community", controller.CreateCommunityHandler)
 		v1.GET("/community/:id", controller.CommunityDetailHandler)
+		v1.GET("/communities/trending", controller.TrendingCommunitiesHandler)
+		v1.GET("/community/:id/posts", controller.CommunityPostListHandler)
+		v1.POST("/community/:id/join", controller.JoinCommunityHandler)
+		v1.POST("/community/:id/access", controller.RequestJoinCommunityHandler)
+		v1.POST("/community/:id/leave", controller.LeaveCommunityHandler)
+		v1.POST("/community/:id/mute", controller.MuteCommunityHandler)
+		v1.POST("/community/:id/unmute", controller.UnmuteCommunityHandler)
+
+		// 社区管理员任免版主和其他管理员，版主管理本社区的帖子
+		communityAdmin := middlewares.CommunityRoleMiddleware(models.CommunityRoleAdmin)
+		moderator := middlewares.CommunityRoleMiddleware(models.CommunityRoleModerator)
+		v1.POST("/community/:id/moderators/:uid", communityAdmin, controller.PromoteModeratorHandler)
+		v1.DELETE("/community/:id/moderators/:uid", communityAdmin, controller.DemoteModeratorHandler)
+		v1.POST("/community/:id/admins/:uid", communityAdmin, controller.PromoteCommunityAdminHandler)
+		v1.DELETE("/community/:id/admins/:uid", communityAdmin, controller.DemoteCommunityAdminHandler)
+		v1.PUT("/community/:id/visibility", communityAdmin, controller.SetCommunityVisibilityHandler)
+		v1.PUT("/community/:id/post-permission", communityAdmin, controller.SetCommunityPostPermissionHandler)
+		v1.PUT("/community/:id/min-post-length", moderator, controller.SetCommunityMinPostLengthHandler)
+		v1.PUT("/community/:id/rules", moderator, controller.SetCommunityRulesHandler)
+		v1.PUT("/community/:id/anonymous", moderator, controller.SetCommunityAnonymousHandler)
+		v1.GET("/community/:id/posts/:pid/author", moderator, controller.RevealPostAuthorHandler)
+		// 新帖子通知的webhook
+		v1.GET("/community/:id/webhooks", moderator, controller.WebhookListHandler)
+		v1.POST("/community/:id/webhooks", moderator, controller.AddWebhookHandler)
+		v1.DELETE("/community/:id/webhooks/:wid", moderator, controller.DeleteWebhookHandler)
+		v1.POST("/community/:id/webhooks/:wid/test", moderator, controller.TestWebhookHandler)
+		// 私有社区的加入申请和邀请
+		v1.GET("/community/:id/requests", moderator, controller.JoinRequestListHandler)
+		v1.POST("/community/:id/requests/:uid/approve", moderator, controller.ApproveJoinRequestHandler)
+		v1.POST("/community/:id/requests/:uid/deny", moderator, controller.DenyJoinRequestHandler)
+		v1.POST("/community/:id/invites/:uid", moderator, controller.InviteMemberHandler)
+		v1.DELETE("/community/:id/posts/:pid", moderator, controller.RemoveCommunityPostHandler)
+		v1.POST("/community/:id/users/:uid/content/remove", moderator, controller.RemoveUserContentHandler)
+		v1.POST("/community/:id/posts/:pid/lock", moderator, controller.LockCommentsHandler)
+		v1.DELETE("/community/:id/posts/:pid/lock", moderator, controller.UnlockCommentsHandler)
+		v1.POST("/community/:id/post/:pid/pin", moderator, controller.PinPostHandler)
+		v1.POST("/community/:id/post/:pid/unpin", moderator, controller.UnpinPostHandler)
 
 		v1.POST("/post", controller.CreatePostHandler)
 		v1.GET("/post/:id", controller.GetPostDetailHandler)
+		v1.GET("/p/:slug", controller.GetPostBySlugHandler)
+		v1.PUT("/post/:id", controller.UpdatePostHandler)
+		v1.GET("/post/:id/full", controller.GetPostFullHandler)
+		v1.GET("/post/:id/history", controller.GetPostHistoryHandler)
+		v1.GET("/post/:id/related", controller.GetRelatedPostsHandler)
+		v1.GET("/post/:id/vote-stats", controller.PostVoteStatsHandler)
+		v1.DELETE("/post/:id", controller.DeletePostHandler)
+		v1.POST("/post/:id/restore", middlewares.AdminMiddleware(), controller.RestorePostHandler)
+		v1.POST("/post/:id/publish", controller.PublishPostHandler)
+		v1.POST("/post/:id/save", controller.SavePostHandler)
+		v1.POST("/post/:id/unsave", controller.UnsavePostHandler)
+		v1.POST("/post/:id/report", controller.ReportPostHandler)
+		v1.POST("/post/:id/poll/vote", controller.VotePollHandler)
+		attachmentLimit := settings.Current().AttachmentConfig.MaxSize*1024 + 64*1024
+		v1.POST("/post/:id/attachments", middlewares.BodyLimitMiddleware(attachmentLimit), controller.UploadAttachmentHandler)
+		v1.GET("/post/:id/attachments", controller.GetPostAttachmentsHandler)
+		v1.GET("/attachment/:id", controller.DownloadAttachmentHandler)
+
+		// 举报按帖子汇总，版主只能看到和处理自己社区的举报
+		v1.GET("/reports", controller.ReportListHandler)
+		v1.POST("/reports/:id/resolve", controller.ResolveReportHandler)
+		// 审核队列汇总举报、刷票和被隐藏的帖子，认领之后只有认领的版主可以处理
+		v1.GET("/modqueue", controller.ModQueueHandler)
+		v1.POST("/modqueue/:id/claim", controller.ClaimModQueueHandler)
+		v1.POST("/modqueue/:id/resolve", controller.ResolveModQueueHandler)
 		v1.GET("/posts", controller.GetPostListHandler)
 		v1.GET("/posts2", controller.GetPostListHandler2)
+		v1.GET("/search", controller.SearchPostHandler)
+		v1.GET("/feed", controller.HomeFeedHandler)
+
+		v1.GET("/tags", controller.TagSuggestHandler)
+		v1.GET("/tags/trending", controller.TrendingTagsHandler)
+		v1.GET("/tags/following", controller.FollowedTagsHandler)
+		v1.GET("/tag/:name", controller.TagDetailHandler)
+		v1.POST("/tag/:name/follow", controller.FollowTagHandler)
+		v1.POST("/tag/:name/unfollow", controller.UnfollowTagHandler)
 
 		v1.POST("/vote", controller.PostVoteHandler)
+		v1.GET("/leaderboard", controller.LeaderboardHandler)
+
+		v1.POST("/comment", controller.CreateCommentHandler)
+		v1.GET("/comments/:postID", controller.GetCommentListHandler)
+		v1.PUT("/comment/:id", controller.UpdateCommentHandler)
+		v1.DELETE("/comment/:id", controller.DeleteCommentHandler)
+		v1.POST("/comment/:id/vote", controller.CommentVoteHandler)
 
 		v1.GET("/event/:id", controller.GetEventHandler)
-	}
 
-	//r.GET("/", func(context *gin.Context) {
-	//	context.String(http.StatusOK, "ok")
-	//})
+		v1.GET("/user/:id", controller.UserProfileHandler)
+		v1.POST("/user/:id/follow", controller.FollowHandler)
+		v1.POST("/user/:id/unfollow", controller.UnfollowHandler)
+		v1.GET("/user/:id/followers", controller.FollowerListHandler)
+		v1.GET("/user/:id/following", controller.FollowingListHandler)
+		v1.GET("/user/:id/posts", controller.UserPostListHandler)
+		v1.GET("/user/:id/votes", controller.UserVotedPostsHandler)
+		v1.POST("/user/:id/block", controller.BlockHandler)
+		v1.POST("/user/:id/unblock", controller.UnblockHandler)
+		v1.GET("/username/:name", controller.UserProfileByUsernameHandler)
+		v1.POST("/users/batch", controller.UserBatchHandler)
 
-	//r.GET("/ping", middlewares.JWTAuthMiddleware(), func(context *gin.Context) {
-	//	context.String(http.StatusOK, "pong")
-	//})
+		// 当前登录用户自己的账号，不能放在/user下面，否则和/user/:id冲突
+		// multipart的边界和表单字段会占用少量额外的空间
+		avatarLimit := settings.Current().AvatarConfig.MaxSize*1024 + 64*1024
+		v1.POST("/account/avatar", middlewares.BodyLimitMiddleware(avatarLimit), controller.UploadAvatarHandler)
+		v1.POST("/account/username", controller.ChangeUsernameHandler)
+		v1.POST("/account/deactivate", controller.DeactivateHandler)
+		v1.POST("/account/merge/token", controller.RequestMergeHandler)
+		v1.POST("/account/merge", controller.ConfirmMergeHandler)
+		v1.POST("/account/export", controller.ExportHandler)
+		v1.GET("/account/export/:token", controller.ExportDownloadHandler)
+		v1.GET("/account/saved", controller.SavedPostListHandler)
+		v1.GET("/account/drafts", controller.DraftListHandler)
+		v1.GET("/account/scheduled", controller.ScheduledPostListHandler)
+		v1.DELETE("/account/scheduled/:id", controller.CancelScheduledPostHandler)
+		v1.GET("/account/sessions", controller.SessionListHandler)
+		v1.DELETE("/account/sessions/:id", controller.RevokeSessionHandler)
+		v1.POST("/account/sessions/revoke-all", controller.RevokeOtherSessionsHandler)
+		v1.GET("/account/2fa", controller.TwoFactorStatusHandler)
+		v1.POST("/account/2fa/setup", controller.TwoFactorSetupHandler)
+		v1.POST("/account/2fa/enable", controller.EnableTwoFactorHandler)
+		v1.POST("/account/2fa/disable", controller.DisableTwoFactorHandler)
+		v1.POST("/account/2fa/recovery-codes", controller.RecoveryCodesHandler)
 
-	r.NoRoute(func(context *gin.Context) {
-		context.JSON(http.StatusNotFound, gin.H{
-			"msg": "404",
-		})
-	})
-	return r
+		v1.GET("/notifications", controller.NotificationListHandler)
+		v1.GET("/notifications/unread", controller.UnreadCountHandler)
+		v1.GET("/notifications/preferences", controller.NotificationPrefHandler)
+		v1.PUT("/notifications/preferences", controller.UpdateNotificationPrefHandler)
+		v1.POST("/notifications/read", controller.MarkNotificationsReadHandler)
+		v1.POST("/notifications/read-all", controller.MarkAllNotificationsReadHandler)
+	}
+
+	// 网站管理员的接口，与社区版主的权限相互独立
+	admin := v1.Group("/admin", middlewares.AdminMiddleware())
+	{
+		admin.GET("/users", controller.AdminUserListHandler)
+		admin.POST("/users/:id/ban", controller.AdminBanUserHandler)
+		admin.POST("/users/:id/merge", controller.AdminMergeUserHandler)
+		admin.POST("/users/:id/content/remove", controller.AdminRemoveUserContentHandler)
+		admin.POST("/communities", controller.AdminCreateCommunityHandler)
+		admin.POST("/communities/import", controller.AdminImportCommunitiesHandler)
+		admin.POST("/communities/featured/:id", controller.AdminAddFeaturedHandler)
+		admin.DELETE("/communities/featured/:id", controller.AdminRemoveFeaturedHandler)
+		admin.PUT("/communities/featured", controller.AdminReorderFeaturedHandler)
+		admin.GET("/stats", controller.AdminStatsHandler)
+		admin.GET("/posts/export", controller.AdminExportPostsHandler)
+		admin.GET("/audit", controller.AdminAuditLogHandler)
+		admin.POST("/purge", controller.AdminPurgeHandler)
+		admin.GET("/maintenance", controller.AdminGetMaintenanceHandler)
+		admin.PUT("/maintenance", controller.AdminSetMaintenanceHandler)
+		admin.GET("/features", controller.AdminFeatureFlagListHandler)
+		admin.PUT("/features/:name", controller.AdminSetFeatureFlagHandler)
+		admin.DELETE("/features/:name", controller.AdminDeleteFeatureFlagHandler)
+	}
+}
+
+// registerV2 返回格式有变化的接口，其余的接口继续使用v1
+func registerV2(v2 *gin.RouterGroup) {
+	v2.Use(authMiddlewares()...)
+	{
+		v2.GET("/post/:id", controller.GetPostDetailV2Handler)
+	}
 }
 
 //// JWTAuthMiddleware 基于JWT的认证中间件
@@ -2,25 +2,102 @@ package settings
 
 import (
 	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
 
 	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
 
 	"github.com/spf13/viper"
 )
 
+// Conf 启动时加载的配置，初始化之后不再修改
+// 读取配置统一通过 Current()，没有加载配置文件时(例如测试中)返回Conf
 var Conf = new(AppConfig)
 
+var (
+	current  atomic.Value // *AppConfig
+	hooksMu  sync.Mutex
+	hooks    []func(*AppConfig)
+	reloadMu sync.Mutex
+)
+
+// Current 返回最新的配置，配置文件修改后会被整体替换，调用方不要修改返回值
+func Current() *AppConfig {
+	if c, ok := current.Load().(*AppConfig); ok {
+		return c
+	}
+	return Conf
+}
+
+// OnReload 注册配置重新加载后的回调
+func OnReload(fn func(*AppConfig)) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	hooks = append(hooks, fn)
+}
+
 type AppConfig struct {
-	Mode           string `mapstructure:"mode"`
-	Port           int    `mapstructure:"port"`
-	Version        string `mapstructure:"version"`
-	StartTime      string `mapstructure:"start_time"`
-	MachineID      int64  `mapstructure:"machine_id"`
-	Name           string `mapstructure:"name"`
-	*LogConfig     `mapstructure:"log"`
-	*MySQLConfig   `mapstructure:"mysql"`
-	*RedisConfig   `mapstructure:"redis"`
-	*MongodbConfig `mapstructure:"mongodb"`
+	Mode                 string `mapstructure:"mode"`
+	Port                 int    `mapstructure:"port"`
+	Version              string `mapstructure:"version"`
+	StartTime            string `mapstructure:"start_time"`
+	MachineID            int64  `mapstructure:"machine_id"`
+	Name                 string `mapstructure:"name"`
+	Locale               string `mapstructure:"locale"` // 参数校验错误信息的默认语言，请求头Accept-Language不支持时使用
+	*LogConfig           `mapstructure:"log"`
+	*MySQLConfig         `mapstructure:"mysql"`
+	*RedisConfig         `mapstructure:"redis"`
+	*MongodbConfig       `mapstructure:"mongodb"`
+	*AuthConfig          `mapstructure:"auth"`
+	*EmailConfig         `mapstructure:"email"`
+	*CommentConfig       `mapstructure:"comment"`
+	*PostConfig          `mapstructure:"post"`
+	*AvatarConfig        `mapstructure:"avatar"`
+	*RateLimitConfig     `mapstructure:"ratelimit"`
+	*MetricsConfig       `mapstructure:"metrics"`
+	*WebSocketConfig     `mapstructure:"websocket"`
+	*CORSConfig          `mapstructure:"cors"`
+	*RequestConfig       `mapstructure:"request"`
+	*TagConfig           `mapstructure:"tag"`
+	*ReportConfig        `mapstructure:"report"`
+	*ShutdownConfig      `mapstructure:"shutdown"`
+	*StartupConfig       `mapstructure:"startup"`
+	*SnowflakeConfig     `mapstructure:"snowflake"`
+	*ContentFilterConfig `mapstructure:"content_filter"`
+	*PreviewConfig       `mapstructure:"preview"`
+	*CreationLimitConfig `mapstructure:"creation_limit"`
+	*LoginThrottleConfig `mapstructure:"login_throttle"`
+	*OAuthConfig         `mapstructure:"oauth"`
+	*KarmaConfig         `mapstructure:"karma"`
+	*TextLimitConfig     `mapstructure:"text_limit"`
+	*APIConfig           `mapstructure:"api"`
+	*CompressConfig      `mapstructure:"compress"`
+	*MentionConfig       `mapstructure:"mention"`
+	*UsernameConfig      `mapstructure:"username"`
+	*PurgeConfig         `mapstructure:"purge"`
+	*CommunityConfig     `mapstructure:"community"`
+	*ExportConfig        `mapstructure:"export"`
+	*TracingConfig       `mapstructure:"tracing"`
+	*MaintenanceConfig   `mapstructure:"maintenance"`
+	*CaptchaConfig       `mapstructure:"captcha"`
+	*BrigadeConfig       `mapstructure:"brigade"`
+	*SlowQueryConfig     `mapstructure:"slow_query"`
+	*FeedConfig          `mapstructure:"feed"`
+	*AccountAgeConfig    `mapstructure:"account_age"`
+	*PaginationConfig    `mapstructure:"pagination"`
+	*VoteWeightConfig    `mapstructure:"vote_weight"`
+	*AttachmentConfig    `mapstructure:"attachment"`
+	*QuietHoursConfig    `mapstructure:"quiet_hours"`
+	*CSRFConfig          `mapstructure:"csrf"`
+	*SignupGeoConfig     `mapstructure:"signup_geo"`
+	*WebhookConfig       `mapstructure:"webhook"`
+	*TwoFactorConfig     `mapstructure:"two_factor"`
+	*LinkFilterConfig    `mapstructure:"link_filter"`
+	*SearchConfig        `mapstructure:"search"`
+	*VoteConfig          `mapstructure:"vote"`
+	*ResponseCacheConfig `mapstructure:"response_cache"`
 }
 
 type MySQLConfig struct {
@@ -31,6 +108,12 @@ type MySQLConfig struct {
 	Port         int    `mapstructure:"port"`
 	MaxOpenConns int    `mapstructure:"max_open_conns"`
 	MaxIdleConns int    `mapstructure:"max_idle_conns"`
+	// ConnMaxLifetime 连接最长复用时间，单位秒，0表示不限制
+	ConnMaxLifetime int `mapstructure:"conn_max_lifetime"`
+	// AutoMigrate 启动时是否自动执行数据库迁移，生产环境可以关闭后手动执行
+	AutoMigrate bool `mapstructure:"auto_migrate"`
+	// QueryTimeout 每条语句的超时时间，事务中的语句单独计算，单位毫秒，0表示不限制
+	QueryTimeout int `mapstructure:"query_timeout"`
 }
 
 type RedisConfig struct {
@@ -40,6 +123,14 @@ type RedisConfig struct {
 	DB           int    `mapstructure:"db"`
 	PoolSize     int    `mapstructure:"pool_size"`
 	MinIdleConns int    `mapstructure:"min_idle_conns"`
+	// Timeout 读写命令的超时时间，单位毫秒，0使用客户端的默认值(3秒)
+	Timeout int `mapstructure:"timeout"`
+	// BreakerThreshold 连续多少次连接失败后熔断，熔断期间命令直接返回错误，0表示不熔断
+	BreakerThreshold int `mapstructure:"breaker_threshold"`
+	// BreakerCooldown 熔断多久之后放行一个命令试探redis是否恢复，单位毫秒
+	BreakerCooldown int `mapstructure:"breaker_cooldown"`
+	// FallbackCacheSize 熔断期间用于读取的进程内缓存最多保存多少个值，0表示不缓存
+	FallbackCacheSize int `mapstructure:"fallback_cache_size"`
 }
 
 type MongodbConfig struct {
@@ -47,33 +138,717 @@ type MongodbConfig struct {
 	DB       string `mapstructure:"db"`
 	Username string `mapstructure:"username"`
 	Password string `mapstructure:"password"`
+	// QueryTimeout 每次操作的超时时间，单位毫秒，0表示不限制
+	QueryTimeout int `mapstructure:"query_timeout"`
+}
+
+type AuthConfig struct {
+	// token 有效期，单位：小时
+	JwtExpire     int `mapstructure:"jwt_expire"`
+	RefreshExpire int `mapstructure:"refresh_expire"`
+	// 登录时选择"记住我"的refresh token有效期
+	RememberExpire int `mapstructure:"remember_expire"`
+	// 允许注册的邮箱域名，例如 rutgers.edu
+	AllowedEmailDomains []string `mapstructure:"allowed_email_domains"`
+	// 每个用户同时登录的会话数，超过时最早过期的会话被退出，0表示不限制
+	MaxSessions int `mapstructure:"max_sessions"`
+}
+
+type EmailConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	From     string `mapstructure:"from"`
+	BaseURL  string `mapstructure:"base_url"` // 邮件中链接的前缀，例如 https://community.rutgers.edu
+	Mode     string `mapstructure:"mode"`     // smtp发送，log只写日志用于本地开发
+
+	RatePerMinute int `mapstructure:"rate_per_minute"` // 每个实例每分钟最多发送几封
+	MaxAttempts   int `mapstructure:"max_attempts"`    // 发送失败的邮件最多尝试几次，之后放入死信列表
+	RetryBackoff  int `mapstructure:"retry_backoff"`   // 第一次重试的等待时间，单位秒，之后每次翻倍
+
+	// 每天发送未读通知摘要的时间，格式为15:04，使用服务器的时区，为空时不发送
+	DigestTime string `mapstructure:"digest_time"`
+}
+
+type CommunityConfig struct {
+	CacheTTL    int `mapstructure:"cache_ttl"`    // 社区信息在进程内和redis中的缓存时间，单位秒，0表示不缓存
+	MaxPinned   int `mapstructure:"max_pinned"`   // 每个社区最多同时置顶几个帖子，0表示不限制
+	MaxFeatured int `mapstructure:"max_featured"` // 首页最多推荐几个社区，0表示不限制
+	// 创建社区时名称的长度范围，名称只能包含字母、数字、下划线和连字符
+	NameMinLen int `mapstructure:"name_min_len"`
+	NameMaxLen int `mapstructure:"name_max_len"`
+	// 不能用作社区名称的保留名，比较时忽略大小写
+	ReservedNames []string `mapstructure:"reserved_names"`
+	// 新用户注册时自动加入的社区，auto_join为false时不加入
+	AutoJoin           bool    `mapstructure:"auto_join"`
+	DefaultCommunities []int64 `mapstructure:"default_communities"`
+	// 普通用户创建社区需要的最低声望，站点管理员不受限制，用户较少时可以关闭karma_gate
+	KarmaGate      bool  `mapstructure:"karma_gate"`
+	CreateMinKarma int64 `mapstructure:"create_min_karma"`
+	// 热门社区按小时分桶统计最近TrendingWindow小时内的新帖子和新成员，每个新帖子和新成员分别按权重计入活跃度
+	TrendingWindow     int   `mapstructure:"trending_window"`
+	TrendingSize       int64 `mapstructure:"trending_size"`
+	TrendingPostWeight int64 `mapstructure:"trending_post_weight"`
+	TrendingJoinWeight int64 `mapstructure:"trending_join_weight"`
+	TrendingCacheTTL   int   `mapstructure:"trending_cache_ttl"` // 合并分桶的结果缓存多久，单位秒
+}
+
+type CommentConfig struct {
+	MaxDepth          int   `mapstructure:"max_depth"`          // 评论树最大嵌套深度
+	CollapseThreshold int64 `mapstructure:"collapse_threshold"` // 得分不高于该负数的评论默认折叠，0表示不折叠
+	// 帖子详情和评论一起返回时(/post/:id/full)包含的顶层评论数，和每个顶层评论下的回复数
+	EmbedSize    int `mapstructure:"embed_size"`
+	EmbedReplies int `mapstructure:"embed_replies"`
+	// 评论列表中作者信息在redis中的缓存时间，单位秒，0表示不缓存
+	AuthorCacheTTL int `mapstructure:"author_cache_ttl"`
+	// 发表后多少分钟内作者可以编辑评论，0表示不限制，编辑过的评论都会带上edited_at
+	EditWindow int `mapstructure:"edit_window"`
+	// 发布超过多少天的帖子不能再发表评论，0表示不关闭，版主手动解锁的帖子不受限制
+	CloseAfter int `mapstructure:"close_after"`
+}
+
+type PostConfig struct {
+	MaxRevisions          int     `mapstructure:"max_revisions"`           // 每个帖子保留的历史版本数
+	HotGravity            float64 `mapstructure:"hot_gravity"`             // 热度随时间衰减的速度
+	HotRefreshInterval    int     `mapstructure:"hot_refresh_interval"`    // 按帖子年龄重新计算热度的间隔，单位秒
+	VoteWindow            int     `mapstructure:"vote_window"`             // 帖子发布多少小时内允许投票
+	MaxTags               int     `mapstructure:"max_tags"`                // 每个帖子最多的标签数
+	MaxCommunities        int     `mapstructure:"max_communities"`         // 每个帖子最多同时发布到几个社区，0表示不限制
+	IdempotencyTTL        int     `mapstructure:"idempotency_ttl"`         // 创建帖子的幂等键保留多少小时
+	DetailCacheTTL        int     `mapstructure:"detail_cache_ttl"`        // 帖子详情的缓存时间，单位秒，0表示不缓存
+	ScheduleTick          int     `mapstructure:"schedule_tick"`           // 检查定时帖子的间隔，单位秒
+	ScheduleSkew          int     `mapstructure:"schedule_skew"`           // publish_at允许早于当前时间多少秒，用于容忍客户端的时钟误差
+	RelatedSize           int     `mapstructure:"related_size"`            // 相关帖子最多返回几个
+	RelatedTTL            int     `mapstructure:"related_ttl"`             // 相关帖子的缓存时间，单位秒，0表示不缓存
+	PollMaxOptions        int     `mapstructure:"poll_max_options"`        // 投票最多的选项数
+	ControversialMinVotes int64   `mapstructure:"controversial_min_votes"` // 总票数达到多少的帖子才参与争议度排序
+	DuplicateWindow       int     `mapstructure:"duplicate_window"`        // 多少小时内不能重复发布相同的内容，0表示不检查
+	DuplicateCrossUser    bool    `mapstructure:"duplicate_cross_user"`    // 其他用户最近发布过相同的内容时在创建结果中提示，不阻止发布
+	DefaultCommunity      int64   `mapstructure:"default_community"`       // 发帖时没有选择社区时使用的社区，0表示没有默认社区
+	RequireCommunity      bool    `mapstructure:"require_community"`       // 为true时发帖必须选择社区，不使用默认社区
+	MinContentLength      int     `mapstructure:"min_content_length"`      // 帖子内容的最少字符数，社区没有单独设置时使用，0表示不限制
+	ScoreSyncInterval     int     `mapstructure:"score_sync_interval"`     // 把redis中变化的帖子分数写回mysql的间隔，单位秒
+	ScoreSyncBatch        int64   `mapstructure:"score_sync_batch"`        // 每次最多写回多少个帖子的分数
+	SearchSnippetLength   int     `mapstructure:"search_snippet_length"`   // 搜索结果中高亮片段的最多字符数
+	SlugMaxLength         int     `mapstructure:"slug_max_length"`         // 由标题生成的slug最多的字符数，不能超过100
+	SlugFollowTitle       bool    `mapstructure:"slug_follow_title"`       // 为true时编辑标题会生成新的slug，旧的slug重定向到新的slug
+}
+
+type AvatarConfig struct {
+	MaxSize   int64  `mapstructure:"max_size"`   // 上传大小上限，单位KB
+	Size      int    `mapstructure:"size"`       // 缩放后的边长，单位像素
+	Dir       string `mapstructure:"dir"`        // 本地保存目录
+	URLPrefix string `mapstructure:"url_prefix"` // 访问头像的URL前缀
+}
+
+// ExportConfig 用户导出自己的数据，导出的文件保存在mongodb的GridFS中，所有实例都可以下载
+type ExportConfig struct {
+	TTL           int `mapstructure:"ttl"`            // 导出的文件保留多少小时，过期后下载链接失效
+	Interval      int `mapstructure:"interval"`       // 每个用户两次成功的导出之间至少间隔多少小时，失败的导出不计算在内
+	CleanInterval int `mapstructure:"clean_interval"` // 多少秒检查一次过期的文件
+}
+
+// AttachmentConfig 帖子的附件，保存在本地目录中，通过接口检查权限后下载
+type AttachmentConfig struct {
+	Dir          string   `mapstructure:"dir"`            // 本地保存目录，不能放在静态文件目录下
+	MaxSize      int64    `mapstructure:"max_size"`       // 单个文件的大小上限，单位KB
+	MaxCount     int      `mapstructure:"max_count"`      // 每个帖子最多几个附件
+	MaxTotalSize int64    `mapstructure:"max_total_size"` // 每个帖子所有附件的大小上限，单位KB
+	AllowedTypes []string `mapstructure:"allowed_types"`  // 允许的MIME类型，按文件头判断
+}
+
+// QuietHoursConfig 用户设置的免打扰时段，期间推迟websocket推送和邮件，用户默认不启用
+type QuietHoursConfig struct {
+	CriticalTypes []string `mapstructure:"critical_types"` // 免打扰期间照常推送的通知类型，摘要邮件为digest
+	FlushInterval int      `mapstructure:"flush_interval"` // 检查推迟的推送是否到时间的间隔，单位秒
+}
+
+// CSRFConfig 浏览器通过cookie认证时使用双重提交的CSRF token，只使用Bearer token的客户端不需要开启
+// 修改配置文件后立即生效
+type CSRFConfig struct {
+	Enable     bool   `mapstructure:"enable"`
+	CookieName string `mapstructure:"cookie_name"`
+	HeaderName string `mapstructure:"header_name"` // 修改请求需要在该请求头中带上cookie中的token
+	CookiePath string `mapstructure:"cookie_path"`
+	Domain     string `mapstructure:"domain"`
+	MaxAge     int    `mapstructure:"max_age"`   // cookie的有效期，单位秒
+	SameSite   string `mapstructure:"same_site"` // lax、strict或none，为none时必须同时开启Secure
+	Secure     bool   `mapstructure:"secure"`
+	// HttpOnly 开启后前端无法读取cookie，需要通过获取token的接口拿到token
+	HttpOnly bool `mapstructure:"http_only"`
+}
+
+// SignupGeoConfig 按IP所在的国家限制注册，只用于出现问题时临时开启，修改配置文件后立即生效
+type SignupGeoConfig struct {
+	Enable bool `mapstructure:"enable"`
+	// Provider header: 使用前面的代理或CDN设置的请求头，file: 查询File中的网段
+	Provider string   `mapstructure:"provider"`
+	Header   string   `mapstructure:"header"` // 保存国家代码的请求头，例如CF-IPCountry
+	File     string   `mapstructure:"file"`   // 每行为"网段,国家代码"
+	Allow    []string `mapstructure:"allow"`  // 不为空时只允许这些国家注册
+	Deny     []string `mapstructure:"deny"`
+	// FailOpen 查不到国家或查询出错时是否允许注册
+	FailOpen bool `mapstructure:"fail_open"`
+}
+
+// WebhookConfig 社区中有新帖子时通知版主设置的外部地址，发送失败时按RetryBackoff翻倍等待后重试
+type WebhookConfig struct {
+	MaxPerCommunity int `mapstructure:"max_per_community"`
+	Timeout         int `mapstructure:"timeout"` // 每次请求的超时时间，单位毫秒
+	MaxAttempts     int `mapstructure:"max_attempts"`
+	RetryBackoff    int `mapstructure:"retry_backoff"` // 第一次重试前的等待时间，单位秒
+}
+
+// TwoFactorConfig 两步验证，没有配置SecretKey时不能开启
+// 登录时每个临时token最多尝试MaxAttempts次验证码；同一个账号的错误次数按login_throttle的窗口统计，达到FailThreshold时锁定cooldown
+type TwoFactorConfig struct {
+	Issuer        string `mapstructure:"issuer"`        // 验证器应用中显示的名称
+	SecretKey     string `mapstructure:"secret_key"`    // 加密保存TOTP密钥，修改后已经开启的两步验证无法使用
+	ChallengeTTL  int    `mapstructure:"challenge_ttl"` // 登录时临时token的有效期，单位秒
+	MaxAttempts   int64  `mapstructure:"max_attempts"`
+	FailThreshold int64  `mapstructure:"fail_threshold"`
+	RecoveryCodes int    `mapstructure:"recovery_codes"` // 每次生成的恢复码数量
+}
+
+// RateLimitConfig 令牌桶限流，Rate为每秒补充的令牌数，Burst为桶的容量
+type RateLimitConfig struct {
+	Rate  float64                   `mapstructure:"rate"`
+	Burst int64                     `mapstructure:"burst"`
+	Rules map[string]*RateLimitRule `mapstructure:"rules"` // 按路由覆盖默认值，例如 login、signup
+}
+
+type RateLimitRule struct {
+	Rate  float64 `mapstructure:"rate"`
+	Burst int64   `mapstructure:"burst"`
+}
+
+// MetricsConfig Prometheus指标接口
+type MetricsConfig struct {
+	Enable bool   `mapstructure:"enable"`
+	Path   string `mapstructure:"path"`
+}
+
+type WebSocketConfig struct {
+	MaxConnsPerUser       int `mapstructure:"max_conns_per_user"`       // 每个用户在单个实例上的最大连接数
+	MaxSubscribersPerPost int `mapstructure:"max_subscribers_per_post"` // 每个帖子在单个实例上最多有多少个连接在等待新评论
+}
+
+// CORSConfig 跨域配置，AllowOrigins中的"*"只在非release模式下生效
+type CORSConfig struct {
+	AllowOrigins     []string `mapstructure:"allow_origins"`
+	AllowMethods     []string `mapstructure:"allow_methods"`
+	AllowHeaders     []string `mapstructure:"allow_headers"`
+	ExposeHeaders    []string `mapstructure:"expose_headers"`
+	AllowCredentials bool     `mapstructure:"allow_credentials"`
+	MaxAge           int      `mapstructure:"max_age"` // 预检请求的缓存时间，单位秒
+}
+
+// RequestConfig 限制单个请求占用的资源
+type RequestConfig struct {
+	MaxBodySize int64 `mapstructure:"max_body_size"` // 请求体大小上限，单位KB，上传文件的接口单独限制
+	Timeout     int   `mapstructure:"timeout"`       // 处理超时时间，单位秒
+	// 可信的反向代理的IP或网段，只有直接来自这些地址的请求才读取X-Forwarded-For，为空时总是使用连接的地址
+	// 修改后需要重启
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+}
+
+// TagConfig 热门标签按小时分桶统计，取最近TrendingWindow小时的总和
+// 关注的标签中有帖子的热度分数超过HotThreshold时通知关注者，每个帖子只通知一次
+type TagConfig struct {
+	TrendingWindow int     `mapstructure:"trending_window"` // 统计窗口，单位小时
+	TrendingSize   int64   `mapstructure:"trending_size"`   // 返回的标签数
+	MaxFollowed    int     `mapstructure:"max_followed"`    // 每个用户最多关注的标签数
+	NotifyHot      bool    `mapstructure:"notify_hot"`
+	HotThreshold   float64 `mapstructure:"hot_threshold"` // 与post:hot中的热度分数比较
+	HotInterval    int     `mapstructure:"hot_interval"`  // 检查的间隔，单位秒
+}
+
+// SnowflakeConfig 多实例部署时从redis租用不重复的机器id，不开启时使用machine_id
+type SnowflakeConfig struct {
+	LeaseMachineID bool `mapstructure:"lease_machine_id"`
+	LeaseTTL       int  `mapstructure:"lease_ttl"` // 租约时间，单位秒，运行期间自动续期
+}
+
+// ContentFilterConfig 帖子和评论的敏感词过滤，修改配置文件后立即生效
+type ContentFilterConfig struct {
+	Words []string `mapstructure:"words"`
+	Mode  string   `mapstructure:"mode"` // reject: 拒绝发布，mask: 把敏感词替换为*
+}
+
+// LinkFilterConfig 帖子和评论中链接的域名黑名单，包含子域名，修改配置文件后立即生效
+// 评论没有隐藏的状态，hold模式下也直接拒绝
+type LinkFilterConfig struct {
+	Enable             bool     `mapstructure:"enable"`
+	Blocklist          []string `mapstructure:"blocklist"`
+	Allowlist          []string `mapstructure:"allowlist"`             // 不检查也不计数的域名，rutgers.edu总是允许
+	Action             string   `mapstructure:"action"`                // reject: 拒绝发布，hold: 帖子隐藏并提交到举报队列等待版主处理
+	NewAccountAge      int      `mapstructure:"new_account_age"`       // 注册不满多少小时的账号限制链接数
+	NewAccountMaxLinks int      `mapstructure:"new_account_max_links"` // 新账号的一个帖子或评论中最多的链接数，0表示不限制
+}
+
+// SearchConfig 全局搜索，帖子的高亮片段长度见post.search_snippet_length，修改配置文件后立即生效
+type SearchConfig struct {
+	Types   []string `mapstructure:"types"`    // 可以搜索的类型，posts、comments、users
+	AllSize int64    `mapstructure:"all_size"` // type=all时每种类型最多返回的条数
+}
+
+// VoteConfig 帖子和评论的投票，修改配置文件后立即生效
+type VoteConfig struct {
+	UndoWindow int `mapstructure:"undo_window"` // 投票后多少秒内可以撤销，撤销不计入限流，0表示不能撤销
+}
+
+// ResponseCacheConfig 热门、排行榜和相关帖子这类开销大的读接口的进程内缓存
+// 结果在ttl秒内直接返回，之后的grace秒内先返回旧的结果并在后台刷新，size修改后需要重启
+type ResponseCacheConfig struct {
+	Size      int                             `mapstructure:"size"`
+	TTL       int                             `mapstructure:"ttl"`
+	Grace     int                             `mapstructure:"grace"`
+	Endpoints map[string]*ResponseCachePolicy `mapstructure:"endpoints"` // 按接口覆盖默认值，例如 leaderboard
+}
+
+// ResponseCachePolicy ttl为0表示这个接口不缓存
+type ResponseCachePolicy struct {
+	TTL   int `mapstructure:"ttl"`
+	Grace int `mapstructure:"grace"`
+}
+
+// PreviewConfig 帖子中外部链接的预览，开启后发帖时在后台抓取链接的OpenGraph信息
+type PreviewConfig struct {
+	FetchOpenGraph  bool `mapstructure:"fetch_opengraph"`
+	FetchTimeout    int  `mapstructure:"fetch_timeout"`     // 抓取超时时间，单位毫秒
+	CacheTTL        int  `mapstructure:"cache_ttl"`         // 抓取结果的缓存时间，单位小时
+	FailureCacheTTL int  `mapstructure:"failure_cache_ttl"` // 抓取失败的缓存时间，单位分钟
+}
+
+// CreationLimitConfig 每个用户发帖和评论的频率上限，0表示不限制
+// 注册不满NewAccountAge小时的账号使用New开头的更严格的限制
+type CreationLimitConfig struct {
+	PostsPerHour         int64 `mapstructure:"posts_per_hour"`
+	CommentsPerMinute    int64 `mapstructure:"comments_per_minute"`
+	NewAccountAge        int   `mapstructure:"new_account_age"`
+	NewPostsPerHour      int64 `mapstructure:"new_posts_per_hour"`
+	NewCommentsPerMinute int64 `mapstructure:"new_comments_per_minute"`
+}
+
+// LoginThrottleConfig 登录失败过多时临时锁定，时间单位为分钟，阈值为0表示不限制
+// 账号的失败次数不区分IP，换IP不能继续猜密码；账号锁定的时间单独配置并且最多1小时，避免被恶意锁定太久
+type LoginThrottleConfig struct {
+	Window           int   `mapstructure:"window"`            // 统计失败次数的窗口
+	Cooldown         int   `mapstructure:"cooldown"`          // IP锁定的时间
+	AccountThreshold int64 `mapstructure:"account_threshold"` // 同一个账号在所有IP上的失败次数
+	AccountCooldown  int   `mapstructure:"account_cooldown"`  // 账号锁定的时间，为0时使用cooldown
+	IPThreshold      int64 `mapstructure:"ip_threshold"`      // 同一个IP对所有账号的失败次数
+}
+
+// OAuthConfig 第三方登录，没有配置client_id时不开启
+type OAuthConfig struct {
+	GoogleClientID     string `mapstructure:"google_client_id"`
+	GoogleClientSecret string `mapstructure:"google_client_secret"`
+	GoogleRedirectURL  string `mapstructure:"google_redirect_url"` // 例如 https://example.com/api/v1/oauth/google/callback
+}
+
+// APIConfig 各个接口版本的弃用计划，key为版本，例如v1，修改配置文件后立即生效
+type APIConfig struct {
+	Deprecations map[string]*APIDeprecation `mapstructure:"deprecations"`
+	MsgPack      bool                       `mapstructure:"msgpack"` // 请求头Accept为application/msgpack时使用MessagePack编码响应
+}
+
+// CompressConfig 响应压缩，修改配置文件后立即生效
+type CompressConfig struct {
+	Enable  bool `mapstructure:"enable"`
+	MinSize int  `mapstructure:"min_size"` // 小于该大小的响应不压缩，单位字节
+	Brotli  bool `mapstructure:"brotli"`   // 客户端支持时优先使用br
+}
+
+// APIDeprecation 时间为RFC3339格式，为空时不返回对应的响应头
+type APIDeprecation struct {
+	Date   string `mapstructure:"date"`   // 开始弃用的时间，对应Deprecation响应头
+	Sunset string `mapstructure:"sunset"` // 预计停止服务的时间，对应Sunset响应头
+	Link   string `mapstructure:"link"`   // 替代的版本，例如/api/v2
+}
+
+// TextLimitConfig 帖子和评论的最大长度，按字符计算，0表示不限制
+// 标题和正文不能超过数据库中字段的长度
+type TextLimitConfig struct {
+	Title   int `mapstructure:"title"`
+	Post    int `mapstructure:"post"`
+	Comment int `mapstructure:"comment"`
+}
+
+// KarmaConfig 用户的帖子和评论被投票时声望的变化，修改配置文件后立即生效
+type KarmaConfig struct {
+	PostVoteWeight    int64 `mapstructure:"post_vote_weight"`    // 帖子的一票对应的声望
+	CommentVoteWeight int64 `mapstructure:"comment_vote_weight"` // 评论的一票对应的声望
+	PersistInterval   int   `mapstructure:"persist_interval"`    // 写回mysql的间隔，单位秒
+}
+
+// VoteWeightConfig 帖子排序分数中每一票的权重，新账号和低声望账号的票权重较低，修改配置文件后立即生效
+// 只影响排序分数，显示的赞成和反对票数仍然按一人一票计算
+type VoteWeightConfig struct {
+	Enable        bool    `mapstructure:"enable"`
+	MinWeight     float64 `mapstructure:"min_weight"`      // 声望为0及以下的账号的权重
+	FullKarma     int64   `mapstructure:"full_karma"`      // 声望达到这个值时权重为1，之间线性增加
+	NewAccountAge int     `mapstructure:"new_account_age"` // 注册不满多少小时的账号按min_weight计算，0表示不限制
+}
+
+// ShutdownConfig 收到退出信号后的等待时间，单位秒
+type ShutdownConfig struct {
+	Timeout      int `mapstructure:"timeout"`       // 整个关闭过程的超时时间
+	DrainTimeout int `mapstructure:"drain_timeout"` // 等待websocket客户端断开的时间，包含在Timeout中
+}
+
+// StartupConfig 启动时MySQL、Redis、MongoDB还没有就绪时的重试，等待时间单位毫秒，每次翻倍
+type StartupConfig struct {
+	RetryAttempts   int `mapstructure:"retry_attempts"`    // 每个依赖最多尝试几次，1表示不重试
+	RetryBackoff    int `mapstructure:"retry_backoff"`     // 第一次失败后的等待时间
+	RetryMaxBackoff int `mapstructure:"retry_max_backoff"` // 最长的等待时间
+}
+
+// MentionConfig 帖子和评论中的@提及，修改配置文件后立即生效
+type MentionConfig struct {
+	MaxPerItem int `mapstructure:"max_per_item"` // 每个帖子或评论最多解析几个@，超出的按普通文本处理，0表示不限制
+}
+
+// UsernameConfig 修改用户名的限制，单位天，修改配置文件后立即生效
+type UsernameConfig struct {
+	ChangeCooldown int `mapstructure:"change_cooldown"` // 两次修改之间至少间隔多少天，0表示不限制
+	ReserveDays    int `mapstructure:"reserve_days"`    // 改掉的旧用户名保留多少天，期间只有原来的用户可以改回
+}
+
+// PurgeConfig 定期彻底删除过期的数据，多个实例时只有一个实例执行
+// 历史版本按post.max_revisions清理
+type PurgeConfig struct {
+	Enable                    bool `mapstructure:"enable"`
+	Interval                  int  `mapstructure:"interval"`                    // 执行的间隔，单位小时
+	DeletedPostRetention      int  `mapstructure:"deleted_post_retention"`      // 软删除的帖子保留多少天，0表示不删除
+	ReadNotificationRetention int  `mapstructure:"read_notification_retention"` // 已读通知保留多少天，0表示不删除
+}
+
+// MaintenanceConfig 维护模式，开关通过管理接口保存在redis中，这里只有放行的规则，修改配置文件后立即生效
+type MaintenanceConfig struct {
+	ReadOnlyAllow []string `mapstructure:"read_only_allow"` // 只读模式下仍然允许的写请求，按路径前缀匹配
 }
 
+// CaptchaConfig 注册和找回密码时校验人机验证，修改配置文件后立即生效
+type CaptchaConfig struct {
+	Enable    bool   `mapstructure:"enable"`
+	Provider  string `mapstructure:"provider"`   // hcaptcha或recaptcha
+	Secret    string `mapstructure:"secret"`     // 服务端校验使用的密钥
+	VerifyURL string `mapstructure:"verify_url"` // 为空时使用provider默认的校验地址
+	Timeout   int    `mapstructure:"timeout"`    // 校验接口的超时，单位毫秒，超时按校验失败处理
+}
+
+// BrigadeConfig 刷票检测，短时间内大量新账号或低声望账号同一方向的投票视为刷票，修改配置文件后立即生效
+type BrigadeConfig struct {
+	Enable        bool  `mapstructure:"enable"`
+	Window        int   `mapstructure:"window"`          // 统计的时间窗口，单位分钟
+	Threshold     int64 `mapstructure:"threshold"`       // 窗口内可疑投票达到多少票时触发
+	NewAccountAge int   `mapstructure:"new_account_age"` // 注册不满多少小时的账号视为新账号
+	LowKarma      int64 `mapstructure:"low_karma"`       // 声望低于这个值的账号视为低声望账号
+	FlagTTL       int   `mapstructure:"flag_ttl"`        // 触发后的标记保留多少小时，期间不会重复提交审核
+	Dampen        bool  `mapstructure:"dampen"`          // 标记期间可疑账号的投票不计入作者的声望
+}
+
+// SlowQueryConfig 超过阈值的MySQL语句和MongoDB命令记录警告日志，修改配置文件后立即生效
+type SlowQueryConfig struct {
+	Enable    bool `mapstructure:"enable"`
+	Threshold int  `mapstructure:"threshold"` // 单位毫秒
+}
+
+// FeedConfig 首页信息流，没有关注任何人的新用户看到的是活跃社区的热门帖子
+type FeedConfig struct {
+	OnboardingCommunities int `mapstructure:"onboarding_communities"` // 取最活跃的几个社区，0表示不使用，直接返回全站热门
+	OnboardingWindow      int `mapstructure:"onboarding_window"`      // 统计活跃度和热门帖子的时间范围，单位小时
+	OnboardingAge         int `mapstructure:"onboarding_age"`         // 注册不满多少小时算新用户，0表示不使用
+}
+
+// AccountAgeConfig 注册满多少小时才能执行这些操作，0表示不限制，修改配置文件后立即生效
+type AccountAgeConfig struct {
+	Post              int  `mapstructure:"post"`
+	Downvote          int  `mapstructure:"downvote"`            // 帖子和评论的反对票
+	Mention           int  `mapstructure:"mention"`             // 在帖子和评论中@其他用户
+	ExemptVerifiedEdu bool `mapstructure:"exempt_verified_edu"` // 已验证的.edu邮箱不受限制
+}
+
+// PaginationConfig 列表接口的每页条数，修改配置文件后立即生效
+type PaginationConfig struct {
+	DefaultSize int64 `mapstructure:"default_size"`  // 没有传size时使用
+	MaxSize     int64 `mapstructure:"max_size"`      // size超过时按这个值返回，不报错
+	MaxBatchIDs int   `mapstructure:"max_batch_ids"` // 按id批量查询时最多多少个id，超过时返回错误
+}
+
+// TracingConfig OpenTelemetry链路追踪，通过OTLP/HTTP上报，修改后重启生效
+type TracingConfig struct {
+	Enable     bool    `mapstructure:"enable"`
+	Endpoint   string  `mapstructure:"endpoint"`    // 采集端的地址，例如localhost:4318
+	Insecure   bool    `mapstructure:"insecure"`    // 不使用TLS连接采集端
+	SampleRate float64 `mapstructure:"sample_rate"` // 没有上游采样决定时的采样比例，0到1
+}
+
+type ReportConfig struct {
+	HideThreshold int64 `mapstructure:"hide_threshold"` // 待处理的举报达到多少条时自动隐藏帖子，0表示不隐藏
+}
+
+// LogConfig 日志文件按大小轮转，应用日志和访问日志使用相同的轮转配置
 type LogConfig struct {
-	Level      string `mapstructure:"level"`
-	Filename   string `mapstructure:"filename"`
-	MaxSize    int    `mapstructure:"max_size"`
-	MaxAge     int    `mapstructure:"max_age"`
-	MaxBackups int    `mapstructure:"max_backups"`
+	Level           string `mapstructure:"level"`
+	AccessLevel     string `mapstructure:"access_level"` // 访问日志的级别，与全局级别分开配置
+	Filename        string `mapstructure:"filename"`
+	AccessFilename  string `mapstructure:"access_filename"`  // 访问日志单独的文件，为空时写入Filename
+	MaxSize         int    `mapstructure:"max_size"`         // 单个文件的大小上限，单位MB
+	MaxAge          int    `mapstructure:"max_age"`          // 轮转后的文件保留的天数，0表示不按时间删除
+	MaxBackups      int    `mapstructure:"max_backups"`      // 轮转后的文件保留的个数，0表示不按个数删除
+	Compress        bool   `mapstructure:"compress"`         // 轮转后的文件使用gzip压缩
+	Encoding        string `mapstructure:"encoding"`         // console或json，为空时release模式使用json，其他模式使用console
+	Caller          bool   `mapstructure:"caller"`           // 是否记录调用的文件和行号
+	StacktraceLevel string `mapstructure:"stacktrace_level"` // 达到该级别的日志记录调用栈，为空时不记录
+	// 访问日志中遮盖的JSON请求体字段和查询参数，不区分大小写，修改配置文件后立即生效
+	RedactFields []string `mapstructure:"redact_fields"`
+	LogBody      bool     `mapstructure:"log_body"`     // 访问日志是否记录JSON请求体，敏感字段按RedactFields遮盖
+	MaxBodyLog   int      `mapstructure:"max_body_log"` // 记录的请求体的最大字节数，超过时不记录
 }
 
 func Init() (err error) {
 	viper.SetConfigFile("./conf/config.yaml")
+	viper.SetDefault("locale", "en")
+	viper.SetDefault("mysql.max_open_conns", 100)
+	viper.SetDefault("mysql.max_idle_conns", 10)
+	viper.SetDefault("mysql.conn_max_lifetime", 3600)
+	viper.SetDefault("mysql.auto_migrate", true)
+	viper.SetDefault("mysql.query_timeout", 5000)
+	viper.SetDefault("redis.timeout", 3000)
+	viper.SetDefault("redis.breaker_threshold", 5)
+	viper.SetDefault("redis.breaker_cooldown", 5000)
+	viper.SetDefault("redis.fallback_cache_size", 10000)
+	viper.SetDefault("mongodb.query_timeout", 5000)
+	viper.SetDefault("auth.jwt_expire", 2)
+	viper.SetDefault("auth.refresh_expire", 24*7)
+	viper.SetDefault("auth.remember_expire", 24*30)
+	viper.SetDefault("auth.max_sessions", 10)
+	viper.SetDefault("auth.allowed_email_domains", []string{"rutgers.edu", "scarletmail.rutgers.edu"})
+	viper.SetDefault("email.mode", "smtp")
+	viper.SetDefault("email.rate_per_minute", 60)
+	viper.SetDefault("email.max_attempts", 5)
+	viper.SetDefault("email.retry_backoff", 30)
+	viper.SetDefault("email.digest_time", "08:00")
+	viper.SetDefault("community.cache_ttl", 300)
+	viper.SetDefault("community.max_pinned", 3)
+	viper.SetDefault("community.max_featured", 12)
+	viper.SetDefault("community.name_min_len", 2)
+	viper.SetDefault("community.name_max_len", 32)
+	viper.SetDefault("community.reserved_names", []string{"admin", "administrator", "official", "moderator", "mod", "support", "system"})
+	viper.SetDefault("community.auto_join", false)
+	viper.SetDefault("community.karma_gate", true)
+	viper.SetDefault("community.create_min_karma", 100)
+	viper.SetDefault("community.trending_window", 24)
+	viper.SetDefault("community.trending_size", 10)
+	viper.SetDefault("community.trending_post_weight", 2)
+	viper.SetDefault("community.trending_join_weight", 1)
+	viper.SetDefault("community.trending_cache_ttl", 300)
+	viper.SetDefault("comment.max_depth", 5)
+	viper.SetDefault("comment.collapse_threshold", -5)
+	viper.SetDefault("comment.embed_size", 10)
+	viper.SetDefault("comment.embed_replies", 2)
+	viper.SetDefault("comment.author_cache_ttl", 60)
+	viper.SetDefault("comment.edit_window", 30)
+	viper.SetDefault("comment.close_after", 0)
+	viper.SetDefault("post.max_revisions", 20)
+	viper.SetDefault("post.hot_gravity", 1.8)
+	viper.SetDefault("post.hot_refresh_interval", 300)
+	viper.SetDefault("post.vote_window", 14*24)
+	viper.SetDefault("post.max_tags", 5)
+	viper.SetDefault("post.max_communities", 3)
+	viper.SetDefault("post.idempotency_ttl", 24)
+	viper.SetDefault("post.detail_cache_ttl", 60)
+	viper.SetDefault("post.schedule_tick", 30)
+	viper.SetDefault("post.schedule_skew", 60)
+	viper.SetDefault("post.related_size", 5)
+	viper.SetDefault("post.related_ttl", 600)
+	viper.SetDefault("post.poll_max_options", 10)
+	viper.SetDefault("post.controversial_min_votes", 10)
+	viper.SetDefault("post.duplicate_window", 24)
+	viper.SetDefault("post.duplicate_cross_user", true)
+	viper.SetDefault("post.min_content_length", 0)
+	viper.SetDefault("post.score_sync_interval", 60)
+	viper.SetDefault("post.score_sync_batch", 500)
+	viper.SetDefault("tag.trending_window", 24)
+	viper.SetDefault("tag.trending_size", 10)
+	viper.SetDefault("tag.max_followed", 50)
+	viper.SetDefault("tag.notify_hot", true)
+	viper.SetDefault("tag.hot_threshold", 1.0)
+	viper.SetDefault("tag.hot_interval", 300)
+	viper.SetDefault("report.hide_threshold", 5)
+	viper.SetDefault("mention.max_per_item", 10)
+	viper.SetDefault("username.change_cooldown", 30)
+	viper.SetDefault("username.reserve_days", 30)
+	viper.SetDefault("purge.enable", true)
+	viper.SetDefault("purge.interval", 24)
+	viper.SetDefault("purge.deleted_post_retention", 30)
+	viper.SetDefault("purge.read_notification_retention", 90)
+	viper.SetDefault("maintenance.read_only_allow", []string{"/api/v1/logout"})
+	viper.SetDefault("captcha.enable", false)
+	viper.SetDefault("captcha.provider", "hcaptcha")
+	viper.SetDefault("captcha.timeout", 3000)
+	viper.SetDefault("brigade.enable", true)
+	viper.SetDefault("brigade.window", 10)
+	viper.SetDefault("brigade.threshold", 10)
+	viper.SetDefault("brigade.new_account_age", 72)
+	viper.SetDefault("brigade.low_karma", 10)
+	viper.SetDefault("brigade.flag_ttl", 24)
+	viper.SetDefault("brigade.dampen", true)
+	viper.SetDefault("slow_query.enable", true)
+	viper.SetDefault("slow_query.threshold", 200)
+	viper.SetDefault("feed.onboarding_communities", 5)
+	viper.SetDefault("feed.onboarding_window", 7*24)
+	viper.SetDefault("feed.onboarding_age", 72)
+	viper.SetDefault("account_age.post", 0)
+	viper.SetDefault("account_age.downvote", 24)
+	viper.SetDefault("account_age.mention", 24)
+	viper.SetDefault("account_age.exempt_verified_edu", true)
+	viper.SetDefault("pagination.default_size", 10)
+	viper.SetDefault("pagination.max_size", 100)
+	viper.SetDefault("pagination.max_batch_ids", 100)
+	viper.SetDefault("tracing.enable", false)
+	viper.SetDefault("tracing.endpoint", "localhost:4318")
+	viper.SetDefault("tracing.insecure", true)
+	viper.SetDefault("tracing.sample_rate", 0.1)
+	viper.SetDefault("avatar.max_size", 2048)
+	viper.SetDefault("avatar.size", 256)
+	viper.SetDefault("avatar.dir", "./static/avatar")
+	viper.SetDefault("avatar.url_prefix", "/static/avatar")
+	viper.SetDefault("quiet_hours.critical_types", []string{})
+	viper.SetDefault("quiet_hours.flush_interval", 60)
+	viper.SetDefault("csrf.enable", false)
+	viper.SetDefault("csrf.cookie_name", "csrf_token")
+	viper.SetDefault("csrf.header_name", "X-CSRF-Token")
+	viper.SetDefault("csrf.cookie_path", "/")
+	viper.SetDefault("csrf.max_age", 86400)
+	viper.SetDefault("csrf.same_site", "lax")
+	viper.SetDefault("csrf.secure", true)
+	viper.SetDefault("csrf.http_only", false)
+	viper.SetDefault("signup_geo.enable", false)
+	viper.SetDefault("signup_geo.provider", "header")
+	viper.SetDefault("signup_geo.header", "CF-IPCountry")
+	viper.SetDefault("signup_geo.allow", []string{})
+	viper.SetDefault("signup_geo.deny", []string{})
+	viper.SetDefault("signup_geo.fail_open", true)
+	viper.SetDefault("webhook.max_per_community", 5)
+	viper.SetDefault("webhook.timeout", 3000)
+	viper.SetDefault("webhook.max_attempts", 6)
+	viper.SetDefault("webhook.retry_backoff", 30)
+	viper.SetDefault("two_factor.issuer", "Rutgers Online Community")
+	viper.SetDefault("two_factor.challenge_ttl", 300)
+	viper.SetDefault("two_factor.max_attempts", 5)
+	viper.SetDefault("two_factor.fail_threshold", 10)
+	viper.SetDefault("two_factor.recovery_codes", 10)
+	viper.SetDefault("attachment.dir", "./data/attachment")
+	viper.SetDefault("attachment.max_size", 10240)
+	viper.SetDefault("attachment.max_count", 5)
+	viper.SetDefault("attachment.max_total_size", 25600)
+	viper.SetDefault("attachment.allowed_types", []string{"application/pdf", "image/png", "image/jpeg", "image/gif"})
+	viper.SetDefault("export.ttl", 24)
+	viper.SetDefault("export.interval", 24)
+	viper.SetDefault("export.clean_interval", 600)
+	viper.SetDefault("log.access_level", "info")
+	viper.SetDefault("log.max_size", 100)
+	viper.SetDefault("log.max_age", 30)
+	viper.SetDefault("log.max_backups", 10)
+	viper.SetDefault("log.compress", true)
+	viper.SetDefault("log.caller", true)
+	viper.SetDefault("log.redact_fields", []string{"password", "re_password", "token", "refresh_token", "access_token", "captcha_token", "code", "email"})
+	viper.SetDefault("log.log_body", false)
+	viper.SetDefault("log.max_body_log", 4096)
+	viper.SetDefault("ratelimit.rate", 10)
+	viper.SetDefault("ratelimit.burst", 20)
+	viper.SetDefault("ratelimit.rules", map[string]interface{}{
+		"login":  map[string]interface{}{"rate": 0.2, "burst": 5},
+		"signup": map[string]interface{}{"rate": 0.02, "burst": 3},
+		// 找回密码会发送邮件，和登录分开计数，避免互相占用额度
+		"password_forgot": map[string]interface{}{"rate": 0.01, "burst": 3},
+		"password_reset":  map[string]interface{}{"rate": 0.1, "burst": 5},
+	})
+	viper.SetDefault("metrics.enable", true)
+	viper.SetDefault("metrics.path", "/metrics")
+	viper.SetDefault("websocket.max_conns_per_user", 5)
+	viper.SetDefault("websocket.max_subscribers_per_post", 200)
+	viper.SetDefault("cors.allow_origins", []string{})
+	viper.SetDefault("cors.allow_methods", []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"})
+	viper.SetDefault("cors.allow_headers", []string{"Authorization", "Content-Type", "X-Request-ID", "X-CSRF-Token"})
+	viper.SetDefault("cors.expose_headers", []string{"X-Request-ID", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Deprecation", "Sunset", "Link", "ETag"})
+	viper.SetDefault("cors.allow_credentials", true)
+	viper.SetDefault("cors.max_age", 3600)
+	viper.SetDefault("request.max_body_size", 1024)
+	viper.SetDefault("request.timeout", 10)
+	viper.SetDefault("api.msgpack", true)
+	viper.SetDefault("post.search_snippet_length", 160)
+	viper.SetDefault("post.slug_max_length", 60)
+	viper.SetDefault("post.slug_follow_title", false)
+	viper.SetDefault("compress.enable", true)
+	viper.SetDefault("compress.min_size", 1024)
+	viper.SetDefault("compress.brotli", true)
+	viper.SetDefault("shutdown.timeout", 5)
+	viper.SetDefault("snowflake.lease_machine_id", false)
+	viper.SetDefault("snowflake.lease_ttl", 60)
+	viper.SetDefault("content_filter.words", []string{})
+	viper.SetDefault("content_filter.mode", "mask")
+	viper.SetDefault("link_filter.enable", true)
+	viper.SetDefault("link_filter.blocklist", []string{})
+	viper.SetDefault("link_filter.allowlist", []string{})
+	viper.SetDefault("link_filter.action", "hold")
+	viper.SetDefault("link_filter.new_account_age", 72)
+	viper.SetDefault("link_filter.new_account_max_links", 0)
+	viper.SetDefault("search.types", []string{"posts", "comments", "users"})
+	viper.SetDefault("search.all_size", 5)
+	viper.SetDefault("vote.undo_window", 10)
+	viper.SetDefault("response_cache.size", 1000)
+	viper.SetDefault("response_cache.ttl", 30)
+	viper.SetDefault("response_cache.grace", 60)
+	viper.SetDefault("creation_limit.posts_per_hour", 10)
+	viper.SetDefault("creation_limit.comments_per_minute", 10)
+	viper.SetDefault("creation_limit.new_account_age", 24)
+	viper.SetDefault("creation_limit.new_posts_per_hour", 3)
+	viper.SetDefault("creation_limit.new_comments_per_minute", 3)
+	viper.SetDefault("login_throttle.window", 15)
+	viper.SetDefault("login_throttle.cooldown", 15)
+	viper.SetDefault("login_throttle.account_threshold", 10)
+	viper.SetDefault("login_throttle.account_cooldown", 5)
+	viper.SetDefault("login_throttle.ip_threshold", 20)
+	viper.SetDefault("oauth.google_client_id", "")
+	viper.SetDefault("text_limit.title", 128)
+	viper.SetDefault("text_limit.post", 8192)
+	viper.SetDefault("text_limit.comment", 2000)
+	viper.SetDefault("karma.post_vote_weight", 2)
+	viper.SetDefault("karma.comment_vote_weight", 1)
+	viper.SetDefault("karma.persist_interval", 60)
+	viper.SetDefault("vote_weight.enable", true)
+	viper.SetDefault("vote_weight.min_weight", 0.25)
+	viper.SetDefault("vote_weight.full_karma", 100)
+	viper.SetDefault("vote_weight.new_account_age", 72)
+	viper.SetDefault("preview.fetch_opengraph", false)
+	viper.SetDefault("preview.fetch_timeout", 2000)
+	viper.SetDefault("preview.cache_ttl", 24)
+	viper.SetDefault("preview.failure_cache_ttl", 30)
+	viper.SetDefault("shutdown.drain_timeout", 3)
+	viper.SetDefault("startup.retry_attempts", 5)
+	viper.SetDefault("startup.retry_backoff", 1000)
+	viper.SetDefault("startup.retry_max_backoff", 15000)
 	err = viper.ReadInConfig() // 读取配置信息
 	if err != nil {            // 读取配置信息失败
 		fmt.Printf("viper.ReadInConfig() failed, err:%v\n", err)
 		panic(fmt.Errorf("Fatal error while reading config file: %v\n", err))
-		return
 	}
 	if err := viper.Unmarshal(&Conf); err != nil {
 		panic(fmt.Errorf("unmarshal to Conf failed, err:%v", err))
 	}
 
+	current.Store(Conf)
+
 	// 监控配置文件变化
 	viper.WatchConfig()
 	viper.OnConfigChange(func(in fsnotify.Event) {
-		fmt.Println("Config file modified...")
-		viper.Unmarshal(&Conf)
+		zap.L().Info("config file modified, reloading", zap.String("file", in.Name))
+		reload()
 	})
 
 	return
@@ -88,3 +863,54 @@ func Init() (err error) {
 	//	panic(err)
 	//}
 }
+
+// reload 重新解析配置文件并替换 Current()
+// 数据库连接、端口等无法在运行时安全修改的配置保持原值，只记录日志
+func reload() {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	fresh := new(AppConfig)
+	if err := viper.Unmarshal(fresh); err != nil {
+		zap.L().Error("reload config failed, keep the old one", zap.Error(err))
+		return
+	}
+	old := Current()
+	keepStatic(old, fresh)
+	if err := Validate(fresh); err != nil {
+		zap.L().Error("reloaded config is invalid, keep the old one", zap.Error(err))
+		return
+	}
+	current.Store(fresh)
+
+	hooksMu.Lock()
+	fns := append([]func(*AppConfig){}, hooks...)
+	hooksMu.Unlock()
+	for _, fn := range fns {
+		fn(fresh)
+	}
+}
+
+// keepStatic 把不能热更新的配置还原为旧值，有变化时记录被忽略的配置项
+func keepStatic(old, fresh *AppConfig) {
+	ignored := func(name string, changed bool) {
+		if changed {
+			zap.L().Warn("config change ignored, restart required", zap.String("key", name))
+		}
+	}
+	ignored("mode", old.Mode != fresh.Mode)
+	ignored("port", old.Port != fresh.Port)
+	ignored("start_time", old.StartTime != fresh.StartTime)
+	ignored("machine_id", old.MachineID != fresh.MachineID)
+	ignored("mysql", !reflect.DeepEqual(old.MySQLConfig, fresh.MySQLConfig))
+	ignored("redis", !reflect.DeepEqual(old.RedisConfig, fresh.RedisConfig))
+	ignored("mongodb", !reflect.DeepEqual(old.MongodbConfig, fresh.MongodbConfig))
+
+	fresh.Mode = old.Mode
+	fresh.Port = old.Port
+	fresh.StartTime = old.StartTime
+	fresh.MachineID = old.MachineID
+	fresh.MySQLConfig = old.MySQLConfig
+	fresh.RedisConfig = old.RedisConfig
+	fresh.MongodbConfig = old.MongodbConfig
+}
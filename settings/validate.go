@@ -0,0 +1,197 @@
+package settings
+
+import (
+	"fmt"
+	"go-web-app/pkg/snowflake"
+	"net"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// ValidationError 配置中所有的问题，一次报告出来，不需要改一个重启一次
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%d config problem(s):\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+type validator struct {
+	problems []string
+}
+
+// check ok为false时记录key的问题
+func (v *validator) check(ok bool, key, format string, args ...interface{}) {
+	if !ok {
+		v.problems = append(v.problems, key+": "+fmt.Sprintf(format, args...))
+	}
+}
+
+// section 配置文件中缺少必需的段时记录问题，返回false时调用方跳过这个段的其他检查
+func (v *validator) section(present bool, key string) bool {
+	v.check(present, key, "section is missing")
+	return present
+}
+
+func (v *validator) notEmpty(value, key string) {
+	v.check(strings.TrimSpace(value) != "", key, "must not be empty")
+}
+
+func (v *validator) port(port int, key string) {
+	v.check(port > 0 && port <= 65535, key, "must be between 1 and 65535, got %d", port)
+}
+
+func (v *validator) positive(n int, key string) {
+	v.check(n > 0, key, "must be positive, got %d", n)
+}
+
+func (v *validator) nonNegative(n int, key string) {
+	v.check(n >= 0, key, "must not be negative, got %d", n)
+}
+
+func (v *validator) oneOf(value, key string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.check(false, key, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+}
+
+func (v *validator) logLevel(level, key string) {
+	if level == "" {
+		return
+	}
+	var l zapcore.Level
+	v.check(l.UnmarshalText([]byte(level)) == nil, key, "unknown log level %q", level)
+}
+
+func (v *validator) ids(ids []int64, key string) {
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		v.check(id > 0, key, "community id must be positive, got %d", id)
+		v.check(!seen[id], key, "community id %d is listed more than once", id)
+		seen[id] = true
+	}
+}
+
+func (v *validator) proxies(entries []string, key string) {
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		_, _, err := net.ParseCIDR(e)
+		v.check(err == nil || net.ParseIP(e) != nil, key, "must be an IP or CIDR, got %q", e)
+	}
+}
+
+// Validate 检查启动需要的配置是否完整、取值是否合理，在初始化任何依赖之前调用
+// 社区是否存在需要查询数据库，在连接mysql之后由logic.CheckCommunityConfig检查
+func Validate(cfg *AppConfig) error {
+	v := new(validator)
+	v.port(cfg.Port, "port")
+	_, err := time.Parse("2006-01-02", cfg.StartTime)
+	v.check(err == nil, "start_time", "must be a date like 2020-07-01, got %q", cfg.StartTime)
+	if cfg.SnowflakeConfig == nil || !cfg.SnowflakeConfig.LeaseMachineID {
+		v.check(cfg.MachineID >= 0 && cfg.MachineID <= snowflake.MaxMachineID(), "machine_id",
+			"must be between 0 and %d, got %d", snowflake.MaxMachineID(), cfg.MachineID)
+	} else {
+		v.positive(cfg.SnowflakeConfig.LeaseTTL, "snowflake.lease_ttl")
+	}
+
+	if v.section(cfg.LogConfig != nil, "log") {
+		v.logLevel(cfg.LogConfig.Level, "log.level")
+		v.logLevel(cfg.LogConfig.AccessLevel, "log.access_level")
+		v.logLevel(cfg.LogConfig.StacktraceLevel, "log.stacktrace_level")
+		if cfg.LogConfig.Encoding != "" {
+			v.oneOf(cfg.LogConfig.Encoding, "log.encoding", "console", "json")
+		}
+	}
+	if v.section(cfg.MySQLConfig != nil, "mysql") {
+		v.notEmpty(cfg.MySQLConfig.Host, "mysql.host")
+		v.notEmpty(cfg.MySQLConfig.User, "mysql.user")
+		v.notEmpty(cfg.MySQLConfig.DB, "mysql.db")
+		v.port(cfg.MySQLConfig.Port, "mysql.port")
+		v.nonNegative(cfg.MySQLConfig.MaxOpenConns, "mysql.max_open_conns")
+		v.nonNegative(cfg.MySQLConfig.MaxIdleConns, "mysql.max_idle_conns")
+		v.nonNegative(cfg.MySQLConfig.ConnMaxLifetime, "mysql.conn_max_lifetime")
+		v.nonNegative(cfg.MySQLConfig.QueryTimeout, "mysql.query_timeout")
+	}
+	if v.section(cfg.RedisConfig != nil, "redis") {
+		v.notEmpty(cfg.RedisConfig.Host, "redis.host")
+		v.port(cfg.RedisConfig.Port, "redis.port")
+		v.nonNegative(cfg.RedisConfig.DB, "redis.db")
+		v.nonNegative(cfg.RedisConfig.Timeout, "redis.timeout")
+		v.nonNegative(cfg.RedisConfig.BreakerThreshold, "redis.breaker_threshold")
+		if cfg.RedisConfig.BreakerThreshold > 0 {
+			v.positive(cfg.RedisConfig.BreakerCooldown, "redis.breaker_cooldown")
+		}
+		v.nonNegative(cfg.RedisConfig.FallbackCacheSize, "redis.fallback_cache_size")
+	}
+	if v.section(cfg.MongodbConfig != nil, "mongodb") {
+		v.notEmpty(cfg.MongodbConfig.Host, "mongodb.host")
+		v.notEmpty(cfg.MongodbConfig.DB, "mongodb.db")
+		v.nonNegative(cfg.MongodbConfig.QueryTimeout, "mongodb.query_timeout")
+	}
+	if v.section(cfg.AuthConfig != nil, "auth") {
+		v.positive(cfg.AuthConfig.JwtExpire, "auth.jwt_expire")
+		v.positive(cfg.AuthConfig.RefreshExpire, "auth.refresh_expire")
+		v.positive(cfg.AuthConfig.RememberExpire, "auth.remember_expire")
+		v.nonNegative(cfg.AuthConfig.MaxSessions, "auth.max_sessions")
+	}
+	if cfg.LoginThrottleConfig != nil {
+		v.positive(cfg.LoginThrottleConfig.Window, "login_throttle.window")
+		v.nonNegative(cfg.LoginThrottleConfig.Cooldown, "login_throttle.cooldown")
+		v.check(cfg.LoginThrottleConfig.AccountCooldown >= 0 && cfg.LoginThrottleConfig.AccountCooldown <= 60, "login_throttle.account_cooldown",
+			"must be between 0 and 60 minutes, got %d", cfg.LoginThrottleConfig.AccountCooldown)
+	}
+	if cfg.EmailConfig != nil {
+		v.oneOf(cfg.EmailConfig.Mode, "email.mode", "smtp", "log")
+		// 没有配置host时不发送邮件
+		if cfg.EmailConfig.Mode == "smtp" && cfg.EmailConfig.Host != "" {
+			v.port(cfg.EmailConfig.Port, "email.port")
+		}
+		if cfg.EmailConfig.DigestTime != "" {
+			_, err := time.Parse("15:04", cfg.EmailConfig.DigestTime)
+			v.check(err == nil, "email.digest_time", "must be a time like 08:00, got %q", cfg.EmailConfig.DigestTime)
+		}
+	}
+	if v.section(cfg.StartupConfig != nil, "startup") {
+		v.positive(cfg.StartupConfig.RetryAttempts, "startup.retry_attempts")
+		v.nonNegative(cfg.StartupConfig.RetryBackoff, "startup.retry_backoff")
+		v.check(cfg.StartupConfig.RetryMaxBackoff >= cfg.StartupConfig.RetryBackoff, "startup.retry_max_backoff",
+			"must not be less than startup.retry_backoff (%d), got %d", cfg.StartupConfig.RetryBackoff, cfg.StartupConfig.RetryMaxBackoff)
+	}
+	if cfg.CommunityConfig != nil {
+		v.nonNegative(cfg.CommunityConfig.CacheTTL, "community.cache_ttl")
+		v.ids(cfg.CommunityConfig.DefaultCommunities, "community.default_communities")
+	}
+	if cfg.PostConfig != nil {
+		v.check(cfg.PostConfig.DefaultCommunity >= 0, "post.default_community", "must not be negative, got %d", cfg.PostConfig.DefaultCommunity)
+	}
+	if cfg.ContentFilterConfig != nil && cfg.ContentFilterConfig.Mode != "" {
+		v.oneOf(cfg.ContentFilterConfig.Mode, "content_filter.mode", "reject", "mask")
+	}
+	if cfg.LinkFilterConfig != nil {
+		v.oneOf(cfg.LinkFilterConfig.Action, "link_filter.action", "reject", "hold")
+	}
+	if cfg.RequestConfig != nil {
+		v.proxies(cfg.RequestConfig.TrustedProxies, "request.trusted_proxies")
+	}
+	if cfg.ResponseCacheConfig != nil {
+		v.nonNegative(cfg.ResponseCacheConfig.TTL, "response_cache.ttl")
+		v.nonNegative(cfg.ResponseCacheConfig.Grace, "response_cache.grace")
+		v.nonNegative(cfg.ResponseCacheConfig.Size, "response_cache.size")
+		for name, p := range cfg.ResponseCacheConfig.Endpoints {
+			if p != nil {
+				v.nonNegative(p.TTL, "response_cache.endpoints."+name+".ttl")
+				v.nonNegative(p.Grace, "response_cache.endpoints."+name+".grace")
+			}
+		}
+	}
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
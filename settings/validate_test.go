@@ -0,0 +1,62 @@
+package settings
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validConfig() *AppConfig {
+	return &AppConfig{
+		Port:          8080,
+		StartTime:     "2020-07-01",
+		MachineID:     1,
+		LogConfig:     &LogConfig{Level: "debug", Encoding: "json"},
+		MySQLConfig:   &MySQLConfig{Host: "127.0.0.1", User: "root", DB: "bluebell", Port: 3306},
+		RedisConfig:   &RedisConfig{Host: "127.0.0.1", Port: 6379},
+		MongodbConfig: &MongodbConfig{Host: "mongodb://127.0.0.1:27017", DB: "bluebell"},
+		AuthConfig:    &AuthConfig{JwtExpire: 1, RefreshExpire: 24, RememberExpire: 720},
+		StartupConfig: &StartupConfig{RetryAttempts: 3, RetryBackoff: 1, RetryMaxBackoff: 10},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(validConfig()))
+
+	// 可选的段存在时也要检查
+	cfg := validConfig()
+	cfg.CommunityConfig = &CommunityConfig{DefaultCommunities: []int64{1, 2}}
+	cfg.ContentFilterConfig = &ContentFilterConfig{Mode: "mask"}
+	cfg.RequestConfig = &RequestConfig{TrustedProxies: []string{"10.0.0.1", "172.16.0.0/12", "::1"}}
+	assert.NoError(t, Validate(cfg))
+}
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	cfg := validConfig()
+	cfg.Port = 0
+	cfg.StartTime = "07/01/2020"
+	cfg.LogConfig.Level = "verbose"
+	cfg.MySQLConfig.Host = " "
+	cfg.RedisConfig = nil
+	cfg.StartupConfig.RetryMaxBackoff = 0
+	cfg.CommunityConfig = &CommunityConfig{DefaultCommunities: []int64{1, 1, -2}}
+	cfg.RequestConfig = &RequestConfig{TrustedProxies: []string{"10.0.0.0/8", "proxy.local"}}
+
+	err := Validate(cfg)
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr))
+	assert.Equal(t, []string{
+		"port: must be between 1 and 65535, got 0",
+		`start_time: must be a date like 2020-07-01, got "07/01/2020"`,
+		`log.level: unknown log level "verbose"`,
+		"mysql.host: must not be empty",
+		"redis: section is missing",
+		"startup.retry_max_backoff: must not be less than startup.retry_backoff (1), got 0",
+		"community.default_communities: community id 1 is listed more than once",
+		"community.default_communities: community id must be positive, got -2",
+		`request.trusted_proxies: must be an IP or CIDR, got "proxy.local"`,
+	}, verr.Problems)
+	assert.Contains(t, err.Error(), "9 config problem(s)")
+}